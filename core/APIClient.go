@@ -12,25 +12,33 @@ package core
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"reflect"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
 
 	"github.com/golang/protobuf/ptypes/empty"
 	pb "github.com/hpc/kraken/core/proto"
 	"github.com/hpc/kraken/lib"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 var _ lib.APIClient = (*APIClient)(nil)
 
 type APIClient struct {
 	sock    string
+	creds   credentials.TransportCredentials // nil for the local, insecure UNIX socket
 	self    lib.NodeID
 	logChan chan LoggerEvent
 	log     lib.Logger
+	tracer  *Tracer
+	trace   string // traceparent to send with calls, see WithTrace
+	who     string // principal to make calls on behalf of, see WithPrincipal
 }
 
 func NewAPIClient(sock string) *APIClient {
@@ -40,10 +48,44 @@ func NewAPIClient(sock string) *APIClient {
 	return a
 }
 
+// NewAPIClientWithTLS creates a client for the API of a remote kraken at addr (host:port).
+// tc says how to verify the server, and can have a client certificate to identify ourselves with (see lib.NewClientTLSConfig).
+func NewAPIClientWithTLS(addr string, tc *tls.Config) *APIClient {
+	a := &APIClient{
+		sock:  addr,
+		creds: credentials.NewTLS(tc),
+	}
+	return a
+}
+
 func (a *APIClient) Self() lib.NodeID { return a.self }
 
 func (a *APIClient) SetSelf(s lib.NodeID) { a.self = s }
 
+// Tracer is the tracer for this module process; it is nil (but safe to use) if tracing is disabled
+func (a *APIClient) Tracer() *Tracer { return a.tracer }
+
+func (a *APIClient) SetTracer(t *Tracer) { a.tracer = t }
+
+// SetLogger sets where the client logs, e.g. stream errors; LoggerInit sets it for modules
+func (a *APIClient) SetLogger(l lib.Logger) { a.log = l }
+
+// WithTrace returns a copy of the client that sends a traceparent with every call it makes,
+// so that work done on kraken's side is part of the trace
+func (a *APIClient) WithTrace(traceparent string) *APIClient {
+	c := *a
+	c.trace = traceparent
+	return &c
+}
+
+// WithPrincipal returns a copy of the client that makes calls on behalf of principal,
+// so they are subject to the authorization policy
+func (a *APIClient) WithPrincipal(principal string) lib.APIClient {
+	c := *a
+	c.who = principal
+	return &c
+}
+
 func (a *APIClient) QueryCreate(n lib.Node) (r lib.Node, e error) {
 	q := &pb.Query{
 		Payload: &pb.Query_Node{
@@ -106,6 +148,37 @@ func (a *APIClient) QueryUpdateDsc(n lib.Node) (r lib.Node, e error) {
 	return
 }
 
+func (a *APIClient) QueryUpdateIf(n lib.Node, version string) (r lib.Node, e error) {
+	q := &pb.Query{
+		Payload: &pb.Query_Node{
+			Node: n.Message().(*pb.Node),
+		},
+		Version: version,
+	}
+	rv, e := a.oneshot("QueryUpdate", reflect.ValueOf(q))
+	if e != nil {
+		return
+	}
+	r = NewNodeFromMessage(rv.Interface().(*pb.Query).GetNode())
+	return
+}
+
+func (a *APIClient) QuerySetValue(url string, v reflect.Value) (r reflect.Value, e error) {
+	var j []byte
+	if j, e = MarshalValueJSON(v); e != nil {
+		return
+	}
+	q := &pb.Query{
+		URL:     url,
+		Payload: &pb.Query_Text{Text: string(j)},
+	}
+	rv, e := a.oneshot("QuerySetValue", reflect.ValueOf(q))
+	if e != nil {
+		return
+	}
+	return UnmarshalValueJSON([]byte(rv.Interface().(*pb.Query).GetText()), v.Type())
+}
+
 func (a *APIClient) QueryDelete(id string) (r lib.Node, e error) {
 	q := &pb.Query{URL: id}
 	rv, e := a.oneshot("QueryDelete", reflect.ValueOf(q))
@@ -192,6 +265,18 @@ func (a *APIClient) QueryNodeMutationPath(id string) (r pb.MutationPath, e error
 	return
 }
 
+func (a *APIClient) QueryNodeMutationHistory(id string) (r pb.MutationHistory, e error) {
+	q := &pb.Query{URL: lib.NodeURLJoin(id, "/graph/history")}
+	rv, e := a.oneshot("QueryNodeMutationHistory", reflect.ValueOf(q))
+	if e != nil {
+		return
+	}
+	if h := rv.Interface().(*pb.Query).GetMutationHistory(); h != nil {
+		r = *h
+	}
+	return
+}
+
 func (a *APIClient) QueryDeleteAll() (r []lib.Node, e error) {
 	q := &empty.Empty{}
 	rvs, e := a.oneshot("QueryDeleteAll", reflect.ValueOf(q))
@@ -225,16 +310,80 @@ func (a *APIClient) QueryFrozen() (r bool, e error) {
 	return
 }
 
+// Metrics gets kraken's metrics in the Prometheus text exposition format
+func (a *APIClient) Metrics() (r string, e error) {
+	q := &empty.Empty{}
+	rv, e := a.oneshot("Metrics", reflect.ValueOf(q))
+	if e != nil {
+		return
+	}
+	r = rv.Interface().(*pb.MetricsReply).GetText()
+	return
+}
+
+// ServiceList gets the status of every service instance
+func (a *APIClient) ServiceList() (r []*pb.ServiceStatus, e error) {
+	rv, e := a.oneshot("ServiceList", reflect.ValueOf(&empty.Empty{}))
+	if e != nil {
+		return
+	}
+	r = rv.Interface().(*pb.ServiceStatusList).GetServices()
+	return
+}
+
+func (a *APIClient) ServiceGet(id string) (*pb.ServiceStatus, error) {
+	return a.serviceCall("ServiceGet", &pb.ServiceRequest{Id: id})
+}
+
+func (a *APIClient) ServiceStart(id string) (*pb.ServiceStatus, error) {
+	return a.serviceCall("ServiceStart", &pb.ServiceRequest{Id: id})
+}
+
+func (a *APIClient) ServiceStop(id string) (*pb.ServiceStatus, error) {
+	return a.serviceCall("ServiceStop", &pb.ServiceRequest{Id: id})
+}
+
+// ServiceRestart returns once the service has been told to stop; it is started again once it has
+func (a *APIClient) ServiceRestart(id string) (*pb.ServiceStatus, error) {
+	return a.serviceCall("ServiceRestart", &pb.ServiceRequest{Id: id})
+}
+
+// ServiceConfigure sets a new config for a service; cfg must be of the type its module's NewConfig gives
+func (a *APIClient) ServiceConfigure(id string, cfg proto.Message) (r *pb.ServiceStatus, e error) {
+	var ca *any.Any
+	if ca, e = ptypes.MarshalAny(cfg); e != nil {
+		return
+	}
+	return a.serviceCall("ServiceConfigure", &pb.ServiceRequest{Id: id, Config: ca})
+}
+
+// ServiceHeartbeat tells kraken the service is alive.  Once a service sends heartbeats, it must send the next
+// within a few intervals, or kraken considers it hung and kills it.
+func (a *APIClient) ServiceHeartbeat(id string, interval time.Duration) (e error) {
+	_, e = a.oneshot("ServiceHeartbeat", reflect.ValueOf(&pb.ServiceHeartbeatRequest{Id: id, Interval: ptypes.DurationProto(interval)}))
+	return
+}
+
 func (a *APIClient) ServiceInit(id string, module string) (c <-chan lib.ServiceControl, e error) {
+	return a.serviceInit(&pb.ServiceInitRequest{Id: id, Module: module})
+}
+
+// PluginInit is ServiceInit for a plugin, which registers what it provides as it calls in
+func (a *APIClient) PluginInit(id string, module string, reg *pb.PluginRegistration) (c <-chan lib.ServiceControl, e error) {
+	return a.serviceInit(&pb.ServiceInitRequest{Id: id, Module: module, Plugin: reg})
+}
+
+func (a *APIClient) serviceInit(sir *pb.ServiceInitRequest) (c <-chan lib.ServiceControl, e error) {
+	id := sir.GetId()
 	var stream grpc.ClientStream
-	stream, e = a.serverStream("ServiceInit", reflect.ValueOf(&pb.ServiceInitRequest{Id: id, Module: module}))
+	stream, e = a.serverStream("ServiceInit", reflect.ValueOf(sir))
 	if e != nil {
 		return
 	}
 	// read our init
 	init, e := stream.(pb.API_ServiceInitClient).Recv()
-	if e != nil || init.Command != pb.ServiceControl_INIT {
-		e = fmt.Errorf("%s failed to init, (got %v, err %v)", id, init.Command, e)
+	if e != nil || init.GetCommand() != pb.ServiceControl_INIT {
+		e = fmt.Errorf("%s failed to init, (got %v, err %v)", id, init.GetCommand(), e)
 		return
 	}
 	self := &pb.Node{}
@@ -279,6 +428,8 @@ func (a *APIClient) MutationInit(id string, module string) (c <-chan lib.Event,
 					NodeCfg:  cfg,
 					NodeDsc:  dsc,
 					Mutation: [2]string{mc.GetModule(), mc.GetId()},
+					Attempt:  mc.GetAttempt(),
+					Trace:    mc.GetTraceparent(),
 				})
 		}
 	}()
@@ -286,53 +437,58 @@ func (a *APIClient) MutationInit(id string, module string) (c <-chan lib.Event,
 	return
 }
 
+// EventInit streams all events.  If this service instance had an event stream before,
+// events it missed since then get replayed first (if kraken is journaling events).
 func (a *APIClient) EventInit(id string, module string) (c <-chan lib.Event, e error) {
-	var stream grpc.ClientStream
-	if stream, e = a.serverStream("EventInit", reflect.ValueOf(&pb.ServiceInitRequest{Id: id, Module: module})); e != nil {
+	return a.eventInit(&pb.ServiceInitRequest{Id: id, Module: module, Resume: true})
+}
+
+// EventInitFrom streams all events, starting with a replay of journaled events from sequence number seq
+func (a *APIClient) EventInitFrom(id string, module string, seq uint64) (c <-chan lib.Event, e error) {
+	return a.eventInit(&pb.ServiceInitRequest{Id: id, Module: module, Replay: seq})
+}
+
+// Watch streams events, starting with a replay of journaled events from sequence number from (0 for none).
+// If types is not empty, only those types of events are sent.  The channel is closed when ctx is done.
+func (a *APIClient) Watch(ctx context.Context, from uint64, types []lib.EventType) (c <-chan lib.Event, e error) {
+	var conn *grpc.ClientConn
+	if conn, e = a.dial(); e != nil {
+		return
+	}
+	wr := &pb.WatchRequest{From: from}
+	if a.self != nil {
+		wr.Id = a.self.String()
+	}
+	for pt, t := range EventControlType {
+		for _, want := range types {
+			if t == want {
+				wr.Types = append(wr.Types, pt)
+			}
+		}
+	}
+	var stream pb.API_WatchClient
+	if stream, e = pb.NewAPIClient(conn).Watch(PrincipalContextWith(ctx, a.who), wr); e != nil {
+		conn.Close()
 		return
 	}
 	cc := make(chan lib.Event)
 	go func() {
+		defer conn.Close()
+		defer close(cc)
 		for {
-			var ec *pb.EventControl
-			if ec, e = stream.(pb.API_EventInitClient).Recv(); e != nil {
-				a.Logf(lib.LLERROR, "got stream read error on event stream: %v\n", e)
+			ec, e := stream.Recv()
+			if e != nil {
+				if ctx.Err() == nil {
+					a.Logf(lib.LLERROR, "got stream read error on watch stream: %v\n", e)
+				}
 				return
 			}
-			switch ec.GetType() {
-			case pb.EventControl_Mutation:
-				event := ec.GetMutationControl()
-				cfg := NewNodeFromMessage(event.GetCfg())
-				dsc := NewNodeFromMessage(event.GetDsc())
-				cc <- NewEvent(
-					lib.Event_STATE_MUTATION,
-					cfg.ID().String(),
-					&MutationEvent{
-						Type:     event.GetType(),
-						NodeCfg:  cfg,
-						NodeDsc:  dsc,
-						Mutation: [2]string{event.GetModule(), event.GetId()},
-					})
-			case pb.EventControl_StateChange:
-				event := ec.GetStateChangeControl()
-				cc <- NewEvent(
-					lib.Event_STATE_CHANGE,
-					event.GetUrl(),
-					&StateChangeEvent{
-						Type:  event.GetType(),
-						URL:   event.GetUrl(),
-						Value: reflect.ValueOf(event.GetValue()),
-					})
-			case pb.EventControl_Discovery:
-				event := ec.GetDiscoveryEvent()
-				cc <- NewEvent(
-					lib.Event_DISCOVERY,
-					event.GetUrl(),
-					&DiscoveryEvent{
-						ID:      event.GetId(),
-						URL:     event.GetUrl(),
-						ValueID: event.GetValueId(),
-					})
+			if v := eventFromProto(ec); v != nil {
+				select {
+				case cc <- v:
+				case <-ctx.Done():
+					return
+				}
 			}
 		}
 	}()
@@ -343,7 +499,7 @@ func (a *APIClient) EventInit(id string, module string) (c <-chan lib.Event, e e
 func (a *APIClient) DiscoveryInit(id string) (c chan<- lib.Event, e error) {
 	var stream pb.API_DiscoveryInitClient
 	var conn *grpc.ClientConn
-	if conn, e = grpc.Dial(a.sock, grpc.WithInsecure()); e != nil {
+	if conn, e = a.dial(); e != nil {
 		return
 	}
 	client := pb.NewAPIClient(conn)
@@ -360,9 +516,10 @@ func (a *APIClient) DiscoveryInit(id string) (c chan<- lib.Event, e error) {
 				continue
 			}
 			d := &pb.DiscoveryEvent{
-				Id:      id,
-				Url:     de.URL,
-				ValueId: de.ValueID,
+				Id:          id,
+				Url:         de.URL,
+				ValueId:     de.ValueID,
+				Traceparent: de.Trace,
 			}
 			if e = stream.Send(d); e != nil {
 				a.Logf(CRITICAL, "got stream send error on discovery stream: %v\n", e)
@@ -377,7 +534,7 @@ func (a *APIClient) DiscoveryInit(id string) (c chan<- lib.Event, e error) {
 func (a *APIClient) LoggerInit(si string) (e error) {
 	var stream pb.API_LoggerInitClient
 	var conn *grpc.ClientConn
-	if conn, e = grpc.Dial(a.sock, grpc.WithInsecure()); e != nil {
+	if conn, e = a.dial(); e != nil {
 		return
 	}
 	client := pb.NewAPIClient(conn)
@@ -409,9 +566,47 @@ func (a *APIClient) LoggerInit(si string) (e error) {
 // use reflection to call API methods by name and encapsulate
 // all of the one-time connection symantics
 // this is convoluted, but makes everything else DRYer
+func (a *APIClient) eventInit(sir *pb.ServiceInitRequest) (c <-chan lib.Event, e error) {
+	var stream grpc.ClientStream
+	if stream, e = a.serverStream("EventInit", reflect.ValueOf(sir)); e != nil {
+		return
+	}
+	cc := make(chan lib.Event)
+	go func() {
+		for {
+			var ec *pb.EventControl
+			if ec, e = stream.(pb.API_EventInitClient).Recv(); e != nil {
+				a.Logf(lib.LLERROR, "got stream read error on event stream: %v\n", e)
+				return
+			}
+			if v := eventFromProto(ec); v != nil {
+				cc <- v
+			}
+		}
+	}()
+	c = cc
+	return
+}
+
+func (a *APIClient) dial() (*grpc.ClientConn, error) {
+	if a.creds != nil {
+		return grpc.Dial(a.sock, grpc.WithTransportCredentials(a.creds))
+	}
+	return grpc.Dial(a.sock, grpc.WithInsecure())
+}
+
+func (a *APIClient) serviceCall(call string, sr *pb.ServiceRequest) (r *pb.ServiceStatus, e error) {
+	rv, e := a.oneshot(call, reflect.ValueOf(sr))
+	if e != nil {
+		return
+	}
+	r = rv.Interface().(*pb.ServiceStatus)
+	return
+}
+
 func (a *APIClient) oneshot(call string, in reflect.Value) (out reflect.Value, e error) {
 	var conn *grpc.ClientConn
-	if conn, e = grpc.Dial(a.sock, grpc.WithInsecure()); e != nil {
+	if conn, e = a.dial(); e != nil {
 		return
 	}
 	defer conn.Close()
@@ -421,7 +616,7 @@ func (a *APIClient) oneshot(call string, in reflect.Value) (out reflect.Value, e
 		e = fmt.Errorf("no such API call: %s", call)
 		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(PrincipalContextWith(TraceContextWith(context.Background(), a.trace), a.who), time.Second)
 	defer cancel()
 	r := fv.Call([]reflect.Value{reflect.ValueOf(ctx), in})
 	if len(r) != 2 {
@@ -438,7 +633,7 @@ func (a *APIClient) oneshot(call string, in reflect.Value) (out reflect.Value, e
 
 func (a *APIClient) serverStream(call string, in reflect.Value) (out grpc.ClientStream, e error) {
 	var conn *grpc.ClientConn
-	conn, e = grpc.Dial(a.sock, grpc.WithInsecure())
+	conn, e = a.dial()
 	if e != nil {
 		return
 	}
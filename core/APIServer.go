@@ -13,17 +13,29 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
+	"os"
+	"reflect"
+	"sync"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 
 	"github.com/golang/protobuf/ptypes/empty"
 	pb "github.com/hpc/kraken/core/proto"
 	"github.com/hpc/kraken/lib"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 ///////////////////////
@@ -37,6 +49,7 @@ type DiscoveryEvent struct {
 	ID      string // ID of a service instance
 	URL     string // fully qualified, with node
 	ValueID string
+	Trace   string // W3C traceparent this discovery is part of, if any
 }
 
 func (de *DiscoveryEvent) String() string {
@@ -53,12 +66,24 @@ var _ pb.APIServer = (*APIServer)(nil)
 type APIServer struct {
 	nlist net.Listener
 	ulist net.Listener
+	rlist net.Listener // remote clients, over TLS
+	rtls  *tls.Config
 	query *QueryEngine
 	log   lib.Logger
 	em    lib.EventEmitter
 	sm    lib.ServiceManager
 	schan chan<- lib.EventListener
 	self  lib.NodeID
+	ede   lib.EventDispatchEngine
+	// cursors track the last event sequence number sent to each service instance
+	cursors map[string]uint64
+	cmutex  *sync.Mutex
+	metrics []MetricsSource
+	tracer  *Tracer
+	authz   *Authorizer
+	trusted map[uint32]bool // UNIX socket users whose calls can name a principal
+	srv     *grpc.Server    // serves modules (and local clients) on the UNIX socket
+	rsrv    *grpc.Server    // serves remote clients; nil if we don't
 }
 
 // NewAPIServer creates a new, initialized API
@@ -66,12 +91,28 @@ func NewAPIServer(ctx Context) *APIServer {
 	api := &APIServer{
 		nlist: ctx.RPC.NetListner,
 		ulist: ctx.RPC.UNIXListener,
+		rlist: ctx.RPC.API.Listener,
+		rtls:  ctx.RPC.API.TLSConfig,
 		query: &ctx.Query,
 		log:   &ctx.Logger,
 		em:    NewEventEmitter(lib.Event_API),
 		schan: ctx.SubChan,
 		self:  ctx.Self,
 		sm:    ctx.Sm,
+		ede:   ctx.Ede,
+
+		cursors: make(map[string]uint64),
+		cmutex:  &sync.Mutex{},
+		metrics: ctx.metrics,
+		tracer:  ctx.tracer,
+		authz:   ctx.authz,
+		trusted: make(map[uint32]bool),
+	}
+	for _, uid := range ctx.RPC.TrustedUIDs {
+		api.trusted[uid] = true
+	}
+	if len(api.trusted) == 0 {
+		api.trusted[uint32(os.Getuid())] = true
 	}
 	api.log.SetModule("API")
 	return api
@@ -85,6 +126,12 @@ func (s *APIServer) QueryCreate(ctx context.Context, in *pb.Query) (out *pb.Quer
 		return
 	}
 	nin := NewNodeFromMessage(pbin)
+	if e = s.authz.Authorize(ctx, AuthzCreate, nin.ID().String()); e != nil {
+		return
+	}
+	if e = validateConfigs(nil, nin); e != nil {
+		return
+	}
 	var nout lib.Node
 	nout, e = s.query.Create(nin)
 	out.URL = in.URL
@@ -95,6 +142,9 @@ func (s *APIServer) QueryCreate(ctx context.Context, in *pb.Query) (out *pb.Quer
 }
 
 func (s *APIServer) QueryRead(ctx context.Context, in *pb.Query) (out *pb.Query, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, in.URL); e != nil {
+		return
+	}
 	var nout lib.Node
 	out = &pb.Query{}
 	nout, e = s.query.Read(NewNodeIDFromURL(in.URL))
@@ -106,6 +156,9 @@ func (s *APIServer) QueryRead(ctx context.Context, in *pb.Query) (out *pb.Query,
 }
 
 func (s *APIServer) QueryReadDsc(ctx context.Context, in *pb.Query) (out *pb.Query, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, in.URL); e != nil {
+		return
+	}
 	var nout lib.Node
 	out = &pb.Query{}
 	nout, e = s.query.ReadDsc(NewNodeIDFromURL(in.URL))
@@ -124,8 +177,23 @@ func (s *APIServer) QueryUpdate(ctx context.Context, in *pb.Query) (out *pb.Quer
 		return
 	}
 	nin := NewNodeFromMessage(pbin)
+	if e = s.authorizeUpdate(ctx, nin, false); e != nil {
+		return
+	}
+	if cur, ce := s.query.Read(nin.ID()); ce == nil {
+		if e = validateConfigs(cur, nin); e != nil {
+			return
+		}
+	}
 	var nout lib.Node
-	nout, e = s.query.Update(nin)
+	if in.Version != "" {
+		nout, e = s.query.UpdateIf(nin, in.Version)
+	} else {
+		nout, e = s.query.Update(nin)
+	}
+	if e == ErrNodeVersion {
+		e = status.Error(codes.Aborted, e.Error())
+	}
 	out.URL = in.URL
 	if nout != nil {
 		out.Payload = &pb.Query_Node{Node: nout.Message().(*pb.Node)}
@@ -141,6 +209,9 @@ func (s *APIServer) QueryUpdateDsc(ctx context.Context, in *pb.Query) (out *pb.Q
 		return
 	}
 	nin := NewNodeFromMessage(pbin)
+	if e = s.authorizeUpdate(ctx, nin, true); e != nil {
+		return
+	}
 	var nout lib.Node
 	nout, e = s.query.UpdateDsc(nin)
 	out.URL = in.URL
@@ -150,7 +221,47 @@ func (s *APIServer) QueryUpdateDsc(ctx context.Context, in *pb.Query) (out *pb.Q
 	return
 }
 
+// QuerySetValue sets a single cfg value, in one step; in.URL is the node URL of the value,
+// and in.Text is the value as JSON.  The reply has the value that got set, as JSON.
+func (s *APIServer) QuerySetValue(ctx context.Context, in *pb.Query) (out *pb.Query, e error) {
+	out = &pb.Query{URL: in.URL}
+	id, url := lib.NodeURLSplit(in.URL)
+	if e = s.authz.Authorize(ctx, AuthzUpdate, id, url); e != nil {
+		return
+	}
+	var cur lib.Node
+	if cur, e = s.query.Read(NewNodeIDFromURL(id)); e != nil {
+		return
+	}
+	var old, v reflect.Value
+	if old, e = cur.GetValue(url); e != nil {
+		return
+	}
+	if v, e = UnmarshalValueJSON([]byte(in.GetText()), old.Type()); e != nil {
+		return out, status.Errorf(codes.InvalidArgument, "bad value for %s: %v", in.URL, e)
+	}
+	next := NewNodeFromMessage(cur.Message().(*pb.Node))
+	if _, e = next.SetValue(url, v); e != nil {
+		return
+	}
+	if e = validateConfigs(cur, next); e != nil {
+		return
+	}
+	if v, e = s.query.SetValue(in.URL, v); e != nil {
+		return
+	}
+	var j []byte
+	if j, e = MarshalValueJSON(v); e != nil {
+		return
+	}
+	out.Payload = &pb.Query_Text{Text: string(j)}
+	return
+}
+
 func (s *APIServer) QueryDelete(ctx context.Context, in *pb.Query) (out *pb.Query, e error) {
+	if e = s.authz.Authorize(ctx, AuthzDelete, in.URL); e != nil {
+		return
+	}
 	var nout lib.Node
 	out = &pb.Query{}
 	nout, e = s.query.Delete(NewNodeIDFromURL(in.URL))
@@ -162,6 +273,9 @@ func (s *APIServer) QueryDelete(ctx context.Context, in *pb.Query) (out *pb.Quer
 }
 
 func (s *APIServer) QueryReadAll(ctx context.Context, in *empty.Empty) (out *pb.QueryMulti, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, ""); e != nil {
+		return
+	}
 	var nout []lib.Node
 	out = &pb.QueryMulti{}
 	out.Queries = []*pb.Query{}
@@ -179,6 +293,9 @@ func (s *APIServer) QueryReadAll(ctx context.Context, in *empty.Empty) (out *pb.
 }
 
 func (s *APIServer) QueryReadAllDsc(ctx context.Context, in *empty.Empty) (out *pb.QueryMulti, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, ""); e != nil {
+		return
+	}
 	var nout []lib.Node
 	out = &pb.QueryMulti{}
 	out.Queries = []*pb.Query{}
@@ -196,6 +313,9 @@ func (s *APIServer) QueryReadAllDsc(ctx context.Context, in *empty.Empty) (out *
 }
 
 func (s *APIServer) QueryMutationNodes(ctx context.Context, in *empty.Empty) (out *pb.Query, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, ""); e != nil {
+		return
+	}
 	var mnlout pb.MutationNodeList
 	url := "/graph/nodes"
 	out = &pb.Query{}
@@ -210,6 +330,9 @@ func (s *APIServer) QueryMutationNodes(ctx context.Context, in *empty.Empty) (ou
 }
 
 func (s *APIServer) QueryMutationEdges(ctx context.Context, in *empty.Empty) (out *pb.Query, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, ""); e != nil {
+		return
+	}
 	var melout pb.MutationEdgeList
 	url := "/graph/nodes"
 	out = &pb.Query{}
@@ -224,6 +347,9 @@ func (s *APIServer) QueryMutationEdges(ctx context.Context, in *empty.Empty) (ou
 }
 
 func (s *APIServer) QueryNodeMutationNodes(ctx context.Context, in *pb.Query) (out *pb.Query, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, in.URL); e != nil {
+		return
+	}
 	var mnlout pb.MutationNodeList
 	out = &pb.Query{}
 	mnlout, e = s.query.ReadNodeMutationNodes(in.URL)
@@ -237,6 +363,9 @@ func (s *APIServer) QueryNodeMutationNodes(ctx context.Context, in *pb.Query) (o
 }
 
 func (s *APIServer) QueryNodeMutationEdges(ctx context.Context, in *pb.Query) (out *pb.Query, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, in.URL); e != nil {
+		return
+	}
 	var melout pb.MutationEdgeList
 	out = &pb.Query{}
 	melout, e = s.query.ReadNodeMutationEdges(in.URL)
@@ -250,6 +379,9 @@ func (s *APIServer) QueryNodeMutationEdges(ctx context.Context, in *pb.Query) (o
 }
 
 func (s *APIServer) QueryNodeMutationPath(ctx context.Context, in *pb.Query) (out *pb.Query, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, in.URL); e != nil {
+		return
+	}
 	var mpout pb.MutationPath
 	out = &pb.Query{}
 	mpout, e = s.query.ReadNodeMutationPath(in.URL)
@@ -262,7 +394,26 @@ func (s *APIServer) QueryNodeMutationPath(ctx context.Context, in *pb.Query) (ou
 	return
 }
 
+func (s *APIServer) QueryNodeMutationHistory(ctx context.Context, in *pb.Query) (out *pb.Query, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, in.URL); e != nil {
+		return
+	}
+	var mhout pb.MutationHistory
+	out = &pb.Query{}
+	mhout, e = s.query.ReadNodeMutationHistory(in.URL)
+	out.URL = in.URL
+	if mhout.Records != nil {
+		out.Payload = &pb.Query_MutationHistory{
+			MutationHistory: &mhout,
+		}
+	}
+	return
+}
+
 func (s *APIServer) QueryDeleteAll(ctx context.Context, in *empty.Empty) (out *pb.QueryMulti, e error) {
+	if e = s.authz.Authorize(ctx, AuthzDelete, ""); e != nil {
+		return
+	}
 	var nout []lib.Node
 	out = &pb.QueryMulti{}
 	out.Queries = []*pb.Query{}
@@ -280,16 +431,25 @@ func (s *APIServer) QueryDeleteAll(ctx context.Context, in *empty.Empty) (out *p
 }
 
 func (s *APIServer) QueryFreeze(ctx context.Context, in *empty.Empty) (out *pb.Query, e error) {
+	if e = s.authz.Authorize(ctx, AuthzFreeze, ""); e != nil {
+		return
+	}
 	e = s.query.Freeze()
 	out = &pb.Query{}
 	return
 }
 func (s *APIServer) QueryThaw(ctx context.Context, in *empty.Empty) (out *pb.Query, e error) {
+	if e = s.authz.Authorize(ctx, AuthzFreeze, ""); e != nil {
+		return
+	}
 	e = s.query.Thaw()
 	out = &pb.Query{}
 	return
 }
 func (s *APIServer) QueryFrozen(ctx context.Context, in *empty.Empty) (out *pb.Query, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, ""); e != nil {
+		return
+	}
 	out = &pb.Query{}
 	rb, e := s.query.Frozen()
 	out.Payload = &pb.Query_Bool{Bool: rb}
@@ -300,8 +460,67 @@ func (s *APIServer) QueryFrozen(ctx context.Context, in *empty.Empty) (out *pb.Q
  * Service management
  */
 
+// Metrics reports node state counts, and whatever our MetricsSources have to say
+func (s *APIServer) Metrics(ctx context.Context, in *empty.Empty) (out *pb.MetricsReply, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, ""); e != nil {
+		return
+	}
+	run := MetricFamily{
+		Name: "kraken_nodes_run_state",
+		Help: "Nodes by /RunState, for configured (cfg) or discovered (dsc) state.",
+		Type: MetricGauge,
+	}
+	phys := MetricFamily{
+		Name: "kraken_nodes_phys_state",
+		Help: "Nodes by /PhysState, for configured (cfg) or discovered (dsc) state.",
+		Type: MetricGauge,
+	}
+	for _, which := range []string{"cfg", "dsc"} {
+		var ns []lib.Node
+		if which == "cfg" {
+			ns, e = s.query.ReadAll()
+		} else {
+			ns, e = s.query.ReadAllDsc()
+		}
+		if e != nil {
+			return
+		}
+		runs := make(map[int32]float64)
+		physs := make(map[int32]float64)
+		for _, n := range ns {
+			if v, e := n.GetValue("/RunState"); e == nil {
+				runs[int32(v.Int())]++
+			}
+			if v, e := n.GetValue("/PhysState"); e == nil {
+				physs[int32(v.Int())]++
+			}
+		}
+		run.Metrics = append(run.Metrics, metricEnumCounts(pb.Node_RunState_name, runs, MetricLabel{"which", which})...)
+		phys.Metrics = append(phys.Metrics, metricEnumCounts(pb.Node_PhysState_name, physs, MetricLabel{"which", which})...)
+	}
+	fams := []MetricFamily{run, phys}
+	for _, m := range s.metrics {
+		fams = append(fams, m.Metrics()...)
+	}
+	buf := &bytes.Buffer{}
+	if e = WriteMetrics(buf, fams); e != nil {
+		return
+	}
+	return &pb.MetricsReply{Text: buf.String()}, nil
+}
+
+// ServiceInit handles a service instance calling in, and streams it control messages.
+// Plugins register what they provide here, too.
 func (s *APIServer) ServiceInit(sir *pb.ServiceInitRequest, stream pb.API_ServiceInitServer) (e error) {
 	srv := s.sm.GetService(sir.GetId())
+	if srv == nil {
+		return status.Errorf(codes.NotFound, "no such service: %s", sir.GetId())
+	}
+	if sir.GetPlugin() != nil {
+		if e = s.sm.RegisterPlugin(sir.GetId(), sir.GetPlugin()); e != nil {
+			return
+		}
+	}
 
 	self, _ := s.query.Read(s.self)
 	any, _ := ptypes.MarshalAny(self.Message())
@@ -311,12 +530,118 @@ func (s *APIServer) ServiceInit(sir *pb.ServiceInitRequest, stream pb.API_Servic
 	})
 	c := make(chan lib.ServiceControl)
 	srv.SetCtl(c)
+	defer releaseCtl(srv, c)
 	for {
-		ctl := <-c
-		stream.Send(&pb.ServiceControl{
-			Command: pb.ServiceControl_Command(ctl.Command),
-		})
+		select {
+		case ctl := <-c:
+			if e = stream.Send(&pb.ServiceControl{
+				Command: pb.ServiceControl_Command(ctl.Command),
+			}); e != nil {
+				return
+			}
+		case <-stream.Context().Done():
+			return
+		}
+	}
+}
+
+// a ctlClearer can unset its control channel only if it's still the one we set
+type ctlClearer interface {
+	ClearCtl(chan<- lib.ServiceControl)
+}
+
+// releaseCtl disconnects a service from a control channel we're done reading
+func releaseCtl(srv lib.ServiceInstance, c chan lib.ServiceControl) {
+	// keep reading, so anyone in the middle of sending can finish
+	done := make(chan interface{})
+	go func() {
+		for {
+			select {
+			case <-c:
+			case <-done:
+				return
+			}
+		}
+	}()
+	if cc, ok := srv.(ctlClearer); ok {
+		cc.ClearCtl(c)
+	} else {
+		srv.SetCtl(nil)
 	}
+	close(done)
+}
+
+/*
+ * Service lifecycle
+ */
+
+// ServiceList reports the status of every service instance
+func (s *APIServer) ServiceList(ctx context.Context, in *empty.Empty) (out *pb.ServiceStatusList, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, s.self.String(), "/Services"); e != nil {
+		return
+	}
+	out = &pb.ServiceStatusList{}
+	for _, id := range s.sm.Services() {
+		if st, e := s.sm.Status(id); e == nil {
+			out.Services = append(out.Services, st)
+		}
+	}
+	return
+}
+
+func (s *APIServer) ServiceGet(ctx context.Context, in *pb.ServiceRequest) (out *pb.ServiceStatus, e error) {
+	if e = s.authz.Authorize(ctx, AuthzRead, s.self.String(), lib.URLPush("/Services", in.GetId())); e != nil {
+		return
+	}
+	return s.sm.Status(in.GetId())
+}
+
+func (s *APIServer) ServiceStart(ctx context.Context, in *pb.ServiceRequest) (out *pb.ServiceStatus, e error) {
+	return s.serviceControl(ctx, in, s.sm.StartService)
+}
+
+func (s *APIServer) ServiceStop(ctx context.Context, in *pb.ServiceRequest) (out *pb.ServiceStatus, e error) {
+	return s.serviceControl(ctx, in, s.sm.StopService)
+}
+
+func (s *APIServer) ServiceRestart(ctx context.Context, in *pb.ServiceRequest) (out *pb.ServiceStatus, e error) {
+	return s.serviceControl(ctx, in, s.sm.RestartService)
+}
+
+// ServiceConfigure sets a new config for a service.  The config has to be of the type of its module's config.
+func (s *APIServer) ServiceConfigure(ctx context.Context, in *pb.ServiceRequest) (out *pb.ServiceStatus, e error) {
+	if e = s.authz.Authorize(ctx, AuthzUpdate, s.self.String(), lib.URLPush(lib.URLPush("/Services", in.GetId()), "Config")); e != nil {
+		return
+	}
+	if in.GetConfig() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "no config given")
+	}
+	var p proto.Message
+	if p, e = Registry.Resolve(in.GetConfig().GetTypeUrl()); e != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown config type: %s", in.GetConfig().GetTypeUrl())
+	}
+	if e = ptypes.UnmarshalAny(in.GetConfig(), p); e != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad config: %v", e)
+	}
+	if e = s.sm.ConfigureService(in.GetId(), p); e != nil {
+		return
+	}
+	return s.sm.Status(in.GetId())
+}
+
+// ServiceHeartbeat tells us a service is alive, and how soon to expect the next heartbeat
+func (s *APIServer) ServiceHeartbeat(ctx context.Context, in *pb.ServiceHeartbeatRequest) (out *empty.Empty, e error) {
+	if e = s.authz.Authorize(ctx, AuthzUpdate, s.self.String(), lib.URLPush(lib.URLPush("/Services", in.GetId()), "State")); e != nil {
+		return
+	}
+	interval, e := ptypes.Duration(in.GetInterval())
+	if e != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad heartbeat interval: %v", e)
+	}
+	if e = s.sm.Heartbeat(in.GetId(), interval); e != nil {
+		return
+	}
+	return &empty.Empty{}, nil
 }
 
 /*
@@ -344,11 +669,13 @@ func (s *APIServer) MutationInit(sir *pb.ServiceInitRequest, stream pb.API_Mutat
 		v := <-echan
 		smev := v.Data().(*MutationEvent)
 		mc := &pb.MutationControl{
-			Module: smev.Mutation[0],
-			Id:     smev.Mutation[1],
-			Type:   smev.Type,
-			Cfg:    smev.NodeCfg.Message().(*pb.Node),
-			Dsc:    smev.NodeDsc.Message().(*pb.Node),
+			Module:      smev.Mutation[0],
+			Id:          smev.Mutation[1],
+			Type:        smev.Type,
+			Cfg:         smev.NodeCfg.Message().(*pb.Node),
+			Dsc:         smev.NodeDsc.Message().(*pb.Node),
+			Attempt:     smev.Attempt,
+			Traceparent: smev.Trace,
 		}
 		if e := stream.Send(mc); e != nil {
 			s.Logf(INFO, "mutation stream closed: %v", e)
@@ -364,79 +691,47 @@ func (s *APIServer) MutationInit(sir *pb.ServiceInitRequest, stream pb.API_Mutat
 
 // EventInit handles establishing the event stream
 // This just caputures all events and sends them over the stream
+// If asked, we first replay journaled events from a sequence number, or from where this service instance left off.
 func (s *APIServer) EventInit(sir *pb.ServiceInitRequest, stream pb.API_EventInitServer) (e error) {
-	module := sir.GetModule()
-	echan := make(chan lib.Event)
-	filterFunction := func(e lib.Event) bool {
-		return true
-	}
-	list := NewEventListener("EventFor:"+module, lib.Event_ALL,
-		filterFunction,
-		func(v lib.Event) error { return ChanSender(v, echan) })
-	// subscribe our listener
-	s.schan <- list
-
-	for {
-		v := <-echan
-		var ec = &pb.EventControl{}
-		switch v.Type() {
-		case lib.Event_STATE_MUTATION:
-			smev := v.Data().(*MutationEvent)
-			ec = &pb.EventControl{
-				Type: pb.EventControl_Mutation,
-				Event: &pb.EventControl_MutationControl{
-					MutationControl: &pb.MutationControl{
-						Module: smev.Mutation[0],
-						Id:     smev.Mutation[1],
-						Type:   smev.Type,
-						Cfg:    smev.NodeCfg.Message().(*pb.Node),
-						Dsc:    smev.NodeDsc.Message().(*pb.Node),
-					},
-				},
-			}
-		case lib.Event_STATE_CHANGE:
-			scev := v.Data().(*StateChangeEvent)
-			s.Logf(lib.LLDEBUG, "api server got state change event: %+v\n%v", scev, scev.Value)
-			ec = &pb.EventControl{
-				Type: pb.EventControl_StateChange,
-				Event: &pb.EventControl_StateChangeControl{
-					StateChangeControl: &pb.StateChangeControl{
-						Type:  scev.Type,
-						Url:   scev.URL,
-						Value: lib.ValueToString(scev.Value),
-					},
-				},
-			}
-		case lib.Event_DISCOVERY:
-			dev := v.Data().(*DiscoveryEvent)
-			ec = &pb.EventControl{
-				Type: pb.EventControl_Discovery,
-				Event: &pb.EventControl_DiscoveryEvent{
-					DiscoveryEvent: &pb.DiscoveryEvent{
-						Id:      dev.ID,
-						Url:     dev.URL,
-						ValueId: dev.ValueID,
-					},
-				},
-			}
-		default:
-			s.Logf(lib.LLERROR, "Couldn't convert Event into mutation, statechange, or discovery: %+v", v)
+	id := sir.GetId()
+	from := sir.GetReplay()
+	if from == 0 && sir.GetResume() {
+		s.cmutex.Lock()
+		if c, ok := s.cursors[id]; ok {
+			from = c + 1
 		}
+		s.cmutex.Unlock()
+	}
+	s.streamEvents(stream.Context(), "EventFor:"+sir.GetModule(), from, nil, func(ec *pb.EventControl) error {
 		if e := stream.Send(ec); e != nil {
-			s.Logf(INFO, "event stream closed: %v", e)
-			break
+			return e
 		}
-	}
+		s.cmutex.Lock()
+		s.cursors[id] = ec.GetSeq()
+		s.cmutex.Unlock()
+		return nil
+	})
+	return
+}
 
-	// politely unsubscribe
-	list.SetState(lib.EventListener_UNSUBSCRIBE)
-	s.schan <- list
+// Watch streams events to any API client, optionally replaying journaled events first
+func (s *APIServer) Watch(wr *pb.WatchRequest, stream pb.API_WatchServer) (e error) {
+	if e = s.authz.Authorize(stream.Context(), AuthzRead, ""); e != nil {
+		return
+	}
+	types := map[lib.EventType]bool{}
+	for _, t := range wr.GetTypes() {
+		types[EventControlType[t]] = true
+	}
+	s.streamEvents(stream.Context(), fmt.Sprintf("WatchFor:%s:%p", wr.GetId(), stream), wr.GetFrom(), types, stream.Send)
 	return
 }
 
 // DiscoveryInit handles discoveries from nodes
 // This dispatches nodes
 func (s *APIServer) DiscoveryInit(stream pb.API_DiscoveryInitServer) (e error) {
+	// discoveries without their own trace context are part of the stream's, if it has one
+	stp := TraceContextFrom(stream.Context())
 	for {
 		dc, e := stream.Recv()
 		if e != nil {
@@ -447,6 +742,17 @@ func (s *APIServer) DiscoveryInit(stream pb.API_DiscoveryInitServer) (e error) {
 			ID:      dc.GetId(),
 			URL:     dc.GetUrl(),
 			ValueID: dc.GetValueId(),
+			Trace:   dc.GetTraceparent(),
+		}
+		if dv.Trace == "" {
+			dv.Trace = stp
+		}
+		if dv.Trace != "" {
+			span := s.tracer.Start("discovery", dv.Trace)
+			span.SetAttr("kraken.service", dv.ID)
+			span.SetAttr("kraken.url", dv.URL)
+			span.SetAttr("kraken.value", dv.ValueID)
+			span.End()
 		}
 		v := NewEvent(
 			lib.Event_DISCOVERY,
@@ -473,16 +779,342 @@ func (s *APIServer) LoggerInit(stream pb.API_LoggerInitServer) (e error) {
 // Run starts the API service listener
 func (s *APIServer) Run(ready chan<- interface{}) {
 	s.Log(INFO, "starting API")
-	srv := grpc.NewServer()
-	pb.RegisterAPIServer(srv, s)
-	reflection.Register(srv)
+	s.srv = grpc.NewServer(
+		grpc.Creds(peerCredentials{}),
+		grpc.ChainUnaryInterceptor(
+			func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				return handler(localPrincipalContext(ctx, s.trusted), req)
+			},
+			s.tracer.UnaryServerInterceptor,
+		),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return handler(srv, &principalServerStream{ss, localPrincipalContext(ss.Context(), s.trusted)})
+		}),
+	)
+	pb.RegisterAPIServer(s.srv, s)
+	reflection.Register(s.srv)
+	if s.rlist != nil {
+		s.rsrv = s.remoteServer()
+		go s.serveRemote()
+	}
 	ready <- nil
-	if e := srv.Serve(s.ulist); e != nil {
+	if e := s.srv.Serve(s.ulist); e != nil {
 		s.Logf(CRITICAL, "couldn't start API service: %v", e)
 		return
 	}
 }
 
+// StopRemote stops taking API calls from remote clients.  Calls in progress get up to timeout to finish.
+// Stop and StopRemote must only be called after Run has reported ready.
+func (s *APIServer) StopRemote(timeout time.Duration) {
+	if s.rsrv != nil {
+		s.Log(INFO, "stopping remote API")
+		gracefulStop(s.rsrv, timeout)
+	}
+}
+
+// Stop stops serving the API altogether.  Calls in progress get up to timeout to finish; streams, like Watch, are cut off then.
+func (s *APIServer) Stop(timeout time.Duration) {
+	s.StopRemote(timeout)
+	s.Log(INFO, "stopping API")
+	gracefulStop(s.srv, timeout)
+}
+
+////////////////////////
+// Unexported methods /
+//////////////////////
+
+// remoteUnary are the unary calls remote clients can make; the rest are for modules
+var remoteUnary = map[string]bool{
+	"/proto.API/QueryCreate":              true,
+	"/proto.API/QueryRead":                true,
+	"/proto.API/QueryReadDsc":             true,
+	"/proto.API/QueryUpdate":              true,
+	"/proto.API/QuerySetValue":            true,
+	"/proto.API/QueryDelete":              true,
+	"/proto.API/QueryReadAll":             true,
+	"/proto.API/QueryReadAllDsc":          true,
+	"/proto.API/QueryMutationNodes":       true,
+	"/proto.API/QueryMutationEdges":       true,
+	"/proto.API/QueryNodeMutationNodes":   true,
+	"/proto.API/QueryNodeMutationEdges":   true,
+	"/proto.API/QueryNodeMutationPath":    true,
+	"/proto.API/QueryNodeMutationHistory": true,
+	"/proto.API/QueryDeleteAll":           true,
+	"/proto.API/QueryFreeze":              true,
+	"/proto.API/QueryThaw":                true,
+	"/proto.API/QueryFrozen":              true,
+	"/proto.API/Metrics":                  true,
+	"/proto.API/ServiceList":              true,
+	"/proto.API/ServiceGet":               true,
+	"/proto.API/ServiceStart":             true,
+	"/proto.API/ServiceStop":              true,
+	"/proto.API/ServiceRestart":           true,
+	"/proto.API/ServiceConfigure":         true,
+}
+
+// remoteStreams are the streaming calls remote clients can make; the rest are for modules
+var remoteStreams = map[string]bool{
+	"/proto.API/Watch": true,
+}
+
+// serveRemote serves the API to remote clients over TLS.
+// Remote calls are always made on behalf of who the client certificate says the client is (or AnonymousPrincipal),
+// never who the client says it is.
+func (s *APIServer) serveRemote() {
+	if e := s.rsrv.Serve(s.rlist); e != nil {
+		s.Logf(CRITICAL, "couldn't start remote API service: %v", e)
+		return
+	}
+}
+
+// remoteServer creates the gRPC server for remote clients
+func (s *APIServer) remoteServer() *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(s.rtls)),
+		grpc.ChainUnaryInterceptor(
+			func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				if !remoteUnary[info.FullMethod] {
+					return nil, status.Errorf(codes.PermissionDenied, "%s is only available to modules", info.FullMethod)
+				}
+				return handler(remotePrincipalContext(ctx), req)
+			},
+			s.tracer.UnaryServerInterceptor,
+		),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if !remoteStreams[info.FullMethod] {
+				return status.Errorf(codes.PermissionDenied, "%s is only available to modules", info.FullMethod)
+			}
+			return handler(srv, &principalServerStream{ss, remotePrincipalContext(ss.Context())})
+		}),
+	)
+	pb.RegisterAPIServer(srv, s)
+	reflection.Register(srv)
+	return srv
+}
+
+// gracefulStop stops a gRPC server, letting calls in progress finish, but only waits up to timeout for them
+func gracefulStop(srv *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		srv.Stop()
+	}
+}
+
+// remotePrincipalContext replaces whatever principal a remote client sent with who its certificate says it is
+func remotePrincipalContext(ctx context.Context) context.Context {
+	who := AnonymousPrincipal
+	if p, ok := peer.FromContext(ctx); ok {
+		if ti, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if n := lib.TLSPeerName(&ti.State); n != "" {
+				who = n
+			}
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	md.Set(PrincipalMetadataKey, who)
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+// principalServerStream is a stream with the context from remotePrincipalContext or localPrincipalContext
+type principalServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (r *principalServerStream) Context() context.Context { return r.ctx }
+
+// serviceControl authorizes, and makes, a change to the state of a service
+func (s *APIServer) serviceControl(ctx context.Context, in *pb.ServiceRequest, f func(string) error) (out *pb.ServiceStatus, e error) {
+	if e = s.authz.Authorize(ctx, AuthzUpdate, s.self.String(), lib.URLPush(lib.URLPush("/Services", in.GetId()), "State")); e != nil {
+		return
+	}
+	if e = f(in.GetId()); e != nil {
+		return
+	}
+	return s.sm.Status(in.GetId())
+}
+
+// authorizeUpdate checks that the caller may change every URL that differs between a node and its current (cfg or dsc) state
+func (s *APIServer) authorizeUpdate(ctx context.Context, n lib.Node, dsc bool) (e error) {
+	if _, ok := PrincipalFrom(ctx); !ok || s.authz == nil {
+		return
+	}
+	var cur lib.Node
+	if dsc {
+		cur, e = s.query.ReadDsc(n.ID())
+	} else {
+		cur, e = s.query.Read(n.ID())
+	}
+	if e != nil {
+		// we can't tell what would change, so it has to be allowed to change anything
+		return s.authz.Authorize(ctx, AuthzUpdate, n.ID().String())
+	}
+	diff, e := cur.Diff(n, "")
+	if e != nil {
+		return
+	}
+	if len(diff) == 0 {
+		return
+	}
+	return s.authz.Authorize(ctx, AuthzUpdate, n.ID().String(), diff...)
+}
+
+// validateConfigs checks the service configs of n that differ from those of cur (which may be nil)
+func validateConfigs(cur, n lib.Node) error {
+	for _, srv := range n.GetServices() {
+		a := srv.GetConfig()
+		if a == nil {
+			continue
+		}
+		module := srv.GetModule()
+		if cur != nil {
+			old := cur.GetService(srv.GetId())
+			if proto.Equal(old.GetConfig(), a) {
+				continue
+			}
+			if old != nil {
+				module = old.GetModule() // an update doesn't get to say which module checks it
+			}
+		}
+		p, e := Registry.Resolve(a.GetTypeUrl())
+		if e != nil {
+			continue // not a config we know, so not one we can check
+		}
+		if e = ptypes.UnmarshalAny(a, p); e != nil {
+			return status.Errorf(codes.InvalidArgument, "bad config for service %s: %v", srv.GetId(), e)
+		}
+		if e = validateConfig(srv.GetId(), module, p); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// streamCatchUp is how long an event stream waits for an event before it catches up on the journal itself
+const streamCatchUp = time.Second
+
+// streamEvents sends events until send fails.  If from is non-zero, journaled events from that
+// sequence number get sent first.  If types is non-empty, only those types of events get sent.
+func (s *APIServer) streamEvents(ctx context.Context, name string, from uint64, types map[lib.EventType]bool, send func(*pb.EventControl) error) {
+	var last uint64 // sequence number of the last event sent
+	sendOne := func(v lib.Event) bool {
+		if v.Seq() != 0 && v.Seq() <= last {
+			// we already sent this one during replay
+			return true
+		}
+		if len(types) > 0 && !types[v.Type()] {
+			return true
+		}
+		ec, e := eventToProto(v)
+		if e != nil {
+			s.Logf(lib.LLERROR, "%v", e)
+			return true
+		}
+		if e := send(ec); e != nil {
+			s.Logf(INFO, "event stream closed: %v", e)
+			return false
+		}
+		last = v.Seq()
+		return true
+	}
+	// replay sends journaled events from `from` up to, but not including, `until` (0 for no limit)
+	replay := func(until uint64) bool {
+		more := true
+		if e := s.ede.Replay(from, func(v lib.Event) bool {
+			if until > 0 && v.Seq() >= until {
+				return false
+			}
+			more = sendOne(v)
+			return more
+		}); e != nil {
+			s.Logf(ERROR, "event replay failed: %v", e)
+		}
+		if last >= from {
+			from = last + 1
+		}
+		return more
+	}
+
+	if from > 0 && s.ede != nil {
+		// catch up before we subscribe, so a long replay doesn't hold up event dispatch
+		if !replay(0) {
+			return
+		}
+	}
+
+	// Everything after this is sent from our listener's goroutine, so it only ever waits on its own queue.
+	// mutex protects last, from, caught and closed, and makes sure we don't send after we return.
+	mutex := &sync.Mutex{}
+	caught := from == 0 || s.ede == nil
+	closed := false
+	failed := make(chan interface{})
+	fail := func() {
+		closed = true
+		close(failed)
+	}
+	list := NewEventListener(name, lib.Event_ALL,
+		func(lib.Event) bool { return true },
+		func(v lib.Event) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if closed {
+				return nil
+			}
+			if !caught {
+				caught = true
+				// anything dispatched between our replay and subscribing is now in the journal
+				if v.Seq() != 0 && !replay(v.Seq()) {
+					fail()
+					return nil
+				}
+			}
+			if !sendOne(v) {
+				fail()
+			}
+			return nil
+		})
+	// subscribe our listener
+	s.schan <- list
+
+	// if no events come along to catch us up, nothing is waiting on us, so we catch up ourselves
+	catchUp := time.NewTimer(streamCatchUp)
+	defer catchUp.Stop()
+LOOP:
+	for {
+		select {
+		case <-catchUp.C:
+			mutex.Lock()
+			if !closed && !caught {
+				caught = true
+				if !replay(0) {
+					fail()
+				}
+			}
+			mutex.Unlock()
+		case <-failed:
+			break LOOP
+		case <-ctx.Done():
+			s.Logf(INFO, "event stream closed: %v", ctx.Err())
+			mutex.Lock()
+			closed = true
+			mutex.Unlock()
+			break LOOP
+		}
+	}
+
+	// politely unsubscribe
+	list.SetState(lib.EventListener_UNSUBSCRIBE)
+	s.schan <- list
+}
+
 ////////////////////////////
 // Passthrough Interfaces /
 //////////////////////////
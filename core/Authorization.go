@@ -0,0 +1,373 @@
+/* Authorization.go: role-based authorization of API calls
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os/user"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/hpc/kraken/lib"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// PrincipalMetadataKey is the gRPC metadata key that names who an API call is made on behalf of.
+// It is only trusted on calls from trusted users over the UNIX socket (see ContextRPC.TrustedUIDs), like restapi's.
+// Calls from them without it come from kraken's own services, and are always allowed;
+// calls from anyone else are made on behalf of who they are, whatever they send.
+const PrincipalMetadataKey = "kraken-principal"
+
+// AnonymousPrincipal is who unauthenticated requests are made on behalf of
+const AnonymousPrincipal = "anonymous"
+
+// AuthzAny binds roles to every principal
+const AuthzAny = "*"
+
+// AuthzOps are the kinds of operation a role can be allowed
+type AuthzOp uint8
+
+const (
+	AuthzRead   AuthzOp = iota // read node state, graphs, history and metrics; watch events
+	AuthzUpdate                // change node state, cfg or dsc
+	AuthzCreate                // create nodes
+	AuthzDelete                // delete nodes
+	AuthzFreeze                // freeze or thaw the SME
+)
+
+var AuthzOpString = map[AuthzOp]string{
+	AuthzRead:   "read",
+	AuthzUpdate: "update",
+	AuthzCreate: "create",
+	AuthzDelete: "delete",
+	AuthzFreeze: "freeze",
+}
+
+var AuthzOpValue = map[string]AuthzOp{
+	"read":   AuthzRead,
+	"update": AuthzUpdate,
+	"create": AuthzCreate,
+	"delete": AuthzDelete,
+	"freeze": AuthzFreeze,
+}
+
+// An AuthzRule allows operations on node URLs.
+// A URL pattern is a glob (e.g. "/Services/*/State") that matches a URL or any of its parents, so "/" matches everything.
+// Operations that aren't about a URL (e.g. freeze) are checked against "/".
+type AuthzRule struct {
+	Ops  []string `json:"ops"`
+	URLs []string `json:"urls"`
+}
+
+// An AuthzPolicy binds principals to roles, which are lists of rules
+type AuthzPolicy struct {
+	Roles    map[string][]AuthzRule `json:"roles"`
+	Bindings map[string][]string    `json:"bindings"` // principal -> roles; AuthzAny binds roles to everyone
+}
+
+// DefaultAuthzRoles are always available to bind to; a policy can redefine them
+func DefaultAuthzRoles() map[string][]AuthzRule {
+	return map[string][]AuthzRule{
+		"observer": {
+			{Ops: []string{"read"}, URLs: []string{"/"}},
+		},
+		"operator": {
+			{Ops: []string{"read"}, URLs: []string{"/"}},
+			{Ops: []string{"update"}, URLs: []string{"/PhysState", "/Services/*/State"}},
+		},
+		"admin": {
+			{Ops: []string{"read", "update", "create", "delete", "freeze"}, URLs: []string{"/"}},
+		},
+	}
+}
+
+// LoadAuthzPolicy reads a JSON policy file, adding the default roles it doesn't redefine
+func LoadAuthzPolicy(file string) (p *AuthzPolicy, e error) {
+	b, e := ioutil.ReadFile(file)
+	if e != nil {
+		return nil, fmt.Errorf("could not read authorization policy: %v", e)
+	}
+	p = &AuthzPolicy{}
+	if e = json.Unmarshal(b, p); e != nil {
+		return nil, fmt.Errorf("could not parse authorization policy: %v", e)
+	}
+	if p.Roles == nil {
+		p.Roles = make(map[string][]AuthzRule)
+	}
+	for name, rules := range DefaultAuthzRoles() {
+		if _, ok := p.Roles[name]; !ok {
+			p.Roles[name] = rules
+		}
+	}
+	return p, p.Validate()
+}
+
+// Validate makes sure every op, URL pattern and bound role makes sense
+func (p *AuthzPolicy) Validate() error {
+	for name, rules := range p.Roles {
+		for _, r := range rules {
+			for _, op := range r.Ops {
+				if _, ok := AuthzOpValue[op]; !ok {
+					return fmt.Errorf("role %s has an unknown operation: %s", name, op)
+				}
+			}
+			for _, u := range r.URLs {
+				if _, e := path.Match(u, ""); e != nil {
+					return fmt.Errorf("role %s has a bad URL pattern %s: %v", name, u, e)
+				}
+			}
+		}
+	}
+	for who, roles := range p.Bindings {
+		for _, role := range roles {
+			if _, ok := p.Roles[role]; !ok {
+				return fmt.Errorf("%s is bound to an unknown role: %s", who, role)
+			}
+		}
+	}
+	return nil
+}
+
+// Allowed decides if principal may do op on a node URL
+func (p *AuthzPolicy) Allowed(principal string, op AuthzOp, url string) bool {
+	roles := append(append([]string{}, p.Bindings[principal]...), p.Bindings[AuthzAny]...)
+	for _, role := range roles {
+		for _, r := range p.Roles[role] {
+			if authzRuleMatch(r, op, url) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func authzRuleMatch(r AuthzRule, op AuthzOp, url string) bool {
+	hasOp := false
+	for _, o := range r.Ops {
+		if o == AuthzOpString[op] {
+			hasOp = true
+			break
+		}
+	}
+	if !hasOp {
+		return false
+	}
+	for _, pat := range r.URLs {
+		// try the URL, then each of its parents
+		for u := url; ; u = path.Dir(u) {
+			if pat == "/" || pat == u {
+				return true
+			}
+			if ok, _ := path.Match(pat, u); ok {
+				return true
+			}
+			if u == "/" || u == "." || u == "" {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// PrincipalContextWith adds a principal to an outgoing gRPC context
+func PrincipalContextWith(ctx context.Context, principal string) context.Context {
+	if principal == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, PrincipalMetadataKey, principal)
+}
+
+// PrincipalFrom gets the principal from an incoming gRPC context; ok is false if there isn't one
+func PrincipalFrom(ctx context.Context) (principal string, ok bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	if v := md.Get(PrincipalMetadataKey); len(v) > 0 {
+		return v[0], true
+	}
+	return "", false
+}
+
+// localPrincipalContext makes sure a call over the UNIX socket is made on behalf of someone we can vouch for.
+// Trusted peers (e.g. restapi, which authenticates its own clients) can name any principal, or none;
+// anyone else's calls are made on behalf of their user name, whatever principal they send.
+func localPrincipalContext(ctx context.Context, trusted map[uint32]bool) context.Context {
+	who := AnonymousPrincipal
+	if p, ok := peer.FromContext(ctx); ok {
+		if pc, ok := p.AuthInfo.(peerCredInfo); ok {
+			if trusted[pc.Uid] {
+				return ctx
+			}
+			who = unixPrincipal(pc.Uid)
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	md.Set(PrincipalMetadataKey, who)
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+// unixPrincipal is who a local user is: their user name, or "uid:<uid>" if they don't have one
+func unixPrincipal(uid uint32) string {
+	id := strconv.FormatUint(uint64(uid), 10)
+	if u, e := user.LookupId(id); e == nil {
+		return u.Username
+	}
+	return "uid:" + id
+}
+
+// peerCredInfo is the AuthInfo of a UNIX socket peer: who the kernel says it is (SO_PEERCRED)
+type peerCredInfo struct {
+	credentials.CommonAuthInfo
+	syscall.Ucred
+}
+
+func (peerCredInfo) AuthType() string { return "peercred" }
+
+// peerCredentials are gRPC server credentials that identify UNIX socket peers by SO_PEERCRED.
+// There's no handshake, so clients connect with grpc.WithInsecure like always.
+type peerCredentials struct{}
+
+var _ credentials.TransportCredentials = peerCredentials{}
+
+func (peerCredentials) ServerHandshake(c net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return c, nil, nil
+	}
+	rc, e := uc.SyscallConn()
+	if e != nil {
+		return nil, nil, e
+	}
+	var cred *syscall.Ucred
+	var ce error
+	if e = rc.Control(func(fd uintptr) {
+		cred, ce = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); e != nil {
+		return nil, nil, e
+	}
+	if ce != nil {
+		return nil, nil, fmt.Errorf("could not get peer credentials: %v", ce)
+	}
+	return c, peerCredInfo{
+		CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity},
+		Ucred:          *cred,
+	}, nil
+}
+
+func (peerCredentials) ClientHandshake(_ context.Context, _ string, c net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return c, nil, nil
+}
+
+func (peerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "peercred"}
+}
+
+func (p peerCredentials) Clone() credentials.TransportCredentials { return p }
+func (peerCredentials) OverrideServerName(string) error           { return nil }
+
+// An Authorizer enforces an AuthzPolicy, logging and counting denials.  A nil *Authorizer allows everything.
+type Authorizer struct {
+	policy  *AuthzPolicy
+	log     lib.Logger
+	mutex   *sync.Mutex
+	denials map[[2]string]uint64 // [principal, op] -> count
+}
+
+// NewAuthorizer creates an Authorizer for a policy file; it returns nil if file is empty
+func NewAuthorizer(file string, log lib.Logger) (*Authorizer, error) {
+	if file == "" {
+		return nil, nil
+	}
+	p, e := LoadAuthzPolicy(file)
+	if e != nil {
+		return nil, e
+	}
+	return NewAuthorizerWithPolicy(p, log), nil
+}
+
+// NewAuthorizerWithPolicy creates an Authorizer for a policy that is already loaded
+func NewAuthorizerWithPolicy(p *AuthzPolicy, log lib.Logger) *Authorizer {
+	return &Authorizer{
+		policy:  p,
+		log:     log,
+		mutex:   &sync.Mutex{},
+		denials: make(map[[2]string]uint64),
+	}
+}
+
+// Authorize checks that the principal of a gRPC call may do op on every one of urls on node.
+// It returns a PermissionDenied error if not.
+func (a *Authorizer) Authorize(ctx context.Context, op AuthzOp, node string, urls ...string) error {
+	if a == nil {
+		return nil
+	}
+	principal, ok := PrincipalFrom(ctx)
+	if !ok {
+		return nil
+	}
+	if len(urls) == 0 {
+		urls = []string{"/"}
+	}
+	for _, u := range urls {
+		if !a.policy.Allowed(principal, op, u) {
+			a.deny(principal, op, node, u)
+			return status.Errorf(codes.PermissionDenied, "%s may not %s %s%s", principal, AuthzOpString[op], node, u)
+		}
+	}
+	return nil
+}
+
+// Metrics reports how many calls have been denied
+func (a *Authorizer) Metrics() []MetricFamily {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	f := MetricFamily{
+		Name: "kraken_authz_denied_total",
+		Help: "API calls denied by the authorization policy.",
+		Type: MetricCounter,
+	}
+	keys := make([][2]string, 0, len(a.denials))
+	for k := range a.denials {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		f.Metrics = append(f.Metrics, Metric{
+			Labels: []MetricLabel{{"principal", k[0]}, {"op", k[1]}},
+			Value:  float64(a.denials[k]),
+		})
+	}
+	return []MetricFamily{f}
+}
+
+func (a *Authorizer) deny(principal string, op AuthzOp, node, url string) {
+	a.mutex.Lock()
+	a.denials[[2]string{principal, AuthzOpString[op]}]++
+	a.mutex.Unlock()
+	a.log.Logf(NOTICE, "authorization denied: %s may not %s %s%s", principal, AuthzOpString[op], node, url)
+}
@@ -0,0 +1,99 @@
+/* Cgroup.go: runs service instance processes in cgroup v2 cgroups, with resource limits
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	pb "github.com/hpc/kraken/core/proto"
+)
+
+// cgroupControllers are the controllers a ResourcePolicy uses
+var cgroupControllers = []string{"cpu", "cpuset", "memory", "pids"}
+
+// cgroupQuotaPeriod is the cpu.max period, in µs, that ResourcePolicy.CpuQuota is a percentage of
+const cgroupQuotaPeriod = 100000
+
+// enableCgroupControllers creates root, if needed, and enables the controllers we use for the cgroups in it.
+// Controllers that aren't available to root are skipped; policies that need them will fail to apply.
+// Note that root can't have processes of its own (including kraken) once it has controllers enabled.
+func enableCgroupControllers(root string) (e error) {
+	if e = os.MkdirAll(root, 0755); e != nil {
+		return
+	}
+	b, e := ioutil.ReadFile(filepath.Join(root, "cgroup.controllers"))
+	if e != nil {
+		return fmt.Errorf("%s is not a cgroup v2 directory: %v", root, e)
+	}
+	avail := map[string]bool{}
+	for _, c := range strings.Fields(string(b)) {
+		avail[c] = true
+	}
+	enable := []string{}
+	for _, c := range cgroupControllers {
+		if avail[c] {
+			enable = append(enable, "+"+c)
+		}
+	}
+	if len(enable) == 0 {
+		return
+	}
+	return ioutil.WriteFile(filepath.Join(root, "cgroup.subtree_control"), []byte(strings.Join(enable, " ")), 0644)
+}
+
+// applyCgroup creates the cgroup for a service in root, if needed, and sets its limits to p.
+// Limits p leaves unset are reset to the defaults, so a nil p is no limits.
+func applyCgroup(root, si string, p *pb.ResourcePolicy) (dir string, e error) {
+	if si == "" || si == "." || si == ".." || strings.ContainsRune(si, '/') {
+		return "", fmt.Errorf("service ID can't be used as a cgroup name: %q", si)
+	}
+	dir = filepath.Join(root, si)
+	if e = os.Mkdir(dir, 0755); e != nil && !os.IsExist(e) {
+		return
+	}
+	weight, quota, memory, pids := "100", "max", "max", "max"
+	if p.GetCpuWeight() > 0 {
+		weight = strconv.FormatUint(uint64(p.GetCpuWeight()), 10)
+	}
+	if p.GetCpuQuota() > 0 {
+		quota = strconv.FormatUint(uint64(p.GetCpuQuota())*cgroupQuotaPeriod/100, 10)
+	}
+	if p.GetMemoryMax() > 0 {
+		memory = strconv.FormatUint(p.GetMemoryMax(), 10)
+	}
+	if p.GetPidsMax() > 0 {
+		pids = strconv.FormatUint(p.GetPidsMax(), 10)
+	}
+	for _, f := range []struct {
+		file, value string
+		set         bool
+	}{
+		{"cpu.weight", weight, p.GetCpuWeight() > 0},
+		{"cpu.max", quota + " " + strconv.Itoa(cgroupQuotaPeriod), p.GetCpuQuota() > 0},
+		{"memory.max", memory, p.GetMemoryMax() > 0},
+		{"pids.max", pids, p.GetPidsMax() > 0},
+		{"cpuset.cpus", p.GetCpus() + "\n", p.GetCpus() != ""}, // an empty cpuset.cpus is the parent's
+	} {
+		if e = ioutil.WriteFile(filepath.Join(dir, f.file), []byte(f.value), 0644); e != nil {
+			if os.IsNotExist(e) && !f.set {
+				// the controller isn't enabled, but we don't need it
+				e = nil
+				continue
+			}
+			return dir, fmt.Errorf("couldn't set %s to %s: %v", f.file, strings.TrimSpace(f.value), e)
+		}
+	}
+	return
+}
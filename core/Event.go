@@ -10,6 +10,10 @@
 package core
 
 import (
+	"fmt"
+	"reflect"
+
+	pb "github.com/hpc/kraken/core/proto"
 	"github.com/hpc/kraken/lib"
 )
 
@@ -24,6 +28,7 @@ type Event struct {
 	t    lib.EventType
 	url  string
 	data interface{}
+	seq  uint64
 }
 
 // NewEvent creates an initialized, fully specified Event
@@ -39,3 +44,128 @@ func NewEvent(t lib.EventType, url string, data interface{}) lib.Event {
 func (v *Event) Type() lib.EventType { return v.t }
 func (v *Event) URL() string         { return v.url }
 func (v *Event) Data() interface{}   { return v.data }
+func (v *Event) Seq() uint64         { return v.seq }
+
+// eventWithSeq makes a copy of an event with a sequence number
+func eventWithSeq(ev lib.Event, seq uint64) lib.Event {
+	return &Event{
+		t:    ev.Type(),
+		url:  ev.URL(),
+		data: ev.Data(),
+		seq:  seq,
+	}
+}
+
+// EventControlType maps protobuf event types to lib.EventType
+var EventControlType = map[pb.EventControl_Type]lib.EventType{
+	pb.EventControl_StateChange: lib.Event_STATE_CHANGE,
+	pb.EventControl_Mutation:    lib.Event_STATE_MUTATION,
+	pb.EventControl_Discovery:   lib.Event_DISCOVERY,
+}
+
+// eventToProto converts an event into an EventControl to send over the API or write to the journal
+func eventToProto(v lib.Event) (ec *pb.EventControl, e error) {
+	switch v.Type() {
+	case lib.Event_STATE_MUTATION:
+		smev, ok := v.Data().(*MutationEvent)
+		if !ok {
+			break
+		}
+		ec = &pb.EventControl{
+			Type: pb.EventControl_Mutation,
+			Event: &pb.EventControl_MutationControl{
+				MutationControl: &pb.MutationControl{
+					Module:      smev.Mutation[0],
+					Id:          smev.Mutation[1],
+					Type:        smev.Type,
+					Cfg:         smev.NodeCfg.Message().(*pb.Node),
+					Dsc:         smev.NodeDsc.Message().(*pb.Node),
+					Attempt:     smev.Attempt,
+					Traceparent: smev.Trace,
+				},
+			},
+		}
+	case lib.Event_STATE_CHANGE:
+		scev, ok := v.Data().(*StateChangeEvent)
+		if !ok {
+			break
+		}
+		ec = &pb.EventControl{
+			Type: pb.EventControl_StateChange,
+			Event: &pb.EventControl_StateChangeControl{
+				StateChangeControl: &pb.StateChangeControl{
+					Type:  scev.Type,
+					Url:   scev.URL,
+					Value: lib.ValueToString(scev.Value),
+				},
+			},
+		}
+	case lib.Event_DISCOVERY:
+		dev, ok := v.Data().(*DiscoveryEvent)
+		if !ok {
+			break
+		}
+		ec = &pb.EventControl{
+			Type: pb.EventControl_Discovery,
+			Event: &pb.EventControl_DiscoveryEvent{
+				DiscoveryEvent: &pb.DiscoveryEvent{
+					Id:          dev.ID,
+					Url:         dev.URL,
+					ValueId:     dev.ValueID,
+					Traceparent: dev.Trace,
+				},
+			},
+		}
+	}
+	if ec == nil {
+		return nil, fmt.Errorf("couldn't convert event into mutation, statechange, or discovery: %+v", v)
+	}
+	ec.Seq = v.Seq()
+	return
+}
+
+// eventFromProto converts an EventControl back into an event
+func eventFromProto(ec *pb.EventControl) (v lib.Event) {
+	switch ec.GetType() {
+	case pb.EventControl_Mutation:
+		event := ec.GetMutationControl()
+		cfg := NewNodeFromMessage(event.GetCfg())
+		dsc := NewNodeFromMessage(event.GetDsc())
+		v = NewEvent(
+			lib.Event_STATE_MUTATION,
+			cfg.ID().String(),
+			&MutationEvent{
+				Type:     event.GetType(),
+				NodeCfg:  cfg,
+				NodeDsc:  dsc,
+				Mutation: [2]string{event.GetModule(), event.GetId()},
+				Attempt:  event.GetAttempt(),
+				Trace:    event.GetTraceparent(),
+			})
+	case pb.EventControl_StateChange:
+		event := ec.GetStateChangeControl()
+		v = NewEvent(
+			lib.Event_STATE_CHANGE,
+			event.GetUrl(),
+			&StateChangeEvent{
+				Type:  event.GetType(),
+				URL:   event.GetUrl(),
+				Value: reflect.ValueOf(event.GetValue()),
+			})
+	case pb.EventControl_Discovery:
+		event := ec.GetDiscoveryEvent()
+		v = NewEvent(
+			lib.Event_DISCOVERY,
+			event.GetUrl(),
+			&DiscoveryEvent{
+				ID:      event.GetId(),
+				URL:     event.GetUrl(),
+				ValueID: event.GetValueId(),
+				Trace:   event.GetTraceparent(),
+			})
+	default:
+		return nil
+	}
+	v.(*Event).seq = ec.GetSeq()
+	return
+}
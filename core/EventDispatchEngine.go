@@ -11,7 +11,9 @@ package core
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/hpc/kraken/lib"
 )
@@ -23,25 +25,59 @@ import (
 var _ lib.EventDispatchEngine = (*EventDispatchEngine)(nil)
 
 // EventDispatchEngine redistributes event to (possibly filtered) listeners.
+// Each listener gets its own bounded queue, drained by its own goroutine, and events are delivered to each
+// listener in the order they arrived.  Dispatch only ever waits on a listener whose queue is full and BLOCKs,
+// and even then it keeps handling subscriptions (so the listener can be unsubscribed) and Stop, and it gives up
+// on the listener after the block time.
 type EventDispatchEngine struct {
-	lists map[string]lib.EventListener // place we send Events to
-	schan chan lib.EventListener       // where we get subscriptions
+	lists map[string]*listenerQueue // place we send Events to
+	schan chan lib.EventListener    // where we get subscriptions
 	// should we have a unique echan for each source?
-	echan chan []lib.Event // where we get events
-	log   lib.Logger
-	lock  *sync.RWMutex // Make sure we don't concurrently add a listener and iterate through the list
+	echan    chan []lib.Event // where we get events
+	log      lib.Logger
+	lock     *sync.RWMutex // Make sure we don't concurrently add a listener and iterate through the list
+	qsize    int
+	overflow lib.EventOverflowPolicy
+	drain    time.Duration
+	block    time.Duration
+	dropped  map[string]uint64 // drop counts for listeners that are gone
+	seq      uint64            // last sequence number assigned
+	journal  *EventJournal     // nil if we aren't journaling
+	quit     chan interface{}
+	stopped  chan interface{}
+	stopOnce sync.Once
 }
 
 // NewEventDispatchEngine creates an initialized EventDispatchEngine
 func NewEventDispatchEngine(ctx Context) (v *EventDispatchEngine) {
 	v = &EventDispatchEngine{
-		lists: make(map[string]lib.EventListener),
-		schan: make(chan lib.EventListener),
-		echan: make(chan []lib.Event),
-		log:   &ctx.Logger,
-		lock:  &sync.RWMutex{},
+		lists:    make(map[string]*listenerQueue),
+		schan:    make(chan lib.EventListener),
+		echan:    make(chan []lib.Event),
+		log:      &ctx.Logger,
+		lock:     &sync.RWMutex{},
+		qsize:    ctx.EDE.QueueSize,
+		overflow: ctx.EDE.Overflow,
+		drain:    ctx.EDE.DrainTime,
+		block:    ctx.EDE.BlockTime,
+		dropped:  make(map[string]uint64),
+		quit:     make(chan interface{}),
+		stopped:  make(chan interface{}),
+	}
+	if v.qsize < 1 {
+		v.qsize = 1024
 	}
 	v.log.SetModule("EventDispatchEngine")
+	if ctx.EDE.Journal.Dir != "" {
+		j, e := NewEventJournal(ctx.EDE.Journal, v.log)
+		if e != nil {
+			v.Logf(ERROR, "event journal disabled: %v", e)
+		} else {
+			v.journal = j
+			v.seq = j.Last()
+			v.Logf(INFO, "journaling events to %s, starting after sequence number %d", ctx.EDE.Journal.Dir, v.seq)
+		}
+	}
 	return
 }
 
@@ -52,8 +88,10 @@ func (v *EventDispatchEngine) AddListener(el lib.EventListener) (e error) {
 	k := el.Name()
 	switch el.State() {
 	case lib.EventListener_UNSUBSCRIBE:
-		if _, ok := v.lists[k]; ok {
-			delete(v.lists, k)
+		if q, ok := v.lists[k]; ok {
+			v.removeQueue(q)
+			// the listener doesn't want anything else
+			q.discard()
 		} else {
 			e = fmt.Errorf("cannot unsubscribe unknown listener: %s", k)
 		}
@@ -62,7 +100,14 @@ func (v *EventDispatchEngine) AddListener(el lib.EventListener) (e error) {
 		fallthrough
 	case lib.EventListener_RUN:
 		// should we check validity?
-		v.lists[k] = el
+		if q, ok := v.lists[k]; ok {
+			// replacing a listener; whatever it had queued still goes to the old one
+			v.removeQueue(q)
+			q.close()
+		}
+		q := v.newQueue(el)
+		v.lists[k] = q
+		go q.run()
 		return
 	default:
 		e = fmt.Errorf("unknown EventListener state: %d", el.State())
@@ -76,19 +121,83 @@ func (v *EventDispatchEngine) SubscriptionChan() chan<- lib.EventListener { retu
 // EventChan is the channel emitters should send new events on
 func (v *EventDispatchEngine) EventChan() chan<- []lib.Event { return v.echan }
 
+// Dropped reports how many events have been dropped for each listener, by listener name
+func (v *EventDispatchEngine) Dropped() (r map[string]uint64) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	r = make(map[string]uint64)
+	for k, d := range v.dropped {
+		r[k] = d
+	}
+	for k, q := range v.lists {
+		r[k] += q.droppedCount()
+	}
+	return
+}
+
+// Replay calls f on journaled events in order, starting at sequence number from, until f returns false.
+// If there is no journal, there's nothing to replay.
+func (v *EventDispatchEngine) Replay(from uint64, f func(lib.Event) bool) error {
+	if v.journal == nil {
+		return nil
+	}
+	return v.journal.Replay(from, f)
+}
+
+// Metrics reports on event dispatch and listener queues
+// LOCKS: lock (R); listener queue mutexes
+func (v *EventDispatchEngine) Metrics() (fams []MetricFamily) {
+	depth := MetricFamily{
+		Name: "kraken_ede_queue_depth",
+		Help: "Events waiting to be delivered, by listener.",
+		Type: MetricGauge,
+	}
+	v.lock.RLock()
+	seq := v.seq
+	names := make([]string, 0, len(v.lists))
+	for k := range v.lists {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		depth.Metrics = append(depth.Metrics, Metric{
+			Labels: []MetricLabel{{"listener", k}},
+			Value:  float64(v.lists[k].depth()),
+		})
+	}
+	v.lock.RUnlock()
+	dropped := MetricFamily{
+		Name: "kraken_ede_events_dropped_total",
+		Help: "Events dropped because a listener's queue was full, by listener.",
+		Type: MetricCounter,
+	}
+	ds := v.Dropped()
+	names = names[:0]
+	for k := range ds {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		dropped.Metrics = append(dropped.Metrics, Metric{Labels: []MetricLabel{{"listener", k}}, Value: float64(ds[k])})
+	}
+	fams = append(fams, MetricFamily{
+		Name:    "kraken_ede_events_total",
+		Help:    "Events dispatched; this is also the last event sequence number.",
+		Type:    MetricCounter,
+		Metrics: []Metric{{Value: float64(seq)}},
+	}, depth, dropped)
+	return
+}
+
 // Run is a goroutine than handles event dispatch and subscriptions
-// There's currently no way to stop this once it's started.
+// It runs until Stop is called.
 func (v *EventDispatchEngine) Run(ready chan<- interface{}) {
 	v.Log(INFO, "starting EventDispatchEngine")
 	ready <- nil
 	for {
 		select {
 		case el := <-v.schan:
-			if e := v.AddListener(el); e != nil {
-				v.Logf(ERROR, "failed to add new listener: %s, %v", el.Name(), e)
-			} else {
-				v.Logf(DEBUG, "successfully added new listener: %s", el.Name())
-			}
+			v.subscribe(el)
 			break
 		case e := <-v.echan:
 			if len(e) == 0 {
@@ -97,31 +206,337 @@ func (v *EventDispatchEngine) Run(ready chan<- interface{}) {
 			} else {
 				v.Logf(DEBUG, "dispatching event: %s %s %v\n", lib.EventTypeString[e[0].Type()], e[0].URL(), e[0].Data())
 			}
-			go v.sendEvents(e)
+			e = v.sequence(e)
+			v.sendEvents(e)
 			break
+		case <-v.quit:
+			v.shutdown()
+			close(v.stopped)
+			return
 		}
 	}
 }
 
+// Stop stops event dispatch.  Events that are already queued get delivered, but
+// we only wait up to the configured drain time for listeners to take them.
+// Stop must only be called after Run.
+func (v *EventDispatchEngine) Stop() {
+	v.stopOnce.Do(func() { close(v.quit) })
+	<-v.stopped
+}
+
 ////////////////////////
 // Unexported methods /
 //////////////////////
 
-// goroutine
+// sequence assigns sequence numbers to events, and journals them
+func (v *EventDispatchEngine) sequence(evs []lib.Event) []lib.Event {
+	r := make([]lib.Event, len(evs))
+	v.lock.Lock()
+	for i, ev := range evs {
+		v.seq++
+		r[i] = eventWithSeq(ev, v.seq)
+	}
+	v.lock.Unlock()
+	if v.journal != nil {
+		if e := v.journal.Write(r); e != nil {
+			v.Logf(ERROR, "%v", e)
+		}
+	}
+	return r
+}
+
+// subscribe handles a subscription request
+func (v *EventDispatchEngine) subscribe(el lib.EventListener) {
+	if e := v.AddListener(el); e != nil {
+		v.Logf(ERROR, "failed to add new listener: %s, %v", el.Name(), e)
+	} else {
+		v.Logf(DEBUG, "successfully added new listener: %s", el.Name())
+	}
+}
+
+// sendEvents queues events for every interested listener
+// this can wait if a listener with the BLOCK policy is full; see wait
 func (v *EventDispatchEngine) sendEvents(evs []lib.Event) {
+	// we don't hold the lock while queueing, so a blocked listener can still be unsubscribed
 	v.lock.RLock()
-	defer v.lock.RUnlock()
+	qs := make([]*listenerQueue, 0, len(v.lists))
+	for _, q := range v.lists {
+		qs = append(qs, q)
+	}
+	v.lock.RUnlock()
 	for _, ev := range evs {
-		for _, el := range v.lists {
-			if el.Type() == lib.Event_ALL {
-				el.Send(ev)
-			} else if ev.Type() == el.Type() {
-				el.Send(ev)
+		// queue for everyone with room first, so a full listener only holds up later events
+		full := []*listenerQueue{}
+		for _, q := range qs {
+			t := q.el.Type()
+			if t != lib.Event_ALL && ev.Type() != t {
+				continue
+			}
+			// filter before queueing so uninteresting events don't take up room
+			if !q.el.Filter(ev) {
+				continue
+			}
+			switch v.enqueue(q, ev) {
+			case enqueueFull:
+				full = append(full, q)
+			case enqueueDisconnect:
+				v.disconnect(q)
+			}
+		}
+		for _, q := range full {
+			if !v.wait(q, ev) {
+				v.disconnect(q)
+			}
+		}
+	}
+}
+
+type enqueueResult uint8
+
+const (
+	enqueueOK enqueueResult = iota
+	enqueueFull
+	enqueueDisconnect
+)
+
+// enqueue queues an event for a listener without waiting, applying its overflow policy if its queue is full.
+// It returns enqueueFull if the listener BLOCKs and we need to wait for room.
+func (v *EventDispatchEngine) enqueue(q *listenerQueue, ev lib.Event) enqueueResult {
+	if q.isClosed() {
+		q.drop()
+		return enqueueOK
+	}
+	select {
+	case q.ch <- ev:
+		return enqueueOK
+	default:
+	}
+	switch q.overflow {
+	case lib.EventOverflow_DROP_OLDEST:
+		q.pushDropOldest(ev)
+		return enqueueOK
+	case lib.EventOverflow_DISCONNECT:
+		q.drop()
+		return enqueueDisconnect
+	}
+	return enqueueFull
+}
+
+// wait waits for room in a BLOCK queue.  It returns false if the listener should be disconnected.
+// While we wait, we keep handling subscriptions, so the listener can be unsubscribed,
+// and we give up on the event if we're stopped, or on the listener if it takes longer than the block time.
+func (v *EventDispatchEngine) wait(q *listenerQueue, ev lib.Event) bool {
+	var timeout <-chan time.Time
+	if v.block > 0 {
+		t := time.NewTimer(v.block)
+		defer t.Stop()
+		timeout = t.C
+	}
+	for {
+		select {
+		case q.ch <- ev:
+			return true
+		case <-q.closing: // unsubscribed while we waited
+			q.drop()
+			return true
+		case el := <-v.schan:
+			v.subscribe(el)
+		case <-v.quit:
+			q.drop()
+			return true
+		case <-timeout:
+			q.drop()
+			v.Logf(ERROR, "listener %s didn't take any events for %v", q.el.Name(), v.block)
+			return false
+		}
+	}
+}
+
+// disconnect unsubscribes a listener that overflowed its queue
+func (v *EventDispatchEngine) disconnect(q *listenerQueue) {
+	v.lock.Lock()
+	if v.lists[q.el.Name()] != q {
+		// already gone
+		v.lock.Unlock()
+		return
+	}
+	v.removeQueue(q)
+	v.lock.Unlock()
+	q.discard()
+	q.el.SetState(lib.EventListener_UNSUBSCRIBE)
+	v.Logf(ERROR, "event queue for listener %s overflowed, disconnecting", q.el.Name())
+}
+
+// shutdown closes all of the queues and waits for them to drain
+func (v *EventDispatchEngine) shutdown() {
+	v.lock.Lock()
+	qs := []*listenerQueue{}
+	for _, q := range v.lists {
+		qs = append(qs, q)
+		q.close()
+	}
+	v.lock.Unlock()
+	timeout := time.After(v.drain)
+	for _, q := range qs {
+		select {
+		case <-q.done:
+		case <-timeout:
+			v.Logf(ERROR, "timed out waiting for listener %s to take its queued events", q.el.Name())
+			q.discard()
+		}
+	}
+	if v.journal != nil {
+		v.journal.Close()
+	}
+	v.Log(INFO, "stopped EventDispatchEngine")
+}
+
+// newQueue creates a queue for a listener, using its own settings if it has them
+func (v *EventDispatchEngine) newQueue(el lib.EventListener) *listenerQueue {
+	size, overflow := v.qsize, v.overflow
+	if qel, ok := el.(lib.EventListenerWithQueue); ok && qel.QueueSize() > 0 {
+		size, overflow = qel.QueueSize(), qel.Overflow()
+	}
+	return newListenerQueue(el, size, overflow, v.log)
+}
+
+// removeQueue takes a queue out of the listener list, keeping its drop count
+// !!!IMPORTANT!!! assumes you already hold a lock
+func (v *EventDispatchEngine) removeQueue(q *listenerQueue) {
+	n := q.el.Name()
+	delete(v.lists, n)
+	if d := q.droppedCount(); d > 0 {
+		v.dropped[n] += d
+	}
+}
+
+// a listenerQueue is a bounded queue of events that are delivered to a single listener, in order, by its own goroutine
+// Only dispatch queues events, so only dispatch closes the queue; that way we never queue on a closed queue.
+type listenerQueue struct {
+	el         lib.EventListener
+	overflow   lib.EventOverflowPolicy
+	log        lib.Logger
+	ch         chan lib.Event
+	closing    chan interface{} // closed when the queue stops taking events
+	closeOnce  sync.Once
+	mutex      *sync.Mutex // protects dropped and discarding
+	dropped    uint64
+	discarding bool
+	done       chan interface{}
+}
+
+func newListenerQueue(el lib.EventListener, size int, overflow lib.EventOverflowPolicy, log lib.Logger) *listenerQueue {
+	return &listenerQueue{
+		el:       el,
+		overflow: overflow,
+		log:      log,
+		ch:       make(chan lib.Event, size),
+		closing:  make(chan interface{}),
+		mutex:    &sync.Mutex{},
+		done:     make(chan interface{}),
+	}
+}
+
+// pushDropOldest queues an event, making room by dropping the oldest queued events
+func (q *listenerQueue) pushDropOldest(ev lib.Event) {
+	for {
+		select {
+		case q.ch <- ev:
+			return
+		default:
+		}
+		select {
+		case <-q.ch:
+			q.mutex.Lock()
+			if q.dropped == 0 {
+				q.log.Logf(ERROR, "event queue for listener %s is full, dropping oldest events", q.el.Name())
 			}
+			q.dropped++
+			q.mutex.Unlock()
+		default: // the listener just took one
 		}
 	}
 }
 
+// run delivers events until the queue is closed and empty
+// goroutine
+func (q *listenerQueue) run() {
+	defer close(q.done)
+	for {
+		select {
+		case ev := <-q.ch:
+			q.deliver(ev)
+		case <-q.closing:
+			for {
+				select {
+				case ev := <-q.ch:
+					q.deliver(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver sends an event to the listener, unless we're discarding.  Events were filtered before they were queued.
+func (q *listenerQueue) deliver(ev lib.Event) {
+	q.mutex.Lock()
+	if q.discarding {
+		q.dropped++
+		q.mutex.Unlock()
+		return
+	}
+	q.mutex.Unlock()
+	var e error
+	if d, ok := q.el.(lib.EventListenerWithDeliver); ok {
+		e = d.Deliver(ev)
+	} else {
+		e = q.el.Send(ev)
+	}
+	if e != nil {
+		q.log.Logf(DEBUG, "failed to send event to listener %s: %v", q.el.Name(), e)
+	}
+}
+
+// close stops the queue from taking new events; anything already queued still gets delivered
+func (q *listenerQueue) close() {
+	q.closeOnce.Do(func() { close(q.closing) })
+}
+
+// discard closes the queue and throws away anything that hasn't been delivered
+func (q *listenerQueue) discard() {
+	q.mutex.Lock()
+	q.discarding = true
+	q.mutex.Unlock()
+	q.close()
+}
+
+func (q *listenerQueue) isClosed() bool {
+	select {
+	case <-q.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// drop counts an event we didn't queue
+func (q *listenerQueue) drop() {
+	q.mutex.Lock()
+	q.dropped++
+	q.mutex.Unlock()
+}
+
+func (q *listenerQueue) depth() int { return len(q.ch) }
+
+func (q *listenerQueue) droppedCount() uint64 {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.dropped
+}
+
 ////////////////////////////
 // Passthrough Interfaces /
 //////////////////////////
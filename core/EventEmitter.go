@@ -11,6 +11,7 @@ package core
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/hpc/kraken/lib"
 )
@@ -23,23 +24,30 @@ var _ lib.EventEmitter = (*EventEmitter)(nil)
 
 // EventEmitter is really just a helper object, and not a core type.
 // It simplifies making an engine that Emits events to event dispatch.
+// Events are sent in the order they were emitted.
 type EventEmitter struct {
-	subs map[string]chan<- []lib.Event
-	t    lib.EventType
+	subs    map[string]chan<- []lib.Event
+	t       lib.EventType
+	mutex   *sync.Mutex // protects subs, pending and sending
+	pending [][]lib.Event
+	sending bool
 }
 
 // NewEventEmitter creates a new initialized EventEmitter.
 // It must be Subscribed to do anything interesting.
 func NewEventEmitter(t lib.EventType) *EventEmitter {
 	ne := &EventEmitter{
-		subs: make(map[string]chan<- []lib.Event),
-		t:    t,
+		subs:  make(map[string]chan<- []lib.Event),
+		t:     t,
+		mutex: &sync.Mutex{},
 	}
 	return ne
 }
 
 // Subscribe links the Emitter to an Event chan, allowing it to actually send events.
 func (m *EventEmitter) Subscribe(id string, c chan<- []lib.Event) (e error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	if _, ok := m.subs[id]; ok {
 		e = fmt.Errorf("subscription id already in use: %s", id)
 		return
@@ -50,6 +58,8 @@ func (m *EventEmitter) Subscribe(id string, c chan<- []lib.Event) (e error) {
 
 // Unsubscribe removes an event chan from the subscriber list
 func (m *EventEmitter) Unsubscribe(id string) (e error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	if _, ok := m.subs[id]; !ok {
 		e = fmt.Errorf("cannot unsubscribe, no such subscription: %s", id)
 		return
@@ -63,8 +73,15 @@ func (m *EventEmitter) EventType() lib.EventType { return m.t }
 
 // Emit emits (non-blocking) a slice of Events
 // NOT a goroutine; handles that internally
+// Events are queued and sent in order by a single goroutine.
 func (m *EventEmitter) Emit(v []lib.Event) {
-	go m.emit(v)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pending = append(m.pending, v)
+	if !m.sending {
+		m.sending = true
+		go m.emit()
+	}
 }
 
 // EmitOne is a helper for when we have a single event
@@ -76,8 +93,26 @@ func (m *EventEmitter) EmitOne(v lib.Event) {
 // Unexported methods /
 //////////////////////
 
-func (m *EventEmitter) emit(v []lib.Event) {
-	for _, s := range m.subs {
-		s <- v // should we introduce timeouts?
+// emit sends pending events until there are none left
+// goroutine
+func (m *EventEmitter) emit() {
+	for {
+		m.mutex.Lock()
+		if len(m.pending) == 0 {
+			m.sending = false
+			m.mutex.Unlock()
+			return
+		}
+		v := m.pending[0]
+		m.pending[0] = nil
+		m.pending = m.pending[1:]
+		subs := make([]chan<- []lib.Event, 0, len(m.subs))
+		for _, s := range m.subs {
+			subs = append(subs, s)
+		}
+		m.mutex.Unlock()
+		for _, s := range subs {
+			s <- v // should we introduce timeouts?
+		}
 	}
 }
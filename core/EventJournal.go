@@ -0,0 +1,309 @@
+/* EventJournal.go: an on-disk journal of dispatched events, so they can be replayed
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/hpc/kraken/core/proto"
+	"github.com/hpc/kraken/lib"
+)
+
+const journalSuffix = ".journal"
+
+// a journalSegment is one file of the journal, named for the first sequence number in it
+type journalSegment struct {
+	first uint64
+	path  string
+	size  int64
+}
+
+//////////////////////////
+// EventJournal Object /
+////////////////////////
+
+// An EventJournal writes events to a directory of segment files.
+// Each record is a uvarint length, a CRC32 of the data, and the data, which is a pb.EventControl.
+// Segments are rotated when they reach a size, and only a limited number are kept.
+type EventJournal struct {
+	dir     string
+	segSize int64
+	nsegs   int
+	sync    bool
+	log     lib.Logger
+	mutex   *sync.Mutex
+	segs    []*journalSegment // oldest first
+	cur     *os.File          // open for append; nil if the next write starts a new segment
+	last    uint64            // last sequence number written
+}
+
+// NewEventJournal opens (or creates) a journal in a directory.
+// A partially written record at the end of the journal, e.g. from a crash, is discarded.
+func NewEventJournal(cfg ContextEventJournal, log lib.Logger) (j *EventJournal, e error) {
+	j = &EventJournal{
+		dir:     cfg.Dir,
+		segSize: cfg.SegmentSize,
+		nsegs:   cfg.Segments,
+		sync:    cfg.Sync,
+		log:     log,
+		mutex:   &sync.Mutex{},
+		segs:    []*journalSegment{},
+	}
+	if j.segSize < 1 {
+		j.segSize = 64 * 1024 * 1024
+	}
+	if j.nsegs < 1 {
+		j.nsegs = 1
+	}
+	if e = os.MkdirAll(j.dir, 0755); e != nil {
+		return nil, fmt.Errorf("could not create event journal directory: %v", e)
+	}
+	fs, e := ioutil.ReadDir(j.dir)
+	if e != nil {
+		return nil, fmt.Errorf("could not read event journal directory: %v", e)
+	}
+	for _, f := range fs {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), journalSuffix) {
+			continue
+		}
+		first, e := strconv.ParseUint(strings.TrimSuffix(f.Name(), journalSuffix), 10, 64)
+		if e != nil {
+			continue
+		}
+		j.segs = append(j.segs, &journalSegment{
+			first: first,
+			path:  filepath.Join(j.dir, f.Name()),
+			size:  f.Size(),
+		})
+	}
+	sort.Slice(j.segs, func(a, b int) bool { return j.segs[a].first < j.segs[b].first })
+	if len(j.segs) == 0 {
+		return
+	}
+	// find where we left off
+	seg := j.segs[len(j.segs)-1]
+	j.last = seg.first - 1
+	good, e := readSegment(seg, func(ec *pb.EventControl) bool {
+		j.last = ec.GetSeq()
+		return true
+	})
+	if e != nil {
+		return nil, e
+	}
+	if good != seg.size {
+		j.log.Logf(NOTICE, "discarding %d bytes of partial record at the end of event journal segment %s", seg.size-good, seg.path)
+	}
+	if j.cur, e = os.OpenFile(seg.path, os.O_RDWR, 0644); e != nil {
+		return nil, fmt.Errorf("could not open event journal segment: %v", e)
+	}
+	if e = j.cur.Truncate(good); e != nil {
+		return nil, fmt.Errorf("could not truncate event journal segment: %v", e)
+	}
+	if _, e = j.cur.Seek(good, io.SeekStart); e != nil {
+		return nil, fmt.Errorf("could not seek event journal segment: %v", e)
+	}
+	seg.size = good
+	return
+}
+
+// Last is the last sequence number that was written to the journal
+func (j *EventJournal) Last() uint64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.last
+}
+
+// Write appends events to the journal.  Events must already have sequence numbers.
+func (j *EventJournal) Write(evs []lib.Event) (e error) {
+	buf := []byte{}
+	var first uint64
+	for _, ev := range evs {
+		ec, e := eventToProto(ev)
+		if e != nil {
+			// not something we know how to record
+			j.log.Logf(DEBUG, "not journaling event: %v", e)
+			continue
+		}
+		data, e := proto.Marshal(ec)
+		if e != nil {
+			return fmt.Errorf("could not marshal event for journal: %v", e)
+		}
+		if first == 0 {
+			first = ev.Seq()
+		}
+		var hdr [binary.MaxVarintLen64 + 4]byte
+		n := binary.PutUvarint(hdr[:], uint64(len(data)))
+		binary.BigEndian.PutUint32(hdr[n:], crc32.ChecksumIEEE(data))
+		buf = append(buf, hdr[:n+4]...)
+		buf = append(buf, data...)
+	}
+	if len(buf) == 0 {
+		return
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.cur == nil {
+		if e = j.newSegment(first); e != nil {
+			return
+		}
+	}
+	if _, e = j.cur.Write(buf); e != nil {
+		return fmt.Errorf("could not write to event journal: %v", e)
+	}
+	if j.sync {
+		if e = j.cur.Sync(); e != nil {
+			return fmt.Errorf("could not sync event journal: %v", e)
+		}
+	}
+	seg := j.segs[len(j.segs)-1]
+	seg.size += int64(len(buf))
+	j.last = evs[len(evs)-1].Seq()
+	if seg.size >= j.segSize {
+		j.cur.Close()
+		j.cur = nil
+	}
+	return
+}
+
+// Replay calls f on each journaled event, in order, starting at sequence number from, until f returns false.
+// If from is older than anything we still have, we start at the oldest event we have.
+// Events written while we replay may or may not be included.
+func (j *EventJournal) Replay(from uint64, f func(lib.Event) bool) (e error) {
+	j.mutex.Lock()
+	segs := make([]journalSegment, len(j.segs))
+	for i, seg := range j.segs {
+		segs[i] = *seg
+	}
+	j.mutex.Unlock()
+	if len(segs) > 0 && from < segs[0].first {
+		j.log.Logf(NOTICE, "event replay requested from %d, but the journal starts at %d", from, segs[0].first)
+	}
+	more := true
+	for i := range segs {
+		if !more {
+			break
+		}
+		if i+1 < len(segs) && segs[i+1].first <= from {
+			// entirely before what we want
+			continue
+		}
+		if _, e = readSegment(&segs[i], func(ec *pb.EventControl) bool {
+			if ec.GetSeq() < from {
+				return true
+			}
+			if ev := eventFromProto(ec); ev != nil {
+				more = f(ev)
+			}
+			return more
+		}); e != nil {
+			if os.IsNotExist(e) {
+				// the segment was rotated away while we were reading
+				e = nil
+				continue
+			}
+			return
+		}
+	}
+	return
+}
+
+// Close closes the journal
+func (j *EventJournal) Close() (e error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.cur != nil {
+		e = j.cur.Close()
+		j.cur = nil
+	}
+	return
+}
+
+////////////////////////
+// Unexported methods /
+//////////////////////
+
+// newSegment starts a new segment file, and removes old segments if we have too many
+// !!!IMPORTANT!!! assumes you already hold a lock
+func (j *EventJournal) newSegment(first uint64) (e error) {
+	seg := &journalSegment{
+		first: first,
+		path:  filepath.Join(j.dir, fmt.Sprintf("%020d%s", first, journalSuffix)),
+	}
+	if j.cur, e = os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644); e != nil {
+		return fmt.Errorf("could not create event journal segment: %v", e)
+	}
+	j.segs = append(j.segs, seg)
+	for len(j.segs) > j.nsegs {
+		if e := os.Remove(j.segs[0].path); e != nil && !os.IsNotExist(e) {
+			j.log.Logf(ERROR, "could not remove old event journal segment %s: %v", j.segs[0].path, e)
+		}
+		j.segs = j.segs[1:]
+	}
+	return
+}
+
+// readSegment reads the records of a segment, up to its known size, calling f on each until it returns false.
+// It returns the offset just past the last good record.
+func readSegment(seg *journalSegment, f func(*pb.EventControl) bool) (good int64, e error) {
+	fh, e := os.Open(seg.path)
+	if e != nil {
+		return
+	}
+	defer fh.Close()
+	r := bufio.NewReader(io.LimitReader(fh, seg.size))
+	for {
+		var l uint64
+		var crc [4]byte
+		if l, e = binary.ReadUvarint(r); e != nil {
+			break
+		}
+		if _, e = io.ReadFull(r, crc[:]); e != nil {
+			break
+		}
+		if l > uint64(seg.size) {
+			break
+		}
+		data := make([]byte, l)
+		if _, e = io.ReadFull(r, data); e != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(crc[:]) {
+			break
+		}
+		ec := &pb.EventControl{}
+		if e = proto.Unmarshal(data, ec); e != nil {
+			break
+		}
+		good += int64(uvarintLen(l) + 4 + len(data))
+		if !f(ec) {
+			break
+		}
+	}
+	// a short or corrupt record just ends the segment
+	e = nil
+	return
+}
+
+func uvarintLen(x uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], x)
+}
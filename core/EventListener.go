@@ -11,6 +11,7 @@ package core
 
 import (
 	"regexp"
+	"sync"
 
 	"github.com/hpc/kraken/lib"
 )
@@ -58,19 +59,32 @@ func ChanSender(ev lib.Event, c chan<- lib.Event) error {
 	return nil
 }
 
+// unsubscribe politely unsubscribes listeners
+func unsubscribe(schan chan<- lib.EventListener, els ...lib.EventListener) {
+	for _, el := range els {
+		el.SetState(lib.EventListener_UNSUBSCRIBE)
+		schan <- el
+	}
+}
+
 //////////////////////////
 // EventListener Object /
 ////////////////////////
 
 var _ lib.EventListener = (*EventListener)(nil)
+var _ lib.EventListenerWithQueue = (*EventListener)(nil)
+var _ lib.EventListenerWithDeliver = (*EventListener)(nil)
 
 // An EventListener implementation that leaves filter/send as arbitrary function pointers.
 type EventListener struct {
 	name   string
 	s      lib.EventListenerState
+	smutex *sync.Mutex // protects s; dispatch reads it while its owner sets it
 	filter func(lib.Event) bool
 	send   func(lib.Event) error
 	t      lib.EventType
+	qsize  int
+	qover  lib.EventOverflowPolicy
 }
 
 // NewEventListener creates a new initialized, full specified EventListener
@@ -78,6 +92,7 @@ func NewEventListener(name string, t lib.EventType, filter func(lib.Event) bool,
 	el := &EventListener{}
 	el.name = name
 	el.s = lib.EventListener_RUN
+	el.smutex = &sync.Mutex{}
 	el.filter = filter
 	el.send = send
 	el.t = t
@@ -88,10 +103,18 @@ func NewEventListener(name string, t lib.EventType, filter func(lib.Event) bool,
 func (v *EventListener) Name() string { return v.name }
 
 // State is the current state of the listener; listeners can be temporarily muted, for instance
-func (v *EventListener) State() lib.EventListenerState { return v.s }
+func (v *EventListener) State() lib.EventListenerState {
+	v.smutex.Lock()
+	defer v.smutex.Unlock()
+	return v.s
+}
 
 // SetState sets the listener runstate
-func (v *EventListener) SetState(s lib.EventListenerState) { v.s = s }
+func (v *EventListener) SetState(s lib.EventListenerState) {
+	v.smutex.Lock()
+	defer v.smutex.Unlock()
+	v.s = s
+}
 
 // Send processes the callback to send the event to the object listening.
 func (v *EventListener) Send(ev lib.Event) (e error) {
@@ -101,6 +124,9 @@ func (v *EventListener) Send(ev lib.Event) (e error) {
 	return
 }
 
+// Deliver sends an event that already passed Filter
+func (v *EventListener) Deliver(ev lib.Event) error { return v.send(ev) }
+
 // Filter processes a filter callback, returns whether this event would be filtered.
 // Send uses this automatically.
 func (v *EventListener) Filter(ev lib.Event) (r bool) {
@@ -109,3 +135,16 @@ func (v *EventListener) Filter(ev lib.Event) (r bool) {
 
 // Type returns the type of event we're listening for.  This is another kind of filter.
 func (v *EventListener) Type() lib.EventType { return v.t }
+
+// SetQueue sets the event queue size and overflow policy for this listener.
+// This must be set before the listener is subscribed.  A size of 0 uses the EventDispatchEngine defaults.
+func (v *EventListener) SetQueue(size int, overflow lib.EventOverflowPolicy) {
+	v.qsize = size
+	v.qover = overflow
+}
+
+// QueueSize is the size of the event queue for this listener, 0 means use the default
+func (v *EventListener) QueueSize() int { return v.qsize }
+
+// Overflow is what to do when the event queue for this listener is full
+func (v *EventListener) Overflow() lib.EventOverflowPolicy { return v.qover }
@@ -10,10 +10,14 @@
 package core
 
 import (
+	"crypto/tls"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"os/exec"
+	"os/user"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"time"
@@ -39,8 +43,17 @@ type Context struct {
 	Parents []string
 	SSE     ContextSSE
 	SME     ContextSME
+	SM      ContextSM
+	EDE     ContextEDE
 	RPC     ContextRPC
-	Sm      lib.ServiceManager // API needs this
+	Trace   ContextTrace
+	Authz   ContextAuthz
+	Sm      lib.ServiceManager      // API needs this
+	Ede     lib.EventDispatchEngine // API needs this for event replay
+	metrics []MetricsSource         // API needs these to serve metrics
+	tracer  *Tracer                 // SME and API need this; nil if tracing is disabled
+	authz   *Authorizer             // API needs this; nil if authorization is disabled
+	sme     *StateMutationEngine    // SM needs this to register the mutations of plugins
 	sdqChan chan lib.Query
 	smqChan chan lib.Query
 }
@@ -54,17 +67,68 @@ type ContextSSE struct {
 	DeadTime  time.Duration
 }
 
+type ContextEDE struct {
+	QueueSize int                     // default event queue size per listener
+	Overflow  lib.EventOverflowPolicy // default policy for full event queues
+	DrainTime time.Duration           // how long Stop waits for queued events to be delivered
+	BlockTime time.Duration           // how long dispatch waits for room in a BLOCK queue before it disconnects the listener; 0 waits forever
+	Journal   ContextEventJournal
+}
+
+type ContextEventJournal struct {
+	Dir         string // where to keep the event journal; empty disables the journal
+	SegmentSize int64  // size at which journal files are rotated
+	Segments    int    // how many journal files to keep
+	Sync        bool   // sync the journal to disk after every write
+}
+
 type ContextSME struct {
-	RootSpec lib.StateSpec
+	RootSpec      lib.StateSpec
+	HistoryLength int           // how many mutation paths to remember per node, 0 disables history
+	DrainTime     time.Duration // how long Shutdown waits for active mutation paths to end before interrupting them
+}
+
+type ContextSM struct {
+	CgroupRoot   string        // cgroup v2 directory to run services in, each in its own cgroup; empty runs them in ours, without resource limits
+	PluginDir    string        // directory of plugin executables (out-of-tree modules) to run as services; empty for none
+	StopTimeout  time.Duration // how long Shutdown gives services to stop before it kills them
+	ShutdownTime time.Duration // how long Shutdown waits for all of the services to stop, before it moves on without them
+}
+
+type ContextTrace struct {
+	File      string        // append spans to this file as JSON lines
+	OTLP      string        // send spans to this OTLP/HTTP collector, e.g. http://localhost:4318
+	Service   string        // service name to report spans as
+	CloseTime time.Duration // how long Shutdown waits for queued spans to be exported
+}
+
+type ContextAuthz struct {
+	PolicyFile string // JSON authorization policy; empty allows every API call
 }
 
 type ContextRPC struct {
 	Network      string
 	Addr         string
 	Port         int
-	Path         string // path for UNIX socket
+	Path         string        // path for UNIX socket
+	SocketMode   os.FileMode   // permissions of the UNIX socket; only kraken's user can use it by default (0600)
+	SocketGroup  string        // group that owns the UNIX socket, e.g. so its members can use it with SocketMode 0660
+	TrustedUIDs  []uint32      // users whose calls over the UNIX socket can name a principal; kraken's own user if empty
+	DrainTime    time.Duration // how long Shutdown lets API calls in progress finish
 	NetListner   net.Listener
 	UNIXListener net.Listener
+	API          ContextRPCAPI
+}
+
+// ContextRPCAPI configures serving the API over TCP, for remote clients.  Remote clients always use TLS.
+type ContextRPCAPI struct {
+	Addr              string // host:port to serve the API on; empty only serves it on the UNIX socket
+	CertFile          string // PEM certificate to serve TLS with
+	KeyFile           string // PEM key for CertFile
+	ClientCAFile      string // PEM CA certificates to verify client certificates with; clients are identified by their certificate CN
+	RequireClientCert bool   // reject clients without a valid client certificate
+	Listener          net.Listener
+	TLSConfig         *tls.Config
 }
 
 ///////////////////
@@ -117,13 +181,35 @@ func NewKraken(self lib.Node, parents []string, logger lib.Logger) *Kraken {
 		DeadTime:  40 * time.Second,
 	}
 	k.Ctx.SME = ContextSME{
-		RootSpec: DefaultRootSpec(),
+		RootSpec:      DefaultRootSpec(),
+		HistoryLength: 16,
+		DrainTime:     20 * time.Second,
+	}
+	k.Ctx.SM = ContextSM{
+		StopTimeout:  10 * time.Second,
+		ShutdownTime: 60 * time.Second,
+	}
+	k.Ctx.EDE = ContextEDE{
+		QueueSize: 1024,
+		Overflow:  lib.EventOverflow_BLOCK,
+		DrainTime: 5 * time.Second,
+		BlockTime: 30 * time.Second,
+		Journal: ContextEventJournal{
+			SegmentSize: 64 * 1024 * 1024,
+			Segments:    8,
+		},
+	}
+	k.Ctx.Trace = ContextTrace{
+		Service:   "kraken",
+		CloseTime: 15 * time.Second,
 	}
 	k.Ctx.RPC = ContextRPC{
-		Network: "tcp",
-		Addr:    ip.String(),
-		Port:    31415,
-		Path:    "/tmp/kraken.sock",
+		Network:    "tcp",
+		Addr:       ip.String(),
+		Port:       31415,
+		Path:       "/tmp/kraken.sock",
+		SocketMode: 0600,
+		DrainTime:  5 * time.Second,
 	}
 	k.SetModule("kraken")
 	return k
@@ -192,26 +278,58 @@ func (k *Kraken) Bootstrap() {
 	go ServiceLoggerListener(k.log, slog)
 
 	// setup the RPC listener, to be shared
-	if e := setupRPCListener(&k.Ctx.RPC); e != nil {
+	if e := setupRPCListener(&k.Ctx.RPC, k.Ctx.Authz); e != nil {
 		k.Logf(FATAL, "%v", e)
 		os.Exit(1)
 		return
 	}
 	k.Logf(INFO, "RPC is listening on %s:%s:%d", k.Ctx.RPC.Network, k.Ctx.RPC.Addr, k.Ctx.RPC.Port)
 	k.Logf(INFO, "RPC is listening on socket %s", k.Ctx.RPC.Path)
+	if k.Ctx.RPC.API.Listener != nil {
+		k.Logf(INFO, "API is listening for remote clients on %s", k.Ctx.RPC.API.Addr)
+	}
+
+	// setup tracing; module processes inherit our environment, so they trace to the same places
+	if t, e := NewTracer(k.Ctx.Trace, k.log); e != nil {
+		k.Logf(ERROR, "failed to setup tracing, continuing without it: %v", e)
+	} else if t != nil {
+		k.Ctx.tracer = t
+		os.Setenv(TraceFileEnv, k.Ctx.Trace.File)
+		os.Setenv(TraceOTLPEnv, k.Ctx.Trace.OTLP)
+		k.Log(INFO, "tracing is enabled")
+	}
+
+	// setup authorization; we don't run with a policy we can't enforce
+	if a, e := NewAuthorizer(k.Ctx.Authz.PolicyFile, k.log); e != nil {
+		k.Logf(FATAL, "failed to load authorization policy: %v", e)
+		os.Exit(1)
+		return
+	} else if a != nil {
+		k.Ctx.authz = a
+		k.Logf(INFO, "authorization is enabled with policy: %s", k.Ctx.Authz.PolicyFile)
+	}
 
 	k.Ctx.sdqChan = make(chan lib.Query)
 	k.Ctx.smqChan = make(chan lib.Query)
 
 	k.Ede = NewEventDispatchEngine(k.Ctx)
 	k.Ctx.SubChan = k.Ede.SubscriptionChan()
+	k.Ctx.Ede = k.Ede
 	k.Sde = NewStateDifferenceEngine(k.self, k.Ctx, k.Ctx.sdqChan)
 	k.Ctx.Query = *NewQueryEngine(k.Ctx.sdqChan, k.Ctx.smqChan)
+	k.Sme = NewStateMutationEngine(k.Ctx, k.Ctx.smqChan)
+	k.Ctx.sme = k.Sme // SM needs this
 	k.Sm = NewServiceManager(k.Ctx, "unix:"+k.Ctx.RPC.Path)
 	k.Ctx.Sm = k.Sm // API needs this
 
 	k.Sse = NewStateSyncEngine(k.Ctx)
-	k.Sme = NewStateMutationEngine(k.Ctx, k.Ctx.smqChan)
+	k.Ctx.metrics = []MetricsSource{k.Ede, k.Sme, k.Sse, k.Sm}
+	if k.Ctx.tracer != nil {
+		k.Ctx.metrics = append(k.Ctx.metrics, k.Ctx.tracer)
+	}
+	if k.Ctx.authz != nil {
+		k.Ctx.metrics = append(k.Ctx.metrics, k.Ctx.authz)
+	}
 	k.Api = NewAPIServer(k.Ctx)
 
 	k.Sde.Subscribe("SDE", k.Ede.EventChan())
@@ -259,21 +377,112 @@ func (k *Kraken) Run() {
 	}
 }
 
+// shutdownGrace is how much longer than its own time limit we wait for a step of Shutdown before we move on without it
+const shutdownGrace = 5 * time.Second
+
+// Shutdown stops the Kraken gracefully.  Remote API clients are cut off first, then no new mutations
+// are started, and the ones in progress get a chance to finish before they're interrupted.  Services are
+// stopped in dependency order, then the engines that use them, then the API and state.  Queued events
+// are delivered (and journaled), and queued spans are exported, last.
+// Each step is bounded, so a stuck step can't hold up the rest.
+// Shutdown must only be called after Run.
+func (k *Kraken) Shutdown() {
+	k.Log(NOTICE, "shutting down")
+	k.shutdownStep("cut off remote API clients", k.Ctx.RPC.DrainTime, func() { k.Api.StopRemote(k.Ctx.RPC.DrainTime) })
+	k.shutdownStep("drain mutations", k.Ctx.SME.DrainTime, func() { k.Sme.Drain(k.Ctx.SME.DrainTime) })
+	k.shutdownStep("stop services", k.Ctx.SM.ShutdownTime, func() { k.Sm.Shutdown(k.Ctx.SM.StopTimeout) })
+	k.shutdownStep("stop StateMutationEngine", 0, func() { k.Sme.Stop(shutdownGrace) })
+	k.shutdownStep("stop StateSyncEngine", 0, func() { k.Sse.Shutdown(shutdownGrace) })
+	k.shutdownStep("stop API", k.Ctx.RPC.DrainTime, func() { k.Api.Stop(k.Ctx.RPC.DrainTime) })
+	k.shutdownStep("stop StateDifferenceEngine", 0, func() { k.Sde.Stop(shutdownGrace) })
+	k.shutdownStep("stop EventDispatchEngine", k.Ctx.EDE.DrainTime, k.Ede.Stop)
+	k.shutdownStep("close tracer", k.Ctx.Trace.CloseTime, k.Ctx.tracer.Close)
+	k.Log(NOTICE, "shut down")
+}
+
+// shutdownStep runs a step of Shutdown, and waits up to timeout (plus shutdownGrace) for it
+func (k *Kraken) shutdownStep(name string, timeout time.Duration, f func()) {
+	done := make(chan interface{})
+	go func() {
+		f()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout + shutdownGrace):
+		k.Logf(ERROR, "shutdown step timed out, moving on without it: %s", name)
+	}
+}
+
 ////////////////////////
 // Unexported methods /
 //////////////////////
 
-func setupRPCListener(cfg *ContextRPC) (e error) {
+// unixSocketListener removes its socket when it's closed
+type unixSocketListener struct {
+	net.Listener
+	path string
+}
+
+func (l *unixSocketListener) Close() error {
+	defer os.Remove(l.path)
+	return l.Listener.Close()
+}
+
+func setupRPCListener(cfg *ContextRPC, authz ContextAuthz) (e error) {
 	// Setup gRPC
 	cfg.NetListner, e = net.Listen(cfg.Network, cfg.Addr+":"+strconv.Itoa(cfg.Port))
 	if e != nil {
 		return fmt.Errorf("listen for RPC failed: %v", e)
 	}
 	os.Remove(cfg.Path)
-	cfg.UNIXListener, e = net.Listen("unix", cfg.Path)
+	// nobody else gets to connect before we've set the socket's permissions, so we make it somewhere only we can reach,
+	// and move it into place once it's ready
+	dir, e := ioutil.TempDir(filepath.Dir(cfg.Path), ".kraken-sock-")
 	if e != nil {
+		return fmt.Errorf("could not make a directory for the RPC socket: %v", e)
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "sock")
+	var l net.Listener
+	if l, e = net.Listen("unix", tmp); e != nil {
 		return fmt.Errorf("listen for RPC failed: %v", e)
 	}
+	// the listener would remove tmp when it's closed, not the path we move it to
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	cfg.UNIXListener = &unixSocketListener{Listener: l, path: cfg.Path}
+	mode := cfg.SocketMode
+	if mode == 0 {
+		mode = 0600
+	}
+	if e = os.Chmod(tmp, mode); e != nil {
+		return fmt.Errorf("could not set RPC socket permissions: %v", e)
+	}
+	if cfg.SocketGroup != "" {
+		var g *user.Group
+		if g, e = user.LookupGroup(cfg.SocketGroup); e != nil {
+			return fmt.Errorf("unknown RPC socket group: %v", e)
+		}
+		gid, _ := strconv.Atoi(g.Gid)
+		if e = os.Chown(tmp, -1, gid); e != nil {
+			return fmt.Errorf("could not set RPC socket group: %v", e)
+		}
+	}
+	if e = os.Rename(tmp, cfg.Path); e != nil {
+		return fmt.Errorf("could not move RPC socket into place: %v", e)
+	}
+	if cfg.API.Addr != "" {
+		// without either, anyone who can reach the port could make any call
+		if !(cfg.API.ClientCAFile != "" && cfg.API.RequireClientCert) && authz.PolicyFile == "" {
+			return fmt.Errorf("remote API needs client certificates to be required, or an authorization policy")
+		}
+		if cfg.API.TLSConfig, e = lib.NewServerTLSConfig(cfg.API.CertFile, cfg.API.KeyFile, cfg.API.ClientCAFile, cfg.API.RequireClientCert); e != nil {
+			return fmt.Errorf("remote API needs TLS: %v", e)
+		}
+		if cfg.API.Listener, e = net.Listen("tcp", cfg.API.Addr); e != nil {
+			return fmt.Errorf("listen for remote API failed: %v", e)
+		}
+	}
 	return
 }
 
@@ -0,0 +1,168 @@
+/* Metrics.go: metrics about kraken internals, written in the Prometheus text exposition format
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package core
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Prometheus metric types
+const (
+	MetricGauge     = "gauge"
+	MetricCounter   = "counter"
+	MetricHistogram = "histogram"
+)
+
+// A MetricLabel is a name/value pair that distinguishes metrics in a family
+type MetricLabel struct {
+	Name  string
+	Value string
+}
+
+// A Metric is a single sample in a MetricFamily
+type Metric struct {
+	Suffix string // added to the family name, e.g. _bucket for histograms
+	Labels []MetricLabel
+	Value  float64
+}
+
+// A MetricFamily is a named, documented set of metrics of one type
+type MetricFamily struct {
+	Name    string
+	Help    string
+	Type    string
+	Metrics []Metric
+}
+
+// A MetricsSource can report metrics about itself.  It must be safe to call at any time.
+type MetricsSource interface {
+	Metrics() []MetricFamily
+}
+
+// WriteMetrics writes metric families in the Prometheus text exposition format (version 0.0.4)
+func WriteMetrics(w io.Writer, fams []MetricFamily) error {
+	b := bufio.NewWriter(w)
+	for _, f := range fams {
+		b.WriteString("# HELP " + f.Name + " " + metricHelpEscaper.Replace(f.Help) + "\n")
+		b.WriteString("# TYPE " + f.Name + " " + f.Type + "\n")
+		for _, m := range f.Metrics {
+			b.WriteString(f.Name + m.Suffix)
+			if len(m.Labels) > 0 {
+				b.WriteString("{")
+				for i, l := range m.Labels {
+					if i > 0 {
+						b.WriteString(",")
+					}
+					b.WriteString(l.Name + "=\"" + metricLabelEscaper.Replace(l.Value) + "\"")
+				}
+				b.WriteString("}")
+			}
+			b.WriteString(" " + metricValue(m.Value) + "\n")
+		}
+	}
+	return b.Flush()
+}
+
+// metricEnum makes a 0/1 metric for every possible value of an enum, so absent values show up as 0
+// names maps enum values to their names, as in the protobuf _name maps
+func metricEnum(names map[int32]string, cur int32, labels ...MetricLabel) []Metric {
+	return metricEnumCounts(names, map[int32]float64{cur: 1}, labels...)
+}
+
+// metricEnumCounts makes a metric for every possible value of an enum, with the count for that value
+func metricEnumCounts(names map[int32]string, counts map[int32]float64, labels ...MetricLabel) (ms []Metric) {
+	vals := make([]int, 0, len(names))
+	for v := range names {
+		vals = append(vals, int(v))
+	}
+	sort.Ints(vals)
+	for _, v := range vals {
+		ms = append(ms, Metric{
+			Labels: append(append([]MetricLabel{}, labels...), MetricLabel{"state", names[int32(v)]}),
+			Value:  counts[int32(v)],
+		})
+	}
+	return
+}
+
+var metricHelpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func metricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// defaultDurationBuckets are histogram buckets (in seconds) for how long things like mutations take
+var defaultDurationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
+// a metricHistogram counts observations into buckets
+type metricHistogram struct {
+	mutex   *sync.Mutex
+	buckets []float64 // upper bounds, ascending
+	counts  []uint64  // not cumulative
+	sum     float64
+	count   uint64
+}
+
+func newMetricHistogram(buckets []float64) *metricHistogram {
+	return &metricHistogram{
+		mutex:   &sync.Mutex{},
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *metricHistogram) observe(v float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// metrics gives the _bucket, _sum and _count samples for this histogram
+func (h *metricHistogram) metrics(labels []MetricLabel) (ms []Metric) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var cum uint64
+	for i, b := range h.buckets {
+		cum += h.counts[i]
+		ms = append(ms, Metric{
+			Suffix: "_bucket",
+			Labels: append(append([]MetricLabel{}, labels...), MetricLabel{"le", metricValue(b)}),
+			Value:  float64(cum),
+		})
+	}
+	ms = append(ms,
+		Metric{Suffix: "_bucket", Labels: append(append([]MetricLabel{}, labels...), MetricLabel{"le", "+Inf"}), Value: float64(h.count)},
+		Metric{Suffix: "_sum", Labels: labels, Value: h.sum},
+		Metric{Suffix: "_count", Labels: labels, Value: float64(h.count)},
+	)
+	return
+}
@@ -0,0 +1,417 @@
+/* MutationLint.go: offline sanity checks for the mutation graph
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hpc/kraken/lib"
+)
+
+// MutationLintType describes the kind of problem a MutationLintIssue reports
+type MutationLintType uint8
+
+const (
+	MutationLint_CONFLICT    MutationLintType = iota // a mutation's requires/excludes/mutates can never be satisfied
+	MutationLint_FAILTO                              // a mutation's FailTo() points at a discoverable nobody registers
+	MutationLint_UNUSED                              // a mutation never appears in the graph
+	MutationLint_UNREACHABLE                         // a graph state can't be reached from the root
+	MutationLint_DEADEND                             // a group of graph states has no way out
+	MutationLint_AMBIGUOUS                           // more than one shortest path connects two states
+)
+
+var MutationLintString = map[MutationLintType]string{
+	MutationLint_CONFLICT:    "CONFLICT",
+	MutationLint_FAILTO:      "FAILTO",
+	MutationLint_UNUSED:      "UNUSED",
+	MutationLint_UNREACHABLE: "UNREACHABLE",
+	MutationLint_DEADEND:     "DEADEND",
+	MutationLint_AMBIGUOUS:   "AMBIGUOUS",
+}
+
+// A MutationLintIssue is a single problem found by LintMutations
+type MutationLintIssue struct {
+	Type     MutationLintType
+	Mutation [2]string // [0] = module, [1] = mutid; empty if the issue is about graph states
+	Message  string
+}
+
+func (i MutationLintIssue) String() string {
+	if i.Mutation[0] == "" && i.Mutation[1] == "" {
+		return fmt.Sprintf("(%s) %s", MutationLintString[i.Type], i.Message)
+	}
+	return fmt.Sprintf("(%s) %s:%s: %s", MutationLintString[i.Type], i.Mutation[0], i.Mutation[1], i.Message)
+}
+
+// LintMutations builds the mutation graph for a set of mutations, starting from root, and reports likely problems.
+// muts and dscs are laid out like Registry.Mutations and Registry.Discoverables.
+// Nothing is started; this is meant to be run offline, e.g. against the modules of a build configuration.
+func LintMutations(root lib.StateSpec, muts map[string]map[string]lib.StateMutation, dscs map[string]map[string]map[string]reflect.Value, log lib.Logger) (issues []MutationLintIssue) {
+	ctx := Context{
+		SME: ContextSME{RootSpec: root},
+	}
+	lc := make(chan LoggerEvent)
+	go ServiceLoggerListener(log, lc)
+	ctx.Logger.RegisterChannel(lc)
+	ctx.Logger.SetLoggerLevel(log.GetLoggerLevel())
+	sme := NewStateMutationEngine(ctx, nil)
+
+	mods := []string{}
+	for mod := range muts {
+		mods = append(mods, mod)
+	}
+	sort.Strings(mods)
+	for _, mod := range mods {
+		ids := []string{}
+		for id := range muts[mod] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			mut := muts[mod][id]
+			issues = append(issues, lintMutation(mod, id, mut, dscs)...)
+			if len(uncomparableURLs(mut)) > 0 {
+				// the graph builder compares values with ==, and would panic
+				continue
+			}
+			sme.muts = append(sme.muts, mut)
+			sme.mutResolver[mut] = [2]string{mod, id}
+		}
+	}
+	sme.onUpdate()
+
+	sme.graphMutex.RLock()
+	issues = append(issues, sme.lintGraph()...)
+	sme.graphMutex.RUnlock()
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Type < issues[j].Type })
+	return
+}
+
+// lintMutation checks a single mutation for problems that don't need the graph
+func lintMutation(mod, id string, mut lib.StateMutation, dscs map[string]map[string]map[string]reflect.Value) (issues []MutationLintIssue) {
+	issue := func(t MutationLintType, f string, v ...interface{}) {
+		issues = append(issues, MutationLintIssue{
+			Type:     t,
+			Mutation: [2]string{mod, id},
+			Message:  fmt.Sprintf(f, v...),
+		})
+	}
+	for _, u := range uncomparableURLs(mut) {
+		issue(MutationLint_CONFLICT, "%s is set to values that can't be compared; the mutation can't be added to the graph", u)
+	}
+	reqs, excs := mut.Requires(), mut.Excludes()
+	for _, u := range sortedURLs(reqs) {
+		if ev, ok := excs[u]; ok && reflect.DeepEqual(ev.Interface(), reqs[u].Interface()) {
+			issue(MutationLint_CONFLICT, "%s both requires and excludes %s", u, lib.ValueToString(ev))
+		}
+	}
+	mutates := mut.Mutates()
+	mus := []string{}
+	for u := range mutates {
+		mus = append(mus, u)
+	}
+	sort.Strings(mus)
+	for _, u := range mus {
+		from := mutates[u][0]
+		if ev, ok := excs[u]; ok && reflect.DeepEqual(ev.Interface(), from.Interface()) {
+			issue(MutationLint_CONFLICT, "mutates %s from %s, but excludes that value", u, lib.ValueToString(from))
+		}
+		if rv, ok := reqs[u]; ok && !reflect.DeepEqual(rv.Interface(), from.Interface()) {
+			issue(MutationLint_CONFLICT, "mutates %s from %s, but requires %s", u, lib.ValueToString(from), lib.ValueToString(rv))
+		}
+	}
+
+	d := mut.FailTo()
+	if d[0] == "" && d[1] == "" && d[2] == "" {
+		issue(MutationLint_FAILTO, "no FailTo discoverable is set")
+		return
+	}
+	if _, ok := dscs[d[0]][d[1]][d[2]]; !ok {
+		issue(MutationLint_FAILTO, "FailTo %s:%s:%s is not a registered discoverable", d[0], d[1], d[2])
+	}
+	return
+}
+
+// lintGraph checks the built graph for unused mutations, unreachable states, dead ends and ambiguous paths
+// !!!IMPORTANT!!!
+// lintGraph assumes you already hold a lock
+func (sme *StateMutationEngine) lintGraph() (issues []MutationLintIssue) {
+	// 1. unused mutations
+	used := map[lib.StateMutation]bool{}
+	for _, e := range sme.edges {
+		used[e.mut] = true
+	}
+	for _, m := range sme.muts {
+		if !used[m] {
+			issues = append(issues, MutationLintIssue{
+				Type:     MutationLint_UNUSED,
+				Mutation: sme.mutResolver[m],
+				Message:  "mutation does not appear anywhere in the mutation graph",
+			})
+		}
+	}
+
+	// 2. unreachable states
+	// a new node can start anywhere in the graph that is compatible with the root spec
+	reach := map[*mutationNode]bool{}
+	queue := []*mutationNode{}
+	for _, n := range sme.nodes {
+		if n == sme.graph || n.spec.SpecCompat(sme.root) {
+			reach[n] = true
+			queue = append(queue, n)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range n.out {
+			if !reach[e.to] {
+				reach[e.to] = true
+				queue = append(queue, e.to)
+			}
+		}
+	}
+	for _, n := range sme.nodes {
+		if !reach[n] {
+			issues = append(issues, MutationLintIssue{
+				Type:    MutationLint_UNREACHABLE,
+				Message: fmt.Sprintf("state {%s} can not be reached from the root {%s}", specLabelLine(n.spec), specLabelLine(sme.root)),
+			})
+		}
+	}
+
+	// 3. dead ends
+	// a healthy graph eventually funnels into a single set of states that can all reach each other.
+	// if there's more than one such set for the same non-mutating state (e.g. the same /Arch),
+	// a node that falls into one can never get to the others.
+	var sinks [][]*mutationNode
+	for _, scc := range sme.lintSCCs(reach) {
+		in := map[*mutationNode]bool{}
+		for _, n := range scc {
+			in[n] = true
+		}
+		exit := false
+		for _, n := range scc {
+			for _, e := range n.out {
+				if !in[e.to] {
+					exit = true
+				}
+			}
+		}
+		if !exit {
+			sinks = append(sinks, scc)
+		}
+	}
+	nonMut := func(n *mutationNode) lib.StateSpec {
+		reqs := map[string]reflect.Value{}
+		for u, v := range n.spec.Requires() {
+			if _, ok := sme.mutators[u]; !ok {
+				reqs[u] = v
+			}
+		}
+		return NewStateSpec(reqs, map[string]reflect.Value{})
+	}
+	for i, scc := range sinks {
+		dead := false
+		for j, other := range sinks {
+			if i != j && nonMut(scc[0]).SpecCompat(nonMut(other[0])) {
+				dead = true
+				break
+			}
+		}
+		if dead {
+			labels := []string{}
+			for _, n := range scc {
+				labels = append(labels, "{"+specLabelLine(n.spec)+"}")
+			}
+			sort.Strings(labels)
+			issues = append(issues, MutationLintIssue{
+				Type:    MutationLint_DEADEND,
+				Message: fmt.Sprintf("%d state(s) have no way out: %s", len(scc), strings.Join(labels, " ")),
+			})
+		}
+	}
+
+	// 4. ambiguous paths
+	// drijkstra picks arbitrarily between equal cost paths, so which mutations run can vary from node to node.
+	// we only report where the paths converge, so each ambiguity is reported once.
+	type ambiguity struct {
+		from  *mutationNode
+		count uint64
+		dist  uint32
+	}
+	seen := map[string]*ambiguity{}
+	var keys []string
+	keyNodes := map[string]*mutationNode{}
+	for _, s := range sme.nodes {
+		if !reach[s] {
+			continue
+		}
+		dist, count, order := sme.lintShortestPaths(s)
+		for _, t := range order {
+			if count[t] < 2 {
+				continue
+			}
+			preds := []string{}
+			converge := true
+			for _, e := range t.in {
+				if d, ok := dist[e.from]; ok && d+e.cost == dist[t] {
+					if count[e.from] != 1 {
+						converge = false
+						break
+					}
+					preds = append(preds, fmt.Sprintf("%p", e))
+				}
+			}
+			if !converge {
+				continue
+			}
+			sort.Strings(preds)
+			key := fmt.Sprintf("%p:%s", t, strings.Join(preds, ","))
+			if a, ok := seen[key]; ok {
+				if dist[t] < a.dist {
+					a.from, a.count, a.dist = s, count[t], dist[t]
+				}
+				continue
+			}
+			seen[key] = &ambiguity{from: s, count: count[t], dist: dist[t]}
+			keys = append(keys, key)
+			keyNodes[key] = t
+		}
+	}
+	for _, k := range keys {
+		a := seen[k]
+		issues = append(issues, MutationLintIssue{
+			Type: MutationLint_AMBIGUOUS,
+			Message: fmt.Sprintf("%d equal cost paths (cost %d) from {%s} to {%s}",
+				a.count, a.dist, specLabelLine(a.from.spec), specLabelLine(keyNodes[k].spec)),
+		})
+	}
+	return
+}
+
+// lintShortestPaths computes distances and shortest path counts from s
+// order lists reachable nodes by increasing distance
+// !!!IMPORTANT!!!
+// lintShortestPaths assumes you already hold a lock
+func (sme *StateMutationEngine) lintShortestPaths(s *mutationNode) (dist map[*mutationNode]uint32, count map[*mutationNode]uint64, order []*mutationNode) {
+	dist = map[*mutationNode]uint32{s: 0}
+	count = map[*mutationNode]uint64{s: 1}
+	done := map[*mutationNode]bool{}
+	for {
+		var u *mutationNode
+		for n, d := range dist {
+			if !done[n] && (u == nil || d < dist[u]) {
+				u = n
+			}
+		}
+		if u == nil {
+			return
+		}
+		done[u] = true
+		order = append(order, u)
+		for _, e := range u.out {
+			alt := dist[u] + e.cost
+			if d, ok := dist[e.to]; !ok || alt < d {
+				dist[e.to] = alt
+				count[e.to] = count[u]
+			} else if alt == d && !done[e.to] {
+				count[e.to] += count[u]
+			}
+		}
+	}
+}
+
+// lintSCCs returns the strongly connected components of the nodes in set (Tarjan)
+// !!!IMPORTANT!!!
+// lintSCCs assumes you already hold a lock
+func (sme *StateMutationEngine) lintSCCs(set map[*mutationNode]bool) (sccs [][]*mutationNode) {
+	index := map[*mutationNode]int{}
+	low := map[*mutationNode]int{}
+	onStack := map[*mutationNode]bool{}
+	stack := []*mutationNode{}
+	i := 0
+	var connect func(n *mutationNode)
+	connect = func(n *mutationNode) {
+		index[n], low[n] = i, i
+		i++
+		stack = append(stack, n)
+		onStack[n] = true
+		for _, e := range n.out {
+			if !set[e.to] {
+				continue
+			}
+			if _, ok := index[e.to]; !ok {
+				connect(e.to)
+				if low[e.to] < low[n] {
+					low[n] = low[e.to]
+				}
+			} else if onStack[e.to] && index[e.to] < low[n] {
+				low[n] = index[e.to]
+			}
+		}
+		if low[n] == index[n] {
+			scc := []*mutationNode{}
+			for {
+				m := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[m] = false
+				scc = append(scc, m)
+				if m == n {
+					break
+				}
+			}
+			sccs = append(sccs, scc)
+		}
+	}
+	for _, n := range sme.nodes {
+		if _, ok := index[n]; !ok && set[n] {
+			connect(n)
+		}
+	}
+	return
+}
+
+// uncomparableURLs lists the URLs a mutation sets to a type that can't be compared with ==, e.g. a slice or map
+func uncomparableURLs(mut lib.StateMutation) (r []string) {
+	found := map[string]bool{}
+	check := func(u string, v reflect.Value) {
+		if v.IsValid() && !v.Type().Comparable() {
+			found[u] = true
+		}
+	}
+	for u, vs := range mut.Mutates() {
+		check(u, vs[0])
+		check(u, vs[1])
+	}
+	for u, v := range mut.Requires() {
+		check(u, v)
+	}
+	for u, v := range mut.Excludes() {
+		check(u, v)
+	}
+	for u := range found {
+		r = append(r, u)
+	}
+	sort.Strings(r)
+	return
+}
+
+func sortedURLs(m map[string]reflect.Value) (r []string) {
+	for u := range m {
+		r = append(r, u)
+	}
+	sort.Strings(r)
+	return
+}
@@ -13,6 +13,7 @@
 package core
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"reflect"
 	"sync"
@@ -82,6 +83,15 @@ func NewNodeFromMessage(m *pb.Node) *Node {
 	return n
 }
 
+// ErrNodeVersion is returned by a conditional update when the node has changed since it was read
+var ErrNodeVersion = fmt.Errorf("node has changed since it was read")
+
+// NodeVersion identifies the current contents of a node, e.g. for a conditional update.
+// It's a hash of the node's JSON, which is the same wherever the node gets read.
+func NodeVersion(n lib.Node) string {
+	return fmt.Sprintf("%x", sha256.Sum256(n.JSON()))[:16]
+}
+
 // ID returns the NodeID object for the node
 // Note: we don't lock on this under the assumption that ID's don't typically change
 func (n *Node) ID() lib.NodeID {
@@ -135,6 +145,11 @@ func (n *Node) Message() proto.Message {
 	return m
 }
 
+// copy makes a deep copy of the node
+func (n *Node) copy() *Node {
+	return NewNodeFromMessage(n.Message().(*pb.Node))
+}
+
 // GetValue returns a specific value (reflect.Value) by URL
 // note: we can't just wrap everything in a lock because n.GetService will lock too
 func (n *Node) GetValue(url string) (v reflect.Value, e error) {
@@ -145,12 +160,12 @@ func (n *Node) GetValue(url string) (v reflect.Value, e error) {
 	case "/type.googleapis.com": // resolve extension
 		p, sub := lib.URLShift(sub)
 		n.mutex.RLock()
+		defer n.mutex.RUnlock()
 		ext, ok := n.exts[lib.URLPush(root, p)]
 		if !ok {
 			e = fmt.Errorf("node does not have extension: %s", lib.URLPush(root, p))
 			return
 		}
-		defer n.mutex.RUnlock()
 		return lib.ResolveURL(sub, reflect.ValueOf(ext))
 	case "Services":
 		fallthrough
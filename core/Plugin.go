@@ -0,0 +1,357 @@
+/* Plugin.go: out-of-tree modules, run as separate executables that speak the module API
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	pb "github.com/hpc/kraken/core/proto"
+	"github.com/hpc/kraken/lib"
+)
+
+/*
+ * A plugin is an executable in the plugin directory.  Its file name is its module name, and the ID of its one service instance.
+ * kraken runs it like any other service, and it calls in with ServiceInit, MutationInit, DiscoveryInit, LoggerInit, etc.
+ * Its ServiceInit carries a PluginRegistration, with the config type, mutations and discoverables it provides.
+ */
+
+// FindPlugins finds the plugin executables in a directory, by name
+func FindPlugins(dir string) (map[string]string, error) {
+	fis, e := ioutil.ReadDir(dir)
+	if e != nil {
+		return nil, e
+	}
+	ps := make(map[string]string)
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		exe := filepath.Join(dir, fi.Name())
+		if fi, e = os.Stat(exe); e != nil || fi.IsDir() || fi.Mode()&0111 == 0 {
+			continue
+		}
+		ps[fi.Name()] = exe
+	}
+	return ps, nil
+}
+
+// NewPluginInstance provides a ServiceInstance that runs a plugin executable
+func NewPluginInstance(id, exe string) *ServiceInstance {
+	si := &ServiceInstance{
+		id:       id,
+		module:   id,
+		exe:      exe,
+		ctlMutex: &sync.Mutex{},
+		mutex:    &sync.Mutex{},
+	}
+	si.setState(lib.Service_STOP)
+	return si
+}
+
+// PluginExecute is the entry point of a plugin written in Go.
+// It runs m just like kraken runs a built-in module, after registering m's config type, muts and discs with kraken.
+func PluginExecute(m lib.ModuleSelfService, muts map[string]lib.StateMutation, discs map[string]map[string]reflect.Value) {
+	var cfg proto.Message
+	if mc, ok := m.(lib.ModuleWithConfig); ok {
+		cfg = mc.NewConfig()
+	}
+	reg, e := NewPluginRegistration(cfg, muts, discs)
+	if e != nil {
+		fmt.Printf("failed to describe plugin %s: %v\n", m.Name(), e)
+		return
+	}
+	Registry.RegisterModule(m)
+	// systemd (or ^C) signals all of kraken's processes, but kraken stops its plugins itself, in order
+	signal.Ignore(syscall.SIGTERM, syscall.SIGINT)
+	moduleExecute(m, os.Getenv("KRAKEN_ID"), os.Getenv("KRAKEN_MODULE"), os.Getenv("KRAKEN_SOCK"), reg)
+}
+
+// NewPluginRegistration describes a plugin's config type (cfg may be nil), mutations and discoverables for kraken
+func NewPluginRegistration(cfg proto.Message, muts map[string]lib.StateMutation, discs map[string]map[string]reflect.Value) (reg *pb.PluginRegistration, e error) {
+	reg = &pb.PluginRegistration{
+		Mutations:     make(map[string]*pb.PluginMutation),
+		Discoverables: make(map[string]*pb.PluginDiscoverable),
+	}
+	if cfg != nil {
+		md := proto.MessageV2(cfg).ProtoReflect().Descriptor()
+		reg.ConfigType = string(md.FullName())
+		seen := map[string]bool{}
+		var add func(f protoreflect.FileDescriptor)
+		add = func(f protoreflect.FileDescriptor) { // dependencies first
+			if seen[f.Path()] {
+				return
+			}
+			seen[f.Path()] = true
+			for i := 0; i < f.Imports().Len(); i++ {
+				add(f.Imports().Get(i).FileDescriptor)
+			}
+			reg.Files = append(reg.Files, protodesc.ToFileDescriptorProto(f))
+		}
+		add(md.ParentFile())
+	}
+	for url, vs := range discs {
+		d := &pb.PluginDiscoverable{Values: make(map[string]string)}
+		for id, v := range vs {
+			if d.Values[id], e = pluginJSON(v); e != nil {
+				return nil, fmt.Errorf("discoverable %s:%s: %v", url, id, e)
+			}
+		}
+		reg.Discoverables[url] = d
+	}
+	for id, m := range muts {
+		pm := &pb.PluginMutation{
+			Mutates:  make(map[string]*pb.PluginMutation_Change),
+			Requires: make(map[string]string),
+			Excludes: make(map[string]string),
+			Context:  pb.PluginMutation_Context(m.Context()),
+		}
+		for u, c := range m.Mutates() {
+			ch := &pb.PluginMutation_Change{}
+			if ch.From, e = pluginJSON(c[0]); e == nil {
+				ch.To, e = pluginJSON(c[1])
+			}
+			if e != nil {
+				return nil, fmt.Errorf("mutation %s: %s: %v", id, u, e)
+			}
+			pm.Mutates[u] = ch
+		}
+		for u, v := range m.Requires() {
+			if pm.Requires[u], e = pluginJSON(v); e != nil {
+				return nil, fmt.Errorf("mutation %s: %s: %v", id, u, e)
+			}
+		}
+		for u, v := range m.Excludes() {
+			if pm.Excludes[u], e = pluginJSON(v); e != nil {
+				return nil, fmt.Errorf("mutation %s: %s: %v", id, u, e)
+			}
+		}
+		if m.Timeout() != 0 {
+			pm.Timeout = ptypes.DurationProto(m.Timeout())
+		}
+		if ft := m.FailTo(); ft[1] != "" {
+			pm.FailToUrl, pm.FailToValue = ft[1], ft[2]
+		}
+		reg.Mutations[id] = pm
+	}
+	return
+}
+
+// RegisterPlugin registers the module and discoverables of a plugin, replacing any it registered before.
+// Its mutations are returned, for the SME; they aren't added to Mutations.
+func (r *KrakenRegistry) RegisterPlugin(name string, reg *pb.PluginRegistration) (muts map[string]lib.StateMutation, e error) {
+	if _, ok := r.Modules[name]; ok {
+		return nil, fmt.Errorf("plugin has the name of a built-in module: %s", name)
+	}
+	var m lib.Module = &pluginModule{name: name}
+	if reg.GetConfigType() != "" {
+		var md protoreflect.MessageDescriptor
+		if md, e = pluginConfigType(reg.GetFiles(), reg.GetConfigType()); e != nil {
+			return
+		}
+		m = &pluginConfigModule{pluginModule{name: name}, md}
+	}
+	n := NewNodeWithID("00000000-0000-0000-0000-000000000000") // for the types of URLs
+	discs := make(map[string]map[string]reflect.Value)
+	for url, d := range reg.GetDiscoverables() {
+		discs[url] = make(map[string]reflect.Value)
+		for id, j := range d.GetValues() {
+			if discs[url][id], e = r.pluginValue(n, url, j); e != nil {
+				return nil, fmt.Errorf("discoverable %s:%s: %v", url, id, e)
+			}
+		}
+	}
+	muts = make(map[string]lib.StateMutation)
+	for id, pm := range reg.GetMutations() {
+		if muts[id], e = r.pluginMutation(n, name, pm, discs); e != nil {
+			return nil, fmt.Errorf("mutation %s: %v", id, e)
+		}
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.plugins[name] = m
+	r.Discoverables[name] = discs
+	return
+}
+
+// pluginMutation decodes a plugin's mutation
+func (r *KrakenRegistry) pluginMutation(n *Node, name string, pm *pb.PluginMutation, discs map[string]map[string]reflect.Value) (lib.StateMutation, error) {
+	if len(pm.GetMutates()) == 0 {
+		return nil, fmt.Errorf("it doesn't mutate anything")
+	}
+	var e error
+	mut := make(map[string][2]reflect.Value)
+	for u, c := range pm.GetMutates() {
+		var v [2]reflect.Value
+		if v[0], e = r.pluginValue(n, u, c.GetFrom()); e == nil {
+			v[1], e = r.pluginValue(n, u, c.GetTo())
+		}
+		if e != nil {
+			return nil, fmt.Errorf("%s: %v", u, e)
+		}
+		mut[u] = v
+	}
+	req := make(map[string]reflect.Value)
+	for u, j := range pm.GetRequires() {
+		if req[u], e = r.pluginValue(n, u, j); e != nil {
+			return nil, fmt.Errorf("%s: %v", u, e)
+		}
+	}
+	exc := make(map[string]reflect.Value)
+	for u, j := range pm.GetExcludes() {
+		if exc[u], e = r.pluginValue(n, u, j); e != nil {
+			return nil, fmt.Errorf("%s: %v", u, e)
+		}
+	}
+	var timeout time.Duration
+	if pm.GetTimeout() != nil {
+		if timeout, e = ptypes.Duration(pm.GetTimeout()); e != nil {
+			return nil, e
+		}
+	}
+	failto := [3]string{}
+	if pm.GetFailToUrl() != "" {
+		if _, ok := discs[pm.GetFailToUrl()][pm.GetFailToValue()]; !ok {
+			return nil, fmt.Errorf("it fails to %s:%s, which the plugin doesn't discover", pm.GetFailToUrl(), pm.GetFailToValue())
+		}
+		failto = [3]string{name, pm.GetFailToUrl(), pm.GetFailToValue()}
+	}
+	return NewStateMutation(mut, req, exc, lib.StateMutationContext(pm.GetContext()), timeout, failto), nil
+}
+
+// pluginValue decodes the JSON of a plugin's value for a URL, as the type of that URL in node n.
+// n gets the extensions the URLs need, if we have them.
+func (r *KrakenRegistry) pluginValue(n *Node, url, j string) (reflect.Value, error) {
+	root, sub := lib.URLShift(url)
+	switch strings.TrimPrefix(root, "/") {
+	case "type.googleapis.com":
+		p, _ := lib.URLShift(sub)
+		ext := lib.URLPush("type.googleapis.com", p)
+		if !n.HasExtension(ext) {
+			x, ok := r.Extensions[ext]
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("unknown extension: %s", ext)
+			}
+			n.AddExtension(x.New())
+		}
+	case "Services": // our node doesn't have the plugin's service
+		_, sub = lib.URLShift(sub)
+		cur, e := lib.ResolveURL(sub, reflect.ValueOf(&pb.ServiceInstance{}))
+		if e != nil {
+			return cur, e
+		}
+		return UnmarshalValueJSON([]byte(j), cur.Type())
+	}
+	cur, e := n.GetValue(url)
+	if e != nil {
+		return cur, e
+	}
+	return UnmarshalValueJSON([]byte(j), cur.Type())
+}
+
+// pluginJSON is the JSON of a value, as a plugin sends it
+func pluginJSON(v reflect.Value) (string, error) {
+	b, e := MarshalValueJSON(v)
+	return string(b), e
+}
+
+// pluginConfigType builds a plugin's config message type from its descriptors.
+// Files kraken already has (e.g. google/protobuf/*.proto) are used as they are.
+func pluginConfigType(fds []*descriptorpb.FileDescriptorProto, name string) (protoreflect.MessageDescriptor, error) {
+	res := pluginResolver{&protoregistry.Files{}}
+	for _, fd := range fds {
+		if _, e := protoregistry.GlobalFiles.FindFileByPath(fd.GetName()); e == nil {
+			continue
+		}
+		f, e := protodesc.NewFile(fd, res)
+		if e != nil {
+			return nil, fmt.Errorf("bad descriptor %s: %v", fd.GetName(), e)
+		}
+		if e = res.files.RegisterFile(f); e != nil {
+			return nil, fmt.Errorf("bad descriptor %s: %v", fd.GetName(), e)
+		}
+	}
+	d, e := res.FindDescriptorByName(protoreflect.FullName(name))
+	if e != nil {
+		return nil, fmt.Errorf("config type not found: %s", name)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("config type is not a message: %s", name)
+	}
+	return md, nil
+}
+
+// pluginResolver finds descriptors in a plugin's files, then in ours
+type pluginResolver struct {
+	files *protoregistry.Files
+}
+
+func (r pluginResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if f, e := r.files.FindFileByPath(path); e == nil {
+		return f, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r pluginResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, e := r.files.FindDescriptorByName(name); e == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// pluginModule is the module of a plugin, as kraken sees it
+type pluginModule struct {
+	name string
+}
+
+func (p *pluginModule) Name() string { return p.name }
+
+// pluginConfigModule is the module of a plugin that takes a config
+type pluginConfigModule struct {
+	pluginModule
+	config protoreflect.MessageDescriptor
+}
+
+func (p *pluginConfigModule) NewConfig() proto.Message { return dynamicpb.NewMessage(p.config) }
+
+// UpdateConfig does nothing; the plugin reads its own config
+func (p *pluginConfigModule) UpdateConfig(proto.Message) error { return nil }
+
+func (p *pluginConfigModule) ConfigURL() string {
+	return "type.googleapis.com/" + string(p.config.FullName())
+}
+
+// sortedKeys is the keys of a map of plugin mutations, in order
+func sortedKeys(m map[string]lib.StateMutation) (ks []string) {
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return
+}
@@ -190,6 +190,16 @@ func (q *QueryEngine) ReadNodeMutationPath(url string) (mc pb.MutationPath, e er
 	return v[0].Interface().(pb.MutationPath), e
 }
 
+func (q *QueryEngine) ReadNodeMutationHistory(url string) (mc pb.MutationHistory, e error) {
+	n := NewNodeIDFromURL(url)
+	query, r := NewQuery(lib.Query_MUTATIONHISTORY, lib.QueryState_BOTH, url, []reflect.Value{reflect.ValueOf(n)})
+	v, e := q.blockingQuery(query, r)
+	if len(v) < 1 || !v[0].IsValid() {
+		return
+	}
+	return v[0].Interface().(pb.MutationHistory), e
+}
+
 func (q *QueryEngine) Freeze() (e error) {
 	query, r := NewQuery(
 		lib.Query_FREEZE,
@@ -235,6 +245,21 @@ func (q *QueryEngine) Update(n lib.Node) (nc lib.Node, e error) {
 	return v[0].Interface().(lib.Node), e
 }
 
+// UpdateIf will update a node in the Engine's Cfg store, but only if it's still at version (see NodeVersion);
+// otherwise, it returns ErrNodeVersion
+func (q *QueryEngine) UpdateIf(n lib.Node, version string) (nc lib.Node, e error) {
+	query, r := NewQuery(
+		lib.Query_UPDATE,
+		lib.QueryState_CONFIG,
+		"",
+		[]reflect.Value{reflect.ValueOf(n), reflect.ValueOf(version)})
+	v, e := q.blockingQuery(query, r)
+	if len(v) < 1 || !v[0].IsValid() {
+		return
+	}
+	return v[0].Interface().(lib.Node), e
+}
+
 // UpdateDsc will update a node in the Engine's Dsc store
 func (q *QueryEngine) UpdateDsc(n lib.Node) (nc lib.Node, e error) {
 	query, r := NewQuery(
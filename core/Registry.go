@@ -10,13 +10,19 @@
 package core
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/hpc/kraken/lib"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/runtime/protoimpl"
 )
 
 //////////////////////
@@ -53,6 +59,84 @@ func UnmarshalJSON(in []byte, p proto.Message) error {
 	return um.Unmarshal(strings.NewReader(string(in)), p)
 }
 
+// UnmarshalConfigJSON decodes the JSON of a module's config.
+// If it has an @type it is decoded as that type, otherwise as the type the module's NewConfig gives.
+func UnmarshalConfigJSON(module string, in []byte) (p proto.Message, e error) {
+	var typed struct {
+		Type string `json:"@type"`
+	}
+	json.Unmarshal(in, &typed)
+	if typed.Type != "" {
+		var a any.Any
+		if e = UnmarshalJSON(in, &a); e != nil {
+			return
+		}
+		if p, e = Registry.Resolve(a.GetTypeUrl()); e != nil {
+			return nil, fmt.Errorf("unknown config type: %s", a.GetTypeUrl())
+		}
+		return p, ptypes.UnmarshalAny(&a, p)
+	}
+	mc, ok := Registry.Module(module).(lib.ModuleWithConfig)
+	if !ok {
+		return nil, fmt.Errorf("module %s doesn't take a config", module)
+	}
+	p = mc.NewConfig()
+	p.Reset()
+	return p, UnmarshalJSON(in, p)
+}
+
+// MarshalValueJSON marshals a node value (e.g. from Node.GetValue) to JSON.
+// Messages are marshaled with MarshalJSON, and enums by name.
+func MarshalValueJSON(v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return []byte("null"), nil
+	}
+	if m, ok := v.Interface().(proto.Message); ok {
+		return MarshalJSON(m)
+	}
+	if isEnumType(v.Type()) {
+		return json.Marshal(fmt.Sprint(v.Interface()))
+	}
+	return json.Marshal(v.Interface())
+}
+
+// UnmarshalValueJSON unmarshals JSON to a node value of type t (e.g. to use with Node.SetValue).
+// Enums can be given by name or number.
+func UnmarshalValueJSON(in []byte, t reflect.Type) (v reflect.Value, e error) {
+	if t.Implements(reflect.TypeOf((*proto.Message)(nil)).Elem()) && t.Kind() == reflect.Ptr {
+		v = reflect.New(t.Elem())
+		e = UnmarshalJSON(in, v.Interface().(proto.Message))
+		return
+	}
+	if isEnumType(t) {
+		var name string
+		if json.Unmarshal(in, &name) == nil {
+			ev := protoimpl.X.EnumDescriptorOf(reflect.Zero(t).Interface()).Values().ByName(protoreflect.Name(name))
+			if ev == nil {
+				return v, fmt.Errorf("unknown %s value: %s", t.Name(), name)
+			}
+			return reflect.ValueOf(int64(ev.Number())).Convert(t), nil
+		}
+	}
+	p := reflect.New(t)
+	if e = json.Unmarshal(in, p.Interface()); e != nil {
+		return
+	}
+	return p.Elem(), nil
+}
+
+// isEnumType checks for a protobuf enum, old or new style
+func isEnumType(t reflect.Type) bool {
+	if t.Kind() != reflect.Int32 {
+		return false
+	}
+	if t.Implements(reflect.TypeOf((*protoreflect.Enum)(nil)).Elem()) {
+		return true
+	}
+	_, ok := t.MethodByName("EnumDescriptor")
+	return ok
+}
+
 ///////////////////////////
 // KrakenRegistry Object /
 /////////////////////////
@@ -65,6 +149,9 @@ type KrakenRegistry struct {
 	Discoverables    map[string]map[string]map[string]reflect.Value // d["instance_id"]["property_url"]["value_id"]
 	Mutations        map[string]map[string]lib.StateMutation        // m["instance_id"]["mutation_id"]
 	ServiceInstances map[string]map[string]lib.ServiceInstance      // s["module"]["instance_id"]
+	// plugins register at runtime, so Discoverables and plugins are protected by mutex
+	plugins map[string]lib.Module
+	mutex   *sync.RWMutex
 }
 
 func NewKrakenRegistry() *KrakenRegistry {
@@ -74,6 +161,8 @@ func NewKrakenRegistry() *KrakenRegistry {
 		Discoverables:    make(map[string]map[string]map[string]reflect.Value),
 		Mutations:        make(map[string]map[string]lib.StateMutation),
 		ServiceInstances: make(map[string]map[string]lib.ServiceInstance),
+		plugins:          make(map[string]lib.Module),
+		mutex:            &sync.RWMutex{},
 	}
 	return r
 }
@@ -96,9 +185,41 @@ func (r *KrakenRegistry) RegisterExtension(e lib.Extension) {
 
 // RegisterDiscoverable adds a map of discoverables the module can emit
 func (r *KrakenRegistry) RegisterDiscoverable(si lib.ServiceInstance, d map[string]map[string]reflect.Value) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 	r.Discoverables[si.ID()] = d
 }
 
+// Discoverable looks up a value a service instance can discover
+func (r *KrakenRegistry) Discoverable(si, url, id string) (v reflect.Value, ok bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	v, ok = r.Discoverables[si][url][id]
+	return
+}
+
+// DiscoverableURLs lists every URL some service instance can discover
+func (r *KrakenRegistry) DiscoverableURLs() (urls []string) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for si := range r.Discoverables {
+		for u := range r.Discoverables[si] {
+			urls = append(urls, u)
+		}
+	}
+	return
+}
+
+// Module looks up a module by name, built-in or plugin
+func (r *KrakenRegistry) Module(name string) lib.Module {
+	if m, ok := r.Modules[name]; ok {
+		return m
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.plugins[name]
+}
+
 // RegisterMutations declares mutations a module can perform
 func (r *KrakenRegistry) RegisterMutations(si lib.ServiceInstance, d map[string]lib.StateMutation) {
 	r.Mutations[si.ID()] = d
@@ -122,5 +243,14 @@ func (r *KrakenRegistry) Resolve(url string) (proto.Message, error) {
 			}
 		}
 	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, m := range r.plugins {
+		if m, ok := m.(lib.ModuleWithConfig); ok {
+			if m.ConfigURL() == url {
+				return m.NewConfig(), nil
+			}
+		}
+	}
 	return nil, fmt.Errorf("proto not found")
 }
@@ -14,9 +14,11 @@ import (
 	"os"
 	"os/exec"
 	"sync"
+	"syscall"
 
 	"github.com/golang/protobuf/ptypes"
 
+	pb "github.com/hpc/kraken/core/proto"
 	"github.com/hpc/kraken/lib"
 )
 
@@ -29,27 +31,30 @@ var _ lib.ServiceInstance = (*ServiceInstance)(nil)
 // A ServiceInstance describes a service that will be built-in to the binary and exec'ed by forking
 // note: state information is stored in the node proto object, this object manages a running context
 type ServiceInstance struct {
-	id     string // ID must be unique
-	module string // name doesn't need to be unique; we can run multiple configs of the same service
-	exe    string // gets set automatically
-	entry  func() // needs to run as a goroutine
-	sock   string
-	cmd    *exec.Cmd
-	ctl    chan<- lib.ServiceControl
-	wchan  chan<- lib.ServiceInstanceUpdate
-	state  lib.ServiceState // note: these states mean a slightly different: RUN means process is running, INIT means nothing
-	m      lib.ModuleSelfService
-	mutex  *sync.Mutex
+	id       string // ID must be unique
+	module   string // name doesn't need to be unique; we can run multiple configs of the same service
+	exe      string // gets set automatically
+	entry    func() // needs to run as a goroutine
+	sock     string
+	cgroup   string // empty runs the process in our own cgroup
+	cmd      *exec.Cmd
+	ctl      chan<- lib.ServiceControl
+	ctlMutex *sync.Mutex // protects ctl, and is held while we send on it
+	wchan    chan<- lib.ServiceInstanceUpdate
+	state    lib.ServiceState // note: these states mean a slightly different: RUN means process is running, INIT means nothing
+	m        lib.ModuleSelfService
+	mutex    *sync.Mutex
 }
 
 // NewServiceInstance provides a new, initialized ServiceInstance object
 func NewServiceInstance(id, module string, entry func()) *ServiceInstance {
 	si := &ServiceInstance{
-		id:     id,
-		module: module,
-		entry:  entry,
-		cmd:    nil,
-		mutex:  &sync.Mutex{},
+		id:       id,
+		module:   module,
+		entry:    entry,
+		cmd:      nil,
+		ctlMutex: &sync.Mutex{},
+		mutex:    &sync.Mutex{},
 	}
 	si.setState((lib.Service_STOP)) // we're obviously stopped right now
 	si.exe, _ = os.Executable()
@@ -71,16 +76,14 @@ func (si *ServiceInstance) GetState() lib.ServiceState {
 
 // UpdateConfig will send a signal to the running si to check for a config update
 func (si *ServiceInstance) UpdateConfig() {
-	if si.ctl != nil {
-		si.ctl <- lib.ServiceControl{Command: lib.ServiceControl_UPDATE}
-	}
+	si.sendCtl(lib.ServiceControl{Command: lib.ServiceControl_UPDATE})
 }
 
 // Start will execute the process
 func (si *ServiceInstance) Start() {
 	e := si.start()
 	if e != nil {
-		si.setState(lib.Service_ERROR)
+		si.setStateError(lib.Service_ERROR, e)
 		return
 	}
 	si.setState(lib.Service_RUN)
@@ -89,8 +92,15 @@ func (si *ServiceInstance) Start() {
 
 // Stop sends a signal to the running si to stop
 func (si *ServiceInstance) Stop() {
-	if si.ctl != nil {
-		si.ctl <- lib.ServiceControl{Command: lib.ServiceControl_STOP}
+	si.sendCtl(lib.ServiceControl{Command: lib.ServiceControl_STOP})
+}
+
+// Kill kills the process, for when it won't stop by being asked to
+func (si *ServiceInstance) Kill() {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+	if si.state == lib.Service_RUN && si.cmd != nil && si.cmd.Process != nil {
+		si.cmd.Process.Kill()
 	}
 }
 
@@ -101,16 +111,48 @@ func (si *ServiceInstance) Watch(wchan chan<- lib.ServiceInstanceUpdate) {
 
 // SetCtl sets the channel to send control message to (to pass through the API)
 func (si *ServiceInstance) SetCtl(ctl chan<- lib.ServiceControl) {
+	si.ctlMutex.Lock()
+	defer si.ctlMutex.Unlock()
 	si.ctl = ctl
 }
 
+// ClearCtl unsets the control channel, if it's still ctl.  Whoever reads ctl must keep reading until ClearCtl returns.
+func (si *ServiceInstance) ClearCtl(ctl chan<- lib.ServiceControl) {
+	si.ctlMutex.Lock()
+	defer si.ctlMutex.Unlock()
+	if si.ctl == ctl {
+		si.ctl = nil
+	}
+}
+
 // SetSock sets the path to the API socket
 func (si *ServiceInstance) SetSock(sock string) {
 	si.sock = sock
 }
 
+// SetCgroup sets the cgroup the process will be run in, the next time it's started
+func (si *ServiceInstance) SetCgroup(cgroup string) {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+	si.cgroup = cgroup
+}
+
+// sendCtl sends a control message to the running si, if it's connected
+func (si *ServiceInstance) sendCtl(ctl lib.ServiceControl) {
+	si.ctlMutex.Lock()
+	defer si.ctlMutex.Unlock()
+	if si.ctl != nil {
+		si.ctl <- ctl
+	}
+}
+
 // setState sets the state, but should only be done internally.  This makes sure we notify any watcher
 func (si *ServiceInstance) setState(state lib.ServiceState) {
+	si.setStateError(state, nil)
+}
+
+// setStateError is setState, with the error that put us in that state
+func (si *ServiceInstance) setStateError(state lib.ServiceState, e error) {
 	si.mutex.Lock()
 	defer si.mutex.Unlock()
 	si.state = state
@@ -118,6 +160,7 @@ func (si *ServiceInstance) setState(state lib.ServiceState) {
 		si.wchan <- lib.ServiceInstanceUpdate{
 			ID:    si.id,
 			State: si.state,
+			Error: e,
 		}
 	}
 }
@@ -125,7 +168,7 @@ func (si *ServiceInstance) setState(state lib.ServiceState) {
 func (si *ServiceInstance) watcher() {
 	e := si.cmd.Wait()
 	if e != nil {
-		si.setState(lib.Service_ERROR)
+		si.setStateError(lib.Service_ERROR, e)
 		return
 	}
 	si.setState(lib.Service_STOP)
@@ -150,8 +193,16 @@ func (si *ServiceInstance) start() (e error) {
 		"KRAKEN_SOCK="+si.sock,
 		"KRAKEN_MODULE="+si.module,
 		"KRAKEN_ID="+si.ID())
-	e = si.cmd.Start()
-	return
+	if si.cgroup != "" {
+		// the process starts in its cgroup, so it never runs without its limits
+		var cg *os.File
+		if cg, e = os.Open(si.cgroup); e != nil {
+			return fmt.Errorf("couldn't open cgroup %s: %v", si.cgroup, e)
+		}
+		defer cg.Close()
+		si.cmd.SysProcAttr = &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: int(cg.Fd())}
+	}
+	return si.cmd.Start()
 }
 
 // moduleExecute does all of the necessary steps to start the service instance
@@ -162,6 +213,11 @@ func ModuleExecute(id, module, sock string) {
 		fmt.Printf("trying to launch non-existent module: %s", module)
 		return
 	}
+	moduleExecute(m, id, module, sock, nil)
+}
+
+// moduleExecute runs module m as service instance id; plugins also give their registration
+func moduleExecute(m lib.Module, id, module, sock string, reg *pb.PluginRegistration) {
 	mss, ok := m.(lib.ModuleSelfService)
 	if !ok {
 		fmt.Printf("module is not executable: %s", module)
@@ -174,9 +230,20 @@ func ModuleExecute(id, module, sock string) {
 	}
 
 	api := NewAPIClient(sock)
+	// the tracer logs through the API, once LoggerInit below sets that up
+	tracer, e := NewTracerFromEnv(id, api)
+	if e != nil {
+		fmt.Printf("failed to setup tracing, continuing without it: %v\n", e)
+	}
+	api.SetTracer(tracer)
 	mss.Init(api)
 	// call in, and get a control chan
-	cc, e := api.ServiceInit(id, module)
+	var cc <-chan lib.ServiceControl
+	if reg != nil {
+		cc, e = api.PluginInit(id, module, reg)
+	} else {
+		cc, e = api.ServiceInit(id, module)
+	}
 	if e != nil {
 		fmt.Printf("sock: %v\nid: %v\nmodule: %v\nerror: %v\n", os.Getenv("KRAKEN_SOCK"), os.Getenv("KRAKEN_ID"), os.Getenv("KRAKEN_MODULE"), e)
 		return
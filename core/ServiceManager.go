@@ -10,42 +10,96 @@
 package core
 
 import (
+	"errors"
+	"fmt"
+	"os/exec"
 	"reflect"
 	"regexp"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/duration"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	pb "github.com/hpc/kraken/core/proto"
 	"github.com/hpc/kraken/lib"
 )
 
+// ServiceStopTimeout is how long a restart waits for a service to stop before giving up
+var ServiceStopTimeout = 30 * time.Second
+
+// DefaultRestartPolicy applies to services that don't have a restart policy in their cfg, so they aren't restarted.
+// Anything a service's policy leaves unset, including its mode, is taken from it, too.
+var DefaultRestartPolicy = &pb.RestartPolicy{
+	Mode:        pb.RestartPolicy_NEVER,
+	MaxRestarts: 5,
+	Backoff:     ptypes.DurationProto(time.Second),
+	MaxBackoff:  ptypes.DurationProto(time.Minute),
+	ResetAfter:  ptypes.DurationProto(10 * time.Minute),
+}
+
 ///////////////////////////
 // ServiceManager Object /
 /////////////////////////
 
+// HeartbeatMisses is how many heartbeats in a row a service may miss before it's considered hung
+var HeartbeatMisses = 3
+
 var _ lib.ServiceManager = (*ServiceManager)(nil)
 
+// heartbeat tracks the liveness of a service that sends heartbeats
+type heartbeat struct {
+	last    time.Time     // when we last heard from it
+	timeout time.Duration // how long we wait to hear from it again
+	timer   *time.Timer
+}
+
 type ServiceManager struct {
 	srv    map[string]lib.ServiceInstance // map of si IDs to ServiceInstances
 	mutex  *sync.Mutex
-	sock   string // socket that we use for API comms
+	smutex *sync.Mutex // serializes syncService, so we never start a service twice
+	sock   string      // socket that we use for API comms
+	cgroup string      // cgroup v2 directory to run services in; empty for no resource limits
 	sclist lib.EventListener
 	echan  chan lib.Event
 	wchan  chan lib.ServiceInstanceUpdate
 	ctx    Context
 	query  *QueryEngine
 	log    lib.Logger
+	// these are protected by mutex
+	started  map[string]time.Time // when we last started each service
+	stopping map[string]bool      // services we stopped to restart them; their exit isn't a failure
+	// services that send heartbeats, and those we killed for not sending them (and why)
+	heartbeats map[string]*heartbeat
+	hung       map[string]string
+	plugins    map[string][]string // plugin services, and the IDs of the mutations they registered
+	shutdown   bool                // we're shutting down; nothing gets started or restarted
 }
 
 func NewServiceManager(ctx Context, sock string) *ServiceManager {
 	sm := &ServiceManager{
-		srv:   make(map[string]lib.ServiceInstance),
-		mutex: &sync.Mutex{},
-		sock:  sock,
-		echan: make(chan lib.Event),
-		wchan: make(chan lib.ServiceInstanceUpdate),
-		ctx:   ctx,
-		log:   &ctx.Logger,
-		query: &ctx.Query,
+		srv:    make(map[string]lib.ServiceInstance),
+		mutex:  &sync.Mutex{},
+		smutex: &sync.Mutex{},
+		sock:   sock,
+		cgroup: ctx.SM.CgroupRoot,
+		echan:  make(chan lib.Event),
+		wchan:  make(chan lib.ServiceInstanceUpdate),
+		ctx:    ctx,
+		log:    &ctx.Logger,
+		query:  &ctx.Query,
+
+		started:  make(map[string]time.Time),
+		stopping: make(map[string]bool),
+
+		heartbeats: make(map[string]*heartbeat),
+		hung:       make(map[string]string),
+		plugins:    make(map[string][]string),
 	}
 	sm.log.SetModule("ServiceManager")
 	return sm
@@ -71,6 +125,15 @@ func (sm *ServiceManager) Run(ready chan<- interface{}) {
 	)
 	sm.ctx.SubChan <- sm.sclist
 
+	if sm.cgroup != "" {
+		if e := enableCgroupControllers(sm.cgroup); e != nil {
+			sm.log.Logf(lib.LLERROR, "can't run services in cgroups, so they will run without resource limits: %v", e)
+			sm.cgroup = ""
+		} else {
+			sm.log.Logf(lib.LLINFO, "running services in cgroups under: %s", sm.cgroup)
+		}
+	}
+
 	// initialize service instances
 	for m := range Registry.ServiceInstances {
 		for _, si := range Registry.ServiceInstances[m] {
@@ -79,6 +142,10 @@ func (sm *ServiceManager) Run(ready chan<- interface{}) {
 		}
 	}
 
+	if sm.ctx.SM.PluginDir != "" {
+		sm.addPlugins(sm.ctx.SM.PluginDir)
+	}
+
 	go func() {
 		sm.log.Logf(lib.LLDEBUG, "starting initial service sync")
 		for _, si := range sm.srv {
@@ -135,6 +202,319 @@ func (sm *ServiceManager) GetService(si string) lib.ServiceInstance {
 	return nil
 }
 
+// Services lists the IDs of the services we manage
+func (sm *ServiceManager) Services() (ids []string) {
+	sm.mutex.Lock()
+	for id := range sm.srv {
+		ids = append(ids, id)
+	}
+	sm.mutex.Unlock()
+	sort.Strings(ids)
+	return
+}
+
+// Status reports the cfg and dsc state of a service, its error, and its config
+func (sm *ServiceManager) Status(si string) (*pb.ServiceStatus, error) {
+	srv := sm.GetService(si)
+	if srv == nil {
+		return nil, status.Errorf(codes.NotFound, "no such service: %s", si)
+	}
+	st := &pb.ServiceStatus{
+		Id:     si,
+		Module: srv.Module(),
+		Cfg:    sm.getServiceStateCfg(si),
+		Dsc:    sm.getServiceStateDsc(si),
+	}
+	if n, e := sm.query.Read(sm.ctx.Self); e == nil && n.HasService(si) {
+		if c := n.GetService(si).GetConfig(); c != nil {
+			st.Config = proto.Clone(c).(*any.Any)
+		}
+	}
+	if n, e := sm.query.ReadDsc(sm.ctx.Self); e == nil && n.HasService(si) {
+		st.ErrorMsg = n.GetService(si).GetErrorMsg()
+	}
+	sm.mutex.Lock()
+	if hb, ok := sm.heartbeats[si]; ok {
+		st.LastHeartbeat, _ = ptypes.TimestampProto(hb.last)
+	}
+	sm.mutex.Unlock()
+	return st, nil
+}
+
+// StartService sets a service to run.  A service that stopped in ERROR is reset, so it can be started again.
+func (sm *ServiceManager) StartService(si string) error {
+	if sm.isShutdown() {
+		return errShutdown
+	}
+	srv := sm.GetService(si)
+	if srv == nil {
+		return status.Errorf(codes.NotFound, "no such service: %s", si)
+	}
+	if srv.GetState() != lib.Service_RUN {
+		// starting by hand ends any crash loop
+		sm.setServiceValueDsc(si, "Restarts", uint32(0))
+		if sm.getServiceStateDsc(si) == pb.ServiceInstance_ERROR {
+			sm.clearServiceError(si)
+		}
+	}
+	if e := sm.setServiceStateCfg(si, pb.ServiceInstance_RUN); e != nil {
+		return e
+	}
+	// if cfg was already RUN, nothing changed to make us sync
+	sm.syncService(si)
+	return nil
+}
+
+// StopService sets a service to stop.  A service in ERROR is stopped too, if it's still running, and reset if it isn't.
+func (sm *ServiceManager) StopService(si string) error {
+	if sm.isShutdown() {
+		return errShutdown
+	}
+	srv := sm.GetService(si)
+	if srv == nil {
+		return status.Errorf(codes.NotFound, "no such service: %s", si)
+	}
+	if e := sm.setServiceStateCfg(si, pb.ServiceInstance_STOP); e != nil {
+		return e
+	}
+	if sm.getServiceStateDsc(si) == pb.ServiceInstance_ERROR {
+		// syncService leaves services in ERROR alone
+		if srv.GetState() == lib.Service_RUN {
+			srv.Stop()
+		} else {
+			sm.clearServiceError(si)
+		}
+		return nil
+	}
+	sm.syncService(si)
+	return nil
+}
+
+// RestartService stops a running service and starts it again once it has stopped; a service that isn't running is just started.
+// It returns once the service has been told to stop.
+func (sm *ServiceManager) RestartService(si string) error {
+	if sm.isShutdown() {
+		return errShutdown
+	}
+	srv := sm.GetService(si)
+	if srv == nil {
+		return status.Errorf(codes.NotFound, "no such service: %s", si)
+	}
+	if sm.getServiceStateCfg(si) != pb.ServiceInstance_RUN || srv.GetState() != lib.Service_RUN {
+		return sm.StartService(si)
+	}
+	sm.log.Logf(lib.LLINFO, "restarting service: %s", si)
+	sm.mutex.Lock()
+	sm.stopping[si] = true
+	sm.mutex.Unlock()
+	srv.Stop()
+	go func() {
+		// the process has to exit, and dsc has to catch up, or syncService would think it's still running
+		deadline := time.Now().Add(ServiceStopTimeout)
+		for srv.GetState() == lib.Service_RUN || sm.getServiceStateDsc(si) == pb.ServiceInstance_INIT || sm.getServiceStateDsc(si) == pb.ServiceInstance_RUN {
+			if time.Now().After(deadline) {
+				sm.log.Logf(lib.LLERROR, "service didn't stop within %v, not restarting it: %s", ServiceStopTimeout, si)
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if e := sm.StartService(si); e != nil {
+			sm.log.Logf(lib.LLERROR, "failed to restart service %s: %v", si, e)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops all of the running services, and keeps them from being started again.
+// Services are stopped in dependency order: a service is stopped before any service it depends on,
+// and services that don't depend on each other are stopped together.  Each of these has up to timeout
+// to stop before it's killed.
+func (sm *ServiceManager) Shutdown(timeout time.Duration) {
+	sm.mutex.Lock()
+	sm.shutdown = true
+	sm.mutex.Unlock()
+	deps := sm.ctx.sme.ServiceDepends()
+	left := map[string]bool{}
+	for _, si := range sm.Services() {
+		if sm.GetService(si).GetState() == lib.Service_RUN {
+			left[si] = true
+		}
+	}
+	for len(left) > 0 {
+		// the next to stop are the ones nothing left running depends on
+		next := []string{}
+		for si := range left {
+			needed := false
+			for o := range left {
+				for _, d := range deps[o] {
+					if d == si {
+						needed = true
+					}
+				}
+			}
+			if !needed {
+				next = append(next, si)
+			}
+		}
+		if len(next) == 0 {
+			sm.log.Logf(lib.LLWARNING, "services depend on each other, stopping them together")
+			for si := range left {
+				next = append(next, si)
+			}
+		}
+		sort.Strings(next)
+		sm.stopServices(next, timeout)
+		for _, si := range next {
+			delete(left, si)
+		}
+	}
+	sm.log.Logf(lib.LLINFO, "all services are stopped")
+}
+
+// ConfigureService sets a new config for a service, and tells it to update if it's running.
+// The config must be of the type the service's module gives with NewConfig.
+func (sm *ServiceManager) ConfigureService(si string, cfg proto.Message) error {
+	srv := sm.GetService(si)
+	if srv == nil {
+		return status.Errorf(codes.NotFound, "no such service: %s", si)
+	}
+	mc, ok := Registry.Module(srv.Module()).(lib.ModuleWithConfig)
+	if !ok {
+		return status.Errorf(codes.FailedPrecondition, "module %s doesn't take a config", srv.Module())
+	}
+	if want, got := proto.MessageName(mc.NewConfig()), proto.MessageName(cfg); want != got {
+		return status.Errorf(codes.InvalidArgument, "module %s takes a %s config, not %s", srv.Module(), want, got)
+	}
+	if e := validateConfig(si, srv.Module(), cfg); e != nil {
+		return e
+	}
+	a, e := ptypes.MarshalAny(cfg)
+	if e != nil {
+		return status.Errorf(codes.InvalidArgument, "bad config: %v", e)
+	}
+	if _, e = sm.query.SetValue(lib.NodeURLJoin(sm.ctx.Self.String(), lib.URLPush(lib.URLPush("/Services", si), "Config")), reflect.ValueOf(a)); e != nil {
+		return e
+	}
+	if srv.GetState() == lib.Service_RUN {
+		srv.UpdateConfig()
+	}
+	return nil
+}
+
+// validateConfig checks a config for service si with its module's ValidateConfig, if it has one
+func validateConfig(si, module string, cfg proto.Message) error {
+	mv, ok := Registry.Module(module).(lib.ModuleWithConfigValidation)
+	if !ok {
+		return nil
+	}
+	if e := mv.ValidateConfig(cfg); e != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid config for service %s: %v", si, e)
+	}
+	return nil
+}
+
+// Heartbeat records that a running service is alive.  Services don't have to send heartbeats,
+// but once one has, it must send the next within HeartbeatMisses intervals, or it's considered hung:
+// it's killed, and put in ERROR (from which its restart policy may restart it).
+func (sm *ServiceManager) Heartbeat(si string, interval time.Duration) error {
+	srv := sm.GetService(si)
+	if srv == nil {
+		return status.Errorf(codes.NotFound, "no such service: %s", si)
+	}
+	if interval <= 0 {
+		return status.Errorf(codes.InvalidArgument, "heartbeat interval must be positive, not %v", interval)
+	}
+	if srv.GetState() != lib.Service_RUN {
+		return status.Errorf(codes.FailedPrecondition, "service isn't running: %s", si)
+	}
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	hb, ok := sm.heartbeats[si]
+	if !ok {
+		hb = &heartbeat{}
+		sm.heartbeats[si] = hb
+	}
+	hb.last = time.Now()
+	hb.timeout = interval * time.Duration(HeartbeatMisses)
+	if hb.timer == nil {
+		hb.timer = time.AfterFunc(hb.timeout, func() { sm.heartbeatMissed(si, hb) })
+	} else {
+		hb.timer.Reset(hb.timeout)
+	}
+	return nil
+}
+
+// RegisterPlugin registers what a plugin service provides, as it calls in: its config type, mutations and discoverables.
+// A plugin that calls in again (e.g. because it was restarted) replaces what it registered before.
+func (sm *ServiceManager) RegisterPlugin(si string, reg *pb.PluginRegistration) error {
+	sm.mutex.Lock()
+	old, ok := sm.plugins[si]
+	sm.mutex.Unlock()
+	if !ok {
+		return status.Errorf(codes.FailedPrecondition, "service is not a plugin: %s", si)
+	}
+	muts, e := Registry.RegisterPlugin(si, reg)
+	if e != nil {
+		return status.Errorf(codes.InvalidArgument, "bad plugin registration: %v", e)
+	}
+	for _, id := range old {
+		sm.ctx.sme.UnregisterMutation(si, id)
+	}
+	ids := []string{}
+	for _, id := range sortedKeys(muts) {
+		if e = sm.ctx.sme.RegisterMutation(si, id, muts[id]); e != nil {
+			sm.log.Logf(lib.LLERROR, "plugin %s: couldn't register mutation %s: %v", si, id, e)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sm.mutex.Lock()
+	sm.plugins[si] = ids
+	sm.mutex.Unlock()
+
+	// start it off with an empty config of its type, as built-in services are
+	if mc, ok := Registry.Module(si).(lib.ModuleWithConfig); ok {
+		if n, e := sm.query.Read(sm.ctx.Self); e == nil && n.GetService(si).GetConfig().GetTypeUrl() != mc.ConfigURL() {
+			a, _ := ptypes.MarshalAny(mc.NewConfig())
+			if _, e = sm.query.SetValue(lib.NodeURLJoin(sm.ctx.Self.String(), lib.URLPush(lib.URLPush("/Services", si), "Config")), reflect.ValueOf(a)); e != nil {
+				sm.log.Logf(lib.LLERROR, "plugin %s: couldn't set its config: %v", si, e)
+			}
+		}
+	}
+	sm.log.Logf(lib.LLINFO, "registered plugin %s: %d mutations, %d discoverable URLs", si, len(ids), len(reg.GetDiscoverables()))
+	return nil
+}
+
+// Metrics reports the cfg and dsc state of each service
+func (sm *ServiceManager) Metrics() (fams []MetricFamily) {
+	sm.mutex.Lock()
+	ids := make([]string, 0, len(sm.srv))
+	mods := make(map[string]string, len(sm.srv))
+	for id, si := range sm.srv {
+		ids = append(ids, id)
+		mods[id] = si.Module()
+	}
+	sm.mutex.Unlock()
+	sort.Strings(ids)
+	f := MetricFamily{
+		Name: "kraken_service_state",
+		Help: "Service instance state; 1 for the current state, by service and whether it's the configured (cfg) or discovered (dsc) state.",
+		Type: MetricGauge,
+	}
+	r := MetricFamily{
+		Name: "kraken_service_restarts",
+		Help: "Automatic restarts of a service in a row, since it last ran long enough or was started by hand.",
+		Type: MetricGauge,
+	}
+	for _, id := range ids {
+		labels := []MetricLabel{{"service", id}, {"module", mods[id]}}
+		f.Metrics = append(f.Metrics, metricEnum(pb.ServiceInstance_ServiceState_name, int32(sm.getServiceStateCfg(id)), append(labels, MetricLabel{"which", "cfg"})...)...)
+		f.Metrics = append(f.Metrics, metricEnum(pb.ServiceInstance_ServiceState_name, int32(sm.getServiceStateDsc(id)), append(labels, MetricLabel{"which", "dsc"})...)...)
+		r.Metrics = append(r.Metrics, Metric{Labels: labels, Value: float64(sm.getServiceRestartsDsc(id))})
+	}
+	return append(fams, f, r)
+}
+
 func (sm *ServiceManager) processStateChange(v *StateChangeEvent) {
 	// extract SI
 	_, url := lib.NodeURLSplit(v.URL)
@@ -150,10 +530,26 @@ func (sm *ServiceManager) processStateChange(v *StateChangeEvent) {
 		sm.log.Logf(lib.LLDEBUG, "failed to parse URL for /Services state change: %s", v.URL)
 		return
 	}
+	sm.syncResources(si)
 	sm.syncService(si)
 }
 
 func (sm *ServiceManager) processUpdate(su lib.ServiceInstanceUpdate) {
+	if su.State != lib.Service_RUN {
+		sm.mutex.Lock()
+		sm.stopHeartbeat(su.ID)
+		msg, hung := sm.hung[su.ID]
+		delete(sm.hung, su.ID)
+		sm.mutex.Unlock()
+		if hung { // we killed it, so whatever it says, it failed
+			su.State = lib.Service_ERROR
+			if su.Error != nil {
+				su.Error = fmt.Errorf("%s (%w)", msg, su.Error)
+			} else {
+				su.Error = errors.New(msg)
+			}
+		}
+	}
 	// set the state in the SDE
 	switch su.State {
 	case lib.Service_STOP:
@@ -162,12 +558,287 @@ func (sm *ServiceManager) processUpdate(su lib.ServiceInstanceUpdate) {
 		// this is actually pb state INIT; it's up to
 		sm.setServiceStateDsc(su.ID, pb.ServiceInstance_INIT)
 	case lib.Service_ERROR:
+		if su.Error != nil {
+			sm.setServiceValueDsc(su.ID, "ErrorMsg", su.Error.Error())
+		}
 		sm.setServiceStateDsc(su.ID, pb.ServiceInstance_ERROR)
 	}
+	if su.State == lib.Service_STOP || su.State == lib.Service_ERROR {
+		sm.processExit(su)
+	}
+}
+
+// processExit records how a service's process exited (or failed to start), and restarts it if its restart policy says to
+func (sm *ServiceManager) processExit(su lib.ServiceInstanceUpdate) {
+	var ee *exec.ExitError
+	if su.Error == nil || errors.As(su.Error, &ee) {
+		status := int32(0)
+		if ee != nil {
+			status = int32(ee.ExitCode())
+		}
+		sm.setServiceValueDsc(su.ID, "ExitStatus", status)
+		sm.setServiceValueDsc(su.ID, "LastExit", ptypes.TimestampNow())
+	}
+
+	sm.mutex.Lock()
+	stopping := sm.stopping[su.ID]
+	delete(sm.stopping, su.ID)
+	started := sm.started[su.ID]
+	sm.mutex.Unlock()
+	if stopping || sm.getServiceStateCfg(su.ID) != pb.ServiceInstance_RUN {
+		// we meant for it to stop
+		return
+	}
+
+	p := sm.restartPolicy(su.ID)
+	switch p.GetMode() {
+	case pb.RestartPolicy_NEVER:
+		return
+	case pb.RestartPolicy_ON_FAILURE:
+		if su.State != lib.Service_ERROR {
+			return
+		}
+	}
+	restarts := sm.getServiceRestartsDsc(su.ID)
+	if !started.IsZero() && time.Since(started) >= policyDuration(p.GetResetAfter()) {
+		// it ran long enough that this isn't a crash loop
+		restarts = 0
+	}
+	if p.GetMaxRestarts() > 0 && restarts >= p.GetMaxRestarts() {
+		msg := fmt.Sprintf("crash loop: exited %d times in a row, not restarting", restarts+1)
+		if su.Error != nil {
+			msg += ": " + su.Error.Error()
+		}
+		sm.log.Logf(lib.LLERROR, "service %s: %s", su.ID, msg)
+		sm.setServiceValueDsc(su.ID, "ErrorMsg", msg)
+		sm.setServiceStateDsc(su.ID, pb.ServiceInstance_ERROR)
+		return
+	}
+	delay := policyDuration(p.GetBackoff())
+	max := policyDuration(p.GetMaxBackoff())
+	for i := uint32(0); i < restarts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	sm.setServiceValueDsc(su.ID, "Restarts", restarts+1)
+	sm.log.Logf(lib.LLNOTICE, "service %s exited, restarting it in %v (restart %d in a row)", su.ID, delay, restarts+1)
+	time.AfterFunc(delay, func() { sm.autoRestart(su.ID) })
+}
+
+// stopServices stops services and waits up to timeout for them to exit, then kills the ones that haven't
+func (sm *ServiceManager) stopServices(sis []string, timeout time.Duration) {
+	srvs := map[string]lib.ServiceInstance{}
+	for _, si := range sis {
+		srv := sm.GetService(si)
+		if srv == nil || srv.GetState() != lib.Service_RUN {
+			continue
+		}
+		sm.log.Logf(lib.LLINFO, "stopping service: %s", si)
+		sm.mutex.Lock()
+		sm.stopping[si] = true
+		sm.mutex.Unlock()
+		srv.Stop()
+		srvs[si] = srv
+	}
+	deadline := time.Now().Add(timeout)
+	for len(srvs) > 0 {
+		for si, srv := range srvs {
+			if srv.GetState() != lib.Service_RUN {
+				delete(srvs, si)
+			}
+		}
+		if len(srvs) > 0 && time.Now().After(deadline) {
+			for si, srv := range srvs {
+				sm.log.Logf(lib.LLERROR, "service didn't stop within %v, killing it: %s", timeout, si)
+				srv.Kill()
+			}
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// heartbeatMissed kills a service that stopped sending heartbeats
+func (sm *ServiceManager) heartbeatMissed(si string, hb *heartbeat) {
+	sm.mutex.Lock()
+	if sm.heartbeats[si] != hb || time.Since(hb.last) < hb.timeout {
+		// it exited, or a heartbeat raced us
+		sm.mutex.Unlock()
+		return
+	}
+	delete(sm.heartbeats, si)
+	msg := fmt.Sprintf("hung: no heartbeat for %v", time.Since(hb.last).Round(time.Millisecond))
+	sm.hung[si] = msg
+	sm.mutex.Unlock()
+	srv := sm.GetService(si)
+	if srv == nil {
+		return
+	}
+	sm.log.Logf(lib.LLERROR, "service %s is %s, killing it", si, msg)
+	srv.Kill()
+}
+
+// stopHeartbeat stops tracking heartbeats from a service; mutex must be held
+func (sm *ServiceManager) stopHeartbeat(si string) {
+	if hb, ok := sm.heartbeats[si]; ok {
+		hb.timer.Stop()
+		delete(sm.heartbeats, si)
+	}
+}
+
+// addPlugins adds a service for each plugin executable in dir, and to our cfg and dsc
+func (sm *ServiceManager) addPlugins(dir string) {
+	ps, e := FindPlugins(dir)
+	if e != nil {
+		sm.log.Logf(lib.LLERROR, "couldn't read plugin directory: %v", e)
+		return
+	}
+	for id, exe := range ps {
+		if sm.GetService(id) != nil || Registry.Modules[id] != nil {
+			sm.log.Logf(lib.LLERROR, "plugin %s has the name of a built-in module or service, skipping it", exe)
+			continue
+		}
+		sm.log.Logf(lib.LLINFO, "adding plugin service: %s (%s)", id, exe)
+		sm.mutex.Lock()
+		sm.plugins[id] = nil
+		sm.mutex.Unlock()
+		sm.AddService(NewPluginInstance(id, exe))
+		if n, e := sm.query.Read(sm.ctx.Self); e == nil && !n.HasService(id) {
+			n.AddService(&pb.ServiceInstance{Id: id, Module: id})
+			sm.query.Update(n)
+		}
+		if n, e := sm.query.ReadDsc(sm.ctx.Self); e == nil && !n.HasService(id) {
+			n.AddService(&pb.ServiceInstance{Id: id, Module: id})
+			sm.query.UpdateDsc(n)
+		}
+	}
+}
+
+// setupCgroup sets up the cgroup a service will be started in, with its resource policy
+func (sm *ServiceManager) setupCgroup(si string, srv lib.ServiceInstance) error {
+	p := sm.resourcePolicy(si)
+	if sm.cgroup == "" {
+		if !proto.Equal(p, &pb.ResourcePolicy{}) {
+			sm.log.Logf(lib.LLWARNING, "service %s has a resource policy, but services aren't run in cgroups, so it won't be applied", si)
+		}
+		return nil
+	}
+	dir, e := applyCgroup(sm.cgroup, si, p)
+	if e != nil {
+		return e
+	}
+	srv.SetCgroup(dir)
+	sm.setServiceValueDsc(si, "Cgroup", dir)
+	sm.setServiceValueDsc(si, "Resources", p)
+	return nil
+}
+
+// syncResources applies a changed resource policy to a running service
+func (sm *ServiceManager) syncResources(si string) {
+	srv := sm.GetService(si)
+	if sm.cgroup == "" || srv == nil || srv.GetState() != lib.Service_RUN {
+		return
+	}
+	n, e := sm.query.ReadDsc(sm.ctx.Self)
+	if e != nil || !n.HasService(si) {
+		return
+	}
+	cur := n.GetService(si).GetResources()
+	if cur == nil {
+		cur = &pb.ResourcePolicy{}
+	}
+	p := sm.resourcePolicy(si)
+	if proto.Equal(p, cur) {
+		return
+	}
+	if _, e = applyCgroup(sm.cgroup, si, p); e != nil {
+		sm.log.Logf(lib.LLERROR, "couldn't apply the new resource policy of service %s: %v", si, e)
+		sm.setServiceValueDsc(si, "ErrorMsg", "resource policy: "+e.Error())
+		return
+	}
+	sm.log.Logf(lib.LLINFO, "applied the new resource policy of service %s", si)
+	sm.setServiceValueDsc(si, "Resources", p)
+}
+
+// resourcePolicy is a copy of the resource policy of a service in cfg; an empty policy is no limits
+func (sm *ServiceManager) resourcePolicy(si string) *pb.ResourcePolicy {
+	n, e := sm.query.Read(sm.ctx.Self)
+	if e == nil && n.HasService(si) {
+		if p := n.GetService(si).GetResources(); p != nil {
+			return proto.Clone(p).(*pb.ResourcePolicy)
+		}
+	}
+	return &pb.ResourcePolicy{}
+}
+
+// errShutdown is what service control calls get once we've shut down
+var errShutdown = status.Errorf(codes.FailedPrecondition, "kraken is shutting down")
+
+func (sm *ServiceManager) isShutdown() bool {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	return sm.shutdown
+}
+
+// autoRestart restarts a service after a backoff, unless someone started or stopped it in the meantime
+func (sm *ServiceManager) autoRestart(si string) {
+	if sm.isShutdown() {
+		return
+	}
+	srv := sm.GetService(si)
+	if srv == nil || srv.GetState() == lib.Service_RUN || sm.getServiceStateCfg(si) != pb.ServiceInstance_RUN {
+		return
+	}
+	// syncService doesn't start services in ERROR
+	sm.setServiceStateDsc(si, pb.ServiceInstance_STOP)
+	sm.syncService(si)
+}
+
+// restartPolicy is the restart policy of a service, with anything it leaves unset taken from DefaultRestartPolicy
+func (sm *ServiceManager) restartPolicy(si string) *pb.RestartPolicy {
+	var p *pb.RestartPolicy
+	if n, e := sm.query.Read(sm.ctx.Self); e == nil && n.HasService(si) {
+		p = n.GetService(si).GetRestartPolicy()
+	}
+	return mergeRestartPolicy(p, DefaultRestartPolicy)
+}
+
+// mergeRestartPolicy is p, with the fields it leaves unset (zero) taken from def
+func mergeRestartPolicy(p, def *pb.RestartPolicy) *pb.RestartPolicy {
+	r := proto.Clone(def).(*pb.RestartPolicy)
+	if p.GetMode() != pb.RestartPolicy_NEVER { // an unset mode is NEVER, too
+		r.Mode = p.GetMode()
+	}
+	if p.GetMaxRestarts() != 0 {
+		r.MaxRestarts = p.GetMaxRestarts()
+	}
+	if policyDuration(p.GetBackoff()) > 0 {
+		r.Backoff = p.GetBackoff()
+	}
+	if policyDuration(p.GetMaxBackoff()) > 0 {
+		r.MaxBackoff = p.GetMaxBackoff()
+	}
+	if policyDuration(p.GetResetAfter()) > 0 {
+		r.ResetAfter = p.GetResetAfter()
+	}
+	return r
+}
+
+// policyDuration is d, or 0 if it's unset or invalid
+func policyDuration(d *duration.Duration) time.Duration {
+	t, e := ptypes.Duration(d)
+	if e != nil {
+		return 0
+	}
+	return t
 }
 
 // syncService is what actually does most of the work.  It compares cfg to dsc and decides what to do
 func (sm *ServiceManager) syncService(si string) {
+	sm.smutex.Lock()
+	defer sm.smutex.Unlock()
 	sm.log.Logf(lib.LLDDEBUG, "syncing service: %s", si)
 	srv := sm.GetService(si)
 	if srv == nil {
@@ -186,8 +857,23 @@ func (sm *ServiceManager) syncService(si string) {
 	}
 	switch c {
 	case pb.ServiceInstance_RUN: // we're supposed to be running
-		if d != pb.ServiceInstance_INIT { // did we already try to start?
+		if d != pb.ServiceInstance_INIT && srv.GetState() != lib.Service_RUN { // did we already try to start?
+			if sm.isShutdown() {
+				sm.log.Logf(lib.LLDEBUG, "not starting service, we're shutting down: %s", si)
+				return
+			}
 			sm.log.Logf(lib.LLDDEBUG, "starting service: %s", si)
+			if e := sm.setupCgroup(si, srv); e != nil {
+				sm.log.Logf(lib.LLERROR, "can't start service %s: %v", si, e)
+				sm.setServiceValueDsc(si, "ErrorMsg", "resource policy: "+e.Error())
+				sm.setServiceStateDsc(si, pb.ServiceInstance_ERROR)
+				return
+			}
+			sm.mutex.Lock()
+			sm.started[si] = time.Now()
+			sm.stopHeartbeat(si)
+			delete(sm.hung, si)
+			sm.mutex.Unlock()
 			srv.Start() // startup
 		}
 	case pb.ServiceInstance_STOP: // we're supposed to be stopped
@@ -219,13 +905,38 @@ func (sm *ServiceManager) getServiceStateDsc(si string) pb.ServiceInstance_Servi
 }
 
 func (sm *ServiceManager) setServiceStateDsc(si string, state pb.ServiceInstance_ServiceState) {
+	if _, e := sm.query.SetValueDsc(lib.NodeURLJoin(sm.ctx.Self.String(), sm.stateURL(si)), reflect.ValueOf(state)); e != nil {
+		sm.log.Logf(lib.LLERROR, "failed to set dsc state value (%s): %s", sm.stateURL(si), e.Error())
+	}
+}
+
+func (sm *ServiceManager) setServiceStateCfg(si string, state pb.ServiceInstance_ServiceState) (e error) {
+	_, e = sm.query.SetValue(lib.NodeURLJoin(sm.ctx.Self.String(), sm.stateURL(si)), reflect.ValueOf(state))
+	return
+}
+
+func (sm *ServiceManager) getServiceRestartsDsc(si string) uint32 {
 	n, _ := sm.query.ReadDsc(sm.ctx.Self)
-	_, e := n.SetValue(sm.stateURL(si), reflect.ValueOf(state))
+	v, e := n.GetValue(lib.URLPush(lib.URLPush("/Services", si), "Restarts"))
 	if e != nil {
-		sm.log.Logf(lib.LLERROR, "failed to set dsc state value (%s): %s", sm.stateURL(si), e.Error())
-		return
+		return 0
 	}
-	sm.query.UpdateDsc(n)
+	return uint32(v.Uint())
+}
+
+// setServiceValueDsc sets a field of a service in dsc, e.g. ErrorMsg
+func (sm *ServiceManager) setServiceValueDsc(si, field string, v interface{}) {
+	url := lib.NodeURLJoin(sm.ctx.Self.String(), lib.URLPush(lib.URLPush("/Services", si), field))
+	if _, e := sm.query.SetValueDsc(url, reflect.ValueOf(v)); e != nil {
+		sm.log.Logf(lib.LLERROR, "failed to set dsc %s (%s): %s", field, si, e.Error())
+	}
+}
+
+// clearServiceError resets a service in ERROR to STOP
+func (sm *ServiceManager) clearServiceError(si string) {
+	surl := lib.NodeURLJoin(sm.ctx.Self.String(), lib.URLPush("/Services", si))
+	sm.query.SetValueDsc(lib.URLPush(surl, "ErrorMsg"), reflect.ValueOf(""))
+	sm.query.SetValueDsc(lib.URLPush(surl, "State"), reflect.ValueOf(pb.ServiceInstance_STOP))
 }
 
 func (sm *ServiceManager) stateURL(si string) string {
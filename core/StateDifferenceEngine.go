@@ -13,7 +13,10 @@ package core
 import (
 	"fmt"
 	"reflect"
+	"sync"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	pb "github.com/hpc/kraken/core/proto"
 	"github.com/hpc/kraken/lib"
 )
@@ -82,6 +85,10 @@ type StateDifferenceEngine struct {
 	cfg   *State
 	qc    chan lib.Query
 	schan chan<- lib.EventListener
+
+	quit     chan interface{}
+	stopped  chan interface{}
+	stopOnce sync.Once
 }
 
 // NewStateDifferenceEngine initializes a new StateDifferenceEngine object given a Context
@@ -92,6 +99,8 @@ func NewStateDifferenceEngine(me lib.Node, ctx Context, qc chan lib.Query) *Stat
 	n.qc = qc
 	n.em = NewEventEmitter(lib.Event_STATE_CHANGE)
 	n.schan = ctx.SubChan
+	n.quit = make(chan interface{})
+	n.stopped = make(chan interface{})
 	n.log = &ctx.Logger
 	n.log.SetModule("StateDifferenceEngine")
 	n.Create(me)
@@ -130,6 +139,18 @@ func (n *StateDifferenceEngine) Update(m lib.Node) (r lib.Node, e error) {
 	return n.updateByType(false, m)
 }
 
+// UpdateIf updates a node in Cfg, but only if it's still at version (see NodeVersion)
+func (n *StateDifferenceEngine) UpdateIf(m lib.Node, version string) (r lib.Node, e error) {
+	var cur lib.Node
+	if cur, e = n.cfg.Read(m.ID()); e != nil {
+		return
+	}
+	if NodeVersion(cur) != version {
+		return nil, ErrNodeVersion
+	}
+	return n.updateByType(false, m)
+}
+
 // UpdateDsc updates a node in Dsc
 func (n *StateDifferenceEngine) UpdateDsc(m lib.Node) (r lib.Node, e error) {
 	return n.updateByType(true, m)
@@ -162,7 +183,7 @@ func (n *StateDifferenceEngine) GetValueDsc(url string) (r reflect.Value, e erro
 func (n *StateDifferenceEngine) SetValue(url string, v reflect.Value) (r reflect.Value, e error) {
 	var cur reflect.Value
 	cur, e = n.cfg.GetValue(url)
-	if e == nil && cur.Interface() == v.Interface() { // nothing new to set
+	if e == nil && reflect.DeepEqual(cur.Interface(), v.Interface()) { // nothing new to set
 		n.Logf(DDEBUG, "SetValue called, but it's not a change: %s", url)
 		r = v
 		return
@@ -171,7 +192,7 @@ func (n *StateDifferenceEngine) SetValue(url string, v reflect.Value) (r reflect
 	if e != nil {
 		n.Logf(ERROR, "failed to set value (cfg): %v", e)
 	}
-	go n.EmitOne(NewStateChangeEvent(StateChange_CFG_UPDATE, url, r))
+	go n.EmitOne(NewStateChangeEvent(StateChange_CFG_UPDATE, url, copyValue(r)))
 	return
 }
 
@@ -179,7 +200,7 @@ func (n *StateDifferenceEngine) SetValue(url string, v reflect.Value) (r reflect
 func (n *StateDifferenceEngine) SetValueDsc(url string, v reflect.Value) (r reflect.Value, e error) {
 	var cur reflect.Value
 	cur, e = n.dsc.GetValue(url)
-	if e == nil && cur.Interface() == v.Interface() { // nothing new to set
+	if e == nil && reflect.DeepEqual(cur.Interface(), v.Interface()) { // nothing new to set
 		n.Logf(DDEBUG, "SetValueDsc called, but it's not a change: %s", url)
 		r = v
 		return
@@ -188,7 +209,7 @@ func (n *StateDifferenceEngine) SetValueDsc(url string, v reflect.Value) (r refl
 	if e != nil {
 		n.Logf(ERROR, "failed to set value (dsc): %v", e)
 	}
-	go n.EmitOne(NewStateChangeEvent(StateChange_UPDATE, url, r))
+	go n.EmitOne(NewStateChangeEvent(StateChange_UPDATE, url, copyValue(r)))
 	return
 }
 
@@ -304,7 +325,13 @@ func (n *StateDifferenceEngine) Run(ready chan<- interface{}) {
 		"SDEDiscovery",
 		lib.Event_DISCOVERY,
 		func(v lib.Event) bool { return true },
-		func(v lib.Event) error { return ChanSender(v, dchan) },
+		func(v lib.Event) error {
+			select {
+			case dchan <- v:
+			case <-n.quit:
+			}
+			return nil
+		},
 	)
 	// subscribe our discovery listener
 	n.schan <- list
@@ -335,6 +362,9 @@ func (n *StateDifferenceEngine) Run(ready chan<- interface{}) {
 				default:
 					e = fmt.Errorf("unknown state for Query_READ")
 				}
+				if e == nil {
+					v = v.(*Node).copy()
+				}
 				go n.sendQueryResponse(NewQueryResponse(
 					[]reflect.Value{reflect.ValueOf(v)}, e), q.ResponseChan())
 				break
@@ -343,6 +373,10 @@ func (n *StateDifferenceEngine) Run(ready chan<- interface{}) {
 				var e error
 				switch q.State() {
 				case lib.QueryState_CONFIG:
+					if len(q.Value()) > 1 { // conditional on a version
+						v, e = n.UpdateIf(q.Value()[0].Interface().(lib.Node), q.Value()[1].String())
+						break
+					}
 					v, e = n.Update(q.Value()[0].Interface().(lib.Node))
 					break
 				case lib.QueryState_DISCOVER:
@@ -370,6 +404,9 @@ func (n *StateDifferenceEngine) Run(ready chan<- interface{}) {
 					v, e = n.GetValueDsc(q.URL())
 					break
 				}
+				if e == nil {
+					v = copyValue(v)
+				}
 				go n.sendQueryResponse(NewQueryResponse(
 					[]reflect.Value{v}, e), q.ResponseChan())
 				break
@@ -402,7 +439,7 @@ func (n *StateDifferenceEngine) Run(ready chan<- interface{}) {
 				}
 				var vs []reflect.Value
 				for _, i := range v {
-					vs = append(vs, reflect.ValueOf(i))
+					vs = append(vs, reflect.ValueOf(i.(*Node).copy()))
 				}
 				go n.sendQueryResponse(NewQueryResponse(vs, e), q.ResponseChan())
 				break
@@ -421,7 +458,7 @@ func (n *StateDifferenceEngine) Run(ready chan<- interface{}) {
 		case v := <-dchan: // got a discovery
 			data := v.Data().(*DiscoveryEvent)
 			_, url := lib.NodeURLSplit(data.URL)
-			val, ok := Registry.Discoverables[data.ID][url][data.ValueID]
+			val, ok := Registry.Discoverable(data.ID, url, data.ValueID)
 			n.Logf(DDEBUG, "processing discovery: si (%s) url (%s) id(%s)", data.ID, url, data.ValueID)
 			if !ok {
 				n.Logf(ERROR, "got discover, but can't lookup value: si (%s) url (%s) id(%s)", data.ID, url, data.ValueID)
@@ -433,10 +470,34 @@ func (n *StateDifferenceEngine) Run(ready chan<- interface{}) {
 			}
 			n.Logf(DEBUG, "discovered %s is %v\n", data.URL, vset.Interface())
 			break
+		case <-n.quit:
+			unsubscribe(n.schan, list)
+			go func() {
+				// answer queries, so nothing waits on us forever
+				for q := range n.qc {
+					go n.sendQueryResponse(NewQueryResponse(
+						[]reflect.Value{}, fmt.Errorf("StateDifferenceEngine is stopped")), q.ResponseChan())
+				}
+			}()
+			n.Log(INFO, "stopped StateDifferenceEngine")
+			close(n.stopped)
+			return
 		}
 	}
 }
 
+// Stop stops the engine, waiting up to timeout for it to finish what it's doing.
+// Queries made after Stop fail, so anything that makes them should be stopped first.
+// Stop must only be called after Run.
+func (n *StateDifferenceEngine) Stop(timeout time.Duration) {
+	n.stopOnce.Do(func() { close(n.quit) })
+	select {
+	case <-n.stopped:
+	case <-time.After(timeout):
+		n.Logf(ERROR, "timed out waiting for StateDifferenceEngine to stop")
+	}
+}
+
 ////////////////////////
 // Unexported methods /
 //////////////////////
@@ -474,7 +535,7 @@ func (n *StateDifferenceEngine) updateByType(dsc bool, m lib.Node) (r lib.Node,
 		for _, u := range diff {
 			_, url := lib.NodeURLSplit(u)
 			v, _ := r.GetValue(url)
-			evs = append(evs, NewStateChangeEvent(utype, u, reflect.ValueOf(v)))
+			evs = append(evs, NewStateChangeEvent(utype, u, reflect.ValueOf(copyValue(v))))
 		}
 		go n.Emit(evs)
 	}
@@ -531,6 +592,20 @@ func (n *StateDifferenceEngine) sendQueryResponse(qr lib.QueryResponse, r chan<-
 	r <- qr
 }
 
+// copyValue copies a value for a query response.  Like the nodes we answer with, it's a copy,
+// so the asker can use it while we go on changing state.
+func copyValue(v reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return v
+	}
+	if m, ok := v.Interface().(proto.Message); ok && !v.IsNil() {
+		return reflect.ValueOf(proto.Clone(m))
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
 ////////////////////////////
 // Passthrough Interfaces /
 //////////////////////////
@@ -31,6 +31,8 @@ type StateMutation struct {
 	base    *StateSpec // this is the spec, less the mutation value
 	timeout time.Duration
 	failto  [3]string
+	retry   lib.StateMutationRetry
+	depends []lib.StateMutationDependency
 }
 
 // NewStateMutation creates an initialized, specified StateMutation object
@@ -54,6 +56,20 @@ func NewStateMutation(mut map[string][2]reflect.Value, req map[string]reflect.Va
 	return r
 }
 
+// NewStateMutationWithRetry creates a StateMutation that will be retried according to retry when it times out
+func NewStateMutationWithRetry(mut map[string][2]reflect.Value, req map[string]reflect.Value, exc map[string]reflect.Value, context lib.StateMutationContext, timeout time.Duration, failto [3]string, retry lib.StateMutationRetry) *StateMutation {
+	r := NewStateMutation(mut, req, exc, context, timeout, failto)
+	r.retry = retry
+	return r
+}
+
+// NewStateMutationWithDepends creates a StateMutation that won't fire until the nodes it depends on reach the required states
+func NewStateMutationWithDepends(mut map[string][2]reflect.Value, req map[string]reflect.Value, exc map[string]reflect.Value, context lib.StateMutationContext, timeout time.Duration, failto [3]string, depends []lib.StateMutationDependency) *StateMutation {
+	r := NewStateMutation(mut, req, exc, context, timeout, failto)
+	r.depends = depends
+	return r
+}
+
 // Mutates returns the map of URLs/values (before & after) that mutate in this mutation
 func (s *StateMutation) Mutates() map[string][2]reflect.Value { return s.mut }
 
@@ -192,3 +208,9 @@ func (s *StateMutation) SpecCompatOut(sp lib.StateSpec, muts map[string]uint32)
 func (s *StateMutation) Timeout() time.Duration { return s.timeout }
 
 func (s *StateMutation) FailTo() [3]string { return s.failto }
+
+// Retry returns the retry policy to apply when this mutation times out
+func (s *StateMutation) Retry() lib.StateMutationRetry { return s.retry }
+
+// Depends returns the other node states this mutation has to wait for
+func (s *StateMutation) Depends() []lib.StateMutationDependency { return s.depends }
@@ -15,10 +15,14 @@ import (
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	pb "github.com/hpc/kraken/core/proto"
 	"github.com/hpc/kraken/lib"
 )
@@ -44,6 +48,8 @@ type MutationEvent struct {
 	NodeCfg  lib.Node
 	NodeDsc  lib.Node
 	Mutation [2]string // [0] = module, [1] = mutid
+	Attempt  uint32    // which attempt at this mutation this is, starting at 1
+	Trace    string    // W3C traceparent of the span for this mutation, if tracing is enabled
 }
 
 func (me *MutationEvent) String() string {
@@ -90,7 +96,28 @@ type mutationPath struct {
 	gend       *mutationNode
 	chain      []*mutationEdge
 	timer      *time.Timer
-	waitingFor string // the SI we're currently waiting for
+	waitingFor string                     // the SI we're currently waiting for
+	waitingOn  string                     // the node dependency we're currently waiting on
+	depWait    map[string][]lib.StateSpec // nodes we're indexed under in depWaiting, and the states they have to reach (nil: any change means we check again)
+	depCheck   uint64                     // counts the times we've started waiting on nodes, so a check that's been overtaken knows it
+	attempt    uint32                     // how many times we've fired the current mutation
+	retries    uint32                     // how many retries we've made over the whole path
+	record     *pb.MutationPathRecord     // history record for this path, nil if we aren't recording
+	stepStart  time.Time                  // when we first fired the current mutation
+	span       *Span                      // trace span for the whole path
+	stepSpan   *Span                      // trace span for the current mutation
+	waitSpan   *Span                      // trace span for waiting on a service or other nodes
+	ended      bool                       // the path completed, failed or was interrupted, and hasn't been continued since
+}
+
+// smeStats are counters kept for metrics
+type smeStats struct {
+	mutex     *sync.Mutex
+	completed uint64
+	failed    uint64
+	timeouts  uint64
+	retries   uint64
+	durations map[[2]string]*metricHistogram // how long mutations take, by module/mutation id
 }
 
 // DefaultRootSpec provides a sensible root StateSpec to build the mutation graph off of
@@ -109,12 +136,17 @@ type StateMutationEngine struct {
 	muts        []lib.StateMutation
 	mutResolver map[lib.StateMutation][2]string // this allows us to lookup module/id pair from mutation
 	// stuff we can compute from muts
-	mutators    map[string]uint32 // ref count, all URLs that mutate
-	requires    map[string]uint32 // ref count, referenced (req/exc) urls that don't mutate
-	graph       *mutationNode     // graph start
-	graphMutex  *sync.RWMutex
-	nodes       []*mutationNode // so we can search for matches
-	edges       []*mutationEdge
+	mutators   map[string]uint32 // ref count, all URLs that mutate
+	requires   map[string]uint32 // ref count, referenced (req/exc) urls that don't mutate
+	depends    map[string]uint32 // ref count, urls referenced by mutation dependencies on other nodes
+	graph      *mutationNode     // graph start
+	graphMutex *sync.RWMutex
+	nodes      []*mutationNode // so we can search for matches
+	edges      []*mutationEdge
+	// the stage1 graph is kept so we can extend/prune it as mutations come and go
+	s1root      *mutationNode
+	s1nodes     []*mutationNode
+	s1seen      map[lib.StateSpec]*mutationNode
 	em          *EventEmitter
 	qc          chan lib.Query
 	schan       chan<- lib.EventListener // subscription channel
@@ -125,12 +157,24 @@ type StateMutationEngine struct {
 	run         bool                     // are we running?
 	active      map[string]*mutationPath // active mutations
 	waiting     map[string][]*mutationPath
-	activeMutex *sync.Mutex // active (and waiting) needs some synchronization, or we can get in bad places
+	depWaiting  map[depKey][]*mutationPath // paths waiting on other nodes, by the node and URL whose change may let them go on
+	activeMutex *sync.Mutex                // active (and waiting) needs some synchronization, or we can get in bad places
 	query       *QueryEngine
 	log         lib.Logger
 	self        lib.NodeID
 	root        lib.StateSpec
 	freeze      bool
+	draining    int32  // no new paths are started while draining; atomic, since some callers of startNewMutation hold activeMutex
+	depChanges  uint64 // counts changes to nodes that paths may be waiting on; atomic, so checkNodes can tell if it missed one
+	// history of completed mutation paths, per node
+	history      map[string][]*pb.MutationPathRecord
+	historyLen   int
+	historyMutex *sync.Mutex
+	stats        *smeStats
+	tracer       *Tracer
+	quit         chan interface{}
+	stopped      chan interface{}
+	stopOnce     sync.Once
 }
 
 // NewStateMutationEngine creates an initialized StateMutationEngine
@@ -140,10 +184,13 @@ func NewStateMutationEngine(ctx Context, qc chan lib.Query) *StateMutationEngine
 		mutResolver: make(map[lib.StateMutation][2]string),
 		active:      make(map[string]*mutationPath),
 		waiting:     make(map[string][]*mutationPath),
+		depWaiting:  make(map[depKey][]*mutationPath),
 		activeMutex: &sync.Mutex{},
 		mutators:    make(map[string]uint32),
 		requires:    make(map[string]uint32),
+		depends:     make(map[string]uint32),
 		graph:       &mutationNode{spec: ctx.SME.RootSpec},
+		s1seen:      make(map[lib.StateSpec]*mutationNode),
 		graphMutex:  &sync.RWMutex{},
 		nodes:       []*mutationNode{},
 		edges:       []*mutationEdge{},
@@ -152,26 +199,82 @@ func NewStateMutationEngine(ctx Context, qc chan lib.Query) *StateMutationEngine
 		run:         false,
 		echan:       make(chan lib.Event),
 		sichan:      make(chan lib.Event),
+		quit:        make(chan interface{}),
+		stopped:     make(chan interface{}),
 		query:       &ctx.Query,
 		schan:       ctx.SubChan,
 		log:         &ctx.Logger,
 		self:        ctx.Self,
 		root:        ctx.SME.RootSpec,
 		freeze:      true,
+
+		history:      make(map[string][]*pb.MutationPathRecord),
+		historyLen:   ctx.SME.HistoryLength,
+		historyMutex: &sync.Mutex{},
+		stats: &smeStats{
+			mutex:     &sync.Mutex{},
+			durations: make(map[[2]string]*metricHistogram),
+		},
+		tracer: ctx.tracer,
 	}
 	sme.log.SetModule("StateMutationEngine")
 	return sme
 }
 
-// RegisterMutation injects new mutaitons into the SME. muts[i] should match callback[i]
-// We take a list so that we only call onUpdate once
-// LOCKS: graphMutex (RW)
+// RegisterMutation injects a new mutation into the SME
+// The stage1 graph is extended in place if we can, otherwise it is rebuilt; the rest of the graph is rebuilt either way (see finishGraph)
+// LOCKS: graphMutex (RW); activeMutex; path.mutex
 func (sme *StateMutationEngine) RegisterMutation(si, id string, mut lib.StateMutation) (e error) {
 	sme.graphMutex.Lock()
+	for m, r := range sme.mutResolver {
+		if r[0] == si && r[1] == id {
+			sme.graphMutex.Unlock()
+			return fmt.Errorf("mutation is already registered: %s:%s", si, id)
+		}
+		if m == mut {
+			sme.graphMutex.Unlock()
+			return fmt.Errorf("mutation %s:%s is already registered as %s:%s", si, id, r[0], r[1])
+		}
+	}
 	sme.muts = append(sme.muts, mut)
 	sme.mutResolver[mut] = [2]string{si, id}
+	if sme.s1root == nil || !sme.stage1Extend(mut) {
+		sme.rebuildGraph()
+	}
 	sme.graphMutex.Unlock()
-	sme.onUpdate()
+	sme.remapActive()
+	return
+}
+
+// UnregisterMutation removes a mutation from the SME
+// The stage1 graph is pruned in place if we can, otherwise it is rebuilt; the rest of the graph is rebuilt either way (see finishGraph)
+// Active mutation paths that still needed this mutation get a new path.
+// LOCKS: graphMutex (RW); activeMutex; path.mutex
+func (sme *StateMutationEngine) UnregisterMutation(si, id string) (e error) {
+	sme.graphMutex.Lock()
+	var mut lib.StateMutation
+	for m, r := range sme.mutResolver {
+		if r[0] == si && r[1] == id {
+			mut = m
+			break
+		}
+	}
+	if mut == nil {
+		sme.graphMutex.Unlock()
+		return fmt.Errorf("no such mutation registered: %s:%s", si, id)
+	}
+	for i := range sme.muts {
+		if sme.muts[i] == mut {
+			sme.muts = append(sme.muts[:i], sme.muts[i+1:]...)
+			break
+		}
+	}
+	delete(sme.mutResolver, mut)
+	if sme.s1root == nil || !sme.stage1Prune(mut) {
+		sme.rebuildGraph()
+	}
+	sme.graphMutex.Unlock()
+	sme.remapActive()
 	return
 }
 
@@ -271,33 +374,40 @@ func mutationNodesToProto(nodes []*mutationNode) (r pb.MutationNodeList) {
 	for _, mn := range nodes {
 		var nmn pb.MutationNode
 		nmn.Id = fmt.Sprintf("%p", mn)
-		label := ""
-
-		var reqKeys []string
-		var reqs = mn.spec.Requires()
-		for k := range reqs {
-			reqKeys = append(reqKeys, k)
-		}
-		sort.Strings(reqKeys)
-
-		for _, reqKey := range reqKeys {
-			reqValue := reqs[reqKey]
-			// Add req to label
-			trimKey := strings.Replace(reqKey, "type.googleapis.com", "", -1)
-			trimKey = strings.Replace(trimKey, "/", "", -1)
-			if label == "" {
-				label = fmt.Sprintf("%s: %s", trimKey, lib.ValueToString(reqValue))
-			} else {
-				label = fmt.Sprintf("%s\n%s: %s", label, trimKey, lib.ValueToString(reqValue))
-			}
-		}
-
-		nmn.Label = label
+		nmn.Label = specLabel(mn.spec)
 		r.MutationNodeList = append(r.MutationNodeList, &nmn)
 	}
 	return
 }
 
+// specLabel builds a human readable label from the requires of a spec
+func specLabel(spec lib.StateSpec) (label string) {
+	var reqKeys []string
+	var reqs = spec.Requires()
+	for k := range reqs {
+		reqKeys = append(reqKeys, k)
+	}
+	sort.Strings(reqKeys)
+
+	for _, reqKey := range reqKeys {
+		reqValue := reqs[reqKey]
+		// Add req to label
+		trimKey := strings.Replace(reqKey, "type.googleapis.com", "", -1)
+		trimKey = strings.Replace(trimKey, "/", "", -1)
+		if label == "" {
+			label = fmt.Sprintf("%s: %s", trimKey, lib.ValueToString(reqValue))
+		} else {
+			label = fmt.Sprintf("%s\n%s: %s", label, trimKey, lib.ValueToString(reqValue))
+		}
+	}
+	return
+}
+
+// specLabelLine is a single line version of specLabel
+func specLabelLine(spec lib.StateSpec) string {
+	return strings.Replace(specLabel(spec), "\n", ", ", -1)
+}
+
 // Converts a slice of sme mutation edges to a protobuf MutationEdgeList
 func mutationEdgesToProto(edges []*mutationEdge) (r pb.MutationEdgeList) {
 	for _, me := range edges {
@@ -314,11 +424,17 @@ func mutationEdgesToProto(edges []*mutationEdge) (r pb.MutationEdgeList) {
 // Converts an sme mutation path to a protobuf MutationPath
 // LOCKS: path.mutex
 func mutationPathToProto(path *mutationPath) (r pb.MutationPath, e error) {
-	path.mutex.Lock()
-	defer path.mutex.Unlock()
 	if path != nil {
+		path.mutex.Lock()
+		defer path.mutex.Unlock()
 		r.Cur = int64(path.cur)
 		r.Cmplt = path.cmplt
+		r.Attempt = path.attempt
+		r.Retries = path.retries
+		r.Waiting = path.waitingFor
+		if path.waitingOn != "" {
+			r.Waiting = path.waitingOn
+		}
 		for _, me := range path.chain {
 			var nme pb.MutationEdge
 			nme.From = fmt.Sprintf("%p", me.from)
@@ -348,10 +464,8 @@ func (sme *StateMutationEngine) filterMutNodesFromNode(n NodeID) (r []*mutationN
 
 		// Combine discoverables and mutators into discoverables map
 		discoverables := make(map[string]string)
-		for _, siMap := range Registry.Discoverables {
-			for key := range siMap {
-				discoverables[key] = ""
-			}
+		for _, key := range Registry.DiscoverableURLs() {
+			discoverables[key] = ""
 		}
 		sme.graphMutex.RLock()
 		for key := range sme.mutators {
@@ -480,12 +594,15 @@ func (sme *StateMutationEngine) Run(ready chan<- interface{}) {
 					return true
 				}
 			}
+			if _, ok := sme.depends[url]; ok { // another node may be waiting on this
+				return true
+			}
 			if url == "" { // this should mean we got CREATE/DELETE
 				return true
 			}
 			return false
 		},
-		func(v lib.Event) error { return ChanSender(v, sme.echan) })
+		func(v lib.Event) error { return sme.chanSender(v, sme.echan) })
 
 	// subscribe our listener
 	sme.schan <- sme.selist
@@ -504,7 +621,7 @@ func (sme *StateMutationEngine) Run(ready chan<- interface{}) {
 			}
 			return false
 		},
-		func(v lib.Event) error { return ChanSender(v, sme.sichan) },
+		func(v lib.Event) error { return sme.chanSender(v, sme.sichan) },
 	)
 	sme.schan <- sme.silist
 
@@ -572,6 +689,12 @@ func (sme *StateMutationEngine) Run(ready chan<- interface{}) {
 				go sme.sendQueryResponse(NewQueryResponse(
 					[]reflect.Value{reflect.ValueOf(pmp)}, e), q.ResponseChan())
 				break
+			case lib.Query_MUTATIONHISTORY:
+				n := NewNodeIDFromURL(q.URL())
+				h := sme.nodeHistory(n)
+				go sme.sendQueryResponse(NewQueryResponse(
+					[]reflect.Value{reflect.ValueOf(h)}, nil), q.ResponseChan())
+				break
 			case lib.Query_FREEZE:
 				sme.Freeze()
 				if sme.Frozen() {
@@ -616,8 +739,50 @@ func (sme *StateMutationEngine) Run(ready chan<- interface{}) {
 		case <-debugchan:
 			sme.Logf(DDEBUG, "There are %d active mutations.", len(sme.active))
 			break
+		case <-sme.quit:
+			unsubscribe(sme.schan, sme.selist, sme.silist)
+			go sme.stoppedQueries()
+			sme.Log(INFO, "stopped StateMutationEngine")
+			close(sme.stopped)
+			return
+		}
+	}
+}
+
+// Stop stops the engine, waiting up to timeout for it to finish what it's doing.
+// It should be drained first.  Stop must only be called after Run.
+func (sme *StateMutationEngine) Stop(timeout time.Duration) {
+	sme.stopOnce.Do(func() { close(sme.quit) })
+	select {
+	case <-sme.stopped:
+	case <-time.After(timeout):
+		sme.Logf(ERROR, "timed out waiting for StateMutationEngine to stop")
+	}
+}
+
+// stoppedQueries answers queries once we've stopped, so nothing waits on us forever.
+// History is still there (it's a record of how shutdown went); everything else is an error.
+// goroutine
+func (sme *StateMutationEngine) stoppedQueries() {
+	for q := range sme.qc {
+		if q.Type() == lib.Query_MUTATIONHISTORY {
+			h := sme.nodeHistory(NewNodeIDFromURL(q.URL()))
+			go sme.sendQueryResponse(NewQueryResponse(
+				[]reflect.Value{reflect.ValueOf(h)}, nil), q.ResponseChan())
+			continue
 		}
+		go sme.sendQueryResponse(NewQueryResponse(
+			[]reflect.Value{}, fmt.Errorf("StateMutationEngine is stopped")), q.ResponseChan())
+	}
+}
+
+// chanSender is ChanSender, but it gives up once we've stopped
+func (sme *StateMutationEngine) chanSender(v lib.Event, c chan<- lib.Event) error {
+	select {
+	case c <- v:
+	case <-sme.quit:
 	}
+	return nil
 }
 
 func (sme *StateMutationEngine) Frozen() bool {
@@ -636,6 +801,11 @@ func (sme *StateMutationEngine) Freeze() {
 func (sme *StateMutationEngine) Thaw() {
 	sme.Log(INFO, "thawing")
 	sme.activeMutex.Lock()
+	for _, p := range sme.active {
+		p.mutex.Lock()
+		sme.recordEnd(p, pb.MutationPathRecord_INTERRUPTED, "mutation engine was thawed")
+		p.mutex.Unlock()
+	}
 	sme.active = make(map[string]*mutationPath)
 	sme.freeze = false
 	sme.activeMutex.Unlock()
@@ -645,10 +815,133 @@ func (sme *StateMutationEngine) Thaw() {
 	}
 }
 
+// Drain stops new mutation paths from being started, and waits up to timeout for the active ones to end.
+// Paths that haven't ended by then are interrupted; modules that are in the middle of a mutation for them get an INTERRUPT.
+// The engine is left frozen.
+func (sme *StateMutationEngine) Drain(timeout time.Duration) {
+	sme.Log(INFO, "draining")
+	atomic.StoreInt32(&sme.draining, 1)
+	deadline := time.Now().Add(timeout)
+	for {
+		ps := sme.inFlight()
+		if len(ps) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			sme.Logf(NOTICE, "interrupting %d mutation path(s) that didn't end within %v", len(ps), timeout)
+			for _, p := range ps {
+				sme.interruptPath(p, "kraken is shutting down")
+			}
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	sme.Freeze()
+}
+
+// ServiceDepends says which services each service depends on.
+// A service depends on another if one of its mutations requires a value that a mutation of the other service mutates.
+// LOCKS: graphMutex (R)
+func (sme *StateMutationEngine) ServiceDepends() map[string][]string {
+	sme.graphMutex.RLock()
+	defer sme.graphMutex.RUnlock()
+	mutatedBy := map[string]map[string]bool{}
+	for _, m := range sme.muts {
+		si := sme.mutResolver[m][0]
+		for url := range m.Mutates() {
+			if mutatedBy[url] == nil {
+				mutatedBy[url] = map[string]bool{}
+			}
+			mutatedBy[url][si] = true
+		}
+	}
+	deps := map[string]map[string]bool{}
+	for _, m := range sme.muts {
+		si := sme.mutResolver[m][0]
+		for url := range m.Requires() {
+			for o := range mutatedBy[url] {
+				if o == si || o == "core" {
+					continue
+				}
+				if deps[si] == nil {
+					deps[si] = map[string]bool{}
+				}
+				deps[si][o] = true
+			}
+		}
+	}
+	r := map[string][]string{}
+	for si, d := range deps {
+		for o := range d {
+			r[si] = append(r[si], o)
+		}
+		sort.Strings(r[si])
+	}
+	return r
+}
+
 ////////////////////////
 // Unexported methods /
 //////////////////////
 
+// inFlight lists the active paths that haven't ended
+// LOCKS: activeMutex; path.mutex
+func (sme *StateMutationEngine) inFlight() (ps []*mutationPath) {
+	sme.activeMutex.Lock()
+	active := make([]*mutationPath, 0, len(sme.active))
+	for _, p := range sme.active {
+		active = append(active, p)
+	}
+	sme.activeMutex.Unlock()
+	for _, p := range active {
+		p.mutex.Lock()
+		if !p.ended {
+			ps = append(ps, p)
+		}
+		p.mutex.Unlock()
+	}
+	return
+}
+
+// interruptPath ends a path as interrupted.  If we fired its current mutation, the module gets an INTERRUPT.
+// LOCKS: path.mutex; graphMutex (R)
+func (sme *StateMutationEngine) interruptPath(p *mutationPath, reason string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.ended {
+		return
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	if p.waitingFor != "" || p.waitingOn != "" {
+		// it never fired its mutation, and mustn't once what it's waiting on is ready
+		sme.activeMutex.Lock()
+		sme.unwaitForService(p)
+		sme.unwaitForNodes(p)
+		sme.activeMutex.Unlock()
+		p.waitingOn = ""
+	} else if p.cur >= 0 && p.cur < len(p.chain) && sme.mutationInContext(p.end, p.chain[p.cur].mut) {
+		sme.graphMutex.RLock()
+		m := sme.mutResolver[p.chain[p.cur].mut]
+		sme.graphMutex.RUnlock()
+		sme.Logf(DEBUG, "interrupting mutation %s:%s for %s", m[0], m[1], p.end.ID().String())
+		sme.EmitOne(NewEvent(
+			lib.Event_STATE_MUTATION,
+			p.end.ID().String(),
+			&MutationEvent{
+				Type:     MutationEvent_INTERRUPT,
+				NodeCfg:  p.end,
+				NodeDsc:  p.start,
+				Mutation: m,
+				Attempt:  p.attempt,
+				Trace:    p.span.Traceparent(),
+			},
+		))
+	}
+	sme.recordEnd(p, pb.MutationPathRecord_INTERRUPTED, reason)
+}
+
 // !!!IMPORTANT!!!
 // collectURLs assumes you already hold a lock
 // currently only used in onUpdate
@@ -686,14 +979,40 @@ func (sme *StateMutationEngine) collectURLs() {
 			sme.requires[u]++
 
 		}
+		for _, d := range m.Depends() {
+			for _, u := range dependsURLs(d) {
+				sme.depends[u]++
+			}
+		}
+	}
+}
+
+// dependsURLs lists the URLs a dependency watches on other nodes
+func dependsURLs(d lib.StateMutationDependency) (urls []string) {
+	if d.Requires == nil {
+		return
+	}
+	for u := range d.Requires.Requires() {
+		urls = append(urls, u)
 	}
+	for u := range d.Requires.Excludes() {
+		urls = append(urls, u)
+	}
+	return
 }
 
 func (sme *StateMutationEngine) remapToNode(root *mutationNode, to *mutationNode, mutOnly bool) []*mutationEdge {
 	var mutEqual func(*mutationEdge, *mutationEdge) bool
 
 	if mutOnly {
-		mutEqual = func(a *mutationEdge, b *mutationEdge) bool { return a.mut == b.mut }
+		// the same mutation is only the same edge if it's to/from the same place:
+		// for an in edge that's the same from, for an out edge the same to.
+		// A mutation can reach a state from more than one node.  If we took those for duplicates,
+		// we'd drop all but one of them, and the nodes they come from would keep an edge to the
+		// copy of the state we're merging away, which isn't in the graph; that way there would be lost.
+		mutEqual = func(a *mutationEdge, b *mutationEdge) bool {
+			return a.mut == b.mut && (a.from == b.from || a.to == b.to)
+		}
 	} else {
 		mutEqual = func(a *mutationEdge, b *mutationEdge) bool { return a.Equal(b) }
 	}
@@ -776,56 +1095,8 @@ func (sme *StateMutationEngine) buildGraphStage1(root *mutationNode, edge *mutat
 	seenNodes[root.spec] = root
 
 	// find connecting mutations
-OUTER:
 	for _, m := range sme.muts {
-		// do we have a valid out arrow?
-		if m.SpecCompatOut(root.spec, sme.mutators) {
-			// m is a valid out arrow, create an edge for the out arrow
-			// first, do we already know about this one?
-			for _, edge := range root.out {
-				if m == edge.mut {
-					continue OUTER
-				}
-			}
-			newEdge := &mutationEdge{
-				cost: 1,
-				mut:  m,
-				from: root,
-			}
-			// ...and construct the new node that it connects to
-			newNode := &mutationNode{
-				spec: root.spec.SpecMergeMust(m.After()), // a combination of the current spec + the changes the mation creates
-				in:   []*mutationEdge{newEdge},           // we know we have this in at least
-				out:  []*mutationEdge{},                  // for now, out is empty
-			}
-			newEdge.to = newNode
-			root.out = append(root.out, newEdge)
-			// ready to recurse
-			ns, _ := sme.buildGraphStage1(newNode, newEdge, seenNodes)
-			nodes = append(nodes, ns...)
-		} else if m.SpecCompatIn(root.spec, sme.mutators) {
-			// note: it doesn't make sense for the same mutator to be both in/out.  This would be a meaningless nop.
-			// m is a valid in arrow, similar to out with some subtle differences
-			for _, edge := range root.in {
-				if m == edge.mut {
-					continue OUTER
-				}
-			}
-			newEdge := &mutationEdge{
-				cost: 1,
-				mut:  m,
-				to:   root,
-			}
-			newNode := &mutationNode{
-				spec: root.spec.SpecMergeMust(m.Before()),
-				in:   []*mutationEdge{},
-				out:  []*mutationEdge{newEdge},
-			}
-			newEdge.from = newNode
-			root.in = append(root.in, newEdge)
-			ns, _ := sme.buildGraphStage1(newNode, newEdge, seenNodes)
-			nodes = append(nodes, ns...)
-		}
+		nodes = append(nodes, sme.buildGraphStage1Connect(root, m, seenNodes)...)
 	}
 	// build edges list
 	for _, n := range nodes {
@@ -834,6 +1105,58 @@ OUTER:
 	return nodes, edges
 }
 
+// buildGraphStage1Connect connects a single mutation to root, if it fits, and recursively builds out from there
+// it returns any new nodes that were created
+func (sme *StateMutationEngine) buildGraphStage1Connect(root *mutationNode, m lib.StateMutation, seenNodes map[lib.StateSpec]*mutationNode) (nodes []*mutationNode) {
+	// do we have a valid out arrow?
+	if m.SpecCompatOut(root.spec, sme.mutators) {
+		// m is a valid out arrow, create an edge for the out arrow
+		// first, do we already know about this one?
+		for _, edge := range root.out {
+			if m == edge.mut {
+				return
+			}
+		}
+		newEdge := &mutationEdge{
+			cost: 1,
+			mut:  m,
+			from: root,
+		}
+		// ...and construct the new node that it connects to
+		newNode := &mutationNode{
+			spec: root.spec.SpecMergeMust(m.After()), // a combination of the current spec + the changes the mation creates
+			in:   []*mutationEdge{newEdge},           // we know we have this in at least
+			out:  []*mutationEdge{},                  // for now, out is empty
+		}
+		newEdge.to = newNode
+		root.out = append(root.out, newEdge)
+		// ready to recurse
+		nodes, _ = sme.buildGraphStage1(newNode, newEdge, seenNodes)
+	} else if m.SpecCompatIn(root.spec, sme.mutators) {
+		// note: it doesn't make sense for the same mutator to be both in/out.  This would be a meaningless nop.
+		// m is a valid in arrow, similar to out with some subtle differences
+		for _, edge := range root.in {
+			if m == edge.mut {
+				return
+			}
+		}
+		newEdge := &mutationEdge{
+			cost: 1,
+			mut:  m,
+			to:   root,
+		}
+		newNode := &mutationNode{
+			spec: root.spec.SpecMergeMust(m.Before()),
+			in:   []*mutationEdge{},
+			out:  []*mutationEdge{newEdge},
+		}
+		newEdge.from = newNode
+		root.in = append(root.in, newEdge)
+		nodes, _ = sme.buildGraphStage1(newNode, newEdge, seenNodes)
+	}
+	return
+}
+
 // some useful util functions for graph building
 func edgeInEdges(m *mutationEdge, es []*mutationEdge) bool {
 	for _, e := range es {
@@ -1140,12 +1463,26 @@ OUTER_EDGE:
 }
 
 // buildGraph builds the graph of Specs/Mutations.  It is depth-first, recursive.
+// The stage1 graph is kept in sme.s1* so that it can be updated incrementally.
 // TODO: this function may eventually need recursion protection
 // !!!IMPORTANT!!!
 // buildGraph assumes you already hold a lock
-// currently only used in onUpdate
-func (sme *StateMutationEngine) buildGraph(root *mutationNode) (nodes []*mutationNode, edges []*mutationEdge) {
-	nodes, edges = sme.buildGraphStage1(root, nil, map[lib.StateSpec]*mutationNode{})
+// currently only used in rebuildGraph
+func (sme *StateMutationEngine) buildGraph(root *mutationNode) {
+	sme.s1root = root
+	sme.s1seen = map[lib.StateSpec]*mutationNode{}
+	sme.s1nodes, _ = sme.buildGraphStage1(root, nil, sme.s1seen)
+	sme.finishGraph()
+}
+
+// finishGraph builds the final graph from a copy of the stage1 graph
+// Only stage1 is kept up to date incrementally.  The copy and the later stages (buildGraphStripState)
+// are redone over the whole graph every time, since stripping state can merge nodes anywhere in it,
+// so registering a mutation always costs at least that much.
+// !!!IMPORTANT!!!
+// finishGraph assumes you already hold a lock
+func (sme *StateMutationEngine) finishGraph() {
+	root, nodes, edges := cloneGraph(sme.s1root, sme.s1nodes)
 	if sme.log.GetLoggerLevel() > lib.LLDEBUG {
 		sme.graphIsSane(nodes, edges)
 	}
@@ -1154,39 +1491,285 @@ func (sme *StateMutationEngine) buildGraph(root *mutationNode) (nodes []*mutatio
 		sme.DumpJSONGraph(nodes, edges)
 	}
 
-	nodes, edges = sme.buildGraphStripState(nodes, edges)
+	sme.graph = root
+	sme.nodes, sme.edges = sme.buildGraphStripState(nodes, edges)
 	if sme.log.GetLoggerLevel() > lib.LLDEBUG {
-		sme.graphIsSane(nodes, edges)
+		sme.graphIsSane(sme.nodes, sme.edges)
+	}
+	sme.Logf(DEBUG, "Built graph [ Mutations: %d Mutation URLs: %d Requires URLs: %d Graph Nodes: %d Graph Edges: %d ]",
+		len(sme.muts), len(sme.mutators), len(sme.requires), len(sme.nodes), len(sme.edges))
+}
+
+// cloneGraph makes a deep copy of a graph, since later graph stages modify nodes in place
+func cloneGraph(root *mutationNode, nodes []*mutationNode) (nroot *mutationNode, nnodes []*mutationNode, nedges []*mutationEdge) {
+	nmap := map[*mutationNode]*mutationNode{}
+	emap := map[*mutationEdge]*mutationEdge{}
+	copyValues := func(from map[string]reflect.Value) (to map[string]reflect.Value) {
+		to = make(map[string]reflect.Value)
+		for k, v := range from {
+			to[k] = v
+		}
+		return
+	}
+	var cloneNode func(n *mutationNode) *mutationNode
+	var cloneEdge func(e *mutationEdge) *mutationEdge
+	cloneNode = func(n *mutationNode) *mutationNode {
+		if nn, ok := nmap[n]; ok {
+			return nn
+		}
+		nn := &mutationNode{
+			spec: NewStateSpec(copyValues(n.spec.Requires()), copyValues(n.spec.Excludes())),
+			in:   make([]*mutationEdge, 0, len(n.in)),
+			out:  make([]*mutationEdge, 0, len(n.out)),
+		}
+		nmap[n] = nn
+		for _, e := range n.in {
+			nn.in = append(nn.in, cloneEdge(e))
+		}
+		for _, e := range n.out {
+			nn.out = append(nn.out, cloneEdge(e))
+		}
+		return nn
+	}
+	cloneEdge = func(e *mutationEdge) *mutationEdge {
+		if ne, ok := emap[e]; ok {
+			return ne
+		}
+		ne := &mutationEdge{
+			cost: e.cost,
+			mut:  e.mut,
+		}
+		emap[e] = ne
+		ne.from = cloneNode(e.from)
+		ne.to = cloneNode(e.to)
+		return ne
+	}
+	nroot = cloneNode(root)
+	for _, n := range nodes {
+		nn := cloneNode(n)
+		nnodes = append(nnodes, nn)
+		nedges = append(nedges, nn.out...)
 	}
 	return
 }
 
+// stage1Extend adds a newly registered mutation to the stage1 graph and rebuilds the final graph
+// it returns false, without changing anything, if the mutation changes which URLs are mutators.
+// in that case, the graph needs a full rebuild.
+// !!!IMPORTANT!!!
+// stage1Extend assumes you already hold a lock
+func (sme *StateMutationEngine) stage1Extend(m lib.StateMutation) bool {
+	for u := range m.Mutates() {
+		if _, ok := sme.mutators[u]; !ok {
+			return false
+		}
+	}
+	for _, urls := range []map[string]reflect.Value{m.Requires(), m.Excludes()} {
+		for u := range urls {
+			_, isMut := sme.mutators[u]
+			_, isReq := sme.requires[u]
+			if !isMut && !isReq {
+				return false
+			}
+		}
+	}
+	sme.countURLs(m, 1)
+	for _, n := range append([]*mutationNode{}, sme.s1nodes...) {
+		sme.s1nodes = append(sme.s1nodes, sme.buildGraphStage1Connect(n, m, sme.s1seen)...)
+	}
+	sme.finishGraph()
+	return true
+}
+
+// stage1Prune removes an unregistered mutation from the stage1 graph and rebuilds the final graph
+// it returns false, without changing anything, if the mutation changes which URLs are mutators.
+// in that case, the graph needs a full rebuild.
+// !!!IMPORTANT!!!
+// stage1Prune assumes you already hold a lock
+func (sme *StateMutationEngine) stage1Prune(m lib.StateMutation) bool {
+	for u := range m.Mutates() {
+		if sme.mutators[u] <= 1 {
+			return false
+		}
+	}
+	for _, urls := range []map[string]reflect.Value{m.Requires(), m.Excludes()} {
+		for u := range urls {
+			if _, ok := sme.mutators[u]; !ok && sme.requires[u] <= 1 {
+				return false
+			}
+		}
+	}
+	sme.countURLs(m, -1)
+
+	rmMut := func(edges []*mutationEdge) (r []*mutationEdge) {
+		for _, e := range edges {
+			if e.mut != m {
+				r = append(r, e)
+			}
+		}
+		return
+	}
+	isNode := map[*mutationNode]bool{}
+	for _, n := range sme.s1nodes {
+		n.in = rmMut(n.in)
+		n.out = rmMut(n.out)
+		isNode[n] = true
+	}
+
+	// anything we can no longer get to from the root is gone
+	keep := map[*mutationNode]bool{sme.s1root: true}
+	queue := []*mutationNode{sme.s1root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range append(append([]*mutationEdge{}, n.in...), n.out...) {
+			for _, nn := range []*mutationNode{e.from, e.to} {
+				if isNode[nn] && !keep[nn] {
+					keep[nn] = true
+					queue = append(queue, nn)
+				}
+			}
+		}
+	}
+	nodes := []*mutationNode{}
+	sme.s1seen = map[lib.StateSpec]*mutationNode{}
+	for _, n := range sme.s1nodes {
+		if keep[n] {
+			nodes = append(nodes, n)
+			sme.s1seen[n.spec] = n
+		}
+	}
+	sme.s1nodes = nodes
+	sme.finishGraph()
+	return true
+}
+
+// countURLs updates the URL ref counts for a single mutation by delta
+// !!!IMPORTANT!!!
+// countURLs assumes you already hold a lock
+// it assumes all of the mutation's URLs are already known, see stage1Extend and stage1Prune
+func (sme *StateMutationEngine) countURLs(m lib.StateMutation, delta int) {
+	for u := range m.Mutates() {
+		sme.mutators[u] = uint32(int(sme.mutators[u]) + delta)
+	}
+	for _, urls := range []map[string]reflect.Value{m.Requires(), m.Excludes()} {
+		for u := range urls {
+			if _, ok := sme.mutators[u]; ok {
+				continue
+			}
+			sme.requires[u] = uint32(int(sme.requires[u]) + delta)
+		}
+	}
+	for _, d := range m.Depends() {
+		for _, u := range dependsURLs(d) {
+			sme.depends[u] = uint32(int(sme.depends[u]) + delta)
+			if sme.depends[u] == 0 {
+				delete(sme.depends, u)
+			}
+		}
+	}
+}
+
 // !!!IMPORTANT!!!
 // clearGraph assumes you already hold a lock
-// currently only used in onUpdate
+// currently only used in rebuildGraph
 func (sme *StateMutationEngine) clearGraph() {
 	sme.mutators = make(map[string]uint32)
 	sme.requires = make(map[string]uint32)
-	sme.graph.in = []*mutationEdge{}
-	sme.graph.out = []*mutationEdge{}
-	sme.graph.spec = sme.root
+	sme.depends = make(map[string]uint32)
+	sme.graph = &mutationNode{
+		spec: sme.root,
+		in:   []*mutationEdge{},
+		out:  []*mutationEdge{},
+	}
+}
+
+// rebuildGraph throws away the graph and builds it from scratch
+// !!!IMPORTANT!!!
+// rebuildGraph assumes you already hold a lock
+func (sme *StateMutationEngine) rebuildGraph() {
+	sme.clearGraph()
+	sme.collectURLs()
+	sme.buildGraph(sme.graph)
 }
 
-// onUpdate should get called any time a new mutation is registered
+// onUpdate rebuilds the whole graph
 // onUpdate gets a graphMutex around everything, so it's important that it doesn't
 // call anything that tries to get it's own lock, or it will deadlock
 // LOCKS: graphMutex (RW)
-// FIXME: We should re-compute active mutations?
 func (sme *StateMutationEngine) onUpdate() {
 	sme.graphMutex.Lock()
-	sme.clearGraph()
-	sme.collectURLs()
-	sme.nodes, sme.edges = sme.buildGraph(sme.graph)
-	sme.Logf(DEBUG, "Built graph [ Mutations: %d Mutation URLs: %d Requires URLs: %d Graph Nodes: %d Graph Edges: %d ]",
-		len(sme.muts), len(sme.mutators), len(sme.requires), len(sme.nodes), len(sme.edges))
+	sme.rebuildGraph()
 	sme.graphMutex.Unlock()
 }
 
+// remapActive points active mutation paths at the current graph after it has been rebuilt
+// paths that depend on mutations that no longer exist are interrupted and restarted
+// LOCKS: graphMutex (R); activeMutex; path.mutex
+func (sme *StateMutationEngine) remapActive() {
+	// we can't hold graphMutex while we take path locks, so work from a snapshot
+	sme.graphMutex.RLock()
+	nodes, edges := sme.nodes, sme.edges
+	registered := make(map[lib.StateMutation]bool, len(sme.mutResolver))
+	for m := range sme.mutResolver {
+		registered[m] = true
+	}
+	sme.graphMutex.RUnlock()
+
+	findEdge := func(e *mutationEdge) *mutationEdge {
+		for _, ne := range edges {
+			if ne.mut == e.mut && ne.from.spec.Equal(e.from.spec) && ne.to.spec.Equal(e.to.spec) {
+				return ne
+			}
+		}
+		return nil
+	}
+	findNode := func(n *mutationNode) *mutationNode {
+		if n == nil {
+			return nil
+		}
+		for _, nn := range nodes {
+			if nn.spec.Equal(n.spec) {
+				return nn
+			}
+		}
+		return n
+	}
+	restart := []string{}
+	sme.activeMutex.Lock()
+	for node, p := range sme.active {
+		p.mutex.Lock()
+		chain := make([]*mutationEdge, len(p.chain))
+		lost := false
+		for i, e := range p.chain {
+			if chain[i] = findEdge(e); chain[i] == nil {
+				chain[i] = e
+				if !registered[e.mut] && i >= p.cur {
+					lost = true
+				}
+			}
+		}
+		if lost {
+			if p.timer != nil {
+				p.timer.Stop()
+			}
+			sme.recordEnd(p, pb.MutationPathRecord_INTERRUPTED, "a mutation in the path was unregistered")
+			sme.unwaitForNodes(p)
+			delete(sme.active, node)
+			restart = append(restart, node)
+		} else {
+			p.chain = chain
+			p.gstart, p.gend = findNode(p.gstart), findNode(p.gend)
+		}
+		p.mutex.Unlock()
+	}
+	sme.activeMutex.Unlock()
+	for _, node := range restart {
+		sme.Logf(DEBUG, "%s lost a mutation in its path, finding a new one", node)
+		sme.startNewMutation(node)
+	}
+}
+
 // LOCKS: graphMutex (R)
 func (sme *StateMutationEngine) nodeSearch(node lib.Node) (mns []*mutationNode) {
 	sme.graphMutex.RLock()
@@ -1199,10 +1782,9 @@ func (sme *StateMutationEngine) nodeSearch(node lib.Node) (mns []*mutationNode)
 	return
 }
 
-// LOCKS: graphMutex (R)
+// LOCKS: none; the caller must hold graphMutex (R)
 func (sme *StateMutationEngine) boundarySearch(start lib.Node, end lib.Node) (gstart []*mutationNode, gend []*mutationNode) {
 	startMerge := sme.dscNodeMeld(end, start)
-	sme.graphMutex.RLock()
 	for _, n := range sme.nodes {
 		// in general, we don't want the graph root as an option
 		if n != sme.graph && n.spec.NodeMatchWithMutators(startMerge, sme.mutators) {
@@ -1212,7 +1794,6 @@ func (sme *StateMutationEngine) boundarySearch(start lib.Node, end lib.Node) (gs
 			gend = append(gend, n)
 		}
 	}
-	sme.graphMutex.RUnlock()
 	// there's one exception: we may be starting on the graph root (if nothing else matched)
 	if len(gstart) == 0 {
 		gstart = append(gstart, sme.graph)
@@ -1222,10 +1803,8 @@ func (sme *StateMutationEngine) boundarySearch(start lib.Node, end lib.Node) (gs
 
 // drijkstra implements the Drijkstra shortest path graph algorithm.
 // NOTE: An alternative would be to pre-compute trees for every node
-// LOCKS: graphMutex (R)
+// LOCKS: none; the caller must hold graphMutex (R)
 func (sme *StateMutationEngine) drijkstra(gstart *mutationNode, gend []*mutationNode) *mutationPath {
-	sme.graphMutex.RLock()
-	defer sme.graphMutex.RUnlock()
 	isEnd := func(i *mutationNode) (r bool) {
 		for _, j := range gend {
 			if i == j {
@@ -1294,8 +1873,25 @@ func (sme *StateMutationEngine) drijkstra(gstart *mutationNode, gend []*mutation
 }
 
 // findPath finds the sequence of edges (if it exists) between two lib.Nodes
-// LOCKS: graphMutex (R) via boundarySearch, drijkstra
+// LOCKS: graphMutex (R)
 func (sme *StateMutationEngine) findPath(start lib.Node, end lib.Node) (path *mutationPath, e error) {
+	sme.graphMutex.RLock()
+	// the graph can be rebuilt (e.g. when a plugin registers), so hold it still while we search it
+	path, e = sme.findPathLocked(start, end)
+	sme.graphMutex.RUnlock()
+	if e != nil && sme.GetLoggerLevel() >= DDEBUG {
+		fmt.Printf("start: %v, end: %v\n", string(start.JSON()), string(end.JSON()))
+		sme.DumpGraph()
+		sme.graphMutex.RLock()
+		sme.DumpJSONGraph(sme.nodes, sme.edges) // Use this to debug your graph
+		sme.graphMutex.RUnlock()
+	}
+	return
+}
+
+// findPathLocked is findPath, once we hold graphMutex (R)
+// LOCKS: none; the caller must hold graphMutex (R)
+func (sme *StateMutationEngine) findPathLocked(start lib.Node, end lib.Node) (path *mutationPath, e error) {
 	same := true
 	for m := range sme.mutators {
 		sv, _ := start.GetValue(m)
@@ -1323,13 +1919,6 @@ func (sme *StateMutationEngine) findPath(start lib.Node, end lib.Node) (path *mu
 	}
 	if len(ge) < 1 {
 		e = fmt.Errorf("could not find path: end not in graph")
-		if sme.GetLoggerLevel() >= DDEBUG {
-			fmt.Printf("start: %v, end: %v\n", string(start.JSON()), string(end.JSON()))
-			sme.DumpGraph()
-			sme.graphMutex.RLock()
-			sme.DumpJSONGraph(sme.nodes, sme.edges) // Use this to debug your graph
-			sme.graphMutex.RUnlock()
-		}
 	}
 	if e != nil {
 		return
@@ -1368,6 +1957,10 @@ func (sme *StateMutationEngine) startNewMutation(node string) {
 		sme.Log(ERROR, e.Error())
 		return
 	}
+	if atomic.LoadInt32(&sme.draining) != 0 {
+		sme.Logf(DEBUG, "not starting a new mutation for %s, we're draining", nid.String())
+		return
+	}
 	p, e := sme.findPath(start, end)
 	if e != nil {
 		sme.Log(ERROR, e.Error())
@@ -1386,20 +1979,14 @@ func (sme *StateMutationEngine) startNewMutation(node string) {
 	sme.activeMutex.Lock()
 	sme.active[node] = p
 	sme.activeMutex.Unlock()
+	sme.recordStart(p)
 	sme.Logf(DEBUG, "started new mutation for %s (1/%d).", nid.String(), len(p.chain))
 	if sme.mutationInContext(end, p.chain[p.cur].mut) {
-		if sme.waitForServices(p) {
+		if sme.waitForServices(p) || sme.waitForNodes(p) {
 			return
 		}
-		sme.Logf(DDEBUG, "firing mutation in context, timeout %s.", p.chain[p.cur].mut.Timeout().String())
-		sme.emitMutation(end, start, p.chain[p.cur].mut)
-		if p.chain[p.cur].mut.Timeout() != 0 {
-			if p.timer != nil {
-				// Stop old timer if it exists
-				p.timer.Stop()
-			}
-			p.timer = time.AfterFunc(p.chain[p.cur].mut.Timeout(), func() { sme.emitFail(start, p) })
-		}
+		p.attempt = 1
+		sme.fireMutation(p)
 	} else {
 		sme.Log(DDEBUG, "mutation is not in our context.")
 	}
@@ -1423,6 +2010,8 @@ func (sme *StateMutationEngine) waitForServices(p *mutationPath) (wait bool) {
 		// queue already exists, just add ourselves to it
 		sme.waiting[si] = append(sme.waiting[si], p)
 		p.waitingFor = si
+		sme.startWaitTimer(p)
+		sme.traceWait(p, "wait for service "+si)
 		sme.Logf(INFO, "%s is waiting for service %s", p.end.ID().String(), si)
 		return true
 	}
@@ -1444,6 +2033,8 @@ func (sme *StateMutationEngine) waitForServices(p *mutationPath) (wait bool) {
 		// 3. Create a waitlist of this SI
 		sme.waiting[si] = []*mutationPath{p}
 		p.waitingFor = si
+		sme.startWaitTimer(p)
+		sme.traceWait(p, "wait for service "+si)
 		sme.Logf(INFO, "%s is waiting for service %s", p.end.ID().String(), si)
 		return true
 	}
@@ -1467,6 +2058,282 @@ func (sme *StateMutationEngine) unwaitForService(p *mutationPath) {
 	}
 }
 
+// depKey is a node, and a URL of it whose change a path may be waiting on; "" is the node being created or deleted
+type depKey struct {
+	node string
+	url  string
+}
+
+// depKeys are the keys a path waiting on node to reach specs is indexed under in depWaiting
+func depKeys(node string, specs []lib.StateSpec) (keys []depKey) {
+	seen := map[string]bool{"": true}
+	keys = append(keys, depKey{node, ""})
+	for _, spec := range specs {
+		for _, u := range dependsURLs(lib.StateMutationDependency{Requires: spec}) {
+			if !seen[u] {
+				seen[u] = true
+				keys = append(keys, depKey{node, u})
+			}
+		}
+	}
+	return
+}
+
+// waitForNodes queues the path if the current mutation depends on other nodes.
+// Whether they're ready is up to checkNodes, which reads them without the path locked; it fires the mutation if they are.
+// Assumes that path is already locked
+func (sme *StateMutationEngine) waitForNodes(p *mutationPath) (wait bool) {
+	if len(p.chain[p.cur].mut.Depends()) == 0 {
+		return
+	}
+	p.waitingOn = "the nodes it depends on"
+	p.depCheck++
+	sme.startWaitTimer(p)
+	sme.traceWait(p, "wait for nodes")
+	go sme.checkNodes(p, p.depCheck)
+	return true
+}
+
+// depWaitOn indexes a path under the nodes it's waiting on
+// assumes activeMutex is already locked
+func (sme *StateMutationEngine) depWaitOn(p *mutationPath, waits map[string][]lib.StateSpec) {
+	p.depWait = waits
+	for node, specs := range waits {
+		for _, k := range depKeys(node, specs) {
+			sme.depWaiting[k] = append(sme.depWaiting[k], p)
+		}
+	}
+}
+
+// unwaitForNode takes a path out of the depWaiting index for one node
+// assumes activeMutex is already locked
+func (sme *StateMutationEngine) unwaitForNode(p *mutationPath, node string) {
+	for _, k := range depKeys(node, p.depWait[node]) {
+		queue := sme.depWaiting[k]
+		for i := range queue {
+			if queue[i] == p {
+				// order isn't important
+				queue[i] = queue[len(queue)-1]
+				queue = queue[:len(queue)-1]
+				break
+			}
+		}
+		if len(queue) == 0 {
+			delete(sme.depWaiting, k)
+		} else {
+			sme.depWaiting[k] = queue
+		}
+	}
+	delete(p.depWait, node)
+}
+
+// unwaitForNodes takes a path out of the depWaiting index
+// assumes activeMutex is already locked
+func (sme *StateMutationEngine) unwaitForNodes(p *mutationPath) {
+	for node := range p.depWait {
+		sme.unwaitForNode(p, node)
+	}
+	p.depWait = nil
+}
+
+// startWaitTimer starts the current mutation's timeout clock while the path waits to fire it,
+// so a path can't wait forever on a service or node that never gets there
+// assumes p.mutex is locked by surrounding func
+func (sme *StateMutationEngine) startWaitTimer(p *mutationPath) {
+	mut := p.chain[p.cur].mut
+	if mut.Timeout() == 0 {
+		return
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.timer = time.AfterFunc(mut.Timeout(), func() { sme.mutationTimeout(p) })
+}
+
+// dependsUnmet reads the nodes a mutation depends on, and returns the ones that aren't ready, with the states they have to reach.
+// A nil state means we can't tell what the node has to do; any change to it is worth checking again for.
+// unmet describes the first dependency that isn't met; it's "" if they all are.
+// It doesn't touch the path, so it can be called without the path locked.
+func (sme *StateMutationEngine) dependsUnmet(self lib.Node, deps []lib.StateMutationDependency) (waits map[string][]lib.StateSpec, unmet string) {
+	waits = make(map[string][]lib.StateSpec)
+	wait := func(node string, spec lib.StateSpec, f string, v ...interface{}) {
+		waits[node] = append(waits[node], spec)
+		if unmet == "" {
+			unmet = fmt.Sprintf(f, v...)
+		}
+	}
+	for _, d := range deps {
+		if d.NodeURL != "" {
+			v, e := self.GetValue(d.NodeURL)
+			if e != nil {
+				wait(self.ID().String(), nil, "the node referenced by %s (%v)", d.NodeURL, e)
+				continue
+			}
+			var nid lib.NodeID
+			switch v.Kind() {
+			case reflect.String:
+				nid = NewNodeID(v.String())
+			case reflect.Slice:
+				if b, ok := v.Interface().([]byte); ok {
+					nid = NewNodeIDFromBinary(b)
+				}
+			}
+			if nid == nil || nid.Nil() {
+				wait(self.ID().String(), nil, "the node referenced by %s (not a node ID)", d.NodeURL)
+				continue
+			}
+			n, e := sme.query.ReadDsc(nid)
+			if e != nil {
+				wait(nid.String(), d.Requires, "node %s (%v)", nid.String(), e)
+				continue
+			}
+			if d.Requires != nil && !d.Requires.NodeMatch(n) {
+				wait(nid.String(), d.Requires, "node %s to reach {%s}", nid.String(), specLabelLine(d.Requires))
+			}
+			continue
+		}
+		if d.Select == nil {
+			continue
+		}
+		ns, e := sme.query.ReadAll()
+		if e != nil {
+			// we can't tell which nodes to wait on; the timeout will have to do
+			wait(self.ID().String(), nil, "nodes matching {%s} (%v)", specLabelLine(d.Select), e)
+			continue
+		}
+		for _, n := range ns {
+			if n.ID().Equal(self.ID()) || !d.Select.NodeMatch(n) {
+				continue
+			}
+			dsc, e := sme.query.ReadDsc(n.ID())
+			if e != nil {
+				wait(n.ID().String(), d.Requires, "node %s (%v)", n.ID().String(), e)
+				continue
+			}
+			if d.Requires != nil && !d.Requires.NodeMatch(dsc) {
+				wait(n.ID().String(), d.Requires, "node %s to reach {%s}", n.ID().String(), specLabelLine(d.Requires))
+			}
+		}
+	}
+	return
+}
+
+// depCurrent says if a check that was asked for when the path's depCheck was check still matters
+// assumes p.mutex is locked by surrounding func
+// LOCKS: activeMutex
+func (sme *StateMutationEngine) depCurrent(p *mutationPath, check uint64) bool {
+	sme.activeMutex.Lock()
+	active := sme.active[p.end.ID().String()] == p
+	frozen := sme.freeze
+	sme.activeMutex.Unlock()
+	return active && !frozen && !p.ended && p.depCheck == check && p.waitingOn != ""
+}
+
+// checkNodes checks all of the node dependencies of a waiting path's current mutation.
+// If they're met, it fires the mutation; if not, it indexes the path under the nodes that aren't ready.
+// check is the path's depCheck when the check was asked for; if the path has moved on since, we leave it be.
+// LOCKS: path.mutex; activeMutex
+func (sme *StateMutationEngine) checkNodes(p *mutationPath, check uint64) {
+	for {
+		changes := atomic.LoadUint64(&sme.depChanges)
+		p.mutex.Lock()
+		if !sme.depCurrent(p, check) {
+			p.mutex.Unlock()
+			return
+		}
+		self, deps := p.end, p.chain[p.cur].mut.Depends()
+		p.mutex.Unlock()
+
+		// the reads can be slow (every node, for a Select), so the path isn't locked while we make them
+		waits, unmet := sme.dependsUnmet(self, deps)
+
+		p.mutex.Lock()
+		if !sme.depCurrent(p, check) {
+			p.mutex.Unlock()
+			return
+		}
+		if unmet != "" && atomic.LoadUint64(&sme.depChanges) != changes {
+			// a node changed while we looked, and we weren't indexed to hear about it; look again
+			p.mutex.Unlock()
+			continue
+		}
+		sme.activeMutex.Lock()
+		sme.unwaitForNodes(p)
+		if unmet != "" {
+			sme.depWaitOn(p, waits)
+		}
+		sme.activeMutex.Unlock()
+		if unmet != "" {
+			if p.waitingOn != unmet {
+				sme.Logf(INFO, "%s is waiting on %s", self.ID().String(), unmet)
+			}
+			p.waitingOn = unmet
+			p.mutex.Unlock()
+			return
+		}
+		sme.Logf(INFO, "%s is done waiting on %s", self.ID().String(), p.waitingOn)
+		p.waitingOn = ""
+		sme.resumeMutation(p)
+		p.mutex.Unlock()
+		return
+	}
+}
+
+// checkDepends wakes the paths waiting on a change to url of node ("" for the node being created or deleted).
+// The node is read once, without any locks held.  A path only looks at the rest of what it depends on
+// (with checkNodes) once this node is ready and nothing else is left, or if it can't tell from this node alone.
+// LOCKS: activeMutex; path.mutex
+func (sme *StateMutationEngine) checkDepends(node, url string) {
+	atomic.AddUint64(&sme.depChanges, 1)
+	sme.activeMutex.Lock()
+	queue := append([]*mutationPath{}, sme.depWaiting[depKey{node, url}]...)
+	sme.activeMutex.Unlock()
+	if len(queue) == 0 {
+		return
+	}
+	dsc, e := sme.query.ReadDsc(NewNodeIDFromURL(node))
+
+	for _, p := range queue {
+		p.mutex.Lock()
+		sme.activeMutex.Lock()
+		specs, ok := p.depWait[node]
+		recheck := false
+		if ok {
+			// a node that's come or gone may change what we're waiting on, and a nil state says we can't tell on our own
+			recheck = e != nil || url == ""
+			met := e == nil
+			for _, spec := range specs {
+				if spec == nil {
+					recheck = true
+				} else if met && !spec.NodeMatch(dsc) {
+					met = false
+				}
+			}
+			if met && !recheck {
+				sme.unwaitForNode(p, node)
+				recheck = len(p.depWait) == 0
+			}
+		}
+		sme.activeMutex.Unlock()
+		check := p.depCheck
+		p.mutex.Unlock()
+		if recheck {
+			sme.checkNodes(p, check)
+		}
+	}
+}
+
+// resumeMutation fires the current mutation of a path that was waiting on other nodes, now that they're ready
+// we've just checked the node dependencies, so we only make sure the service is still running
+// assumes p.mutex is locked by surrounding func
+func (sme *StateMutationEngine) resumeMutation(p *mutationPath) {
+	if sme.waitForServices(p) {
+		return
+	}
+	p.attempt = 1
+	sme.fireMutation(p)
+}
+
 func (sme *StateMutationEngine) handleServiceEvent(v *StateChangeEvent) {
 	if v.Type != StateChange_UPDATE {
 		return
@@ -1520,17 +2387,108 @@ func (sme *StateMutationEngine) handleServiceEvent(v *StateChangeEvent) {
 	}
 }
 
-// LOCKS: graphMutex (R); path.mutex
-func (sme *StateMutationEngine) emitFail(start lib.Node, p *mutationPath) {
+// fireMutation emits the current mutation in the path and starts its timeout clock
+// assumes p.mutex is locked by surrounding func
+func (sme *StateMutationEngine) fireMutation(p *mutationPath) {
+	mut := p.chain[p.cur].mut
+	if p.attempt <= 1 {
+		p.stepStart = time.Now()
+	}
+	sme.traceStep(p)
+	sme.recordStep(p)
+	sme.Logf(DDEBUG, "firing mutation in context, timeout %s, attempt %d.", mut.Timeout().String(), p.attempt)
+	sme.emitMutation(p.end, p.start, mut, p.attempt, p.stepSpan.Traceparent())
+	if mut.Timeout() != 0 {
+		if p.timer != nil {
+			// Stop old timer if it exists
+			p.timer.Stop()
+		}
+		p.timer = time.AfterFunc(mut.Timeout(), func() { sme.mutationTimeout(p) })
+	}
+}
+
+// mutationTimeout gets called when a mutation times out
+// If the mutation has a retry policy with attempts left we schedule a retry, otherwise we emitFail
+// LOCKS: path.mutex; graphMutex (R) via emitFail
+func (sme *StateMutationEngine) mutationTimeout(p *mutationPath) {
+	p.mutex.Lock()
+	if p.cur < 0 || p.cur >= len(p.chain) {
+		p.mutex.Unlock()
+		return
+	}
+	sme.countStat(&sme.stats.timeouts)
+	if waiting := p.waitingFor + p.waitingOn; waiting != "" {
+		// we never fired the mutation, so there's nothing to retry
+		p.mutex.Unlock()
+		sme.emitFail(p.start, p, waiting)
+		return
+	}
+	retry := p.chain[p.cur].mut.Retry()
+	if p.attempt >= retry.Attempts {
+		p.mutex.Unlock()
+		sme.emitFail(p.start, p, "")
+		return
+	}
+	cur := p.cur
+	delay := retry.Delay(p.attempt)
+	sme.Logf(INFO, "mutation timeout for %s, retrying (%d/%d) in %s", p.start.ID().String(), p.attempt+1, retry.Attempts, delay.String())
+	p.timer = time.AfterFunc(delay, func() { sme.retryMutation(p, cur) })
+	p.mutex.Unlock()
+}
+
+// retryMutation re-fires the current mutation of a path once its retry delay has passed
+// cur is the chain position the retry was scheduled for; if the path has moved on, we do nothing
+// LOCKS: path.mutex; activeMutex
+func (sme *StateMutationEngine) retryMutation(p *mutationPath, cur int) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	sme.activeMutex.Lock()
+	active := sme.active[p.end.ID().String()] == p
+	frozen := sme.freeze
+	sme.activeMutex.Unlock()
+	if !active || frozen || p.cur != cur || p.waitingFor != "" {
+		sme.Logf(DEBUG, "%s path changed while waiting to retry, not retrying", p.end.ID().String())
+		return
+	}
+	p.attempt++
+	p.retries++
+	sme.countStat(&sme.stats.retries)
+	sme.fireMutation(p)
+}
+
+// waiting is what the path was waiting on when it timed out, or "" if we fired the mutation
+// LOCKS: graphMutex (R); path.mutex; activeMutex
+func (sme *StateMutationEngine) emitFail(start lib.Node, p *mutationPath, waiting string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if waiting != "" {
+		if p.waitingFor == "" && p.waitingOn == "" {
+			// we stopped waiting before we got here
+			return
+		}
+		sme.activeMutex.Lock()
+		active := sme.active[p.end.ID().String()] == p
+		sme.unwaitForService(p)
+		sme.unwaitForNodes(p)
+		sme.activeMutex.Unlock()
+		p.waitingOn = ""
+		if !active {
+			return
+		}
+	}
 
+	sme.countStat(&sme.stats.failed)
 	nid := p.start.ID()
 	d := p.chain[p.cur].mut.FailTo()
-	sme.Logf(INFO, "mutation timeout for %s, emitting: %s:%s:%s", nid.String(), d[0], d[1], d[2])
+	if waiting != "" {
+		sme.Logf(INFO, "mutation timeout for %s while waiting on %s, emitting: %s:%s:%s", nid.String(), waiting, d[0], d[1], d[2])
+	} else {
+		sme.Logf(INFO, "mutation timeout for %s after %d attempt(s), emitting: %s:%s:%s", nid.String(), p.attempt, d[0], d[1], d[2])
+	}
 
 	// try devolve first
-	val, ok := Registry.Discoverables[d[0]][d[1]][d[2]]
+	val, ok := Registry.Discoverable(d[0], d[1], d[2])
 	if !ok {
 		sme.Logf(ERROR, "could not find value %v:%v:%v in discoverables registry", d[0], d[1], d[2])
 		return
@@ -1551,6 +2509,8 @@ func (sme *StateMutationEngine) emitFail(start lib.Node, p *mutationPath) {
 		return
 	}
 
+	sme.recordStepEnd(p, fmt.Sprintf("timed out after %d attempt(s)", p.attempt))
+
 	// this is a devolution
 	if err == nil {
 		// ok, let's devolve
@@ -1563,6 +2523,14 @@ func (sme *StateMutationEngine) emitFail(start lib.Node, p *mutationPath) {
 	}
 
 	// We couldn't devolve so...
+	sme.graphMutex.RLock()
+	fm := sme.mutResolver[p.chain[p.cur].mut]
+	sme.graphMutex.RUnlock()
+	if waiting != "" {
+		sme.recordEnd(p, pb.MutationPathRecord_FAILED, fmt.Sprintf("mutation %s:%s timed out waiting on %s, failing to %s:%s", fm[0], fm[1], waiting, d[1], d[2]))
+	} else {
+		sme.recordEnd(p, pb.MutationPathRecord_FAILED, fmt.Sprintf("mutation %s:%s timed out, failing to %s:%s", fm[0], fm[1], d[1], d[2]))
+	}
 	// Create fake lib.node with our failto and all non-mutators (sme.requires).
 	// These non-mutators do not exist in the dsc (platform for example) so we have to pull from the cfg node
 	fn := NewNodeWithID(n.ID().String())
@@ -1647,8 +2615,15 @@ func (sme *StateMutationEngine) emitFail(start lib.Node, p *mutationPath) {
 			NodeCfg:  p.end,
 			NodeDsc:  start,
 			Mutation: sme.mutResolver[p.chain[p.cur].mut],
+			Attempt:  p.attempt,
+			Trace:    p.span.Traceparent(),
 		},
 	)
+	if waiting != "" {
+		// the module was never asked to mutate, so there's nothing to interrupt
+		sme.EmitOne(dv)
+		return
+	}
 	sme.Emit([]lib.Event{dv, iv})
 }
 
@@ -1661,18 +2636,11 @@ func (sme *StateMutationEngine) advanceMutation(node string, m *mutationPath) {
 	m.curSeen = []string{}
 	sme.Logf(DEBUG, "resuming mutation for %s (%d/%d).", nid.String(), m.cur+1, len(m.chain))
 	if sme.mutationInContext(m.end, m.chain[m.cur].mut) {
-		if sme.waitForServices(m) {
+		if sme.waitForServices(m) || sme.waitForNodes(m) {
 			return
 		}
-		sme.Logf(DDEBUG, "firing mutation in context, timeout %s.", m.chain[m.cur].mut.Timeout().String())
-		sme.emitMutation(m.end, m.start, m.chain[m.cur].mut)
-		if m.chain[m.cur].mut.Timeout() != 0 {
-			if m.timer != nil {
-				// Stop old timer if it exists
-				m.timer.Stop()
-			}
-			m.timer = time.AfterFunc(m.chain[m.cur].mut.Timeout(), func() { sme.emitFail(m.start, m) })
-		}
+		m.attempt = 1
+		sme.fireMutation(m)
 	} else {
 		sme.Logf(DDEBUG, "node (%s) mutation is not in our context", node)
 	}
@@ -1732,6 +2700,7 @@ func (sme *StateMutationEngine) handleUnexpected(node, url string, val reflect.V
 		return
 	}
 	m.cmplt = false
+	sme.recordStepEnd(m, fmt.Sprintf("unexpected change of %s", url))
 
 	// this is a bit bad.  We don't want to get our own state changes, so we change the node directly
 	nid := NewNodeIDFromURL(node)
@@ -1752,16 +2721,22 @@ func (sme *StateMutationEngine) handleUnexpected(node, url string, val reflect.V
 	if e == nil {
 		if len(p.chain) == 0 { // we're already there
 			sme.Logf(DEBUG, "%s discovered that we're already where we want to be", nid.String())
+			sme.recordEnd(m, pb.MutationPathRecord_COMPLETE, "")
 			return
 		}
 		// update the chain & increment
 		sme.Logf(DEBUG, "%s found a new path", node)
+		if m.record == nil {
+			// we're starting a new leg, so track where it starts & ends
+			m.gstart, m.gend = p.gstart, p.gend
+		}
 		m.chain = append(m.chain[:m.cur+1], p.chain...)
 		sme.advanceMutation(node, m)
 		return
 	}
 
 	sme.Logf(DEBUG, "%s could neither find a path, nor devolve.  We're lost.", node)
+	sme.recordEnd(m, pb.MutationPathRecord_FAILED, fmt.Sprintf("could not find a path: %v", e))
 }
 
 // updateMutation attempts to progress along an existing mutation chain
@@ -1772,16 +2747,18 @@ func (sme *StateMutationEngine) updateMutation(node string, url string, val refl
 	if !ok {
 		// this shouldn't happen
 		sme.Logf(DDEBUG, "call to updateMutation, but no mutation exists %s", node)
+		sme.activeMutex.Unlock() // startNewMutation takes it
 		sme.startNewMutation(node)
-		sme.activeMutex.Unlock()
 		return
 	}
 	// we should reset waiting status
 	sme.unwaitForService(m)
+	sme.unwaitForNodes(m)
 	sme.activeMutex.Unlock()
 
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
+	m.waitingOn = ""
 
 	// stop any timer clocks
 	if m.timer != nil {
@@ -1835,11 +2812,15 @@ func (sme *StateMutationEngine) updateMutation(node string, url string, val refl
 		}
 		m.curSeen = []string{} // possibly redundant
 		m.timer.Stop()
+		sme.recordStepEnd(m, "")
+		sme.observeStep(m)
 		// are we done?
 		if len(m.chain) == m.cur+1 {
 			// all done!
 			sme.Logf(DEBUG, "mutation chain completed for %s (%d/%d)", node, m.cur+1, len(m.chain))
 			m.cmplt = true
+			sme.countStat(&sme.stats.completed)
+			sme.recordEnd(m, pb.MutationPathRecord_COMPLETE, "")
 			return
 		}
 		sme.Logf(DEBUG, "mutation for %s progressing as normal, moving to next (%d/%d)", node, m.cur+1, len(m.chain))
@@ -1892,6 +2873,8 @@ func (sme *StateMutationEngine) handleEvent(v lib.Event) {
 			if m.timer != nil {
 				m.timer.Stop()
 			}
+			sme.recordEnd(m, pb.MutationPathRecord_INTERRUPTED, "node was re-created")
+			sme.unwaitForNodes(m)
 			delete(sme.active, node)
 			m.mutex.Unlock()
 			sme.activeMutex.Unlock()
@@ -1905,6 +2888,8 @@ func (sme *StateMutationEngine) handleEvent(v lib.Event) {
 			if m.timer != nil {
 				m.timer.Stop()
 			}
+			sme.recordEnd(m, pb.MutationPathRecord_INTERRUPTED, "node was deleted")
+			sme.unwaitForNodes(m)
 			delete(sme.active, node)
 			m.mutex.Unlock()
 			sme.activeMutex.Unlock()
@@ -1920,6 +2905,8 @@ func (sme *StateMutationEngine) handleEvent(v lib.Event) {
 			if m.timer != nil {
 				m.timer.Stop()
 			}
+			sme.recordEnd(m, pb.MutationPathRecord_INTERRUPTED, fmt.Sprintf("cfg changed: %s", url))
+			sme.unwaitForNodes(m)
 			delete(sme.active, node)
 			m.mutex.Unlock()
 			sme.activeMutex.Unlock()
@@ -1928,16 +2915,372 @@ func (sme *StateMutationEngine) handleEvent(v lib.Event) {
 		sme.startNewMutation(node)
 	default:
 	}
+	// any change in discovered state could be what another node is waiting on
+	switch sce.Type {
+	case StateChange_UPDATE:
+		sme.checkDepends(node, url)
+	case StateChange_CREATE, StateChange_DELETE:
+		sme.checkDepends(node, "")
+	}
+}
+
+// recordStart begins a new history record for a path
+// assumes p.mutex is locked by surrounding func
+func (sme *StateMutationEngine) recordStart(p *mutationPath) {
+	p.ended = false
+	sme.traceStart(p)
+	if sme.historyLen <= 0 {
+		return
+	}
+	p.record = &pb.MutationPathRecord{
+		Node:    p.end.ID().String(),
+		Started: ptypes.TimestampNow(),
+		Outcome: pb.MutationPathRecord_ACTIVE,
+		TraceId: p.span.Trace(),
+	}
+	if p.gstart != nil {
+		p.record.Start = specLabel(p.gstart.spec)
+	}
+	if p.gend != nil {
+		p.record.End = specLabel(p.gend.spec)
+	}
+}
+
+// recordStep adds the current mutation of a path to its history record
+// retries of the same mutation update the existing step
+// assumes p.mutex is locked by surrounding func
+// LOCKS: graphMutex (R)
+func (sme *StateMutationEngine) recordStep(p *mutationPath) {
+	if p.record == nil {
+		// we may be continuing a path that previously completed or failed
+		if sme.recordStart(p); p.record == nil {
+			return
+		}
+	}
+	edge := p.chain[p.cur]
+	id := fmt.Sprintf("%p", edge)
+	if n := len(p.record.Steps); n > 0 && p.attempt > 1 {
+		if last := p.record.Steps[n-1]; last.Id == id && last.Ended == nil {
+			last.Attempt = p.attempt
+			return
+		}
+	}
+	sme.graphMutex.RLock()
+	m := sme.mutResolver[edge.mut]
+	sme.graphMutex.RUnlock()
+	p.record.Steps = append(p.record.Steps, &pb.MutationStep{
+		Id:       id,
+		Module:   m[0],
+		Mutation: m[1],
+		Attempt:  p.attempt,
+		Started:  ptypes.TimestampNow(),
+	})
+}
+
+// recordStepEnd marks the latest step in a path's history record as ended
+// err should be empty if the step completed as expected
+// assumes p.mutex is locked by surrounding func
+func (sme *StateMutationEngine) recordStepEnd(p *mutationPath, err string) {
+	sme.traceStepEnd(p, err)
+	if p.record == nil || len(p.record.Steps) == 0 {
+		return
+	}
+	last := p.record.Steps[len(p.record.Steps)-1]
+	if last.Ended != nil {
+		return
+	}
+	last.Ended = ptypes.TimestampNow()
+	last.Error = err
+}
+
+// recordEnd finalizes a path's history record and files it in the node's history
+// assumes p.mutex is locked by surrounding func
+// LOCKS: historyMutex
+func (sme *StateMutationEngine) recordEnd(p *mutationPath, outcome pb.MutationPathRecord_Outcome, reason string) {
+	p.ended = true
+	sme.traceEnd(p, outcome, reason)
+	if p.record == nil {
+		return
+	}
+	if outcome != pb.MutationPathRecord_COMPLETE {
+		sme.recordStepEnd(p, reason)
+	}
+	r := p.record
+	p.record = nil
+	now := time.Now()
+	r.Ended, _ = ptypes.TimestampProto(now)
+	if started, e := ptypes.Timestamp(r.Started); e == nil {
+		r.Duration = ptypes.DurationProto(now.Sub(started))
+	}
+	r.Outcome = outcome
+	r.Reason = reason
+
+	sme.historyMutex.Lock()
+	h := append(sme.history[r.Node], r)
+	if len(h) > sme.historyLen {
+		h = h[len(h)-sme.historyLen:]
+	}
+	sme.history[r.Node] = h
+	sme.historyMutex.Unlock()
+}
+
+// traceStart starts the trace span for a path, if it doesn't have one
+// assumes p.mutex is locked by surrounding func
+func (sme *StateMutationEngine) traceStart(p *mutationPath) {
+	if sme.tracer == nil || p.span != nil {
+		return
+	}
+	p.span = sme.tracer.Start("mutation path", "")
+	p.span.SetAttr("kraken.node", p.end.ID().String())
+	if p.gstart != nil {
+		p.span.SetAttr("kraken.start", specLabel(p.gstart.spec))
+	}
+	if p.gend != nil {
+		p.span.SetAttr("kraken.end", specLabel(p.gend.spec))
+	}
+}
+
+// traceWait starts a span for the time a path spends waiting, if we aren't already in one
+// assumes p.mutex is locked by surrounding func
+func (sme *StateMutationEngine) traceWait(p *mutationPath, name string) {
+	if p.span == nil || p.waitSpan != nil {
+		return
+	}
+	p.waitSpan = sme.tracer.Start(name, p.span.Traceparent())
+	if p.waitingOn != "" {
+		p.waitSpan.SetAttr("kraken.waiting", p.waitingOn)
+	}
+}
+
+// traceStep starts a span for the current mutation of a path; retries of the same mutation stay in the same span
+// assumes p.mutex is locked by surrounding func
+// LOCKS: graphMutex (R)
+func (sme *StateMutationEngine) traceStep(p *mutationPath) {
+	if sme.tracer == nil {
+		return
+	}
+	sme.traceStart(p)
+	p.waitSpan.End()
+	p.waitSpan = nil
+	if p.stepSpan != nil && p.attempt > 1 {
+		p.stepSpan.SetAttr("kraken.attempt", strconv.Itoa(int(p.attempt)))
+		return
+	}
+	p.stepSpan.End()
+	sme.graphMutex.RLock()
+	m := sme.mutResolver[p.chain[p.cur].mut]
+	sme.graphMutex.RUnlock()
+	p.stepSpan = sme.tracer.Start("mutation "+m[1], p.span.Traceparent())
+	p.stepSpan.SetAttr("kraken.node", p.end.ID().String())
+	p.stepSpan.SetAttr("kraken.module", m[0])
+	p.stepSpan.SetAttr("kraken.mutation", m[1])
+	p.stepSpan.SetAttr("kraken.attempt", strconv.Itoa(int(p.attempt)))
+}
+
+// traceStepEnd ends the span for the current mutation of a path
+// assumes p.mutex is locked by surrounding func
+func (sme *StateMutationEngine) traceStepEnd(p *mutationPath, err string) {
+	if p.stepSpan == nil {
+		return
+	}
+	if err != "" {
+		p.stepSpan.SetError(err)
+	}
+	p.stepSpan.End()
+	p.stepSpan = nil
+}
+
+// traceEnd ends all of the spans for a path
+// assumes p.mutex is locked by surrounding func
+func (sme *StateMutationEngine) traceEnd(p *mutationPath, outcome pb.MutationPathRecord_Outcome, reason string) {
+	if p.span == nil {
+		return
+	}
+	p.waitSpan.End()
+	p.waitSpan = nil
+	if outcome != pb.MutationPathRecord_COMPLETE {
+		sme.traceStepEnd(p, reason)
+	}
+	p.span.SetAttr("kraken.outcome", pb.MutationPathRecord_Outcome_name[int32(outcome)])
+	p.span.SetAttr("kraken.retries", strconv.Itoa(int(p.retries)))
+	if outcome == pb.MutationPathRecord_FAILED {
+		p.span.SetError(reason)
+	}
+	p.span.End()
+	p.span = nil
+}
+
+// nodeHistory returns the recorded mutation paths for a node, oldest first
+// if the node has an active path, it is included last
+// LOCKS: historyMutex; activeMutex; path.mutex
+func (sme *StateMutationEngine) nodeHistory(n lib.NodeID) (r pb.MutationHistory) {
+	sme.historyMutex.Lock()
+	// filed records are never modified, so we don't need to copy them
+	r.Records = append(r.Records, sme.history[n.String()]...)
+	sme.historyMutex.Unlock()
+
+	sme.activeMutex.Lock()
+	mp := sme.active[n.String()]
+	sme.activeMutex.Unlock()
+	if mp != nil {
+		mp.mutex.Lock()
+		if mp.record != nil {
+			r.Records = append(r.Records, proto.Clone(mp.record).(*pb.MutationPathRecord))
+		}
+		mp.mutex.Unlock()
+	}
+	return
+}
+
+// countStat increments a counter in our stats
+// LOCKS: stats.mutex
+func (sme *StateMutationEngine) countStat(c *uint64) {
+	sme.stats.mutex.Lock()
+	*c++
+	sme.stats.mutex.Unlock()
+}
+
+// observeStep records how long the current mutation of a path took to complete
+// assumes p.mutex is locked by surrounding func
+// LOCKS: graphMutex (R); stats.mutex
+func (sme *StateMutationEngine) observeStep(p *mutationPath) {
+	if p.stepStart.IsZero() {
+		return
+	}
+	sme.graphMutex.RLock()
+	id := sme.mutResolver[p.chain[p.cur].mut]
+	sme.graphMutex.RUnlock()
+	sme.stats.mutex.Lock()
+	h, ok := sme.stats.durations[id]
+	if !ok {
+		h = newMetricHistogram(defaultDurationBuckets)
+		sme.stats.durations[id] = h
+	}
+	sme.stats.mutex.Unlock()
+	h.observe(time.Since(p.stepStart).Seconds())
+}
+
+// Metrics reports on mutation paths, and how far nodes are from their cfg state
+// LOCKS: activeMutex; path.mutex; graphMutex (R); stats.mutex
+func (sme *StateMutationEngine) Metrics() (fams []MetricFamily) {
+	sme.activeMutex.Lock()
+	paths := make([]*mutationPath, 0, len(sme.active))
+	for _, p := range sme.active {
+		paths = append(paths, p)
+	}
+	sme.activeMutex.Unlock()
+	var active, waiting, complete float64
+	for _, p := range paths {
+		p.mutex.Lock()
+		switch {
+		case p.cmplt:
+			complete++
+		case p.waitingFor != "" || p.waitingOn != "":
+			waiting++
+		default:
+			active++
+		}
+		p.mutex.Unlock()
+	}
+	fams = append(fams, MetricFamily{
+		Name: "kraken_sme_paths",
+		Help: "Mutation paths the StateMutationEngine is tracking, by state.",
+		Type: MetricGauge,
+		Metrics: []Metric{
+			{Labels: []MetricLabel{{"state", "active"}}, Value: active},
+			{Labels: []MetricLabel{{"state", "waiting"}}, Value: waiting},
+			{Labels: []MetricLabel{{"state", "complete"}}, Value: complete},
+		},
+	})
+
+	sme.stats.mutex.Lock()
+	counters := []struct {
+		name, help string
+		v          uint64
+	}{
+		{"kraken_sme_paths_completed_total", "Mutation paths that reached their cfg state.", sme.stats.completed},
+		{"kraken_sme_paths_failed_total", "Mutation paths that failed and fell back to their failure state.", sme.stats.failed},
+		{"kraken_sme_mutation_timeouts_total", "Mutations that timed out, including those that were retried.", sme.stats.timeouts},
+		{"kraken_sme_mutation_retries_total", "Mutations that were retried after timing out.", sme.stats.retries},
+	}
+	ids := make([][2]string, 0, len(sme.stats.durations))
+	hists := make(map[[2]string]*metricHistogram, len(sme.stats.durations))
+	for id, h := range sme.stats.durations {
+		ids = append(ids, id)
+		hists[id] = h
+	}
+	sme.stats.mutex.Unlock()
+	for _, c := range counters {
+		fams = append(fams, MetricFamily{Name: c.name, Help: c.help, Type: MetricCounter, Metrics: []Metric{{Value: float64(c.v)}}})
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i][0] == ids[j][0] {
+			return ids[i][1] < ids[j][1]
+		}
+		return ids[i][0] < ids[j][0]
+	})
+	durs := MetricFamily{
+		Name: "kraken_sme_mutation_duration_seconds",
+		Help: "How long mutations take to complete, from when they are first fired, by mutation.",
+		Type: MetricHistogram,
+	}
+	for _, id := range ids {
+		durs.Metrics = append(durs.Metrics, hists[id].metrics([]MetricLabel{{"module", id[0]}, {"mutation", id[1]}})...)
+	}
+	fams = append(fams, durs)
+
+	// how many nodes have dsc values that differ from cfg on URLs we can mutate
+	sme.graphMutex.RLock()
+	urls := make([]string, 0, len(sme.mutators))
+	for u := range sme.mutators {
+		urls = append(urls, u)
+	}
+	sme.graphMutex.RUnlock()
+	var diverged float64
+	cfgs, e1 := sme.query.ReadAll()
+	dscs, e2 := sme.query.ReadAllDsc()
+	if e1 == nil && e2 == nil {
+		dscMap := make(map[string]lib.Node, len(dscs))
+		for _, n := range dscs {
+			dscMap[n.ID().String()] = n
+		}
+		for _, cfg := range cfgs {
+			dsc, ok := dscMap[cfg.ID().String()]
+			if !ok {
+				continue
+			}
+			for _, u := range urls {
+				cv, e1 := cfg.GetValue(u)
+				dv, e2 := dsc.GetValue(u)
+				if e1 != nil || e2 != nil {
+					continue
+				}
+				if !reflect.DeepEqual(cv.Interface(), dv.Interface()) {
+					diverged++
+					break
+				}
+			}
+		}
+	}
+	fams = append(fams, MetricFamily{
+		Name:    "kraken_nodes_diverged",
+		Help:    "Nodes whose discovered state differs from their configured state on a mutable URL.",
+		Type:    MetricGauge,
+		Metrics: []Metric{{Value: diverged}},
+	})
+	return
 }
 
 // LOCKS: graphMutex (R)
-func (sme *StateMutationEngine) emitMutation(cfg lib.Node, dsc lib.Node, sm lib.StateMutation) {
+func (sme *StateMutationEngine) emitMutation(cfg lib.Node, dsc lib.Node, sm lib.StateMutation, attempt uint32, trace string) {
 	sme.graphMutex.RLock()
 	smee := &MutationEvent{
 		Type:     MutationEvent_MUTATE,
 		NodeCfg:  cfg,
 		NodeDsc:  dsc,
 		Mutation: sme.mutResolver[sm],
+		Attempt:  attempt,
+		Trace:    trace,
 	}
 	sme.graphMutex.RUnlock()
 	v := NewEvent(
@@ -1953,12 +3296,7 @@ func (sme *StateMutationEngine) emitMutation(cfg lib.Node, dsc lib.Node, sm lib.
 // This takes the cfg state and merges only discoverable values from dsc state into it
 func (sme *StateMutationEngine) dscNodeMeld(cfg, dsc lib.Node) (r lib.Node) {
 	r = NewNodeFromMessage(cfg.Message().(*pb.Node)) // might be a bit expensive
-	diff := []string{}
-	for si := range Registry.Discoverables {
-		for u := range Registry.Discoverables[si] {
-			diff = append(diff, u)
-		}
-	}
+	diff := Registry.DiscoverableURLs()
 	r.MergeDiff(dsc, diff)
 	return
 }
@@ -21,6 +21,7 @@ import (
 	"reflect"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/hpc/kraken/core/proto"
@@ -143,6 +144,21 @@ type StateSyncEngine struct {
 	parents []string
 	conn    net.PacketConn
 	rpc     ContextRPC
+	stats   *sseStats
+	srv     *grpc.Server
+
+	quit     chan interface{}
+	stopped  chan interface{}
+	stopOnce sync.Once
+}
+
+// sseStats are packet counters kept for metrics; they're updated atomically
+type sseStats struct {
+	sent       uint64
+	received   uint64
+	sendErrors uint64
+	recvErrors uint64 // read and decode failures, including HMAC failures
+	hmacFails  uint64
 }
 
 // NewStateSyncEngine creates a new initialized StateSyncEngine
@@ -158,8 +174,11 @@ func NewStateSyncEngine(ctx Context) *StateSyncEngine {
 		tchan:   make(chan interface{}),
 		log:     &ctx.Logger,
 		self:    ctx.Self,
+		stats:   &sseStats{},
 		parents: ctx.Parents,
 		rpc:     ctx.RPC,
+		quit:    make(chan interface{}),
+		stopped: make(chan interface{}),
 	}
 	sse.log.SetModule("StateSyncEngine")
 	return sse
@@ -171,11 +190,13 @@ func (sse *StateSyncEngine) ID() string                             { return sse
 func (sse *StateSyncEngine) Module() string                         { return sse.Name() }
 func (sse *StateSyncEngine) Start()                                 {} //NOP
 func (sse *StateSyncEngine) Stop()                                  {} //NOP
+func (sse *StateSyncEngine) Kill()                                  {} //NOP
 func (sse *StateSyncEngine) GetState() lib.ServiceState             { return lib.Service_RUN }
 func (sse *StateSyncEngine) UpdateConfig()                          {} //NOP
 func (sse *StateSyncEngine) Watch(chan<- lib.ServiceInstanceUpdate) {} //NOP
 func (sse *StateSyncEngine) SetCtl(chan<- lib.ServiceControl)       {} //NOP
 func (sse *StateSyncEngine) SetSock(string)                         {} //NOP
+func (sse *StateSyncEngine) SetCgroup(string)                       {} //NOP
 
 // implement lib.Module
 func (*StateSyncEngine) Name() string { return "sse" }
@@ -262,7 +283,11 @@ func (sse *StateSyncEngine) Run(ready chan<- interface{}) {
 		lib.Event_STATE_CHANGE,
 		eventFilter,
 		func(v lib.Event) error {
-			return ChanSender(v, echan)
+			select {
+			case echan <- v:
+			case <-sse.quit:
+			}
+			return nil
 		})
 
 	// subscribe to events we care about
@@ -278,6 +303,7 @@ func (sse *StateSyncEngine) Run(ready chan<- interface{}) {
 	sse.Logf(INFO, "sync protocol listening on %s:%s:%d", sse.cfg.Network, sse.cfg.Addr, sse.cfg.Port)
 
 	s := grpc.NewServer()
+	sse.srv = s
 	pb.RegisterStateSyncServer(s, sse)
 	reflection.Register(s)
 	go func(lis net.Listener, s *grpc.Server) {
@@ -330,10 +356,28 @@ func (sse *StateSyncEngine) Run(ready chan<- interface{}) {
 			sse.Logf(DDEBUG, "current queue: %v\n", sse.queue)
 			sse.lock.RUnlock()
 			break
+		case <-sse.quit:
+			unsubscribe(sse.schan, elist)
+			sse.conn.Close()
+			sse.srv.Stop()
+			sse.Log(INFO, "stopped StateSyncEngine")
+			close(sse.stopped)
+			return
 		}
 	}
 }
 
+// Shutdown stops state sync, waiting up to timeout for it to finish what it's doing.
+// (Stop is the lib.ServiceInstance NOP.)  Shutdown must only be called after Run.
+func (sse *StateSyncEngine) Shutdown(timeout time.Duration) {
+	sse.stopOnce.Do(func() { close(sse.quit) })
+	select {
+	case <-sse.stopped:
+	case <-time.After(timeout):
+		sse.Logf(ERROR, "timed out waiting for StateSyncEngine to stop")
+	}
+}
+
 ////////////////////////
 // Unexported methods /
 //////////////////////
@@ -461,6 +505,7 @@ func (sse *StateSyncEngine) ssmToNode(m *pb.StateSyncMessage) (rp recvPacket, e
 	mac.Write(m.Message)
 	nmac := mac.Sum(nil)
 	if !hmac.Equal(m.Hmac, nmac) {
+		atomic.AddUint64(&sse.stats.hmacFails, 1)
 		e = fmt.Errorf("HMAC does not match on packet")
 		return
 	}
@@ -502,16 +547,57 @@ func (sse *StateSyncEngine) send(n *stateSyncNeighbor) {
 	msg, _ := sse.nodeToBinary(n.getID(), node)
 	cnt, e := sse.conn.WriteTo(msg, &net.UDPAddr{IP: ip, Port: sse.cfg.Port})
 	if e != nil {
+		atomic.AddUint64(&sse.stats.sendErrors, 1)
 		sse.Logf(ERROR, "udp write failed: %v", e)
 		return
 	}
 	if cnt != len(msg) {
+		atomic.AddUint64(&sse.stats.sendErrors, 1)
 		sse.Logf(ERROR, "udp write only %d of %d bytes", cnt, len(msg))
 		return
 	}
+	atomic.AddUint64(&sse.stats.sent, 1)
 	n.sent()
 }
 
+// Metrics reports on our neighbors and state sync packets
+// LOCKS: sse.lock (R)
+func (sse *StateSyncEngine) Metrics() (fams []MetricFamily) {
+	var parents, children float64
+	sse.lock.RLock()
+	for _, n := range sse.pool {
+		if n.getParent() {
+			parents++
+		} else {
+			children++
+		}
+	}
+	sse.lock.RUnlock()
+	fams = append(fams, MetricFamily{
+		Name: "kraken_sse_neighbors",
+		Help: "State sync neighbors, by whether they are our parents or children.",
+		Type: MetricGauge,
+		Metrics: []Metric{
+			{Labels: []MetricLabel{{"role", "parent"}}, Value: parents},
+			{Labels: []MetricLabel{{"role", "child"}}, Value: children},
+		},
+	})
+	counters := []struct {
+		name, help string
+		v          *uint64
+	}{
+		{"kraken_sse_packets_sent_total", "State sync packets sent.", &sse.stats.sent},
+		{"kraken_sse_packets_received_total", "State sync packets received and successfully decoded.", &sse.stats.received},
+		{"kraken_sse_send_errors_total", "State sync packets that failed to send.", &sse.stats.sendErrors},
+		{"kraken_sse_receive_errors_total", "State sync packets that failed to be read or decoded, including HMAC failures.", &sse.stats.recvErrors},
+		{"kraken_sse_hmac_failures_total", "State sync packets that failed HMAC verification.", &sse.stats.hmacFails},
+	}
+	for _, c := range counters {
+		fams = append(fams, MetricFamily{Name: c.name, Help: c.help, Type: MetricCounter, Metrics: []Metric{{Value: float64(atomic.LoadUint64(c.v))}}})
+	}
+	return
+}
+
 func (sse *StateSyncEngine) sendDiscoverable(id lib.NodeID)  {}
 func (sse *StateSyncEngine) sendConfiguration(id lib.NodeID) {}
 
@@ -520,18 +606,29 @@ func (sse *StateSyncEngine) listen(c chan<- recvPacket, conn net.PacketConn) {
 	for {
 		cnt, _, e := conn.ReadFrom(buffer)
 		buf := buffer[:cnt]
+		select {
+		case <-sse.quit:
+			return
+		default:
+		}
 		if e != nil {
+			atomic.AddUint64(&sse.stats.recvErrors, 1)
 			sse.Logf(ERROR, "UDP read error: %s\n", e)
 			continue
 		}
 		rp, e := sse.binaryToNode(buf)
 		if e != nil {
+			atomic.AddUint64(&sse.stats.recvErrors, 1)
 			sse.Logf(DEBUG, "node decode failure: %s\n", e)
 			continue
 		}
+		atomic.AddUint64(&sse.stats.received, 1)
 
 		go func(c chan<- recvPacket, rp recvPacket) {
-			c <- rp
+			select {
+			case c <- rp:
+			case <-sse.quit:
+			}
 		}(c, rp)
 	}
 }
@@ -574,7 +671,10 @@ func (sse *StateSyncEngine) wakeForNext() {
 	go func(d time.Duration) {
 		sse.Logf(DDEBUG, "next timer due in: %s\n", d.String())
 		time.Sleep(d)
-		sse.tchan <- nil
+		select {
+		case sse.tchan <- nil:
+		case <-sse.quit:
+		}
 	}(d)
 }
 
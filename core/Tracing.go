@@ -0,0 +1,492 @@
+/* Tracing.go: distributed tracing of mutation chains, with spans exported to files or OTLP collectors
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hpc/kraken/lib"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TraceMetadataKey is the gRPC metadata key (and HTTP header) used to propagate trace context
+const TraceMetadataKey = "traceparent"
+
+// environment variables used to pass trace configuration to module processes
+const (
+	TraceFileEnv = "KRAKEN_TRACE_FILE"
+	TraceOTLPEnv = "KRAKEN_TRACE_OTLP"
+)
+
+// ParseTraceparent splits a W3C traceparent (00-<trace id>-<span id>-<flags>) into its trace and span IDs
+func ParseTraceparent(tp string) (traceID, spanID string, e error) {
+	parts := strings.Split(tp, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", "", fmt.Errorf("malformed traceparent: %s", tp)
+	}
+	for _, p := range parts[1:] {
+		if _, e = hex.DecodeString(p); e != nil {
+			return "", "", fmt.Errorf("malformed traceparent: %s", tp)
+		}
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", "", fmt.Errorf("traceparent has a zero ID: %s", tp)
+	}
+	return parts[1], parts[2], nil
+}
+
+// TraceContextWith adds a traceparent to the outgoing gRPC metadata of a context
+func TraceContextWith(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, TraceMetadataKey, traceparent)
+}
+
+// TraceContextFrom gets the traceparent from the incoming gRPC metadata of a context, if there is one
+func TraceContextFrom(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if tps := md.Get(TraceMetadataKey); len(tps) > 0 {
+		return tps[0]
+	}
+	return ""
+}
+
+func traceRandomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+/////////////////
+// Span Object /
+///////////////
+
+// A Span is a timed operation in a trace.
+// All methods are safe to call on a nil *Span, so callers don't need to check if tracing is enabled.
+// A Span is not safe for concurrent use, except for End; the code that started it should own it.
+type Span struct {
+	Name     string            `json:"name"`
+	Service  string            `json:"service"`
+	TraceID  string            `json:"trace_id"`
+	SpanID   string            `json:"span_id"`
+	ParentID string            `json:"parent_id,omitempty"`
+	Started  time.Time         `json:"started"`
+	Ended    time.Time         `json:"ended"`
+	Attrs    map[string]string `json:"attributes,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	tracer   *Tracer
+	ended    uint32 // atomic; non-zero once End is called
+}
+
+// Traceparent is the W3C traceparent for this span, used to make child spans elsewhere
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + s.TraceID + "-" + s.SpanID + "-01"
+}
+
+// Trace is the ID of the trace this span is part of
+func (s *Span) Trace() string {
+	if s == nil {
+		return ""
+	}
+	return s.TraceID
+}
+
+// SetAttr sets a key/value attribute on the span
+func (s *Span) SetAttr(key, value string) {
+	if s == nil {
+		return
+	}
+	s.Attrs[key] = value
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(err string) {
+	if s == nil {
+		return
+	}
+	s.Error = err
+}
+
+// End finishes the span and queues it for export.  Calling End more than once does nothing.
+func (s *Span) End() {
+	if s == nil || !atomic.CompareAndSwapUint32(&s.ended, 0, 1) {
+		return
+	}
+	s.Ended = time.Now()
+	s.tracer.export(s)
+}
+
+///////////////////
+// Tracer Object /
+/////////////////
+
+// A SpanExporter sends finished spans somewhere.  Export is only ever called from one goroutine at a time.
+type SpanExporter interface {
+	Export([]*Span) error
+	Close() error
+}
+
+// A Tracer makes spans and exports them in batches.
+// All methods are safe to call on a nil *Tracer, which makes nil spans.
+type Tracer struct {
+	service   string
+	exporters []SpanExporter
+	log       lib.Logger
+	queue     chan *Span
+	done      chan interface{}
+	dropped   uint64
+	mutex     *sync.RWMutex // protects closed, so we never export to a closed queue
+	closed    bool
+	closeOnce sync.Once
+}
+
+// NewTracer creates a tracer that exports to the places in cfg.  If cfg doesn't name any, it returns nil, which is a valid, disabled tracer.
+func NewTracer(cfg ContextTrace, log lib.Logger) (t *Tracer, e error) {
+	exps := []SpanExporter{}
+	if cfg.File != "" {
+		var fe SpanExporter
+		if fe, e = newFileSpanExporter(cfg.File); e != nil {
+			return nil, e
+		}
+		exps = append(exps, fe)
+	}
+	if cfg.OTLP != "" {
+		exps = append(exps, newOTLPSpanExporter(cfg.OTLP, cfg.Service))
+	}
+	if len(exps) == 0 {
+		return nil, nil
+	}
+	return NewTracerWithExporters(cfg.Service, log, exps...), nil
+}
+
+// NewTracerFromEnv creates a tracer from the environment variables kraken sets for module processes
+func NewTracerFromEnv(service string, log lib.Logger) (*Tracer, error) {
+	return NewTracer(ContextTrace{
+		File:    os.Getenv(TraceFileEnv),
+		OTLP:    os.Getenv(TraceOTLPEnv),
+		Service: service,
+	}, log)
+}
+
+// NewTracerWithExporters creates a tracer that exports to a specific set of exporters
+func NewTracerWithExporters(service string, log lib.Logger, exps ...SpanExporter) *Tracer {
+	t := &Tracer{
+		service:   service,
+		exporters: exps,
+		log:       log,
+		queue:     make(chan *Span, 4096),
+		done:      make(chan interface{}),
+		mutex:     &sync.RWMutex{},
+	}
+	go t.run()
+	return t
+}
+
+// Start starts a new span.  If parent is a valid traceparent, the span is its child; otherwise it starts a new trace.
+func (t *Tracer) Start(name, parent string) *Span {
+	if t == nil {
+		return nil
+	}
+	s := &Span{
+		Name:    name,
+		Service: t.service,
+		SpanID:  traceRandomID(8),
+		Started: time.Now(),
+		Attrs:   map[string]string{},
+		tracer:  t,
+	}
+	if parent != "" {
+		var e error
+		if s.TraceID, s.ParentID, e = ParseTraceparent(parent); e != nil {
+			t.log.Logf(DEBUG, "starting a new trace for %s: %v", name, e)
+		}
+	}
+	if s.TraceID == "" {
+		s.TraceID = traceRandomID(16)
+	}
+	return s
+}
+
+// Dropped is how many spans were dropped because the exporters couldn't keep up
+func (t *Tracer) Dropped() uint64 {
+	if t == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&t.dropped)
+}
+
+// Close exports any queued spans and closes the exporters.
+// Spans that end after Close are dropped, and calling Close more than once does nothing.
+func (t *Tracer) Close() {
+	if t == nil {
+		return
+	}
+	t.closeOnce.Do(func() {
+		t.mutex.Lock()
+		t.closed = true
+		close(t.queue)
+		t.mutex.Unlock()
+		<-t.done
+		for _, x := range t.exporters {
+			if e := x.Close(); e != nil {
+				t.log.Logf(ERROR, "failed to close span exporter: %v", e)
+			}
+		}
+	})
+}
+
+// UnaryServerInterceptor makes a span for every gRPC call that carries a traceparent in its metadata
+func (t *Tracer) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	tp := TraceContextFrom(ctx)
+	if t == nil || tp == "" {
+		return handler(ctx, req)
+	}
+	s := t.Start(info.FullMethod, tp)
+	s.SetAttr("rpc.method", info.FullMethod)
+	r, e := handler(ctx, req)
+	if e != nil {
+		s.SetError(e.Error())
+	}
+	s.End()
+	return r, e
+}
+
+// Metrics reports on spans we couldn't export
+func (t *Tracer) Metrics() []MetricFamily {
+	return []MetricFamily{{
+		Name:    "kraken_trace_spans_dropped_total",
+		Help:    "Spans dropped because the span exporters couldn't keep up.",
+		Type:    MetricCounter,
+		Metrics: []Metric{{Value: float64(t.Dropped())}},
+	}}
+}
+
+////////////////////////
+// Unexported methods /
+//////////////////////
+
+func (t *Tracer) export(s *Span) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.queue <- s:
+	default:
+		if atomic.AddUint64(&t.dropped, 1) == 1 {
+			t.log.Logf(ERROR, "span exporters can't keep up, dropping spans")
+		}
+	}
+}
+
+// goroutine
+// run batches spans, and exports them when a batch is full or once a second
+func (t *Tracer) run() {
+	defer close(t.done)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	batch := []*Span{}
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		for _, x := range t.exporters {
+			if e := x.Export(batch); e != nil {
+				t.log.Logf(ERROR, "failed to export %d spans: %v", len(batch), e)
+			}
+		}
+		batch = []*Span{}
+	}
+	for {
+		select {
+		case s, ok := <-t.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if batch = append(batch, s); len(batch) >= 512 {
+				flush()
+			}
+		case <-tick.C:
+			flush()
+		}
+	}
+}
+
+/*
+ * JSON-lines file exporter
+ */
+
+var _ SpanExporter = (*fileSpanExporter)(nil)
+
+// fileSpanExporter appends spans to a file, one JSON object per line.
+// Each batch is one write, so processes can share a file.
+type fileSpanExporter struct {
+	f *os.File
+}
+
+func newFileSpanExporter(path string) (*fileSpanExporter, error) {
+	f, e := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if e != nil {
+		return nil, fmt.Errorf("could not open trace file: %v", e)
+	}
+	return &fileSpanExporter{f: f}, nil
+}
+
+func (x *fileSpanExporter) Export(spans []*Span) (e error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	for _, s := range spans {
+		if e = enc.Encode(s); e != nil {
+			return
+		}
+	}
+	_, e = x.f.Write(buf.Bytes())
+	return
+}
+
+func (x *fileSpanExporter) Close() error { return x.f.Close() }
+
+/*
+ * OTLP/HTTP (JSON) exporter
+ */
+
+var _ SpanExporter = (*otlpSpanExporter)(nil)
+
+// otlpSpanExporter POSTs spans to an OpenTelemetry collector's /v1/traces endpoint, using the JSON encoding of OTLP
+type otlpSpanExporter struct {
+	url     string
+	service string
+	client  *http.Client
+}
+
+func newOTLPSpanExporter(endpoint, service string) *otlpSpanExporter {
+	return &otlpSpanExporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// OTLP span kind and status codes
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusOk         = 1
+	otlpStatusError      = 2
+)
+
+func (x *otlpSpanExporter) Export(spans []*Span) (e error) {
+	ss := otlpScopeSpans{}
+	ss.Scope.Name = "github.com/hpc/kraken"
+	for _, s := range spans {
+		sp := otlpSpan{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentID,
+			Name:              s.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Started.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.Ended.UnixNano(), 10),
+			Status:            otlpStatus{Code: otlpStatusOk},
+		}
+		for k, v := range s.Attrs {
+			sp.Attributes = append(sp.Attributes, otlpKeyValue{Key: k, Value: otlpValue{v}})
+		}
+		if s.Error != "" {
+			sp.Status = otlpStatus{Code: otlpStatusError, Message: s.Error}
+		}
+		ss.Spans = append(ss.Spans, sp)
+	}
+	rs := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{ss}}
+	rs.Resource.Attributes = []otlpKeyValue{{Key: "service.name", Value: otlpValue{x.service}}}
+	body, e := json.Marshal(&otlpRequest{ResourceSpans: []otlpResourceSpans{rs}})
+	if e != nil {
+		return
+	}
+	resp, e := x.client.Post(x.url, "application/json", bytes.NewReader(body))
+	if e != nil {
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("trace collector returned %s", resp.Status)
+	}
+	return
+}
+
+func (x *otlpSpanExporter) Close() error { return nil }
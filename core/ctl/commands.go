@@ -0,0 +1,750 @@
+/* commands.go: krakenctl's commands
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package ctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/lib"
+
+	pb "github.com/hpc/kraken/core/proto"
+)
+
+func init() {
+	commands = map[string]command{
+		"get":      {"get [-dsc] [<node> [<url>]]", "show all nodes, a node, or one value of a node", (*Ctl).get},
+		"set":      {"set [-dsc] <node> <url> <value> | set [-dsc] -f <file>", "set a value (JSON, or a bare string) of a node, or update nodes from a JSON node or node list (- is stdin)", (*Ctl).set},
+		"diff":     {"diff [<selector>...]", "show where cfg and dsc differ (default: all nodes)", (*Ctl).diff},
+		"power":    {"power [-wait] on|off|cycle <selector>...", "power nodes on, off, or off and back on", (*Ctl).power},
+		"freeze":   {"freeze", "freeze the state mutation engine", (*Ctl).freeze},
+		"thaw":     {"thaw", "thaw the state mutation engine", (*Ctl).thaw},
+		"frozen":   {"frozen", "show whether the state mutation engine is frozen", (*Ctl).frozen},
+		"path":     {"path <node>", "show a node's current mutation path", (*Ctl).path},
+		"graph":    {"graph [<node>]", "show the mutation graph, or the part of it a node can use", (*Ctl).graph},
+		"events":   {"events [-from <seq>] [-types <type>,...] [<selector>...]", "tail events, optionally replaying journaled events and only about some nodes", (*Ctl).events},
+		"services": {"services [<selector>...]", "list services and their cfg and dsc states (default: all nodes with services)", (*Ctl).services},
+		"service":  {"service start|stop|restart <service> | service config <service> <file>", "start, stop or restart a service of the kraken we talk to, or set its config from JSON (- is stdin)", (*Ctl).service},
+		"help":     {"help", "show this help", (*Ctl).help},
+	}
+}
+
+func (c *Ctl) help(ctx context.Context, args []string) error {
+	usage(c.Out)
+	return nil
+}
+
+// get shows nodes or values
+func (c *Ctl) get(ctx context.Context, args []string) error {
+	fs := c.flags("get")
+	dsc := fs.Bool("dsc", false, "")
+	if e := parseFlags(fs, args); e != nil {
+		return e
+	}
+	args = fs.Args()
+	switch len(args) {
+	case 0:
+		ns, e := c.readAll(*dsc)
+		if e != nil {
+			return e
+		}
+		return c.print(nodesResult(ns))
+	case 1:
+		id, e := c.resolve(args[0])
+		if e != nil {
+			return e
+		}
+		n, e := c.read(id, *dsc)
+		if e != nil {
+			return e
+		}
+		r := nodesResult([]lib.Node{n})
+		r.data = rawJSON(n.JSON())
+		return c.print(r)
+	case 2:
+		id, e := c.resolve(args[0])
+		if e != nil {
+			return e
+		}
+		n, e := c.read(id, *dsc)
+		if e != nil {
+			return e
+		}
+		return c.printValue(n, args[1])
+	}
+	return fmt.Errorf("usage: %s", commands["get"].usage)
+}
+
+func nodesResult(ns []lib.Node) *result {
+	r := &result{
+		header: []string{"ID", "NODENAME", "PHYSSTATE", "RUNSTATE"},
+	}
+	data := []interface{}{}
+	for _, n := range ns {
+		data = append(data, rawJSON(n.JSON()))
+		r.rows = append(r.rows, []string{n.ID().String(), valueString(n, "/Nodename"), valueString(n, "/PhysState"), valueString(n, "/RunState")})
+	}
+	r.data = data
+	return r
+}
+
+// printValue prints one value of a node
+func (c *Ctl) printValue(n lib.Node, url string) error {
+	url = fixURL(url)
+	v, e := n.GetValue(url)
+	if e != nil {
+		return e
+	}
+	b, e := core.MarshalValueJSON(v)
+	if e != nil {
+		return e
+	}
+	r := &result{
+		data:   rawJSON(b),
+		header: []string{"ID", "URL", "VALUE"},
+		rows:   [][]string{{n.ID().String(), url, lib.ValueToString(v)}},
+	}
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+		// messages and lists are easier to read as JSON
+		r.rows[0][2] = string(compactJSON(b))
+	}
+	return c.print(r)
+}
+
+// fixURL lets URLs be given without the leading /
+func fixURL(url string) string {
+	if strings.HasPrefix(url, "/") || strings.HasPrefix(url, "type.googleapis.com") {
+		return url
+	}
+	return "/" + url
+}
+
+func compactJSON(b []byte) []byte {
+	var v interface{}
+	if json.Unmarshal(b, &v) != nil {
+		return b
+	}
+	cb, _ := json.Marshal(v)
+	return cb
+}
+
+// set sets a value, or whole nodes
+func (c *Ctl) set(ctx context.Context, args []string) error {
+	fs := c.flags("set")
+	dsc := fs.Bool("dsc", false, "")
+	file := fs.String("f", "", "")
+	if e := parseFlags(fs, args); e != nil {
+		return e
+	}
+	args = fs.Args()
+	update := c.API.QueryUpdate
+	if *dsc {
+		update = c.API.QueryUpdateDsc
+	}
+
+	if *file != "" {
+		if len(args) > 0 {
+			return fmt.Errorf("usage: %s", commands["set"].usage)
+		}
+		ns, e := readNodes(*file)
+		if e != nil {
+			return e
+		}
+		var updated []lib.Node
+		for _, n := range ns {
+			nn, e := update(n)
+			if e != nil {
+				return fmt.Errorf("%s: %v", n.ID().String(), e)
+			}
+			updated = append(updated, nn)
+		}
+		return c.print(nodesResult(updated))
+	}
+
+	if len(args) != 3 {
+		return fmt.Errorf("usage: %s", commands["set"].usage)
+	}
+	id, e := c.resolve(args[0])
+	if e != nil {
+		return e
+	}
+	n, e := c.read(id, *dsc)
+	if e != nil {
+		return e
+	}
+	url := fixURL(args[1])
+	cur, e := n.GetValue(url)
+	if e != nil {
+		return e
+	}
+	v, e := parseValue(args[2], cur.Type())
+	if e != nil {
+		return fmt.Errorf("bad value for %s: %v", url, e)
+	}
+	if _, e = n.SetValue(url, v); e != nil {
+		return e
+	}
+	nn, e := update(n)
+	if e != nil {
+		return e
+	}
+	return c.printValue(nn, url)
+}
+
+// parseValue parses a value as JSON; if that fails, a bare string (e.g. POWER_ON) is taken as a JSON string
+func parseValue(s string, t reflect.Type) (reflect.Value, error) {
+	v, e := core.UnmarshalValueJSON([]byte(s), t)
+	if e == nil {
+		return v, nil
+	}
+	if !json.Valid([]byte(s)) {
+		q, _ := json.Marshal(s)
+		return core.UnmarshalValueJSON(q, t)
+	}
+	return v, e
+}
+
+// readNodes reads a JSON node, or node list, from a file (or stdin for "-")
+func readNodes(file string) ([]lib.Node, error) {
+	b, e := readFile(file)
+	if e != nil {
+		return nil, e
+	}
+	var nl pb.NodeList
+	if core.UnmarshalJSON(b, &nl) == nil && len(nl.Nodes) > 0 {
+		var ns []lib.Node
+		for _, m := range nl.Nodes {
+			ns = append(ns, core.NewNodeFromMessage(m))
+		}
+		return ns, nil
+	}
+	var m pb.Node
+	if e = core.UnmarshalJSON(b, &m); e != nil {
+		return nil, fmt.Errorf("%s is neither a node nor a node list: %v", file, e)
+	}
+	return []lib.Node{core.NewNodeFromMessage(&m)}, nil
+}
+
+// readFile reads a file, or stdin for "-"
+func readFile(file string) ([]byte, error) {
+	if file == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(file)
+}
+
+// diff shows where cfg and dsc differ
+func (c *Ctl) diff(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		args = []string{"all"}
+	}
+	ns, e := c.selectNodes(args, false)
+	if e != nil {
+		return e
+	}
+	type diff struct {
+		ID  string      `json:"id"`
+		URL string      `json:"url"`
+		Cfg interface{} `json:"cfg"`
+		Dsc interface{} `json:"dsc"`
+	}
+	r := &result{
+		header: []string{"ID", "URL", "CFG", "DSC"},
+	}
+	data := []diff{}
+	for _, n := range ns {
+		dn, e := c.API.QueryReadDsc(n.ID().String())
+		if e != nil {
+			return e
+		}
+		urls, e := n.Diff(dn, "")
+		if e != nil {
+			return e
+		}
+		sort.Strings(urls)
+		for _, u := range urls {
+			d := diff{ID: n.ID().String(), URL: u}
+			cv, _ := n.GetValue(u)
+			dv, _ := dn.GetValue(u)
+			if b, e := core.MarshalValueJSON(cv); e == nil {
+				d.Cfg = rawJSON(b)
+			}
+			if b, e := core.MarshalValueJSON(dv); e == nil {
+				d.Dsc = rawJSON(b)
+			}
+			data = append(data, d)
+			r.rows = append(r.rows, []string{d.ID, u, jsonString(d.Cfg), jsonString(d.Dsc)})
+		}
+	}
+	r.data = data
+	return c.print(r)
+}
+
+// jsonString prints a decoded JSON value compactly, and strings without quotes
+func jsonString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	if v == nil {
+		return ""
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// power sets the physical state of nodes, and waits for cycles (or everything, with -wait)
+func (c *Ctl) power(ctx context.Context, args []string) error {
+	fs := c.flags("power")
+	wait := fs.Bool("wait", false, "")
+	if e := parseFlags(fs, args); e != nil {
+		return e
+	}
+	args = fs.Args()
+	if len(args) < 2 {
+		return fmt.Errorf("usage: %s", commands["power"].usage)
+	}
+	ns, e := c.selectNodes(args[1:], false)
+	if e != nil {
+		return e
+	}
+	switch args[0] {
+	case "on":
+		e = c.setPower(ctx, ns, pb.Node_POWER_ON, *wait)
+	case "off":
+		e = c.setPower(ctx, ns, pb.Node_POWER_OFF, *wait)
+	case "cycle":
+		if e = c.setPower(ctx, ns, pb.Node_POWER_OFF, true); e == nil {
+			e = c.setPower(ctx, ns, pb.Node_POWER_ON, *wait)
+		}
+	default:
+		return fmt.Errorf("unknown power operation: %s; usage: %s", args[0], commands["power"].usage)
+	}
+	if e != nil {
+		return e
+	}
+	r := &result{
+		header: []string{"ID", "NODENAME", "PHYSSTATE"},
+	}
+	type power struct {
+		ID        string `json:"id"`
+		Nodename  string `json:"nodename,omitempty"`
+		PhysState string `json:"physState"`
+	}
+	data := []power{}
+	for _, n := range ns {
+		dn, e := c.API.QueryReadDsc(n.ID().String())
+		if e != nil {
+			return e
+		}
+		p := power{n.ID().String(), valueString(n, "/Nodename"), valueString(dn, "/PhysState")}
+		data = append(data, p)
+		r.rows = append(r.rows, []string{p.ID, p.Nodename, p.PhysState})
+	}
+	r.data = data
+	return c.print(r)
+}
+
+// setPower sets the cfg PhysState of nodes, and optionally waits for their dsc PhysState to match
+func (c *Ctl) setPower(ctx context.Context, ns []lib.Node, ps pb.Node_PhysState, wait bool) error {
+	for _, n := range ns {
+		cn, e := c.API.QueryRead(n.ID().String())
+		if e != nil {
+			return e
+		}
+		if _, e = cn.SetValue("/PhysState", reflect.ValueOf(ps)); e != nil {
+			return e
+		}
+		if _, e = c.API.QueryUpdate(cn); e != nil {
+			return fmt.Errorf("%s: %v", nodeName(n), e)
+		}
+	}
+	if !wait {
+		return nil
+	}
+	return c.waitFor(ctx, ns, "/PhysState", reflect.ValueOf(ps))
+}
+
+// waitFor waits until the dsc value of url is v for every node, or we time out
+func (c *Ctl) waitFor(ctx context.Context, ns []lib.Node, url string, v reflect.Value) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	waiting := map[string]lib.Node{}
+	for _, n := range ns {
+		waiting[n.ID().String()] = n
+	}
+	for {
+		for id := range waiting {
+			dn, e := c.API.QueryReadDsc(id)
+			if e != nil {
+				return e
+			}
+			if dv, e := dn.GetValue(url); e == nil && dv.Interface() == v.Interface() {
+				delete(waiting, id)
+			}
+		}
+		if len(waiting) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			var names []string
+			for _, n := range waiting {
+				names = append(names, nodeName(n))
+			}
+			sort.Strings(names)
+			return fmt.Errorf("gave up waiting for %s to be %s on: %s", url, lib.ValueToString(v), strings.Join(names, ", "))
+		case <-time.After(c.Poll):
+		}
+	}
+}
+
+func (c *Ctl) freeze(ctx context.Context, args []string) error {
+	if e := c.API.QueryFreeze(); e != nil {
+		return e
+	}
+	return c.printFrozen(true)
+}
+
+func (c *Ctl) thaw(ctx context.Context, args []string) error {
+	if e := c.API.QueryThaw(); e != nil {
+		return e
+	}
+	return c.printFrozen(false)
+}
+
+func (c *Ctl) frozen(ctx context.Context, args []string) error {
+	f, e := c.API.QueryFrozen()
+	if e != nil {
+		return e
+	}
+	return c.printFrozen(f)
+}
+
+func (c *Ctl) printFrozen(f bool) error {
+	return c.print(&result{
+		data:   map[string]bool{"frozen": f},
+		header: []string{"FROZEN"},
+		rows:   [][]string{{strconv.FormatBool(f)}},
+	})
+}
+
+// specLabels maps mutation graph node IDs to single line labels; a spec that requires nothing is "(any)"
+func specLabels(nl pb.MutationNodeList) map[string]string {
+	labels := map[string]string{}
+	for _, mn := range nl.MutationNodeList {
+		labels[mn.Id] = strings.Replace(mn.Label, "\n", ", ", -1)
+		if labels[mn.Id] == "" {
+			labels[mn.Id] = "(any)"
+		}
+	}
+	return labels
+}
+
+// path shows a node's current mutation path
+func (c *Ctl) path(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s", commands["path"].usage)
+	}
+	id, e := c.resolve(args[0])
+	if e != nil {
+		return e
+	}
+	p, e := c.API.QueryNodeMutationPath(id)
+	if e != nil {
+		return e
+	}
+	nodes, e := c.API.QueryNodeMutationNodes(id)
+	if e != nil {
+		return e
+	}
+	labels := specLabels(nodes)
+	type step struct {
+		Step   int    `json:"step"`
+		From   string `json:"from"`
+		To     string `json:"to"`
+		Status string `json:"status"`
+	}
+	path := struct {
+		Complete bool   `json:"complete"`
+		Attempt  uint32 `json:"attempt,omitempty"`
+		Retries  uint32 `json:"retries,omitempty"`
+		Waiting  string `json:"waiting,omitempty"`
+		Steps    []step `json:"steps"`
+	}{
+		Complete: p.Cmplt,
+		Attempt:  p.Attempt,
+		Retries:  p.Retries,
+		Waiting:  p.Waiting,
+		Steps:    []step{},
+	}
+	r := &result{
+		header: []string{"STEP", "FROM", "TO", "STATUS"},
+	}
+	for i, me := range p.Chain {
+		s := step{Step: i + 1, From: labels[me.From], To: labels[me.To]}
+		switch {
+		case p.Cmplt || int64(i) < p.Cur:
+			s.Status = "done"
+		case int64(i) == p.Cur:
+			s.Status = "current"
+			if p.Waiting != "" {
+				s.Status = "waiting on " + p.Waiting
+			}
+		default:
+			s.Status = "pending"
+		}
+		path.Steps = append(path.Steps, s)
+		r.rows = append(r.rows, []string{strconv.Itoa(s.Step), s.From, s.To, s.Status})
+	}
+	r.data = path
+	return c.print(r)
+}
+
+// graph shows the edges of the mutation graph
+func (c *Ctl) graph(ctx context.Context, args []string) error {
+	var nodes pb.MutationNodeList
+	var edges pb.MutationEdgeList
+	var e error
+	switch len(args) {
+	case 0:
+		if nodes, e = c.API.QueryMutationNodes(); e != nil {
+			return e
+		}
+		edges, e = c.API.QueryMutationEdges()
+	case 1:
+		id, e := c.resolve(args[0])
+		if e != nil {
+			return e
+		}
+		if nodes, e = c.API.QueryNodeMutationNodes(id); e != nil {
+			return e
+		}
+		edges, e = c.API.QueryNodeMutationEdges(id)
+	default:
+		return fmt.Errorf("usage: %s", commands["graph"].usage)
+	}
+	if e != nil {
+		return e
+	}
+	labels := specLabels(nodes)
+	type edge struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	data := []edge{}
+	r := &result{
+		header: []string{"FROM", "TO"},
+	}
+	for _, me := range edges.MutationEdgeList {
+		data = append(data, edge{labels[me.From], labels[me.To]})
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].From != data[j].From {
+			return data[i].From < data[j].From
+		}
+		return data[i].To < data[j].To
+	})
+	for _, ed := range data {
+		r.rows = append(r.rows, []string{ed.From, ed.To})
+	}
+	r.data = data
+	return c.print(r)
+}
+
+// events tails events until ctx is done; they're printed one per line (or YAML document) as they come
+func (c *Ctl) events(ctx context.Context, args []string) error {
+	fs := c.flags("events")
+	from := fs.Uint64("from", 0, "")
+	types := fs.String("types", "", "")
+	if e := parseFlags(fs, args); e != nil {
+		return e
+	}
+	var ets []lib.EventType
+	if *types != "" {
+		for _, t := range strings.Split(*types, ",") {
+			et, ok := lib.EventTypeValue[strings.ToUpper(strings.TrimSpace(t))]
+			if !ok {
+				return fmt.Errorf("unknown event type: %s", t)
+			}
+			ets = append(ets, et)
+		}
+	}
+	ids := map[string]bool{}
+	if len(fs.Args()) > 0 {
+		ns, e := c.selectNodes(fs.Args(), false)
+		if e != nil {
+			return e
+		}
+		for _, n := range ns {
+			ids[n.ID().String()] = true
+		}
+	}
+	ch, e := c.API.Watch(ctx, *from, ets)
+	if e != nil {
+		return e
+	}
+	type event struct {
+		Seq  uint64 `json:"seq"`
+		Type string `json:"type"`
+		URL  string `json:"url"`
+		Data string `json:"data,omitempty"`
+	}
+	for v := range ch {
+		if len(ids) > 0 {
+			id, _ := lib.NodeURLSplit(v.URL())
+			if !ids[id] {
+				continue
+			}
+		}
+		ev := event{v.Seq(), lib.EventTypeString[v.Type()], v.URL(), ""}
+		if v.Data() != nil {
+			ev.Data = fmt.Sprintf("%v", v.Data())
+		}
+		var e error
+		switch c.Format {
+		case FormatJSON:
+			b, _ := json.Marshal(ev)
+			_, e = fmt.Fprintf(c.Out, "%s\n", b)
+		case FormatYAML:
+			b, _ := toYAML(ev)
+			_, e = fmt.Fprintf(c.Out, "---\n%s", b)
+		default:
+			_, e = fmt.Fprintf(c.Out, "%d\t%s\t%s\t%s\n", ev.Seq, ev.Type, ev.URL, ev.Data)
+		}
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// services lists services, with their cfg and dsc states
+func (c *Ctl) services(ctx context.Context, args []string) error {
+	all := len(args) == 0
+	if all {
+		args = []string{"all"}
+	}
+	ns, e := c.selectNodes(args, false)
+	if e != nil {
+		return e
+	}
+	type service struct {
+		Node   string `json:"node"`
+		ID     string `json:"id"`
+		Module string `json:"module"`
+		Cfg    string `json:"cfg"`
+		Dsc    string `json:"dsc"`
+		Error  string `json:"error,omitempty"`
+	}
+	data := []service{}
+	r := &result{
+		header: []string{"NODE", "SERVICE", "MODULE", "CFG", "DSC", "ERROR"},
+	}
+	for _, n := range ns {
+		srvs := n.GetServices()
+		if len(srvs) == 0 {
+			continue
+		}
+		dn, e := c.API.QueryReadDsc(n.ID().String())
+		if e != nil {
+			return e
+		}
+		sort.Slice(srvs, func(i, j int) bool { return srvs[i].Id < srvs[j].Id })
+		for _, s := range srvs {
+			sv := service{
+				Node:   nodeName(n),
+				ID:     s.Id,
+				Module: path.Base(s.Module),
+				Cfg:    s.State.String(),
+			}
+			if ds := dn.GetService(s.Id); ds != nil {
+				sv.Dsc = ds.State.String()
+				sv.Error = ds.ErrorMsg
+			}
+			data = append(data, sv)
+			r.rows = append(r.rows, []string{sv.Node, sv.ID, sv.Module, sv.Cfg, sv.Dsc, sv.Error})
+		}
+	}
+	r.data = data
+	return c.print(r)
+}
+
+// service controls one of the services of the kraken we talk to
+func (c *Ctl) service(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: %s", commands["service"].usage)
+	}
+	var st *pb.ServiceStatus
+	var e error
+	switch args[0] {
+	case "start", "stop", "restart":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: %s", commands["service"].usage)
+		}
+		switch args[0] {
+		case "start":
+			st, e = c.API.ServiceStart(args[1])
+		case "stop":
+			st, e = c.API.ServiceStop(args[1])
+		case "restart":
+			st, e = c.API.ServiceRestart(args[1])
+		}
+	case "config":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: %s", commands["service"].usage)
+		}
+		if st, e = c.API.ServiceGet(args[1]); e != nil {
+			return e
+		}
+		b, e := readFile(args[2])
+		if e != nil {
+			return e
+		}
+		cfg, e := core.UnmarshalConfigJSON(st.GetModule(), b)
+		if e != nil {
+			return fmt.Errorf("bad config: %v", e)
+		}
+		if st, e = c.API.ServiceConfigure(args[1], cfg); e != nil {
+			return e
+		}
+	default:
+		return fmt.Errorf("unknown service operation: %s; usage: %s", args[0], commands["service"].usage)
+	}
+	if e != nil {
+		return e
+	}
+	b, e := core.MarshalJSON(st)
+	if e != nil {
+		return e
+	}
+	return c.print(&result{
+		data:   rawJSON(b),
+		header: []string{"SERVICE", "MODULE", "CFG", "DSC", "ERROR"},
+		rows:   [][]string{{st.GetId(), path.Base(st.GetModule()), st.GetCfg().String(), st.GetDsc().String(), st.GetErrorMsg()}},
+	})
+}
+
+// globMatch matches a nodename against a selector; bad patterns only match themselves
+func globMatch(pattern, name string) bool {
+	if ok, e := path.Match(pattern, name); e == nil {
+		return ok
+	}
+	return pattern == name
+}
@@ -0,0 +1,358 @@
+/* ctl.go: krakenctl, a command-line client for kraken's API
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+// Package ctl implements krakenctl.  It is built by kraken-build (with -ctl),
+// so the extensions and modules of a build are compiled in, but it can run against any lib.APIClient.
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/lib"
+)
+
+// Formats are how results are printed
+type Format uint8
+
+const (
+	FormatTable Format = iota
+	FormatJSON
+	FormatYAML
+)
+
+var FormatString = map[Format]string{
+	FormatTable: "table",
+	FormatJSON:  "json",
+	FormatYAML:  "yaml",
+}
+
+var FormatValue = map[string]Format{
+	"table": FormatTable,
+	"json":  FormatJSON,
+	"yaml":  FormatYAML,
+}
+
+// A Ctl runs krakenctl commands with an API client
+type Ctl struct {
+	API     lib.APIClient
+	Out     io.Writer
+	Format  Format
+	Timeout time.Duration // how long to wait for nodes, e.g. to power off during a power cycle
+	Poll    time.Duration // how often to check on nodes we're waiting for
+}
+
+// New creates a Ctl that prints tables to out
+func New(api lib.APIClient, out io.Writer) *Ctl {
+	return &Ctl{
+		API:     api,
+		Out:     out,
+		Format:  FormatTable,
+		Timeout: 5 * time.Minute,
+		Poll:    time.Second,
+	}
+}
+
+// A command is a krakenctl subcommand
+type command struct {
+	usage   string
+	summary string
+	run     func(c *Ctl, ctx context.Context, args []string) error
+}
+
+// commands is filled in by init, since help refers to it
+var commands map[string]command
+
+// Run runs one command line, e.g. {"get", "n0", "/PhysState"}.
+// Streaming commands (events) run until ctx is done.
+func (c *Ctl) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command given; try \"help\"")
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command: %s; try \"help\"", args[0])
+	}
+	return cmd.run(c, ctx, args[1:])
+}
+
+// flags makes a flag set for a command; its errors are returned, not printed
+func (c *Ctl) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if e := fs.Parse(args); e != nil {
+		return fmt.Errorf("%s: %v; usage: %s", fs.Name(), e, commands[fs.Name()].usage)
+	}
+	return nil
+}
+
+/*
+ * Output
+ */
+
+// A result is what a command prints: data for JSON and YAML, and the same as rows for tables
+type result struct {
+	data   interface{}
+	header []string
+	rows   [][]string
+}
+
+// print prints a result in our format
+func (c *Ctl) print(r *result) error {
+	switch c.Format {
+	case FormatJSON:
+		b, e := json.MarshalIndent(r.data, "", "  ")
+		if e != nil {
+			return e
+		}
+		_, e = fmt.Fprintf(c.Out, "%s\n", b)
+		return e
+	case FormatYAML:
+		b, e := toYAML(r.data)
+		if e != nil {
+			return e
+		}
+		_, e = c.Out.Write(b)
+		return e
+	}
+	tw := tabwriter.NewWriter(c.Out, 0, 4, 2, ' ', 0)
+	if r.header != nil {
+		fmt.Fprintln(tw, strings.Join(r.header, "\t"))
+	}
+	for _, row := range r.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// toYAML marshals data as YAML, by way of JSON so json tags (and proto JSON) are respected
+func toYAML(data interface{}) ([]byte, error) {
+	b, e := json.Marshal(data)
+	if e != nil {
+		return nil, e
+	}
+	var v interface{}
+	if e = yaml.Unmarshal(b, &v); e != nil {
+		return nil, e
+	}
+	return yaml.Marshal(v)
+}
+
+// rawJSON decodes JSON (e.g. from Node.JSON) so it can be part of a result
+func rawJSON(b []byte) interface{} {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if d.Decode(&v) != nil {
+		return string(b)
+	}
+	return v
+}
+
+/*
+ * Nodes
+ */
+
+// nodeName is a node's name, or its ID if it doesn't have one
+func nodeName(n lib.Node) string {
+	if v, e := n.GetValue("/Nodename"); e == nil && v.String() != "" {
+		return v.String()
+	}
+	return n.ID().String()
+}
+
+func valueString(n lib.Node, url string) string {
+	v, e := n.GetValue(url)
+	if e != nil || !v.IsValid() {
+		return ""
+	}
+	return lib.ValueToString(v)
+}
+
+// readAll reads every node, cfg or dsc
+func (c *Ctl) readAll(dsc bool) ([]lib.Node, error) {
+	var ns []lib.Node
+	var e error
+	if dsc {
+		ns, e = c.API.QueryReadAllDsc()
+	} else {
+		ns, e = c.API.QueryReadAll()
+	}
+	if e != nil {
+		return nil, e
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i].ID().String() < ns[j].ID().String() })
+	return ns, nil
+}
+
+// read reads one node, cfg or dsc
+func (c *Ctl) read(id string, dsc bool) (lib.Node, error) {
+	if dsc {
+		return c.API.QueryReadDsc(id)
+	}
+	return c.API.QueryRead(id)
+}
+
+// selectNodes finds the nodes a selector matches.
+// Each selector is "all", a node ID, or a nodename, which can be a glob (e.g. "n[0-9]*").
+func (c *Ctl) selectNodes(selectors []string, dsc bool) ([]lib.Node, error) {
+	if len(selectors) == 0 {
+		return nil, fmt.Errorf("no nodes selected")
+	}
+	ns, e := c.readAll(dsc)
+	if e != nil {
+		return nil, e
+	}
+	var sel []lib.Node
+	seen := map[string]bool{}
+	for _, s := range selectors {
+		found := false
+		for _, n := range ns {
+			id := n.ID().String()
+			if !(s == "all" || s == id || globMatch(s, nodeName(n))) {
+				continue
+			}
+			found = true
+			if !seen[id] {
+				seen[id] = true
+				sel = append(sel, n)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no nodes match: %s", s)
+		}
+	}
+	return sel, nil
+}
+
+// resolve finds the ID of exactly one node
+func (c *Ctl) resolve(selector string) (string, error) {
+	if !core.NewNodeID(selector).Nil() {
+		return selector, nil
+	}
+	ns, e := c.selectNodes([]string{selector}, false)
+	if e != nil {
+		return "", e
+	}
+	if len(ns) > 1 {
+		return "", fmt.Errorf("%s matches %d nodes, but only one can be used here", selector, len(ns))
+	}
+	return ns[0].ID().String(), nil
+}
+
+/*
+ * Main
+ */
+
+// Main is krakenctl's main; it returns the exit code
+func Main(args []string) int {
+	fs := flag.NewFlagSet("krakenctl", flag.ContinueOnError)
+	sock := fs.String("sock", "/tmp/kraken.sock", "UNIX socket of a local kraken's API")
+	addr := fs.String("addr", "", "host:port of a remote kraken's API (over TLS); overrides -sock")
+	ca := fs.String("ca", "", "PEM CA certificates to verify a remote kraken with (default: the system's)")
+	cert := fs.String("cert", "", "PEM client certificate to identify ourselves to a remote kraken with")
+	key := fs.String("key", "", "PEM key for -cert")
+	serverName := fs.String("server-name", "", "name to verify a remote kraken's certificate against (default: the host of -addr)")
+	as := fs.String("as", "", "make calls on behalf of this principal (local socket only; remote clients are who their certificate says)")
+	output := fs.String("o", "table", "output format: table, json or yaml")
+	timeout := fs.Duration("timeout", 5*time.Minute, "how long to wait for nodes to change state")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: krakenctl [flags] <command> [args]\n\nFlags:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\n")
+		usage(fs.Output())
+	}
+	if e := fs.Parse(args); e != nil {
+		if e == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	format, ok := FormatValue[*output]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown output format: %s\n", *output)
+		return 2
+	}
+
+	var client *core.APIClient
+	if *addr != "" {
+		tc, e := lib.NewClientTLSConfig(*ca, *cert, *key, *serverName)
+		if e != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", e)
+			return 2
+		}
+		client = core.NewAPIClientWithTLS(*addr, tc)
+	} else {
+		client = core.NewAPIClient("unix:" + *sock)
+	}
+	// the client logs stream errors, e.g. if kraken goes away during events
+	log := &core.WriterLogger{}
+	log.RegisterWriter(os.Stderr)
+	log.SetModule("krakenctl")
+	log.SetLoggerLevel(lib.LLERROR)
+	client.SetLogger(log)
+	var api lib.APIClient = client
+	if *as != "" && *addr == "" {
+		api = api.WithPrincipal(*as)
+	}
+
+	c := New(api, os.Stdout)
+	c.Format = format
+	c.Timeout = *timeout
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	if e := c.Run(ctx, fs.Args()); e != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", e)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "Commands:\n")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, n := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", commands[n].usage, commands[n].summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nNodes are given by ID or nodename; selectors can also be \"all\" or nodename globs.\n")
+}
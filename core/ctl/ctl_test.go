@@ -0,0 +1,237 @@
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/lib"
+
+	ip4pb "github.com/hpc/kraken/extensions/IPv4/proto"
+
+	_ "github.com/hpc/kraken/extensions/IPv4"
+)
+
+// fake is a service that doesn't run anything; it just says it does
+var fake = &fakeService{}
+
+func init() {
+	core.Registry.RegisterModule(&fakeModule{})
+	core.Registry.RegisterServiceInstance(&fakeModule{}, map[string]lib.ServiceInstance{"fake": fake})
+}
+
+// TestCtl runs commands against an in-process kraken
+func TestCtl(t *testing.T) {
+	self := core.NewNodeWithID("123e4567-e89b-12d3-a456-426655440000")
+	self.SetValue("type.googleapis.com/proto.IPv4OverEthernet/Ifaces/0", reflect.ValueOf(&ip4pb.IPv4OverEthernet_ConfiguredInterface{
+		Eth: &ip4pb.Ethernet{Iface: "lo"},
+		Ip:  &ip4pb.IPv4{Ip: net.ParseIP("127.0.0.1").To4()},
+	}))
+	k := core.NewKraken(self, []string{}, &core.WriterLogger{})
+	k.Ctx.SSE.Port = 0
+	k.Ctx.RPC.Port = 0
+	k.Ctx.RPC.Path = filepath.Join(t.TempDir(), "kraken.sock")
+	k.Release()
+	k.Sme.Thaw()
+
+	out := &bytes.Buffer{}
+	c := New(core.NewAPIClient("unix:"+k.Ctx.RPC.Path), out)
+	c.Timeout = 2 * time.Second
+	c.Poll = 100 * time.Millisecond
+	run := func(args ...string) string {
+		out.Reset()
+		if e := c.Run(context.Background(), args); e != nil {
+			t.Fatalf("%v failed: %v", args, e)
+		}
+		return out.String()
+	}
+
+	if o := run("get"); !strings.Contains(o, "123e4567-e89b-12d3-a456-426655440000") || !strings.Contains(o, "POWER_ON") {
+		t.Errorf("get didn't list our node:\n%s", o)
+	}
+	run("set", "123e4567-e89b-12d3-a456-426655440000", "Nodename", "kr0")
+
+	// nodes can be selected by name, and values are JSON
+	c.Format = FormatJSON
+	if o := run("get", "kr0", "/Nodename"); strings.TrimSpace(o) != `"kr0"` {
+		t.Errorf("expected \"kr0\", got: %s", o)
+	}
+
+	// nothing powers nodes off here, so powering off makes cfg and dsc differ
+	run("power", "off", "kr*")
+	var diff []struct {
+		URL string `json:"url"`
+		Cfg string `json:"cfg"`
+		Dsc string `json:"dsc"`
+	}
+	if e := json.Unmarshal([]byte(run("diff")), &diff); e != nil {
+		t.Fatalf("bad diff JSON: %v", e)
+	}
+	found := false
+	for _, d := range diff {
+		if d.URL == "/PhysState" && d.Cfg == "POWER_OFF" && d.Dsc == "POWER_ON" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("diff didn't show /PhysState: %+v", diff)
+	}
+	out.Reset()
+	if e := c.Run(context.Background(), []string{"power", "-wait", "off", "kr0"}); e == nil {
+		t.Errorf("waiting for a node that never powers off didn't time out")
+	}
+
+	c.Format = FormatYAML
+	run("freeze")
+	if o := run("frozen"); strings.TrimSpace(o) != "frozen: true" {
+		t.Errorf("expected frozen: true, got: %s", o)
+	}
+	run("thaw")
+
+	if e := c.Run(context.Background(), []string{"bogus"}); e == nil {
+		t.Errorf("unknown command didn't fail")
+	}
+	if e := c.Run(context.Background(), []string{"get", "nosuchnode"}); e == nil {
+		t.Errorf("unknown node didn't fail")
+	}
+
+	// services; what the service manager does with them is tested in core/tests
+	c.Format = FormatJSON
+	service := func(args ...string) (st struct{ Cfg, Dsc string }) {
+		if e := json.Unmarshal([]byte(run(append([]string{"service"}, args...)...)), &st); e != nil {
+			t.Fatalf("bad service JSON: %v", e)
+		}
+		return
+	}
+	if st := service("start", "fake"); st.Cfg != "RUN" {
+		t.Errorf("start didn't set cfg to RUN: %+v", st)
+	}
+	cfgFile := filepath.Join(t.TempDir(), "config.json")
+	ioutil.WriteFile(cfgFile, []byte(`"hello"`), 0644)
+	if o := run("service", "config", "fake", cfgFile); !strings.Contains(o, "hello") {
+		t.Errorf("config wasn't set: %s", o)
+	}
+	ioutil.WriteFile(cfgFile, []byte(`{"@type": "type.googleapis.com/proto.IPv4OverEthernet"}`), 0644)
+	if e := c.Run(context.Background(), []string{"service", "config", "fake", cfgFile}); e == nil {
+		t.Errorf("a config of the wrong type was accepted")
+	}
+	// the module's reason for rejecting a config is reported
+	ioutil.WriteFile(cfgFile, []byte(`"bad"`), 0644)
+	if e := c.Run(context.Background(), []string{"service", "config", "fake", cfgFile}); e == nil || !strings.Contains(e.Error(), "invalid config for service fake: bad is not a greeting") {
+		t.Errorf("an invalid config was accepted: %v", e)
+	}
+	if st := service("restart", "fake"); st.Cfg != "RUN" {
+		t.Errorf("restart didn't leave cfg RUN: %+v", st)
+	}
+	if st := service("stop", "fake"); st.Cfg != "STOP" {
+		t.Errorf("stop didn't set cfg to STOP: %+v", st)
+	}
+	if e := c.Run(context.Background(), []string{"service", "start", "nosuchservice"}); e == nil {
+		t.Errorf("unknown service didn't fail")
+	}
+
+	// events stream until we stop them
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	c.Format = FormatTable
+	ebuf := &lockedBuffer{}
+	c.Out = ebuf
+	go func() { done <- c.Run(ctx, []string{"events", "-types", "STATE_CHANGE", "kr0"}) }()
+	time.Sleep(500 * time.Millisecond)
+	sc := New(core.NewAPIClient("unix:"+k.Ctx.RPC.Path), &bytes.Buffer{})
+	if e := sc.Run(context.Background(), []string{"set", "kr0", "/Arch", "aarch64"}); e != nil {
+		t.Fatalf("set failed: %v", e)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(ebuf.String(), "/Arch") && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	cancel()
+	if e := <-done; e != nil {
+		t.Errorf("events failed: %v", e)
+	}
+	if !strings.Contains(ebuf.String(), "STATE_CHANGE") {
+		t.Errorf("events didn't show the state change:\n%s", ebuf.String())
+	}
+}
+
+// lockedBuffer is a bytes.Buffer that a streaming command can write while we read it
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+// fakeModule takes a string as its config
+type fakeModule struct{}
+
+func (*fakeModule) Name() string                         { return "fake" }
+func (*fakeModule) NewConfig() proto.Message             { return &wrappers.StringValue{} }
+func (*fakeModule) UpdateConfig(cfg proto.Message) error { return nil }
+func (*fakeModule) ValidateConfig(cfg proto.Message) error {
+	if v := cfg.(*wrappers.StringValue).GetValue(); v == "bad" {
+		return fmt.Errorf("%s is not a greeting", v)
+	}
+	return nil
+}
+func (m *fakeModule) ConfigURL() string {
+	a, _ := ptypes.MarshalAny(m.NewConfig())
+	return a.GetTypeUrl()
+}
+
+// fakeService says it does what it's told
+type fakeService struct {
+	mutex sync.Mutex
+	state lib.ServiceState
+	wchan chan<- lib.ServiceInstanceUpdate
+}
+
+func (*fakeService) ID() string     { return "fake" }
+func (*fakeService) Module() string { return "fake" }
+
+func (s *fakeService) GetState() lib.ServiceState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state
+}
+
+func (*fakeService) UpdateConfig() {}
+func (s *fakeService) Start()      { s.setState(lib.Service_RUN) }
+func (s *fakeService) Stop()       { s.setState(lib.Service_STOP) }
+func (s *fakeService) Kill()       { s.setState(lib.Service_STOP) }
+
+func (s *fakeService) Watch(w chan<- lib.ServiceInstanceUpdate) { s.wchan = w }
+func (*fakeService) SetCtl(chan<- lib.ServiceControl)           {}
+func (*fakeService) SetSock(string)                             {}
+func (*fakeService) SetCgroup(string)                           {}
+
+func (s *fakeService) setState(state lib.ServiceState) {
+	s.mutex.Lock()
+	s.state = state
+	s.mutex.Unlock()
+	s.wchan <- lib.ServiceInstanceUpdate{ID: "fake", State: state}
+}
@@ -0,0 +1,397 @@
+/* APIClient.go: an in-memory lib.APIClient for testing modules without a kraken
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package ktest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+
+	"github.com/hpc/kraken/core"
+	pb "github.com/hpc/kraken/core/proto"
+	"github.com/hpc/kraken/lib"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ lib.APIClient = (*APIClient)(nil)
+
+// ErrUnsupported is returned by APIClient calls that need a running kraken; use Boot for those
+var ErrUnsupported = fmt.Errorf("not supported by the testing APIClient")
+
+// A LogEntry is a message a module logged through an APIClient
+type LogEntry struct {
+	Level   lib.LoggerLevel
+	Message string
+}
+
+// APIClient is an in-memory lib.APIClient.  It keeps cfg and dsc copies of nodes, which a test
+// can set up beforehand and inspect afterwards, and records what the module logs.
+// Everything it hands out or stores is a copy, like the real API.
+type APIClient struct {
+	self   lib.NodeID
+	cfg    map[string]lib.Node
+	dsc    map[string]lib.Node
+	frozen bool
+	logs   []LogEntry
+	beats  map[string]time.Duration
+	module string
+	lv     lib.LoggerLevel
+	out    func(string, ...interface{})
+	mutex  *sync.Mutex
+}
+
+// NewAPIClient creates an APIClient with self as the node the module runs on, in both cfg and dsc
+func NewAPIClient(self lib.Node) *APIClient {
+	a := &APIClient{
+		self:  self.ID(),
+		cfg:   map[string]lib.Node{},
+		dsc:   map[string]lib.Node{},
+		beats: map[string]time.Duration{},
+		lv:    lib.LLDDDEBUG,
+		mutex: &sync.Mutex{},
+	}
+	a.cfg[a.self.String()] = clone(self)
+	a.dsc[a.self.String()] = clone(self)
+	return a
+}
+
+// SetOutput sends log messages to f (e.g. testing.T.Logf) as well as recording them
+func (a *APIClient) SetOutput(f func(string, ...interface{})) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.out = f
+}
+
+// Logs returns everything logged so far
+func (a *APIClient) Logs() []LogEntry {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return append([]LogEntry{}, a.logs...)
+}
+
+// Heartbeats returns the last heartbeat interval each service instance sent
+func (a *APIClient) Heartbeats() map[string]time.Duration {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	r := map[string]time.Duration{}
+	for id, d := range a.beats {
+		r[id] = d
+	}
+	return r
+}
+
+/*
+ * lib.Logger
+ */
+
+// Log records a message, if it's enabled for lv
+func (a *APIClient) Log(lv lib.LoggerLevel, m string) {
+	if !a.IsEnabledFor(lv) {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.logs = append(a.logs, LogEntry{Level: lv, Message: m})
+	if a.out != nil {
+		a.out("%s:%s:%s", a.module, lib.LoggerLevels[lv], m)
+	}
+}
+
+// Logf is Log with sprintf formatting
+func (a *APIClient) Logf(lv lib.LoggerLevel, f string, v ...interface{}) {
+	a.Log(lv, fmt.Sprintf(f, v...))
+}
+
+// SetModule sets the module name log messages are attributed to
+func (a *APIClient) SetModule(m string) { a.module = m }
+
+// GetModule gets the module name log messages are attributed to
+func (a *APIClient) GetModule() string { return a.module }
+
+// SetLoggerLevel sets the log filtering level; by default, everything is recorded
+func (a *APIClient) SetLoggerLevel(lv lib.LoggerLevel) { a.lv = lv }
+
+// GetLoggerLevel gets the log filtering level
+func (a *APIClient) GetLoggerLevel() lib.LoggerLevel { return a.lv }
+
+// IsEnabledFor determines if a message at level lv would be recorded
+func (a *APIClient) IsEnabledFor(lv lib.LoggerLevel) bool { return lv <= a.lv }
+
+/*
+ * Queries
+ */
+
+// Self returns the ID of the node the module runs on
+func (a *APIClient) Self() lib.NodeID { return a.self }
+
+// QueryCreate adds a node, to both cfg and dsc
+func (a *APIClient) QueryCreate(n lib.Node) (lib.Node, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	id := n.ID().String()
+	if _, ok := a.cfg[id]; ok {
+		return nil, fmt.Errorf("node already exists: %s", id)
+	}
+	a.cfg[id] = clone(n)
+	a.dsc[id] = clone(n)
+	return clone(n), nil
+}
+
+// QueryRead reads a cfg node
+func (a *APIClient) QueryRead(id string) (lib.Node, error) { return a.read(a.cfg, id) }
+
+// QueryReadDsc reads a dsc node
+func (a *APIClient) QueryReadDsc(id string) (lib.Node, error) { return a.read(a.dsc, id) }
+
+// QueryUpdate replaces a cfg node
+func (a *APIClient) QueryUpdate(n lib.Node) (lib.Node, error) { return a.update(a.cfg, n) }
+
+// QueryUpdateDsc replaces a dsc node
+func (a *APIClient) QueryUpdateDsc(n lib.Node) (lib.Node, error) { return a.update(a.dsc, n) }
+
+// QueryUpdateIf replaces a cfg node, if it's still at version
+func (a *APIClient) QueryUpdateIf(n lib.Node, version string) (lib.Node, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	id := n.ID().String()
+	cur, ok := a.cfg[id]
+	if !ok {
+		return nil, fmt.Errorf("no such node: %s", id)
+	}
+	if core.NodeVersion(cur) != version {
+		return nil, status.Error(codes.Aborted, core.ErrNodeVersion.Error())
+	}
+	a.cfg[id] = clone(n)
+	return clone(n), nil
+}
+
+// QuerySetValue sets a cfg value by node-qualified URL
+func (a *APIClient) QuerySetValue(nodeURL string, v reflect.Value) (reflect.Value, error) {
+	node, url := lib.NodeURLSplit(nodeURL)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	n, ok := a.cfg[node]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("no such node: %s", node)
+	}
+	return n.SetValue(url, v)
+}
+
+// QueryDelete removes a node
+func (a *APIClient) QueryDelete(id string) (lib.Node, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	n, ok := a.cfg[id]
+	if !ok {
+		return nil, fmt.Errorf("no such node: %s", id)
+	}
+	delete(a.cfg, id)
+	delete(a.dsc, id)
+	return n, nil
+}
+
+// QueryReadAll reads all cfg nodes
+func (a *APIClient) QueryReadAll() ([]lib.Node, error) { return a.readAll(a.cfg), nil }
+
+// QueryReadAllDsc reads all dsc nodes
+func (a *APIClient) QueryReadAllDsc() ([]lib.Node, error) { return a.readAll(a.dsc), nil }
+
+// QueryDeleteAll removes all nodes, including self
+func (a *APIClient) QueryDeleteAll() ([]lib.Node, error) {
+	ns := a.readAll(a.cfg)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.cfg = map[string]lib.Node{}
+	a.dsc = map[string]lib.Node{}
+	return ns, nil
+}
+
+// QueryMutationNodes is unsupported; there is no mutation engine
+func (a *APIClient) QueryMutationNodes() (pb.MutationNodeList, error) {
+	return pb.MutationNodeList{}, ErrUnsupported
+}
+
+// QueryMutationEdges is unsupported; there is no mutation engine
+func (a *APIClient) QueryMutationEdges() (pb.MutationEdgeList, error) {
+	return pb.MutationEdgeList{}, ErrUnsupported
+}
+
+// QueryNodeMutationNodes is unsupported; there is no mutation engine
+func (a *APIClient) QueryNodeMutationNodes(string) (pb.MutationNodeList, error) {
+	return pb.MutationNodeList{}, ErrUnsupported
+}
+
+// QueryNodeMutationEdges is unsupported; there is no mutation engine
+func (a *APIClient) QueryNodeMutationEdges(string) (pb.MutationEdgeList, error) {
+	return pb.MutationEdgeList{}, ErrUnsupported
+}
+
+// QueryNodeMutationPath is unsupported; there is no mutation engine
+func (a *APIClient) QueryNodeMutationPath(string) (pb.MutationPath, error) {
+	return pb.MutationPath{}, ErrUnsupported
+}
+
+// QueryNodeMutationHistory is unsupported; there is no mutation engine
+func (a *APIClient) QueryNodeMutationHistory(string) (pb.MutationHistory, error) {
+	return pb.MutationHistory{}, ErrUnsupported
+}
+
+// QueryFreeze records that mutations are frozen
+func (a *APIClient) QueryFreeze() error { a.setFrozen(true); return nil }
+
+// QueryThaw records that mutations are thawed
+func (a *APIClient) QueryThaw() error { a.setFrozen(false); return nil }
+
+// QueryFrozen reports whether QueryFreeze was called more recently than QueryThaw
+func (a *APIClient) QueryFrozen() (bool, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.frozen, nil
+}
+
+// Metrics has nothing to report
+func (a *APIClient) Metrics() (string, error) { return "", nil }
+
+/*
+ * Services
+ */
+
+// ServiceInit is unsupported; a Harness passes service control to the module itself
+func (a *APIClient) ServiceInit(string, string) (<-chan lib.ServiceControl, error) {
+	return nil, ErrUnsupported
+}
+
+// ServiceList is unsupported; there is no service manager
+func (a *APIClient) ServiceList() ([]*pb.ServiceStatus, error) { return nil, ErrUnsupported }
+
+// ServiceGet is unsupported; there is no service manager
+func (a *APIClient) ServiceGet(string) (*pb.ServiceStatus, error) { return nil, ErrUnsupported }
+
+// ServiceStart is unsupported; there is no service manager
+func (a *APIClient) ServiceStart(string) (*pb.ServiceStatus, error) { return nil, ErrUnsupported }
+
+// ServiceStop is unsupported; there is no service manager
+func (a *APIClient) ServiceStop(string) (*pb.ServiceStatus, error) { return nil, ErrUnsupported }
+
+// ServiceRestart is unsupported; there is no service manager
+func (a *APIClient) ServiceRestart(string) (*pb.ServiceStatus, error) {
+	return nil, ErrUnsupported
+}
+
+// ServiceConfigure sets the config of a service instance on self's cfg node, if its module's ValidateConfig
+// (if it has one) accepts it.  Use Harness.Configure to also tell the module about it.
+func (a *APIClient) ServiceConfigure(id string, cfg proto.Message) (*pb.ServiceStatus, error) {
+	any, e := ptypes.MarshalAny(cfg)
+	if e != nil {
+		return nil, e
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	srv := a.cfg[a.self.String()].GetService(id)
+	if srv == nil {
+		return nil, fmt.Errorf("no such service: %s", id)
+	}
+	if mv, ok := core.Registry.Module(srv.GetModule()).(lib.ModuleWithConfigValidation); ok {
+		if e = mv.ValidateConfig(cfg); e != nil {
+			return nil, fmt.Errorf("invalid config for service %s: %v", id, e)
+		}
+	}
+	srv.Config = any
+	return &pb.ServiceStatus{Id: id, Module: srv.GetModule(), Config: any}, nil
+}
+
+// ServiceHeartbeat records a heartbeat
+func (a *APIClient) ServiceHeartbeat(id string, interval time.Duration) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.beats[id] = interval
+	return nil
+}
+
+// WithPrincipal returns the same client; there is no authorization policy to check
+func (a *APIClient) WithPrincipal(string) lib.APIClient { return a }
+
+// Watch is unsupported; there are no events
+func (a *APIClient) Watch(context.Context, uint64, []lib.EventType) (<-chan lib.Event, error) {
+	return nil, ErrUnsupported
+}
+
+////////////////////////
+// Unexported methods /
+//////////////////////
+
+func (a *APIClient) read(ns map[string]lib.Node, id string) (lib.Node, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	n, ok := ns[id]
+	if !ok {
+		return nil, fmt.Errorf("no such node: %s", id)
+	}
+	return clone(n), nil
+}
+
+func (a *APIClient) readAll(ns map[string]lib.Node) (r []lib.Node) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, n := range ns {
+		r = append(r, clone(n))
+	}
+	return
+}
+
+func (a *APIClient) update(ns map[string]lib.Node, n lib.Node) (lib.Node, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	id := n.ID().String()
+	if _, ok := ns[id]; !ok {
+		return nil, fmt.Errorf("no such node: %s", id)
+	}
+	ns[id] = clone(n)
+	return clone(n), nil
+}
+
+// setDsc sets a value on a dsc node by node-qualified URL, like a discovery does
+func (a *APIClient) setDsc(nodeURL string, v reflect.Value) error {
+	node, url := lib.NodeURLSplit(nodeURL)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	n, ok := a.dsc[node]
+	if !ok {
+		return fmt.Errorf("no such node: %s", node)
+	}
+	_, e := n.SetValue(url, v)
+	return e
+}
+
+// addService adds a stub for a service instance to self's cfg node, if it doesn't have one
+func (a *APIClient) addService(id, module string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if n, ok := a.cfg[a.self.String()]; ok && !n.HasService(id) {
+		n.AddService(&pb.ServiceInstance{Id: id, Module: module})
+	}
+}
+
+func (a *APIClient) setFrozen(f bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.frozen = f
+}
+
+// clone makes a deep copy of a node
+func clone(n lib.Node) lib.Node {
+	return core.NewNodeFromMessage(proto.Clone(n.Message()).(*pb.Node))
+}
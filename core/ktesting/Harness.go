@@ -0,0 +1,286 @@
+/* Harness.go: runs a single module against an in-memory APIClient, for unit testing
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+// Package ktest helps test kraken modules.
+//
+// A Harness runs one module in the test process against an in-memory APIClient.
+// The test plays the part of kraken: it injects mutations (and other events) and checks the
+// discoveries the module sends back, which are applied to the APIClient's dsc nodes like kraken would.
+//
+// Boot starts a whole single-node kraken in the test process, with a chosen set of modules
+// running as goroutines instead of as child processes.
+package ktest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+
+	"github.com/hpc/kraken/core"
+	pb "github.com/hpc/kraken/core/proto"
+	"github.com/hpc/kraken/lib"
+)
+
+// SelfID is the node ID NewHarness and Boot use for self if they aren't given a node
+const SelfID = "123e4567-e89b-12d3-a456-426655440000"
+
+// DefaultTimeout is how long a Harness waits for the module by default
+var DefaultTimeout = 5 * time.Second
+
+// TB is the part of testing.TB we need
+type TB interface {
+	Helper()
+	Logf(string, ...interface{})
+	Errorf(string, ...interface{})
+	Fatalf(string, ...interface{})
+	Cleanup(func())
+	TempDir() string
+}
+
+// A Harness runs a module in the test process.  The module gets an APIClient instead of a
+// connection to kraken, and its mutation, discovery and event channels are connected to the Harness.
+//
+// Many modules exit the process in Stop, so the Harness never calls it.
+type Harness struct {
+	API     *APIClient
+	ID      string        // service instance ID the module runs as; mutations and discoverables are registered under it
+	Timeout time.Duration // how long to wait for the module to take or send something
+
+	t       TB
+	m       lib.Module
+	mchan   chan lib.Event
+	dchan   chan lib.Event
+	echan   chan lib.Event
+	started bool
+	mutex   *sync.Mutex
+}
+
+// NewHarness creates a Harness for module m, running on node self (or a new node, if self is nil).
+// The service instance ID is the one m registered, if it registered exactly one, and m's name otherwise.
+func NewHarness(t TB, m lib.Module, self lib.Node) *Harness {
+	if self == nil {
+		self = core.NewNodeWithID(SelfID)
+	}
+	h := &Harness{
+		API:     NewAPIClient(self),
+		ID:      m.Name(),
+		Timeout: DefaultTimeout,
+		t:       t,
+		m:       m,
+		mchan:   make(chan lib.Event),
+		dchan:   make(chan lib.Event),
+		echan:   make(chan lib.Event),
+		mutex:   &sync.Mutex{},
+	}
+	if sis := core.Registry.ServiceInstances[m.Name()]; len(sis) == 1 {
+		for id := range sis {
+			h.ID = id
+		}
+	}
+	h.API.SetModule(m.Name())
+	h.API.SetOutput(t.Logf)
+	return h
+}
+
+// Start sets the module up the way kraken does for a new module process, then runs its Entry in a goroutine.
+// If a config was set with Configure, the module gets it before Entry runs.
+func (h *Harness) Start() {
+	h.t.Helper()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.started {
+		h.t.Fatalf("module %s is already started", h.m.Name())
+		return
+	}
+	mss, ok := h.m.(lib.ModuleSelfService)
+	if !ok {
+		h.t.Fatalf("module is not executable: %s", h.m.Name())
+		return
+	}
+	mss.Init(h.API)
+	if mm, ok := h.m.(lib.ModuleWithMutations); ok {
+		mm.SetMutationChan(h.mchan)
+	}
+	if me, ok := h.m.(lib.ModuleWithAllEvents); ok {
+		me.SetEventsChan(h.echan)
+	}
+	if md, ok := h.m.(lib.ModuleWithDiscovery); ok {
+		md.SetDiscoveryChan(h.dchan)
+	}
+	if _, ok := h.m.(lib.ModuleWithConfig); ok {
+		h.updateConfig()
+	}
+	go mss.Entry()
+	h.started = true
+}
+
+// Configure sets the module's config.  If the module is running, it's told about it, like a config UPDATE.
+func (h *Harness) Configure(cfg proto.Message) {
+	h.t.Helper()
+	h.API.addService(h.ID, h.m.Name())
+	if _, e := h.API.ServiceConfigure(h.ID, cfg); e != nil {
+		h.t.Fatalf("failed to set config: %v", e)
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.started {
+		h.updateConfig()
+	}
+}
+
+// Mutate sends the module a request to perform mutation mutid on the node with configuration cfg and
+// discovered state dsc.  If they're nil, the APIClient's self nodes are used.
+func (h *Harness) Mutate(mutid string, cfg, dsc lib.Node) {
+	h.t.Helper()
+	h.mutation(pb.MutationControl_MUTATE, mutid, cfg, dsc)
+}
+
+// Interrupt tells the module to stop working on mutation mutid, like kraken does when a mutation times out
+func (h *Harness) Interrupt(mutid string, cfg, dsc lib.Node) {
+	h.t.Helper()
+	h.mutation(pb.MutationControl_INTERRUPT, mutid, cfg, dsc)
+}
+
+// Event sends the module an event, if it takes all events
+func (h *Harness) Event(v lib.Event) {
+	h.t.Helper()
+	if _, ok := h.m.(lib.ModuleWithAllEvents); !ok {
+		h.t.Fatalf("module %s does not take events", h.m.Name())
+		return
+	}
+	select {
+	case h.echan <- v:
+	case <-time.After(h.Timeout):
+		h.t.Fatalf("module %s did not take event within %v: %v", h.m.Name(), h.Timeout, v)
+	}
+}
+
+// Discovered waits for the module to discover something, and applies it to the APIClient's dsc node.
+// The discovery has to be of a value the module registered, or the test fails, since kraken would drop it.
+func (h *Harness) Discovered() *core.DiscoveryEvent {
+	h.t.Helper()
+	var v lib.Event
+	select {
+	case v = <-h.dchan:
+	case <-time.After(h.Timeout):
+		h.t.Fatalf("module %s discovered nothing within %v", h.m.Name(), h.Timeout)
+		return nil
+	}
+	de, ok := v.Data().(*core.DiscoveryEvent)
+	if !ok {
+		h.t.Fatalf("module %s sent a discovery that is not *DiscoveryEvent: %v", h.m.Name(), v.Data())
+		return nil
+	}
+	de.ID = h.ID // the API fills this in
+	_, url := lib.NodeURLSplit(de.URL)
+	val, ok := core.Registry.Discoverable(de.ID, url, de.ValueID)
+	if !ok {
+		h.t.Fatalf("module %s discovered a value it didn't register: %s", h.m.Name(), de)
+		return de
+	}
+	if e := h.API.setDsc(de.URL, val); e != nil {
+		h.t.Fatalf("failed to set discovered value %s: %v", de, e)
+	}
+	return de
+}
+
+// ExpectDiscovery waits for a discovery, and fails the test if it isn't of value valueID at nodeURL
+func (h *Harness) ExpectDiscovery(nodeURL, valueID string) {
+	h.t.Helper()
+	de := h.Discovered()
+	if de != nil && (de.URL != nodeURL || de.ValueID != valueID) {
+		h.t.Errorf("expected discovery %s == %s, got %s == %s", nodeURL, valueID, de.URL, de.ValueID)
+	}
+}
+
+// ExpectNoDiscovery fails the test if the module discovers something within d
+func (h *Harness) ExpectNoDiscovery(d time.Duration) {
+	h.t.Helper()
+	select {
+	case v := <-h.dchan:
+		h.t.Errorf("expected no discovery, got: %v", v.Data())
+	case <-time.After(d):
+	}
+}
+
+////////////////////////
+// Unexported methods /
+//////////////////////
+
+// mutation builds a mutation event the way the API client does and hands it to the module
+func (h *Harness) mutation(t pb.MutationControl_Type, mutid string, cfg, dsc lib.Node) {
+	h.t.Helper()
+	if _, ok := h.m.(lib.ModuleWithMutations); !ok {
+		h.t.Fatalf("module %s does not take mutations", h.m.Name())
+		return
+	}
+	if _, ok := core.Registry.Mutations[h.ID][mutid]; !ok {
+		h.t.Fatalf("service %s has no mutation %s", h.ID, mutid)
+		return
+	}
+	var e error
+	if cfg == nil {
+		if cfg, e = h.API.QueryRead(h.API.Self().String()); e != nil {
+			h.t.Fatalf("%v", e)
+			return
+		}
+	}
+	if dsc == nil {
+		if dsc, e = h.API.QueryReadDsc(h.API.Self().String()); e != nil {
+			h.t.Fatalf("%v", e)
+			return
+		}
+	}
+	v := core.NewEvent(
+		lib.Event_STATE_MUTATION,
+		cfg.ID().String(),
+		&core.MutationEvent{
+			Type:     t,
+			NodeCfg:  clone(cfg),
+			NodeDsc:  clone(dsc),
+			Mutation: [2]string{h.ID, mutid},
+			Attempt:  1,
+		})
+	select {
+	case h.mchan <- v:
+	case <-time.After(h.Timeout):
+		h.t.Fatalf("module %s did not take mutation %s within %v", h.m.Name(), mutid, h.Timeout)
+	}
+}
+
+// updateConfig gives the module its config from self's cfg node, like a config UPDATE
+// Assume h.mutex is locked
+func (h *Harness) updateConfig() {
+	h.t.Helper()
+	mc := h.m.(lib.ModuleWithConfig)
+	n, e := h.API.QueryRead(h.API.Self().String())
+	if e != nil {
+		h.t.Fatalf("%v", e)
+		return
+	}
+	srv := n.GetService(h.ID)
+	if srv.GetConfig() == nil {
+		return // nothing configured yet
+	}
+	p, e := core.Registry.Resolve(srv.GetConfig().GetTypeUrl())
+	if e != nil {
+		h.t.Fatalf("resolve config error (%s): %v", srv.GetConfig().GetTypeUrl(), e)
+		return
+	}
+	if e = ptypes.UnmarshalAny(srv.GetConfig(), p); e != nil {
+		h.t.Fatalf("unmarshal config failure: %v", e)
+		return
+	}
+	if e = mc.UpdateConfig(p); e != nil {
+		h.t.Errorf("module %s rejected config: %v", h.m.Name(), e)
+	}
+}
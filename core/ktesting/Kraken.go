@@ -0,0 +1,253 @@
+/* Kraken.go: boots a single-node kraken in the test process, with modules running as goroutines
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package ktest
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hpc/kraken/core"
+	pb "github.com/hpc/kraken/core/proto"
+	"github.com/hpc/kraken/lib"
+
+	ip4pb "github.com/hpc/kraken/extensions/IPv4/proto"
+
+	_ "github.com/hpc/kraken/extensions/IPv4"
+)
+
+// LogLevel is the level Boot logs kraken at
+var LogLevel = lib.LLNOTICE
+
+// Kraken is a kraken running in the test process
+type Kraken struct {
+	*core.Kraken
+	API *core.APIClient // connected to the kraken's API socket
+}
+
+// Boot starts a full-state kraken in the test process, running on node self (or a new node, if self is nil).
+// The listed modules' service instances are set to run, and run as goroutines instead of processes.
+// Self is powered on and in sync from the start, so mutation paths start there instead of at PHYS_UNKNOWN,
+// and mutations are thawed.  Boot returns once each of the services has discovered that it's running
+// (/Services/<id>/State is RUN), since that's when kraken can start mutating with them.
+//
+// Boot changes the module registry, and the kraken keeps running (quietly) until the test binary exits,
+// unless the test calls Shutdown.  Many modules exit the process in Stop, so stopping a service only
+// marks it stopped; its goroutines keep running and pick up where they were if it's started again.
+func Boot(t TB, self lib.Node, modules ...string) *Kraken {
+	t.Helper()
+	if self == nil {
+		self = core.NewNodeWithID(SelfID)
+	}
+	// kraken listens on self's address, so make sure it has one
+	if !self.HasExtension("type.googleapis.com/proto.IPv4OverEthernet") {
+		self.AddExtension(&ip4pb.IPv4OverEthernet{})
+	}
+	if v, e := self.GetValue(core.AddrURL); e != nil || v.Len() == 0 {
+		self.SetValue("type.googleapis.com/proto.IPv4OverEthernet/Ifaces/0", reflect.ValueOf(&ip4pb.IPv4OverEthernet_ConfiguredInterface{
+			Eth: &ip4pb.Ethernet{Iface: "lo"},
+			Ip:  &ip4pb.IPv4{Ip: net.ParseIP("127.0.0.1").To4()},
+		}))
+	}
+
+	booted := []string{}
+	for _, name := range modules {
+		m, ok := core.Registry.Modules[name]
+		if !ok {
+			t.Fatalf("no such module: %s", name)
+			return nil
+		}
+		if _, ok := m.(lib.ModuleSelfService); !ok {
+			t.Fatalf("module is not executable: %s", name)
+			return nil
+		}
+		ids := []string{}
+		for id := range core.Registry.ServiceInstances[name] {
+			ids = append(ids, id)
+		}
+		if len(ids) == 0 {
+			ids = append(ids, name)
+		}
+		sis := map[string]lib.ServiceInstance{}
+		for _, id := range ids {
+			sis[id] = newInProcessInstance(id, name)
+			booted = append(booted, id)
+			srv := self.GetService(id)
+			if srv == nil {
+				srv = &pb.ServiceInstance{Id: id, Module: name}
+				self.AddService(srv)
+			}
+			srv.State = pb.ServiceInstance_RUN
+		}
+		core.Registry.RegisterServiceInstance(m, sis)
+	}
+
+	w := &logWriter{t: t}
+	t.Cleanup(w.close)
+	log := &core.WriterLogger{}
+	log.RegisterWriter(w)
+	log.SetLoggerLevel(LogLevel)
+
+	k := core.NewKraken(self, []string{}, log)
+	k.Ctx.SSE.Port = 0
+	k.Ctx.RPC.Port = 0
+	k.Ctx.RPC.Path = filepath.Join(t.TempDir(), "kraken.sock")
+	// nothing powers self on or syncs it; it's on and in sync from the start, so that's where mutation paths start too
+	k.Ctx.SME.RootSpec = core.NewStateSpec(map[string]reflect.Value{
+		"/PhysState": reflect.ValueOf(pb.Node_POWER_ON),
+		"/RunState":  reflect.ValueOf(pb.Node_SYNC),
+	}, map[string]reflect.Value{})
+	k.Release()
+	k.Sme.Thaw()
+
+	// mutations for a service wait for it to say it's running, so wait for that too
+	deadline := time.Now().Add(DefaultTimeout)
+	for _, id := range booted {
+		for {
+			// the SDE hands out copies, so reading through the query engine doesn't race its writes
+			if n, e := k.Ctx.Query.ReadDsc(k.Ctx.Self); e == nil && n.GetService(id).GetState() == pb.ServiceInstance_RUN {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("service %s didn't discover that it's running within %v", id, DefaultTimeout)
+				return nil
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	return &Kraken{
+		Kraken: k,
+		API:    core.NewAPIClient("unix:" + k.Ctx.RPC.Path),
+	}
+}
+
+// logWriter logs to a test, until the test is over
+type logWriter struct {
+	t      TB
+	closed bool
+	mutex  sync.Mutex
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if !w.closed {
+		w.t.Logf("%s", strings.TrimSpace(string(p)))
+	}
+	return len(p), nil
+}
+
+func (w *logWriter) close() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closed = true
+}
+
+//////////////////////////////
+// inProcessInstance Object /
+////////////////////////////
+
+var _ lib.ServiceInstance = (*inProcessInstance)(nil)
+
+// An inProcessInstance is a lib.ServiceInstance that runs its module in a goroutine of this process.
+// The module connects to the API socket just like a module process would.
+type inProcessInstance struct {
+	id       string
+	module   string
+	sock     string
+	started  bool
+	state    lib.ServiceState
+	ctl      chan<- lib.ServiceControl
+	ctlMutex *sync.Mutex // protects ctl, and is held while we send on it
+	wchan    chan<- lib.ServiceInstanceUpdate
+	mutex    *sync.Mutex
+}
+
+func newInProcessInstance(id, module string) *inProcessInstance {
+	return &inProcessInstance{
+		id:       id,
+		module:   module,
+		state:    lib.Service_STOP,
+		ctlMutex: &sync.Mutex{},
+		mutex:    &sync.Mutex{},
+	}
+}
+
+func (si *inProcessInstance) ID() string     { return si.id }
+func (si *inProcessInstance) Module() string { return si.module }
+
+func (si *inProcessInstance) GetState() lib.ServiceState {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+	return si.state
+}
+
+func (si *inProcessInstance) UpdateConfig() {
+	si.ctlMutex.Lock()
+	defer si.ctlMutex.Unlock()
+	if si.ctl != nil {
+		si.ctl <- lib.ServiceControl{Command: lib.ServiceControl_UPDATE}
+	}
+}
+
+// Start runs the module the first time; after that, the module is still running, so it only marks it running
+func (si *inProcessInstance) Start() {
+	si.mutex.Lock()
+	if !si.started {
+		si.started = true
+		go core.ModuleExecute(si.id, si.module, si.sock)
+	}
+	si.mutex.Unlock()
+	si.setState(lib.Service_RUN, nil)
+}
+
+func (si *inProcessInstance) Stop() { si.setState(lib.Service_STOP, nil) }
+
+func (si *inProcessInstance) Kill() {
+	if si.GetState() == lib.Service_RUN {
+		si.setState(lib.Service_ERROR, fmt.Errorf("killed"))
+	}
+}
+
+func (si *inProcessInstance) Watch(wchan chan<- lib.ServiceInstanceUpdate) { si.wchan = wchan }
+
+func (si *inProcessInstance) SetCtl(ctl chan<- lib.ServiceControl) {
+	si.ctlMutex.Lock()
+	defer si.ctlMutex.Unlock()
+	si.ctl = ctl
+}
+
+func (si *inProcessInstance) ClearCtl(ctl chan<- lib.ServiceControl) {
+	si.ctlMutex.Lock()
+	defer si.ctlMutex.Unlock()
+	if si.ctl == ctl {
+		si.ctl = nil
+	}
+}
+
+func (si *inProcessInstance) SetSock(sock string) { si.sock = sock }
+func (si *inProcessInstance) SetCgroup(string)    {} // we run in kraken's cgroup
+
+func (si *inProcessInstance) setState(state lib.ServiceState, e error) {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+	si.state = state
+	if si.wchan != nil {
+		si.wchan <- lib.ServiceInstanceUpdate{
+			ID:    si.id,
+			State: si.state,
+			Error: e,
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package ktest_test
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+
+	"github.com/hpc/kraken/core"
+	pb "github.com/hpc/kraken/core/proto"
+	"github.com/hpc/kraken/lib"
+
+	"github.com/hpc/kraken/core/ktesting"
+)
+
+// blinky is a module that sets /Platform to "on" (or "follow", or "flaky") when asked to.  It never manages to fail.
+// It ignores the first attempt at blinkyFlaky.
+type blinky struct {
+	api        lib.APIClient
+	mchan      <-chan lib.Event
+	dchan      chan<- lib.Event
+	fails      int32 // blinkyFail mutations we've been asked for
+	follows    int32 // blinkyFollow mutations we've been asked for
+	flakes     int32 // blinkyFlaky attempts we've been asked for
+	interrupts int32
+}
+
+func (*blinky) Name() string                          { return "blinky" }
+func (b *blinky) Init(api lib.APIClient)              { b.api = api }
+func (*blinky) Stop()                                 {}
+func (b *blinky) SetMutationChan(c <-chan lib.Event)  { b.mchan = c }
+func (b *blinky) SetDiscoveryChan(c chan<- lib.Event) { b.dchan = c }
+func (b *blinky) Entry() {
+	url := lib.NodeURLJoin(b.api.Self().String(), "/Services/blinky/State")
+	b.dchan <- core.NewEvent(lib.Event_DISCOVERY, url, &core.DiscoveryEvent{URL: url, ValueID: "RUN"})
+	for v := range b.mchan {
+		me := v.Data().(*core.MutationEvent)
+		if me.Type == pb.MutationControl_INTERRUPT {
+			atomic.AddInt32(&b.interrupts, 1)
+			continue
+		}
+		if me.Mutation[1] == "blinkyFail" {
+			atomic.AddInt32(&b.fails, 1)
+			continue
+		}
+		value := "on"
+		switch me.Mutation[1] {
+		case "blinkyFollow":
+			atomic.AddInt32(&b.follows, 1)
+			value = "follow"
+		case "blinkyFlaky":
+			atomic.AddInt32(&b.flakes, 1)
+			if me.Attempt < 2 {
+				continue
+			}
+			value = "flaky"
+		}
+		b.api.Logf(lib.LLINFO, "turning %s %s", value, me.NodeCfg.ID().String())
+		url := lib.NodeURLJoin(me.NodeCfg.ID().String(), "/Platform")
+		b.dchan <- core.NewEvent(lib.Event_DISCOVERY, url, &core.DiscoveryEvent{URL: url, ValueID: value})
+	}
+}
+
+func init() {
+	m := &blinky{}
+	si := core.NewServiceInstance("blinky", m.Name(), m.Entry)
+	core.Registry.RegisterModule(m)
+	core.Registry.RegisterMutations(si, map[string]lib.StateMutation{
+		"blinkyFail": core.NewStateMutation(
+			map[string][2]reflect.Value{"/Platform": {reflect.ValueOf(""), reflect.ValueOf("fail")}},
+			map[string]reflect.Value{"/Services/blinky/State": reflect.ValueOf(pb.ServiceInstance_RUN)},
+			map[string]reflect.Value{},
+			lib.StateMutationContext_SELF,
+			0,
+			[3]string{si.ID(), "/Platform", "fail"},
+		),
+		"blinkyOn": core.NewStateMutation(
+			map[string][2]reflect.Value{"/Platform": {reflect.ValueOf(""), reflect.ValueOf("on")}},
+			map[string]reflect.Value{"/Services/blinky/State": reflect.ValueOf(pb.ServiceInstance_RUN)},
+			map[string]reflect.Value{},
+			lib.StateMutationContext_SELF,
+			5*time.Second,
+			[3]string{si.ID(), "/Platform", "fail"},
+		),
+		// only works the second time
+		"blinkyFlaky": core.NewStateMutationWithRetry(
+			map[string][2]reflect.Value{"/Platform": {reflect.ValueOf(""), reflect.ValueOf("flaky")}},
+			map[string]reflect.Value{"/Services/blinky/State": reflect.ValueOf(pb.ServiceInstance_RUN)},
+			map[string]reflect.Value{},
+			lib.StateMutationContext_SELF,
+			300*time.Millisecond,
+			[3]string{si.ID(), "/Platform", "fail"},
+			lib.StateMutationRetry{Attempts: 3, Backoff: 50 * time.Millisecond},
+		),
+		// follows the leader, once it's on
+		"blinkyFollow": core.NewStateMutationWithDepends(
+			map[string][2]reflect.Value{"/Platform": {reflect.ValueOf(""), reflect.ValueOf("follow")}},
+			map[string]reflect.Value{"/Services/blinky/State": reflect.ValueOf(pb.ServiceInstance_RUN)},
+			map[string]reflect.Value{},
+			lib.StateMutationContext_SELF,
+			2*time.Second,
+			[3]string{si.ID(), "/Platform", "fail"},
+			[]lib.StateMutationDependency{{
+				Select:   core.NewStateSpec(map[string]reflect.Value{"/Nodename": reflect.ValueOf("leader")}, map[string]reflect.Value{}),
+				Requires: core.NewStateSpec(map[string]reflect.Value{"/Platform": reflect.ValueOf("on")}, map[string]reflect.Value{}),
+			}},
+		),
+	})
+	core.Registry.RegisterDiscoverable(si, map[string]map[string]reflect.Value{
+		"/Platform":              {"on": reflect.ValueOf("on"), "follow": reflect.ValueOf("follow"), "flaky": reflect.ValueOf("flaky"), "fail": reflect.ValueOf("fail")},
+		"/Services/blinky/State": {"RUN": reflect.ValueOf(pb.ServiceInstance_RUN)},
+	})
+	core.Registry.RegisterServiceInstance(m, map[string]lib.ServiceInstance{si.ID(): si})
+}
+
+func platform(n lib.Node) string {
+	v, _ := n.GetValue("/Platform")
+	return v.String()
+}
+
+// TestHarness mutates a module and checks what it discovers
+func TestHarness(t *testing.T) {
+	h := ktest.NewHarness(t, &blinky{}, nil)
+	if h.ID != "blinky" {
+		t.Fatalf("expected the registered service instance ID, got: %s", h.ID)
+	}
+	h.Start()
+	h.ExpectDiscovery(lib.NodeURLJoin(ktest.SelfID, "/Services/blinky/State"), "RUN")
+
+	h.Interrupt("blinkyOn", nil, nil)
+	h.ExpectNoDiscovery(100 * time.Millisecond)
+
+	h.Mutate("blinkyOn", nil, nil)
+	h.ExpectDiscovery(lib.NodeURLJoin(ktest.SelfID, "/Platform"), "on")
+	n, _ := h.API.QueryReadDsc(ktest.SelfID)
+	if p := platform(n); p != "on" {
+		t.Errorf("expected the discovery to be applied to dsc, got: %q", p)
+	}
+	if n, _ = h.API.QueryRead(ktest.SelfID); platform(n) != "" {
+		t.Errorf("discoveries shouldn't change cfg")
+	}
+
+	found := false
+	for _, l := range h.API.Logs() {
+		if l.Level == lib.LLINFO && l.Message == "turning on "+ktest.SelfID {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("module log wasn't recorded: %v", h.API.Logs())
+	}
+}
+
+// TestBoot runs a module in a real kraken, and has kraken mutate self
+func TestBoot(t *testing.T) {
+	k := ktest.Boot(t, nil, "blinky")
+
+	n, e := k.API.QueryRead(ktest.SelfID)
+	if e != nil {
+		t.Fatalf("failed to read self: %v", e)
+	}
+	n.SetValue("/Platform", reflect.ValueOf("on"))
+	if _, e = k.API.QueryUpdate(n); e != nil {
+		t.Fatalf("failed to update self: %v", e)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if n, e = k.API.QueryReadDsc(ktest.SelfID); e == nil && platform(n) == "on" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("kraken didn't mutate /Platform to on")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if s, e := k.API.ServiceGet("blinky"); e != nil || s.GetDsc() != pb.ServiceInstance_RUN {
+		t.Errorf("expected blinky to be running: %v %v", s, e)
+	}
+}
+
+// TestShutdown shuts a kraken down with a mutation in progress, which gets interrupted, and services and the API stopped
+func TestShutdown(t *testing.T) {
+	k := ktest.Boot(t, nil, "blinky")
+	k.Ctx.SME.DrainTime = 100 * time.Millisecond
+	k.Ctx.RPC.DrainTime = time.Second
+	b := core.Registry.Modules["blinky"].(*blinky)
+
+	n, _ := k.API.QueryRead(ktest.SelfID)
+	n.SetValue("/Platform", reflect.ValueOf("fail"))
+	if _, e := k.API.QueryUpdate(n); e != nil {
+		t.Fatalf("failed to update self: %v", e)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for atomic.LoadInt32(&b.fails) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("kraken didn't ask blinky to fail")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	k.Shutdown()
+	for atomic.LoadInt32(&b.interrupts) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("blinky wasn't interrupted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	h, e := k.Ctx.Query.ReadNodeMutationHistory(ktest.SelfID)
+	if e != nil || len(h.Records) == 0 {
+		t.Fatalf("expected mutation history: %v", e)
+	}
+	if r := h.Records[len(h.Records)-1]; r.Outcome != pb.MutationPathRecord_INTERRUPTED {
+		t.Errorf("expected the mutation path to be interrupted, got: %v", r)
+	}
+	if s := k.Sm.GetService("blinky").GetState(); s != lib.Service_STOP {
+		t.Errorf("expected blinky to be stopped, got: %v", s)
+	}
+	if e := k.Sm.StartService("blinky"); e == nil {
+		t.Errorf("expected starting a service after shutdown to fail")
+	}
+	if s := k.Sm.GetService("blinky").GetState(); s != lib.Service_STOP {
+		t.Errorf("services shouldn't start once kraken is shut down, got: %v", s)
+	}
+	if _, e := k.API.QueryRead(ktest.SelfID); e == nil {
+		t.Errorf("expected the API to be stopped")
+	}
+}
+
+// follow creates a leader node that's off, and asks kraken to make self follow it.
+// It returns the leader once self's mutation path is waiting on it.
+func follow(t *testing.T, k *ktest.Kraken) lib.Node {
+	t.Helper()
+	leader := core.NewNodeWithID("223e4567-e89b-12d3-a456-426655440000")
+	leader.SetValue("/Nodename", reflect.ValueOf("leader"))
+	if _, e := k.API.QueryCreate(leader); e != nil {
+		t.Fatalf("failed to create the leader: %v", e)
+	}
+	n, _ := k.API.QueryRead(ktest.SelfID)
+	n.SetValue("/Platform", reflect.ValueOf("follow"))
+	if _, e := k.API.QueryUpdate(n); e != nil {
+		t.Fatalf("failed to update self: %v", e)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		p, e := k.Ctx.Query.ReadNodeMutationPath(ktest.SelfID)
+		if e == nil && strings.Contains(p.Waiting, leader.ID().String()) {
+			return leader
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("self isn't waiting on the leader: %v %v", p.Waiting, e)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// lastRecord waits for self's latest mutation path to end, and returns its record
+func lastRecord(t *testing.T, k *ktest.Kraken, d time.Duration) *pb.MutationPathRecord {
+	t.Helper()
+	deadline := time.Now().Add(d)
+	for {
+		h, e := k.Ctx.Query.ReadNodeMutationHistory(ktest.SelfID)
+		if e == nil && len(h.Records) > 0 {
+			if r := h.Records[len(h.Records)-1]; r.Outcome != pb.MutationPathRecord_ACTIVE {
+				return r
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("self's mutation path didn't end: %v %v", h, e)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestDependsResume tests that a mutation waits for the nodes it depends on, and fires once they're ready
+func TestDependsResume(t *testing.T) {
+	k := ktest.Boot(t, nil, "blinky")
+	b := core.Registry.Modules["blinky"].(*blinky)
+	follows := atomic.LoadInt32(&b.follows)
+
+	leader := follow(t, k)
+	if f := atomic.LoadInt32(&b.follows); f != follows {
+		t.Fatalf("blinkyFollow fired before the leader was on")
+	}
+	ld, _ := k.API.QueryReadDsc(leader.ID().String())
+	ld.SetValue("/Platform", reflect.ValueOf("on"))
+	if _, e := k.API.QueryUpdateDsc(ld); e != nil {
+		t.Fatalf("failed to turn the leader on: %v", e)
+	}
+
+	if r := lastRecord(t, k, 5*time.Second); r.Outcome != pb.MutationPathRecord_COMPLETE {
+		t.Fatalf("expected self to follow the leader, got: %v", r)
+	}
+	if n, _ := k.API.QueryReadDsc(ktest.SelfID); platform(n) != "follow" {
+		t.Errorf("expected /Platform to be follow, got: %q", platform(n))
+	}
+	if f := atomic.LoadInt32(&b.follows); f != follows+1 {
+		t.Errorf("expected blinkyFollow to fire once, got: %d", f-follows)
+	}
+}
+
+// TestDependsTimeout tests that a mutation that waits too long on the nodes it depends on fails, without firing
+func TestDependsTimeout(t *testing.T) {
+	k := ktest.Boot(t, nil, "blinky")
+	b := core.Registry.Modules["blinky"].(*blinky)
+	follows := atomic.LoadInt32(&b.follows)
+	interrupts := atomic.LoadInt32(&b.interrupts)
+
+	start := time.Now()
+	follow(t, k)
+	r := lastRecord(t, k, 10*time.Second)
+	if r.Outcome != pb.MutationPathRecord_FAILED || !strings.Contains(r.Reason, "waiting on") {
+		t.Fatalf("expected self to fail waiting on the leader, got: %v", r)
+	}
+	if d := time.Since(start); d < 2*time.Second {
+		t.Errorf("mutation failed before its timeout: %v", d)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if n, _ := k.API.QueryReadDsc(ktest.SelfID); platform(n) == "fail" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected /Platform to fail")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if f := atomic.LoadInt32(&b.follows); f != follows {
+		t.Errorf("blinkyFollow fired, but the leader was never on")
+	}
+	if i := atomic.LoadInt32(&b.interrupts); i != interrupts {
+		t.Errorf("blinky was interrupted for a mutation it was never asked for")
+	}
+}
+
+// TestRetry tests that a mutation that times out is fired again, and the path completes once it works
+func TestRetry(t *testing.T) {
+	k := ktest.Boot(t, nil, "blinky")
+	b := core.Registry.Modules["blinky"].(*blinky)
+	flakes := atomic.LoadInt32(&b.flakes)
+
+	n, _ := k.API.QueryRead(ktest.SelfID)
+	n.SetValue("/Platform", reflect.ValueOf("flaky"))
+	if _, e := k.API.QueryUpdate(n); e != nil {
+		t.Fatalf("failed to update self: %v", e)
+	}
+	r := lastRecord(t, k, 5*time.Second)
+	if r.Outcome != pb.MutationPathRecord_COMPLETE {
+		t.Fatalf("expected the retry to complete the path, got: %v", r)
+	}
+	if len(r.Steps) != 1 || r.Steps[0].Attempt != 2 {
+		t.Errorf("expected one step that took two attempts, got: %v", r.Steps)
+	}
+	if f := atomic.LoadInt32(&b.flakes); f != flakes+2 {
+		t.Errorf("expected blinkyFlaky to be fired twice, got: %d", f-flakes)
+	}
+	if n, _ := k.API.QueryReadDsc(ktest.SelfID); platform(n) != "flaky" {
+		t.Errorf("expected /Platform to be flaky, got: %q", platform(n))
+	}
+}
+
+// TestHistory_Complete tests the record of a mutation path that completes
+func TestHistory_Complete(t *testing.T) {
+	k := ktest.Boot(t, nil, "blinky")
+	start := time.Now()
+	n, _ := k.API.QueryRead(ktest.SelfID)
+	n.SetValue("/Platform", reflect.ValueOf("on"))
+	if _, e := k.API.QueryUpdate(n); e != nil {
+		t.Fatalf("failed to update self: %v", e)
+	}
+	lastRecord(t, k, 5*time.Second)
+
+	h, e := k.API.QueryNodeMutationHistory(ktest.SelfID)
+	if e != nil || len(h.Records) != 1 {
+		t.Fatalf("expected one record through the API: %v %v", h.Records, e)
+	}
+	r := h.Records[0]
+	if r.Outcome != pb.MutationPathRecord_COMPLETE || r.Reason != "" || r.Node != ktest.SelfID {
+		t.Errorf("expected a complete path for self, got: %v", r)
+	}
+	if !strings.Contains(r.Start, "PhysState: POWER_ON") || !strings.Contains(r.End, "Platform: on") {
+		t.Errorf("unexpected start and end: %q, %q", r.Start, r.End)
+	}
+	started, _ := ptypes.Timestamp(r.Started)
+	ended, _ := ptypes.Timestamp(r.Ended)
+	d, _ := ptypes.Duration(r.Duration)
+	if started.Before(start.Add(-time.Second)) || ended.Before(started) || d != ended.Sub(started) {
+		t.Errorf("bad times: started %v, ended %v, took %v", started, ended, d)
+	}
+	if len(r.Steps) != 1 {
+		t.Fatalf("expected one step, got: %v", r.Steps)
+	}
+	if s := r.Steps[0]; s.Module != "blinky" || s.Mutation != "blinkyOn" || s.Attempt != 1 || s.Ended == nil || s.Error != "" {
+		t.Errorf("unexpected step: %v", s)
+	}
+	if _, e := k.API.QueryNodeMutationHistory("223e4567-e89b-12d3-a456-426655440000"); e != nil {
+		t.Errorf("a node without history should have an empty one: %v", e)
+	}
+}
+
+// setPlatform sets self's /Platform in cfg, and waits until a path to it is active with its first step fired.
+// It returns the history at that point.
+func setPlatform(t *testing.T, k *ktest.Kraken, p, mutation string) pb.MutationHistory {
+	t.Helper()
+	n, _ := k.API.QueryRead(ktest.SelfID)
+	n.SetValue("/Platform", reflect.ValueOf(p))
+	if _, e := k.API.QueryUpdate(n); e != nil {
+		t.Fatalf("failed to update self: %v", e)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		h, e := k.API.QueryNodeMutationHistory(ktest.SelfID)
+		if e == nil && len(h.Records) > 0 {
+			r := h.Records[len(h.Records)-1]
+			if r.Outcome == pb.MutationPathRecord_ACTIVE && len(r.Steps) > 0 && r.Steps[0].Mutation == mutation {
+				return h
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("a path to %s didn't start: %v %v", p, h.Records, e)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestHistory_Interrupted tests that changing cfg interrupts the active path, and that history keeps only so many paths
+func TestHistory_Interrupted(t *testing.T) {
+	k := ktest.Boot(t, nil, "blinky")
+	h := setPlatform(t, k, "fail", "blinkyFail")
+	if len(h.Records) != 1 {
+		t.Fatalf("expected only the active path, got: %v", h.Records)
+	}
+	first := h.Records[0]
+	if first.Ended != nil || first.Steps[0].Ended != nil {
+		t.Errorf("an active path shouldn't have ended: %v", first)
+	}
+
+	h = setPlatform(t, k, "flaky", "blinkyFlaky")
+	if len(h.Records) != 2 {
+		t.Fatalf("expected an interrupted path and an active one, got: %v", h.Records)
+	}
+	r := h.Records[0]
+	if r.Outcome != pb.MutationPathRecord_INTERRUPTED || r.Reason != "cfg changed: /Platform" || r.Ended == nil {
+		t.Errorf("expected the first path to be interrupted by the cfg change, got: %v", r)
+	}
+	if !proto.Equal(r.Started, first.Started) || r.Steps[0].Ended == nil || r.Steps[0].Error != r.Reason {
+		t.Errorf("expected the interrupted path's step to end with it, got: %v", r.Steps)
+	}
+
+	// history is capped at the default HistoryLength (16) finished paths, dropping the oldest
+	const historyLen = 16
+	plats := [][2]string{{"fail", "blinkyFail"}, {"flaky", "blinkyFlaky"}}
+	for i := 0; i < historyLen; i++ {
+		h = setPlatform(t, k, plats[i%2][0], plats[i%2][1])
+	}
+	if len(h.Records) != historyLen+1 {
+		t.Fatalf("expected %d paths and the active one, got %d", historyLen, len(h.Records))
+	}
+	if proto.Equal(h.Records[0].Started, first.Started) {
+		t.Errorf("the oldest path wasn't dropped")
+	}
+	for i, r := range h.Records[:historyLen] {
+		if r.Outcome != pb.MutationPathRecord_INTERRUPTED {
+			t.Errorf("expected path %d to be interrupted, got: %v", i, r.Outcome)
+		}
+		if i > 0 {
+			prev, _ := ptypes.Timestamp(h.Records[i-1].Started)
+			if cur, _ := ptypes.Timestamp(r.Started); cur.Before(prev) {
+				t.Errorf("history isn't oldest first")
+			}
+		}
+	}
+
+	// the active path would keep retrying, and the next test's kraken shares blinky with this one
+	k.Ctx.SME.DrainTime = 100 * time.Millisecond
+	k.Ctx.RPC.DrainTime = time.Second
+	k.Shutdown()
+}
@@ -6,8 +6,11 @@ package proto
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import descriptor "github.com/golang/protobuf/protoc-gen-go/descriptor"
 import any "github.com/golang/protobuf/ptypes/any"
+import duration "github.com/golang/protobuf/ptypes/duration"
 import empty "github.com/golang/protobuf/ptypes/empty"
+import timestamp "github.com/golang/protobuf/ptypes/timestamp"
 
 import (
 	context "golang.org/x/net/context"
@@ -25,6 +28,32 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type PluginMutation_Context int32
+
+const (
+	PluginMutation_SELF  PluginMutation_Context = 0
+	PluginMutation_CHILD PluginMutation_Context = 1
+	PluginMutation_ALL   PluginMutation_Context = 2
+)
+
+var PluginMutation_Context_name = map[int32]string{
+	0: "SELF",
+	1: "CHILD",
+	2: "ALL",
+}
+var PluginMutation_Context_value = map[string]int32{
+	"SELF":  0,
+	"CHILD": 1,
+	"ALL":   2,
+}
+
+func (x PluginMutation_Context) String() string {
+	return proto.EnumName(PluginMutation_Context_name, int32(x))
+}
+func (PluginMutation_Context) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{4, 0}
+}
+
 type ServiceControl_Command int32
 
 const (
@@ -48,7 +77,7 @@ func (x ServiceControl_Command) String() string {
 	return proto.EnumName(ServiceControl_Command_name, int32(x))
 }
 func (ServiceControl_Command) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{3, 0}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{6, 0}
 }
 
 type MutationControl_Type int32
@@ -71,7 +100,7 @@ func (x MutationControl_Type) String() string {
 	return proto.EnumName(MutationControl_Type_name, int32(x))
 }
 func (MutationControl_Type) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{4, 0}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{11, 0}
 }
 
 type StateChangeControl_Type int32
@@ -106,7 +135,7 @@ func (x StateChangeControl_Type) String() string {
 	return proto.EnumName(StateChangeControl_Type_name, int32(x))
 }
 func (StateChangeControl_Type) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{5, 0}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{12, 0}
 }
 
 type EventControl_Type int32
@@ -132,7 +161,36 @@ func (x EventControl_Type) String() string {
 	return proto.EnumName(EventControl_Type_name, int32(x))
 }
 func (EventControl_Type) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{6, 0}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{13, 0}
+}
+
+type MutationPathRecord_Outcome int32
+
+const (
+	MutationPathRecord_ACTIVE      MutationPathRecord_Outcome = 0
+	MutationPathRecord_COMPLETE    MutationPathRecord_Outcome = 1
+	MutationPathRecord_FAILED      MutationPathRecord_Outcome = 2
+	MutationPathRecord_INTERRUPTED MutationPathRecord_Outcome = 3
+)
+
+var MutationPathRecord_Outcome_name = map[int32]string{
+	0: "ACTIVE",
+	1: "COMPLETE",
+	2: "FAILED",
+	3: "INTERRUPTED",
+}
+var MutationPathRecord_Outcome_value = map[string]int32{
+	"ACTIVE":      0,
+	"COMPLETE":    1,
+	"FAILED":      2,
+	"INTERRUPTED": 3,
+}
+
+func (x MutationPathRecord_Outcome) String() string {
+	return proto.EnumName(MutationPathRecord_Outcome_name, int32(x))
+}
+func (MutationPathRecord_Outcome) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{21, 0}
 }
 
 type Query struct {
@@ -144,7 +202,9 @@ type Query struct {
 	//	*Query_MutationNodeList
 	//	*Query_MutationEdgeList
 	//	*Query_MutationPath
+	//	*Query_MutationHistory
 	Payload              isQuery_Payload `protobuf_oneof:"payload"`
+	Version              string          `protobuf:"bytes,9,opt,name=version,proto3" json:"version,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
 	XXX_unrecognized     []byte          `json:"-"`
 	XXX_sizecache        int32           `json:"-"`
@@ -154,7 +214,7 @@ func (m *Query) Reset()         { *m = Query{} }
 func (m *Query) String() string { return proto.CompactTextString(m) }
 func (*Query) ProtoMessage()    {}
 func (*Query) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{0}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{0}
 }
 func (m *Query) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Query.Unmarshal(m, b)
@@ -209,6 +269,10 @@ type Query_MutationPath struct {
 	MutationPath *MutationPath `protobuf:"bytes,7,opt,name=mutationPath,proto3,oneof"`
 }
 
+type Query_MutationHistory struct {
+	MutationHistory *MutationHistory `protobuf:"bytes,8,opt,name=mutationHistory,proto3,oneof"`
+}
+
 func (*Query_Node) isQuery_Payload() {}
 
 func (*Query_Text) isQuery_Payload() {}
@@ -221,6 +285,8 @@ func (*Query_MutationEdgeList) isQuery_Payload() {}
 
 func (*Query_MutationPath) isQuery_Payload() {}
 
+func (*Query_MutationHistory) isQuery_Payload() {}
+
 func (m *Query) GetPayload() isQuery_Payload {
 	if m != nil {
 		return m.Payload
@@ -270,6 +336,20 @@ func (m *Query) GetMutationPath() *MutationPath {
 	return nil
 }
 
+func (m *Query) GetMutationHistory() *MutationHistory {
+	if x, ok := m.GetPayload().(*Query_MutationHistory); ok {
+		return x.MutationHistory
+	}
+	return nil
+}
+
+func (m *Query) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*Query) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
 	return _Query_OneofMarshaler, _Query_OneofUnmarshaler, _Query_OneofSizer, []interface{}{
@@ -279,6 +359,7 @@ func (*Query) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error,
 		(*Query_MutationNodeList)(nil),
 		(*Query_MutationEdgeList)(nil),
 		(*Query_MutationPath)(nil),
+		(*Query_MutationHistory)(nil),
 	}
 }
 
@@ -316,6 +397,11 @@ func _Query_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
 		if err := b.EncodeMessage(x.MutationPath); err != nil {
 			return err
 		}
+	case *Query_MutationHistory:
+		b.EncodeVarint(8<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.MutationHistory); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("Query.Payload has unexpected type %T", x)
@@ -372,6 +458,14 @@ func _Query_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer)
 		err := b.DecodeMessage(msg)
 		m.Payload = &Query_MutationPath{msg}
 		return true, err
+	case 8: // payload.mutationHistory
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(MutationHistory)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Query_MutationHistory{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -408,6 +502,11 @@ func _Query_OneofSizer(msg proto.Message) (n int) {
 		n += 1 // tag and wire
 		n += proto.SizeVarint(uint64(s))
 		n += s
+	case *Query_MutationHistory:
+		s := proto.Size(x.MutationHistory)
+		n += 1 // tag and wire
+		n += proto.SizeVarint(uint64(s))
+		n += s
 	case nil:
 	default:
 		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
@@ -426,7 +525,7 @@ func (m *QueryMulti) Reset()         { *m = QueryMulti{} }
 func (m *QueryMulti) String() string { return proto.CompactTextString(m) }
 func (*QueryMulti) ProtoMessage()    {}
 func (*QueryMulti) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{1}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{1}
 }
 func (m *QueryMulti) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_QueryMulti.Unmarshal(m, b)
@@ -454,18 +553,21 @@ func (m *QueryMulti) GetQueries() []*Query {
 }
 
 type ServiceInitRequest struct {
-	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Module               string   `protobuf:"bytes,2,opt,name=module,proto3" json:"module,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Id                   string              `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Module               string              `protobuf:"bytes,2,opt,name=module,proto3" json:"module,omitempty"`
+	Replay               uint64              `protobuf:"varint,3,opt,name=replay,proto3" json:"replay,omitempty"`
+	Resume               bool                `protobuf:"varint,4,opt,name=resume,proto3" json:"resume,omitempty"`
+	Plugin               *PluginRegistration `protobuf:"bytes,5,opt,name=plugin,proto3" json:"plugin,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
 }
 
 func (m *ServiceInitRequest) Reset()         { *m = ServiceInitRequest{} }
 func (m *ServiceInitRequest) String() string { return proto.CompactTextString(m) }
 func (*ServiceInitRequest) ProtoMessage()    {}
 func (*ServiceInitRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{2}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{2}
 }
 func (m *ServiceInitRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ServiceInitRequest.Unmarshal(m, b)
@@ -499,6 +601,261 @@ func (m *ServiceInitRequest) GetModule() string {
 	return ""
 }
 
+func (m *ServiceInitRequest) GetReplay() uint64 {
+	if m != nil {
+		return m.Replay
+	}
+	return 0
+}
+
+func (m *ServiceInitRequest) GetResume() bool {
+	if m != nil {
+		return m.Resume
+	}
+	return false
+}
+
+func (m *ServiceInitRequest) GetPlugin() *PluginRegistration {
+	if m != nil {
+		return m.Plugin
+	}
+	return nil
+}
+
+// PluginRegistration is what a plugin (a module that isn't compiled into kraken) tells kraken about itself.
+// Values are given as JSON, and are decoded as the type of the node URL they're for.
+type PluginRegistration struct {
+	Files                []*descriptor.FileDescriptorProto `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	ConfigType           string                            `protobuf:"bytes,2,opt,name=config_type,json=configType,proto3" json:"config_type,omitempty"`
+	Mutations            map[string]*PluginMutation        `protobuf:"bytes,3,rep,name=mutations,proto3" json:"mutations,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Discoverables        map[string]*PluginDiscoverable    `protobuf:"bytes,4,rep,name=discoverables,proto3" json:"discoverables,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}                          `json:"-"`
+	XXX_unrecognized     []byte                            `json:"-"`
+	XXX_sizecache        int32                             `json:"-"`
+}
+
+func (m *PluginRegistration) Reset()         { *m = PluginRegistration{} }
+func (m *PluginRegistration) String() string { return proto.CompactTextString(m) }
+func (*PluginRegistration) ProtoMessage()    {}
+func (*PluginRegistration) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{3}
+}
+func (m *PluginRegistration) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PluginRegistration.Unmarshal(m, b)
+}
+func (m *PluginRegistration) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PluginRegistration.Marshal(b, m, deterministic)
+}
+func (dst *PluginRegistration) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PluginRegistration.Merge(dst, src)
+}
+func (m *PluginRegistration) XXX_Size() int {
+	return xxx_messageInfo_PluginRegistration.Size(m)
+}
+func (m *PluginRegistration) XXX_DiscardUnknown() {
+	xxx_messageInfo_PluginRegistration.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PluginRegistration proto.InternalMessageInfo
+
+func (m *PluginRegistration) GetFiles() []*descriptor.FileDescriptorProto {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+func (m *PluginRegistration) GetConfigType() string {
+	if m != nil {
+		return m.ConfigType
+	}
+	return ""
+}
+
+func (m *PluginRegistration) GetMutations() map[string]*PluginMutation {
+	if m != nil {
+		return m.Mutations
+	}
+	return nil
+}
+
+func (m *PluginRegistration) GetDiscoverables() map[string]*PluginDiscoverable {
+	if m != nil {
+		return m.Discoverables
+	}
+	return nil
+}
+
+type PluginMutation struct {
+	Mutates              map[string]*PluginMutation_Change `protobuf:"bytes,1,rep,name=mutates,proto3" json:"mutates,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Requires             map[string]string                 `protobuf:"bytes,2,rep,name=requires,proto3" json:"requires,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Excludes             map[string]string                 `protobuf:"bytes,3,rep,name=excludes,proto3" json:"excludes,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Context              PluginMutation_Context            `protobuf:"varint,4,opt,name=context,proto3,enum=proto.PluginMutation_Context" json:"context,omitempty"`
+	Timeout              *duration.Duration                `protobuf:"bytes,5,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	FailToUrl            string                            `protobuf:"bytes,6,opt,name=fail_to_url,json=failToUrl,proto3" json:"fail_to_url,omitempty"`
+	FailToValue          string                            `protobuf:"bytes,7,opt,name=fail_to_value,json=failToValue,proto3" json:"fail_to_value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                          `json:"-"`
+	XXX_unrecognized     []byte                            `json:"-"`
+	XXX_sizecache        int32                             `json:"-"`
+}
+
+func (m *PluginMutation) Reset()         { *m = PluginMutation{} }
+func (m *PluginMutation) String() string { return proto.CompactTextString(m) }
+func (*PluginMutation) ProtoMessage()    {}
+func (*PluginMutation) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{4}
+}
+func (m *PluginMutation) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PluginMutation.Unmarshal(m, b)
+}
+func (m *PluginMutation) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PluginMutation.Marshal(b, m, deterministic)
+}
+func (dst *PluginMutation) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PluginMutation.Merge(dst, src)
+}
+func (m *PluginMutation) XXX_Size() int {
+	return xxx_messageInfo_PluginMutation.Size(m)
+}
+func (m *PluginMutation) XXX_DiscardUnknown() {
+	xxx_messageInfo_PluginMutation.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PluginMutation proto.InternalMessageInfo
+
+func (m *PluginMutation) GetMutates() map[string]*PluginMutation_Change {
+	if m != nil {
+		return m.Mutates
+	}
+	return nil
+}
+
+func (m *PluginMutation) GetRequires() map[string]string {
+	if m != nil {
+		return m.Requires
+	}
+	return nil
+}
+
+func (m *PluginMutation) GetExcludes() map[string]string {
+	if m != nil {
+		return m.Excludes
+	}
+	return nil
+}
+
+func (m *PluginMutation) GetContext() PluginMutation_Context {
+	if m != nil {
+		return m.Context
+	}
+	return PluginMutation_SELF
+}
+
+func (m *PluginMutation) GetTimeout() *duration.Duration {
+	if m != nil {
+		return m.Timeout
+	}
+	return nil
+}
+
+func (m *PluginMutation) GetFailToUrl() string {
+	if m != nil {
+		return m.FailToUrl
+	}
+	return ""
+}
+
+func (m *PluginMutation) GetFailToValue() string {
+	if m != nil {
+		return m.FailToValue
+	}
+	return ""
+}
+
+type PluginMutation_Change struct {
+	From                 string   `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To                   string   `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PluginMutation_Change) Reset()         { *m = PluginMutation_Change{} }
+func (m *PluginMutation_Change) String() string { return proto.CompactTextString(m) }
+func (*PluginMutation_Change) ProtoMessage()    {}
+func (*PluginMutation_Change) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{4, 0}
+}
+func (m *PluginMutation_Change) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PluginMutation_Change.Unmarshal(m, b)
+}
+func (m *PluginMutation_Change) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PluginMutation_Change.Marshal(b, m, deterministic)
+}
+func (dst *PluginMutation_Change) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PluginMutation_Change.Merge(dst, src)
+}
+func (m *PluginMutation_Change) XXX_Size() int {
+	return xxx_messageInfo_PluginMutation_Change.Size(m)
+}
+func (m *PluginMutation_Change) XXX_DiscardUnknown() {
+	xxx_messageInfo_PluginMutation_Change.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PluginMutation_Change proto.InternalMessageInfo
+
+func (m *PluginMutation_Change) GetFrom() string {
+	if m != nil {
+		return m.From
+	}
+	return ""
+}
+
+func (m *PluginMutation_Change) GetTo() string {
+	if m != nil {
+		return m.To
+	}
+	return ""
+}
+
+type PluginDiscoverable struct {
+	Values               map[string]string `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *PluginDiscoverable) Reset()         { *m = PluginDiscoverable{} }
+func (m *PluginDiscoverable) String() string { return proto.CompactTextString(m) }
+func (*PluginDiscoverable) ProtoMessage()    {}
+func (*PluginDiscoverable) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{5}
+}
+func (m *PluginDiscoverable) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PluginDiscoverable.Unmarshal(m, b)
+}
+func (m *PluginDiscoverable) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PluginDiscoverable.Marshal(b, m, deterministic)
+}
+func (dst *PluginDiscoverable) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PluginDiscoverable.Merge(dst, src)
+}
+func (m *PluginDiscoverable) XXX_Size() int {
+	return xxx_messageInfo_PluginDiscoverable.Size(m)
+}
+func (m *PluginDiscoverable) XXX_DiscardUnknown() {
+	xxx_messageInfo_PluginDiscoverable.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PluginDiscoverable proto.InternalMessageInfo
+
+func (m *PluginDiscoverable) GetValues() map[string]string {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
 type ServiceControl struct {
 	Command              ServiceControl_Command `protobuf:"varint,1,opt,name=command,proto3,enum=proto.ServiceControl_Command" json:"command,omitempty"`
 	Config               *any.Any               `protobuf:"bytes,2,opt,name=config,proto3" json:"config,omitempty"`
@@ -511,7 +868,7 @@ func (m *ServiceControl) Reset()         { *m = ServiceControl{} }
 func (m *ServiceControl) String() string { return proto.CompactTextString(m) }
 func (*ServiceControl) ProtoMessage()    {}
 func (*ServiceControl) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{3}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{6}
 }
 func (m *ServiceControl) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ServiceControl.Unmarshal(m, b)
@@ -545,12 +902,230 @@ func (m *ServiceControl) GetConfig() *any.Any {
 	return nil
 }
 
+type ServiceRequest struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Config               *any.Any `protobuf:"bytes,2,opt,name=config,proto3" json:"config,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ServiceRequest) Reset()         { *m = ServiceRequest{} }
+func (m *ServiceRequest) String() string { return proto.CompactTextString(m) }
+func (*ServiceRequest) ProtoMessage()    {}
+func (*ServiceRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{7}
+}
+func (m *ServiceRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ServiceRequest.Unmarshal(m, b)
+}
+func (m *ServiceRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ServiceRequest.Marshal(b, m, deterministic)
+}
+func (dst *ServiceRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServiceRequest.Merge(dst, src)
+}
+func (m *ServiceRequest) XXX_Size() int {
+	return xxx_messageInfo_ServiceRequest.Size(m)
+}
+func (m *ServiceRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ServiceRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ServiceRequest proto.InternalMessageInfo
+
+func (m *ServiceRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *ServiceRequest) GetConfig() *any.Any {
+	if m != nil {
+		return m.Config
+	}
+	return nil
+}
+
+type ServiceStatus struct {
+	Id                   string                       `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Module               string                       `protobuf:"bytes,2,opt,name=module,proto3" json:"module,omitempty"`
+	Cfg                  ServiceInstance_ServiceState `protobuf:"varint,3,opt,name=cfg,proto3,enum=proto.ServiceInstance_ServiceState" json:"cfg,omitempty"`
+	Dsc                  ServiceInstance_ServiceState `protobuf:"varint,4,opt,name=dsc,proto3,enum=proto.ServiceInstance_ServiceState" json:"dsc,omitempty"`
+	ErrorMsg             string                       `protobuf:"bytes,5,opt,name=error_msg,json=errorMsg,proto3" json:"error_msg,omitempty"`
+	Config               *any.Any                     `protobuf:"bytes,6,opt,name=config,proto3" json:"config,omitempty"`
+	LastHeartbeat        *timestamp.Timestamp         `protobuf:"bytes,7,opt,name=last_heartbeat,json=lastHeartbeat,proto3" json:"last_heartbeat,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                     `json:"-"`
+	XXX_unrecognized     []byte                       `json:"-"`
+	XXX_sizecache        int32                        `json:"-"`
+}
+
+func (m *ServiceStatus) Reset()         { *m = ServiceStatus{} }
+func (m *ServiceStatus) String() string { return proto.CompactTextString(m) }
+func (*ServiceStatus) ProtoMessage()    {}
+func (*ServiceStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{8}
+}
+func (m *ServiceStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ServiceStatus.Unmarshal(m, b)
+}
+func (m *ServiceStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ServiceStatus.Marshal(b, m, deterministic)
+}
+func (dst *ServiceStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServiceStatus.Merge(dst, src)
+}
+func (m *ServiceStatus) XXX_Size() int {
+	return xxx_messageInfo_ServiceStatus.Size(m)
+}
+func (m *ServiceStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_ServiceStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ServiceStatus proto.InternalMessageInfo
+
+func (m *ServiceStatus) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *ServiceStatus) GetModule() string {
+	if m != nil {
+		return m.Module
+	}
+	return ""
+}
+
+func (m *ServiceStatus) GetCfg() ServiceInstance_ServiceState {
+	if m != nil {
+		return m.Cfg
+	}
+	return ServiceInstance_UNKNOWN
+}
+
+func (m *ServiceStatus) GetDsc() ServiceInstance_ServiceState {
+	if m != nil {
+		return m.Dsc
+	}
+	return ServiceInstance_UNKNOWN
+}
+
+func (m *ServiceStatus) GetErrorMsg() string {
+	if m != nil {
+		return m.ErrorMsg
+	}
+	return ""
+}
+
+func (m *ServiceStatus) GetConfig() *any.Any {
+	if m != nil {
+		return m.Config
+	}
+	return nil
+}
+
+func (m *ServiceStatus) GetLastHeartbeat() *timestamp.Timestamp {
+	if m != nil {
+		return m.LastHeartbeat
+	}
+	return nil
+}
+
+type ServiceHeartbeatRequest struct {
+	Id                   string             `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Interval             *duration.Duration `protobuf:"bytes,2,opt,name=interval,proto3" json:"interval,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *ServiceHeartbeatRequest) Reset()         { *m = ServiceHeartbeatRequest{} }
+func (m *ServiceHeartbeatRequest) String() string { return proto.CompactTextString(m) }
+func (*ServiceHeartbeatRequest) ProtoMessage()    {}
+func (*ServiceHeartbeatRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{9}
+}
+func (m *ServiceHeartbeatRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ServiceHeartbeatRequest.Unmarshal(m, b)
+}
+func (m *ServiceHeartbeatRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ServiceHeartbeatRequest.Marshal(b, m, deterministic)
+}
+func (dst *ServiceHeartbeatRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServiceHeartbeatRequest.Merge(dst, src)
+}
+func (m *ServiceHeartbeatRequest) XXX_Size() int {
+	return xxx_messageInfo_ServiceHeartbeatRequest.Size(m)
+}
+func (m *ServiceHeartbeatRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ServiceHeartbeatRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ServiceHeartbeatRequest proto.InternalMessageInfo
+
+func (m *ServiceHeartbeatRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *ServiceHeartbeatRequest) GetInterval() *duration.Duration {
+	if m != nil {
+		return m.Interval
+	}
+	return nil
+}
+
+type ServiceStatusList struct {
+	Services             []*ServiceStatus `protobuf:"bytes,1,rep,name=services,proto3" json:"services,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *ServiceStatusList) Reset()         { *m = ServiceStatusList{} }
+func (m *ServiceStatusList) String() string { return proto.CompactTextString(m) }
+func (*ServiceStatusList) ProtoMessage()    {}
+func (*ServiceStatusList) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{10}
+}
+func (m *ServiceStatusList) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ServiceStatusList.Unmarshal(m, b)
+}
+func (m *ServiceStatusList) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ServiceStatusList.Marshal(b, m, deterministic)
+}
+func (dst *ServiceStatusList) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServiceStatusList.Merge(dst, src)
+}
+func (m *ServiceStatusList) XXX_Size() int {
+	return xxx_messageInfo_ServiceStatusList.Size(m)
+}
+func (m *ServiceStatusList) XXX_DiscardUnknown() {
+	xxx_messageInfo_ServiceStatusList.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ServiceStatusList proto.InternalMessageInfo
+
+func (m *ServiceStatusList) GetServices() []*ServiceStatus {
+	if m != nil {
+		return m.Services
+	}
+	return nil
+}
+
 type MutationControl struct {
 	Module               string               `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
 	Id                   string               `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
 	Type                 MutationControl_Type `protobuf:"varint,3,opt,name=type,proto3,enum=proto.MutationControl_Type" json:"type,omitempty"`
 	Cfg                  *Node                `protobuf:"bytes,4,opt,name=cfg,proto3" json:"cfg,omitempty"`
 	Dsc                  *Node                `protobuf:"bytes,5,opt,name=dsc,proto3" json:"dsc,omitempty"`
+	Attempt              uint32               `protobuf:"varint,6,opt,name=attempt,proto3" json:"attempt,omitempty"`
+	Traceparent          string               `protobuf:"bytes,7,opt,name=traceparent,proto3" json:"traceparent,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
 	XXX_unrecognized     []byte               `json:"-"`
 	XXX_sizecache        int32                `json:"-"`
@@ -560,7 +1135,7 @@ func (m *MutationControl) Reset()         { *m = MutationControl{} }
 func (m *MutationControl) String() string { return proto.CompactTextString(m) }
 func (*MutationControl) ProtoMessage()    {}
 func (*MutationControl) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{4}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{11}
 }
 func (m *MutationControl) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_MutationControl.Unmarshal(m, b)
@@ -615,6 +1190,20 @@ func (m *MutationControl) GetDsc() *Node {
 	return nil
 }
 
+func (m *MutationControl) GetAttempt() uint32 {
+	if m != nil {
+		return m.Attempt
+	}
+	return 0
+}
+
+func (m *MutationControl) GetTraceparent() string {
+	if m != nil {
+		return m.Traceparent
+	}
+	return ""
+}
+
 type StateChangeControl struct {
 	Type                 StateChangeControl_Type `protobuf:"varint,1,opt,name=type,proto3,enum=proto.StateChangeControl_Type" json:"type,omitempty"`
 	Url                  string                  `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
@@ -628,7 +1217,7 @@ func (m *StateChangeControl) Reset()         { *m = StateChangeControl{} }
 func (m *StateChangeControl) String() string { return proto.CompactTextString(m) }
 func (*StateChangeControl) ProtoMessage()    {}
 func (*StateChangeControl) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{5}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{12}
 }
 func (m *StateChangeControl) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_StateChangeControl.Unmarshal(m, b)
@@ -676,6 +1265,7 @@ type EventControl struct {
 	//	*EventControl_MutationControl
 	//	*EventControl_DiscoveryEvent
 	Event                isEventControl_Event `protobuf_oneof:"event"`
+	Seq                  uint64               `protobuf:"varint,5,opt,name=seq,proto3" json:"seq,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
 	XXX_unrecognized     []byte               `json:"-"`
 	XXX_sizecache        int32                `json:"-"`
@@ -685,7 +1275,7 @@ func (m *EventControl) Reset()         { *m = EventControl{} }
 func (m *EventControl) String() string { return proto.CompactTextString(m) }
 func (*EventControl) ProtoMessage()    {}
 func (*EventControl) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{6}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{13}
 }
 func (m *EventControl) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_EventControl.Unmarshal(m, b)
@@ -762,6 +1352,13 @@ func (m *EventControl) GetDiscoveryEvent() *DiscoveryEvent {
 	return nil
 }
 
+func (m *EventControl) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*EventControl) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
 	return _EventControl_OneofMarshaler, _EventControl_OneofUnmarshaler, _EventControl_OneofSizer, []interface{}{
@@ -855,10 +1452,103 @@ func _EventControl_OneofSizer(msg proto.Message) (n int) {
 	return n
 }
 
+type MetricsReply struct {
+	Text                 string   `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MetricsReply) Reset()         { *m = MetricsReply{} }
+func (m *MetricsReply) String() string { return proto.CompactTextString(m) }
+func (*MetricsReply) ProtoMessage()    {}
+func (*MetricsReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{14}
+}
+func (m *MetricsReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MetricsReply.Unmarshal(m, b)
+}
+func (m *MetricsReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MetricsReply.Marshal(b, m, deterministic)
+}
+func (dst *MetricsReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MetricsReply.Merge(dst, src)
+}
+func (m *MetricsReply) XXX_Size() int {
+	return xxx_messageInfo_MetricsReply.Size(m)
+}
+func (m *MetricsReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_MetricsReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MetricsReply proto.InternalMessageInfo
+
+func (m *MetricsReply) GetText() string {
+	if m != nil {
+		return m.Text
+	}
+	return ""
+}
+
+type WatchRequest struct {
+	Id                   string              `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	From                 uint64              `protobuf:"varint,2,opt,name=from,proto3" json:"from,omitempty"`
+	Types                []EventControl_Type `protobuf:"varint,3,rep,packed,name=types,proto3,enum=proto.EventControl_Type" json:"types,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *WatchRequest) Reset()         { *m = WatchRequest{} }
+func (m *WatchRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchRequest) ProtoMessage()    {}
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{15}
+}
+func (m *WatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchRequest.Unmarshal(m, b)
+}
+func (m *WatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchRequest.Marshal(b, m, deterministic)
+}
+func (dst *WatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchRequest.Merge(dst, src)
+}
+func (m *WatchRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchRequest.Size(m)
+}
+func (m *WatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchRequest proto.InternalMessageInfo
+
+func (m *WatchRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *WatchRequest) GetFrom() uint64 {
+	if m != nil {
+		return m.From
+	}
+	return 0
+}
+
+func (m *WatchRequest) GetTypes() []EventControl_Type {
+	if m != nil {
+		return m.Types
+	}
+	return nil
+}
+
 type DiscoveryEvent struct {
 	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Url                  string   `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
 	ValueId              string   `protobuf:"bytes,3,opt,name=value_id,json=valueId,proto3" json:"value_id,omitempty"`
+	Traceparent          string   `protobuf:"bytes,4,opt,name=traceparent,proto3" json:"traceparent,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -868,7 +1558,7 @@ func (m *DiscoveryEvent) Reset()         { *m = DiscoveryEvent{} }
 func (m *DiscoveryEvent) String() string { return proto.CompactTextString(m) }
 func (*DiscoveryEvent) ProtoMessage()    {}
 func (*DiscoveryEvent) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{7}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{16}
 }
 func (m *DiscoveryEvent) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_DiscoveryEvent.Unmarshal(m, b)
@@ -909,6 +1599,13 @@ func (m *DiscoveryEvent) GetValueId() string {
 	return ""
 }
 
+func (m *DiscoveryEvent) GetTraceparent() string {
+	if m != nil {
+		return m.Traceparent
+	}
+	return ""
+}
+
 type MutationNodeList struct {
 	MutationNodeList     []*MutationNode `protobuf:"bytes,1,rep,name=MutationNodeList,proto3" json:"MutationNodeList,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
@@ -920,7 +1617,7 @@ func (m *MutationNodeList) Reset()         { *m = MutationNodeList{} }
 func (m *MutationNodeList) String() string { return proto.CompactTextString(m) }
 func (*MutationNodeList) ProtoMessage()    {}
 func (*MutationNodeList) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{8}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{17}
 }
 func (m *MutationNodeList) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_MutationNodeList.Unmarshal(m, b)
@@ -958,7 +1655,7 @@ func (m *MutationEdgeList) Reset()         { *m = MutationEdgeList{} }
 func (m *MutationEdgeList) String() string { return proto.CompactTextString(m) }
 func (*MutationEdgeList) ProtoMessage()    {}
 func (*MutationEdgeList) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{9}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{18}
 }
 func (m *MutationEdgeList) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_MutationEdgeList.Unmarshal(m, b)
@@ -989,6 +1686,9 @@ type MutationPath struct {
 	Cur                  int64           `protobuf:"varint,1,opt,name=cur,proto3" json:"cur,omitempty"`
 	Cmplt                bool            `protobuf:"varint,2,opt,name=cmplt,proto3" json:"cmplt,omitempty"`
 	Chain                []*MutationEdge `protobuf:"bytes,3,rep,name=chain,proto3" json:"chain,omitempty"`
+	Attempt              uint32          `protobuf:"varint,4,opt,name=attempt,proto3" json:"attempt,omitempty"`
+	Retries              uint32          `protobuf:"varint,5,opt,name=retries,proto3" json:"retries,omitempty"`
+	Waiting              string          `protobuf:"bytes,6,opt,name=waiting,proto3" json:"waiting,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
 	XXX_unrecognized     []byte          `json:"-"`
 	XXX_sizecache        int32           `json:"-"`
@@ -998,7 +1698,7 @@ func (m *MutationPath) Reset()         { *m = MutationPath{} }
 func (m *MutationPath) String() string { return proto.CompactTextString(m) }
 func (*MutationPath) ProtoMessage()    {}
 func (*MutationPath) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{10}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{19}
 }
 func (m *MutationPath) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_MutationPath.Unmarshal(m, b)
@@ -1039,6 +1739,261 @@ func (m *MutationPath) GetChain() []*MutationEdge {
 	return nil
 }
 
+func (m *MutationPath) GetAttempt() uint32 {
+	if m != nil {
+		return m.Attempt
+	}
+	return 0
+}
+
+func (m *MutationPath) GetRetries() uint32 {
+	if m != nil {
+		return m.Retries
+	}
+	return 0
+}
+
+func (m *MutationPath) GetWaiting() string {
+	if m != nil {
+		return m.Waiting
+	}
+	return ""
+}
+
+type MutationStep struct {
+	Id                   string               `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Module               string               `protobuf:"bytes,2,opt,name=module,proto3" json:"module,omitempty"`
+	Mutation             string               `protobuf:"bytes,3,opt,name=mutation,proto3" json:"mutation,omitempty"`
+	Attempt              uint32               `protobuf:"varint,4,opt,name=attempt,proto3" json:"attempt,omitempty"`
+	Started              *timestamp.Timestamp `protobuf:"bytes,5,opt,name=started,proto3" json:"started,omitempty"`
+	Ended                *timestamp.Timestamp `protobuf:"bytes,6,opt,name=ended,proto3" json:"ended,omitempty"`
+	Error                string               `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *MutationStep) Reset()         { *m = MutationStep{} }
+func (m *MutationStep) String() string { return proto.CompactTextString(m) }
+func (*MutationStep) ProtoMessage()    {}
+func (*MutationStep) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{20}
+}
+func (m *MutationStep) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MutationStep.Unmarshal(m, b)
+}
+func (m *MutationStep) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MutationStep.Marshal(b, m, deterministic)
+}
+func (dst *MutationStep) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MutationStep.Merge(dst, src)
+}
+func (m *MutationStep) XXX_Size() int {
+	return xxx_messageInfo_MutationStep.Size(m)
+}
+func (m *MutationStep) XXX_DiscardUnknown() {
+	xxx_messageInfo_MutationStep.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MutationStep proto.InternalMessageInfo
+
+func (m *MutationStep) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *MutationStep) GetModule() string {
+	if m != nil {
+		return m.Module
+	}
+	return ""
+}
+
+func (m *MutationStep) GetMutation() string {
+	if m != nil {
+		return m.Mutation
+	}
+	return ""
+}
+
+func (m *MutationStep) GetAttempt() uint32 {
+	if m != nil {
+		return m.Attempt
+	}
+	return 0
+}
+
+func (m *MutationStep) GetStarted() *timestamp.Timestamp {
+	if m != nil {
+		return m.Started
+	}
+	return nil
+}
+
+func (m *MutationStep) GetEnded() *timestamp.Timestamp {
+	if m != nil {
+		return m.Ended
+	}
+	return nil
+}
+
+func (m *MutationStep) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type MutationPathRecord struct {
+	Node                 string                     `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	Start                string                     `protobuf:"bytes,2,opt,name=start,proto3" json:"start,omitempty"`
+	End                  string                     `protobuf:"bytes,3,opt,name=end,proto3" json:"end,omitempty"`
+	Started              *timestamp.Timestamp       `protobuf:"bytes,4,opt,name=started,proto3" json:"started,omitempty"`
+	Ended                *timestamp.Timestamp       `protobuf:"bytes,5,opt,name=ended,proto3" json:"ended,omitempty"`
+	Duration             *duration.Duration         `protobuf:"bytes,6,opt,name=duration,proto3" json:"duration,omitempty"`
+	Outcome              MutationPathRecord_Outcome `protobuf:"varint,7,opt,name=outcome,proto3,enum=proto.MutationPathRecord_Outcome" json:"outcome,omitempty"`
+	Reason               string                     `protobuf:"bytes,8,opt,name=reason,proto3" json:"reason,omitempty"`
+	Steps                []*MutationStep            `protobuf:"bytes,9,rep,name=steps,proto3" json:"steps,omitempty"`
+	TraceId              string                     `protobuf:"bytes,10,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                   `json:"-"`
+	XXX_unrecognized     []byte                     `json:"-"`
+	XXX_sizecache        int32                      `json:"-"`
+}
+
+func (m *MutationPathRecord) Reset()         { *m = MutationPathRecord{} }
+func (m *MutationPathRecord) String() string { return proto.CompactTextString(m) }
+func (*MutationPathRecord) ProtoMessage()    {}
+func (*MutationPathRecord) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{21}
+}
+func (m *MutationPathRecord) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MutationPathRecord.Unmarshal(m, b)
+}
+func (m *MutationPathRecord) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MutationPathRecord.Marshal(b, m, deterministic)
+}
+func (dst *MutationPathRecord) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MutationPathRecord.Merge(dst, src)
+}
+func (m *MutationPathRecord) XXX_Size() int {
+	return xxx_messageInfo_MutationPathRecord.Size(m)
+}
+func (m *MutationPathRecord) XXX_DiscardUnknown() {
+	xxx_messageInfo_MutationPathRecord.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MutationPathRecord proto.InternalMessageInfo
+
+func (m *MutationPathRecord) GetNode() string {
+	if m != nil {
+		return m.Node
+	}
+	return ""
+}
+
+func (m *MutationPathRecord) GetStart() string {
+	if m != nil {
+		return m.Start
+	}
+	return ""
+}
+
+func (m *MutationPathRecord) GetEnd() string {
+	if m != nil {
+		return m.End
+	}
+	return ""
+}
+
+func (m *MutationPathRecord) GetStarted() *timestamp.Timestamp {
+	if m != nil {
+		return m.Started
+	}
+	return nil
+}
+
+func (m *MutationPathRecord) GetEnded() *timestamp.Timestamp {
+	if m != nil {
+		return m.Ended
+	}
+	return nil
+}
+
+func (m *MutationPathRecord) GetDuration() *duration.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
+
+func (m *MutationPathRecord) GetOutcome() MutationPathRecord_Outcome {
+	if m != nil {
+		return m.Outcome
+	}
+	return MutationPathRecord_ACTIVE
+}
+
+func (m *MutationPathRecord) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *MutationPathRecord) GetSteps() []*MutationStep {
+	if m != nil {
+		return m.Steps
+	}
+	return nil
+}
+
+func (m *MutationPathRecord) GetTraceId() string {
+	if m != nil {
+		return m.TraceId
+	}
+	return ""
+}
+
+type MutationHistory struct {
+	Records              []*MutationPathRecord `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
+	XXX_unrecognized     []byte                `json:"-"`
+	XXX_sizecache        int32                 `json:"-"`
+}
+
+func (m *MutationHistory) Reset()         { *m = MutationHistory{} }
+func (m *MutationHistory) String() string { return proto.CompactTextString(m) }
+func (*MutationHistory) ProtoMessage()    {}
+func (*MutationHistory) Descriptor() ([]byte, []int) {
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{22}
+}
+func (m *MutationHistory) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MutationHistory.Unmarshal(m, b)
+}
+func (m *MutationHistory) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MutationHistory.Marshal(b, m, deterministic)
+}
+func (dst *MutationHistory) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MutationHistory.Merge(dst, src)
+}
+func (m *MutationHistory) XXX_Size() int {
+	return xxx_messageInfo_MutationHistory.Size(m)
+}
+func (m *MutationHistory) XXX_DiscardUnknown() {
+	xxx_messageInfo_MutationHistory.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MutationHistory proto.InternalMessageInfo
+
+func (m *MutationHistory) GetRecords() []*MutationPathRecord {
+	if m != nil {
+		return m.Records
+	}
+	return nil
+}
+
 type MutationNode struct {
 	Label                string     `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`
 	Id                   string     `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
@@ -1052,7 +2007,7 @@ func (m *MutationNode) Reset()         { *m = MutationNode{} }
 func (m *MutationNode) String() string { return proto.CompactTextString(m) }
 func (*MutationNode) ProtoMessage()    {}
 func (*MutationNode) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{11}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{23}
 }
 func (m *MutationNode) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_MutationNode.Unmarshal(m, b)
@@ -1107,7 +2062,7 @@ func (m *MutationEdge) Reset()         { *m = MutationEdge{} }
 func (m *MutationEdge) String() string { return proto.CompactTextString(m) }
 func (*MutationEdge) ProtoMessage()    {}
 func (*MutationEdge) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{12}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{24}
 }
 func (m *MutationEdge) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_MutationEdge.Unmarshal(m, b)
@@ -1169,7 +2124,7 @@ func (m *EdgeColor) Reset()         { *m = EdgeColor{} }
 func (m *EdgeColor) String() string { return proto.CompactTextString(m) }
 func (*EdgeColor) ProtoMessage()    {}
 func (*EdgeColor) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{13}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{25}
 }
 func (m *EdgeColor) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_EdgeColor.Unmarshal(m, b)
@@ -1223,7 +2178,7 @@ func (m *NodeColor) Reset()         { *m = NodeColor{} }
 func (m *NodeColor) String() string { return proto.CompactTextString(m) }
 func (*NodeColor) ProtoMessage()    {}
 func (*NodeColor) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{14}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{26}
 }
 func (m *NodeColor) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_NodeColor.Unmarshal(m, b)
@@ -1270,7 +2225,7 @@ func (m *LogMessage) Reset()         { *m = LogMessage{} }
 func (m *LogMessage) String() string { return proto.CompactTextString(m) }
 func (*LogMessage) ProtoMessage()    {}
 func (*LogMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_API_e7e18ec3f24e7299, []int{15}
+	return fileDescriptor_API_3f1e8b205fdf3161, []int{27}
 }
 func (m *LogMessage) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_LogMessage.Unmarshal(m, b)
@@ -1315,23 +2270,44 @@ func init() {
 	proto.RegisterType((*Query)(nil), "proto.Query")
 	proto.RegisterType((*QueryMulti)(nil), "proto.QueryMulti")
 	proto.RegisterType((*ServiceInitRequest)(nil), "proto.ServiceInitRequest")
+	proto.RegisterType((*PluginRegistration)(nil), "proto.PluginRegistration")
+	proto.RegisterMapType((map[string]*PluginDiscoverable)(nil), "proto.PluginRegistration.DiscoverablesEntry")
+	proto.RegisterMapType((map[string]*PluginMutation)(nil), "proto.PluginRegistration.MutationsEntry")
+	proto.RegisterType((*PluginMutation)(nil), "proto.PluginMutation")
+	proto.RegisterMapType((map[string]string)(nil), "proto.PluginMutation.ExcludesEntry")
+	proto.RegisterMapType((map[string]*PluginMutation_Change)(nil), "proto.PluginMutation.MutatesEntry")
+	proto.RegisterMapType((map[string]string)(nil), "proto.PluginMutation.RequiresEntry")
+	proto.RegisterType((*PluginMutation_Change)(nil), "proto.PluginMutation.Change")
+	proto.RegisterType((*PluginDiscoverable)(nil), "proto.PluginDiscoverable")
+	proto.RegisterMapType((map[string]string)(nil), "proto.PluginDiscoverable.ValuesEntry")
 	proto.RegisterType((*ServiceControl)(nil), "proto.ServiceControl")
+	proto.RegisterType((*ServiceRequest)(nil), "proto.ServiceRequest")
+	proto.RegisterType((*ServiceStatus)(nil), "proto.ServiceStatus")
+	proto.RegisterType((*ServiceHeartbeatRequest)(nil), "proto.ServiceHeartbeatRequest")
+	proto.RegisterType((*ServiceStatusList)(nil), "proto.ServiceStatusList")
 	proto.RegisterType((*MutationControl)(nil), "proto.MutationControl")
 	proto.RegisterType((*StateChangeControl)(nil), "proto.StateChangeControl")
 	proto.RegisterType((*EventControl)(nil), "proto.EventControl")
+	proto.RegisterType((*MetricsReply)(nil), "proto.MetricsReply")
+	proto.RegisterType((*WatchRequest)(nil), "proto.WatchRequest")
 	proto.RegisterType((*DiscoveryEvent)(nil), "proto.DiscoveryEvent")
 	proto.RegisterType((*MutationNodeList)(nil), "proto.MutationNodeList")
 	proto.RegisterType((*MutationEdgeList)(nil), "proto.MutationEdgeList")
 	proto.RegisterType((*MutationPath)(nil), "proto.MutationPath")
+	proto.RegisterType((*MutationStep)(nil), "proto.MutationStep")
+	proto.RegisterType((*MutationPathRecord)(nil), "proto.MutationPathRecord")
+	proto.RegisterType((*MutationHistory)(nil), "proto.MutationHistory")
 	proto.RegisterType((*MutationNode)(nil), "proto.MutationNode")
 	proto.RegisterType((*MutationEdge)(nil), "proto.MutationEdge")
 	proto.RegisterType((*EdgeColor)(nil), "proto.EdgeColor")
 	proto.RegisterType((*NodeColor)(nil), "proto.NodeColor")
 	proto.RegisterType((*LogMessage)(nil), "proto.LogMessage")
+	proto.RegisterEnum("proto.PluginMutation_Context", PluginMutation_Context_name, PluginMutation_Context_value)
 	proto.RegisterEnum("proto.ServiceControl_Command", ServiceControl_Command_name, ServiceControl_Command_value)
 	proto.RegisterEnum("proto.MutationControl_Type", MutationControl_Type_name, MutationControl_Type_value)
 	proto.RegisterEnum("proto.StateChangeControl_Type", StateChangeControl_Type_name, StateChangeControl_Type_value)
 	proto.RegisterEnum("proto.EventControl_Type", EventControl_Type_name, EventControl_Type_value)
+	proto.RegisterEnum("proto.MutationPathRecord_Outcome", MutationPathRecord_Outcome_name, MutationPathRecord_Outcome_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -1353,6 +2329,7 @@ type APIClient interface {
 	QueryReadDsc(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error)
 	QueryUpdate(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error)
 	QueryUpdateDsc(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error)
+	QuerySetValue(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error)
 	QueryDelete(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error)
 	QueryReadAll(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*QueryMulti, error)
 	QueryReadAllDsc(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*QueryMulti, error)
@@ -1361,16 +2338,27 @@ type APIClient interface {
 	QueryNodeMutationNodes(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error)
 	QueryNodeMutationEdges(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error)
 	QueryNodeMutationPath(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error)
+	QueryNodeMutationHistory(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error)
 	QueryDeleteAll(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*QueryMulti, error)
 	QueryFreeze(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*Query, error)
 	QueryThaw(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*Query, error)
 	QueryFrozen(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*Query, error)
+	// Metrics
+	Metrics(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*MetricsReply, error)
 	// Service management
 	ServiceInit(ctx context.Context, in *ServiceInitRequest, opts ...grpc.CallOption) (API_ServiceInitClient, error)
+	ServiceList(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*ServiceStatusList, error)
+	ServiceGet(ctx context.Context, in *ServiceRequest, opts ...grpc.CallOption) (*ServiceStatus, error)
+	ServiceStart(ctx context.Context, in *ServiceRequest, opts ...grpc.CallOption) (*ServiceStatus, error)
+	ServiceStop(ctx context.Context, in *ServiceRequest, opts ...grpc.CallOption) (*ServiceStatus, error)
+	ServiceRestart(ctx context.Context, in *ServiceRequest, opts ...grpc.CallOption) (*ServiceStatus, error)
+	ServiceConfigure(ctx context.Context, in *ServiceRequest, opts ...grpc.CallOption) (*ServiceStatus, error)
+	ServiceHeartbeat(ctx context.Context, in *ServiceHeartbeatRequest, opts ...grpc.CallOption) (*empty.Empty, error)
 	// Mutation/Discover management
 	MutationInit(ctx context.Context, in *ServiceInitRequest, opts ...grpc.CallOption) (API_MutationInitClient, error)
 	// Event management
 	EventInit(ctx context.Context, in *ServiceInitRequest, opts ...grpc.CallOption) (API_EventInitClient, error)
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (API_WatchClient, error)
 	// Discovery management
 	DiscoveryInit(ctx context.Context, opts ...grpc.CallOption) (API_DiscoveryInitClient, error)
 	// Logging
@@ -1421,9 +2409,18 @@ func (c *aPIClient) QueryUpdate(ctx context.Context, in *Query, opts ...grpc.Cal
 	return out, nil
 }
 
-func (c *aPIClient) QueryUpdateDsc(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error) {
+func (c *aPIClient) QueryUpdateDsc(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error) {
+	out := new(Query)
+	err := c.cc.Invoke(ctx, "/proto.API/QueryUpdateDsc", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) QuerySetValue(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error) {
 	out := new(Query)
-	err := c.cc.Invoke(ctx, "/proto.API/QueryUpdateDsc", in, out, opts...)
+	err := c.cc.Invoke(ctx, "/proto.API/QuerySetValue", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -1502,6 +2499,15 @@ func (c *aPIClient) QueryNodeMutationPath(ctx context.Context, in *Query, opts .
 	return out, nil
 }
 
+func (c *aPIClient) QueryNodeMutationHistory(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Query, error) {
+	out := new(Query)
+	err := c.cc.Invoke(ctx, "/proto.API/QueryNodeMutationHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *aPIClient) QueryDeleteAll(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*QueryMulti, error) {
 	out := new(QueryMulti)
 	err := c.cc.Invoke(ctx, "/proto.API/QueryDeleteAll", in, out, opts...)
@@ -1538,6 +2544,15 @@ func (c *aPIClient) QueryFrozen(ctx context.Context, in *empty.Empty, opts ...gr
 	return out, nil
 }
 
+func (c *aPIClient) Metrics(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*MetricsReply, error) {
+	out := new(MetricsReply)
+	err := c.cc.Invoke(ctx, "/proto.API/Metrics", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *aPIClient) ServiceInit(ctx context.Context, in *ServiceInitRequest, opts ...grpc.CallOption) (API_ServiceInitClient, error) {
 	stream, err := c.cc.NewStream(ctx, &_API_serviceDesc.Streams[0], "/proto.API/ServiceInit", opts...)
 	if err != nil {
@@ -1570,6 +2585,69 @@ func (x *aPIServiceInitClient) Recv() (*ServiceControl, error) {
 	return m, nil
 }
 
+func (c *aPIClient) ServiceList(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*ServiceStatusList, error) {
+	out := new(ServiceStatusList)
+	err := c.cc.Invoke(ctx, "/proto.API/ServiceList", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ServiceGet(ctx context.Context, in *ServiceRequest, opts ...grpc.CallOption) (*ServiceStatus, error) {
+	out := new(ServiceStatus)
+	err := c.cc.Invoke(ctx, "/proto.API/ServiceGet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ServiceStart(ctx context.Context, in *ServiceRequest, opts ...grpc.CallOption) (*ServiceStatus, error) {
+	out := new(ServiceStatus)
+	err := c.cc.Invoke(ctx, "/proto.API/ServiceStart", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ServiceStop(ctx context.Context, in *ServiceRequest, opts ...grpc.CallOption) (*ServiceStatus, error) {
+	out := new(ServiceStatus)
+	err := c.cc.Invoke(ctx, "/proto.API/ServiceStop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ServiceRestart(ctx context.Context, in *ServiceRequest, opts ...grpc.CallOption) (*ServiceStatus, error) {
+	out := new(ServiceStatus)
+	err := c.cc.Invoke(ctx, "/proto.API/ServiceRestart", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ServiceConfigure(ctx context.Context, in *ServiceRequest, opts ...grpc.CallOption) (*ServiceStatus, error) {
+	out := new(ServiceStatus)
+	err := c.cc.Invoke(ctx, "/proto.API/ServiceConfigure", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ServiceHeartbeat(ctx context.Context, in *ServiceHeartbeatRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/proto.API/ServiceHeartbeat", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *aPIClient) MutationInit(ctx context.Context, in *ServiceInitRequest, opts ...grpc.CallOption) (API_MutationInitClient, error) {
 	stream, err := c.cc.NewStream(ctx, &_API_serviceDesc.Streams[1], "/proto.API/MutationInit", opts...)
 	if err != nil {
@@ -1634,8 +2712,40 @@ func (x *aPIEventInitClient) Recv() (*EventControl, error) {
 	return m, nil
 }
 
+func (c *aPIClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (API_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_API_serviceDesc.Streams[3], "/proto.API/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_WatchClient interface {
+	Recv() (*EventControl, error)
+	grpc.ClientStream
+}
+
+type aPIWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIWatchClient) Recv() (*EventControl, error) {
+	m := new(EventControl)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *aPIClient) DiscoveryInit(ctx context.Context, opts ...grpc.CallOption) (API_DiscoveryInitClient, error) {
-	stream, err := c.cc.NewStream(ctx, &_API_serviceDesc.Streams[3], "/proto.API/DiscoveryInit", opts...)
+	stream, err := c.cc.NewStream(ctx, &_API_serviceDesc.Streams[4], "/proto.API/DiscoveryInit", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -1669,7 +2779,7 @@ func (x *aPIDiscoveryInitClient) CloseAndRecv() (*empty.Empty, error) {
 }
 
 func (c *aPIClient) LoggerInit(ctx context.Context, opts ...grpc.CallOption) (API_LoggerInitClient, error) {
-	stream, err := c.cc.NewStream(ctx, &_API_serviceDesc.Streams[4], "/proto.API/LoggerInit", opts...)
+	stream, err := c.cc.NewStream(ctx, &_API_serviceDesc.Streams[5], "/proto.API/LoggerInit", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -1711,6 +2821,7 @@ type APIServer interface {
 	QueryReadDsc(context.Context, *Query) (*Query, error)
 	QueryUpdate(context.Context, *Query) (*Query, error)
 	QueryUpdateDsc(context.Context, *Query) (*Query, error)
+	QuerySetValue(context.Context, *Query) (*Query, error)
 	QueryDelete(context.Context, *Query) (*Query, error)
 	QueryReadAll(context.Context, *empty.Empty) (*QueryMulti, error)
 	QueryReadAllDsc(context.Context, *empty.Empty) (*QueryMulti, error)
@@ -1719,16 +2830,27 @@ type APIServer interface {
 	QueryNodeMutationNodes(context.Context, *Query) (*Query, error)
 	QueryNodeMutationEdges(context.Context, *Query) (*Query, error)
 	QueryNodeMutationPath(context.Context, *Query) (*Query, error)
+	QueryNodeMutationHistory(context.Context, *Query) (*Query, error)
 	QueryDeleteAll(context.Context, *empty.Empty) (*QueryMulti, error)
 	QueryFreeze(context.Context, *empty.Empty) (*Query, error)
 	QueryThaw(context.Context, *empty.Empty) (*Query, error)
 	QueryFrozen(context.Context, *empty.Empty) (*Query, error)
+	// Metrics
+	Metrics(context.Context, *empty.Empty) (*MetricsReply, error)
 	// Service management
 	ServiceInit(*ServiceInitRequest, API_ServiceInitServer) error
+	ServiceList(context.Context, *empty.Empty) (*ServiceStatusList, error)
+	ServiceGet(context.Context, *ServiceRequest) (*ServiceStatus, error)
+	ServiceStart(context.Context, *ServiceRequest) (*ServiceStatus, error)
+	ServiceStop(context.Context, *ServiceRequest) (*ServiceStatus, error)
+	ServiceRestart(context.Context, *ServiceRequest) (*ServiceStatus, error)
+	ServiceConfigure(context.Context, *ServiceRequest) (*ServiceStatus, error)
+	ServiceHeartbeat(context.Context, *ServiceHeartbeatRequest) (*empty.Empty, error)
 	// Mutation/Discover management
 	MutationInit(*ServiceInitRequest, API_MutationInitServer) error
 	// Event management
 	EventInit(*ServiceInitRequest, API_EventInitServer) error
+	Watch(*WatchRequest, API_WatchServer) error
 	// Discovery management
 	DiscoveryInit(API_DiscoveryInitServer) error
 	// Logging
@@ -1829,6 +2951,24 @@ func _API_QueryUpdateDsc_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _API_QuerySetValue_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Query)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).QuerySetValue(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.API/QuerySetValue",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).QuerySetValue(ctx, req.(*Query))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _API_QueryDelete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Query)
 	if err := dec(in); err != nil {
@@ -1973,6 +3113,24 @@ func _API_QueryNodeMutationPath_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _API_QueryNodeMutationHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Query)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).QueryNodeMutationHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.API/QueryNodeMutationHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).QueryNodeMutationHistory(ctx, req.(*Query))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _API_QueryDeleteAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(empty.Empty)
 	if err := dec(in); err != nil {
@@ -2045,6 +3203,24 @@ func _API_QueryFrozen_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _API_Metrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(empty.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).Metrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.API/Metrics",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).Metrics(ctx, req.(*empty.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _API_ServiceInit_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(ServiceInitRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -2066,6 +3242,132 @@ func (x *aPIServiceInitServer) Send(m *ServiceControl) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _API_ServiceList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(empty.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ServiceList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.API/ServiceList",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ServiceList(ctx, req.(*empty.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ServiceGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ServiceGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.API/ServiceGet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ServiceGet(ctx, req.(*ServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ServiceStart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ServiceStart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.API/ServiceStart",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ServiceStart(ctx, req.(*ServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ServiceStop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ServiceStop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.API/ServiceStop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ServiceStop(ctx, req.(*ServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ServiceRestart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ServiceRestart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.API/ServiceRestart",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ServiceRestart(ctx, req.(*ServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ServiceConfigure_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ServiceConfigure(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.API/ServiceConfigure",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ServiceConfigure(ctx, req.(*ServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ServiceHeartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceHeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ServiceHeartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.API/ServiceHeartbeat",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ServiceHeartbeat(ctx, req.(*ServiceHeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _API_MutationInit_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(ServiceInitRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -2108,6 +3410,27 @@ func (x *aPIEventInitServer) Send(m *EventControl) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _API_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).Watch(m, &aPIWatchServer{stream})
+}
+
+type API_WatchServer interface {
+	Send(*EventControl) error
+	grpc.ServerStream
+}
+
+type aPIWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIWatchServer) Send(m *EventControl) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _API_DiscoveryInit_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(APIServer).DiscoveryInit(&aPIDiscoveryInitServer{stream})
 }
@@ -2184,6 +3507,10 @@ var _API_serviceDesc = grpc.ServiceDesc{
 			MethodName: "QueryUpdateDsc",
 			Handler:    _API_QueryUpdateDsc_Handler,
 		},
+		{
+			MethodName: "QuerySetValue",
+			Handler:    _API_QuerySetValue_Handler,
+		},
 		{
 			MethodName: "QueryDelete",
 			Handler:    _API_QueryDelete_Handler,
@@ -2216,6 +3543,10 @@ var _API_serviceDesc = grpc.ServiceDesc{
 			MethodName: "QueryNodeMutationPath",
 			Handler:    _API_QueryNodeMutationPath_Handler,
 		},
+		{
+			MethodName: "QueryNodeMutationHistory",
+			Handler:    _API_QueryNodeMutationHistory_Handler,
+		},
 		{
 			MethodName: "QueryDeleteAll",
 			Handler:    _API_QueryDeleteAll_Handler,
@@ -2232,6 +3563,38 @@ var _API_serviceDesc = grpc.ServiceDesc{
 			MethodName: "QueryFrozen",
 			Handler:    _API_QueryFrozen_Handler,
 		},
+		{
+			MethodName: "Metrics",
+			Handler:    _API_Metrics_Handler,
+		},
+		{
+			MethodName: "ServiceList",
+			Handler:    _API_ServiceList_Handler,
+		},
+		{
+			MethodName: "ServiceGet",
+			Handler:    _API_ServiceGet_Handler,
+		},
+		{
+			MethodName: "ServiceStart",
+			Handler:    _API_ServiceStart_Handler,
+		},
+		{
+			MethodName: "ServiceStop",
+			Handler:    _API_ServiceStop_Handler,
+		},
+		{
+			MethodName: "ServiceRestart",
+			Handler:    _API_ServiceRestart_Handler,
+		},
+		{
+			MethodName: "ServiceConfigure",
+			Handler:    _API_ServiceConfigure_Handler,
+		},
+		{
+			MethodName: "ServiceHeartbeat",
+			Handler:    _API_ServiceHeartbeat_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -2249,6 +3612,11 @@ var _API_serviceDesc = grpc.ServiceDesc{
 			Handler:       _API_EventInit_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "Watch",
+			Handler:       _API_Watch_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "DiscoveryInit",
 			Handler:       _API_DiscoveryInit_Handler,
@@ -2263,85 +3631,156 @@ var _API_serviceDesc = grpc.ServiceDesc{
 	Metadata: "API.proto",
 }
 
-func init() { proto.RegisterFile("API.proto", fileDescriptor_API_e7e18ec3f24e7299) }
-
-var fileDescriptor_API_e7e18ec3f24e7299 = []byte{
-	// 1220 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x56, 0xdd, 0x72, 0xda, 0xc6,
-	0x17, 0x97, 0xf8, 0x30, 0xe8, 0x40, 0xb0, 0xfe, 0x9b, 0xc4, 0x7f, 0x4c, 0x9a, 0x8c, 0xa3, 0x8b,
-	0x8c, 0x3d, 0xf5, 0xe0, 0x0e, 0x49, 0xda, 0xe9, 0x24, 0xad, 0x07, 0x83, 0x52, 0x98, 0xda, 0x2e,
-	0x5d, 0xe3, 0x8b, 0xce, 0x74, 0x26, 0x23, 0xa4, 0xb5, 0x50, 0x2b, 0xb4, 0x44, 0x12, 0x6e, 0xc9,
-	0xe3, 0xf4, 0x39, 0x7a, 0xd9, 0xbb, 0x4e, 0x1f, 0xa3, 0xef, 0xd1, 0xd9, 0xd5, 0x0a, 0x24, 0x04,
-	0xc6, 0xb9, 0xd2, 0x9e, 0xaf, 0xdf, 0xf9, 0xd4, 0xd9, 0x05, 0xa5, 0x3d, 0xe8, 0x37, 0xa7, 0x3e,
-	0x0d, 0x29, 0x2a, 0xf2, 0x4f, 0x03, 0x2e, 0xa9, 0x45, 0x22, 0x56, 0x63, 0xdf, 0xa6, 0xd4, 0x76,
-	0xc9, 0x09, 0xa7, 0x46, 0xb3, 0x9b, 0x13, 0xc3, 0x9b, 0x0b, 0xd1, 0x93, 0x55, 0x91, 0x3e, 0x99,
-	0x86, 0x42, 0xa8, 0xfd, 0x99, 0x83, 0xe2, 0x8f, 0x33, 0xe2, 0xcf, 0x91, 0x0a, 0xf9, 0x6b, 0x7c,
-	0x5e, 0x97, 0x0f, 0xe4, 0x43, 0x05, 0xb3, 0x23, 0x7a, 0x0e, 0x05, 0x8f, 0x5a, 0xa4, 0x9e, 0x3b,
-	0x90, 0x0f, 0x2b, 0xad, 0x4a, 0x64, 0xd1, 0x64, 0x4e, 0x7b, 0x12, 0xe6, 0x22, 0xf4, 0x08, 0x0a,
-	0x21, 0xf9, 0x3d, 0xac, 0xe7, 0x99, 0x15, 0xe3, 0x32, 0x8a, 0x71, 0x47, 0x94, 0xba, 0xf5, 0xc2,
-	0x81, 0x7c, 0x58, 0x66, 0x5c, 0x46, 0x21, 0x1d, 0xd4, 0xc9, 0x2c, 0x34, 0x42, 0x87, 0x7a, 0x0c,
-	0xe3, 0xdc, 0x09, 0xc2, 0x7a, 0x91, 0x43, 0xff, 0x5f, 0x40, 0x5f, 0xac, 0x88, 0x7b, 0x12, 0xce,
-	0x98, 0x24, 0x61, 0x74, 0xcb, 0x8e, 0x60, 0x76, 0xd6, 0xc2, 0xc4, 0xe2, 0x24, 0x4c, 0xcc, 0x43,
-	0x5f, 0x43, 0x35, 0xe6, 0x0d, 0x8c, 0x70, 0x5c, 0x2f, 0x71, 0x88, 0x87, 0x2b, 0x10, 0x4c, 0xd4,
-	0x93, 0x70, 0x4a, 0xf5, 0x4c, 0x81, 0xd2, 0xd4, 0x98, 0xbb, 0xd4, 0xb0, 0xb4, 0x57, 0x00, 0xbc,
-	0x7a, 0x17, 0x33, 0x37, 0x74, 0xd0, 0x0b, 0x28, 0x7d, 0x98, 0x11, 0xdf, 0x21, 0x41, 0x5d, 0x3e,
-	0xc8, 0x1f, 0x56, 0x5a, 0x55, 0x01, 0xc7, 0x75, 0x70, 0x2c, 0xd4, 0xde, 0x02, 0xba, 0x22, 0xfe,
-	0xad, 0x63, 0x92, 0xbe, 0xe7, 0x84, 0x98, 0x7c, 0x98, 0x91, 0x20, 0x44, 0x35, 0xc8, 0x39, 0x96,
-	0xa8, 0x7f, 0xce, 0xb1, 0xd0, 0x1e, 0xec, 0x4c, 0xa8, 0x35, 0x73, 0xa3, 0x06, 0x28, 0x58, 0x50,
-	0xda, 0x1f, 0x32, 0xd4, 0x84, 0x79, 0x87, 0x7a, 0xa1, 0x4f, 0x5d, 0xf4, 0x15, 0x94, 0x4c, 0x3a,
-	0x99, 0x18, 0x5e, 0x64, 0x5f, 0x6b, 0x3d, 0x15, 0x8e, 0xd3, 0x7a, 0xcd, 0x4e, 0xa4, 0x84, 0x63,
-	0x6d, 0x74, 0x0c, 0x3b, 0x26, 0xf5, 0x6e, 0x1c, 0x5b, 0x34, 0xf9, 0x51, 0x33, 0x1a, 0x96, 0x66,
-	0x3c, 0x2c, 0xcd, 0xb6, 0x37, 0xc7, 0x42, 0x47, 0x3b, 0x82, 0x92, 0x40, 0x40, 0x65, 0x28, 0x5c,
-	0x0d, 0x7f, 0x18, 0xa8, 0x12, 0x02, 0xd8, 0xb9, 0x1e, 0x74, 0xdb, 0x43, 0x5d, 0x95, 0x19, 0xb7,
-	0x7f, 0xd9, 0x1f, 0xaa, 0x39, 0xed, 0x6f, 0x19, 0x76, 0xe3, 0x22, 0xc6, 0x51, 0x2e, 0x13, 0x92,
-	0x93, 0x09, 0x89, 0xc4, 0x73, 0x8b, 0xc4, 0x4f, 0xa0, 0x10, 0xce, 0xa7, 0x84, 0x0f, 0x55, 0xad,
-	0xf5, 0x64, 0xa5, 0x25, 0x71, 0x2e, 0xc3, 0xf9, 0x94, 0x60, 0xae, 0x88, 0x9e, 0x42, 0xde, 0xbc,
-	0xb1, 0xf9, 0xb8, 0xa5, 0xe7, 0x14, 0x33, 0x3e, 0x13, 0x5b, 0x81, 0x29, 0x66, 0x2d, 0x2d, 0xb6,
-	0x02, 0x53, 0x7b, 0x0e, 0x05, 0x86, 0xc5, 0x12, 0xb9, 0xb8, 0x1e, 0xb2, 0x44, 0x24, 0xf4, 0x00,
-	0x94, 0xfe, 0xe5, 0x50, 0xc7, 0xf8, 0x7a, 0x30, 0x54, 0x65, 0xed, 0x2f, 0x19, 0xd0, 0x55, 0x68,
-	0x84, 0xa4, 0x33, 0x36, 0x3c, 0x7b, 0x51, 0xf6, 0x96, 0x08, 0x34, 0xaa, 0xf9, 0xb3, 0xb8, 0xe6,
-	0x19, 0xc5, 0x64, 0xac, 0x2a, 0xe4, 0x67, 0xbe, 0x2b, 0xb2, 0x65, 0x47, 0xf4, 0x08, 0x8a, 0xb7,
-	0x86, 0x3b, 0x8b, 0xf2, 0x55, 0x70, 0x44, 0x68, 0x78, 0x19, 0x55, 0x07, 0xeb, 0x51, 0x54, 0x65,
-	0x28, 0x60, 0xbd, 0xdd, 0x55, 0xe5, 0x44, 0xd1, 0x73, 0xec, 0xdc, 0xd5, 0xcf, 0xf5, 0xa1, 0xae,
-	0xe6, 0x51, 0x15, 0xca, 0x9d, 0x77, 0xdf, 0xbd, 0xe7, 0x5a, 0x05, 0x54, 0x03, 0x60, 0x94, 0xd0,
-	0x2c, 0x6a, 0xff, 0xe4, 0xa0, 0xaa, 0xdf, 0x12, 0x2f, 0x8c, 0x13, 0x38, 0x4e, 0x25, 0x50, 0x17,
-	0x09, 0x24, 0x55, 0x92, 0xa1, 0x7f, 0x0f, 0x28, 0xc8, 0xe4, 0x26, 0x06, 0x67, 0x7f, 0x63, 0xf2,
-	0x3d, 0x09, 0xaf, 0x31, 0x43, 0x67, 0xb0, 0x3b, 0x49, 0x77, 0x94, 0xe7, 0x5f, 0x69, 0xed, 0xad,
-	0xef, 0x77, 0x4f, 0xc2, 0xab, 0x06, 0xe8, 0x14, 0x6a, 0x96, 0x13, 0x98, 0xf4, 0x96, 0xf8, 0x73,
-	0x1e, 0xb4, 0x18, 0x81, 0xc7, 0x02, 0xa2, 0x9b, 0x12, 0xf6, 0x24, 0xbc, 0xa2, 0xae, 0xbd, 0x12,
-	0x45, 0xde, 0x85, 0x4a, 0x22, 0x70, 0x55, 0x62, 0x75, 0x8c, 0xfd, 0xab, 0x32, 0x9b, 0x86, 0x05,
-	0x94, 0x9a, 0x3b, 0x2b, 0x41, 0x91, 0x70, 0xf3, 0x0b, 0xa8, 0xa5, 0x5d, 0x64, 0xfe, 0xe1, 0x6c,
-	0xb7, 0xf7, 0xa1, 0xcc, 0x1b, 0xfc, 0xde, 0xb1, 0x44, 0xc3, 0x4b, 0x9c, 0xee, 0x5b, 0xda, 0x15,
-	0xa8, 0xab, 0x1b, 0x10, 0x9d, 0x66, 0x79, 0x62, 0xb7, 0x3c, 0x5c, 0xb3, 0x34, 0x71, 0x46, 0x39,
-	0x09, 0xba, 0xd8, 0x7d, 0xa7, 0x59, 0xde, 0x06, 0x50, 0x26, 0xc6, 0x19, 0x65, 0xcd, 0x80, 0x6a,
-	0x72, 0x43, 0xb2, 0x34, 0xcd, 0x99, 0xcf, 0xf3, 0xce, 0x63, 0x76, 0x64, 0x43, 0x6d, 0x4e, 0xa6,
-	0x6e, 0xc8, 0x53, 0x2f, 0xe3, 0x88, 0x40, 0x47, 0x50, 0x34, 0xc7, 0x86, 0xe3, 0xd5, 0xf3, 0x9b,
-	0xbd, 0x45, 0x1a, 0xda, 0xcf, 0x4b, 0x17, 0x97, 0xd1, 0x4d, 0x53, 0x74, 0x8d, 0x11, 0x71, 0x45,
-	0x71, 0x23, 0x22, 0xb3, 0x3a, 0x5e, 0x40, 0xd1, 0xa4, 0x2e, 0xf5, 0xc5, 0x2c, 0xa9, 0x89, 0x9f,
-	0xbd, 0xc3, 0xf8, 0x38, 0x12, 0x6b, 0xbf, 0x2c, 0xd1, 0x99, 0x53, 0x84, 0xa0, 0x70, 0xe3, 0xd3,
-	0x89, 0x00, 0xe7, 0x67, 0x86, 0x1d, 0xd2, 0x18, 0x3b, 0xa4, 0xc2, 0x57, 0x3e, 0xeb, 0xab, 0x90,
-	0xf2, 0xc5, 0xf0, 0x52, 0xbe, 0x7e, 0x02, 0x65, 0xc1, 0xe3, 0x75, 0xe1, 0x46, 0x22, 0x0d, 0x4e,
-	0xa0, 0xcf, 0x40, 0x19, 0x3b, 0xf6, 0xd8, 0x75, 0xec, 0x71, 0x28, 0x3c, 0x2e, 0x19, 0xa8, 0x0e,
-	0x25, 0xc7, 0x1b, 0x13, 0xdf, 0x89, 0xee, 0xd9, 0x32, 0x8e, 0x49, 0xad, 0x03, 0xca, 0x22, 0x35,
-	0xb6, 0x5e, 0x47, 0xd4, 0xb7, 0x48, 0x8c, 0x2d, 0x28, 0xf4, 0x0c, 0x60, 0x64, 0x98, 0xbf, 0xda,
-	0x3e, 0x9d, 0x79, 0x71, 0xad, 0x12, 0x1c, 0xed, 0x1c, 0xe0, 0x9c, 0xda, 0x17, 0x24, 0x08, 0x0c,
-	0x9b, 0x30, 0x14, 0xea, 0x3b, 0xb6, 0xe3, 0xc5, 0x28, 0x11, 0xc5, 0xeb, 0x4f, 0x6e, 0x49, 0x34,
-	0xcb, 0x0f, 0x70, 0x44, 0xb0, 0xc6, 0x4f, 0x02, 0x5b, 0x14, 0x85, 0x1d, 0x5b, 0xff, 0x2a, 0x90,
-	0x6f, 0x0f, 0xfa, 0xe8, 0x73, 0xa8, 0xf0, 0x5b, 0xaf, 0xe3, 0x13, 0x23, 0x24, 0x28, 0x75, 0x13,
-	0x36, 0x52, 0x94, 0x26, 0xa1, 0x23, 0x50, 0xa2, 0x2b, 0x92, 0x18, 0xd6, 0x16, 0xd5, 0x63, 0xa8,
-	0x2e, 0x54, 0xbb, 0x81, 0xb9, 0x45, 0x3b, 0x8e, 0xe2, 0x7a, 0x6a, 0x6d, 0x8f, 0xa2, 0x09, 0xb5,
-	0x84, 0xf2, 0xfd, 0xc1, 0xbb, 0xc4, 0x25, 0x5b, 0xc1, 0xdf, 0x24, 0xe2, 0x6e, 0xbb, 0x2e, 0xda,
-	0xcb, 0xdc, 0xb4, 0xfc, 0x59, 0xd6, 0xf8, 0x5f, 0xd2, 0x8e, 0x3f, 0x2b, 0x34, 0x09, 0x7d, 0x0b,
-	0xbb, 0x49, 0x63, 0x16, 0xda, 0x27, 0xd9, 0xbf, 0x05, 0x24, 0xe8, 0xe5, 0x1f, 0x15, 0x6c, 0x84,
-	0x58, 0x0d, 0x7d, 0xd5, 0x9a, 0x4d, 0xf3, 0xfd, 0xad, 0xbf, 0x84, 0x3d, 0x7e, 0x64, 0x3e, 0xd3,
-	0xfe, 0xef, 0x2e, 0xd8, 0x3a, 0xbb, 0xc8, 0xf3, 0xdd, 0x76, 0xaf, 0xe1, 0x71, 0xc6, 0x8e, 0x2f,
-	0xa9, 0xbb, 0xcd, 0xbe, 0x11, 0xcd, 0x8f, 0x9a, 0xf9, 0xc9, 0x1d, 0x7a, 0x2d, 0x66, 0xe1, 0x9d,
-	0x4f, 0xc8, 0x47, 0x72, 0xef, 0xe2, 0xbc, 0x14, 0x83, 0x3f, 0x1c, 0x1b, 0xbf, 0xdd, 0xdb, 0x68,
-	0xe9, 0x8b, 0x7e, 0x24, 0xde, 0xbd, 0xcd, 0x3a, 0x50, 0x49, 0xbc, 0x3a, 0xd1, 0x7e, 0xfa, 0x89,
-	0x98, 0x78, 0x89, 0x36, 0x1e, 0xaf, 0x7d, 0x3d, 0x6a, 0xd2, 0x17, 0x32, 0xd2, 0x97, 0x8b, 0x73,
-	0x1b, 0xca, 0x86, 0x8b, 0x9c, 0xc3, 0x9c, 0x82, 0xc2, 0x2f, 0xcc, 0x6d, 0x18, 0x0f, 0xd7, 0x3c,
-	0x49, 0x38, 0xc0, 0x19, 0x3c, 0x58, 0x5c, 0xbd, 0x1c, 0x64, 0xfd, 0x9d, 0xdf, 0xd8, 0x50, 0x1c,
-	0x4d, 0x3a, 0x94, 0xd1, 0x1b, 0xbe, 0xf8, 0x6c, 0xe2, 0x73, 0x80, 0xb8, 0xad, 0xcb, 0x5d, 0x78,
-	0x97, 0xf1, 0x68, 0x87, 0xf3, 0x5e, 0xfe, 0x17, 0x00, 0x00, 0xff, 0xff, 0x7d, 0x24, 0xaf, 0x2b,
-	0x97, 0x0d, 0x00, 0x00,
+func init() { proto.RegisterFile("API.proto", fileDescriptor_API_3f1e8b205fdf3161) }
+
+var fileDescriptor_API_3f1e8b205fdf3161 = []byte{
+	// 2355 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x18, 0xdb, 0x72, 0x1b, 0x49,
+	0x55, 0xf7, 0xcb, 0xd1, 0x25, 0xda, 0xce, 0x65, 0x27, 0xca, 0x26, 0xeb, 0x0c, 0xb0, 0x38, 0xb5,
+	0xc1, 0x09, 0x4e, 0x0c, 0xd9, 0x4d, 0x16, 0xa3, 0x48, 0xf2, 0x5a, 0x85, 0x9c, 0x98, 0xb6, 0xbc,
+	0x40, 0x41, 0x95, 0x19, 0xcf, 0xb4, 0xe5, 0x21, 0xa3, 0x19, 0x65, 0xa6, 0xe5, 0x5d, 0xed, 0x0f,
+	0xf0, 0xb6, 0x1f, 0xc0, 0x0b, 0x55, 0xbc, 0x53, 0x3c, 0xf0, 0x01, 0xbc, 0xf0, 0xc8, 0x3f, 0xf0,
+	0xce, 0x57, 0x50, 0x7d, 0x1b, 0xcd, 0x45, 0xb2, 0xec, 0x7d, 0x9a, 0x39, 0xa7, 0xcf, 0x39, 0x7d,
+	0xee, 0x7d, 0xba, 0xa1, 0xda, 0x39, 0x1c, 0x6c, 0x4d, 0x7d, 0x8f, 0x7a, 0xa8, 0xc8, 0x3f, 0x6d,
+	0x78, 0xe3, 0x59, 0x44, 0xa0, 0xda, 0xb7, 0x8f, 0x88, 0x7f, 0x61, 0x9b, 0x64, 0xe0, 0x06, 0xd4,
+	0x70, 0x4d, 0x85, 0xbe, 0x3b, 0xf6, 0xbc, 0xb1, 0x43, 0x9e, 0x70, 0xe8, 0x74, 0x76, 0xf6, 0xc4,
+	0x70, 0xe7, 0x72, 0xe9, 0x5e, 0x72, 0xa9, 0x3f, 0x99, 0x52, 0xb5, 0xf8, 0x71, 0x72, 0x91, 0xda,
+	0x13, 0x12, 0x50, 0x63, 0x32, 0x95, 0x04, 0x0f, 0x92, 0x04, 0xd6, 0xcc, 0x37, 0xa8, 0xed, 0xb9,
+	0x72, 0x7d, 0x23, 0xb5, 0x4e, 0x02, 0xd3, 0xb7, 0xa7, 0xd4, 0xf3, 0x05, 0x85, 0xfe, 0x97, 0x3c,
+	0x14, 0x7f, 0x3d, 0x23, 0xfe, 0x1c, 0xb5, 0x20, 0x7f, 0x8c, 0x87, 0x5a, 0x76, 0x23, 0xbb, 0x59,
+	0xc5, 0xec, 0x17, 0x3d, 0x84, 0x82, 0xeb, 0x59, 0x44, 0xcb, 0x6d, 0x64, 0x37, 0x6b, 0xdb, 0x35,
+	0xc1, 0xb1, 0xc5, 0xcc, 0xdd, 0xcf, 0x60, 0xbe, 0x84, 0x6e, 0x41, 0x81, 0x92, 0x6f, 0xa8, 0x96,
+	0x67, 0x5c, 0x0c, 0xcb, 0x20, 0x86, 0x3d, 0xf5, 0x3c, 0x47, 0x2b, 0x6c, 0x64, 0x37, 0x2b, 0x0c,
+	0xcb, 0x20, 0xd4, 0x87, 0xd6, 0x64, 0x46, 0xb9, 0x7a, 0x4c, 0xc6, 0xd0, 0x0e, 0xa8, 0x56, 0xe4,
+	0xa2, 0x3f, 0x94, 0xa2, 0x0f, 0x12, 0xcb, 0xfb, 0x19, 0x9c, 0x62, 0x89, 0x8a, 0xe9, 0x5b, 0x63,
+	0x21, 0xa6, 0xb4, 0x54, 0x8c, 0x5a, 0x8e, 0x8a, 0x51, 0x38, 0xf4, 0x19, 0xd4, 0x15, 0xee, 0xd0,
+	0xa0, 0xe7, 0x5a, 0x99, 0x8b, 0xb8, 0x99, 0x10, 0xc1, 0x96, 0xf6, 0x33, 0x38, 0x46, 0x8a, 0x5e,
+	0xc3, 0x0d, 0x05, 0xef, 0xdb, 0x01, 0xf5, 0xfc, 0xb9, 0x56, 0xe1, 0xdc, 0x77, 0x12, 0xdc, 0x72,
+	0x75, 0x3f, 0x83, 0x93, 0x0c, 0x48, 0x83, 0xf2, 0x05, 0xf1, 0x03, 0xdb, 0x73, 0xb5, 0x2a, 0xf7,
+	0xb8, 0x02, 0x5f, 0x57, 0xa1, 0x3c, 0x35, 0xe6, 0x8e, 0x67, 0x58, 0xfa, 0x73, 0x00, 0x1e, 0x9b,
+	0x83, 0x99, 0x43, 0x6d, 0xf4, 0x09, 0x94, 0xdf, 0xcf, 0x88, 0x6f, 0x93, 0x40, 0xcb, 0x6e, 0xe4,
+	0x37, 0x6b, 0xdb, 0x75, 0xb9, 0x1d, 0xa7, 0xc1, 0x6a, 0x51, 0xff, 0x6b, 0x16, 0x50, 0x98, 0x87,
+	0x36, 0xc5, 0xe4, 0xfd, 0x8c, 0x04, 0x14, 0x35, 0x21, 0x67, 0x5b, 0x32, 0xbc, 0x39, 0xdb, 0x42,
+	0x77, 0xa0, 0x34, 0xf1, 0xac, 0x99, 0x23, 0xe2, 0x5b, 0xc5, 0x12, 0x62, 0x78, 0x9f, 0x4c, 0x1d,
+	0x63, 0xce, 0x83, 0x5a, 0xc0, 0x12, 0x12, 0xf8, 0x60, 0x36, 0x21, 0x22, 0xac, 0x58, 0x42, 0xe8,
+	0xa7, 0x50, 0x9a, 0x3a, 0xb3, 0xb1, 0xed, 0xca, 0x60, 0xde, 0x95, 0x5a, 0x1d, 0x72, 0x24, 0x26,
+	0x63, 0x3b, 0xa0, 0x22, 0x29, 0xb1, 0x24, 0xd4, 0xff, 0x95, 0x07, 0x94, 0x5e, 0x46, 0x9f, 0x43,
+	0xf1, 0xcc, 0x76, 0x42, 0xf3, 0x7e, 0xb8, 0x25, 0xb2, 0x77, 0x4b, 0x65, 0xef, 0xd6, 0x9e, 0xed,
+	0x90, 0x5e, 0x98, 0xc1, 0x87, 0x0c, 0x8d, 0x05, 0x0b, 0xfa, 0x18, 0x6a, 0xa6, 0xe7, 0x9e, 0xd9,
+	0xe3, 0x13, 0x3a, 0x9f, 0x2a, 0x93, 0x40, 0xa0, 0x46, 0xf3, 0x29, 0x41, 0x7b, 0x50, 0x55, 0x31,
+	0x08, 0xb4, 0x3c, 0xdf, 0x60, 0x73, 0xa5, 0xa6, 0x61, 0x04, 0x83, 0xbe, 0x4b, 0xfd, 0x39, 0x5e,
+	0xb0, 0x22, 0x0c, 0x0d, 0xcb, 0x0e, 0x4c, 0xef, 0x82, 0xf8, 0xc6, 0x29, 0x53, 0xb6, 0xc0, 0x65,
+	0x3d, 0x5e, 0x2d, 0xab, 0x17, 0x25, 0x17, 0xf2, 0xe2, 0x22, 0xda, 0x47, 0xd0, 0x8c, 0x6f, 0xc8,
+	0x8a, 0xf1, 0x1d, 0x99, 0xab, 0x62, 0x7c, 0x47, 0xe6, 0xe8, 0x53, 0x28, 0x5e, 0x18, 0xce, 0x4c,
+	0x55, 0xe3, 0xed, 0xd8, 0x7e, 0x8a, 0x1b, 0x0b, 0x9a, 0xcf, 0x73, 0x2f, 0xb2, 0xed, 0xdf, 0x03,
+	0x4a, 0xef, 0xbc, 0x44, 0xf0, 0x93, 0xb8, 0xe0, 0x78, 0xf8, 0xa2, 0x12, 0x22, 0xc2, 0xf5, 0xbf,
+	0x17, 0xa1, 0x19, 0xdf, 0x1a, 0xbd, 0x82, 0x32, 0xf7, 0x52, 0x18, 0x3f, 0x7d, 0xa9, 0x8a, 0xc2,
+	0xb5, 0xca, 0x11, 0x8a, 0x05, 0xed, 0x42, 0xc5, 0x27, 0xef, 0x67, 0xb6, 0x4f, 0x02, 0x2d, 0xc7,
+	0xd9, 0x7f, 0xb0, 0x9c, 0x1d, 0x4b, 0x2a, 0xc1, 0x1f, 0x32, 0x31, 0x01, 0xe4, 0x1b, 0xd3, 0x99,
+	0x59, 0x44, 0x85, 0x77, 0x85, 0x80, 0xbe, 0xa4, 0x92, 0x02, 0x14, 0x13, 0xfa, 0x39, 0x94, 0x4d,
+	0xcf, 0xe5, 0xdd, 0x8c, 0x25, 0x78, 0x73, 0xfb, 0xfe, 0x72, 0xfe, 0xae, 0x20, 0xc2, 0x8a, 0x1a,
+	0x3d, 0x83, 0x32, 0xeb, 0xcb, 0xde, 0x8c, 0x86, 0x15, 0x90, 0x4c, 0xdc, 0x9e, 0x6c, 0xcb, 0x58,
+	0x51, 0xa2, 0x07, 0x50, 0x3b, 0x33, 0x6c, 0xe7, 0x84, 0x7a, 0x27, 0x33, 0xdf, 0xe1, 0x0d, 0xac,
+	0x8a, 0xab, 0x0c, 0x35, 0xf2, 0x8e, 0x7d, 0x07, 0xe9, 0xd0, 0x50, 0xeb, 0x22, 0x3a, 0x65, 0x4e,
+	0x51, 0x13, 0x14, 0x5f, 0x31, 0x54, 0xfb, 0x31, 0x94, 0xba, 0xe7, 0x86, 0x3b, 0x26, 0x08, 0x41,
+	0xe1, 0xcc, 0xf7, 0x26, 0x32, 0xac, 0xfc, 0x9f, 0xd5, 0x3b, 0xf5, 0x64, 0x21, 0xe4, 0xa8, 0xd7,
+	0xfe, 0x2d, 0xd4, 0xa3, 0xae, 0x5f, 0x92, 0x09, 0xdb, 0xf1, 0x4c, 0xf8, 0x68, 0x85, 0xfd, 0x7c,
+	0xcb, 0x68, 0xa6, 0xbd, 0x84, 0x46, 0x2c, 0x2a, 0x4b, 0x44, 0xdf, 0x8a, 0x8a, 0xae, 0x26, 0x98,
+	0x63, 0x11, 0xb9, 0x0e, 0xb3, 0xfe, 0x63, 0x28, 0xcb, 0x70, 0xa0, 0x0a, 0x14, 0x8e, 0xfa, 0xc3,
+	0xbd, 0x56, 0x06, 0x55, 0xa1, 0xd8, 0xdd, 0x1f, 0x0c, 0x7b, 0xad, 0x2c, 0x2a, 0x43, 0xbe, 0x33,
+	0x1c, 0xb6, 0x72, 0xfa, 0x77, 0x59, 0x40, 0xe9, 0x8c, 0x46, 0x5f, 0x40, 0x89, 0x0b, 0x53, 0x29,
+	0xfb, 0xa3, 0x95, 0xc9, 0xbf, 0xc5, 0x5d, 0x2e, 0x93, 0x46, 0x32, 0xb5, 0x3f, 0x83, 0x5a, 0x04,
+	0x7d, 0x2d, 0xcd, 0xff, 0x96, 0x85, 0xa6, 0x6c, 0xd2, 0xcc, 0x02, 0xdf, 0x73, 0x44, 0x02, 0x4e,
+	0x26, 0x86, 0x2b, 0xba, 0xf4, 0x22, 0x01, 0xe3, 0x74, 0x5b, 0x5d, 0x41, 0x84, 0x15, 0x35, 0x7a,
+	0x0c, 0x25, 0xd1, 0xe8, 0x64, 0xe0, 0x6e, 0xa5, 0xf2, 0xaf, 0xe3, 0xce, 0xb1, 0xa4, 0xd1, 0x1f,
+	0x31, 0x9f, 0x09, 0x46, 0xe6, 0xb3, 0xd1, 0xdb, 0xc3, 0x56, 0x06, 0x01, 0x94, 0x8e, 0x0f, 0x7b,
+	0x9d, 0x51, 0xbf, 0x95, 0x65, 0xd8, 0xc1, 0x9b, 0xc1, 0xa8, 0x95, 0xd3, 0xdf, 0x84, 0x3a, 0xae,
+	0x3a, 0x44, 0xae, 0xb7, 0xf5, 0x3f, 0x73, 0xd0, 0x90, 0x02, 0x8f, 0xa8, 0x41, 0x67, 0xc1, 0x95,
+	0x0f, 0xa5, 0x1d, 0xc8, 0x9b, 0x67, 0x63, 0x7e, 0x22, 0x35, 0xc3, 0xc2, 0x4e, 0x0e, 0x5b, 0x11,
+	0xd1, 0x04, 0x33, 0x7a, 0xc6, 0x66, 0x05, 0xa6, 0x56, 0xb8, 0x06, 0x9b, 0x15, 0x98, 0xe8, 0x1e,
+	0x54, 0x89, 0xef, 0x7b, 0xfe, 0xc9, 0x24, 0x18, 0xf3, 0x9a, 0xae, 0xe2, 0x0a, 0x47, 0x1c, 0x04,
+	0xe3, 0x88, 0xc9, 0xa5, 0xf5, 0x26, 0xa3, 0x0e, 0x34, 0x1d, 0x23, 0xa0, 0x27, 0xe7, 0xc4, 0xf0,
+	0xe9, 0x29, 0x31, 0xa8, 0x1c, 0x34, 0xda, 0x29, 0xae, 0x91, 0x9a, 0xed, 0x70, 0x83, 0x71, 0xec,
+	0x2b, 0x06, 0xfd, 0x8f, 0xf0, 0xa1, 0x54, 0x31, 0xc4, 0xad, 0x0a, 0xc7, 0x0e, 0x54, 0x6c, 0x97,
+	0x12, 0xff, 0xc2, 0x70, 0xc2, 0x76, 0xbe, 0xb2, 0x17, 0x85, 0xa4, 0x7a, 0x1f, 0x3e, 0x88, 0x85,
+	0x85, 0x0f, 0x48, 0x4f, 0xa1, 0x12, 0x08, 0xa4, 0xaa, 0x8e, 0x5b, 0x71, 0x07, 0x0a, 0x5a, 0x1c,
+	0x52, 0xe9, 0x7f, 0xce, 0xc1, 0x0d, 0xd5, 0x26, 0x54, 0x52, 0x2f, 0x02, 0x9a, 0x8d, 0x05, 0x54,
+	0x68, 0x9e, 0x0b, 0x35, 0x7f, 0x02, 0x05, 0x7e, 0x70, 0x8b, 0x08, 0xdf, 0x4b, 0x0c, 0x52, 0x2a,
+	0xf5, 0xd9, 0x49, 0x8e, 0x39, 0x21, 0xba, 0x2f, 0x32, 0xa2, 0x90, 0x9a, 0x4d, 0x45, 0xe4, 0xef,
+	0x8b, 0xc8, 0x17, 0x97, 0x2c, 0xb3, 0x08, 0x6b, 0x50, 0x36, 0x28, 0x25, 0x93, 0xa9, 0x98, 0x1d,
+	0x1b, 0x58, 0x81, 0x68, 0x03, 0x6a, 0xd4, 0x37, 0x4c, 0x32, 0x35, 0x7c, 0xe2, 0x52, 0xd5, 0x76,
+	0x23, 0x28, 0xfd, 0x21, 0x14, 0xf8, 0x44, 0x01, 0x50, 0x3a, 0x38, 0x1e, 0xb1, 0x9a, 0xc9, 0xa0,
+	0x06, 0x54, 0x07, 0x6f, 0x46, 0x7d, 0x8c, 0x8f, 0x0f, 0x47, 0xad, 0xac, 0xfe, 0x6f, 0x36, 0x82,
+	0xb1, 0x7c, 0x12, 0xcd, 0x52, 0x39, 0x63, 0x5b, 0x1a, 0x29, 0xca, 0xfb, 0x81, 0x72, 0x67, 0x8a,
+	0x30, 0x6a, 0x67, 0x0b, 0xf2, 0xec, 0x80, 0x10, 0x9e, 0x62, 0xbf, 0x8b, 0xa6, 0x92, 0x8f, 0x34,
+	0x15, 0x1d, 0x2f, 0xb4, 0xea, 0xe2, 0xbe, 0xd0, 0xaa, 0x02, 0x05, 0xdc, 0xef, 0xb0, 0x46, 0xb8,
+	0xa8, 0xef, 0x1c, 0xfb, 0xef, 0xf5, 0x87, 0xfd, 0x51, 0xbf, 0x95, 0x47, 0x75, 0xa8, 0x74, 0xf7,
+	0xbe, 0x3c, 0xe1, 0x54, 0x05, 0xd4, 0x04, 0x60, 0x90, 0xa4, 0x2c, 0xea, 0xff, 0xcd, 0x41, 0xbd,
+	0x7f, 0x41, 0x5c, 0xaa, 0x0c, 0x78, 0x1c, 0x33, 0x40, 0x93, 0x06, 0x44, 0x49, 0xa2, 0xaa, 0xff,
+	0x0a, 0x50, 0x90, 0xb2, 0x2d, 0x31, 0x66, 0xa4, 0x8d, 0xdf, 0xcf, 0xe0, 0x25, 0x6c, 0xd1, 0xa1,
+	0x5b, 0x49, 0xca, 0x2f, 0x1d, 0xba, 0x17, 0x62, 0x92, 0x0c, 0x68, 0x17, 0x9a, 0x6a, 0xf0, 0x9a,
+	0x73, 0xa5, 0xb5, 0x42, 0x6c, 0x98, 0xea, 0xc5, 0x16, 0xf7, 0x33, 0x38, 0x41, 0xce, 0x82, 0x11,
+	0x90, 0xf7, 0x3c, 0xab, 0x0a, 0x98, 0xfd, 0xea, 0xcf, 0xa5, 0xdb, 0x6f, 0x40, 0x2d, 0x62, 0x4a,
+	0x2b, 0xc3, 0x3c, 0xab, 0x34, 0x6a, 0x65, 0x59, 0x7e, 0x84, 0xc2, 0x5b, 0xb9, 0xd7, 0x65, 0x28,
+	0x12, 0x26, 0x50, 0xd7, 0xa1, 0x7e, 0x40, 0xa8, 0x6f, 0x9b, 0x01, 0x26, 0x53, 0x67, 0xce, 0x0e,
+	0x72, 0x3e, 0x81, 0xc8, 0x83, 0x9c, 0xfd, 0xeb, 0xa7, 0x50, 0xff, 0x8d, 0x41, 0xcd, 0xf3, 0x55,
+	0x45, 0xaf, 0x0e, 0xff, 0x1c, 0xd7, 0x8a, 0xff, 0xa3, 0x2d, 0x28, 0xb2, 0x10, 0x88, 0x51, 0xe8,
+	0xb2, 0x48, 0x09, 0x32, 0xdd, 0x83, 0x66, 0xdc, 0xf8, 0xd4, 0x2e, 0xe9, 0x3c, 0xbc, 0x0b, 0x15,
+	0x9e, 0x7a, 0x27, 0xb6, 0x25, 0x53, 0xb1, 0xcc, 0xe1, 0x81, 0x95, 0x2c, 0xa2, 0x42, 0xba, 0x88,
+	0x8e, 0xa0, 0x95, 0xbc, 0xed, 0xa1, 0xdd, 0x34, 0x4e, 0x76, 0x9e, 0x9b, 0x4b, 0x2e, 0x88, 0x38,
+	0x45, 0x1c, 0x15, 0x1a, 0xde, 0xf3, 0x76, 0xd3, 0xb8, 0x15, 0x42, 0xd9, 0x32, 0x4e, 0x11, 0xeb,
+	0xff, 0xc8, 0xca, 0xc1, 0x49, 0x5d, 0xff, 0x5a, 0x90, 0x37, 0x67, 0x3e, 0x77, 0x4d, 0x1e, 0xb3,
+	0x5f, 0x56, 0x91, 0xe6, 0x64, 0xea, 0x50, 0xee, 0x9d, 0x0a, 0x16, 0x00, 0x7a, 0x04, 0x45, 0xf3,
+	0xdc, 0xb0, 0x5d, 0x2d, 0xbf, 0x7a, 0x3b, 0x41, 0x11, 0x6d, 0x47, 0x85, 0x78, 0x3b, 0xd2, 0xa0,
+	0xec, 0xb3, 0x04, 0x21, 0x01, 0xcf, 0xba, 0x06, 0x56, 0x20, 0x5b, 0xf9, 0xda, 0xb0, 0xa9, 0xed,
+	0x8e, 0xe5, 0xf4, 0xa8, 0x40, 0xfd, 0x7f, 0x11, 0x8d, 0x8f, 0x28, 0x99, 0x5e, 0xf9, 0x94, 0x6d,
+	0x43, 0x45, 0x95, 0x8c, 0x8c, 0x68, 0x08, 0x5f, 0xa2, 0xe2, 0x73, 0x28, 0x07, 0xd4, 0xf0, 0x29,
+	0xb1, 0xb4, 0xe2, 0xda, 0xb3, 0x4d, 0x91, 0xa2, 0xa7, 0x50, 0x24, 0xae, 0x45, 0x2c, 0xad, 0xb4,
+	0x96, 0x47, 0x10, 0x32, 0x2f, 0xf3, 0x43, 0x58, 0xf6, 0x64, 0x01, 0xe8, 0xff, 0xc9, 0x03, 0x8a,
+	0x86, 0x07, 0x13, 0xd3, 0xf3, 0x79, 0x51, 0xf0, 0xb7, 0x0b, 0x59, 0x48, 0xf2, 0xb1, 0xa2, 0xc8,
+	0x77, 0x57, 0xd3, 0x18, 0x07, 0x58, 0x38, 0x89, 0xab, 0x32, 0x98, 0xfd, 0x46, 0x0d, 0x2a, 0x7c,
+	0x0f, 0x83, 0x8a, 0x57, 0x35, 0x68, 0x07, 0x2a, 0xea, 0xbd, 0x46, 0x2b, 0xad, 0x3d, 0xad, 0x15,
+	0x29, 0x7a, 0x09, 0x65, 0x6f, 0x46, 0x4d, 0x6f, 0x22, 0x2e, 0x05, 0xcd, 0xed, 0x87, 0x4b, 0x1e,
+	0x2d, 0x84, 0x1b, 0xb6, 0xde, 0x0a, 0x42, 0xac, 0x38, 0xc4, 0x2d, 0xde, 0x08, 0x3c, 0x97, 0x3f,
+	0x59, 0x54, 0xb1, 0x84, 0x58, 0xb2, 0x06, 0x94, 0x4c, 0x03, 0xad, 0xba, 0x34, 0x59, 0x59, 0x1a,
+	0x61, 0x41, 0xc1, 0xea, 0x9e, 0x57, 0x32, 0xab, 0x7b, 0x10, 0x99, 0xc7, 0xe1, 0x81, 0xa5, 0xff,
+	0x12, 0xca, 0x72, 0x47, 0x76, 0xca, 0x74, 0xba, 0xa3, 0xc1, 0x57, 0x7d, 0xd1, 0x0b, 0xbb, 0x6f,
+	0x0f, 0x0e, 0xf9, 0x99, 0xc3, 0xcf, 0xa2, 0xbd, 0xce, 0x60, 0xd8, 0xef, 0xb5, 0x72, 0xac, 0x6d,
+	0x86, 0xe7, 0x66, 0xbf, 0xd7, 0xca, 0xeb, 0x7b, 0x8b, 0x11, 0x42, 0x3d, 0x95, 0x3c, 0x63, 0x25,
+	0xc0, 0xac, 0x51, 0x73, 0xc8, 0xdd, 0x95, 0xf6, 0x62, 0x45, 0xa9, 0xff, 0x01, 0xea, 0xd1, 0xf6,
+	0xc0, 0x62, 0xef, 0x18, 0xa7, 0xc4, 0x91, 0x09, 0x21, 0x80, 0xd4, 0x14, 0xf2, 0x09, 0x14, 0x4d,
+	0xcf, 0xf1, 0x7c, 0x79, 0xb4, 0xb4, 0x22, 0x73, 0x43, 0x97, 0xe1, 0xb1, 0x58, 0xd6, 0xff, 0x04,
+	0xf5, 0x68, 0x19, 0x5f, 0xe5, 0xfe, 0x25, 0xf7, 0xca, 0xa7, 0xf7, 0x2a, 0xc4, 0xf6, 0x62, 0xf2,
+	0x62, 0x7b, 0xfd, 0x0e, 0xaa, 0x21, 0x8e, 0x77, 0x1a, 0xce, 0x24, 0xcd, 0xe0, 0x00, 0xfa, 0x08,
+	0xaa, 0xe7, 0xf6, 0xf8, 0xdc, 0xb1, 0xc7, 0xe7, 0x2a, 0xb9, 0x17, 0x08, 0x56, 0xb9, 0xb6, 0x7b,
+	0x4e, 0x7c, 0x5b, 0x3c, 0xd3, 0x55, 0xb0, 0x02, 0xf5, 0x2e, 0x54, 0x43, 0xd3, 0x58, 0x66, 0x9c,
+	0x7a, 0xbe, 0x45, 0x94, 0x6c, 0x09, 0xa1, 0x07, 0x00, 0xa7, 0x86, 0xf9, 0x6e, 0xec, 0x7b, 0x33,
+	0x57, 0xf9, 0x2a, 0x82, 0xd1, 0x87, 0x00, 0x43, 0x6f, 0x7c, 0x40, 0x82, 0xc0, 0x18, 0xf3, 0xfc,
+	0xf2, 0x7c, 0x9b, 0xbd, 0x06, 0x49, 0x29, 0x02, 0xe2, 0xfe, 0x27, 0x17, 0x44, 0x1c, 0x20, 0x0d,
+	0x2c, 0x00, 0x56, 0x7b, 0x6c, 0xc4, 0x96, 0xb5, 0x37, 0x09, 0xc6, 0xdb, 0xdf, 0xdd, 0x80, 0x7c,
+	0xe7, 0x70, 0x80, 0x3e, 0x85, 0x1a, 0x7f, 0xd6, 0xea, 0xfa, 0xc4, 0xa0, 0x04, 0xc5, 0x9e, 0xba,
+	0xda, 0x31, 0x48, 0xcf, 0xa0, 0x47, 0x50, 0xe5, 0xbf, 0x98, 0x18, 0xd6, 0x1a, 0xd2, 0xc7, 0x50,
+	0x0f, 0x49, 0x7b, 0x81, 0xb9, 0x86, 0x5a, 0x69, 0x71, 0x3c, 0xb5, 0xd6, 0x6b, 0xb1, 0x05, 0xcd,
+	0x08, 0xf1, 0x7a, 0xe1, 0x3f, 0x81, 0x06, 0xff, 0x3d, 0x22, 0x94, 0xdf, 0x22, 0xaf, 0xa8, 0x4b,
+	0x8f, 0x38, 0x64, 0xad, 0x2e, 0x2f, 0x23, 0x66, 0x76, 0x1c, 0x07, 0xdd, 0x49, 0x35, 0x16, 0xfe,
+	0xce, 0xdc, 0xfe, 0x20, 0xca, 0xc7, 0x9f, 0x19, 0xf5, 0x0c, 0xfa, 0x05, 0xdc, 0x88, 0x32, 0x33,
+	0x4b, 0xae, 0xc5, 0xff, 0x0a, 0x90, 0x84, 0x17, 0x05, 0x18, 0xac, 0x14, 0x91, 0x54, 0x3d, 0xc9,
+	0xcd, 0x92, 0xff, 0xea, 0xdc, 0x3f, 0x83, 0x3b, 0xfc, 0x97, 0xed, 0x19, 0xdf, 0xff, 0x72, 0x87,
+	0x2d, 0xe3, 0x13, 0x3b, 0x5f, 0xce, 0xb7, 0x03, 0xb7, 0x53, 0x7c, 0x7c, 0x4a, 0xb8, 0x9c, 0xed,
+	0x05, 0x68, 0x29, 0x36, 0xd5, 0xef, 0x2e, 0xe7, 0xfc, 0x02, 0x9a, 0x91, 0x34, 0xb8, 0x76, 0x6c,
+	0x77, 0x64, 0x16, 0xed, 0xf9, 0x84, 0x7c, 0x4b, 0xae, 0xec, 0xd6, 0x67, 0xb2, 0xc2, 0x46, 0xe7,
+	0xc6, 0xd7, 0x57, 0x66, 0x5a, 0xec, 0xe5, 0x7d, 0x4b, 0xdc, 0x2b, 0xb3, 0xbd, 0x80, 0xb2, 0x9c,
+	0x89, 0x57, 0xb2, 0x84, 0xc7, 0x53, 0x64, 0x76, 0xd6, 0x33, 0xa8, 0x0b, 0xb5, 0xc8, 0xc3, 0x37,
+	0xba, 0x9b, 0xbc, 0xf0, 0x87, 0x8f, 0xe1, 0xed, 0xdb, 0x4b, 0x9f, 0x56, 0xf4, 0xcc, 0xd3, 0x2c,
+	0xea, 0x84, 0x42, 0xf8, 0xfc, 0xb8, 0x4a, 0x05, 0x6d, 0xd9, 0x65, 0x98, 0x0f, 0x8c, 0xac, 0xfa,
+	0x40, 0xa2, 0xbf, 0x24, 0x14, 0x25, 0xf6, 0x52, 0x2a, 0x2c, 0xbd, 0x4d, 0xf3, 0x00, 0xd7, 0x17,
+	0x28, 0xff, 0xda, 0xec, 0xaf, 0x42, 0xf5, 0x8f, 0xa8, 0x37, 0xbd, 0x2e, 0xf7, 0x6e, 0xe4, 0xc5,
+	0x27, 0xf8, 0x3e, 0xdb, 0x77, 0xa0, 0xb5, 0xf0, 0xe9, 0x99, 0x3d, 0x9e, 0xf9, 0xe4, 0xba, 0x22,
+	0x86, 0xd0, 0x4a, 0xbe, 0x77, 0xa0, 0x07, 0x71, 0xda, 0xe4, 0x43, 0x48, 0x7b, 0x45, 0x94, 0xf4,
+	0x0c, 0xea, 0x2f, 0x8e, 0xea, 0x75, 0x49, 0xb1, 0xe2, 0x26, 0xc9, 0xb3, 0x62, 0x17, 0xaa, 0xfc,
+	0x5e, 0xb4, 0x4e, 0xc6, 0xcd, 0x25, 0x37, 0x2d, 0x2e, 0x60, 0x07, 0x8a, 0xfc, 0x16, 0x87, 0x14,
+	0x45, 0xf4, 0x4e, 0xb7, 0x9a, 0xed, 0x35, 0x34, 0xc2, 0x8b, 0x19, 0xdf, 0x7b, 0xf9, 0x5d, 0x75,
+	0xb5, 0x03, 0x36, 0xb3, 0x2c, 0x1d, 0x87, 0xde, 0x78, 0x4c, 0x7c, 0x2e, 0x40, 0xb5, 0x85, 0xc5,
+	0xa1, 0x7d, 0x19, 0xf3, 0x69, 0x89, 0xe3, 0x9e, 0xfd, 0x7f, 0x00, 0xfb, 0x6d, 0xee, 0xf3, 0xf9,
+	0x1c, 0x00, 0x00,
 }
@@ -3,10 +3,14 @@
 
 package proto
 
-import proto "github.com/golang/protobuf/proto"
-import fmt "fmt"
-import math "math"
-import any "github.com/golang/protobuf/ptypes/any"
+import (
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	any "github.com/golang/protobuf/ptypes/any"
+	duration "github.com/golang/protobuf/ptypes/duration"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	math "math"
+)
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
@@ -17,7 +21,35 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 // A compilation error at this line likely means your copy of the
 // proto package needs to be updated.
-const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type RestartPolicy_Mode int32
+
+const (
+	RestartPolicy_NEVER      RestartPolicy_Mode = 0
+	RestartPolicy_ON_FAILURE RestartPolicy_Mode = 1
+	RestartPolicy_ALWAYS     RestartPolicy_Mode = 2
+)
+
+var RestartPolicy_Mode_name = map[int32]string{
+	0: "NEVER",
+	1: "ON_FAILURE",
+	2: "ALWAYS",
+}
+
+var RestartPolicy_Mode_value = map[string]int32{
+	"NEVER":      0,
+	"ON_FAILURE": 1,
+	"ALWAYS":     2,
+}
+
+func (x RestartPolicy_Mode) String() string {
+	return proto.EnumName(RestartPolicy_Mode_name, int32(x))
+}
+
+func (RestartPolicy_Mode) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_3d00b0fd20962128, []int{0, 0}
+}
 
 type ServiceInstance_ServiceState int32
 
@@ -36,6 +68,7 @@ var ServiceInstance_ServiceState_name = map[int32]string{
 	3: "RUN",
 	4: "ERROR",
 }
+
 var ServiceInstance_ServiceState_value = map[string]int32{
 	"UNKNOWN": 0,
 	"INIT":    1,
@@ -47,8 +80,154 @@ var ServiceInstance_ServiceState_value = map[string]int32{
 func (x ServiceInstance_ServiceState) String() string {
 	return proto.EnumName(ServiceInstance_ServiceState_name, int32(x))
 }
+
 func (ServiceInstance_ServiceState) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_ServiceInstance_876f327f391189d8, []int{0, 0}
+	return fileDescriptor_3d00b0fd20962128, []int{2, 0}
+}
+
+// A RestartPolicy says when a service's process gets restarted after it exits, while the service should be running.
+// Anything it leaves unset is taken from kraken's default.
+type RestartPolicy struct {
+	Mode                 RestartPolicy_Mode `protobuf:"varint,1,opt,name=mode,proto3,enum=proto.RestartPolicy_Mode" json:"mode,omitempty"`
+	MaxRestarts          uint32             `protobuf:"varint,2,opt,name=max_restarts,json=maxRestarts,proto3" json:"max_restarts,omitempty"`
+	Backoff              *duration.Duration `protobuf:"bytes,3,opt,name=backoff,proto3" json:"backoff,omitempty"`
+	MaxBackoff           *duration.Duration `protobuf:"bytes,4,opt,name=max_backoff,json=maxBackoff,proto3" json:"max_backoff,omitempty"`
+	ResetAfter           *duration.Duration `protobuf:"bytes,5,opt,name=reset_after,json=resetAfter,proto3" json:"reset_after,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *RestartPolicy) Reset()         { *m = RestartPolicy{} }
+func (m *RestartPolicy) String() string { return proto.CompactTextString(m) }
+func (*RestartPolicy) ProtoMessage()    {}
+func (*RestartPolicy) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3d00b0fd20962128, []int{0}
+}
+
+func (m *RestartPolicy) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RestartPolicy.Unmarshal(m, b)
+}
+func (m *RestartPolicy) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RestartPolicy.Marshal(b, m, deterministic)
+}
+func (m *RestartPolicy) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RestartPolicy.Merge(m, src)
+}
+func (m *RestartPolicy) XXX_Size() int {
+	return xxx_messageInfo_RestartPolicy.Size(m)
+}
+func (m *RestartPolicy) XXX_DiscardUnknown() {
+	xxx_messageInfo_RestartPolicy.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RestartPolicy proto.InternalMessageInfo
+
+func (m *RestartPolicy) GetMode() RestartPolicy_Mode {
+	if m != nil {
+		return m.Mode
+	}
+	return RestartPolicy_NEVER
+}
+
+func (m *RestartPolicy) GetMaxRestarts() uint32 {
+	if m != nil {
+		return m.MaxRestarts
+	}
+	return 0
+}
+
+func (m *RestartPolicy) GetBackoff() *duration.Duration {
+	if m != nil {
+		return m.Backoff
+	}
+	return nil
+}
+
+func (m *RestartPolicy) GetMaxBackoff() *duration.Duration {
+	if m != nil {
+		return m.MaxBackoff
+	}
+	return nil
+}
+
+func (m *RestartPolicy) GetResetAfter() *duration.Duration {
+	if m != nil {
+		return m.ResetAfter
+	}
+	return nil
+}
+
+// A ResourcePolicy limits the resources a service's process may use, through its cgroup v2 cgroup
+type ResourcePolicy struct {
+	CpuWeight            uint32   `protobuf:"varint,1,opt,name=cpu_weight,json=cpuWeight,proto3" json:"cpu_weight,omitempty"`
+	CpuQuota             uint32   `protobuf:"varint,2,opt,name=cpu_quota,json=cpuQuota,proto3" json:"cpu_quota,omitempty"`
+	MemoryMax            uint64   `protobuf:"varint,3,opt,name=memory_max,json=memoryMax,proto3" json:"memory_max,omitempty"`
+	PidsMax              uint64   `protobuf:"varint,4,opt,name=pids_max,json=pidsMax,proto3" json:"pids_max,omitempty"`
+	Cpus                 string   `protobuf:"bytes,5,opt,name=cpus,proto3" json:"cpus,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResourcePolicy) Reset()         { *m = ResourcePolicy{} }
+func (m *ResourcePolicy) String() string { return proto.CompactTextString(m) }
+func (*ResourcePolicy) ProtoMessage()    {}
+func (*ResourcePolicy) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3d00b0fd20962128, []int{1}
+}
+
+func (m *ResourcePolicy) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResourcePolicy.Unmarshal(m, b)
+}
+func (m *ResourcePolicy) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResourcePolicy.Marshal(b, m, deterministic)
+}
+func (m *ResourcePolicy) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResourcePolicy.Merge(m, src)
+}
+func (m *ResourcePolicy) XXX_Size() int {
+	return xxx_messageInfo_ResourcePolicy.Size(m)
+}
+func (m *ResourcePolicy) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResourcePolicy.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResourcePolicy proto.InternalMessageInfo
+
+func (m *ResourcePolicy) GetCpuWeight() uint32 {
+	if m != nil {
+		return m.CpuWeight
+	}
+	return 0
+}
+
+func (m *ResourcePolicy) GetCpuQuota() uint32 {
+	if m != nil {
+		return m.CpuQuota
+	}
+	return 0
+}
+
+func (m *ResourcePolicy) GetMemoryMax() uint64 {
+	if m != nil {
+		return m.MemoryMax
+	}
+	return 0
+}
+
+func (m *ResourcePolicy) GetPidsMax() uint64 {
+	if m != nil {
+		return m.PidsMax
+	}
+	return 0
+}
+
+func (m *ResourcePolicy) GetCpus() string {
+	if m != nil {
+		return m.Cpus
+	}
+	return ""
 }
 
 type ServiceInstance struct {
@@ -57,6 +236,12 @@ type ServiceInstance struct {
 	State                ServiceInstance_ServiceState `protobuf:"varint,3,opt,name=state,proto3,enum=proto.ServiceInstance_ServiceState" json:"state,omitempty"`
 	Config               *any.Any                     `protobuf:"bytes,4,opt,name=config,proto3" json:"config,omitempty"`
 	ErrorMsg             string                       `protobuf:"bytes,5,opt,name=error_msg,json=errorMsg,proto3" json:"error_msg,omitempty"`
+	RestartPolicy        *RestartPolicy               `protobuf:"bytes,6,opt,name=restart_policy,json=restartPolicy,proto3" json:"restart_policy,omitempty"`
+	Restarts             uint32                       `protobuf:"varint,7,opt,name=restarts,proto3" json:"restarts,omitempty"`
+	ExitStatus           int32                        `protobuf:"varint,8,opt,name=exit_status,json=exitStatus,proto3" json:"exit_status,omitempty"`
+	LastExit             *timestamp.Timestamp         `protobuf:"bytes,9,opt,name=last_exit,json=lastExit,proto3" json:"last_exit,omitempty"`
+	Resources            *ResourcePolicy              `protobuf:"bytes,10,opt,name=resources,proto3" json:"resources,omitempty"`
+	Cgroup               string                       `protobuf:"bytes,11,opt,name=cgroup,proto3" json:"cgroup,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                     `json:"-"`
 	XXX_unrecognized     []byte                       `json:"-"`
 	XXX_sizecache        int32                        `json:"-"`
@@ -66,16 +251,17 @@ func (m *ServiceInstance) Reset()         { *m = ServiceInstance{} }
 func (m *ServiceInstance) String() string { return proto.CompactTextString(m) }
 func (*ServiceInstance) ProtoMessage()    {}
 func (*ServiceInstance) Descriptor() ([]byte, []int) {
-	return fileDescriptor_ServiceInstance_876f327f391189d8, []int{0}
+	return fileDescriptor_3d00b0fd20962128, []int{2}
 }
+
 func (m *ServiceInstance) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ServiceInstance.Unmarshal(m, b)
 }
 func (m *ServiceInstance) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_ServiceInstance.Marshal(b, m, deterministic)
 }
-func (dst *ServiceInstance) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_ServiceInstance.Merge(dst, src)
+func (m *ServiceInstance) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServiceInstance.Merge(m, src)
 }
 func (m *ServiceInstance) XXX_Size() int {
 	return xxx_messageInfo_ServiceInstance.Size(m)
@@ -121,31 +307,97 @@ func (m *ServiceInstance) GetErrorMsg() string {
 	return ""
 }
 
+func (m *ServiceInstance) GetRestartPolicy() *RestartPolicy {
+	if m != nil {
+		return m.RestartPolicy
+	}
+	return nil
+}
+
+func (m *ServiceInstance) GetRestarts() uint32 {
+	if m != nil {
+		return m.Restarts
+	}
+	return 0
+}
+
+func (m *ServiceInstance) GetExitStatus() int32 {
+	if m != nil {
+		return m.ExitStatus
+	}
+	return 0
+}
+
+func (m *ServiceInstance) GetLastExit() *timestamp.Timestamp {
+	if m != nil {
+		return m.LastExit
+	}
+	return nil
+}
+
+func (m *ServiceInstance) GetResources() *ResourcePolicy {
+	if m != nil {
+		return m.Resources
+	}
+	return nil
+}
+
+func (m *ServiceInstance) GetCgroup() string {
+	if m != nil {
+		return m.Cgroup
+	}
+	return ""
+}
+
 func init() {
-	proto.RegisterType((*ServiceInstance)(nil), "proto.ServiceInstance")
+	proto.RegisterEnum("proto.RestartPolicy_Mode", RestartPolicy_Mode_name, RestartPolicy_Mode_value)
 	proto.RegisterEnum("proto.ServiceInstance_ServiceState", ServiceInstance_ServiceState_name, ServiceInstance_ServiceState_value)
+	proto.RegisterType((*RestartPolicy)(nil), "proto.RestartPolicy")
+	proto.RegisterType((*ResourcePolicy)(nil), "proto.ResourcePolicy")
+	proto.RegisterType((*ServiceInstance)(nil), "proto.ServiceInstance")
 }
 
-func init() {
-	proto.RegisterFile("ServiceInstance.proto", fileDescriptor_ServiceInstance_876f327f391189d8)
-}
-
-var fileDescriptor_ServiceInstance_876f327f391189d8 = []byte{
-	// 256 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x8e, 0xcf, 0x4b, 0xfb, 0x40,
-	0x14, 0xc4, 0xbf, 0xf9, 0xd9, 0xe6, 0xf5, 0x4b, 0x0d, 0x0f, 0x95, 0x55, 0x2f, 0xa1, 0x5e, 0x72,
-	0x90, 0x2d, 0xd4, 0x93, 0x47, 0x11, 0x0f, 0x41, 0xdc, 0xc8, 0xa6, 0xc5, 0xa3, 0xa4, 0xc9, 0x76,
-	0x09, 0xb4, 0xbb, 0xb2, 0x49, 0x85, 0xde, 0xfd, 0xc3, 0xa5, 0x9b, 0x08, 0xd2, 0xd3, 0xee, 0x0c,
-	0x33, 0x6f, 0x3e, 0x70, 0x51, 0x08, 0xf3, 0xd5, 0x54, 0x22, 0x53, 0x6d, 0x57, 0xaa, 0x4a, 0xd0,
-	0x4f, 0xa3, 0x3b, 0x8d, 0x81, 0x7d, 0xae, 0xaf, 0xa4, 0xd6, 0x72, 0x2b, 0xe6, 0x56, 0xad, 0xf7,
-	0x9b, 0x79, 0xa9, 0x0e, 0x7d, 0x62, 0xf6, 0xed, 0xc2, 0xd9, 0x49, 0x17, 0xa7, 0xe0, 0x36, 0x35,
-	0x71, 0x12, 0x27, 0x8d, 0xb8, 0xdb, 0xd4, 0x78, 0x09, 0xe1, 0x4e, 0xd7, 0xfb, 0xad, 0x20, 0xae,
-	0xf5, 0x06, 0x85, 0x0f, 0x10, 0xb4, 0x5d, 0xd9, 0x09, 0xe2, 0x25, 0x4e, 0x3a, 0x5d, 0xdc, 0xf6,
-	0x27, 0xe9, 0x29, 0xca, 0xa0, 0x8b, 0x63, 0x94, 0xf7, 0x0d, 0xbc, 0x83, 0xb0, 0xd2, 0x6a, 0xd3,
-	0x48, 0xe2, 0x27, 0x4e, 0x3a, 0x59, 0x9c, 0xd3, 0x1e, 0x91, 0xfe, 0x22, 0xd2, 0x47, 0x75, 0xe0,
-	0x43, 0x06, 0x6f, 0x20, 0x12, 0xc6, 0x68, 0xf3, 0xb1, 0x6b, 0x25, 0x09, 0x2c, 0xc3, 0xd8, 0x1a,
-	0xaf, 0xad, 0x9c, 0x3d, 0xc1, 0xff, 0xbf, 0x0b, 0x38, 0x81, 0xd1, 0x8a, 0xbd, 0xb0, 0xfc, 0x9d,
-	0xc5, 0xff, 0x70, 0x0c, 0x7e, 0xc6, 0xb2, 0x65, 0xec, 0x1c, 0x7f, 0xc5, 0x32, 0x7f, 0x8b, 0x5d,
-	0x1c, 0x81, 0xc7, 0x57, 0x2c, 0xf6, 0x30, 0x82, 0xe0, 0x99, 0xf3, 0x9c, 0xc7, 0xfe, 0x3a, 0xb4,
-	0xbb, 0xf7, 0x3f, 0x01, 0x00, 0x00, 0xff, 0xff, 0xf6, 0x93, 0xc6, 0xca, 0x48, 0x01, 0x00, 0x00,
+func init() { proto.RegisterFile("ServiceInstance.proto", fileDescriptor_3d00b0fd20962128) }
+
+var fileDescriptor_3d00b0fd20962128 = []byte{
+	// 616 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x53, 0xd1, 0x6e, 0xd3, 0x3c,
+	0x18, 0x5d, 0xba, 0xb4, 0x4d, 0xbe, 0xae, 0xfd, 0x23, 0xeb, 0x1f, 0x4a, 0x8b, 0x60, 0xa5, 0xdc,
+	0xf4, 0x82, 0x75, 0xd2, 0x76, 0x81, 0x80, 0xab, 0x02, 0x45, 0xaa, 0xd8, 0xd2, 0xe1, 0x6e, 0x4c,
+	0x5c, 0x45, 0x5e, 0xe2, 0x86, 0x88, 0xa6, 0x0e, 0xb6, 0x03, 0xe9, 0x3d, 0x4f, 0xc1, 0xbb, 0xf0,
+	0x6e, 0xc8, 0x8e, 0xcb, 0x46, 0x87, 0xb4, 0xab, 0xe6, 0x7c, 0xdf, 0x39, 0xc9, 0xf1, 0xf1, 0x29,
+	0xec, 0xcf, 0x29, 0xff, 0x96, 0x46, 0x74, 0xba, 0x12, 0x92, 0xac, 0x22, 0x3a, 0xca, 0x39, 0x93,
+	0x0c, 0xd5, 0xf5, 0x4f, 0xaf, 0x9b, 0x30, 0x96, 0x2c, 0xe9, 0x91, 0x46, 0xd7, 0xc5, 0xe2, 0x88,
+	0xac, 0xd6, 0x15, 0xa3, 0xf7, 0x78, 0x7b, 0x15, 0x17, 0x9c, 0xc8, 0x94, 0xad, 0xcc, 0xfe, 0x60,
+	0x7b, 0x2f, 0xd3, 0x8c, 0x0a, 0x49, 0xb2, 0xbc, 0x22, 0x0c, 0x7e, 0xd5, 0xa0, 0x8d, 0xd5, 0x84,
+	0xcb, 0x73, 0xb6, 0x4c, 0xa3, 0x35, 0x3a, 0x04, 0x3b, 0x63, 0x31, 0xf5, 0xad, 0xbe, 0x35, 0xec,
+	0x1c, 0x77, 0x2b, 0xde, 0xe8, 0x2f, 0xce, 0xe8, 0x8c, 0xc5, 0x14, 0x6b, 0x1a, 0x7a, 0x02, 0x7b,
+	0x19, 0x29, 0x43, 0x5e, 0xed, 0x85, 0x5f, 0xeb, 0x5b, 0xc3, 0x36, 0x6e, 0x65, 0xa4, 0x34, 0x12,
+	0x81, 0x4e, 0xa0, 0x79, 0x4d, 0xa2, 0x2f, 0x6c, 0xb1, 0xf0, 0x77, 0xfb, 0xd6, 0xb0, 0x75, 0xdc,
+	0x1d, 0x55, 0xb6, 0x46, 0x1b, 0x5b, 0xa3, 0xb7, 0xc6, 0x36, 0xde, 0x30, 0xd1, 0x4b, 0x50, 0xef,
+	0x08, 0x37, 0x42, 0xfb, 0x3e, 0x21, 0x64, 0xa4, 0x7c, 0x7d, 0xa3, 0xe5, 0x54, 0x50, 0x19, 0x92,
+	0x85, 0xa4, 0xdc, 0xaf, 0xdf, 0xab, 0xd5, 0xec, 0xb1, 0x22, 0x0f, 0x0e, 0xc1, 0x56, 0xa7, 0x43,
+	0x2e, 0xd4, 0x83, 0xc9, 0xc7, 0x09, 0xf6, 0x76, 0x50, 0x07, 0x60, 0x16, 0x84, 0xef, 0xc6, 0xd3,
+	0xd3, 0x4b, 0x3c, 0xf1, 0x2c, 0x04, 0xd0, 0x18, 0x9f, 0x5e, 0x8d, 0x3f, 0xcd, 0xbd, 0xda, 0xe0,
+	0xa7, 0x05, 0x1d, 0x4c, 0x05, 0x2b, 0x78, 0x44, 0x4d, 0x80, 0x8f, 0x00, 0xa2, 0xbc, 0x08, 0xbf,
+	0xd3, 0x34, 0xf9, 0x2c, 0x75, 0x8c, 0x6d, 0xec, 0x46, 0x79, 0x71, 0xa5, 0x07, 0xe8, 0x21, 0x28,
+	0x10, 0x7e, 0x2d, 0x98, 0x24, 0x26, 0x2d, 0x27, 0xca, 0x8b, 0x0f, 0x0a, 0x2b, 0x6d, 0x46, 0x33,
+	0xc6, 0xd7, 0x61, 0x46, 0x4a, 0x9d, 0x96, 0x8d, 0xdd, 0x6a, 0x72, 0x46, 0x4a, 0xd4, 0x05, 0x27,
+	0x4f, 0x63, 0xa1, 0x97, 0xb6, 0x5e, 0x36, 0x15, 0x56, 0x2b, 0x04, 0x76, 0x94, 0x17, 0x42, 0x1f,
+	0xd6, 0xc5, 0xfa, 0x79, 0xf0, 0xc3, 0x86, 0xff, 0xb6, 0x9a, 0x85, 0x3a, 0x50, 0x4b, 0x63, 0xed,
+	0xca, 0xc5, 0xb5, 0x34, 0x46, 0x0f, 0xa0, 0x91, 0xb1, 0xb8, 0x58, 0x52, 0xed, 0xc5, 0xc5, 0x06,
+	0xa1, 0x17, 0x50, 0x17, 0x92, 0x48, 0xaa, 0x4d, 0x74, 0x8e, 0x9f, 0x9a, 0x1e, 0x6c, 0x17, 0xd5,
+	0xe0, 0xb9, 0xa2, 0xe2, 0x4a, 0x81, 0x9e, 0x41, 0x23, 0x62, 0xab, 0x45, 0x9a, 0x98, 0x5b, 0xfb,
+	0xff, 0x4e, 0xf2, 0xe3, 0xd5, 0x1a, 0x1b, 0x8e, 0xca, 0x83, 0x72, 0xce, 0x78, 0x98, 0x89, 0xc4,
+	0xb8, 0x77, 0xf4, 0xe0, 0x4c, 0x24, 0xe8, 0x15, 0x74, 0x4c, 0xb3, 0xc2, 0x5c, 0xa7, 0xeb, 0x37,
+	0xcc, 0x2b, 0xff, 0x51, 0x4b, 0xdc, 0xe6, 0xb7, 0x21, 0xea, 0x81, 0xf3, 0xa7, 0x96, 0xcd, 0x2a,
+	0xe8, 0x0d, 0x46, 0x07, 0xd0, 0xa2, 0x65, 0x2a, 0x43, 0xe5, 0xb8, 0x10, 0xbe, 0xd3, 0xb7, 0x86,
+	0x75, 0x0c, 0x6a, 0x34, 0xd7, 0x13, 0xf4, 0x1c, 0xdc, 0x25, 0x11, 0x32, 0x54, 0x23, 0xdf, 0xd5,
+	0x1f, 0xed, 0xdd, 0x39, 0xc7, 0xc5, 0xe6, 0xdf, 0x84, 0x1d, 0x45, 0x9e, 0x94, 0xa9, 0x44, 0x27,
+	0xe0, 0x72, 0x53, 0x08, 0xe1, 0x83, 0x16, 0xee, 0xdf, 0xb8, 0xbd, 0x55, 0x14, 0x7c, 0xc3, 0x53,
+	0xb7, 0x10, 0x25, 0x9c, 0x15, 0xb9, 0xdf, 0xaa, 0x6e, 0xa1, 0x42, 0x83, 0x37, 0xb0, 0x77, 0x3b,
+	0x61, 0xd4, 0x82, 0xe6, 0x65, 0xf0, 0x3e, 0x98, 0x5d, 0x05, 0xde, 0x0e, 0x72, 0xc0, 0x9e, 0x06,
+	0xd3, 0x0b, 0xcf, 0x52, 0x4f, 0xf3, 0x8b, 0xd9, 0xb9, 0x57, 0x43, 0x4d, 0xd8, 0xc5, 0x97, 0x81,
+	0xb7, 0xab, 0xfa, 0x3b, 0xc1, 0x78, 0x86, 0x3d, 0xfb, 0xba, 0xa1, 0xbf, 0x7e, 0xf2, 0x7b, 0x00,
+	0xf2, 0x2b, 0x37, 0xc6, 0x66, 0x04, 0x00, 0x00,
 }
@@ -0,0 +1,202 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/lib"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	ip4pb "github.com/hpc/kraken/extensions/IPv4/proto"
+)
+
+// TestAuthzPolicy tests that the default roles allow what they should
+func TestAuthzPolicy(t *testing.T) {
+	p := &AuthzPolicy{
+		Roles: DefaultAuthzRoles(),
+		Bindings: map[string][]string{
+			"alice":  {"operator"},
+			"bob":    {"admin"},
+			AuthzAny: {"observer"},
+		},
+	}
+	if e := p.Validate(); e != nil {
+		t.Fatalf("default roles don't validate: %v", e)
+	}
+	tests := []struct {
+		who   string
+		op    AuthzOp
+		url   string
+		allow bool
+	}{
+		{"eve", AuthzRead, "/", true},
+		{"eve", AuthzUpdate, "/PhysState", false},
+		{"alice", AuthzUpdate, "/PhysState", true},
+		{"alice", AuthzUpdate, "/Services/restapi/State", true},
+		{"alice", AuthzUpdate, "/Services/restapi/Config", false},
+		{"alice", AuthzUpdate, "/Nodename", false},
+		{"alice", AuthzDelete, "/", false},
+		{"alice", AuthzFreeze, "/", false},
+		{"bob", AuthzCreate, "/", true},
+		{"bob", AuthzUpdate, "type.googleapis.com/proto.IPv4OverEthernet/Ifaces/0/Ip", true},
+		{"bob", AuthzFreeze, "/", true},
+	}
+	for _, v := range tests {
+		if a := p.Allowed(v.who, v.op, v.url); a != v.allow {
+			t.Errorf("%s %s %s: expected %v, got %v", v.who, AuthzOpString[v.op], v.url, v.allow, a)
+		}
+	}
+
+	p.Bindings["mallory"] = []string{"nobody"}
+	if e := p.Validate(); e == nil {
+		t.Errorf("binding to an unknown role validated")
+	}
+}
+
+// TestAuthorizer tests that denials are errors, and counted
+func TestAuthorizer(t *testing.T) {
+	a := NewAuthorizerWithPolicy(&AuthzPolicy{
+		Roles:    DefaultAuthzRoles(),
+		Bindings: map[string][]string{"alice": {"observer"}},
+	}, &WriterLogger{})
+	in := func(who string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(PrincipalMetadataKey, who))
+	}
+	if e := a.Authorize(context.Background(), AuthzDelete, "n1"); e != nil {
+		t.Errorf("call without a principal was denied: %v", e)
+	}
+	if e := a.Authorize(in("alice"), AuthzRead, "n1"); e != nil {
+		t.Errorf("observer can't read: %v", e)
+	}
+	e := a.Authorize(in("alice"), AuthzUpdate, "n1", "/RunState", "/PhysState")
+	if status.Code(e) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied, got: %v", e)
+	}
+	var nilAuthz *Authorizer
+	if e := nilAuthz.Authorize(in("alice"), AuthzDelete, "n1"); e != nil {
+		t.Errorf("nil authorizer denied a call: %v", e)
+	}
+	fams := a.Metrics()
+	if len(fams) != 1 || len(fams[0].Metrics) != 1 || fams[0].Metrics[0].Value != 1 {
+		t.Fatalf("expected one denial to be counted, got: %+v", fams)
+	}
+	if l := fams[0].Metrics[0].Labels; len(l) != 2 || l[0].Value != "alice" || l[1].Value != "update" {
+		t.Errorf("bad denial labels: %+v", l)
+	}
+}
+
+// authzKraken boots a kraken with no services that enforces policy, trusting trusted on its API socket
+func authzKraken(t *testing.T, policy *AuthzPolicy, trusted []uint32) *Kraken {
+	b, e := json.Marshal(policy)
+	if e != nil {
+		t.Fatal(e)
+	}
+	pfile := filepath.Join(t.TempDir(), "policy.json")
+	if e = ioutil.WriteFile(pfile, b, 0600); e != nil {
+		t.Fatal(e)
+	}
+	return testKraken(t, func(k *Kraken) {
+		k.Ctx.RPC.TrustedUIDs = trusted
+		k.Ctx.Authz.PolicyFile = pfile
+	})
+}
+
+// testKraken boots a kraken that serves the API on a UNIX socket; setup can change its context before it starts
+func testKraken(t *testing.T, setup func(*Kraken)) *Kraken {
+	self := NewNodeWithID("123e4567-e89b-12d3-a456-426655440000")
+	self.AddExtension(&ip4pb.IPv4OverEthernet{})
+	self.SetValue("type.googleapis.com/proto.IPv4OverEthernet/Ifaces/0", reflect.ValueOf(&ip4pb.IPv4OverEthernet_ConfiguredInterface{
+		Eth: &ip4pb.Ethernet{Iface: "lo"},
+		Ip:  &ip4pb.IPv4{Ip: net.ParseIP("127.0.0.1").To4()},
+	}))
+	k := NewKraken(self, []string{}, &WriterLogger{})
+	k.Ctx.SSE.Port = 0
+	k.Ctx.RPC.Port = 0
+	k.Ctx.RPC.Path = filepath.Join(t.TempDir(), "kraken.sock")
+	if setup != nil {
+		setup(k)
+	}
+	k.Release()
+	t.Cleanup(k.Shutdown)
+	return k
+}
+
+// TestRPCSocket tests that the API socket is put in place with its permissions set, and removed at shutdown
+func TestRPCSocket(t *testing.T) {
+	k := testKraken(t, nil)
+	fs, e := ioutil.ReadDir(filepath.Dir(k.Ctx.RPC.Path))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(fs) != 1 || fs[0].Name() != filepath.Base(k.Ctx.RPC.Path) {
+		t.Errorf("expected only the API socket, got %d files", len(fs))
+	}
+	if fi, e := os.Stat(k.Ctx.RPC.Path); e != nil || fi.Mode()&os.ModeSocket == 0 || fi.Mode().Perm() != 0600 {
+		t.Errorf("API socket should only be usable by its owner: %v %v", fi.Mode(), e)
+	}
+	k.Shutdown()
+	if _, e := os.Stat(k.Ctx.RPC.Path); !os.IsNotExist(e) {
+		t.Errorf("API socket was left behind: %v", e)
+	}
+}
+
+// TestAPIServer_Authz tests that only trusted users of the API socket can say who a call is made on behalf of,
+// and that everyone else's calls, with or without a principal, are made on behalf of their user
+func TestAPIServer_Authz(t *testing.T) {
+	me, e := user.Current()
+	if e != nil {
+		t.Fatal(e)
+	}
+	uid, _ := strconv.ParseUint(me.Uid, 10, 32)
+	policy := &AuthzPolicy{
+		Roles:    DefaultAuthzRoles(),
+		Bindings: map[string][]string{"root-ish": {"admin"}},
+	}
+	tests := []struct {
+		name      string
+		trusted   []uint32
+		principal string
+		allow     bool
+		who       string // who a denied call was made on behalf of
+	}{
+		{"trusted, no principal", nil, "", true, ""},
+		{"trusted, allowed principal", nil, "root-ish", true, ""},
+		{"trusted, denied principal", nil, "eve", false, "eve"},
+		{"untrusted, no principal", []uint32{uint32(uid) + 1}, "", false, me.Username},
+		{"untrusted, spoofed principal", []uint32{uint32(uid) + 1}, "root-ish", false, me.Username},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			k := authzKraken(t, policy, v.trusted)
+			if fi, e := os.Stat(k.Ctx.RPC.Path); e != nil || fi.Mode().Perm() != 0600 {
+				t.Errorf("API socket should only be usable by its owner: %v %v", fi.Mode(), e)
+			}
+			var api lib.APIClient = NewAPIClient("unix:" + k.Ctx.RPC.Path)
+			if v.principal != "" {
+				api = api.WithPrincipal(v.principal)
+			}
+			e := api.QueryFreeze()
+			if v.allow && e != nil {
+				t.Fatalf("freeze was denied: %v", e)
+			}
+			if !v.allow && status.Code(e) != codes.PermissionDenied {
+				t.Fatalf("expected PermissionDenied, got: %v", e)
+			}
+			if !v.allow && !strings.HasPrefix(status.Convert(e).Message(), v.who+" ") {
+				t.Errorf("expected the call to be made on behalf of %s, got: %v", v.who, e)
+			}
+		})
+	}
+}
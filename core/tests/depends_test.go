@@ -0,0 +1,201 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/lib"
+
+	pb "github.com/hpc/kraken/core/proto"
+)
+
+const (
+	testSelfID  = "123e4567-e89b-12d3-a456-426655440000"
+	testOtherID = "223e4567-e89b-12d3-a456-426655440000"
+)
+
+// dependsKraken boots a kraken with a core mutation that sets /Platform to "follow" once the node in /ParentId has /Platform "on"
+func dependsKraken(t *testing.T, ctx lib.StateMutationContext, timeout time.Duration) *Kraken {
+	// the start of a path is what's discovered, so /Platform has to be discoverable
+	Registry.RegisterDiscoverable(NewServiceInstance("follow", "follow", nil), map[string]map[string]reflect.Value{
+		"/Platform": {"follow": reflect.ValueOf("follow"), "on": reflect.ValueOf("on"), "off": reflect.ValueOf("off"), "fail": reflect.ValueOf("fail")},
+	})
+	k := testKraken(t, func(k *Kraken) {
+		// self is POWER_ON and in SYNC; nodes that follow are put in SYNC too, so the graph only needs to be about /Platform
+		k.Ctx.SME.RootSpec = NewStateSpec(map[string]reflect.Value{
+			"/Platform": reflect.ValueOf(""),
+			"/RunState": reflect.ValueOf(pb.Node_SYNC),
+		}, map[string]reflect.Value{})
+		k.Ctx.SME.DrainTime = 100 * time.Millisecond
+	})
+	e := k.Sme.RegisterMutation("core", "follow", NewStateMutationWithDepends(
+		map[string][2]reflect.Value{"/Platform": {reflect.ValueOf(""), reflect.ValueOf("follow")}},
+		map[string]reflect.Value{},
+		map[string]reflect.Value{},
+		ctx,
+		timeout,
+		[3]string{"follow", "/Platform", "fail"},
+		[]lib.StateMutationDependency{{
+			NodeURL:  "/ParentId",
+			Requires: NewStateSpec(map[string]reflect.Value{"/Platform": reflect.ValueOf("on")}, map[string]reflect.Value{}),
+		}},
+	))
+	if e != nil {
+		t.Fatal(e)
+	}
+	// the SME starts frozen
+	k.Sme.Thaw()
+	return k
+}
+
+// follow sets a node's cfg to follow parent, and puts it in SYNC
+func follow(t *testing.T, k *Kraken, id, parent string) {
+	t.Helper()
+	n, e := k.Ctx.Query.Read(NewNodeID(id))
+	if e != nil {
+		t.Fatal(e)
+	}
+	n.SetValue("/ParentId", reflect.ValueOf(NewNodeID(parent).Binary()))
+	n.SetValue("/Platform", reflect.ValueOf("follow"))
+	n.SetValue("/RunState", reflect.ValueOf(pb.Node_SYNC))
+	if _, e = k.Ctx.Query.Update(n); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = k.Ctx.Query.SetValueDsc(lib.NodeURLJoin(id, "/RunState"), reflect.ValueOf(pb.Node_SYNC)); e != nil {
+		t.Fatal(e)
+	}
+}
+
+// createNode creates a node with /Platform set to platform in dsc
+func createNode(t *testing.T, k *Kraken, id, platform string) {
+	t.Helper()
+	if _, e := k.Ctx.Query.Create(NewNodeWithID(id)); e != nil {
+		t.Fatal(e)
+	}
+	setPlatform(t, k, id, platform)
+}
+
+func setPlatform(t *testing.T, k *Kraken, id, platform string) {
+	t.Helper()
+	if _, e := k.Ctx.Query.SetValueDsc(lib.NodeURLJoin(id, "/Platform"), reflect.ValueOf(platform)); e != nil {
+		t.Fatal(e)
+	}
+}
+
+// waitingOn waits for a node's path to be waiting on something that mentions on
+func waitingOn(t *testing.T, k *Kraken, id, on string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p, e := k.Ctx.Query.ReadNodeMutationPath(id)
+		if e == nil && strings.Contains(p.Waiting, on) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s isn't waiting on %s: %q %v", id, on, p.Waiting, e)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// lastRecord waits for a node's latest path record to satisfy done, and returns it
+func lastRecord(t *testing.T, k *Kraken, id string, done func(*pb.MutationPathRecord) bool) *pb.MutationPathRecord {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		h, e := k.Ctx.Query.ReadNodeMutationHistory(id)
+		if e == nil && len(h.Records) > 0 {
+			if r := h.Records[len(h.Records)-1]; done(r) {
+				return r
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s's path didn't get where we expected: %v %v", id, h.Records, e)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func fired(r *pb.MutationPathRecord) bool { return len(r.Steps) > 0 }
+func ended(r *pb.MutationPathRecord) bool { return r.Outcome != pb.MutationPathRecord_ACTIVE }
+
+// TestDepends tests that a mutation doesn't fire until the node it depends on is ready
+func TestDepends(t *testing.T) {
+	t.Run("resume", func(t *testing.T) {
+		k := dependsKraken(t, lib.StateMutationContext_SELF, 10*time.Second)
+		createNode(t, k, testOtherID, "")
+		follow(t, k, testSelfID, testOtherID)
+		waitingOn(t, k, testSelfID, testOtherID+" to reach")
+		// a change to something else about the node doesn't let it go
+		if _, e := k.Ctx.Query.SetValueDsc(lib.NodeURLJoin(testOtherID, "/Arch"), reflect.ValueOf("x86_64")); e != nil {
+			t.Fatal(e)
+		}
+		setPlatform(t, k, testOtherID, "off")
+		time.Sleep(100 * time.Millisecond)
+		if r := lastRecord(t, k, testSelfID, func(*pb.MutationPathRecord) bool { return true }); fired(r) {
+			t.Fatalf("the mutation fired before the node it depends on was ready: %v", r)
+		}
+		waitingOn(t, k, testSelfID, testOtherID+" to reach")
+
+		setPlatform(t, k, testOtherID, "on")
+		if r := lastRecord(t, k, testSelfID, fired); r.Steps[0].Module != "core" || r.Steps[0].Mutation != "follow" {
+			t.Errorf("unexpected step: %v", r.Steps[0])
+		}
+		if p, _ := k.Ctx.Query.ReadNodeMutationPath(testSelfID); p.Waiting != "" {
+			t.Errorf("still waiting after firing: %s", p.Waiting)
+		}
+	})
+
+	t.Run("unknown node", func(t *testing.T) {
+		k := dependsKraken(t, lib.StateMutationContext_SELF, 10*time.Second)
+		follow(t, k, testSelfID, testOtherID)
+		waitingOn(t, k, testSelfID, "node "+testOtherID+" (")
+		// it waits on the node being created, then on its state
+		if _, e := k.Ctx.Query.Create(NewNodeWithID(testOtherID)); e != nil {
+			t.Fatal(e)
+		}
+		waitingOn(t, k, testSelfID, testOtherID+" to reach")
+		setPlatform(t, k, testOtherID, "on")
+		lastRecord(t, k, testSelfID, fired)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		// each node waits on the other, until they time out
+		k := dependsKraken(t, lib.StateMutationContext_ALL, 300*time.Millisecond)
+		createNode(t, k, testOtherID, "")
+		follow(t, k, testOtherID, testSelfID)
+		follow(t, k, testSelfID, testOtherID)
+		for _, id := range []string{testSelfID, testOtherID} {
+			r := lastRecord(t, k, id, ended)
+			if r.Outcome != pb.MutationPathRecord_FAILED || !strings.Contains(r.Reason, "timed out waiting on node") || fired(r) {
+				t.Errorf("expected %s to time out waiting, without firing: %v", id, r)
+			}
+		}
+	})
+
+	t.Run("drain and stop", func(t *testing.T) {
+		k := dependsKraken(t, lib.StateMutationContext_SELF, 10*time.Second)
+		createNode(t, k, testOtherID, "")
+		follow(t, k, testSelfID, testOtherID)
+		waitingOn(t, k, testSelfID, testOtherID+" to reach")
+
+		k.Sme.Drain(100 * time.Millisecond)
+		r := lastRecord(t, k, testSelfID, ended)
+		if r.Outcome != pb.MutationPathRecord_INTERRUPTED || r.Reason != "kraken is shutting down" {
+			t.Errorf("expected the waiting path to be interrupted: %v", r)
+		}
+		setPlatform(t, k, testOtherID, "on")
+		time.Sleep(100 * time.Millisecond)
+		if r = lastRecord(t, k, testSelfID, ended); fired(r) {
+			t.Errorf("an interrupted path fired: %v", r)
+		}
+		start := time.Now()
+		k.Sme.Stop(time.Second)
+		if d := time.Since(start); d >= time.Second {
+			t.Errorf("stopping took %s", d)
+		}
+	})
+}
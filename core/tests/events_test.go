@@ -1,10 +1,17 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
 
 	. "github.com/hpc/kraken/core"
+	pb "github.com/hpc/kraken/core/proto"
 	"github.com/hpc/kraken/lib"
+	"google.golang.org/grpc"
 )
 
 // TestEventDispatchEngine tests that NewEventDispatchEngine initializes correctly
@@ -30,6 +37,362 @@ func TestEventDispatchEngine(t *testing.T) {
 }
 */
 
+// TestEventDispatchEngine_Queues tests ordered delivery and the overflow policies
+func TestEventDispatchEngine_Queues(t *testing.T) {
+	ctx := Context{}
+	ctx.EDE = ContextEDE{QueueSize: 4, Overflow: lib.EventOverflow_BLOCK, DrainTime: time.Second}
+	ede := NewEventDispatchEngine(ctx)
+	ready := make(chan interface{})
+	go ede.Run(ready)
+	<-ready
+
+	all := func(lib.Event) bool { return true }
+	got := make(chan lib.Event, 100)
+	ordered := NewEventListener("ordered", lib.Event_ALL, all,
+		func(ev lib.Event) error { return ChanSender(ev, got) })
+	ede.SubscriptionChan() <- ordered
+
+	// these never read, so their queues fill up
+	stuck := make(chan lib.Event)
+	drop := NewEventListener("drop", lib.Event_ALL, all,
+		func(ev lib.Event) error { return ChanSender(ev, stuck) })
+	drop.SetQueue(2, lib.EventOverflow_DROP_OLDEST)
+	ede.SubscriptionChan() <- drop
+	disc := NewEventListener("disconnect", lib.Event_ALL, all,
+		func(ev lib.Event) error { return ChanSender(ev, stuck) })
+	disc.SetQueue(1, lib.EventOverflow_DISCONNECT)
+	ede.SubscriptionChan() <- disc
+
+	n := 50
+	for i := 0; i < n; i++ {
+		ede.EventChan() <- []lib.Event{NewEvent(lib.Event_STATE_CHANGE, fmt.Sprintf("/%d", i), nil)}
+	}
+	for i := 0; i < n; i++ {
+		ev := <-got
+		if ev.URL() != fmt.Sprintf("/%d", i) {
+			t.Fatalf("event out of order: expected /%d, got %s", i, ev.URL())
+		}
+	}
+
+	// at most one event is stuck in Send and two are queued, the rest are dropped
+	drops := ede.Dropped()
+	if drops["drop"] < uint64(n-3) {
+		t.Errorf("expected at least %d drops for drop-oldest listener, got %d", n-3, drops["drop"])
+	}
+	if disc.State() != lib.EventListener_UNSUBSCRIBE {
+		t.Errorf("expected overflowing listener to be unsubscribed")
+	}
+	if drops["ordered"] != 0 {
+		t.Errorf("expected no drops for blocking listener, got %d", drops["ordered"])
+	}
+
+	// let the stuck listeners go so Stop can drain them
+	go func() {
+		for range stuck {
+		}
+	}()
+	ede.Stop()
+}
+
+// TestEventDispatchEngine_Stuck tests that a listener that stops taking events doesn't hold up other listeners or Stop
+func TestEventDispatchEngine_Stuck(t *testing.T) {
+	ctx := Context{}
+	ctx.EDE = ContextEDE{QueueSize: 1, Overflow: lib.EventOverflow_BLOCK, DrainTime: 100 * time.Millisecond, BlockTime: time.Hour}
+	ede := NewEventDispatchEngine(ctx)
+	ready := make(chan interface{})
+	go ede.Run(ready)
+	<-ready
+
+	all := func(lib.Event) bool { return true }
+	got := make(chan lib.Event, 100)
+	ok := NewEventListener("ok", lib.Event_ALL, all,
+		func(ev lib.Event) error { return ChanSender(ev, got) })
+	ok.SetQueue(100, lib.EventOverflow_BLOCK)
+	ede.SubscriptionChan() <- ok
+	stuck := make(chan lib.Event)
+	defer close(stuck)
+	blocked := NewEventListener("stuck", lib.Event_ALL, all,
+		func(ev lib.Event) error { <-stuck; return nil })
+	ede.SubscriptionChan() <- blocked
+
+	// one event is stuck in Send, one is queued, and dispatch waits on the third
+	n := 3
+	for i := 0; i < n; i++ {
+		ede.EventChan() <- []lib.Event{NewEvent(lib.Event_STATE_CHANGE, fmt.Sprintf("/%d", i), nil)}
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case ev := <-got:
+			if ev.URL() != fmt.Sprintf("/%d", i) {
+				t.Fatalf("event out of order: expected /%d, got %s", i, ev.URL())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("a stuck listener held up event /%d", i)
+		}
+	}
+
+	// we can still unsubscribe while dispatch waits on the stuck listener
+	blocked.SetState(lib.EventListener_UNSUBSCRIBE)
+	ede.SubscriptionChan() <- blocked
+	ede.EventChan() <- []lib.Event{NewEvent(lib.Event_STATE_CHANGE, "/after", nil)}
+	select {
+	case ev := <-got:
+		if ev.URL() != "/after" {
+			t.Errorf("expected /after, got %s", ev.URL())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("dispatch stalled after unsubscribing the stuck listener")
+	}
+
+	// Stop doesn't wait on a stuck listener for longer than the drain time
+	stuck2 := make(chan lib.Event)
+	defer close(stuck2)
+	blocked2 := NewEventListener("stuck2", lib.Event_ALL, all,
+		func(ev lib.Event) error { <-stuck2; return nil })
+	ede.SubscriptionChan() <- blocked2
+	for i := 0; i < n; i++ {
+		ede.EventChan() <- []lib.Event{NewEvent(lib.Event_STATE_CHANGE, fmt.Sprintf("/stuck2/%d", i), nil)}
+	}
+	stopped := make(chan interface{})
+	go func() {
+		ede.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop hung on a stuck listener")
+	}
+}
+
+// TestEventDispatchEngine_BlockTime tests that a listener that stays full for longer than the block time is disconnected
+func TestEventDispatchEngine_BlockTime(t *testing.T) {
+	ctx := Context{}
+	ctx.EDE = ContextEDE{QueueSize: 1, Overflow: lib.EventOverflow_BLOCK, DrainTime: 100 * time.Millisecond, BlockTime: 100 * time.Millisecond}
+	ede := NewEventDispatchEngine(ctx)
+	ready := make(chan interface{})
+	go ede.Run(ready)
+	<-ready
+
+	stuck := make(chan lib.Event)
+	defer close(stuck)
+	el := NewEventListener("stuck", lib.Event_ALL, func(lib.Event) bool { return true },
+		func(ev lib.Event) error { <-stuck; return nil })
+	ede.SubscriptionChan() <- el
+	for i := 0; i < 3; i++ {
+		ede.EventChan() <- []lib.Event{NewEvent(lib.Event_STATE_CHANGE, fmt.Sprintf("/%d", i), nil)}
+	}
+	// the next event can only be taken once the third is dealt with
+	ede.EventChan() <- []lib.Event{NewEvent(lib.Event_STATE_CHANGE, "/3", nil)}
+	if el.State() != lib.EventListener_UNSUBSCRIBE {
+		t.Error("expected a listener that stayed full past the block time to be disconnected")
+	}
+	ede.Stop()
+}
+
+// TestEventEmitter_Order tests that emitted events arrive in the order they were emitted
+func TestEventEmitter_Order(t *testing.T) {
+	em := NewEventEmitter(lib.Event_STATE_CHANGE)
+	c := make(chan []lib.Event)
+	em.Subscribe("test", c)
+	n := 100
+	for i := 0; i < n; i++ {
+		em.EmitOne(NewEvent(lib.Event_STATE_CHANGE, fmt.Sprintf("/%d", i), nil))
+	}
+	for i := 0; i < n; i++ {
+		v := <-c
+		if v[0].URL() != fmt.Sprintf("/%d", i) {
+			t.Fatalf("event out of order: expected /%d, got %s", i, v[0].URL())
+		}
+	}
+}
+
+// TestEventDispatchEngine_Journal tests that events are journaled with sequence numbers, and survive a restart
+func TestEventDispatchEngine_Journal(t *testing.T) {
+	dir, e := ioutil.TempDir("", "kraken-journal")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	ctx := Context{}
+	// small segments, so we rotate
+	ctx.EDE.Journal = ContextEventJournal{Dir: dir, SegmentSize: 256, Segments: 100}
+
+	start := func() *EventDispatchEngine {
+		ede := NewEventDispatchEngine(ctx)
+		ready := make(chan interface{})
+		go ede.Run(ready)
+		<-ready
+		return ede
+	}
+	discover := func(i int) lib.Event {
+		url := fmt.Sprintf("/node%d/PhysState", i)
+		return NewEvent(lib.Event_DISCOVERY, url, &DiscoveryEvent{ID: "test", URL: url, ValueID: "POWER_ON"})
+	}
+	replay := func(ede *EventDispatchEngine, from uint64) (urls []string) {
+		if e := ede.Replay(from, func(ev lib.Event) bool {
+			if ev.Seq() != from+uint64(len(urls)) {
+				t.Errorf("replay out of sequence: expected %d, got %d", from+uint64(len(urls)), ev.Seq())
+			}
+			urls = append(urls, ev.URL())
+			return true
+		}); e != nil {
+			t.Fatal(e)
+		}
+		return
+	}
+
+	got := make(chan lib.Event, 100)
+	el := NewEventListener("seq", lib.Event_ALL, func(lib.Event) bool { return true },
+		func(ev lib.Event) error { return ChanSender(ev, got) })
+	ede := start()
+	ede.SubscriptionChan() <- el
+	for i := 0; i < 20; i++ {
+		ede.EventChan() <- []lib.Event{discover(i)}
+	}
+	for i := 0; i < 20; i++ {
+		if ev := <-got; ev.Seq() != uint64(i+1) {
+			t.Errorf("expected sequence number %d, got %d", i+1, ev.Seq())
+		}
+	}
+	ede.Stop()
+
+	// a new engine picks up where we left off
+	ede = start()
+	ede.SubscriptionChan() <- el
+	ede.EventChan() <- []lib.Event{discover(20)}
+	if ev := <-got; ev.Seq() != 21 {
+		t.Errorf("expected sequence number 21 after restart, got %d", ev.Seq())
+	}
+	urls := replay(ede, 15)
+	if len(urls) != 7 || urls[0] != "/node14/PhysState" || urls[6] != "/node20/PhysState" {
+		t.Errorf("unexpected replay from 15: %v", urls)
+	}
+	ede.Stop()
+
+	// a torn write at the end of the journal is discarded
+	fs, _ := ioutil.ReadDir(dir)
+	last := dir + "/" + fs[len(fs)-1].Name()
+	f, _ := os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write([]byte{0x20, 0x01, 0x02})
+	f.Close()
+	ede = start()
+	if urls := replay(ede, 1); len(urls) != 21 {
+		t.Errorf("expected 21 events after torn write, got %d", len(urls))
+	}
+	ede.SubscriptionChan() <- el
+	ede.EventChan() <- []lib.Event{discover(21)}
+	if ev := <-got; ev.Seq() != 22 {
+		t.Errorf("expected sequence number 22 after torn write, got %d", ev.Seq())
+	}
+	ede.Stop()
+}
+
+// watchStream is an API_WatchServer that hands events to the test
+type watchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *pb.EventControl
+}
+
+func (w *watchStream) Context() context.Context { return w.ctx }
+func (w *watchStream) Send(ec *pb.EventControl) error {
+	select {
+	case w.sent <- ec:
+		return nil
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+}
+
+// TestAPIServer_WatchReplay tests that a Watch with replay gets every event once, in order,
+// including the ones dispatched while it replays, and that a slow watcher doesn't hold up other listeners
+func TestAPIServer_WatchReplay(t *testing.T) {
+	dir, e := ioutil.TempDir("", "kraken-journal")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	ctx := Context{}
+	ctx.EDE = ContextEDE{QueueSize: 16, Overflow: lib.EventOverflow_BLOCK, DrainTime: 100 * time.Millisecond, BlockTime: time.Hour}
+	ctx.EDE.Journal = ContextEventJournal{Dir: dir, SegmentSize: 64 * 1024, Segments: 8}
+	ede := NewEventDispatchEngine(ctx)
+	ready := make(chan interface{})
+	go ede.Run(ready)
+	<-ready
+	defer ede.Stop()
+	ctx.Ede = ede
+	ctx.SubChan = ede.SubscriptionChan()
+	api := NewAPIServer(ctx)
+
+	// only events with data can be journaled
+	dispatch := func(i int) {
+		url := fmt.Sprintf("/node%d/PhysState", i)
+		ede.EventChan() <- []lib.Event{NewEvent(lib.Event_DISCOVERY, url, &DiscoveryEvent{ID: "test", URL: url, ValueID: "POWER_ON"})}
+	}
+	got := make(chan lib.Event, 100)
+	ok := NewEventListener("ok", lib.Event_ALL, func(lib.Event) bool { return true },
+		func(ev lib.Event) error { return ChanSender(ev, got) })
+	ok.SetQueue(100, lib.EventOverflow_BLOCK)
+	ede.SubscriptionChan() <- ok
+	n := 5
+	for i := 0; i < n; i++ {
+		dispatch(i)
+	}
+	for i := 0; i < n; i++ {
+		<-got
+	}
+
+	wctx, cancel := context.WithCancel(context.Background())
+	w := &watchStream{ctx: wctx, sent: make(chan *pb.EventControl)}
+	done := make(chan interface{})
+	go func() {
+		api.Watch(&pb.WatchRequest{Id: "test", From: 1}, w)
+		close(done)
+	}()
+
+	// the watcher is stuck sending its first event, but dispatch goes on
+	first := <-w.sent
+	m := 20
+	for i := n; i < n+m; i++ {
+		dispatch(i)
+	}
+	for i := n; i < n+m; i++ {
+		select {
+		case <-got:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("a replaying watcher held up event /%d", i)
+		}
+	}
+
+	seqs := []uint64{first.GetSeq()}
+	for len(seqs) < n+m {
+		select {
+		case ec := <-w.sent:
+			seqs = append(seqs, ec.GetSeq())
+		case <-time.After(5 * time.Second):
+			t.Fatalf("watcher only got %d of %d events: %v", len(seqs), n+m, seqs)
+		}
+	}
+	for i, seq := range seqs {
+		if seq != uint64(i+1) {
+			t.Fatalf("expected events 1-%d in order, got: %v", n+m, seqs)
+		}
+	}
+	select {
+	case ec := <-w.sent:
+		t.Errorf("got an extra event: %d", ec.GetSeq())
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch didn't return after its stream was closed")
+	}
+}
+
 // ExampleFilterSimple shows how to use a filter generator
 func ExampleFilterSimple() {
 	list := []string{
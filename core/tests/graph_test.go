@@ -0,0 +1,215 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	. "github.com/hpc/kraken/core"
+	pb "github.com/hpc/kraken/core/proto"
+	"github.com/hpc/kraken/lib"
+)
+
+func newTestSME() *StateMutationEngine {
+	ctx := Context{}
+	ctx.SME.RootSpec = DefaultRootSpec()
+	return NewStateMutationEngine(ctx, make(chan lib.Query))
+}
+
+// fixtureMutsN builds n mutations: a power on/off/discover cycle for n/3 distinct architectures
+func fixtureMutsN(n int) (muts []lib.StateMutation) {
+	steps := [][2]pb.Node_PhysState{
+		{pb.Node_PHYS_UNKNOWN, pb.Node_POWER_OFF},
+		{pb.Node_POWER_OFF, pb.Node_POWER_ON},
+		{pb.Node_POWER_ON, pb.Node_POWER_OFF},
+	}
+	for i := 0; i < n; i++ {
+		s := steps[i%len(steps)]
+		muts = append(muts, NewStateMutation(
+			map[string][2]reflect.Value{
+				"/PhysState": {reflect.ValueOf(s[0]), reflect.ValueOf(s[1])},
+			},
+			map[string]reflect.Value{
+				"/Arch": reflect.ValueOf(fmt.Sprintf("arch%d", i/len(steps))),
+			},
+			map[string]reflect.Value{},
+			lib.StateMutationContext_CHILD,
+			time.Second*10,
+			[3]string{"", "", ""},
+		))
+	}
+	return
+}
+
+// novelMut references a URL no other fixture mutation does, so it forces a full graph rebuild
+func novelMut() lib.StateMutation {
+	return NewStateMutation(
+		map[string][2]reflect.Value{
+			"/PhysState": {reflect.ValueOf(pb.Node_POWER_ON), reflect.ValueOf(pb.Node_PHYS_HANG)},
+		},
+		map[string]reflect.Value{
+			"/Nodename": reflect.ValueOf("novel"),
+		},
+		map[string]reflect.Value{},
+		lib.StateMutationContext_CHILD,
+		time.Second*10,
+		[3]string{"", "", ""},
+	)
+}
+
+func graphSignature(sme *StateMutationEngine) (sig []string) {
+	nodes := map[string]lib.Node{}
+	for _, n := range fixtureNodes() {
+		nodes[n.node.Nodename] = NewNodeFromMessage(&n.node)
+	}
+	return nodesSignature(sme, nodes)
+}
+
+// nodesSignature describes a graph by how many of its nodes each of nodes matches, and which of them it can get between
+func nodesSignature(sme *StateMutationEngine, nodes map[string]lib.Node) (sig []string) {
+	names := []string{}
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, a := range names {
+		sig = append(sig, fmt.Sprintf("%s matches %d", a, sme.NodeMatch(nodes[a])))
+	}
+	for _, a := range names {
+		for _, b := range names {
+			ok, _ := sme.PathExists(nodes[a], nodes[b])
+			sig = append(sig, fmt.Sprintf("%s -> %s: %v", a, b, ok))
+		}
+	}
+	return
+}
+
+func TestStateMutationEngine_IncrementalGraph(t *testing.T) {
+	// full: forced full rebuild with the fixture mutations
+	full := newTestSME()
+	for i, m := range fixtureMuts() {
+		full.RegisterMutation("test", fmt.Sprintf("mut%d", i), m)
+	}
+	full.RegisterMutation("test", "novel", novelMut())
+	full.UnregisterMutation("test", "novel")
+	want := graphSignature(full)
+
+	// inc: extend the graph one mutation at a time, then add & prune an extra mutation
+	inc := newTestSME()
+	for i, m := range fixtureMuts() {
+		inc.RegisterMutation("test", fmt.Sprintf("mut%d", i), m)
+	}
+	if e := inc.RegisterMutation("test", "mut0", fixtureMuts()[0]); e == nil {
+		t.Errorf("duplicate mutation registration should fail")
+	}
+	inc.RegisterMutation("test", "extra", fixtureMutsN(6)[4])
+	if e := inc.UnregisterMutation("test", "extra"); e != nil {
+		t.Errorf("failed to unregister mutation: %v", e)
+	}
+	if e := inc.UnregisterMutation("test", "extra"); e == nil {
+		t.Errorf("unregistering an unknown mutation should fail")
+	}
+	got := graphSignature(inc)
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("incremental graph differs from full rebuild:\nwant: %v\ngot:  %v", want, got)
+	}
+}
+
+// TestStateMutationEngine_GraphMergedEdges tests that when two ways of getting to the same state get merged,
+// both stay in the graph, and that building the graph incrementally gets the same graph as a full rebuild.
+// Which one the graph builds first depends on map order, so it tries a few times.
+func TestStateMutationEngine_GraphMergedEdges(t *testing.T) {
+	mut := func(to string) lib.StateMutation {
+		return NewStateMutation(
+			map[string][2]reflect.Value{"/Platform": {reflect.ValueOf(""), reflect.ValueOf(to)}},
+			map[string]reflect.Value{"/Services/blinky/State": reflect.ValueOf(pb.ServiceInstance_RUN)},
+			map[string]reflect.Value{},
+			lib.StateMutationContext_SELF,
+			time.Second*10,
+			[3]string{"", "", ""},
+		)
+	}
+	node := func(platform string) lib.Node {
+		n := NewNodeWithID("123e4567-e89b-12d3-a456-426655440000")
+		n.SetValue("/PhysState", reflect.ValueOf(pb.Node_POWER_ON))
+		n.SetValue("/RunState", reflect.ValueOf(pb.Node_SYNC))
+		n.SetValue("/Platform", reflect.ValueOf(platform))
+		n.AddService(&pb.ServiceInstance{Id: "blinky", Module: "blinky", State: pb.ServiceInstance_RUN})
+		return n
+	}
+	// the start of a path is what's discovered, so /Platform has to be discoverable
+	si := NewServiceInstance("blinky", "blinky", nil)
+	Registry.RegisterDiscoverable(si, map[string]map[string]reflect.Value{
+		"/Platform": {"on": reflect.ValueOf("on"), "fail": reflect.ValueOf("fail"), "flaky": reflect.ValueOf("flaky")},
+	})
+	nodes := map[string]lib.Node{}
+	for _, p := range []string{"", "on", "fail", "flaky"} {
+		nodes["sync "+p] = node(p)
+		n := node(p)
+		n.SetValue("/RunState", reflect.ValueOf(pb.Node_INIT))
+		nodes["init "+p] = n
+	}
+	newSME := func() *StateMutationEngine {
+		ctx := Context{}
+		ctx.SME.RootSpec = NewStateSpec(map[string]reflect.Value{
+			"/PhysState": reflect.ValueOf(pb.Node_POWER_ON),
+			"/RunState":  reflect.ValueOf(pb.Node_SYNC),
+		}, map[string]reflect.Value{})
+		sme := NewStateMutationEngine(ctx, make(chan lib.Query))
+		sme.RegisterMutation("sync", "sync", NewStateMutation(
+			map[string][2]reflect.Value{"/RunState": {reflect.ValueOf(pb.Node_INIT), reflect.ValueOf(pb.Node_SYNC)}},
+			map[string]reflect.Value{},
+			map[string]reflect.Value{},
+			lib.StateMutationContext_SELF,
+			time.Second*10,
+			[3]string{"", "", ""},
+		))
+		for _, to := range []string{"on", "fail", "flaky"} {
+			sme.RegisterMutation("blinky", to, mut(to))
+		}
+		return sme
+	}
+	for i := 0; i < 20; i++ {
+		// inc: the blinky mutations extend the graph one at a time
+		inc := newSME()
+		for _, to := range []string{"on", "fail", "flaky"} {
+			if ok, e := inc.PathExists(node(""), node(to)); !ok {
+				t.Fatalf("no path to /Platform %s: %v", to, e)
+			}
+		}
+		// full: a novel mutation forces a full rebuild when it's registered, and again when it's unregistered
+		full := newSME()
+		full.RegisterMutation("test", "novel", novelMut())
+		full.UnregisterMutation("test", "novel")
+		if want, got := nodesSignature(full, nodes), nodesSignature(inc, nodes); !reflect.DeepEqual(want, got) {
+			t.Fatalf("incremental graph differs from full rebuild:\nwant: %v\ngot:  %v", want, got)
+		}
+	}
+}
+
+func benchmarkGraphUpdate(b *testing.B, n int, mut lib.StateMutation) {
+	sme := newTestSME()
+	for i, m := range fixtureMutsN(n) {
+		sme.RegisterMutation("bench", fmt.Sprintf("mut%d", i), m)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sme.RegisterMutation("bench", "update", mut)
+		sme.UnregisterMutation("bench", "update")
+	}
+}
+
+// BenchmarkGraphRebuild* measure registering and unregistering a mutation that forces a full rebuild
+func BenchmarkGraphRebuild100(b *testing.B) { benchmarkGraphUpdate(b, 100, novelMut()) }
+func BenchmarkGraphRebuild300(b *testing.B) { benchmarkGraphUpdate(b, 300, novelMut()) }
+func BenchmarkGraphRebuild600(b *testing.B) { benchmarkGraphUpdate(b, 600, novelMut()) }
+
+// BenchmarkGraphIncremental* measure registering and unregistering a mutation that can be applied incrementally.
+// Only stage1 is incremental; the rest of the graph is still rebuilt in full each time (see finishGraph),
+// so these don't get cheaper than that.
+func BenchmarkGraphIncremental100(b *testing.B) { benchmarkGraphUpdate(b, 100, fixtureMutsN(3)[1]) }
+func BenchmarkGraphIncremental300(b *testing.B) { benchmarkGraphUpdate(b, 300, fixtureMutsN(3)[1]) }
+func BenchmarkGraphIncremental600(b *testing.B) { benchmarkGraphUpdate(b, 600, fixtureMutsN(3)[1]) }
@@ -0,0 +1,175 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/hpc/kraken/core"
+	pb "github.com/hpc/kraken/core/proto"
+	"github.com/hpc/kraken/lib"
+)
+
+func TestLintMutations(t *testing.T) {
+	muts := map[string]map[string]lib.StateMutation{"test": {}}
+	for i, m := range fixtureMuts() {
+		muts["test"][fmt.Sprintf("mut%d", i)] = m
+	}
+	// requires and excludes the same thing, so it can never run
+	muts["test"]["conflict"] = NewStateMutation(
+		map[string][2]reflect.Value{
+			"/RunState": {
+				reflect.ValueOf(pb.Node_INIT),
+				reflect.ValueOf(pb.Node_SYNC),
+			},
+		},
+		map[string]reflect.Value{
+			"/Arch": reflect.ValueOf("IPMI"),
+		},
+		map[string]reflect.Value{
+			"/Arch": reflect.ValueOf("IPMI"),
+		},
+		lib.StateMutationContext_SELF,
+		time.Second*10,
+		[3]string{"test", "/RunState", "ERROR"},
+	)
+	log := &WriterLogger{}
+	log.RegisterWriter(os.Stderr)
+	log.SetLoggerLevel(lib.LLCRITICAL)
+
+	found := map[MutationLintType]int{}
+	for _, i := range LintMutations(DefaultRootSpec(), muts, nil, log) {
+		t.Log(i.String())
+		found[i.Type]++
+	}
+	// fixture mutations don't set a FailTo, and the "test" module registers no discoverables
+	if found[MutationLint_FAILTO] != len(muts["test"]) {
+		t.Errorf("expected %d FAILTO issues, got %d", len(muts["test"]), found[MutationLint_FAILTO])
+	}
+	if found[MutationLint_CONFLICT] != 1 {
+		t.Errorf("expected 1 CONFLICT issue, got %d", found[MutationLint_CONFLICT])
+	}
+	if found[MutationLint_UNUSED] != 1 {
+		t.Errorf("expected 1 UNUSED issue, got %d", found[MutationLint_UNUSED])
+	}
+	if found[MutationLint_DEADEND] != 0 || found[MutationLint_UNREACHABLE] != 0 {
+		t.Errorf("fixture graph should have no dead ends or unreachable states")
+	}
+}
+
+// TestLintMutations_Uncomparable tests that values == can't compare are checked without a panic
+func TestLintMutations_Uncomparable(t *testing.T) {
+	muts := map[string]map[string]lib.StateMutation{"test": {
+		"tags": NewStateMutation(
+			map[string][2]reflect.Value{
+				"/Platform": {reflect.ValueOf(""), reflect.ValueOf("tagged")},
+			},
+			map[string]reflect.Value{
+				"/Tags": reflect.ValueOf([]string{"a", "b"}),
+			},
+			map[string]reflect.Value{
+				"/Tags": reflect.ValueOf([]string{"a", "b"}),
+			},
+			lib.StateMutationContext_SELF,
+			time.Second*10,
+			[3]string{"test", "/Platform", "fail"},
+		),
+	}}
+	log := &WriterLogger{}
+	log.RegisterWriter(os.Stderr)
+	log.SetLoggerLevel(lib.LLCRITICAL)
+
+	found := map[MutationLintType]int{}
+	for _, i := range LintMutations(NewStateSpec(map[string]reflect.Value{"/Platform": reflect.ValueOf("")}, map[string]reflect.Value{}), muts, nil, log) {
+		t.Log(i.String())
+		found[i.Type]++
+	}
+	// it requires and excludes equal slices, and slices can't go in the graph
+	if found[MutationLint_CONFLICT] != 2 {
+		t.Errorf("expected 2 CONFLICT issues, got %d", found[MutationLint_CONFLICT])
+	}
+}
+
+// platformMut mutates /Platform from one value to another
+func platformMut(from, to string) lib.StateMutation {
+	return NewStateMutation(
+		map[string][2]reflect.Value{
+			"/Platform": {reflect.ValueOf(from), reflect.ValueOf(to)},
+		},
+		map[string]reflect.Value{},
+		map[string]reflect.Value{},
+		lib.StateMutationContext_SELF,
+		time.Second*10,
+		[3]string{"test", "/Platform", "fail"},
+	)
+}
+
+// TestLintMutations_Graph tests the findings that come from the shape of the mutation graph
+func TestLintMutations_Graph(t *testing.T) {
+	tests := []struct {
+		name    string
+		muts    map[string][2]string // mutid -> from, to
+		found   map[MutationLintType]int
+		message string // one of the issues says this
+	}{
+		{
+			name:  "healthy",
+			muts:  map[string][2]string{"on": {"", "on"}, "off": {"on", ""}},
+			found: map[MutationLintType]int{},
+		},
+		{
+			name:    "two ways on",
+			muts:    map[string][2]string{"on": {"", "on"}, "on2": {"", "on"}, "off": {"on", ""}},
+			found:   map[MutationLintType]int{MutationLint_AMBIGUOUS: 1},
+			message: "2 equal cost paths (cost 1) from {} to {Platform: on}",
+		},
+		{
+			// a single way to end up isn't a dead end, it's where everything goes
+			name:  "one way to go",
+			muts:  map[string][2]string{"on": {"", "on"}},
+			found: map[MutationLintType]int{},
+		},
+		{
+			name:    "a fork with no way back",
+			muts:    map[string][2]string{"left": {"", "left"}, "right": {"", "right"}},
+			found:   map[MutationLintType]int{MutationLint_DEADEND: 2},
+			message: "1 state(s) have no way out: {Platform: left}",
+		},
+		{
+			// the graph is built backwards from mutations that lead to a state, too, so "broken" is in it
+			name:    "a way back from somewhere root can't get to",
+			muts:    map[string][2]string{"on": {"", "on"}, "off": {"on", ""}, "fix": {"broken", ""}},
+			found:   map[MutationLintType]int{MutationLint_UNREACHABLE: 1},
+			message: "state {Platform: broken} can not be reached",
+		},
+	}
+	root := NewStateSpec(map[string]reflect.Value{"/Platform": reflect.ValueOf("")}, map[string]reflect.Value{})
+	dscs := map[string]map[string]map[string]reflect.Value{"test": {"/Platform": {"fail": reflect.ValueOf("fail")}}}
+	log := &WriterLogger{}
+	log.RegisterWriter(os.Stderr)
+	log.SetLoggerLevel(lib.LLCRITICAL)
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			muts := map[string]map[string]lib.StateMutation{"test": {}}
+			for id, m := range v.muts {
+				muts["test"][id] = platformMut(m[0], m[1])
+			}
+			found := map[MutationLintType]int{}
+			said := v.message == ""
+			for _, i := range LintMutations(root, muts, dscs, log) {
+				t.Log(i.String())
+				found[i.Type]++
+				said = said || strings.Contains(i.Message, v.message)
+			}
+			if !reflect.DeepEqual(found, v.found) {
+				t.Errorf("expected %v, got %v", v.found, found)
+			}
+			if !said {
+				t.Errorf("no issue says %q", v.message)
+			}
+		})
+	}
+}
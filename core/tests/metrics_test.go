@@ -0,0 +1,45 @@
+package core
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	. "github.com/hpc/kraken/core"
+)
+
+// TestWriteMetrics tests that metrics are written in the Prometheus text format
+func TestWriteMetrics(t *testing.T) {
+	fams := []MetricFamily{
+		{
+			Name: "test_gauge",
+			Help: "A test gauge.\nWith two lines.",
+			Type: MetricGauge,
+			Metrics: []Metric{
+				{Value: 1.5},
+				{Labels: []MetricLabel{{Name: "a", Value: "x"}, {Name: "b", Value: `say "hi"`}}, Value: math.Inf(1)},
+			},
+		},
+		{
+			Name:    "test_total",
+			Help:    "A test counter.",
+			Type:    MetricCounter,
+			Metrics: []Metric{{Suffix: "_extra", Value: 3}},
+		},
+	}
+	expect := `# HELP test_gauge A test gauge.\nWith two lines.
+# TYPE test_gauge gauge
+test_gauge 1.5
+test_gauge{a="x",b="say \"hi\""} +Inf
+# HELP test_total A test counter.
+# TYPE test_total counter
+test_total_extra 3
+`
+	b := &bytes.Buffer{}
+	if e := WriteMetrics(b, fams); e != nil {
+		t.Fatalf("failed to write metrics: %v", e)
+	}
+	if b.String() != expect {
+		t.Errorf("unexpected metrics output:\n%s\nexpected:\n%s", b.String(), expect)
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+
+	. "github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/lib"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/hpc/kraken/core/proto"
+)
+
+// TestAPIServer_QueryUpdateIf tests that a conditional update only happens if the node hasn't changed
+func TestAPIServer_QueryUpdateIf(t *testing.T) {
+	k := authzKraken(t, &AuthzPolicy{Roles: DefaultAuthzRoles()}, nil)
+	api := NewAPIClient("unix:" + k.Ctx.RPC.Path)
+	id := k.Ctx.Self.String()
+	n, e := api.QueryRead(id)
+	if e != nil {
+		t.Fatal(e)
+	}
+	version := NodeVersion(n)
+	n.SetValue("/Arch", reflect.ValueOf("x86_64"))
+	if _, e = api.QueryUpdateIf(n, version); e != nil {
+		t.Fatalf("update at the current version failed: %v", e)
+	}
+	n.SetValue("/Arch", reflect.ValueOf("aarch64"))
+	if _, e = api.QueryUpdateIf(n, version); status.Code(e) != codes.Aborted {
+		t.Fatalf("expected Aborted for an update at an old version, got: %v", e)
+	}
+	n, _ = api.QueryRead(id)
+	if v, _ := n.GetValue("/Arch"); v.String() != "x86_64" {
+		t.Errorf("a failed conditional update changed the node: %v", v)
+	}
+	if NodeVersion(n) == version {
+		t.Error("an update didn't change the node's version")
+	}
+}
+
+// TestAPIServer_QuerySetValue tests setting single values, which are sent as JSON
+func TestAPIServer_QuerySetValue(t *testing.T) {
+	k := authzKraken(t, &AuthzPolicy{Roles: DefaultAuthzRoles()}, nil)
+	api := NewAPIClient("unix:" + k.Ctx.RPC.Path)
+	id := k.Ctx.Self.String()
+	tests := []struct {
+		name string
+		url  string
+		v    interface{}
+		bad  bool
+	}{
+		{"string", "/Arch", "x86_64", false},
+		{"enum", "/PhysState", pb.Node_POWER_ON, false},
+		{"wrong type", "/PhysState", "NOT_A_STATE", true},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			rv, e := api.QuerySetValue(lib.NodeURLJoin(id, v.url), reflect.ValueOf(v.v))
+			if v.bad {
+				if status.Code(e) != codes.InvalidArgument {
+					t.Fatalf("expected InvalidArgument for a value of the wrong type, got: %v", e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatal(e)
+			}
+			if !reflect.DeepEqual(rv.Interface(), v.v) {
+				t.Errorf("expected %v to be set, got: %v", v.v, rv.Interface())
+			}
+			n, _ := api.QueryRead(id)
+			if got, _ := n.GetValue(v.url); !reflect.DeepEqual(got.Interface(), v.v) {
+				t.Errorf("expected %v to be read back, got: %v", v.v, got.Interface())
+			}
+		})
+	}
+}
+
+// TestAPIServer_ValidateConfig tests that modules get to reject service configs, however they're set
+func TestAPIServer_ValidateConfig(t *testing.T) {
+	k, api, s := smKraken(t, "validate", nil)
+	id := k.Ctx.Self.String()
+	cfg := func(v string) *any.Any {
+		a, _ := ptypes.MarshalAny(&wrappers.StringValue{Value: v})
+		return a
+	}
+	if _, e := api.ServiceConfigure(s.id, &wrappers.StringValue{Value: "hello"}); e != nil {
+		t.Fatal(e)
+	}
+	_, e := api.ServiceConfigure(s.id, &wrappers.StringValue{Value: "bad"})
+	if status.Code(e) != codes.InvalidArgument || !strings.Contains(e.Error(), "invalid config for service validate: bad is not a greeting") {
+		t.Errorf("expected the service config to be rejected, got: %v", e)
+	}
+
+	tests := []struct {
+		name   string
+		create bool
+		module string // the module the node says the service is; empty leaves it alone
+		cfg    string
+		valid  bool
+	}{
+		{"create", true, "", "hi", true},
+		{"create invalid", true, "", "bad", false},
+		{"update", false, "", "hi", true},
+		{"update invalid", false, "", "bad", false},
+		{"update invalid, claiming another module", false, "notvalidate", "bad", false},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			var n lib.Node
+			if v.create {
+				n = NewNodeWithID("123e4567-e89b-12d3-a456-426655440001")
+			} else {
+				n, _ = api.QueryRead(id)
+			}
+			srv := n.GetService(s.id)
+			srv.Config = cfg(v.cfg)
+			if v.module != "" {
+				srv.Module = v.module
+			}
+			var e error
+			if v.create {
+				_, e = api.QueryCreate(n)
+			} else {
+				_, e = api.QueryUpdate(n)
+			}
+			if v.valid && e != nil {
+				t.Fatalf("a valid config was rejected: %v", e)
+			}
+			if !v.valid && (status.Code(e) != codes.InvalidArgument || !strings.Contains(e.Error(), "bad is not a greeting")) {
+				t.Fatalf("expected an invalid config to be rejected, got: %v", e)
+			}
+			if !v.valid && !v.create {
+				cur, _ := api.QueryRead(id)
+				if !proto.Equal(cur.GetService(s.id).GetConfig(), cfg("hi")) {
+					t.Errorf("an invalid config changed the config: %v", cur.GetService(s.id).GetConfig())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/lib"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/hpc/kraken/core/proto"
+)
+
+// testCA is a throwaway CA that issues certificates for a test
+type testCA struct {
+	dir  string
+	file string // the CA's certificate
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	n    int64
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	ca := &testCA{dir: t.TempDir()}
+	ca.cert = &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "kraken test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	ca.file, _ = ca.issue(t, ca.cert, nil)
+	return ca
+}
+
+// issue writes a certificate (and its key) that the CA signed; it's self-signed if parent is nil
+func (ca *testCA) issue(t *testing.T, tpl *x509.Certificate, parent *x509.Certificate) (cert, key string) {
+	t.Helper()
+	k, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+	ca.n++
+	tpl.SerialNumber = big.NewInt(ca.n)
+	tpl.NotBefore = time.Now().Add(-time.Hour)
+	tpl.NotAfter = time.Now().Add(time.Hour)
+	signer := k
+	if parent == nil {
+		parent, ca.key = tpl, k
+	} else {
+		signer = ca.key
+	}
+	der, e := x509.CreateCertificate(rand.Reader, tpl, parent, &k.PublicKey, signer)
+	if e != nil {
+		t.Fatal(e)
+	}
+	kder, e := x509.MarshalECPrivateKey(k)
+	if e != nil {
+		t.Fatal(e)
+	}
+	name := strings.Replace(tpl.Subject.CommonName, " ", "_", -1)
+	cert, key = filepath.Join(ca.dir, name+".pem"), filepath.Join(ca.dir, name+"-key.pem")
+	ioutil.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	ioutil.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}), 0600)
+	return
+}
+
+// server issues a certificate for a server on localhost
+func (ca *testCA) server(t *testing.T) (cert, key string) {
+	return ca.issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca.cert)
+}
+
+// client issues a client certificate for cn
+func (ca *testCA) client(t *testing.T, cn string) (cert, key string) {
+	return ca.issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: cn},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca.cert)
+}
+
+// TestAPIServer_Remote tests that remote calls are made on behalf of the client certificate's CN, whatever principal the client sends,
+// and that remote clients can't make the calls that are for modules
+func TestAPIServer_Remote(t *testing.T) {
+	ca := newTestCA(t)
+	scert, skey := ca.server(t)
+	policy := &AuthzPolicy{
+		Roles:    DefaultAuthzRoles(),
+		Bindings: map[string][]string{"alice": {"observer"}, "root-ish": {"admin"}},
+	}
+	b, e := json.Marshal(policy)
+	if e != nil {
+		t.Fatal(e)
+	}
+	pfile := filepath.Join(t.TempDir(), "policy.json")
+	if e = ioutil.WriteFile(pfile, b, 0600); e != nil {
+		t.Fatal(e)
+	}
+	k := testKraken(t, func(k *Kraken) {
+		k.Ctx.Authz.PolicyFile = pfile
+		k.Ctx.RPC.API = ContextRPCAPI{
+			Addr:              "127.0.0.1:0",
+			CertFile:          scert,
+			KeyFile:           skey,
+			ClientCAFile:      ca.file,
+			RequireClientCert: true,
+		}
+	})
+	addr := k.Ctx.RPC.API.Listener.Addr().String()
+	ccert, ckey := ca.client(t, "alice")
+	tc, e := lib.NewClientTLSConfig(ca.file, ccert, ckey, "localhost")
+	if e != nil {
+		t.Fatal(e)
+	}
+	alice := NewAPIClientWithTLS(addr, tc)
+
+	if _, e := alice.QueryReadAll(); e != nil {
+		t.Errorf("alice can't read: %v", e)
+	}
+	tests := []struct {
+		name string
+		api  lib.APIClient
+	}{
+		{"certificate", alice},
+		{"certificate, and a principal in metadata", alice.WithPrincipal("root-ish")},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			e := v.api.QueryFreeze()
+			if status.Code(e) != codes.PermissionDenied || !strings.HasPrefix(status.Convert(e).Message(), "alice ") {
+				t.Errorf("expected freeze to be denied to alice, got: %v", e)
+			}
+		})
+	}
+
+	t.Run("no certificate", func(t *testing.T) {
+		tc, e := lib.NewClientTLSConfig(ca.file, "", "", "localhost")
+		if e != nil {
+			t.Fatal(e)
+		}
+		if _, e := NewAPIClientWithTLS(addr, tc).QueryReadAll(); e == nil {
+			t.Errorf("a client without a certificate could read")
+		}
+	})
+
+	t.Run("module calls", func(t *testing.T) {
+		// denied before authorization gets a say
+		moduleOnly := func(e error) bool {
+			return status.Code(e) == codes.PermissionDenied && strings.HasSuffix(status.Convert(e).Message(), "is only available to modules")
+		}
+		if e := alice.ServiceHeartbeat("test", time.Second); !moduleOnly(e) {
+			t.Errorf("expected a heartbeat to be denied, got: %v", e)
+		}
+		if _, e := alice.QueryUpdateDsc(NewNodeWithID("123e4567-e89b-12d3-a456-426655440000")); !moduleOnly(e) {
+			t.Errorf("expected a discovered state update to be denied, got: %v", e)
+		}
+		conn, e := grpc.Dial(addr, grpc.WithTransportCredentials(credentials.NewTLS(tc)))
+		if e != nil {
+			t.Fatal(e)
+		}
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s, e := pb.NewAPIClient(conn).EventInit(ctx, &pb.ServiceInitRequest{Id: "test"})
+		if e == nil {
+			_, e = s.Recv()
+		}
+		if !moduleOnly(e) {
+			t.Errorf("expected a module stream to be denied, got: %v", e)
+		}
+	})
+}
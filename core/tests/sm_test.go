@@ -0,0 +1,400 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+
+	. "github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/lib"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	pb "github.com/hpc/kraken/core/proto"
+)
+
+// smKraken boots a kraken that manages a fake service named name, which no other test may use
+func smKraken(t *testing.T, name string, setup func(*Kraken)) (*Kraken, *APIClient, *fakeService) {
+	s := &fakeService{id: name}
+	Registry.RegisterModule(&fakeModule{name: name})
+	Registry.RegisterServiceInstance(&fakeModule{name: name}, map[string]lib.ServiceInstance{name: s})
+	k := testKraken(t, setup)
+	return k, NewAPIClient("unix:" + k.Ctx.RPC.Path), s
+}
+
+// waitService waits for a service to be dsc after it has been started starts times
+func waitService(t *testing.T, api *APIClient, s *fakeService, dsc string, starts int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		st, _ := api.ServiceGet(s.id)
+		if st.GetDsc().String() == dsc && s.count() == starts {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	st, _ := api.ServiceGet(s.id)
+	t.Fatalf("expected the service to be %s after %d starts, but it's %s after %d", dsc, starts, st.GetDsc(), s.count())
+}
+
+// TestServiceManager_Lifecycle tests starting, configuring, restarting and stopping a service
+func TestServiceManager_Lifecycle(t *testing.T) {
+	_, api, s := smKraken(t, "lifecycle", nil)
+	list, e := api.ServiceList()
+	if e != nil {
+		t.Fatal(e)
+	}
+	found := false
+	for _, st := range list {
+		if st.GetId() == s.id {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("the service isn't listed: %v", list)
+	}
+
+	st, e := api.ServiceStart(s.id)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if st.GetCfg().String() != "RUN" {
+		t.Errorf("start didn't set cfg to RUN: %v", st)
+	}
+	waitService(t, api, s, "INIT", 1)
+
+	if st, e = api.ServiceConfigure(s.id, &wrappers.StringValue{Value: "hello"}); e != nil {
+		t.Fatal(e)
+	}
+	if !strings.Contains(st.GetConfig().String(), "hello") {
+		t.Errorf("config wasn't set: %v", st)
+	}
+	if s.updated() != 1 {
+		t.Errorf("the running service wasn't told to update its config")
+	}
+
+	if _, e = api.ServiceRestart(s.id); e != nil {
+		t.Fatal(e)
+	}
+	waitService(t, api, s, "INIT", 2)
+
+	if st, e = api.ServiceStop(s.id); e != nil {
+		t.Fatal(e)
+	}
+	if st.GetCfg().String() != "STOP" {
+		t.Errorf("stop didn't set cfg to STOP: %v", st)
+	}
+	waitService(t, api, s, "STOP", 2)
+
+	if _, e = api.ServiceStart("nosuchservice"); e == nil {
+		t.Errorf("unknown service didn't fail")
+	}
+}
+
+// TestServiceManager_Restart tests that crashed services are restarted until their restart policy gives up
+func TestServiceManager_Restart(t *testing.T) {
+	k, api, s := smKraken(t, "restart", nil)
+	if _, e := api.ServiceStart(s.id); e != nil {
+		t.Fatal(e)
+	}
+	waitService(t, api, s, "INIT", 1)
+	restarts := func() uint32 {
+		n, _ := k.Ctx.Query.ReadDsc(k.Ctx.Self)
+		return n.GetService(s.id).GetRestarts()
+	}
+	// without a policy, it isn't restarted
+	s.crash()
+	waitService(t, api, s, "ERROR", 1)
+	if r := restarts(); r != 0 {
+		t.Errorf("a service without a restart policy was restarted")
+	}
+
+	k.Ctx.Query.SetValue(lib.NodeURLJoin(k.Ctx.Self.String(), "/Services/restart/RestartPolicy"), reflect.ValueOf(&pb.RestartPolicy{
+		Mode:        pb.RestartPolicy_ON_FAILURE,
+		MaxRestarts: 2,
+		Backoff:     ptypes.DurationProto(10 * time.Millisecond),
+	}))
+	if _, e := api.ServiceStart(s.id); e != nil {
+		t.Fatal(e)
+	}
+	waitService(t, api, s, "INIT", 2)
+	s.crash()
+	waitService(t, api, s, "INIT", 3)
+	s.crash()
+	waitService(t, api, s, "INIT", 4)
+	s.crash()
+	waitService(t, api, s, "ERROR", 4)
+	if st, _ := api.ServiceGet(s.id); !strings.Contains(st.GetErrorMsg(), "crash loop") {
+		t.Errorf("expected a crash loop error, got: %q", st.GetErrorMsg())
+	}
+	if r := restarts(); r != 2 {
+		t.Errorf("expected 2 restarts, got %d", r)
+	}
+	if _, e := api.ServiceStart(s.id); e != nil {
+		t.Fatal(e)
+	}
+	waitService(t, api, s, "INIT", 5)
+	if r := restarts(); r != 0 {
+		t.Errorf("starting by hand didn't reset restarts: %d", r)
+	}
+}
+
+// TestServiceManager_Heartbeat tests that a service that stops sending heartbeats is killed, then restarted
+func TestServiceManager_Heartbeat(t *testing.T) {
+	k, api, s := smKraken(t, "heartbeat", nil)
+	// the rest of the policy is the default's
+	k.Ctx.Query.SetValue(lib.NodeURLJoin(k.Ctx.Self.String(), "/Services/heartbeat/RestartPolicy"), reflect.ValueOf(&pb.RestartPolicy{
+		Mode:    pb.RestartPolicy_ON_FAILURE,
+		Backoff: ptypes.DurationProto(10 * time.Millisecond),
+	}))
+	if _, e := api.ServiceStart(s.id); e != nil {
+		t.Fatal(e)
+	}
+	waitService(t, api, s, "INIT", 1)
+	if e := api.ServiceHeartbeat(s.id, 20*time.Millisecond); e != nil {
+		t.Fatalf("heartbeat failed: %v", e)
+	}
+	if st, _ := api.ServiceGet(s.id); st.GetLastHeartbeat() == nil {
+		t.Errorf("status doesn't show the heartbeat")
+	}
+	waitService(t, api, s, "INIT", 2)
+	if st, _ := api.ServiceGet(s.id); !strings.Contains(st.GetErrorMsg(), "hung") {
+		t.Errorf("expected a hung error, got: %q", st.GetErrorMsg())
+	}
+	if e := api.ServiceHeartbeat("nosuchservice", time.Second); e == nil {
+		t.Errorf("heartbeat from an unknown service didn't fail")
+	}
+}
+
+// TestServiceManager_Cgroup tests that resource policies are applied to a service's cgroup, even while it runs
+func TestServiceManager_Cgroup(t *testing.T) {
+	// a directory that looks enough like a cgroup v2 directory
+	cgroot := t.TempDir()
+	ioutil.WriteFile(filepath.Join(cgroot, "cgroup.controllers"), []byte("cpu memory pids\n"), 0644)
+	os.Mkdir(filepath.Join(cgroot, "cgroup"), 0755)
+	for _, f := range []string{"cpu.weight", "cpu.max", "memory.max", "pids.max"} {
+		ioutil.WriteFile(filepath.Join(cgroot, "cgroup", f), nil, 0644)
+	}
+	k, api, s := smKraken(t, "cgroup", func(k *Kraken) { k.Ctx.SM.CgroupRoot = cgroot })
+	cgfile := func(f string) string {
+		b, _ := ioutil.ReadFile(filepath.Join(cgroot, "cgroup", f))
+		return string(b)
+	}
+	if _, e := api.ServiceStart(s.id); e != nil {
+		t.Fatal(e)
+	}
+	waitService(t, api, s, "INIT", 1)
+	if cgfile("memory.max") != "max" || cgfile("cpu.max") != "max 100000" {
+		t.Errorf("the service's cgroup wasn't set up without limits: %q, %q", cgfile("memory.max"), cgfile("cpu.max"))
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(cgroot, "cgroup.subtree_control")); string(b) != "+cpu +memory +pids" {
+		t.Errorf("controllers weren't enabled: %q", b)
+	}
+	k.Ctx.Query.SetValue(lib.NodeURLJoin(k.Ctx.Self.String(), "/Services/cgroup/Resources"), reflect.ValueOf(&pb.ResourcePolicy{
+		CpuQuota:  50,
+		MemoryMax: 1 << 20,
+	}))
+	deadline := time.Now().Add(5 * time.Second)
+	for cgfile("memory.max") != "1048576" && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if cgfile("memory.max") != "1048576" || cgfile("cpu.max") != "50000 100000" {
+		t.Errorf("the resource policy wasn't applied: %q, %q", cgfile("memory.max"), cgfile("cpu.max"))
+	}
+	if n, _ := k.Ctx.Query.ReadDsc(k.Ctx.Self); n.GetService(s.id).GetCgroup() != filepath.Join(cgroot, "cgroup") || n.GetService(s.id).GetResources().GetMemoryMax() != 1<<20 {
+		t.Errorf("dsc doesn't report the cgroup and limits: %v", n.GetService(s.id))
+	}
+}
+
+// TestServiceManager_Plugin tests that plugins register their config type, mutations and discoverables as they call in
+func TestServiceManager_Plugin(t *testing.T) {
+	plugins := t.TempDir()
+	ioutil.WriteFile(filepath.Join(plugins, "plugin"), []byte("#!/bin/sh\nsleep 60\n"), 0755)
+	k, api, _ := smKraken(t, "notplugin", func(k *Kraken) {
+		k.Ctx.SM.PluginDir = plugins
+		// the plugin's streams are still open when we shut down
+		k.Ctx.RPC.DrainTime = 100 * time.Millisecond
+	})
+	if _, e := api.ServiceGet("plugin"); e != nil {
+		t.Fatalf("plugin service wasn't added: %v", e)
+	}
+	reg := &pb.PluginRegistration{
+		Files: []*descriptorpb.FileDescriptorProto{{
+			Name:    proto.String("acme.proto"),
+			Package: proto.String("acme"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("PluginConfig"),
+				Field: []*descriptorpb.FieldDescriptorProto{{
+					Name:     proto.String("greeting"),
+					JsonName: proto.String("greeting"),
+					Number:   proto.Int32(1),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				}},
+			}},
+		}},
+		ConfigType: "acme.PluginConfig",
+		Mutations: map[string]*pb.PluginMutation{
+			"setplatform": {
+				Mutates:  map[string]*pb.PluginMutation_Change{"/Platform": {From: `""`, To: `"acme"`}},
+				Requires: map[string]string{"/PhysState": `"POWER_ON"`},
+			},
+		},
+		Discoverables: map[string]*pb.PluginDiscoverable{"/Platform": {Values: map[string]string{"acme": `"acme"`}}},
+	}
+	if _, e := api.PluginInit("notplugin", "notplugin", reg); e == nil {
+		t.Errorf("a built-in service registered as a plugin")
+	}
+	bad := proto.Clone(reg).(*pb.PluginRegistration)
+	bad.Mutations["setplatform"].Mutates["/NoSuchURL"] = &pb.PluginMutation_Change{From: "1", To: "2"}
+	if _, e := api.PluginInit("plugin", "plugin", bad); e == nil {
+		t.Errorf("a plugin registered a mutation of a bad URL")
+	}
+	if _, e := api.PluginInit("plugin", "plugin", reg); e != nil {
+		t.Fatalf("plugin registration failed: %v", e)
+	}
+
+	st, _ := api.ServiceGet("plugin")
+	cfg, e := UnmarshalConfigJSON(st.GetModule(), []byte(`{"greeting": "hi"}`))
+	if e != nil {
+		t.Fatalf("the plugin's config type wasn't registered: %v", e)
+	}
+	if st, e = api.ServiceConfigure("plugin", cfg); e != nil {
+		t.Fatal(e)
+	}
+	if b, _ := MarshalJSON(st); !strings.Contains(string(b), "acme.PluginConfig") || !strings.Contains(string(b), `"hi"`) {
+		t.Errorf("plugin config wasn't set: %s", b)
+	}
+
+	dc, e := api.DiscoveryInit("plugin")
+	if e != nil {
+		t.Fatalf("plugin discovery stream failed: %v", e)
+	}
+	purl := lib.NodeURLJoin(k.Ctx.Self.String(), "/Platform")
+	dc <- NewEvent(lib.Event_DISCOVERY, purl, &DiscoveryEvent{URL: purl, ValueID: "acme"})
+	platform := func() string {
+		n, _ := k.Ctx.Query.ReadDsc(k.Ctx.Self)
+		return n.Message().(*pb.Node).GetPlatform()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for platform() != "acme" && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if platform() != "acme" {
+		t.Errorf("plugin discovery wasn't applied")
+	}
+
+	// Go plugins describe themselves from the same things built-in modules register, and may register again
+	greg, e := NewPluginRegistration(&wrappers.StringValue{}, map[string]lib.StateMutation{
+		"setplatform": NewStateMutation(
+			map[string][2]reflect.Value{"/Platform": {reflect.ValueOf(""), reflect.ValueOf("acme")}},
+			map[string]reflect.Value{"/PhysState": reflect.ValueOf(pb.Node_POWER_ON)},
+			map[string]reflect.Value{}, lib.StateMutationContext_SELF, time.Second, [3]string{"plugin", "/Platform", "acme"}),
+	}, map[string]map[string]reflect.Value{"/Platform": {"acme": reflect.ValueOf("acme")}})
+	if e != nil {
+		t.Fatalf("couldn't describe a Go plugin: %v", e)
+	}
+	if greg.GetConfigType() != "google.protobuf.StringValue" || greg.GetMutations()["setplatform"].GetRequires()["/PhysState"] != `"POWER_ON"` {
+		t.Errorf("bad Go plugin registration: %v", greg)
+	}
+	if _, e := api.PluginInit("plugin", "plugin", greg); e != nil {
+		t.Errorf("plugin couldn't register again: %v", e)
+	}
+}
+
+// fakeModule takes a string as its config
+type fakeModule struct {
+	name string
+}
+
+func (m *fakeModule) Name() string                       { return m.name }
+func (*fakeModule) NewConfig() proto.Message             { return &wrappers.StringValue{} }
+func (*fakeModule) UpdateConfig(cfg proto.Message) error { return nil }
+func (*fakeModule) ValidateConfig(cfg proto.Message) error {
+	if v := cfg.(*wrappers.StringValue).GetValue(); v == "bad" {
+		return fmt.Errorf("%s is not a greeting", v)
+	}
+	return nil
+}
+func (m *fakeModule) ConfigURL() string {
+	a, _ := ptypes.MarshalAny(m.NewConfig())
+	return a.GetTypeUrl()
+}
+
+// fakeService doesn't run anything; it just says it does, and counts how often it is started and told to update
+type fakeService struct {
+	id      string
+	mutex   sync.Mutex
+	state   lib.ServiceState
+	wchan   chan<- lib.ServiceInstanceUpdate
+	starts  int
+	updates int
+}
+
+func (s *fakeService) ID() string     { return s.id }
+func (s *fakeService) Module() string { return s.id }
+
+func (s *fakeService) GetState() lib.ServiceState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state
+}
+
+func (s *fakeService) UpdateConfig() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.updates++
+}
+
+func (s *fakeService) Start() {
+	s.mutex.Lock()
+	s.starts++
+	s.mutex.Unlock()
+	s.setState(lib.Service_RUN)
+}
+
+func (s *fakeService) Stop() { s.setState(lib.Service_STOP) }
+func (s *fakeService) Kill() { s.crash() }
+
+func (s *fakeService) Watch(w chan<- lib.ServiceInstanceUpdate) { s.wchan = w }
+func (*fakeService) SetCtl(chan<- lib.ServiceControl)           {}
+func (*fakeService) SetSock(string)                             {}
+func (*fakeService) SetCgroup(string)                           {}
+
+func (s *fakeService) setState(state lib.ServiceState) {
+	s.mutex.Lock()
+	s.state = state
+	s.mutex.Unlock()
+	s.wchan <- lib.ServiceInstanceUpdate{ID: s.id, State: state}
+}
+
+// crash makes the service fail as if its process died
+func (s *fakeService) crash() {
+	s.mutex.Lock()
+	s.state = lib.Service_ERROR
+	s.mutex.Unlock()
+	s.wchan <- lib.ServiceInstanceUpdate{ID: s.id, State: lib.Service_ERROR, Error: errors.New("crashed")}
+}
+
+func (s *fakeService) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.starts
+}
+
+func (s *fakeService) updated() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.updates
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/hpc/kraken/core"
+)
+
+// memSpanExporter keeps exported spans in memory
+type memSpanExporter struct {
+	mutex *sync.Mutex
+	spans []*Span
+}
+
+func (m *memSpanExporter) Export(spans []*Span) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.spans = append(m.spans, spans...)
+	return nil
+}
+
+func (m *memSpanExporter) Close() error { return nil }
+
+// TestTracer tests that spans propagate trace context and get exported
+func TestTracer(t *testing.T) {
+	t.Run("nil tracer", func(t *testing.T) {
+		var tr *Tracer
+		s := tr.Start("nothing", "")
+		s.SetAttr("a", "b")
+		s.End()
+		if s.Traceparent() != "" {
+			t.Errorf("nil span has a traceparent: %s", s.Traceparent())
+		}
+		tr.Close()
+	})
+	t.Run("parse traceparent", func(t *testing.T) {
+		tid, sid, e := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		if e != nil || tid != "4bf92f3577b34da6a3ce929d0e0e4736" || sid != "00f067aa0ba902b7" {
+			t.Errorf("bad parse: %s %s %v", tid, sid, e)
+		}
+		for _, bad := range []string{
+			"",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+		} {
+			if _, _, e := ParseTraceparent(bad); e == nil {
+				t.Errorf("parsed bad traceparent: %s", bad)
+			}
+		}
+	})
+	t.Run("export", func(t *testing.T) {
+		log := &WriterLogger{}
+		log.RegisterWriter(os.Stderr)
+		mem := &memSpanExporter{mutex: &sync.Mutex{}}
+		tr := NewTracerWithExporters("test", log, mem)
+		root := tr.Start("root", "")
+		child := tr.Start("child", root.Traceparent())
+		child.SetError("failed")
+		child.End()
+		child.End()
+		time.Sleep(10 * time.Millisecond)
+		root.End()
+		tr.Close()
+		if len(mem.spans) != 2 {
+			t.Fatalf("expected 2 spans, got %d", len(mem.spans))
+		}
+		c, r := mem.spans[0], mem.spans[1]
+		if c.TraceID != r.TraceID || c.ParentID != r.SpanID || r.ParentID != "" {
+			t.Errorf("child is not in the root's trace: %+v %+v", c, r)
+		}
+		if c.Error != "failed" || c.Service != "test" {
+			t.Errorf("child span lost data: %+v", c)
+		}
+		if !r.Ended.After(r.Started) {
+			t.Errorf("root span has bad times: %v %v", r.Started, r.Ended)
+		}
+	})
+	t.Run("end after close", func(t *testing.T) {
+		mem := &memSpanExporter{mutex: &sync.Mutex{}}
+		tr := NewTracerWithExporters("test", &WriterLogger{}, mem)
+		before := tr.Start("before", "")
+		late := tr.Start("late", "")
+		before.End()
+		// spans can end while we close, and after
+		ended := make(chan interface{})
+		go func() {
+			for i := 0; i < 100; i++ {
+				tr.Start("racing", "").End()
+			}
+			close(ended)
+		}()
+		tr.Close()
+		tr.Close()
+		late.End()
+		<-ended
+		mem.mutex.Lock()
+		defer mem.mutex.Unlock()
+		if len(mem.spans) == 0 || mem.spans[0].Name != "before" {
+			t.Errorf("span that ended before Close wasn't exported")
+		}
+		for _, s := range mem.spans {
+			if s.Name == "late" {
+				t.Errorf("span that ended after Close was exported")
+			}
+		}
+	})
+}
@@ -1,7 +1,6 @@
 module github.com/hpc/kraken
 
 require (
-	github.com/coreos/go-systemd v0.0.0-20191104093116-d3cd4ed1dbcf // indirect
 	github.com/golang/protobuf v1.4.3
 	github.com/google/gopacket v1.1.19
 	github.com/gorilla/handlers v1.5.1
@@ -16,4 +15,11 @@ require (
 	gopkg.in/yaml.v2 v2.3.0
 )
 
-go 1.15
+require (
+	github.com/felixge/httpsnoop v1.0.1 // indirect
+	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f // indirect
+	golang.org/x/text v0.3.3 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
+)
+
+go 1.20
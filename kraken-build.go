@@ -40,6 +40,9 @@ var (
 	verbose   = flag.Bool("v", false, "verbose will print extra information about the build process")
 	race      = flag.Bool("race", false, "build with -race, warning: enables CGO")
 	pprof     = flag.Bool("pprof", false, "build with pprof support")
+	lint      = flag.Bool("lint", false, "lint the mutation graph of the configured modules instead of building")
+	lintRoot  = flag.String("lintroot", "", "JSON node state to use as the mutation graph root when linting")
+	ctl       = flag.Bool("ctl", false, "also build krakenctl, with the configured modules and extensions, for each build target")
 )
 
 // config
@@ -352,7 +355,8 @@ func buildUrootKraken(config *Config, outDir, krakenDir string) (targets []strin
 }
 
 // buildMainKraken builds a kraken binary for a non-u-root build target.
-func buildMainKraken(dir string, fromTemplates []string, tName string, t Target, verbose bool) (e error) {
+// The binary is named name-tName, e.g. kraken-linux-amd64.
+func buildMainKraken(dir string, fromTemplates []string, name, tName string, t Target, verbose bool) (e error) {
 	// setup log file
 	var f *os.File
 	if verbose {
@@ -390,7 +394,7 @@ func buildMainKraken(dir string, fromTemplates []string, tName string, t Target,
 	cmd.Stderr = f
 	e = cmd.Run()
 
-	path := filepath.Join(*buildDir, "kraken-"+tName)
+	path := filepath.Join(*buildDir, name+"-"+tName)
 	if _, err := os.Stat(path); !os.IsNotExist(err) {
 		if *force {
 			log.Printf("force was specified, overwriting old build: %s", path)
@@ -451,6 +455,73 @@ func readConfig(cfgFile string) (cfg *Config, e error) {
 	return
 }
 
+// lintKraken compiles a mutation graph linter with the modules and extensions
+// from the Config struct and runs it on the build host.
+func lintKraken(cfg *Config, krakenDir, tmpDir string) (e error) {
+	if len(cfg.Modules) == 0 {
+		return fmt.Errorf("no modules to lint in config: %s", *cfgFile)
+	}
+	os.Mkdir(tmpDir, 0755)
+
+	var targets []string
+	for _, tpl := range []string{
+		filepath.Join(krakenDir, "kraken", "includes.go.tpl"),
+		filepath.Join(krakenDir, "kraken", "lint", "main.go.tpl"),
+	} {
+		var target string
+		if target, e = compileTemplate(tpl, tmpDir); e != nil {
+			e = fmt.Errorf("could not compile template %s: %v", tpl, e)
+			return
+		}
+		targets = append(targets, target)
+	}
+
+	args := append([]string{"run"}, targets...)
+	if *lintRoot != "" {
+		var root string
+		if root, e = filepath.Abs(*lintRoot); e != nil {
+			return
+		}
+		args = append(args, "-root", root)
+	}
+	if *verbose {
+		args = append(args, "-log", "5")
+	}
+	cmd := exec.Command("go", args...)
+	if *verbose {
+		log.Printf("Run: %s", strings.Join(cmd.Args, " "))
+	}
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if e = cmd.Run(); e != nil {
+		e = fmt.Errorf("mutation graph lint failed: %v", e)
+	}
+	return
+}
+
+// buildCtl builds krakenctl for a non-u-root build target, with the modules and
+// extensions from the Config struct so it can read everything this kraken can.
+func buildCtl(cfg *Config, krakenDir, tmpDir, tName string) (e error) {
+	dir := filepath.Join(tmpDir, "ctl")
+	os.Mkdir(dir, 0755)
+
+	var targets []string
+	for _, tpl := range []string{
+		filepath.Join(krakenDir, "kraken", "includes.go.tpl"),
+		filepath.Join(krakenDir, "kraken", "ctl", "main.go.tpl"),
+	} {
+		var target string
+		if target, e = compileTemplate(tpl, dir); e != nil {
+			return fmt.Errorf("could not compile template %s: %v", tpl, e)
+		}
+		targets = append(targets, target)
+	}
+	log.Printf("building: krakenctl for %s", tName)
+	return buildMainKraken(dir, targets, "krakenctl", tName, cfg.Targets[tName], *verbose)
+}
+
 // krakenBuild is the wrapper function that builds kraken. It reads
 // the Config struct, compiles the necessary templates, and
 // builds kraken for the specified build targets.
@@ -507,10 +578,15 @@ func krakenBuild(cfg *Config, krakenDir, tmpDir string) (e error) {
 		for t := range cfg.Targets {
 			if t != "u-root" {
 				log.Printf("building: %s (GOOS: %s, GOARCH; %s)", t, cfg.Targets[t].Os, cfg.Targets[t].Arch)
-				if e = buildMainKraken(tmpDir, fromTemplates, t, cfg.Targets[t], *verbose); e != nil {
+				if e = buildMainKraken(tmpDir, fromTemplates, "kraken", t, cfg.Targets[t], *verbose); e != nil {
 					log.Printf("failed to build %s: %v", t, e)
 					continue
 				}
+				if *ctl {
+					if e = buildCtl(cfg, krakenDir, tmpDir, t); e != nil {
+						log.Printf("failed to build krakenctl for %s: %v", t, e)
+					}
+				}
 			}
 		}
 	}
@@ -536,6 +612,17 @@ func main() {
 	// Specify where temp directory should be for compiled templates
 	tmpDir := filepath.Join(krakenDir, "tmp") // make an option to change where this is?
 
+	if *lint {
+		e = lintKraken(cfg, krakenDir, tmpDir)
+		if !*noCleanup {
+			os.RemoveAll(tmpDir)
+		}
+		if e != nil {
+			log.Fatal(e)
+		}
+		return
+	}
+
 	// build
 	if e = krakenBuild(cfg, krakenDir, tmpDir); e != nil {
 		log.Fatalf("failed to build: %v", e)
@@ -0,0 +1,20 @@
+/* main.go: krakenctl, a command-line client for kraken, with the modules and extensions specified in a build config
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package main
+
+import (
+	"os"
+
+	"github.com/hpc/kraken/core/ctl"
+)
+
+func main() {
+	os.Exit(ctl.Main(os.Args[1:]))
+}
@@ -0,0 +1,78 @@
+/* main.go: mutation graph linter for the modules specified in a build config
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+
+	"github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/lib"
+)
+
+func main() {
+	root := flag.String("root", "", "JSON node state to use as the graph root (default: core.DefaultRootSpec())")
+	llevel := flag.Int("log", 2, "set the log level (0-9)")
+	flag.Parse()
+
+	log := &core.WriterLogger{}
+	log.RegisterWriter(os.Stderr)
+	log.SetModule("lint")
+	log.SetLoggerLevel(lib.LoggerLevel(*llevel))
+
+	spec := core.DefaultRootSpec()
+	if *root != "" {
+		b, e := ioutil.ReadFile(*root)
+		if e != nil {
+			log.Logf(lib.LLCRITICAL, "could not read root state: %v", e)
+			os.Exit(2)
+		}
+		n := core.NewNodeFromJSON(b)
+		if n == nil {
+			log.Logf(lib.LLCRITICAL, "could not parse root state: %s", *root)
+			os.Exit(2)
+		}
+		// the root spec is made of the non-zero values for every URL a mutation references
+		reqs := map[string]reflect.Value{}
+		for _, ms := range core.Registry.Mutations {
+			for _, m := range ms {
+				urls := []string{}
+				for u := range m.Mutates() {
+					urls = append(urls, u)
+				}
+				for u := range m.Requires() {
+					urls = append(urls, u)
+				}
+				for u := range m.Excludes() {
+					urls = append(urls, u)
+				}
+				for _, u := range urls {
+					if v, e := n.GetValue(u); e == nil && !v.IsZero() {
+						reqs[u] = v
+					}
+				}
+			}
+		}
+		spec = core.NewStateSpec(reqs, map[string]reflect.Value{})
+	}
+
+	issues := core.LintMutations(spec, core.Registry.Mutations, core.Registry.Discoverables, log)
+	for _, i := range issues {
+		fmt.Println(i.String())
+	}
+	if len(issues) > 0 {
+		fmt.Printf("%d issue(s) found\n", len(issues))
+		os.Exit(1)
+	}
+	fmt.Println("no issues found")
+}
@@ -14,7 +14,9 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"os/signal"
 	"reflect"
+	"syscall"
 
 	"github.com/coreos/go-systemd/daemon"
 	"github.com/golang/protobuf/ptypes"
@@ -38,6 +40,18 @@ func main() {
 	llevel := flag.Int("log", 3, "set the log level (0-9)")
 	sdnotify := flag.Bool("sdnotify", false, "notify systemd when kraken is initialized")
 	journald := flag.Bool("journald", false, "assuming we are logging through journald, disable log prefixes")
+	cgroup := flag.String("cgroup", "", "cgroup v2 directory to run modules in, so their resource policies can be applied, e.g. /sys/fs/cgroup/kraken/services (default: no resource limits)")
+	plugins := flag.String("plugins", "", "directory of plugin executables (out-of-tree modules) to run as services (default: no plugins)")
+	journal := flag.String("journal", "", "directory to keep a journal of events in, so clients can replay them (default: no journal)")
+	traceFile := flag.String("trace-file", "", "file to append trace spans to as JSON lines (default: no file)")
+	traceOTLP := flag.String("trace-otlp", "", "OTLP/HTTP collector to send trace spans to, e.g. http://localhost:4318 (default: no collector)")
+	authzPolicy := flag.String("authz-policy", "", "JSON policy of roles API callers are allowed (default: allow everything)")
+	apiSocketGroup := flag.String("api-socket-group", "", "group whose members may also use the API socket; their calls are made on behalf of their user name (default: only kraken's user)")
+	apiAddr := flag.String("api-addr", "", "host:port to serve the API to remote clients on, over TLS (default: only serve it to local modules)")
+	apiCert := flag.String("api-cert", "", "PEM certificate to serve the remote API with")
+	apiKey := flag.String("api-key", "", "PEM key for -api-cert")
+	apiClientCA := flag.String("api-client-ca", "", "PEM CA certificates to verify remote API client certificates with")
+	apiRequireClientCert := flag.Bool("api-require-client-cert", false, "reject remote API clients without a valid client certificate")
 	flag.Parse()
 
 	// Create a new logger interface
@@ -59,6 +73,8 @@ func main() {
 	log.Logf(lib.LLNOTICE, "I am: %s", id)
 
 	if id != "kraken" {
+		// systemd (or ^C) signals all of kraken's processes, but kraken stops its modules itself, in order
+		signal.Ignore(syscall.SIGTERM, syscall.SIGINT)
 		module := os.Getenv("KRAKEN_MODULE")
 		sock := os.Getenv("KRAKEN_SOCK")
 		if m, ok := core.Registry.Modules[module]; ok {
@@ -156,6 +172,23 @@ func main() {
 
 	// Launch Kraken
 	k := core.NewKraken(self, parents, log)
+	k.Ctx.EDE.Journal.Dir = *journal
+	k.Ctx.SM.CgroupRoot = *cgroup
+	k.Ctx.SM.PluginDir = *plugins
+	k.Ctx.Trace.File = *traceFile
+	k.Ctx.Trace.OTLP = *traceOTLP
+	k.Ctx.Authz.PolicyFile = *authzPolicy
+	k.Ctx.RPC.API = core.ContextRPCAPI{
+		Addr:              *apiAddr,
+		CertFile:          *apiCert,
+		KeyFile:           *apiKey,
+		ClientCAFile:      *apiClientCA,
+		RequireClientCert: *apiRequireClientCert,
+	}
+	if *apiSocketGroup != "" {
+		k.Ctx.RPC.SocketGroup = *apiSocketGroup
+		k.Ctx.RPC.SocketMode = 0660
+	}
 	k.Release()
 
 	// Thaw if full state
@@ -175,8 +208,21 @@ func main() {
 		}
 	}
 
-	// wait forever
-	for {
-		select {}
+	// run until we're told to stop
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
+	s := <-sig
+	log.Logf(lib.LLNOTICE, "got %v, shutting down", s)
+	if *sdnotify {
+		if _, err := daemon.SdNotify(false, daemon.SdNotifyStopping); err != nil {
+			log.Logf(lib.LLERROR, "failed to send sd_notify: %v", err)
+		}
 	}
+	go func() {
+		// a second signal means we shouldn't wait
+		s := <-sig
+		log.Logf(lib.LLCRITICAL, "got %v while shutting down, exiting now", s)
+		os.Exit(1)
+	}()
+	k.Shutdown()
 }
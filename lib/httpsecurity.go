@@ -0,0 +1,181 @@
+/* httpsecurity.go: TLS, authentication and origin checks for the HTTP services modules provide
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package lib
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// HTTPSecurityConfig describes how an HTTP service should be secured.  The zero value is an open, plain HTTP service.
+type HTTPSecurityConfig struct {
+	CertFile          string   // PEM certificate to serve TLS with; needs KeyFile
+	KeyFile           string   // PEM key for CertFile
+	ClientCAFile      string   // PEM CA certificates; clients with certificates they signed are authenticated (needs TLS)
+	RequireClientCert bool     // reject TLS connections without a valid client certificate
+	TokenFile         string   // bearer tokens, one per line as "<token> [<name>]"; requests with one of them are authenticated (needs TLS)
+	AllowedOrigins    []string // origins that may make cross-origin (browser) requests; "*" allows any
+}
+
+// HTTPSecurity secures an HTTP service as described by an HTTPSecurityConfig.
+// If either client certificates or tokens are configured, every request must be authenticated with one of them.
+type HTTPSecurity struct {
+	tlsConfig *tls.Config
+	tokens    map[string]string // token -> name
+	certAuth  bool
+	origins   map[string]bool
+	anyOrigin bool
+}
+
+type httpPrincipalKey struct{}
+
+// NewHTTPSecurity loads the certificates and tokens in cfg
+func NewHTTPSecurity(cfg HTTPSecurityConfig) (s *HTTPSecurity, e error) {
+	s = &HTTPSecurity{
+		origins: make(map[string]bool),
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if s.tlsConfig, e = NewServerTLSConfig(cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile, cfg.RequireClientCert); e != nil {
+			return nil, e
+		}
+		s.certAuth = cfg.ClientCAFile != ""
+	} else if cfg.ClientCAFile != "" || cfg.RequireClientCert {
+		return nil, fmt.Errorf("client certificates need TLS")
+	}
+	if cfg.TokenFile != "" {
+		// tokens sent in the clear are as good as published
+		if s.tlsConfig == nil {
+			return nil, fmt.Errorf("bearer tokens need TLS")
+		}
+		if s.tokens, e = readTokenFile(cfg.TokenFile); e != nil {
+			return nil, e
+		}
+	}
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" {
+			s.anyOrigin = true
+		}
+		s.origins[o] = true
+	}
+	return
+}
+
+// TLSConfig is the TLS config to serve with, or nil if we don't serve TLS
+func (s *HTTPSecurity) TLSConfig() *tls.Config { return s.tlsConfig }
+
+// Scheme is "https" if we serve TLS, "http" if we don't
+func (s *HTTPSecurity) Scheme() string {
+	if s.tlsConfig != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// AllowedOrigin decides if a browser at origin may make requests
+func (s *HTTPSecurity) AllowedOrigin(origin string) bool {
+	return s.anyOrigin || s.origins[origin]
+}
+
+// CheckOrigin decides if a request's origin is allowed.
+// Requests without an Origin header (i.e. not from a browser) and same-origin requests are always allowed.
+// It has the signature of websocket.Upgrader.CheckOrigin.
+func (s *HTTPSecurity) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || s.AllowedOrigin(origin) {
+		return true
+	}
+	u, e := url.Parse(origin)
+	return e == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// Authenticate checks the credentials of a request, and returns the name of who made it.
+// ok is true if the request is authenticated, or if no authentication is configured.
+// Tokens can be given in an "Authorization: Bearer <token>" header, or (e.g. for browser websockets) an access_token query parameter.
+func (s *HTTPSecurity) Authenticate(r *http.Request) (name string, ok bool) {
+	if !s.certAuth && s.tokens == nil {
+		return "", true
+	}
+	if s.certAuth && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return TLSPeerName(r.TLS), true
+	}
+	token := r.URL.Query().Get("access_token")
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		token = strings.TrimPrefix(h, "Bearer ")
+	}
+	if token == "" {
+		return "", false
+	}
+	for t, n := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return n, true
+		}
+	}
+	return "", false
+}
+
+// Handler wraps an HTTP handler with origin and authentication checks.
+// Who made an authenticated request is available to the handler with HTTPPrincipal.
+// CORS preflight requests should be answered before this, since browsers don't send credentials with them.
+func (s *HTTPSecurity) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.CheckOrigin(r) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		name, ok := s.Authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kraken"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), httpPrincipalKey{}, name)))
+	})
+}
+
+// HTTPPrincipal is the name of who made a request that passed HTTPSecurity.Handler; it is empty if authentication is disabled
+func HTTPPrincipal(r *http.Request) string {
+	name, _ := r.Context().Value(httpPrincipalKey{}).(string)
+	return name
+}
+
+func readTokenFile(path string) (tokens map[string]string, e error) {
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, fmt.Errorf("could not open token file: %v", e)
+	}
+	defer f.Close()
+	tokens = make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		name := "token"
+		if len(fields) > 1 {
+			name = fields[1]
+		}
+		tokens[fields[0]] = name
+	}
+	if e = scanner.Err(); e != nil {
+		return nil, fmt.Errorf("could not read token file: %v", e)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no tokens found in token file: %s", path)
+	}
+	return
+}
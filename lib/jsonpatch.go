@@ -0,0 +1,352 @@
+/* jsonpatch.go: JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902)
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Media types for patches
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// MergePatch applies an RFC 7396 merge patch to a JSON document.
+// Objects are merged recursively, null removes a member, and anything else (including arrays) replaces what was there.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	d, e := decodeJSON(doc)
+	if e != nil {
+		return nil, fmt.Errorf("bad document: %v", e)
+	}
+	p, e := decodeJSON(patch)
+	if e != nil {
+		return nil, fmt.Errorf("bad merge patch: %v", e)
+	}
+	return json.Marshal(mergePatch(d, p))
+}
+
+// A JSONPatchOp is one operation of an RFC 6902 JSON Patch
+type JSONPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch applies an RFC 6902 JSON Patch to a JSON document.
+// The operations are applied in order; if any of them fails (including a failed "test"), the patch fails as a whole.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	d, e := decodeJSON(doc)
+	if e != nil {
+		return nil, fmt.Errorf("bad document: %v", e)
+	}
+	var ops []JSONPatchOp
+	if e = json.Unmarshal(patch, &ops); e != nil {
+		return nil, fmt.Errorf("bad JSON patch: %v", e)
+	}
+	for i, op := range ops {
+		if d, e = jsonPatchOp(d, op); e != nil {
+			return nil, fmt.Errorf("JSON patch operation %d (%s %s) failed: %v", i, op.Op, op.Path, e)
+		}
+	}
+	return json.Marshal(d)
+}
+
+// Patch applies a patch of mediaType (MergePatchType or JSONPatchType).
+// If mediaType is neither, an array is a JSON Patch and anything else is a merge patch.
+func Patch(doc, patch []byte, mediaType string) ([]byte, error) {
+	switch mediaType {
+	case MergePatchType:
+		return MergePatch(doc, patch)
+	case JSONPatchType:
+		return JSONPatch(doc, patch)
+	}
+	if t := bytes.TrimSpace(patch); len(t) > 0 && t[0] == '[' {
+		return JSONPatch(doc, patch)
+	}
+	return MergePatch(doc, patch)
+}
+
+func decodeJSON(b []byte) (v interface{}, e error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	e = d.Decode(&v)
+	return
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+func jsonPatchOp(doc interface{}, op JSONPatchOp) (interface{}, error) {
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("missing value")
+		}
+		var e error
+		if value, e = decodeJSON(op.Value); e != nil {
+			return nil, fmt.Errorf("bad value: %v", e)
+		}
+	}
+	switch op.Op {
+	case "add":
+		return jsonPointerAdd(doc, op.Path, value)
+	case "remove":
+		doc, _, e := jsonPointerRemove(doc, op.Path)
+		return doc, e
+	case "replace":
+		doc, _, e := jsonPointerRemove(doc, op.Path)
+		if e != nil {
+			return nil, e
+		}
+		return jsonPointerAdd(doc, op.Path, value)
+	case "move":
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("can't move a value into itself")
+		}
+		doc, v, e := jsonPointerRemove(doc, op.From)
+		if e != nil {
+			return nil, e
+		}
+		return jsonPointerAdd(doc, op.Path, v)
+	case "copy":
+		v, e := jsonPointerGet(doc, op.From)
+		if e != nil {
+			return nil, e
+		}
+		// copies mustn't share structure with the original
+		b, _ := json.Marshal(v)
+		v, _ = decodeJSON(b)
+		return jsonPointerAdd(doc, op.Path, v)
+	case "test":
+		v, e := jsonPointerGet(doc, op.Path)
+		if e != nil {
+			return nil, e
+		}
+		if !jsonEqual(v, value) {
+			return nil, fmt.Errorf("test failed")
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown operation")
+}
+
+// jsonPointerSplit splits an RFC 6901 JSON pointer into unescaped reference tokens
+func jsonPointerSplit(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("bad JSON pointer: %s", ptr)
+	}
+	toks := strings.Split(ptr[1:], "/")
+	for i, t := range toks {
+		toks[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return toks, nil
+}
+
+// jsonArrayIndex parses an array index token; "-" (the end) is allowed if end is set
+func jsonArrayIndex(tok string, l int, end bool) (int, error) {
+	if tok == "-" && end {
+		return l, nil
+	}
+	i, e := strconv.Atoi(tok)
+	if e != nil || i < 0 || (tok != "0" && strings.HasPrefix(tok, "0")) {
+		return 0, fmt.Errorf("bad array index: %s", tok)
+	}
+	max := l - 1
+	if end {
+		max = l
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index out of range: %s", tok)
+	}
+	return i, nil
+}
+
+func jsonPointerGet(doc interface{}, ptr string) (interface{}, error) {
+	toks, e := jsonPointerSplit(ptr)
+	if e != nil {
+		return nil, e
+	}
+	cur := doc
+	for _, t := range toks {
+		switch c := cur.(type) {
+		case map[string]interface{}:
+			v, ok := c[t]
+			if !ok {
+				return nil, fmt.Errorf("no such member: %s", t)
+			}
+			cur = v
+		case []interface{}:
+			i, e := jsonArrayIndex(t, len(c), false)
+			if e != nil {
+				return nil, e
+			}
+			cur = c[i]
+		default:
+			return nil, fmt.Errorf("can't index a scalar with: %s", t)
+		}
+	}
+	return cur, nil
+}
+
+// jsonPointerAdd adds a value at ptr, returning the new document (arrays may be reallocated)
+func jsonPointerAdd(doc interface{}, ptr string, value interface{}) (interface{}, error) {
+	toks, e := jsonPointerSplit(ptr)
+	if e != nil {
+		return nil, e
+	}
+	if len(toks) == 0 {
+		return value, nil
+	}
+	return jsonPointerUpdate(doc, toks, func(parent interface{}, last string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[last] = value
+			return p, nil
+		case []interface{}:
+			i, e := jsonArrayIndex(last, len(p), true)
+			if e != nil {
+				return nil, e
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+			return p, nil
+		}
+		return nil, fmt.Errorf("can't add to a scalar")
+	})
+}
+
+// jsonPointerRemove removes the value at ptr, returning the new document and what was removed
+func jsonPointerRemove(doc interface{}, ptr string) (interface{}, interface{}, error) {
+	toks, e := jsonPointerSplit(ptr)
+	if e != nil {
+		return nil, nil, e
+	}
+	if len(toks) == 0 {
+		return nil, doc, nil
+	}
+	var removed interface{}
+	doc, e = jsonPointerUpdate(doc, toks, func(parent interface{}, last string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			v, ok := p[last]
+			if !ok {
+				return nil, fmt.Errorf("no such member: %s", last)
+			}
+			removed = v
+			delete(p, last)
+			return p, nil
+		case []interface{}:
+			i, e := jsonArrayIndex(last, len(p), false)
+			if e != nil {
+				return nil, e
+			}
+			removed = p[i]
+			return append(p[:i], p[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("can't remove from a scalar")
+	})
+	return doc, removed, e
+}
+
+// jsonPointerUpdate walks to the parent of the last token, and replaces it with what f returns
+func jsonPointerUpdate(doc interface{}, toks []string, f func(parent interface{}, last string) (interface{}, error)) (interface{}, error) {
+	if len(toks) == 1 {
+		return f(doc, toks[0])
+	}
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		v, ok := c[toks[0]]
+		if !ok {
+			return nil, fmt.Errorf("no such member: %s", toks[0])
+		}
+		nv, e := jsonPointerUpdate(v, toks[1:], f)
+		if e != nil {
+			return nil, e
+		}
+		c[toks[0]] = nv
+		return c, nil
+	case []interface{}:
+		i, e := jsonArrayIndex(toks[0], len(c), false)
+		if e != nil {
+			return nil, e
+		}
+		nv, e := jsonPointerUpdate(c[i], toks[1:], f)
+		if e != nil {
+			return nil, e
+		}
+		c[i] = nv
+		return c, nil
+	}
+	return nil, fmt.Errorf("can't index a scalar with: %s", toks[0])
+}
+
+// jsonEqual compares decoded JSON values; numbers are equal if they have the same value
+func jsonEqual(a, b interface{}) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		af, e1 := an.Float64()
+		bf, e2 := bn.Float64()
+		return e1 == nil && e2 == nil && af == bf
+	}
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			if w, ok := bv[k]; !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
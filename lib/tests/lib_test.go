@@ -1,9 +1,22 @@
 package lib
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	proto "github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
@@ -53,6 +66,22 @@ func TestDiff(t *testing.T) {
 	}
 }
 
+// TestDiff_Any tests that an Any that has been marshaled or unmarshaled differs as a whole
+func TestDiff_Any(t *testing.T) {
+	ia1, _ := ptypes.MarshalAny(&ipb.IPv4{Ip: net.ParseIP("192.168.1.1").To4()})
+	ia2, _ := ptypes.MarshalAny(&ipb.IPv4{Ip: net.ParseIP("192.168.1.2").To4()})
+	s1 := &pb.ServiceInstance{Id: "s", Config: ia1}
+	s2 := &pb.ServiceInstance{Id: "s", Config: ia2}
+	proto.Marshal(s1) // a message that has been used keeps internal state, which we shouldn't look at
+	d, e := MessageDiff(s1, s2, "/Services/s")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !reflect.DeepEqual(d, []string{"/Services/s/Config"}) {
+		t.Errorf("incorrect diff: %v", d)
+	}
+}
+
 func TestURLShift(t *testing.T) {
 	type test struct {
 		url  string
@@ -100,3 +129,222 @@ func TestURLShift(t *testing.T) {
 			})
 	}
 }
+
+func TestStateMutationRetry_Delay(t *testing.T) {
+	type test struct {
+		name  string
+		retry StateMutationRetry
+		n     uint32
+		delay time.Duration
+	}
+	tests := []test{
+		{
+			name:  "constant",
+			retry: StateMutationRetry{Attempts: 3, Backoff: time.Second},
+			n:     2,
+			delay: time.Second,
+		},
+		{
+			name:  "exponential",
+			retry: StateMutationRetry{Attempts: 5, Backoff: time.Second, Multiplier: 2},
+			n:     3,
+			delay: 4 * time.Second,
+		},
+		{
+			name:  "bounded",
+			retry: StateMutationRetry{Attempts: 10, Backoff: time.Second, Multiplier: 2, MaxBackoff: 5 * time.Second},
+			n:     8,
+			delay: 5 * time.Second,
+		},
+	}
+	for _, v := range tests {
+		t.Run(v.name,
+			func(t *testing.T) {
+				if d := v.retry.Delay(v.n); d != v.delay {
+					t.Errorf("delay mismatch: %s != %s", d, v.delay)
+				}
+			})
+	}
+	t.Run("jitter",
+		func(t *testing.T) {
+			r := StateMutationRetry{Attempts: 2, Backoff: 10 * time.Second, Jitter: 0.5}
+			for i := 0; i < 100; i++ {
+				if d := r.Delay(1); d < 5*time.Second || d > 15*time.Second {
+					t.Errorf("jittered delay out of range: %s", d)
+				}
+			}
+		})
+}
+
+func TestHTTPSecurity(t *testing.T) {
+	f, e := ioutil.TempFile("", "tokens")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("# comment\nsecret1 alice\nsecret2\n")
+	f.Close()
+
+	cert, key := writeTestCert(t)
+	sec, e := NewHTTPSecurity(HTTPSecurityConfig{
+		CertFile:       cert,
+		KeyFile:        key,
+		TokenFile:      f.Name(),
+		AllowedOrigins: []string{"https://ui.example.com"},
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if sec.TLSConfig() == nil || sec.Scheme() != "https" {
+		t.Errorf("no TLS config with a certificate")
+	}
+	h := sec.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(HTTPPrincipal(r)))
+	}))
+	tests := []struct {
+		name   string
+		url    string
+		header map[string]string
+		code   int
+		who    string
+	}{
+		{"no token", "/cfg/nodes", nil, http.StatusUnauthorized, ""},
+		{"bad token", "/cfg/nodes", map[string]string{"Authorization": "Bearer nope"}, http.StatusUnauthorized, ""},
+		{"named token", "/cfg/nodes", map[string]string{"Authorization": "Bearer secret1"}, http.StatusOK, "alice"},
+		{"unnamed token", "/cfg/nodes", map[string]string{"Authorization": "Bearer secret2"}, http.StatusOK, "token"},
+		{"query token", "/ws?access_token=secret1", nil, http.StatusOK, "alice"},
+		{"allowed origin", "/cfg/nodes", map[string]string{"Authorization": "Bearer secret1", "Origin": "https://ui.example.com"}, http.StatusOK, "alice"},
+		{"same origin", "/cfg/nodes", map[string]string{"Authorization": "Bearer secret1", "Origin": "http://example.com"}, http.StatusOK, "alice"},
+		{"other origin", "/cfg/nodes", map[string]string{"Authorization": "Bearer secret1", "Origin": "https://evil.example.com"}, http.StatusForbidden, ""},
+	}
+	for _, v := range tests {
+		t.Run(v.name,
+			func(t *testing.T) {
+				req := httptest.NewRequest("GET", "http://example.com"+v.url, nil)
+				for k, hv := range v.header {
+					req.Header.Set(k, hv)
+				}
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if rec.Code != v.code {
+					t.Errorf("status mismatch: %d != %d", rec.Code, v.code)
+				}
+				if v.code == http.StatusOK && rec.Body.String() != v.who {
+					t.Errorf("principal mismatch: %s != %s", rec.Body.String(), v.who)
+				}
+			})
+	}
+	t.Run("bad configs",
+		func(t *testing.T) {
+			for _, cfg := range []HTTPSecurityConfig{
+				{CertFile: "cert.pem"},
+				{ClientCAFile: "ca.pem"},
+				{RequireClientCert: true},
+				{TokenFile: "/nonexistent"},
+				{TokenFile: f.Name()},
+			} {
+				if _, e := NewHTTPSecurity(cfg); e == nil {
+					t.Errorf("expected an error for config: %+v", cfg)
+				}
+			}
+		})
+}
+
+// writeTestCert writes a self-signed certificate for localhost, and its key
+func writeTestCert(t *testing.T) (cert, key string) {
+	t.Helper()
+	k, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, e := x509.CreateCertificate(rand.Reader, tpl, tpl, &k.PublicKey, k)
+	if e != nil {
+		t.Fatal(e)
+	}
+	kder, e := x509.MarshalECPrivateKey(k)
+	if e != nil {
+		t.Fatal(e)
+	}
+	dir := t.TempDir()
+	cert, key = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	ioutil.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}), 0600)
+	return
+}
+
+func TestMergePatch(t *testing.T) {
+	// from RFC 7396, appendix A
+	tests := []struct{ doc, patch, result string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, v := range tests {
+		r, e := MergePatch([]byte(v.doc), []byte(v.patch))
+		if e != nil {
+			t.Errorf("%s + %s: %v", v.doc, v.patch, e)
+			continue
+		}
+		if string(r) != v.result {
+			t.Errorf("%s + %s: %s != %s", v.doc, v.patch, r, v.result)
+		}
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	// mostly from RFC 6902, appendix A
+	tests := []struct {
+		name, doc, patch, result string
+	}{
+		{"add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"add element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"append element", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{"remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"move member", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"copy", `{"foo":{"a":1}}`, `[{"op":"copy","from":"/foo","path":"/bar"},{"op":"add","path":"/bar/b","value":2}]`, `{"bar":{"a":1,"b":2},"foo":{"a":1}}`},
+		{"test", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"escapes", `{"a/b":{"m~n":1}}`, `[{"op":"replace","path":"/a~1b/m~0n","value":2}]`, `{"a/b":{"m~n":2}}`},
+		{"failed test", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ""},
+		{"missing target", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ""},
+		{"bad index", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/01","value":"qux"}]`, ""},
+		{"unknown op", `{"foo":"bar"}`, `[{"op":"frob","path":"/foo"}]`, ""},
+	}
+	for _, v := range tests {
+		t.Run(v.name,
+			func(t *testing.T) {
+				r, e := JSONPatch([]byte(v.doc), []byte(v.patch))
+				if v.result == "" {
+					if e == nil {
+						t.Errorf("expected failure, got: %s", r)
+					}
+					return
+				}
+				if e != nil {
+					t.Fatalf("patch failed: %v", e)
+				}
+				if string(r) != v.result {
+					t.Errorf("result mismatch: %s != %s", r, v.result)
+				}
+			})
+	}
+}
@@ -0,0 +1,93 @@
+/* tls.go: TLS configuration for kraken's servers and clients
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package lib
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// NewServerTLSConfig makes a TLS config to serve with a PEM certificate and key.
+// If clientCAFile is set, client certificates signed by those CAs are verified, and required if requireClientCert is set.
+func NewServerTLSConfig(certFile, keyFile, clientCAFile string, requireClientCert bool) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("TLS needs both a certificate and a key")
+	}
+	cert, e := tls.LoadX509KeyPair(certFile, keyFile)
+	if e != nil {
+		return nil, fmt.Errorf("could not load TLS certificate: %v", e)
+	}
+	c := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile == "" {
+		if requireClientCert {
+			return nil, fmt.Errorf("requiring client certificates needs a client CA")
+		}
+		return c, nil
+	}
+	if c.ClientCAs, e = loadCertPool(clientCAFile); e != nil {
+		return nil, e
+	}
+	c.ClientAuth = tls.VerifyClientCertIfGiven
+	if requireClientCert {
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return c, nil
+}
+
+// NewClientTLSConfig makes a TLS config to connect to a server with.
+// The server is verified with the CAs in caFile, or the system's if it is empty.
+// If certFile and keyFile are set, we present them as a client certificate.
+func NewClientTLSConfig(caFile, certFile, keyFile, serverName string) (c *tls.Config, e error) {
+	c = &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if caFile != "" {
+		if c.RootCAs, e = loadCertPool(caFile); e != nil {
+			return nil, e
+		}
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("a client certificate needs both a certificate and a key")
+	}
+	if certFile != "" {
+		cert, e := tls.LoadX509KeyPair(certFile, keyFile)
+		if e != nil {
+			return nil, fmt.Errorf("could not load client certificate: %v", e)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+// TLSPeerName is the common name of a verified peer certificate, or "" if there isn't one
+func TLSPeerName(cs *tls.ConnectionState) string {
+	if cs == nil || len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return cs.VerifiedChains[0][0].Subject.CommonName
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, e := ioutil.ReadFile(file)
+	if e != nil {
+		return nil, fmt.Errorf("could not read CA file: %v", e)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file: %s", file)
+	}
+	return pool, nil
+}
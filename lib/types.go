@@ -10,6 +10,8 @@
 package lib
 
 import (
+	"context"
+	"math/rand"
 	"reflect"
 	"time"
 
@@ -192,6 +194,7 @@ type Event interface {
 	Type() EventType   // We may need to handle event types differently
 	URL() string       // URL must describe what the event pertains to
 	Data() interface{} // consumer should know what we have based on type
+	Seq() uint64       // sequence number assigned by the EventDispatchEngine, 0 if not yet dispatched
 }
 
 // EventEmitter 's emit events. They're a firehose; no filtering.
@@ -247,6 +250,9 @@ type EventDispatchEngine interface {
 	SubscriptionChan() chan<- EventListener
 	EventChan() chan<- []Event
 	Run(chan<- interface{}) // goroutine
+	Stop()                  // stop dispatching, delivering anything already queued
+	// Replay calls the function on journaled events in order, starting at the sequence number, until it returns false
+	Replay(uint64, func(Event) bool) error
 }
 
 // An EventListener decies if an event should be provided on this subscription.
@@ -263,6 +269,36 @@ type EventListener interface {
 	Type() EventType
 }
 
+// An EventListenerWithQueue chooses its own event queue size and overflow policy
+// instead of using the EventDispatchEngine defaults.  A QueueSize of 0 means use the defaults.
+type EventListenerWithQueue interface {
+	EventListener
+	QueueSize() int
+	Overflow() EventOverflowPolicy
+}
+
+// An EventListenerWithDeliver can be sent an event without filtering it again.
+// The EventDispatchEngine filters events before it queues them, so it uses Deliver instead of Send if it can.
+type EventListenerWithDeliver interface {
+	EventListener
+	Deliver(Event) error
+}
+
+// EventOverflowPolicy decides what happens when an event listener's queue is full
+type EventOverflowPolicy uint8
+
+const (
+	EventOverflow_BLOCK       EventOverflowPolicy = 0 // wait for room, up to the block time; this holds up later events for all listeners
+	EventOverflow_DROP_OLDEST EventOverflowPolicy = 1 // drop the oldest queued event
+	EventOverflow_DISCONNECT  EventOverflowPolicy = 2 // unsubscribe the listener
+)
+
+var EventOverflowPolicyString = map[EventOverflowPolicy]string{
+	EventOverflow_BLOCK:       "BLOCK",
+	EventOverflow_DROP_OLDEST: "DROP_OLDEST",
+	EventOverflow_DISCONNECT:  "DISCONNECT",
+}
+
 type EventListenerState uint8
 
 const (
@@ -301,24 +337,26 @@ const (
 	Query_FREEZE
 	Query_THAW
 	Query_FROZEN
+	Query_MUTATIONHISTORY
 )
 
 var QueryTypeMap = map[QueryType]QueryEngineType{
-	Query_CREATE:        Query_SDE,
-	Query_READ:          Query_SDE,
-	Query_UPDATE:        Query_SDE,
-	Query_DELETE:        Query_SDE,
-	Query_READALL:       Query_SDE,
-	Query_DELETEALL:     Query_SDE,
-	Query_GETVALUE:      Query_SDE,
-	Query_SETVALUE:      Query_SDE,
-	Query_RESPONSE:      Query_SDE,
-	Query_MUTATIONNODES: Query_SME,
-	Query_MUTATIONEDGES: Query_SME,
-	Query_MUTATIONPATH:  Query_SME,
-	Query_FREEZE:        Query_SME,
-	Query_THAW:          Query_SME,
-	Query_FROZEN:        Query_SME,
+	Query_CREATE:          Query_SDE,
+	Query_READ:            Query_SDE,
+	Query_UPDATE:          Query_SDE,
+	Query_DELETE:          Query_SDE,
+	Query_READALL:         Query_SDE,
+	Query_DELETEALL:       Query_SDE,
+	Query_GETVALUE:        Query_SDE,
+	Query_SETVALUE:        Query_SDE,
+	Query_RESPONSE:        Query_SDE,
+	Query_MUTATIONNODES:   Query_SME,
+	Query_MUTATIONEDGES:   Query_SME,
+	Query_MUTATIONPATH:    Query_SME,
+	Query_FREEZE:          Query_SME,
+	Query_THAW:            Query_SME,
+	Query_FROZEN:          Query_SME,
+	Query_MUTATIONHISTORY: Query_SME,
 }
 
 type QueryState uint8
@@ -386,11 +424,54 @@ type StateMutation interface {
 	SpecCompatOut(StateSpec, map[string]uint32) bool
 	Timeout() time.Duration
 	FailTo() [3]string // discover address: module:url:value_id
+	Retry() StateMutationRetry
+	Depends() []StateMutationDependency
+}
+
+// StateMutationDependency makes a mutation wait until other nodes reach a (discovered) state.
+// The nodes depended on are either named by NodeURL, or selected by Select.
+type StateMutationDependency struct {
+	NodeURL  string    // URL in the mutating node's cfg that holds the ID of the node we depend on, e.g. "/ParentId"
+	Select   StateSpec // if NodeURL is empty, we depend on every other node whose cfg matches Select
+	Requires StateSpec // state the nodes we depend on must have discovered before the mutation can fire
+}
+
+// StateMutationRetry describes how a timed out mutation should be retried before we give up and FailTo.
+// The zero value means a mutation is never retried.
+type StateMutationRetry struct {
+	Attempts   uint32        // total number of times the mutation may be fired, including the first
+	Backoff    time.Duration // delay before the first retry
+	MaxBackoff time.Duration // upper bound on the retry delay, 0 means unbounded
+	Multiplier float64       // exponential growth of the delay on each retry, values < 1 are treated as 1
+	Jitter     float64       // randomize each delay by +/- this fraction (0.0 - 1.0)
+}
+
+// Delay calculates how long to wait before firing retry number n (starting at 1)
+func (r StateMutationRetry) Delay(n uint32) time.Duration {
+	m := r.Multiplier
+	if m < 1 {
+		m = 1
+	}
+	d := float64(r.Backoff)
+	for i := uint32(1); i < n; i++ {
+		d *= m
+		if r.MaxBackoff > 0 && d >= float64(r.MaxBackoff) {
+			break
+		}
+	}
+	if r.MaxBackoff > 0 && d > float64(r.MaxBackoff) {
+		d = float64(r.MaxBackoff)
+	}
+	if r.Jitter > 0 {
+		d += d * r.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
 }
 
 type StateMutationEngine interface {
 	EventEmitter
 	RegisterMutation(module, id string, mut StateMutation) error
+	UnregisterMutation(module, id string) error
 	NodeMatch(node Node) int
 	PathExists(start Node, end Node) (bool, error)
 	Run(chan<- interface{})
@@ -489,9 +570,11 @@ type ServiceInstance interface {
 	UpdateConfig()                      // Tell process to update its config
 	Start()                             // Tell process to start
 	Stop()                              // Tell process to stop
+	Kill()                              // Stop the process without asking it (e.g. because it stopped responding)
 	Watch(chan<- ServiceInstanceUpdate) // Tell process to report state changes over this chan
 	SetCtl(chan<- ServiceControl)       // Where to send service control messages
 	SetSock(string)                     // Set the path to the API socket
+	SetCgroup(string)                   // Set the cgroup to run the process in; empty for kraken's own
 }
 
 // A ServiceManager handles the lifecycle of external services
@@ -500,6 +583,14 @@ type ServiceManager interface {
 	DelService(string)
 	GetService(string) ServiceInstance
 	Run(chan<- interface{})
+	Services() []string                                  // IDs of the services we manage
+	Status(string) (*pb.ServiceStatus, error)            // cfg and dsc state, error and config of a service
+	StartService(string) error                           // set a service to run
+	StopService(string) error                            // set a service to stop
+	RestartService(string) error                         // stop a running service and start it again
+	ConfigureService(string, proto.Message) error        // set a new config for a service
+	Heartbeat(string, time.Duration) error               // a service is alive, and promises to say so again within the duration
+	RegisterPlugin(string, *pb.PluginRegistration) error // a plugin service registers what it provides
 }
 
 /*
@@ -530,6 +621,14 @@ type ModuleWithConfig interface {
 	ConfigURL() string
 }
 
+// ModuleWithConfigValidation is a ModuleWithConfig that checks configs before they're accepted.
+// ValidateConfig is called in the kraken process (not the module's) on the registered module object,
+// so it should only look at the config it's given.  An error rejects the config, with the error as the reason.
+type ModuleWithConfigValidation interface {
+	ModuleWithConfig
+	ValidateConfig(proto.Message) error
+}
+
 type ModuleWithMutations interface {
 	Module
 	SetMutationChan(<-chan Event)
@@ -553,6 +652,11 @@ type APIClient interface {
 	QueryReadDsc(string) (Node, error)
 	QueryUpdate(Node) (Node, error)
 	QueryUpdateDsc(Node) (Node, error)
+	// QueryUpdateIf is QueryUpdate, but only if the node is still at a version from core.NodeVersion;
+	// if it isn't, the error has the gRPC code Aborted
+	QueryUpdateIf(Node, string) (Node, error)
+	// QuerySetValue sets a single cfg value by node URL, in one step; it returns the value that got set
+	QuerySetValue(string, reflect.Value) (reflect.Value, error)
 	QueryDelete(string) (Node, error)
 	QueryReadAll() ([]Node, error)
 	QueryReadAllDsc() ([]Node, error)
@@ -561,9 +665,23 @@ type APIClient interface {
 	QueryNodeMutationNodes(string) (pb.MutationNodeList, error)
 	QueryNodeMutationEdges(string) (pb.MutationEdgeList, error)
 	QueryNodeMutationPath(string) (pb.MutationPath, error)
+	QueryNodeMutationHistory(string) (pb.MutationHistory, error)
 	QueryDeleteAll() ([]Node, error)
 	QueryFreeze() error
 	QueryThaw() error
 	QueryFrozen() (bool, error)
+	Metrics() (string, error)
 	ServiceInit(string, string) (<-chan ServiceControl, error)
+	ServiceList() ([]*pb.ServiceStatus, error)
+	ServiceGet(string) (*pb.ServiceStatus, error)
+	ServiceStart(string) (*pb.ServiceStatus, error)
+	ServiceStop(string) (*pb.ServiceStatus, error)
+	ServiceRestart(string) (*pb.ServiceStatus, error)
+	ServiceConfigure(string, proto.Message) (*pb.ServiceStatus, error)
+	ServiceHeartbeat(string, time.Duration) error
+	// WithPrincipal returns a client that makes calls on behalf of someone, subject to the authorization policy
+	WithPrincipal(string) APIClient
+	// Watch streams events, first replaying journaled events from a sequence number (0 for none),
+	// optionally only of the listed types.  The channel is closed when the context is done.
+	Watch(context.Context, uint64, []EventType) (<-chan Event, error)
 }
@@ -62,6 +62,9 @@ func diffStruct(a, b reflect.Value, pre string) (r []string, e error) {
 		if f.Name == "Extensions" || f.Name == "Services" || f.Name == "Children" || f.Name == "Parents" || strings.HasPrefix(f.Name, "XXX_") {
 			continue
 		}
+		if f.PkgPath != "" { // unexported, e.g. the message's internal state
+			continue
+		}
 		s, e := diffAny(a.Field(i), b.Field(i), URLPush(pre, f.Name))
 		if e != nil {
 			return r, e
@@ -105,6 +108,13 @@ func diffAny(a, b reflect.Value, pre string) (r []string, e error) {
 			return []string{pre}, nil
 		}
 	case reflect.Struct:
+		if a.Type() == reflect.TypeOf(any.Any{}) {
+			// an Any is set as a whole, so it differs as a whole
+			if !proto.Equal(a.Addr().Interface().(proto.Message), b.Addr().Interface().(proto.Message)) {
+				return []string{pre}, nil
+			}
+			return
+		}
 		return diffStruct(a, b, pre)
 	case reflect.Slice:
 		return diffSlice(a, b, pre)
@@ -0,0 +1,343 @@
+/* eventexport.go: this module exports kraken events to external systems, e.g. syslog, files and webhooks
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+//go:generate protoc -I ../../core/proto/include -I proto --go_out=plugins=grpc:proto proto/eventexport.proto
+
+package eventexport
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hpc/kraken/core"
+	cpb "github.com/hpc/kraken/core/proto"
+	"github.com/hpc/kraken/lib"
+	pb "github.com/hpc/kraken/modules/eventexport/proto"
+)
+
+var _ lib.Module = (*EventExport)(nil)
+var _ lib.ModuleSelfService = (*EventExport)(nil)
+var _ lib.ModuleWithConfig = (*EventExport)(nil)
+var _ lib.ModuleWithAllEvents = (*EventExport)(nil)
+var _ lib.ModuleWithDiscovery = (*EventExport)(nil)
+
+const EeStateURL = "/Services/eventexport/State"
+
+// A Record is what gets exported for each event
+type Record struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	NodeId string    `json:"nodeid"`
+	URL    string    `json:"url"`
+	Value  string    `json:"value"`
+	Data   string    `json:"data"`
+}
+
+// newRecord converts an event into a Record, or returns nil if we don't know how
+func newRecord(ev lib.Event) (r *Record) {
+	nodeID, url := lib.NodeURLSplit(ev.URL())
+	r = &Record{
+		Seq:    ev.Seq(),
+		Time:   time.Now(),
+		Type:   lib.EventTypeString[ev.Type()],
+		NodeId: nodeID,
+		URL:    url,
+	}
+	switch d := ev.Data().(type) {
+	case *core.MutationEvent:
+		r.Value = d.Mutation[1]
+		r.Data = d.String()
+	case *core.StateChangeEvent:
+		r.Value = lib.ValueToString(d.Value)
+		r.Data = d.String()
+	case *core.DiscoveryEvent:
+		r.Value = d.ValueID
+		r.Data = d.String()
+	default:
+		return nil
+	}
+	return
+}
+
+// An exporter filters events, and queues them for a sink
+type exporter struct {
+	name    string
+	re      *regexp.Regexp // nil matches everything
+	types   map[lib.EventType]bool
+	sink    sink
+	queue   chan *Record
+	dropped uint64
+	done    chan interface{}
+	api     lib.APIClient
+}
+
+func newExporter(cfg *pb.EventExportSink, api lib.APIClient) (x *exporter, e error) {
+	x = &exporter{
+		name:  cfg.GetName(),
+		types: make(map[lib.EventType]bool),
+		done:  make(chan interface{}),
+		api:   api,
+	}
+	if x.name == "" {
+		return nil, fmt.Errorf("event export sinks must have a name")
+	}
+	if re := cfg.GetFilter().GetUrl(); re != "" {
+		if x.re, e = regexp.Compile(re); e != nil {
+			return nil, fmt.Errorf("sink %s: bad URL filter: %v", x.name, e)
+		}
+	}
+	for _, t := range cfg.GetFilter().GetTypes() {
+		et, ok := lib.EventTypeValue[t]
+		if !ok {
+			return nil, fmt.Errorf("sink %s: unknown event type: %s", x.name, t)
+		}
+		x.types[et] = true
+	}
+	switch s := cfg.GetSink().(type) {
+	case *pb.EventExportSink_Syslog:
+		x.sink, e = newSyslogSink(s.Syslog)
+	case *pb.EventExportSink_File:
+		x.sink, e = newFileSink(s.File)
+	case *pb.EventExportSink_Webhook:
+		x.sink, e = newWebhookSink(s.Webhook)
+	default:
+		e = fmt.Errorf("no sink type specified")
+	}
+	if e != nil {
+		return nil, fmt.Errorf("sink %s: %v", x.name, e)
+	}
+	q := cfg.GetQueue()
+	if q == 0 {
+		q = 1024
+	}
+	x.queue = make(chan *Record, q)
+	go x.run()
+	return
+}
+
+// Filter decides if an event should go to this exporter's sink
+func (x *exporter) Filter(ev lib.Event) bool {
+	if len(x.types) > 0 && !x.types[ev.Type()] {
+		return false
+	}
+	if x.re != nil && !core.FilterRegexp(ev, x.re) {
+		return false
+	}
+	return true
+}
+
+// push queues a record, dropping it if the queue is full
+func (x *exporter) push(r *Record) {
+	select {
+	case x.queue <- r:
+	default:
+		x.dropped++
+		if x.dropped == 1 || x.dropped%1000 == 0 {
+			x.api.Logf(lib.LLERROR, "sink %s can't keep up, %d events dropped", x.name, x.dropped)
+		}
+	}
+}
+
+// goroutine
+func (x *exporter) run() {
+	defer close(x.done)
+	for r := range x.queue {
+		if e := x.sink.Export(r); e != nil {
+			x.api.Logf(lib.LLERROR, "sink %s failed to export event %d: %v", x.name, r.Seq, e)
+		}
+	}
+}
+
+// close exports whatever is still queued, then closes the sink
+func (x *exporter) close() {
+	close(x.queue)
+	<-x.done
+	if e := x.sink.Close(); e != nil {
+		x.api.Logf(lib.LLERROR, "sink %s failed to close: %v", x.name, e)
+	}
+}
+
+// EventExport sends events to the sinks in its config
+type EventExport struct {
+	cfg       *pb.EventExportConfig
+	api       lib.APIClient
+	echan     <-chan lib.Event
+	dchan     chan<- lib.Event
+	mutex     *sync.Mutex
+	exporters []*exporter
+	running   bool
+}
+
+/*
+ * Required methods
+ */
+
+func (ee *EventExport) Entry() {
+	ee.mutex.Lock()
+	stale, e := ee.setup()
+	if e != nil {
+		ee.api.Logf(lib.LLERROR, "failed to set up event export: %v", e)
+	}
+	ee.running = true
+	ee.mutex.Unlock()
+	closeExporters(stale)
+
+	url := lib.NodeURLJoin(ee.api.Self().String(), EeStateURL)
+	ee.dchan <- core.NewEvent(
+		lib.Event_DISCOVERY,
+		url,
+		&core.DiscoveryEvent{
+			URL:     url,
+			ValueID: "RUN",
+		},
+	)
+
+	for {
+		select {
+		case ev := <-ee.echan:
+			ee.export(ev)
+			break
+		}
+	}
+}
+
+func (ee *EventExport) Stop() {
+	ee.mutex.Lock()
+	xs := ee.exporters
+	ee.exporters = nil
+	ee.mutex.Unlock()
+	closeExporters(xs)
+	os.Exit(0)
+}
+
+func (ee *EventExport) Name() string { return "github.com/hpc/kraken/modules/eventexport" }
+
+/*
+ * Optional methods
+ */
+
+// UpdateConfig replaces our sinks.  If the new config is bad, we keep the old sinks.
+func (ee *EventExport) UpdateConfig(cfg proto.Message) (e error) {
+	ec, ok := cfg.(*pb.EventExportConfig)
+	if !ok {
+		return fmt.Errorf("wrong config type")
+	}
+	ee.api.Logf(lib.LLDEBUG, "updating config for eventexport: %v", ec)
+	ee.mutex.Lock()
+	old := ee.cfg
+	ee.cfg = ec
+	if !ee.running {
+		// Entry will set things up
+		ee.mutex.Unlock()
+		return
+	}
+	stale, e := ee.setup()
+	if e != nil {
+		ee.api.Logf(lib.LLERROR, "bad event export config, keeping the old one: %v", e)
+		ee.cfg = old
+	}
+	ee.mutex.Unlock()
+	// closing flushes queues, which can be slow; don't hold up events while it happens
+	closeExporters(stale)
+	return
+}
+
+func (ee *EventExport) SetEventsChan(c <-chan lib.Event) { ee.echan = c }
+
+func (ee *EventExport) SetDiscoveryChan(c chan<- lib.Event) { ee.dchan = c }
+
+func (ee *EventExport) Init(api lib.APIClient) {
+	ee.api = api
+	ee.mutex = &sync.Mutex{}
+	ee.cfg = ee.NewConfig().(*pb.EventExportConfig)
+}
+
+func (ee *EventExport) NewConfig() proto.Message {
+	return &pb.EventExportConfig{
+		Sinks: []*pb.EventExportSink{},
+	}
+}
+
+func (ee *EventExport) ConfigURL() string {
+	a, _ := ptypes.MarshalAny(ee.NewConfig())
+	return a.GetTypeUrl()
+}
+
+////////////////////////
+// Unexported methods /
+//////////////////////
+
+// setup creates the exporters for our config, replacing any we had.
+// If any sink fails, the old exporters are kept.
+// It returns the exporters that are no longer used; the caller should close them after releasing the lock.
+// !!!IMPORTANT!!! assumes you already hold a lock
+func (ee *EventExport) setup() (stale []*exporter, e error) {
+	xs := []*exporter{}
+	for _, s := range ee.cfg.GetSinks() {
+		x, e := newExporter(s, ee.api)
+		if e != nil {
+			return xs, e
+		}
+		xs = append(xs, x)
+	}
+	stale = ee.exporters
+	ee.exporters = xs
+	ee.api.Logf(lib.LLINFO, "exporting events to %d sinks", len(xs))
+	return
+}
+
+// closeExporters closes exporters, exporting whatever they still have queued
+func closeExporters(xs []*exporter) {
+	for _, x := range xs {
+		x.close()
+	}
+}
+
+// export hands an event to every exporter that wants it
+func (ee *EventExport) export(ev lib.Event) {
+	ee.mutex.Lock()
+	defer ee.mutex.Unlock()
+	var r *Record
+	for _, x := range ee.exporters {
+		if !x.Filter(ev) {
+			continue
+		}
+		if r == nil {
+			if r = newRecord(ev); r == nil {
+				ee.api.Logf(lib.LLDEBUG, "not exporting unknown event: %+v", ev.Data())
+				return
+			}
+		}
+		x.push(r)
+	}
+}
+
+func init() {
+	module := &EventExport{}
+	si := core.NewServiceInstance(
+		"eventexport",
+		module.Name(),
+		module.Entry,
+	)
+
+	discovers := make(map[string]map[string]reflect.Value)
+	discovers[EeStateURL] = map[string]reflect.Value{
+		"RUN": reflect.ValueOf(cpb.ServiceInstance_RUN)}
+
+	core.Registry.RegisterModule(module)
+	core.Registry.RegisterServiceInstance(module, map[string]lib.ServiceInstance{si.ID(): si})
+	core.Registry.RegisterDiscoverable(si, discovers)
+}
@@ -0,0 +1,155 @@
+package eventexport
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/core/ktesting"
+	"github.com/hpc/kraken/lib"
+
+	cpb "github.com/hpc/kraken/core/proto"
+	pb "github.com/hpc/kraken/modules/eventexport/proto"
+)
+
+func testEvent(url string, v interface{}) lib.Event {
+	nodeURL := lib.NodeURLJoin(testID, url)
+	return core.NewEvent(lib.Event_STATE_CHANGE, nodeURL, &core.StateChangeEvent{
+		Type:  cpb.StateChangeControl_UPDATE,
+		URL:   nodeURL,
+		Value: reflect.ValueOf(v),
+	})
+}
+
+func fileSinkConfig(name, path string) *pb.EventExportSink {
+	return &pb.EventExportSink{Name: name, Sink: &pb.EventExportSink_File{File: &pb.FileSink{Path: path}}}
+}
+
+// readRecords reads the records in a file sink's file
+func readRecords(t *testing.T, path string) (rs []*Record) {
+	t.Helper()
+	b, e := ioutil.ReadFile(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, l := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		if l == "" {
+			continue
+		}
+		r := &Record{}
+		if e := json.Unmarshal([]byte(l), r); e != nil {
+			t.Fatalf("bad record in %s: %v", path, e)
+		}
+		rs = append(rs, r)
+	}
+	return
+}
+
+// TestNewExporter tests which sink configs are rejected, and which events a filter passes
+func TestNewExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	power := testEvent("/PhysState", cpb.Node_POWER_ON)
+	arch := testEvent("/Arch", "x86_64")
+	tests := []struct {
+		name   string
+		filter *pb.EventExportFilter
+		err    string
+		pass   []lib.Event
+		block  []lib.Event
+	}{
+		{"no filter", nil, "", []lib.Event{power, arch}, nil},
+		{"url", &pb.EventExportFilter{Url: ":/PhysState$"}, "", []lib.Event{power}, []lib.Event{arch}},
+		{"types", &pb.EventExportFilter{Types: []string{"DISCOVERY"}}, "", nil, []lib.Event{power, arch}},
+		{"types and url", &pb.EventExportFilter{Types: []string{"STATE_CHANGE", "DISCOVERY"}, Url: "/Arch"}, "", []lib.Event{arch}, []lib.Event{power}},
+		{"bad url", &pb.EventExportFilter{Url: "("}, "bad URL filter", nil, nil},
+		{"bad type", &pb.EventExportFilter{Types: []string{"FOO"}}, "unknown event type: FOO", nil, nil},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			cfg := fileSinkConfig("test", path)
+			cfg.Filter = v.filter
+			x, e := newExporter(cfg, ktest.NewAPIClient(core.NewNodeWithID(testID)))
+			if v.err != "" {
+				if e == nil || !strings.Contains(e.Error(), v.err) {
+					t.Fatalf("expected an error containing %q, got: %v", v.err, e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatal(e)
+			}
+			defer x.close()
+			for _, ev := range v.pass {
+				if !x.Filter(ev) {
+					t.Errorf("%s was filtered out", ev.URL())
+				}
+			}
+			for _, ev := range v.block {
+				if x.Filter(ev) {
+					t.Errorf("%s wasn't filtered out", ev.URL())
+				}
+			}
+		})
+	}
+
+	for _, cfg := range []*pb.EventExportSink{
+		{Sink: &pb.EventExportSink_File{File: &pb.FileSink{Path: path}}},
+		{Name: "nosink"},
+		{Name: "badsink", Sink: &pb.EventExportSink_File{File: &pb.FileSink{}}},
+	} {
+		if _, e := newExporter(cfg, ktest.NewAPIClient(core.NewNodeWithID(testID))); e == nil {
+			t.Errorf("a bad sink was accepted: %v", cfg)
+		}
+	}
+}
+
+// TestEventExport tests that events reach the sinks, and that a new config replaces them after flushing the old ones
+func TestEventExport(t *testing.T) {
+	dir := t.TempDir()
+	old, cur := filepath.Join(dir, "old.json"), filepath.Join(dir, "new.json")
+	ee := &EventExport{}
+	h := ktest.NewHarness(t, ee, core.NewNodeWithID(testID))
+	// Event returns once the module has taken an event, which may still be on its way to the exporters.
+	// Exporters ignore events they can't make a record of, so once the module takes one of those, the last event was exported.
+	exported := func() { h.Event(core.NewEvent(lib.Event_STATE_CHANGE, testID, "not a record")) }
+	h.Configure(&pb.EventExportConfig{Sinks: []*pb.EventExportSink{fileSinkConfig("old", old)}})
+	h.Start()
+	h.ExpectDiscovery(lib.NodeURLJoin(testID, EeStateURL), "RUN")
+
+	for i := 0; i < 100; i++ {
+		h.Event(testEvent("/PhysState", cpb.Node_POWER_ON))
+	}
+	// a bad config keeps the old sinks
+	if e := ee.UpdateConfig(&pb.EventExportConfig{Sinks: []*pb.EventExportSink{fileSinkConfig("new", cur), {Name: "bad"}}}); e == nil {
+		t.Errorf("a bad config was accepted")
+	}
+	h.Event(testEvent("/Arch", "x86_64"))
+	exported()
+	// a good one replaces them
+	h.Configure(&pb.EventExportConfig{Sinks: []*pb.EventExportSink{fileSinkConfig("new", cur)}})
+	// the old exporter is closed by now, so everything it was sent is in its file
+	if rs := readRecords(t, old); len(rs) != 101 || rs[0].URL != "/PhysState" || rs[0].Value != "POWER_ON" || rs[100].URL != "/Arch" {
+		t.Fatalf("expected 101 records in the old file, got %d", len(rs))
+	}
+	if rs := readRecords(t, cur); len(rs) != 0 {
+		t.Errorf("the new file got events before it was in use: %d", len(rs))
+	}
+
+	h.Event(testEvent("/PhysState", cpb.Node_POWER_OFF))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(readRecords(t, cur)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	rs := readRecords(t, cur)
+	if len(rs) != 1 || rs[0].Value != "POWER_OFF" || rs[0].NodeId != testID || rs[0].Type != "STATE_CHANGE" {
+		t.Errorf("unexpected records in the new file: %+v", rs)
+	}
+	if rs := readRecords(t, old); len(rs) != 101 {
+		t.Errorf("the old file got events after it was replaced: %d", len(rs))
+	}
+}
@@ -0,0 +1,463 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: eventexport.proto
+
+package proto
+
+import (
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type EventExportConfig struct {
+	Sinks                []*EventExportSink `protobuf:"bytes,1,rep,name=sinks,proto3" json:"sinks,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *EventExportConfig) Reset()         { *m = EventExportConfig{} }
+func (m *EventExportConfig) String() string { return proto.CompactTextString(m) }
+func (*EventExportConfig) ProtoMessage()    {}
+func (*EventExportConfig) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bae94d624a5ea6d3, []int{0}
+}
+
+func (m *EventExportConfig) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EventExportConfig.Unmarshal(m, b)
+}
+func (m *EventExportConfig) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EventExportConfig.Marshal(b, m, deterministic)
+}
+func (m *EventExportConfig) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EventExportConfig.Merge(m, src)
+}
+func (m *EventExportConfig) XXX_Size() int {
+	return xxx_messageInfo_EventExportConfig.Size(m)
+}
+func (m *EventExportConfig) XXX_DiscardUnknown() {
+	xxx_messageInfo_EventExportConfig.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EventExportConfig proto.InternalMessageInfo
+
+func (m *EventExportConfig) GetSinks() []*EventExportSink {
+	if m != nil {
+		return m.Sinks
+	}
+	return nil
+}
+
+type EventExportSink struct {
+	Name   string             `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Filter *EventExportFilter `protobuf:"bytes,2,opt,name=filter,proto3" json:"filter,omitempty"`
+	Queue  uint32             `protobuf:"varint,3,opt,name=queue,proto3" json:"queue,omitempty"`
+	// Types that are valid to be assigned to Sink:
+	//	*EventExportSink_Syslog
+	//	*EventExportSink_File
+	//	*EventExportSink_Webhook
+	Sink                 isEventExportSink_Sink `protobuf_oneof:"sink"`
+	XXX_NoUnkeyedLiteral struct{}               `json:"-"`
+	XXX_unrecognized     []byte                 `json:"-"`
+	XXX_sizecache        int32                  `json:"-"`
+}
+
+func (m *EventExportSink) Reset()         { *m = EventExportSink{} }
+func (m *EventExportSink) String() string { return proto.CompactTextString(m) }
+func (*EventExportSink) ProtoMessage()    {}
+func (*EventExportSink) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bae94d624a5ea6d3, []int{1}
+}
+
+func (m *EventExportSink) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EventExportSink.Unmarshal(m, b)
+}
+func (m *EventExportSink) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EventExportSink.Marshal(b, m, deterministic)
+}
+func (m *EventExportSink) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EventExportSink.Merge(m, src)
+}
+func (m *EventExportSink) XXX_Size() int {
+	return xxx_messageInfo_EventExportSink.Size(m)
+}
+func (m *EventExportSink) XXX_DiscardUnknown() {
+	xxx_messageInfo_EventExportSink.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EventExportSink proto.InternalMessageInfo
+
+func (m *EventExportSink) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *EventExportSink) GetFilter() *EventExportFilter {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
+}
+
+func (m *EventExportSink) GetQueue() uint32 {
+	if m != nil {
+		return m.Queue
+	}
+	return 0
+}
+
+type isEventExportSink_Sink interface {
+	isEventExportSink_Sink()
+}
+
+type EventExportSink_Syslog struct {
+	Syslog *SyslogSink `protobuf:"bytes,4,opt,name=syslog,proto3,oneof"`
+}
+
+type EventExportSink_File struct {
+	File *FileSink `protobuf:"bytes,5,opt,name=file,proto3,oneof"`
+}
+
+type EventExportSink_Webhook struct {
+	Webhook *WebhookSink `protobuf:"bytes,6,opt,name=webhook,proto3,oneof"`
+}
+
+func (*EventExportSink_Syslog) isEventExportSink_Sink() {}
+
+func (*EventExportSink_File) isEventExportSink_Sink() {}
+
+func (*EventExportSink_Webhook) isEventExportSink_Sink() {}
+
+func (m *EventExportSink) GetSink() isEventExportSink_Sink {
+	if m != nil {
+		return m.Sink
+	}
+	return nil
+}
+
+func (m *EventExportSink) GetSyslog() *SyslogSink {
+	if x, ok := m.GetSink().(*EventExportSink_Syslog); ok {
+		return x.Syslog
+	}
+	return nil
+}
+
+func (m *EventExportSink) GetFile() *FileSink {
+	if x, ok := m.GetSink().(*EventExportSink_File); ok {
+		return x.File
+	}
+	return nil
+}
+
+func (m *EventExportSink) GetWebhook() *WebhookSink {
+	if x, ok := m.GetSink().(*EventExportSink_Webhook); ok {
+		return x.Webhook
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*EventExportSink) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*EventExportSink_Syslog)(nil),
+		(*EventExportSink_File)(nil),
+		(*EventExportSink_Webhook)(nil),
+	}
+}
+
+type EventExportFilter struct {
+	Url                  string   `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Types                []string `protobuf:"bytes,2,rep,name=types,proto3" json:"types,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EventExportFilter) Reset()         { *m = EventExportFilter{} }
+func (m *EventExportFilter) String() string { return proto.CompactTextString(m) }
+func (*EventExportFilter) ProtoMessage()    {}
+func (*EventExportFilter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bae94d624a5ea6d3, []int{2}
+}
+
+func (m *EventExportFilter) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EventExportFilter.Unmarshal(m, b)
+}
+func (m *EventExportFilter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EventExportFilter.Marshal(b, m, deterministic)
+}
+func (m *EventExportFilter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EventExportFilter.Merge(m, src)
+}
+func (m *EventExportFilter) XXX_Size() int {
+	return xxx_messageInfo_EventExportFilter.Size(m)
+}
+func (m *EventExportFilter) XXX_DiscardUnknown() {
+	xxx_messageInfo_EventExportFilter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EventExportFilter proto.InternalMessageInfo
+
+func (m *EventExportFilter) GetUrl() string {
+	if m != nil {
+		return m.Url
+	}
+	return ""
+}
+
+func (m *EventExportFilter) GetTypes() []string {
+	if m != nil {
+		return m.Types
+	}
+	return nil
+}
+
+// SyslogSink sends RFC 5424 syslog messages
+type SyslogSink struct {
+	Network              string   `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
+	Addr                 string   `protobuf:"bytes,2,opt,name=addr,proto3" json:"addr,omitempty"`
+	Facility             uint32   `protobuf:"varint,3,opt,name=facility,proto3" json:"facility,omitempty"`
+	AppName              string   `protobuf:"bytes,4,opt,name=app_name,json=appName,proto3" json:"app_name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SyslogSink) Reset()         { *m = SyslogSink{} }
+func (m *SyslogSink) String() string { return proto.CompactTextString(m) }
+func (*SyslogSink) ProtoMessage()    {}
+func (*SyslogSink) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bae94d624a5ea6d3, []int{3}
+}
+
+func (m *SyslogSink) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SyslogSink.Unmarshal(m, b)
+}
+func (m *SyslogSink) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SyslogSink.Marshal(b, m, deterministic)
+}
+func (m *SyslogSink) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SyslogSink.Merge(m, src)
+}
+func (m *SyslogSink) XXX_Size() int {
+	return xxx_messageInfo_SyslogSink.Size(m)
+}
+func (m *SyslogSink) XXX_DiscardUnknown() {
+	xxx_messageInfo_SyslogSink.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SyslogSink proto.InternalMessageInfo
+
+func (m *SyslogSink) GetNetwork() string {
+	if m != nil {
+		return m.Network
+	}
+	return ""
+}
+
+func (m *SyslogSink) GetAddr() string {
+	if m != nil {
+		return m.Addr
+	}
+	return ""
+}
+
+func (m *SyslogSink) GetFacility() uint32 {
+	if m != nil {
+		return m.Facility
+	}
+	return 0
+}
+
+func (m *SyslogSink) GetAppName() string {
+	if m != nil {
+		return m.AppName
+	}
+	return ""
+}
+
+// FileSink writes one JSON object per line
+type FileSink struct {
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	MaxSize              uint64   `protobuf:"varint,2,opt,name=max_size,json=maxSize,proto3" json:"max_size,omitempty"`
+	MaxFiles             uint32   `protobuf:"varint,3,opt,name=max_files,json=maxFiles,proto3" json:"max_files,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FileSink) Reset()         { *m = FileSink{} }
+func (m *FileSink) String() string { return proto.CompactTextString(m) }
+func (*FileSink) ProtoMessage()    {}
+func (*FileSink) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bae94d624a5ea6d3, []int{4}
+}
+
+func (m *FileSink) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FileSink.Unmarshal(m, b)
+}
+func (m *FileSink) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FileSink.Marshal(b, m, deterministic)
+}
+func (m *FileSink) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FileSink.Merge(m, src)
+}
+func (m *FileSink) XXX_Size() int {
+	return xxx_messageInfo_FileSink.Size(m)
+}
+func (m *FileSink) XXX_DiscardUnknown() {
+	xxx_messageInfo_FileSink.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FileSink proto.InternalMessageInfo
+
+func (m *FileSink) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *FileSink) GetMaxSize() uint64 {
+	if m != nil {
+		return m.MaxSize
+	}
+	return 0
+}
+
+func (m *FileSink) GetMaxFiles() uint32 {
+	if m != nil {
+		return m.MaxFiles
+	}
+	return 0
+}
+
+// WebhookSink POSTs each event as JSON
+type WebhookSink struct {
+	Url                  string            `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Headers              map[string]string `protobuf:"bytes,2,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Retries              uint32            `protobuf:"varint,3,opt,name=retries,proto3" json:"retries,omitempty"`
+	Backoff              string            `protobuf:"bytes,4,opt,name=backoff,proto3" json:"backoff,omitempty"`
+	Timeout              string            `protobuf:"bytes,5,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *WebhookSink) Reset()         { *m = WebhookSink{} }
+func (m *WebhookSink) String() string { return proto.CompactTextString(m) }
+func (*WebhookSink) ProtoMessage()    {}
+func (*WebhookSink) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bae94d624a5ea6d3, []int{5}
+}
+
+func (m *WebhookSink) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WebhookSink.Unmarshal(m, b)
+}
+func (m *WebhookSink) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WebhookSink.Marshal(b, m, deterministic)
+}
+func (m *WebhookSink) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WebhookSink.Merge(m, src)
+}
+func (m *WebhookSink) XXX_Size() int {
+	return xxx_messageInfo_WebhookSink.Size(m)
+}
+func (m *WebhookSink) XXX_DiscardUnknown() {
+	xxx_messageInfo_WebhookSink.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WebhookSink proto.InternalMessageInfo
+
+func (m *WebhookSink) GetUrl() string {
+	if m != nil {
+		return m.Url
+	}
+	return ""
+}
+
+func (m *WebhookSink) GetHeaders() map[string]string {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
+}
+
+func (m *WebhookSink) GetRetries() uint32 {
+	if m != nil {
+		return m.Retries
+	}
+	return 0
+}
+
+func (m *WebhookSink) GetBackoff() string {
+	if m != nil {
+		return m.Backoff
+	}
+	return ""
+}
+
+func (m *WebhookSink) GetTimeout() string {
+	if m != nil {
+		return m.Timeout
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*EventExportConfig)(nil), "proto.EventExportConfig")
+	proto.RegisterType((*EventExportSink)(nil), "proto.EventExportSink")
+	proto.RegisterType((*EventExportFilter)(nil), "proto.EventExportFilter")
+	proto.RegisterType((*SyslogSink)(nil), "proto.SyslogSink")
+	proto.RegisterType((*FileSink)(nil), "proto.FileSink")
+	proto.RegisterType((*WebhookSink)(nil), "proto.WebhookSink")
+	proto.RegisterMapType((map[string]string)(nil), "proto.WebhookSink.HeadersEntry")
+}
+
+func init() { proto.RegisterFile("eventexport.proto", fileDescriptor_bae94d624a5ea6d3) }
+
+var fileDescriptor_bae94d624a5ea6d3 = []byte{
+	// 463 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x51, 0x4d, 0x6f, 0xd3, 0x40,
+	0x10, 0xc5, 0x89, 0xf3, 0xe1, 0x09, 0xa8, 0x64, 0x85, 0xd0, 0x52, 0x0e, 0x58, 0x96, 0x90, 0x2c,
+	0x81, 0x22, 0x14, 0x2e, 0x50, 0x4e, 0x80, 0x52, 0xf5, 0xc4, 0x61, 0x23, 0xc1, 0xb1, 0xda, 0x34,
+	0xe3, 0x66, 0xe5, 0x8f, 0x75, 0xed, 0x75, 0x1b, 0xf7, 0xf7, 0xf2, 0x27, 0xb8, 0xa1, 0xfd, 0x2a,
+	0x11, 0xe9, 0xc9, 0xf3, 0xfc, 0xde, 0xbe, 0x99, 0x79, 0x03, 0x73, 0xbc, 0xc5, 0x4a, 0xe1, 0xbe,
+	0x96, 0x8d, 0x5a, 0xd4, 0x8d, 0x54, 0x92, 0x8c, 0xcc, 0x27, 0xf9, 0x0a, 0xf3, 0x95, 0xe6, 0x56,
+	0x86, 0xfb, 0x2e, 0xab, 0x4c, 0x5c, 0x93, 0xf7, 0x30, 0x6a, 0x45, 0x95, 0xb7, 0x34, 0x88, 0x87,
+	0xe9, 0x6c, 0xf9, 0xd2, 0x3e, 0x59, 0x1c, 0x08, 0xd7, 0xa2, 0xca, 0x99, 0x15, 0x25, 0x7f, 0x02,
+	0x38, 0xf9, 0x8f, 0x22, 0x04, 0xc2, 0x8a, 0x97, 0x48, 0x83, 0x38, 0x48, 0x23, 0x66, 0x6a, 0xf2,
+	0x01, 0xc6, 0x99, 0x28, 0x14, 0x36, 0x74, 0x10, 0x07, 0xe9, 0x6c, 0x49, 0x8f, 0x6d, 0xcf, 0x0d,
+	0xcf, 0x9c, 0x8e, 0xbc, 0x80, 0xd1, 0x4d, 0x87, 0x1d, 0xd2, 0x61, 0x1c, 0xa4, 0xcf, 0x98, 0x05,
+	0xe4, 0x1d, 0x8c, 0xdb, 0xbe, 0x2d, 0xe4, 0x35, 0x0d, 0x8d, 0xcf, 0xdc, 0xf9, 0xac, 0xcd, 0x4f,
+	0xdd, 0xfe, 0xe2, 0x09, 0x73, 0x12, 0xf2, 0x16, 0xc2, 0x4c, 0x14, 0x48, 0x47, 0x46, 0x7a, 0xe2,
+	0xa4, 0xe7, 0xa2, 0x40, 0x27, 0x34, 0x34, 0x59, 0xc0, 0xe4, 0x0e, 0x37, 0x3b, 0x29, 0x73, 0x3a,
+	0x36, 0x4a, 0xe2, 0x94, 0xbf, 0xec, 0x5f, 0x27, 0xf6, 0xa2, 0x6f, 0x63, 0x08, 0xf5, 0xf2, 0xc9,
+	0x17, 0x98, 0x1f, 0x8d, 0x4f, 0x9e, 0xc3, 0xb0, 0x6b, 0x0a, 0xb7, 0xbb, 0x2e, 0xf5, 0x22, 0xaa,
+	0xaf, 0xb1, 0xa5, 0x83, 0x78, 0x98, 0x46, 0xcc, 0x82, 0xe4, 0x06, 0xe0, 0xdf, 0xcc, 0x84, 0xc2,
+	0xa4, 0x42, 0x75, 0x27, 0x9b, 0xdc, 0xbd, 0xf4, 0x50, 0x87, 0xc9, 0xb7, 0x5b, 0x1b, 0x5b, 0xc4,
+	0x4c, 0x4d, 0x4e, 0x61, 0x9a, 0xf1, 0x2b, 0x51, 0x08, 0xd5, 0xbb, 0x74, 0x1e, 0x30, 0x79, 0x05,
+	0x53, 0x5e, 0xd7, 0x97, 0xe6, 0x00, 0xa1, 0xb5, 0xe2, 0x75, 0xfd, 0x83, 0x97, 0x98, 0xfc, 0x84,
+	0xa9, 0xdf, 0x5d, 0xdb, 0xd6, 0x5c, 0xed, 0xfc, 0x8d, 0x74, 0xad, 0x9f, 0x96, 0x7c, 0x7f, 0xd9,
+	0x8a, 0x7b, 0x34, 0xed, 0x42, 0x36, 0x29, 0xf9, 0x7e, 0x2d, 0xee, 0x91, 0xbc, 0x86, 0x48, 0x53,
+	0x3a, 0xae, 0xd6, 0xb7, 0x2c, 0xf9, 0x5e, 0xdb, 0xb5, 0xc9, 0xef, 0x00, 0x66, 0x07, 0x51, 0x3d,
+	0x12, 0xc1, 0x67, 0x98, 0xec, 0x90, 0x6f, 0xb1, 0xb1, 0x21, 0xcc, 0x96, 0x6f, 0x8e, 0x13, 0x5e,
+	0x5c, 0x58, 0xc5, 0xaa, 0x52, 0x4d, 0xcf, 0xbc, 0x5e, 0x27, 0xd3, 0xa0, 0x6a, 0xc4, 0x43, 0x5f,
+	0x0f, 0x35, 0xb3, 0xe1, 0x57, 0xb9, 0xcc, 0x32, 0xbf, 0xa8, 0x83, 0x9a, 0x51, 0xa2, 0x44, 0xd9,
+	0x29, 0x73, 0xfa, 0x88, 0x79, 0x78, 0x7a, 0x06, 0x4f, 0x0f, 0xdb, 0xe8, 0x51, 0x73, 0xec, 0xfd,
+	0xa8, 0x39, 0xf6, 0xfa, 0x5a, 0xb7, 0xbc, 0xe8, 0xd0, 0x05, 0x6e, 0xc1, 0xd9, 0xe0, 0x53, 0xb0,
+	0x19, 0x9b, 0x91, 0x3f, 0xfe, 0x1d, 0x00, 0x6b, 0x4b, 0xd6, 0x6b, 0x50, 0x03, 0x00, 0x00,
+}
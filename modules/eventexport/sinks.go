@@ -0,0 +1,300 @@
+/* sinks.go: the places eventexport can send events
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package eventexport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hpc/kraken/lib"
+	pb "github.com/hpc/kraken/modules/eventexport/proto"
+)
+
+// A sink sends records somewhere.  Export is only ever called from one goroutine at a time.
+type sink interface {
+	Export(*Record) error
+	Close() error
+}
+
+/*
+ * syslog
+ */
+
+// syslogSDID is the RFC 5424 structured data ID we use; 32473 is the enterprise number reserved for examples
+const syslogSDID = "kraken@32473"
+
+// syslog severities
+const (
+	syslogNotice = 5
+	syslogInfo   = 6
+)
+
+var _ sink = (*syslogSink)(nil)
+
+// syslogSink sends RFC 5424 messages.  Stream connections use RFC 6587 octet counting.
+type syslogSink struct {
+	network  string
+	addr     string
+	facility uint32
+	app      string
+	host     string
+	pid      int
+	conn     net.Conn
+}
+
+func newSyslogSink(cfg *pb.SyslogSink) (s *syslogSink, e error) {
+	s = &syslogSink{
+		network:  cfg.GetNetwork(),
+		addr:     cfg.GetAddr(),
+		facility: cfg.GetFacility(),
+		app:      cfg.GetAppName(),
+		pid:      os.Getpid(),
+	}
+	switch s.network {
+	case "udp", "tcp", "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %s", s.network)
+	}
+	if s.facility > 23 {
+		return nil, fmt.Errorf("bad syslog facility: %d", s.facility)
+	}
+	if s.app == "" {
+		s.app = "kraken"
+	}
+	if s.host, e = os.Hostname(); e != nil || s.host == "" {
+		s.host = "-"
+	}
+	return s, nil
+}
+
+func (s *syslogSink) Export(r *Record) (e error) {
+	msg := s.format(r)
+	if s.network == "tcp" || s.network == "unix" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	// if the connection went away, try once to get it back
+	for try := 0; try < 2; try++ {
+		if s.conn == nil {
+			if s.conn, e = net.DialTimeout(s.network, s.addr, 5*time.Second); e != nil {
+				s.conn = nil
+				return
+			}
+		}
+		if _, e = s.conn.Write([]byte(msg)); e == nil {
+			return
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return
+}
+
+func (s *syslogSink) Close() (e error) {
+	if s.conn != nil {
+		e = s.conn.Close()
+		s.conn = nil
+	}
+	return
+}
+
+// format makes an RFC 5424 message:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID seq="" node="" url="" value=""] MSG
+func (s *syslogSink) format(r *Record) string {
+	sev := uint32(syslogInfo)
+	if r.Type == lib.EventTypeString[lib.Event_STATE_MUTATION] {
+		sev = syslogNotice
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s [%s seq=\"%d\" node=\"%s\" url=\"%s\" value=\"%s\"] %s",
+		s.facility*8+sev,
+		r.Time.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		s.host,
+		s.app,
+		s.pid,
+		r.Type,
+		syslogSDID,
+		r.Seq,
+		syslogParam(r.NodeId),
+		syslogParam(r.URL),
+		syslogParam(r.Value),
+		r.Data)
+}
+
+// syslogParam escapes a structured data parameter value
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+func syslogParam(v string) string { return syslogParamEscaper.Replace(v) }
+
+/*
+ * JSON-lines file
+ */
+
+var _ sink = (*fileSink)(nil)
+
+// fileSink writes one JSON record per line, rotating like logrotate: path, path.1, path.2, ...
+type fileSink struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	f        *os.File
+	size     int64
+}
+
+func newFileSink(cfg *pb.FileSink) (s *fileSink, e error) {
+	s = &fileSink{
+		path:     cfg.GetPath(),
+		maxSize:  int64(cfg.GetMaxSize()),
+		maxFiles: int(cfg.GetMaxFiles()),
+	}
+	if s.path == "" {
+		return nil, fmt.Errorf("file sink needs a path")
+	}
+	if e = s.open(); e != nil {
+		return nil, e
+	}
+	return
+}
+
+func (s *fileSink) Export(r *Record) (e error) {
+	line, e := json.Marshal(r)
+	if e != nil {
+		return
+	}
+	line = append(line, '\n')
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if e = s.rotate(); e != nil {
+			return
+		}
+	}
+	n, e := s.f.Write(line)
+	s.size += int64(n)
+	return
+}
+
+func (s *fileSink) Close() error { return s.f.Close() }
+
+func (s *fileSink) open() (e error) {
+	if s.f, e = os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); e != nil {
+		return
+	}
+	fi, e := s.f.Stat()
+	if e != nil {
+		s.f.Close()
+		return
+	}
+	s.size = fi.Size()
+	return
+}
+
+func (s *fileSink) rotate() (e error) {
+	s.f.Close()
+	if s.maxFiles < 1 {
+		os.Remove(s.path)
+	} else {
+		for i := s.maxFiles - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+		}
+		if e = os.Rename(s.path, s.path+".1"); e != nil {
+			return
+		}
+	}
+	return s.open()
+}
+
+/*
+ * HTTP webhook
+ */
+
+var _ sink = (*webhookSink)(nil)
+
+// webhookSink POSTs each record as JSON, retrying with exponential backoff
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	retries int
+	backoff time.Duration
+	client  *http.Client
+}
+
+func newWebhookSink(cfg *pb.WebhookSink) (s *webhookSink, e error) {
+	s = &webhookSink{
+		url:     cfg.GetUrl(),
+		headers: cfg.GetHeaders(),
+		retries: int(cfg.GetRetries()),
+		backoff: time.Second,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+	if s.url == "" {
+		return nil, fmt.Errorf("webhook sink needs a url")
+	}
+	if b := cfg.GetBackoff(); b != "" {
+		if s.backoff, e = time.ParseDuration(b); e != nil {
+			return nil, fmt.Errorf("bad webhook backoff: %v", e)
+		}
+	}
+	if t := cfg.GetTimeout(); t != "" {
+		if s.client.Timeout, e = time.ParseDuration(t); e != nil {
+			return nil, fmt.Errorf("bad webhook timeout: %v", e)
+		}
+	}
+	return
+}
+
+func (s *webhookSink) Export(r *Record) (e error) {
+	body, e := json.Marshal(r)
+	if e != nil {
+		return
+	}
+	delay := s.backoff
+	for try := 0; ; try++ {
+		var retry bool
+		if retry, e = s.post(body); e == nil || !retry || try >= s.retries {
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (s *webhookSink) Close() error { return nil }
+
+// post makes one attempt, and reports whether a failure is worth retrying
+func (s *webhookSink) post(body []byte) (retry bool, e error) {
+	req, e := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if e != nil {
+		return false, e
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, e := s.client.Do(req)
+	if e != nil {
+		return true, e
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	e = fmt.Errorf("webhook returned %s", resp.Status)
+	// client errors won't get better by trying again, except these
+	retry = resp.StatusCode >= 500 ||
+		resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode == http.StatusTooManyRequests
+	return
+}
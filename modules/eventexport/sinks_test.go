@@ -0,0 +1,310 @@
+package eventexport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/hpc/kraken/modules/eventexport/proto"
+)
+
+const testID = "123e4567-e89b-12d3-a456-426655440000"
+
+func testRecord(seq uint64) *Record {
+	return &Record{
+		Seq:    seq,
+		Time:   time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC),
+		Type:   "STATE_CHANGE",
+		NodeId: testID,
+		URL:    "/PhysState",
+		Value:  `POWER"ON]`,
+		Data:   "(UPDATE) /PhysState = POWER_ON",
+	}
+}
+
+// TestNewSyslogSink tests which syslog configs are rejected
+func TestNewSyslogSink(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *pb.SyslogSink
+		err  string
+	}{
+		{"udp", &pb.SyslogSink{Network: "udp", Addr: "localhost:514"}, ""},
+		{"unixgram", &pb.SyslogSink{Network: "unixgram", Addr: "/dev/log", Facility: 23}, ""},
+		{"no network", &pb.SyslogSink{Addr: "localhost:514"}, "unsupported syslog network"},
+		{"bad network", &pb.SyslogSink{Network: "sctp", Addr: "localhost:514"}, "unsupported syslog network: sctp"},
+		{"bad facility", &pb.SyslogSink{Network: "udp", Facility: 24}, "bad syslog facility: 24"},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			_, e := newSyslogSink(v.cfg)
+			if v.err == "" && e != nil {
+				t.Fatalf("a good config was rejected: %v", e)
+			}
+			if v.err != "" && (e == nil || !strings.Contains(e.Error(), v.err)) {
+				t.Fatalf("expected an error containing %q, got: %v", v.err, e)
+			}
+		})
+	}
+}
+
+// TestSyslogSink_UDP tests the RFC 5424 message format
+func TestSyslogSink_UDP(t *testing.T) {
+	l, e := net.ListenPacket("udp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer l.Close()
+	s, e := newSyslogSink(&pb.SyslogSink{Network: "udp", Addr: l.LocalAddr().String(), Facility: 16, AppName: "krakentest"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer s.Close()
+	if e = s.Export(testRecord(42)); e != nil {
+		t.Fatal(e)
+	}
+	l.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, e := l.ReadFrom(buf)
+	if e != nil {
+		t.Fatal(e)
+	}
+	// local0 (16) * 8 + info (6) = 134
+	expect := fmt.Sprintf(`<134>1 2019-06-01T12:00:00.000000Z %s krakentest %d STATE_CHANGE [kraken@32473 seq="42" node="%s" url="/PhysState" value="POWER\"ON\]"] (UPDATE) /PhysState = POWER_ON`,
+		s.host, os.Getpid(), testID)
+	if string(buf[:n]) != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s", expect, buf[:n])
+	}
+
+	r := testRecord(43)
+	r.Type = "STATE_MUTATION"
+	if e = s.Export(r); e != nil {
+		t.Fatal(e)
+	}
+	n, _, e = l.ReadFrom(buf)
+	if e != nil {
+		t.Fatal(e)
+	}
+	// notice (5) for mutations
+	if !strings.HasPrefix(string(buf[:n]), "<133>1 ") {
+		t.Errorf("expected a notice for a mutation, got: %s", buf[:n])
+	}
+}
+
+// TestSyslogSink_TCP tests octet counting, and that the sink reconnects if the connection goes away
+func TestSyslogSink_TCP(t *testing.T) {
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer l.Close()
+	s, e := newSyslogSink(&pb.SyslogSink{Network: "tcp", Addr: l.Addr().String()})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer s.Close()
+	read := func(c net.Conn) string {
+		c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var n int
+		br := bufio.NewReader(c)
+		if _, e := fmt.Fscanf(br, "%d ", &n); e != nil {
+			t.Fatalf("no octet count: %v", e)
+		}
+		msg := make([]byte, n)
+		if _, e := br.Read(msg); e != nil {
+			t.Fatal(e)
+		}
+		return string(msg)
+	}
+
+	if e = s.Export(testRecord(1)); e != nil {
+		t.Fatal(e)
+	}
+	c, e := l.Accept()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if m := read(c); !strings.HasSuffix(m, "= POWER_ON") || !strings.Contains(m, `seq="1"`) {
+		t.Errorf("unexpected message: %s", m)
+	}
+	c.Close()
+
+	// the first write after the server hangs up may succeed; keep exporting until the sink notices and reconnects
+	done := make(chan net.Conn)
+	go func() {
+		c, _ := l.Accept()
+		done <- c
+	}()
+	var c2 net.Conn
+	for seq := uint64(2); c2 == nil; seq++ {
+		s.Export(testRecord(seq))
+		select {
+		case c2 = <-done:
+		case <-time.After(100 * time.Millisecond):
+		}
+		if seq > 50 {
+			t.Fatal("the sink didn't reconnect")
+		}
+	}
+	defer c2.Close()
+	if m := read(c2); !strings.Contains(m, "STATE_CHANGE") {
+		t.Errorf("unexpected message after reconnecting: %s", m)
+	}
+}
+
+// TestFileSink tests JSON lines, and rotation
+func TestFileSink(t *testing.T) {
+	line, _ := json.Marshal(testRecord(1))
+	size := uint64(len(line) + 1)
+	tests := []struct {
+		name     string
+		maxSize  uint64
+		maxFiles uint32
+		export   int
+		lines    []int // lines in path, path.1, ...
+	}{
+		{"no rotation", 0, 0, 5, []int{5}},
+		{"rotate", 2 * size, 2, 5, []int{1, 2, 2}},
+		{"rotate, keeping only so many", 2 * size, 2, 9, []int{1, 2, 2}},
+		{"no old files", 2 * size, 0, 5, []int{1}},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.json")
+			s, e := newFileSink(&pb.FileSink{Path: path, MaxSize: v.maxSize, MaxFiles: v.maxFiles})
+			if e != nil {
+				t.Fatal(e)
+			}
+			for i := 1; i <= v.export; i++ {
+				if e = s.Export(testRecord(uint64(i))); e != nil {
+					t.Fatal(e)
+				}
+			}
+			if e = s.Close(); e != nil {
+				t.Fatal(e)
+			}
+			files, _ := filepath.Glob(path + "*")
+			if len(files) != len(v.lines) {
+				t.Fatalf("expected %d files, got %v", len(v.lines), files)
+			}
+			seq := uint64(v.export)
+			for i, n := range v.lines {
+				f := path
+				if i > 0 {
+					f = fmt.Sprintf("%s.%d", path, i)
+				}
+				b, e := ioutil.ReadFile(f)
+				if e != nil {
+					t.Fatal(e)
+				}
+				ls := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+				if len(ls) != n {
+					t.Fatalf("expected %d lines in %s, got %d", n, f, len(ls))
+				}
+				// newest records are in the newest files
+				for j := len(ls) - 1; j >= 0; j-- {
+					var r Record
+					if e := json.Unmarshal([]byte(ls[j]), &r); e != nil {
+						t.Fatalf("bad line in %s: %v", f, e)
+					}
+					if r.Seq != seq {
+						t.Errorf("expected record %d in %s, got %d", seq, f, r.Seq)
+					}
+					seq--
+				}
+			}
+		})
+	}
+
+	// a new sink appends, and rotates based on what's already there
+	path := filepath.Join(t.TempDir(), "events.json")
+	ioutil.WriteFile(path, line, 0644)
+	s, e := newFileSink(&pb.FileSink{Path: path, MaxSize: size, MaxFiles: 1})
+	if e != nil {
+		t.Fatal(e)
+	}
+	s.Export(testRecord(2))
+	s.Close()
+	if b, _ := ioutil.ReadFile(path + ".1"); string(b) != string(line) {
+		t.Errorf("the existing file wasn't rotated: %s", b)
+	}
+	if _, e := newFileSink(&pb.FileSink{}); e == nil {
+		t.Errorf("a file sink without a path was accepted")
+	}
+}
+
+// TestWebhookSink tests headers, and which failures are retried
+func TestWebhookSink(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // the server's responses, in order; after that it returns 200
+		retries  uint32
+		posts    int
+		ok       bool
+	}{
+		{"ok", nil, 3, 1, true},
+		{"retry server errors", []int{500, 503}, 3, 3, true},
+		{"retry timeouts and throttling", []int{408, 429}, 3, 3, true},
+		{"run out of retries", []int{500, 500, 500}, 2, 3, false},
+		{"don't retry client errors", []int{400}, 3, 1, false},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			var posts []Record
+			var mutex sync.Mutex
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" || req.Header.Get("Authorization") != "Bearer xyz" {
+					t.Errorf("bad request: %s %v", req.Method, req.Header)
+				}
+				var r Record
+				if e := json.NewDecoder(req.Body).Decode(&r); e != nil {
+					t.Errorf("bad body: %v", e)
+				}
+				posts = append(posts, r)
+				if len(posts) <= len(v.statuses) {
+					w.WriteHeader(v.statuses[len(posts)-1])
+				}
+			}))
+			defer srv.Close()
+			s, e := newWebhookSink(&pb.WebhookSink{
+				Url:     srv.URL,
+				Headers: map[string]string{"Authorization": "Bearer xyz"},
+				Retries: v.retries,
+				Backoff: "1ms",
+			})
+			if e != nil {
+				t.Fatal(e)
+			}
+			e = s.Export(testRecord(7))
+			if v.ok != (e == nil) {
+				t.Errorf("expected success %v, got: %v", v.ok, e)
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			if len(posts) != v.posts {
+				t.Fatalf("expected %d posts, got %d", v.posts, len(posts))
+			}
+			if posts[0].Seq != 7 || posts[0].NodeId != testID {
+				t.Errorf("unexpected record: %+v", posts[0])
+			}
+		})
+	}
+
+	for _, cfg := range []*pb.WebhookSink{{}, {Url: "http://localhost", Backoff: "x"}, {Url: "http://localhost", Timeout: "x"}} {
+		if _, e := newWebhookSink(cfg); e == nil {
+			t.Errorf("a bad config was accepted: %v", cfg)
+		}
+	}
+}
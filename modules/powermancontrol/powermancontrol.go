@@ -170,6 +170,31 @@ func (*PMC) ConfigURL() string {
 	return any.GetTypeUrl()
 }
 
+var _ lib.ModuleWithConfigValidation = (*PMC)(nil)
+
+// ValidateConfig makes sure the intervals are durations, and that each server has an ip and a port.
+// It doesn't try to reach the servers.
+func (*PMC) ValidateConfig(cfg proto.Message) error {
+	pcfg, ok := cfg.(*pb.PMCConfig)
+	if !ok {
+		return fmt.Errorf("invalid config type")
+	}
+	for _, f := range [][2]string{{"polling_interval", pcfg.GetPollingInterval()}, {"fire_interval", pcfg.GetFireInterval()}} {
+		if d, e := time.ParseDuration(f[1]); e != nil || d <= 0 {
+			return fmt.Errorf("%s must be a positive duration, not %q", f[0], f[1])
+		}
+	}
+	for name, srv := range pcfg.GetServers() {
+		if srv.GetName() != name {
+			return fmt.Errorf("server %q has the name %q", name, srv.GetName())
+		}
+		if srv.GetIp() == "" || srv.GetPort() <= 0 || srv.GetPort() > 65535 {
+			return fmt.Errorf("server %q needs an ip and a port", name)
+		}
+	}
+	return nil
+}
+
 /*
  * lib.ModuleWithMutations & lib.ModuleWithDiscovery
  */
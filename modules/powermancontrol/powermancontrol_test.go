@@ -0,0 +1,47 @@
+package powermancontrol
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+
+	pb "github.com/hpc/kraken/modules/powermancontrol/proto"
+)
+
+// TestPMC_ValidateConfig tests which configs are rejected
+func TestPMC_ValidateConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(*pb.PMCConfig)
+		err  string
+	}{
+		{"default", func(*pb.PMCConfig) {}, ""},
+		{"a server nothing listens on", func(c *pb.PMCConfig) { c.Servers["pmc"].Ip = "192.0.2.1" }, ""},
+		{"no servers", func(c *pb.PMCConfig) { c.Servers = nil }, ""},
+		{"bad polling interval", func(c *pb.PMCConfig) { c.PollingInterval = "often" }, `polling_interval must be a positive duration, not "often"`},
+		{"zero fire interval", func(c *pb.PMCConfig) { c.FireInterval = "0s" }, `fire_interval must be a positive duration, not "0s"`},
+		{"negative fire interval", func(c *pb.PMCConfig) { c.FireInterval = "-2s" }, "fire_interval must be a positive duration"},
+		{"misnamed server", func(c *pb.PMCConfig) { c.Servers["pmc"].Name = "other" }, `server "pmc" has the name "other"`},
+		{"no ip", func(c *pb.PMCConfig) { c.Servers["pmc"].Ip = "" }, `server "pmc" needs an ip and a port`},
+		{"no port", func(c *pb.PMCConfig) { c.Servers["pmc"].Port = 0 }, `server "pmc" needs an ip and a port`},
+		{"port out of range", func(c *pb.PMCConfig) { c.Servers["pmc"].Port = 65536 }, `server "pmc" needs an ip and a port`},
+	}
+	p := &PMC{}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			cfg := p.NewConfig().(*pb.PMCConfig)
+			v.edit(cfg)
+			e := p.ValidateConfig(cfg)
+			if v.err == "" && e != nil {
+				t.Fatalf("a good config was rejected: %v", e)
+			}
+			if v.err != "" && (e == nil || !strings.Contains(e.Error(), v.err)) {
+				t.Fatalf("expected an error containing %q, got: %v", v.err, e)
+			}
+		})
+	}
+	if e := p.ValidateConfig(&wrappers.StringValue{}); e == nil {
+		t.Errorf("a config of the wrong type was accepted")
+	}
+}
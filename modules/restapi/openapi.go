@@ -0,0 +1,326 @@
+/* openapi.go: generates an OpenAPI document for the /v1 API from its routes and the proto types
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package restapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/lib"
+
+	cpb "github.com/hpc/kraken/core/proto"
+)
+
+type jsonObject = map[string]interface{}
+
+// muxVarPattern matches mux path variables with a pattern, e.g. {url:.+}
+var muxVarPattern = regexp.MustCompile(`\{([^}:]+):[^}]+\}`)
+
+// v1Schemas are the schemas that aren't proto messages
+var v1Schemas = map[string]jsonObject{
+	"OpenAPI": {
+		"type":        "object",
+		"description": "an OpenAPI 3 document",
+	},
+	"NodePage": {
+		"type": "object",
+		"properties": jsonObject{
+			"nodes":         jsonObject{"type": "array", "items": schemaRef("proto.Node")},
+			"nextPageToken": jsonObject{"type": "string", "description": "the page_token of the next page; absent on the last page"},
+		},
+	},
+	"NodeList": {
+		"type": "object",
+		"properties": jsonObject{
+			"nodes": jsonObject{"type": "array", "items": schemaRef("proto.Node")},
+		},
+	},
+	"Patch": {
+		"description": "a JSON merge patch (" + lib.MergePatchType + ") object, or a JSON patch (" + lib.JSONPatchType + ") array",
+		"oneOf": []interface{}{
+			jsonObject{"type": "object"},
+			jsonObject{"type": "array", "items": schemaRef("JSONPatchOp")},
+		},
+	},
+	"JSONPatchOp": {
+		"type":     "object",
+		"required": []string{"op", "path"},
+		"properties": jsonObject{
+			"op":    jsonObject{"type": "string", "enum": []string{"add", "remove", "replace", "move", "copy", "test"}},
+			"path":  jsonObject{"type": "string"},
+			"from":  jsonObject{"type": "string"},
+			"value": jsonObject{},
+		},
+	},
+	"NodePatches": {
+		"type":                 "object",
+		"description":          "node IDs mapped to patches",
+		"additionalProperties": schemaRef("Patch"),
+	},
+	"ServiceConfig": {
+		"type":        "object",
+		"description": "the JSON of a service's module config; @type, if given, must name the module's config type",
+	},
+	"Value": {
+		"description": "a node value, as JSON; enums are given by name",
+	},
+	"Graph": {
+		"type": "object",
+		"properties": jsonObject{
+			"nodes": jsonObject{"type": "array", "items": schemaRef("proto.MutationNode")},
+			"edges": jsonObject{"type": "array", "items": schemaRef("proto.MutationEdge")},
+		},
+	},
+	"Frozen": {
+		"type": "object",
+		"properties": jsonObject{
+			"frozen": jsonObject{"type": "boolean"},
+		},
+	},
+	"Enumerables": {
+		"type": "array",
+		"items": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"name":    jsonObject{"type": "string"},
+				"url":     jsonObject{"type": "string"},
+				"options": jsonObject{"type": "object", "additionalProperties": jsonObject{"type": "string"}},
+			},
+		},
+	},
+	"WebSocketInfo": {
+		"type": "object",
+		"properties": jsonObject{
+			"host":   jsonObject{"type": "string"},
+			"port":   jsonObject{"type": "string"},
+			"url":    jsonObject{"type": "string"},
+			"scheme": jsonObject{"type": "string", "enum": []string{"ws", "wss"}},
+		},
+	},
+	"Metrics": {
+		"type":        "string",
+		"description": "metrics in the Prometheus text format",
+	},
+	"Error": {
+		"type": "object",
+		"properties": jsonObject{
+			"error": jsonObject{
+				"type": "object",
+				"properties": jsonObject{
+					"code":    jsonObject{"type": "integer"},
+					"status":  jsonObject{"type": "string"},
+					"message": jsonObject{"type": "string"},
+				},
+			},
+		},
+	},
+}
+
+func schemaRef(name string) jsonObject {
+	return jsonObject{"$ref": "#/components/schemas/" + name}
+}
+
+// openAPI builds the OpenAPI document of the /v1 API
+func (r *RestAPI) openAPI() jsonObject {
+	schemas := jsonObject{}
+	for name, s := range v1Schemas {
+		schemas[name] = s
+	}
+	// nodes, the mutation graph, and whatever extensions and module configs nodes can have in them
+	msgs := []proto.Message{&cpb.Node{}, &cpb.NodeList{}, &cpb.MutationNode{}, &cpb.MutationEdge{}, &cpb.MutationHistory{}, &cpb.ServiceStatusList{}}
+	for _, e := range core.Registry.Extensions {
+		msgs = append(msgs, e.New())
+	}
+	for _, m := range core.Registry.Modules {
+		if mc, ok := m.(lib.ModuleWithConfig); ok {
+			msgs = append(msgs, mc.NewConfig())
+		}
+	}
+	for _, m := range msgs {
+		protoSchemas(schemas, proto.MessageReflect(m).Descriptor())
+	}
+
+	paths := jsonObject{}
+	for _, rt := range v1Routes {
+		path := muxVarPattern.ReplaceAllString(rt.path, "{$1}")
+		item, ok := paths[path].(jsonObject)
+		if !ok {
+			item = jsonObject{}
+			paths[path] = item
+		}
+		item[strings.ToLower(rt.method)] = rt.operation()
+	}
+
+	return jsonObject{
+		"openapi": "3.0.3",
+		"info": jsonObject{
+			"title":   "kraken",
+			"version": "v1",
+		},
+		"servers":    []interface{}{jsonObject{"url": V1Prefix}},
+		"paths":      paths,
+		"components": jsonObject{"schemas": schemas},
+		"security":   []interface{}{jsonObject{}, jsonObject{"bearer": []string{}}},
+	}
+}
+
+// operation is the OpenAPI operation object of a route
+func (rt v1Route) operation() jsonObject {
+	op := jsonObject{
+		"operationId": rt.id,
+		"summary":     rt.summary,
+	}
+	var params []interface{}
+	for _, m := range regexp.MustCompile(`\{([^}:]+)`).FindAllStringSubmatch(rt.path, -1) {
+		params = append(params, jsonObject{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   jsonObject{"type": "string"},
+		})
+	}
+	for _, q := range rt.query {
+		p := v1Params[q]
+		params = append(params, jsonObject{
+			"name":        q,
+			"in":          "query",
+			"description": p.description,
+			"schema":      p.schema,
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if rt.request != "" {
+		content := jsonObject{"application/json": jsonObject{"schema": schemaRef(rt.request)}}
+		if rt.request == "Patch" || rt.request == "NodePatches" {
+			content[lib.MergePatchType] = jsonObject{"schema": schemaRef(rt.request)}
+			content[lib.JSONPatchType] = jsonObject{"schema": schemaRef(rt.request)}
+		}
+		op["requestBody"] = jsonObject{"required": true, "content": content}
+	}
+	code := rt.status
+	if code == 0 {
+		code = http.StatusOK
+	}
+	mediaType := "application/json"
+	if rt.response == "Metrics" {
+		mediaType = "text/plain"
+	}
+	op["responses"] = jsonObject{
+		strconv.Itoa(code): jsonObject{
+			"description": http.StatusText(code),
+			"content":     jsonObject{mediaType: jsonObject{"schema": schemaRef(rt.response)}},
+		},
+		"default": jsonObject{
+			"description": "an error",
+			"content":     jsonObject{"application/json": jsonObject{"schema": schemaRef("Error")}},
+		},
+	}
+	return op
+}
+
+// protoSchemas adds the schema of a message, and of every message it uses, as it marshals to JSON
+func protoSchemas(schemas jsonObject, md protoreflect.MessageDescriptor) {
+	name := string(md.FullName())
+	if _, ok := schemas[name]; ok {
+		return
+	}
+	if s, ok := wellKnownSchema(name); ok {
+		schemas[name] = s
+		return
+	}
+	props := jsonObject{}
+	s := jsonObject{"type": "object", "properties": props}
+	// placeholder, so recursive messages terminate
+	schemas[name] = s
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		var fs jsonObject
+		switch {
+		case fd.IsMap():
+			fs = jsonObject{"type": "object", "additionalProperties": fieldSchema(schemas, fd.MapValue())}
+		case fd.IsList():
+			fs = jsonObject{"type": "array", "items": fieldSchema(schemas, fd)}
+		default:
+			fs = fieldSchema(schemas, fd)
+		}
+		props[fd.JSONName()] = fs
+	}
+}
+
+// fieldSchema is the schema of a single (not repeated) value of a field
+func fieldSchema(schemas jsonObject, fd protoreflect.FieldDescriptor) jsonObject {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return jsonObject{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return jsonObject{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return jsonObject{"type": "integer", "format": "uint32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// 64 bit integers are strings in JSON
+		return jsonObject{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return jsonObject{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return jsonObject{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return jsonObject{"type": "number", "format": "double"}
+	case protoreflect.StringKind:
+		return jsonObject{"type": "string"}
+	case protoreflect.BytesKind:
+		return jsonObject{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		vals := fd.Enum().Values()
+		names := make([]string, vals.Len())
+		for i := range names {
+			names[i] = string(vals.Get(i).Name())
+		}
+		sort.Strings(names)
+		return jsonObject{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		protoSchemas(schemas, fd.Message())
+		return schemaRef(string(fd.Message().FullName()))
+	}
+	return jsonObject{}
+}
+
+// wellKnownSchema gives the schemas of well known types that have special JSON forms
+func wellKnownSchema(name string) (jsonObject, bool) {
+	switch name {
+	case "google.protobuf.Any":
+		return jsonObject{
+			"type":                 "object",
+			"description":          "an extension or config; @type names which, and the rest of its fields are inline",
+			"properties":           jsonObject{"@type": jsonObject{"type": "string"}},
+			"additionalProperties": true,
+		}, true
+	case "google.protobuf.Timestamp":
+		return jsonObject{"type": "string", "format": "date-time"}, true
+	case "google.protobuf.Duration":
+		return jsonObject{"type": "string", "example": "1.5s"}, true
+	case "google.protobuf.Empty":
+		return jsonObject{"type": "object"}, true
+	case "google.protobuf.Struct":
+		return jsonObject{"type": "object", "additionalProperties": true}, true
+	case "google.protobuf.Value":
+		return jsonObject{}, true
+	}
+	return nil, false
+}
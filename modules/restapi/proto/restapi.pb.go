@@ -3,9 +3,11 @@
 
 package proto
 
-import proto "github.com/golang/protobuf/proto"
-import fmt "fmt"
-import math "math"
+import (
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	math "math"
+)
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
@@ -16,11 +18,17 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 // A compilation error at this line likely means your copy of the
 // proto package needs to be updated.
-const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type RestAPIConfig struct {
 	Addr                 string   `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
 	Port                 int32    `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+	TlsCert              string   `protobuf:"bytes,3,opt,name=tls_cert,json=tlsCert,proto3" json:"tls_cert,omitempty"`
+	TlsKey               string   `protobuf:"bytes,4,opt,name=tls_key,json=tlsKey,proto3" json:"tls_key,omitempty"`
+	ClientCa             string   `protobuf:"bytes,5,opt,name=client_ca,json=clientCa,proto3" json:"client_ca,omitempty"`
+	RequireClientCert    bool     `protobuf:"varint,6,opt,name=require_client_cert,json=requireClientCert,proto3" json:"require_client_cert,omitempty"`
+	TokenFile            string   `protobuf:"bytes,7,opt,name=token_file,json=tokenFile,proto3" json:"token_file,omitempty"`
+	AllowedOrigins       []string `protobuf:"bytes,8,rep,name=allowed_origins,json=allowedOrigins,proto3" json:"allowed_origins,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -30,16 +38,17 @@ func (m *RestAPIConfig) Reset()         { *m = RestAPIConfig{} }
 func (m *RestAPIConfig) String() string { return proto.CompactTextString(m) }
 func (*RestAPIConfig) ProtoMessage()    {}
 func (*RestAPIConfig) Descriptor() ([]byte, []int) {
-	return fileDescriptor_restapi_904346250fab119c, []int{0}
+	return fileDescriptor_40fe3c1ccbe469e7, []int{0}
 }
+
 func (m *RestAPIConfig) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_RestAPIConfig.Unmarshal(m, b)
 }
 func (m *RestAPIConfig) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_RestAPIConfig.Marshal(b, m, deterministic)
 }
-func (dst *RestAPIConfig) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_RestAPIConfig.Merge(dst, src)
+func (m *RestAPIConfig) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RestAPIConfig.Merge(m, src)
 }
 func (m *RestAPIConfig) XXX_Size() int {
 	return xxx_messageInfo_RestAPIConfig.Size(m)
@@ -64,18 +73,69 @@ func (m *RestAPIConfig) GetPort() int32 {
 	return 0
 }
 
+func (m *RestAPIConfig) GetTlsCert() string {
+	if m != nil {
+		return m.TlsCert
+	}
+	return ""
+}
+
+func (m *RestAPIConfig) GetTlsKey() string {
+	if m != nil {
+		return m.TlsKey
+	}
+	return ""
+}
+
+func (m *RestAPIConfig) GetClientCa() string {
+	if m != nil {
+		return m.ClientCa
+	}
+	return ""
+}
+
+func (m *RestAPIConfig) GetRequireClientCert() bool {
+	if m != nil {
+		return m.RequireClientCert
+	}
+	return false
+}
+
+func (m *RestAPIConfig) GetTokenFile() string {
+	if m != nil {
+		return m.TokenFile
+	}
+	return ""
+}
+
+func (m *RestAPIConfig) GetAllowedOrigins() []string {
+	if m != nil {
+		return m.AllowedOrigins
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*RestAPIConfig)(nil), "proto.RestAPIConfig")
 }
 
-func init() { proto.RegisterFile("restapi.proto", fileDescriptor_restapi_904346250fab119c) }
+func init() { proto.RegisterFile("restapi.proto", fileDescriptor_40fe3c1ccbe469e7) }
 
-var fileDescriptor_restapi_904346250fab119c = []byte{
-	// 96 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2d, 0x4a, 0x2d, 0x2e,
-	0x49, 0x2c, 0xc8, 0xd4, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x05, 0x53, 0x4a, 0xe6, 0x5c,
-	0xbc, 0x41, 0xa9, 0xc5, 0x25, 0x8e, 0x01, 0x9e, 0xce, 0xf9, 0x79, 0x69, 0x99, 0xe9, 0x42, 0x42,
-	0x5c, 0x2c, 0x89, 0x29, 0x29, 0x45, 0x12, 0x8c, 0x0a, 0x8c, 0x1a, 0x9c, 0x41, 0x60, 0x36, 0x48,
-	0xac, 0x20, 0xbf, 0xa8, 0x44, 0x82, 0x49, 0x81, 0x51, 0x83, 0x35, 0x08, 0xcc, 0x4e, 0x62, 0x03,
-	0xeb, 0x37, 0x06, 0x04, 0x00, 0x00, 0xff, 0xff, 0x50, 0x94, 0x34, 0x66, 0x57, 0x00, 0x00, 0x00,
+var fileDescriptor_40fe3c1ccbe469e7 = []byte{
+	// 232 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x34, 0x90, 0xc1, 0x4a, 0xc4, 0x30,
+	0x10, 0x86, 0xe9, 0xee, 0xb6, 0xdb, 0x0e, 0xac, 0x62, 0x3c, 0x18, 0x11, 0xa1, 0x78, 0xb1, 0xa7,
+	0xbd, 0xf8, 0x04, 0x52, 0x10, 0xc4, 0x83, 0x92, 0x17, 0x08, 0xb1, 0x9d, 0x5d, 0xc2, 0x86, 0xa6,
+	0x4e, 0x46, 0x64, 0x6f, 0x3e, 0xba, 0x74, 0x5a, 0x4f, 0xf9, 0xf9, 0xfe, 0x8f, 0x3f, 0x30, 0xb0,
+	0x23, 0x4c, 0xec, 0x46, 0xbf, 0x1f, 0x29, 0x72, 0x54, 0xb9, 0x3c, 0x0f, 0xbf, 0x2b, 0xd8, 0x19,
+	0x4c, 0xfc, 0xfc, 0xf1, 0xda, 0xc6, 0xe1, 0xe0, 0x8f, 0x4a, 0xc1, 0xc6, 0xf5, 0x3d, 0xe9, 0xac,
+	0xce, 0x9a, 0xca, 0x48, 0x9e, 0xd8, 0x18, 0x89, 0xf5, 0xaa, 0xce, 0x9a, 0xdc, 0x48, 0x56, 0xb7,
+	0x50, 0x72, 0x48, 0xb6, 0x43, 0x62, 0xbd, 0x16, 0x77, 0xcb, 0x21, 0xb5, 0x48, 0xac, 0x6e, 0x60,
+	0x8a, 0xf6, 0x84, 0x67, 0xbd, 0x91, 0xa6, 0xe0, 0x90, 0xde, 0xf0, 0xac, 0xee, 0xa0, 0xea, 0x82,
+	0xc7, 0x81, 0x6d, 0xe7, 0x74, 0x2e, 0x55, 0x39, 0x83, 0xd6, 0xa9, 0x3d, 0x5c, 0x13, 0x7e, 0x7d,
+	0x7b, 0x42, 0xfb, 0x2f, 0x4d, 0xdb, 0x45, 0x9d, 0x35, 0xa5, 0xb9, 0x5a, 0xaa, 0x76, 0xb6, 0xa7,
+	0x5f, 0xee, 0x01, 0x38, 0x9e, 0x70, 0xb0, 0x07, 0x1f, 0x50, 0x6f, 0x65, 0xad, 0x12, 0xf2, 0xe2,
+	0x03, 0xaa, 0x47, 0xb8, 0x74, 0x21, 0xc4, 0x1f, 0xec, 0x6d, 0x24, 0x7f, 0xf4, 0x43, 0xd2, 0x65,
+	0xbd, 0x6e, 0x2a, 0x73, 0xb1, 0xe0, 0xf7, 0x99, 0x7e, 0x16, 0x72, 0x89, 0xa7, 0xbf, 0x01, 0x00,
+	0xe1, 0x85, 0xea, 0x3a, 0x21, 0x01, 0x00, 0x00,
 }
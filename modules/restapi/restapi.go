@@ -16,17 +16,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net"
 	"net/http"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"github.com/hpc/kraken/core"
 	"github.com/hpc/kraken/lib"
 
@@ -38,6 +44,9 @@ var _ lib.Module = (*RestAPI)(nil)
 var _ lib.ModuleSelfService = (*RestAPI)(nil)
 var _ lib.ModuleWithConfig = (*RestAPI)(nil)
 
+// stopTimeout is how long Stop waits for requests in progress to finish
+const stopTimeout = 5 * time.Second
+
 type RestAPI struct {
 	cfg    *pb.RestAPIConfig
 	api    lib.APIClient
@@ -54,6 +63,21 @@ type Frozen struct {
 	Frozen bool `json:"frozen"`
 }
 
+// WebSocketInfo says where to connect to the websocket service
+type WebSocketInfo struct {
+	Host   string `json:"host"`
+	Port   string `json:"port"`
+	URL    string `json:"url"`
+	Scheme string `json:"scheme"`
+}
+
+// An Enumerable is an enum that can be set in a node, and its options
+type Enumerable struct {
+	Name    string           `json:"name"`
+	Url     string           `json:"url"`
+	Options map[int32]string `json:"options"` // 0:"NONE"
+}
+
 func (r *RestAPI) Entry() {
 	r.setupRouter()
 	for {
@@ -61,7 +85,15 @@ func (r *RestAPI) Entry() {
 	}
 }
 
-func (r *RestAPI) Stop() { os.Exit(0) }
+// Stop lets requests in progress finish, for up to stopTimeout, then exits
+func (r *RestAPI) Stop() {
+	if r.srv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+		r.srvStop(ctx)
+		cancel()
+	}
+	os.Exit(0)
+}
 
 func (r *RestAPI) Name() string { return "github.com/hpc/kraken/modules/restapi" }
 
@@ -69,7 +101,7 @@ func (r *RestAPI) UpdateConfig(cfg proto.Message) (e error) {
 	if rc, ok := cfg.(*pb.RestAPIConfig); ok {
 		r.cfg = rc
 		if r.srv != nil {
-			r.srvStop() // we just stop, entry will (re)start
+			r.srvStop(context.Background()) // we just stop, entry will (re)start
 		}
 		return
 	}
@@ -100,6 +132,7 @@ func (r *RestAPI) setupRouter() {
 	r.router.HandleFunc("/cfg/nodes", r.readAll).Methods("GET")
 	r.router.HandleFunc("/cfg/nodes", r.updateMulti).Methods("PUT")
 	r.router.HandleFunc("/cfg/nodes", r.createMulti).Methods("POST")
+	r.router.HandleFunc("/cfg/nodes", r.patchMulti).Methods("PATCH")
 	r.router.HandleFunc("/dsc/nodes", r.readAllDsc).Methods("GET")
 	r.router.HandleFunc("/dsc/nodes", r.updateMultiDsc).Methods("PUT")
 	r.router.HandleFunc("/cfg/node/{id}", r.readNode).Methods("GET")
@@ -110,30 +143,58 @@ func (r *RestAPI) setupRouter() {
 	r.router.HandleFunc("/dsc/node/{id}", r.readNodeDsc).Methods("GET")
 	r.router.HandleFunc("/cfg/node", r.updateNode).Methods("PUT")
 	r.router.HandleFunc("/cfg/node/{id}", r.updateNode).Methods("PUT")
+	r.router.HandleFunc("/cfg/node/{id}", r.patchNode).Methods("PATCH")
+	r.router.HandleFunc("/cfg/node/{id}/value/{url:.+}", r.readValue).Methods("GET")
+	r.router.HandleFunc("/cfg/node/{id}/value/{url:.+}", r.updateValue).Methods("PUT")
 	r.router.HandleFunc("/dsc/node", r.updateNodeDsc).Methods("PUT")
 	r.router.HandleFunc("/dsc/node/{id}", r.updateNodeDsc).Methods("PUT")
 	r.router.HandleFunc("/graph/json", r.readGraphJSON).Methods("GET")
 	r.router.HandleFunc("/graph/node/{id}/json", r.readNodeGraphJSON).Methods("GET")
+	r.router.HandleFunc("/graph/node/{id}/history", r.readNodeHistory).Methods("GET")
 	r.router.HandleFunc("/enumerables", r.getAllEnums).Methods("GET")
 	r.router.HandleFunc("/ws", r.webSocketRedirect).Methods("GET")
 	r.router.HandleFunc("/sme/freeze", r.freeze).Methods("GET")
 	r.router.HandleFunc("/sme/thaw", r.thaw).Methods("GET")
 	r.router.HandleFunc("/sme/frozen", r.frozen).Methods("GET")
+	r.router.HandleFunc("/metrics", r.metrics).Methods("GET")
+	r.setupV1()
 }
 
 func (r *RestAPI) startServer() {
+	sec, e := lib.NewHTTPSecurity(lib.HTTPSecurityConfig{
+		CertFile:          r.cfg.TlsCert,
+		KeyFile:           r.cfg.TlsKey,
+		ClientCAFile:      r.cfg.ClientCa,
+		RequireClientCert: r.cfg.RequireClientCert,
+		TokenFile:         r.cfg.TokenFile,
+		AllowedOrigins:    r.cfg.AllowedOrigins,
+	})
+	if e != nil {
+		// we don't serve anything insecurely; wait and try again, maybe with a new config
+		r.api.Logf(lib.LLERROR, "restapi can't be secured as configured: %v", e)
+		r.srv = nil
+		time.Sleep(10 * time.Second)
+		return
+	}
 	r.srv = &http.Server{
+		// CORS answers preflight requests, which don't carry credentials, before we authenticate
 		Handler: handlers.CORS(
 			handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}),
-			handlers.AllowedOrigins([]string{"*"}),
-			handlers.AllowedMethods([]string{"PUT", "GET", "POST", "DELETE"}),
-		)(r.router),
+			handlers.AllowedOriginValidator(sec.AllowedOrigin),
+			handlers.AllowedMethods([]string{"PUT", "GET", "POST", "DELETE", "PATCH"}),
+		)(sec.Handler(r.router)),
 		Addr:         fmt.Sprintf("%s:%d", r.cfg.Addr, r.cfg.Port),
+		TLSConfig:    sec.TLSConfig(),
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 	}
-	r.api.Logf(lib.LLINFO, "restapi is listening on: %s\n", r.srv.Addr)
-	if e := r.srv.ListenAndServe(); e != nil {
+	r.api.Logf(lib.LLINFO, "restapi is listening on: %s://%s\n", sec.Scheme(), r.srv.Addr)
+	if sec.TLSConfig() != nil {
+		e = r.srv.ListenAndServeTLS("", "")
+	} else {
+		e = r.srv.ListenAndServe()
+	}
+	if e != nil {
 		if e != http.ErrServerClosed {
 			r.api.Logf(lib.LLNOTICE, "http stopped: %v\n", e)
 		}
@@ -141,9 +202,101 @@ func (r *RestAPI) startServer() {
 	r.api.Log(lib.LLNOTICE, "restapi listener stopped")
 }
 
-func (r *RestAPI) srvStop() {
+// apiFor gives an API client that makes calls on behalf of whoever made a request,
+// so kraken can enforce its authorization policy
+func (r *RestAPI) apiFor(req *http.Request) lib.APIClient {
+	who := lib.HTTPPrincipal(req)
+	if who == "" {
+		who = core.AnonymousPrincipal
+	}
+	return r.api.WithPrincipal(who)
+}
+
+// writeError writes an error response; calls the authorization policy denied are always 403 Forbidden
+func writeError(w http.ResponseWriter, code int, e error) {
+	if status.Code(e) == codes.PermissionDenied {
+		code = http.StatusForbidden
+	}
+	w.WriteHeader(code)
+	if e != nil {
+		w.Write([]byte(e.Error()))
+	}
+}
+
+// applyPatch patches the JSON form of a node; the patch can't change the node's ID
+func applyPatch(n lib.Node, patch []byte, contentType string) (lib.Node, error) {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	j, e := lib.Patch(n.JSON(), patch, mt)
+	if e != nil {
+		return nil, e
+	}
+	var m cpb.Node
+	if e = core.UnmarshalJSON(j, &m); e != nil {
+		return nil, fmt.Errorf("patched node is not valid: %v", e)
+	}
+	pn := core.NewNodeFromMessage(&m)
+	if !pn.ID().Equal(n.ID()) {
+		return nil, fmt.Errorf("a patch can't change a node's id")
+	}
+	return pn, nil
+}
+
+// patchRetries is how many times a patch without If-Match starts over when the node changes under it
+const patchRetries = 3
+
+// etag is the ETag header of a node: its version, quoted
+func etag(n lib.Node) string { return strconv.Quote(core.NodeVersion(n)) }
+
+// ifMatch gets the node version a request is conditional on, or "" for none
+func ifMatch(req *http.Request) string {
+	m := strings.TrimPrefix(req.Header.Get("If-Match"), "W/")
+	if m == "*" {
+		return ""
+	}
+	if u, e := strconv.Unquote(m); e == nil {
+		return u
+	}
+	return m
+}
+
+// patchUpdate reads, patches and updates a node, and the update only happens if the node hasn't
+// changed since it was read.  If match is set, the node must be at that version to begin with;
+// otherwise, a node that changed gets read and patched again.  It returns an HTTP status for errors.
+func patchUpdate(api lib.APIClient, id string, patch []byte, contentType, match string) (lib.Node, int, error) {
+	for i := 0; ; i++ {
+		n, e := api.QueryRead(id)
+		if e == nil && n == nil {
+			e = fmt.Errorf("no such node: %s", id)
+		}
+		if e != nil {
+			return nil, http.StatusNotFound, e
+		}
+		version := core.NodeVersion(n)
+		if match != "" && match != version {
+			return nil, http.StatusPreconditionFailed, core.ErrNodeVersion
+		}
+		pn, e := applyPatch(n, patch, contentType)
+		if e != nil {
+			return nil, http.StatusBadRequest, e
+		}
+		nn, e := api.QueryUpdateIf(pn, version)
+		switch {
+		case status.Code(e) == codes.Aborted && match != "":
+			return nil, http.StatusPreconditionFailed, core.ErrNodeVersion
+		case status.Code(e) == codes.Aborted && i < patchRetries:
+			continue
+		case status.Code(e) == codes.Aborted:
+			return nil, http.StatusConflict, e
+		case e != nil:
+			return nil, http.StatusBadRequest, e
+		}
+		return nn, http.StatusOK, nil
+	}
+}
+
+func (r *RestAPI) srvStop(ctx context.Context) {
 	r.api.Log(lib.LLDEBUG, "restapi is shutting down listener")
-	r.srv.Shutdown(context.Background())
+	r.srv.Shutdown(ctx)
 }
 
 /*
@@ -153,25 +306,49 @@ func (r *RestAPI) srvStop() {
 func (r *RestAPI) webSocketRedirect(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 	host, _, _ := net.SplitHostPort(req.Host)
-	nself, _ := r.api.QueryRead(r.api.Self().String())
+	response, code, e := r.webSocketInfo(r.apiFor(req), host, true)
+	if e != nil {
+		writeError(w, code, e)
+		return
+	}
+
+	json, err := json.Marshal(response)
+	if err != nil {
+		r.api.Logf(lib.LLERROR, "Error marshaling response")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Write(json)
+	r.api.Logf(lib.LLDDDEBUG, "Websocket redirecting to: %v", string(json))
+}
+
+// webSocketInfo says where to find the websocket service.
+// If it isn't running, we start it if start is set; if not, that's a 503 Service Unavailable.
+func (r *RestAPI) webSocketInfo(api lib.APIClient, host string, start bool) (*WebSocketInfo, int, error) {
+	nself, e := api.QueryRead(r.api.Self().String())
+	if e != nil {
+		r.api.Logf(lib.LLERROR, "Error reading self: %v", e)
+		return nil, http.StatusInternalServerError, e
+	}
 
 	// Check if websocket module is running
 	wserv := nself.GetService("websocket")
 	if wserv == nil {
 		r.api.Logf(lib.LLERROR, "Could not find websocket service")
-		w.WriteHeader(http.StatusNotFound)
-		return
+		return nil, http.StatusNotFound, fmt.Errorf("no websocket service")
 	}
 
 	if wserv.GetState() != cpb.ServiceInstance_RUN {
+		if !start {
+			return nil, http.StatusServiceUnavailable, fmt.Errorf("the websocket service is not running")
+		}
 		r.api.Logf(lib.LLDEBUG, "Got websocket request, but websocket module isn't running. Attempting to start it now")
 		wserv.State = cpb.ServiceInstance_RUN
 
-		_, e := r.api.QueryUpdate(nself)
+		_, e := api.QueryUpdate(nself)
 		if e != nil {
 			r.api.Logf(lib.LLERROR, "Error updating cfg to start websocket service")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return nil, http.StatusInternalServerError, e
 		}
 	}
 
@@ -179,35 +356,28 @@ func (r *RestAPI) webSocketRedirect(w http.ResponseWriter, req *http.Request) {
 	wsPort, e := nself.GetValue("/Services/websocket/Config/Port")
 	if e != nil {
 		r.api.Logf(lib.LLERROR, "Error getting websocket port")
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, e
 	}
 
-	var response struct {
-		Host string `json:"host"`
-		Port string `json:"port"`
-		URL  string `json:"url"`
+	// wss if the websocket module serves TLS
+	scheme := "ws"
+	if wsCert, e := nself.GetValue("/Services/websocket/Config/TlsCert"); e == nil && wsCert.String() != "" {
+		scheme = "wss"
 	}
-	response.Host = host
-	response.Port = strconv.FormatInt(wsPort.Int(), 10)
-	response.URL = "/ws"
 
-	json, err := json.Marshal(response)
-	if err != nil {
-		r.api.Logf(lib.LLERROR, "Error marshaling response")
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Write(json)
-	r.api.Logf(lib.LLDDDEBUG, "Websocket redirecting to: %v", string(json))
+	return &WebSocketInfo{
+		Host:   host,
+		Port:   strconv.FormatInt(wsPort.Int(), 10),
+		URL:    "/ws",
+		Scheme: scheme,
+	}, http.StatusOK, nil
 }
 
 func (r *RestAPI) readAll(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
-	ns, e := r.api.QueryReadAll()
+	ns, e := r.apiFor(req).QueryReadAll()
 	if e != nil {
-		w.Write([]byte(e.Error()))
+		writeError(w, http.StatusInternalServerError, e)
 		return
 	}
 	var rsp cpb.NodeList
@@ -215,24 +385,31 @@ func (r *RestAPI) readAll(w http.ResponseWriter, req *http.Request) {
 		rsp.Nodes = append(rsp.Nodes, n.Message().(*cpb.Node))
 	}
 	b, _ := core.MarshalJSON(&rsp)
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Write(b)
 }
 
 func (r *RestAPI) getAllEnums(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
-	nself, e := r.api.QueryRead(r.api.Self().String())
+	enums, e := r.enumerables(r.apiFor(req))
 	if e != nil {
-		w.Write([]byte(e.Error()))
+		writeError(w, http.StatusInternalServerError, e)
+		return
+	}
+	jExt, e := json.Marshal(enums)
+	if e != nil {
+		r.api.Logf(lib.LLERROR, "error marshalling json: %v", e)
+		writeError(w, http.StatusConflict, e)
 		return
 	}
+	w.Write(jExt)
+}
 
-	type extension struct {
-		Name    string           `json:"name"`
-		Url     string           `json:"url"`
-		Options map[int32]string `json:"options"` // 0:"NONE"
+// enumerables finds every enum in the extensions we know about, plus PhysState and RunState
+func (r *RestAPI) enumerables(api lib.APIClient) (enums []Enumerable, e error) {
+	nself, e := api.QueryRead(r.api.Self().String())
+	if e != nil {
+		return
 	}
-	var extSlice []extension
 	extMap := nself.GetExtensions()
 
 	for k, v := range extMap {
@@ -249,46 +426,37 @@ func (r *RestAPI) getAllEnums(w http.ResponseWriter, req *http.Request) {
 				if name == "" {
 					name = p.OrigName
 				}
-				enum := extension{
+				enum := Enumerable{
 					Name:    name,
 					Url:     lib.URLPush(k, name),
 					Options: enumOptions,
 				}
-				extSlice = append(extSlice, enum)
+				enums = append(enums, enum)
 			}
 		}
 	}
 
-	physState := extension{
+	physState := Enumerable{
 		Name:    "PhysState",
 		Url:     "physState",
 		Options: cpb.Node_PhysState_name,
 	}
-	extSlice = append(extSlice, physState)
+	enums = append(enums, physState)
 
-	runState := extension{
+	runState := Enumerable{
 		Name:    "RunState",
 		Url:     "runState",
 		Options: cpb.Node_RunState_name,
 	}
-	extSlice = append(extSlice, runState)
-
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	jExt, e := json.Marshal(extSlice)
-	if e != nil {
-		r.api.Logf(lib.LLERROR, "error marshalling json: %v", e)
-		w.WriteHeader(http.StatusConflict)
-		w.Write([]byte(e.Error()))
-		return
-	}
-	w.Write(jExt)
+	enums = append(enums, runState)
+	return
 }
 
 func (r *RestAPI) readAllDsc(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
-	ns, e := r.api.QueryReadAllDsc()
+	ns, e := r.apiFor(req).QueryReadAllDsc()
 	if e != nil {
-		w.Write([]byte(e.Error()))
+		writeError(w, http.StatusInternalServerError, e)
 		return
 	}
 	var rsp cpb.NodeList
@@ -296,19 +464,18 @@ func (r *RestAPI) readAllDsc(w http.ResponseWriter, req *http.Request) {
 		rsp.Nodes = append(rsp.Nodes, n.Message().(*cpb.Node))
 	}
 	b, _ := core.MarshalJSON(&rsp)
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Write(b)
 }
 
 func (r *RestAPI) readNode(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 	params := mux.Vars(req)
-	n, e := r.api.QueryRead(params["id"])
+	n, e := r.apiFor(req).QueryRead(params["id"])
 	if e != nil || n == nil {
-		w.WriteHeader(http.StatusNotFound)
+		writeError(w, http.StatusNotFound, e)
 		return
 	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("ETag", etag(n))
 	w.Write(n.JSON())
 }
 
@@ -316,23 +483,33 @@ func (r *RestAPI) readNodeGraphJSON(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 	params := mux.Vars(req)
 
-	nodes, e := r.api.QueryNodeMutationNodes(params["id"])
+	graph, e := r.nodeGraph(r.apiFor(req), params["id"])
 	if e != nil {
-		w.WriteHeader(http.StatusConflict)
-		w.Write([]byte(e.Error()))
+		writeError(w, http.StatusConflict, e)
 		return
 	}
-	edges, e := r.api.QueryNodeMutationEdges(params["id"])
+	jsonGraph, e := json.Marshal(graph)
 	if e != nil {
-		w.WriteHeader(http.StatusConflict)
-		w.Write([]byte(e.Error()))
+		writeError(w, http.StatusConflict, e)
 		return
 	}
+	r.api.Logf(lib.LLDDDEBUG, "Node filtered graph: %v", string(jsonGraph))
+	w.Write([]byte(string(jsonGraph)))
+}
 
-	path, e := r.api.QueryNodeMutationPath(params["id"])
+// nodeGraph is the mutation graph for a node, with its current mutation path highlighted
+func (r *RestAPI) nodeGraph(api lib.APIClient, id string) (g *GraphJson, e error) {
+	nodes, e := api.QueryNodeMutationNodes(id)
+	if e != nil {
+		return
+	}
+	edges, e := api.QueryNodeMutationEdges(id)
+	if e != nil {
+		return
+	}
+
+	path, e := api.QueryNodeMutationPath(id)
 	if e != nil {
-		w.WriteHeader(http.StatusConflict)
-		w.Write([]byte(e.Error()))
 		return
 	}
 
@@ -421,63 +598,73 @@ func (r *RestAPI) readNodeGraphJSON(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	graph := GraphJson{
+	g = &GraphJson{
 		Nodes: nodes.MutationNodeList,
 		Edges: edges.MutationEdgeList,
 	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	jsonGraph, e := json.Marshal(graph)
+	return
+}
+
+func (r *RestAPI) readNodeHistory(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	params := mux.Vars(req)
+
+	h, e := r.apiFor(req).QueryNodeMutationHistory(params["id"])
 	if e != nil {
-		w.WriteHeader(http.StatusConflict)
-		w.Write([]byte(e.Error()))
+		writeError(w, http.StatusConflict, e)
 		return
 	}
-	r.api.Logf(lib.LLDDDEBUG, "Node filtered graph: %v", string(jsonGraph))
-	w.Write([]byte(string(jsonGraph)))
+	b, e := core.MarshalJSON(&h)
+	if e != nil {
+		writeError(w, http.StatusConflict, e)
+		return
+	}
+	w.Write(b)
 }
 
 func (r *RestAPI) readGraphJSON(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
-	nodes, e := r.api.QueryMutationNodes()
+	graph, e := r.graph(r.apiFor(req))
 	if e != nil {
-		w.WriteHeader(http.StatusConflict)
-		w.Write([]byte(e.Error()))
+		writeError(w, http.StatusConflict, e)
 		return
 	}
 
-	edges, e := r.api.QueryMutationEdges()
+	jsonGraph, e := json.Marshal(graph)
 	if e != nil {
-		w.WriteHeader(http.StatusConflict)
-		w.Write([]byte(e.Error()))
+		writeError(w, http.StatusConflict, e)
 		return
 	}
+	r.api.Logf(lib.LLDDDEBUG, "Graph: %v", string(jsonGraph))
+	w.Write([]byte(string(jsonGraph)))
+}
 
-	graph := GraphJson{
-		Nodes: nodes.MutationNodeList,
-		Edges: edges.MutationEdgeList,
+// graph is the whole mutation graph
+func (r *RestAPI) graph(api lib.APIClient) (g *GraphJson, e error) {
+	nodes, e := api.QueryMutationNodes()
+	if e != nil {
+		return
 	}
-
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	jsonGraph, e := json.Marshal(graph)
+	edges, e := api.QueryMutationEdges()
 	if e != nil {
-		w.WriteHeader(http.StatusConflict)
-		w.Write([]byte(e.Error()))
 		return
 	}
-	r.api.Logf(lib.LLDDDEBUG, "Graph: %v", string(jsonGraph))
-	w.Write([]byte(string(jsonGraph)))
+	g = &GraphJson{
+		Nodes: nodes.MutationNodeList,
+		Edges: edges.MutationEdgeList,
+	}
+	return
 }
 
 func (r *RestAPI) readNodeDsc(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 	params := mux.Vars(req)
-	n, e := r.api.QueryReadDsc(params["id"])
+	n, e := r.apiFor(req).QueryReadDsc(params["id"])
 	if e != nil || n == nil {
-		w.WriteHeader(http.StatusNotFound)
+		writeError(w, http.StatusNotFound, e)
 		return
 	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Write(n.JSON())
 }
 
@@ -490,13 +677,11 @@ func (r *RestAPI) updateNode(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	nn, e := r.api.QueryUpdate(n)
+	nn, e := r.apiFor(req).QueryUpdate(n)
 	if e != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(e.Error()))
+		writeError(w, http.StatusBadRequest, e)
 		return
 	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Write(nn.JSON())
 }
 
@@ -514,13 +699,154 @@ func (r *RestAPI) updateMulti(w http.ResponseWriter, req *http.Request) {
 	var rsp cpb.NodeList
 	for _, m := range pbs.GetNodes() {
 		n := core.NewNodeFromMessage(m)
-		nn, e := r.api.QueryUpdate(n)
+		nn, e := r.apiFor(req).QueryUpdate(n)
 		if e == nil {
 			rsp.Nodes = append(rsp.Nodes, nn.Message().(*cpb.Node))
 		}
 	}
 	b, _ := core.MarshalJSON(&rsp)
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write(b)
+}
+
+// patchNode applies a merge patch or JSON patch to a node, depending on the Content-Type.
+// With If-Match, the node must still be at the version of that ETag.
+func (r *RestAPI) patchNode(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	params := mux.Vars(req)
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(req.Body)
+	nn, code, e := patchUpdate(r.apiFor(req), params["id"], buf.Bytes(), req.Header.Get("Content-Type"), ifMatch(req))
+	if e != nil {
+		writeError(w, code, e)
+		return
+	}
+	w.Header().Set("ETag", etag(nn))
+	w.Write(nn.JSON())
+}
+
+// patchMulti patches several nodes; the body maps node IDs to patches.
+// Every patch is applied before any node is updated, so one bad patch fails them all.
+// If an update fails anyway, the error says which nodes were already updated.
+func (r *RestAPI) patchMulti(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(req.Body)
+	var patches map[string]json.RawMessage
+	if e := json.Unmarshal(buf.Bytes(), &patches); e != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("body must map node IDs to patches: %v", e))
+		return
+	}
+	rsp, code, e := patchNodes(r.apiFor(req), patches, req.Header.Get("Content-Type"))
+	if e != nil {
+		writeError(w, code, e)
+		return
+	}
+	b, _ := core.MarshalJSON(rsp)
+	w.Write(b)
+}
+
+// patchNodes patches several nodes, in order of ID, after checking that every patch applies.
+// It returns an HTTP status for errors.
+func patchNodes(api lib.APIClient, patches map[string]json.RawMessage, contentType string) (*cpb.NodeList, int, error) {
+	ids := make([]string, 0, len(patches))
+	for id := range patches {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var pns []lib.Node
+	var versions []string
+	for _, id := range ids {
+		n, e := api.QueryRead(id)
+		if e == nil && n == nil {
+			e = fmt.Errorf("no such node")
+		}
+		if e != nil {
+			return nil, httpStatus(e, http.StatusNotFound), fmt.Errorf("%s: %v", id, e)
+		}
+		pn, e := applyPatch(n, patches[id], contentType)
+		if e != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("%s: %v", id, e)
+		}
+		pns = append(pns, pn)
+		versions = append(versions, core.NodeVersion(n))
+	}
+	rsp := &cpb.NodeList{}
+	var done []string
+	for i, id := range ids {
+		code := http.StatusBadRequest
+		nn, e := api.QueryUpdateIf(pns[i], versions[i])
+		if status.Code(e) == codes.Aborted {
+			// it changed since we read it, so patch it as it is now
+			nn, code, e = patchUpdate(api, id, patches[id], contentType, "")
+		}
+		if e != nil {
+			code = httpStatus(e, code) // it won't be a gRPC error once it says which node it's for
+			if len(done) > 0 {
+				return nil, code, fmt.Errorf("%s: %v (already updated: %s)", id, e, strings.Join(done, ", "))
+			}
+			return nil, code, fmt.Errorf("%s: %v", id, e)
+		}
+		done = append(done, id)
+		rsp.Nodes = append(rsp.Nodes, nn.Message().(*cpb.Node))
+	}
+	return rsp, http.StatusOK, nil
+}
+
+// readValue gets a single value of a node, by URL
+func (r *RestAPI) readValue(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	params := mux.Vars(req)
+	n, e := r.apiFor(req).QueryRead(params["id"])
+	if e != nil || n == nil {
+		writeError(w, http.StatusNotFound, e)
+		return
+	}
+	v, e := n.GetValue("/" + params["url"])
+	if e != nil {
+		writeError(w, http.StatusNotFound, e)
+		return
+	}
+	b, e := core.MarshalValueJSON(v)
+	if e != nil {
+		writeError(w, http.StatusInternalServerError, e)
+		return
+	}
+	w.Write(b)
+}
+
+// updateValue sets a single value of a node, by URL; the body is the value as JSON
+func (r *RestAPI) updateValue(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	params := mux.Vars(req)
+	url := "/" + params["url"]
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(req.Body)
+	api := r.apiFor(req)
+	n, e := api.QueryRead(params["id"])
+	if e != nil || n == nil {
+		writeError(w, http.StatusNotFound, e)
+		return
+	}
+	cur, e := n.GetValue(url)
+	if e != nil {
+		writeError(w, http.StatusNotFound, e)
+		return
+	}
+	v, e := core.UnmarshalValueJSON(buf.Bytes(), cur.Type())
+	if e != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("bad value for %s: %v", url, e))
+		return
+	}
+	nv, e := api.QuerySetValue(lib.NodeURLJoin(params["id"], url), v)
+	if e != nil {
+		writeError(w, http.StatusBadRequest, e)
+		return
+	}
+	b, e := core.MarshalValueJSON(nv)
+	if e != nil {
+		writeError(w, http.StatusInternalServerError, e)
+		return
+	}
 	w.Write(b)
 }
 
@@ -534,10 +860,9 @@ func (r *RestAPI) updateNodeDsc(w http.ResponseWriter, req *http.Request) {
 		w.Write([]byte("n is nil"))
 		return
 	}
-	nn, e := r.api.QueryUpdateDsc(n)
+	nn, e := r.apiFor(req).QueryUpdateDsc(n)
 	if e != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(e.Error()))
+		writeError(w, http.StatusBadRequest, e)
 		return
 	}
 	// w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -558,26 +883,23 @@ func (r *RestAPI) updateMultiDsc(w http.ResponseWriter, req *http.Request) {
 	var rsp cpb.NodeList
 	for _, m := range pbs.GetNodes() {
 		n := core.NewNodeFromMessage(m)
-		nn, e := r.api.QueryUpdateDsc(n)
+		nn, e := r.apiFor(req).QueryUpdateDsc(n)
 		if e == nil {
 			rsp.Nodes = append(rsp.Nodes, nn.Message().(*cpb.Node))
 		}
 	}
 	b, _ := core.MarshalJSON(&rsp)
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Write(b)
 }
 
 func (r *RestAPI) deleteNode(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 	params := mux.Vars(req)
-	n, e := r.api.QueryDelete(params["id"])
+	n, e := r.apiFor(req).QueryDelete(params["id"])
 	if e != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(e.Error()))
+		writeError(w, http.StatusBadRequest, e)
 		return
 	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Write(n.JSON())
 }
 
@@ -590,13 +912,11 @@ func (r *RestAPI) createNode(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	nn, e := r.api.QueryCreate(n)
+	nn, e := r.apiFor(req).QueryCreate(n)
 	if e != nil {
-		w.WriteHeader(http.StatusConflict)
-		w.Write([]byte(e.Error()))
+		writeError(w, http.StatusConflict, e)
 		return
 	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Write(nn.JSON())
 }
 
@@ -614,22 +934,21 @@ func (r *RestAPI) createMulti(w http.ResponseWriter, req *http.Request) {
 	var rsp cpb.NodeList
 	for _, m := range pbs.GetNodes() {
 		n := core.NewNodeFromMessage(m)
-		nn, e := r.api.QueryCreate(n)
+		nn, e := r.apiFor(req).QueryCreate(n)
 		if e == nil {
 			rsp.Nodes = append(rsp.Nodes, nn.Message().(*cpb.Node))
 		}
 	}
 	b, _ := core.MarshalJSON(&rsp)
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Write(b)
 }
 
 func (r *RestAPI) freeze(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
-	e := r.api.QueryFreeze()
+	e := r.apiFor(req).QueryFreeze()
 	if e != nil {
 		r.api.Logf(lib.LLERROR, "error freezing sme: %v", e)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, e)
 		return
 	}
 	resp := &Frozen{
@@ -641,15 +960,14 @@ func (r *RestAPI) freeze(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Write(json)
 }
 func (r *RestAPI) thaw(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
-	e := r.api.QueryThaw()
+	e := r.apiFor(req).QueryThaw()
 	if e != nil {
 		r.api.Logf(lib.LLERROR, "error thawing sme: %v", e)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, e)
 		return
 	}
 	resp := &Frozen{
@@ -661,15 +979,14 @@ func (r *RestAPI) thaw(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Write(json)
 }
 func (r *RestAPI) frozen(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
-	f, e := r.api.QueryFrozen()
+	f, e := r.apiFor(req).QueryFrozen()
 	if e != nil {
 		r.api.Logf(lib.LLERROR, "error getting state of sme: %v", e)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, e)
 		return
 	}
 
@@ -682,10 +999,21 @@ func (r *RestAPI) frozen(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Write(json)
 }
 
+func (r *RestAPI) metrics(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	m, e := r.apiFor(req).Metrics()
+	if e != nil {
+		r.api.Logf(lib.LLERROR, "error getting metrics: %v", e)
+		writeError(w, http.StatusInternalServerError, e)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(m))
+}
+
 func init() {
 	module := &RestAPI{}
 	core.Registry.RegisterModule(module)
@@ -0,0 +1,118 @@
+package restapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/core/ktesting"
+	"github.com/hpc/kraken/lib"
+
+	cpb "github.com/hpc/kraken/core/proto"
+)
+
+// historyAPI has a mutation history for self, and no other nodes
+type historyAPI struct {
+	*ktest.APIClient
+	history cpb.MutationHistory
+}
+
+func (a *historyAPI) WithPrincipal(string) lib.APIClient { return a }
+func (a *historyAPI) QueryNodeMutationHistory(id string) (cpb.MutationHistory, error) {
+	if id != testSelf {
+		return cpb.MutationHistory{}, fmt.Errorf("no such node: %s", id)
+	}
+	return a.history, nil
+}
+
+// newHistoryAPI has a path that completed in self's history, and one that was interrupted
+func newHistoryAPI() *historyAPI {
+	start := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	ts := func(d time.Duration) *timestamp.Timestamp {
+		t, _ := ptypes.TimestampProto(start.Add(d))
+		return t
+	}
+	return &historyAPI{
+		APIClient: ktest.NewAPIClient(core.NewNodeWithID(testSelf)),
+		history: cpb.MutationHistory{Records: []*cpb.MutationPathRecord{
+			{
+				Node:     testSelf,
+				Start:    "PhysState: POWER_OFF",
+				End:      "PhysState: POWER_ON",
+				Started:  ts(0),
+				Ended:    ts(time.Second),
+				Duration: ptypes.DurationProto(time.Second),
+				Outcome:  cpb.MutationPathRecord_COMPLETE,
+				Steps: []*cpb.MutationStep{
+					{Id: "0x1", Module: "powerman", Mutation: "PPCtoON", Attempt: 2, Started: ts(0), Ended: ts(time.Second)},
+				},
+			},
+			{
+				Node:    testSelf,
+				Start:   "PhysState: POWER_ON",
+				End:     "PhysState: POWER_OFF",
+				Started: ts(time.Minute),
+				Ended:   ts(2 * time.Minute),
+				Outcome: cpb.MutationPathRecord_INTERRUPTED,
+				Reason:  "cfg changed: /PhysState",
+				Steps: []*cpb.MutationStep{
+					{Id: "0x2", Module: "powerman", Mutation: "PPCtoOFF", Attempt: 1, Started: ts(time.Minute), Ended: ts(2 * time.Minute), Error: "cfg changed: /PhysState"},
+				},
+			},
+		}},
+	}
+}
+
+// TestReadNodeHistory tests that both history endpoints return a node's history as JSON, and fail for unknown nodes
+func TestReadNodeHistory(t *testing.T) {
+	api := newHistoryAPI()
+	r := &RestAPI{api: api}
+	r.setupRouter()
+	s := httptest.NewServer(r.router)
+	defer s.Close()
+	tests := []struct {
+		name string
+		path string
+		code int // for an unknown node
+	}{
+		{"graph", "/graph/node/%s/history", http.StatusConflict},
+		{"v1", V1Prefix + "/mutations/nodes/%s/history", http.StatusNotFound},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			rsp, e := http.Get(s.URL + fmt.Sprintf(v.path, testSelf))
+			if e != nil {
+				t.Fatal(e)
+			}
+			b, _ := ioutil.ReadAll(rsp.Body)
+			rsp.Body.Close()
+			if rsp.StatusCode != http.StatusOK {
+				t.Fatalf("expected %d, got %d: %s", http.StatusOK, rsp.StatusCode, b)
+			}
+			var h cpb.MutationHistory
+			if e := core.UnmarshalJSON(b, &h); e != nil {
+				t.Fatalf("bad history: %v: %s", e, b)
+			}
+			if !proto.Equal(&h, &api.history) {
+				t.Errorf("expected %v, got %v", &api.history, &h)
+			}
+
+			rsp, e = http.Get(s.URL + fmt.Sprintf(v.path, testOther))
+			if e != nil {
+				t.Fatal(e)
+			}
+			rsp.Body.Close()
+			if rsp.StatusCode != v.code {
+				t.Errorf("expected %d for an unknown node, got %d", v.code, rsp.StatusCode)
+			}
+		})
+	}
+}
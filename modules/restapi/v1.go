@@ -0,0 +1,786 @@
+/* v1.go: the versioned (/v1) ReST API
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package restapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/lib"
+
+	cpb "github.com/hpc/kraken/core/proto"
+)
+
+// V1Prefix is where the versioned API is served
+const V1Prefix = "/v1"
+
+// Page sizes of list endpoints
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// A v1Route describes one endpoint of the /v1 API.
+// The same table is used to route requests and to generate the OpenAPI document.
+type v1Route struct {
+	method   string
+	path     string // mux path template, relative to V1Prefix
+	handler  func(*RestAPI, http.ResponseWriter, *http.Request)
+	id       string // OpenAPI operationId
+	summary  string
+	query    []string // query parameters, from v1Params
+	request  string   // schema of the request body, if there is one
+	response string   // schema of a successful response
+	status   int      // status of a successful response; default 200 OK
+}
+
+var v1Routes = []v1Route{
+	{method: "GET", path: "/cfg/nodes", handler: (*RestAPI).v1ListCfg, id: "listCfgNodes",
+		summary: "List the cfg state of nodes, ordered by ID", query: []string{"page_size", "page_token", "fields"}, response: "NodePage"},
+	{method: "POST", path: "/cfg/nodes", handler: (*RestAPI).v1CreateNode, id: "createNode",
+		summary: "Create a node", request: "proto.Node", response: "proto.Node", status: http.StatusCreated},
+	{method: "PATCH", path: "/cfg/nodes", handler: (*RestAPI).v1PatchNodes, id: "patchCfgNodes",
+		summary: "Patch several nodes; every patch must apply before any node is updated", request: "NodePatches", response: "NodeList"},
+	{method: "GET", path: "/cfg/nodes/{id}", handler: (*RestAPI).v1ReadCfg, id: "getCfgNode",
+		summary: "Get the cfg state of a node", query: []string{"fields"}, response: "proto.Node"},
+	{method: "PUT", path: "/cfg/nodes/{id}", handler: (*RestAPI).v1UpdateCfg, id: "updateCfgNode",
+		summary: "Replace the cfg state of a node", request: "proto.Node", response: "proto.Node"},
+	{method: "PATCH", path: "/cfg/nodes/{id}", handler: (*RestAPI).v1PatchNode, id: "patchCfgNode",
+		summary: "Patch the cfg state of a node with a merge patch or a JSON patch", request: "Patch", response: "proto.Node"},
+	{method: "DELETE", path: "/cfg/nodes/{id}", handler: (*RestAPI).v1DeleteNode, id: "deleteNode",
+		summary: "Delete a node", response: "proto.Node"},
+	{method: "GET", path: "/cfg/nodes/{id}/values/{url:.+}", handler: (*RestAPI).v1ReadCfgValue, id: "getCfgValue",
+		summary: "Get one value of the cfg state of a node", response: "Value"},
+	{method: "PUT", path: "/cfg/nodes/{id}/values/{url:.+}", handler: (*RestAPI).v1UpdateCfgValue, id: "updateCfgValue",
+		summary: "Set one value of the cfg state of a node", request: "Value", response: "Value"},
+
+	{method: "GET", path: "/dsc/nodes", handler: (*RestAPI).v1ListDsc, id: "listDscNodes",
+		summary: "List the dsc state of nodes, ordered by ID", query: []string{"page_size", "page_token", "fields"}, response: "NodePage"},
+	{method: "GET", path: "/dsc/nodes/{id}", handler: (*RestAPI).v1ReadDsc, id: "getDscNode",
+		summary: "Get the dsc state of a node", query: []string{"fields"}, response: "proto.Node"},
+	{method: "PUT", path: "/dsc/nodes/{id}", handler: (*RestAPI).v1UpdateDsc, id: "updateDscNode",
+		summary: "Replace the dsc state of a node", request: "proto.Node", response: "proto.Node"},
+	{method: "GET", path: "/dsc/nodes/{id}/values/{url:.+}", handler: (*RestAPI).v1ReadDscValue, id: "getDscValue",
+		summary: "Get one value of the dsc state of a node", response: "Value"},
+
+	{method: "GET", path: "/mutations/graph", handler: (*RestAPI).v1Graph, id: "getGraph",
+		summary: "Get the whole mutation graph", response: "Graph"},
+	{method: "GET", path: "/mutations/nodes/{id}/graph", handler: (*RestAPI).v1NodeGraph, id: "getNodeGraph",
+		summary: "Get the mutation graph of a node, with its current path highlighted", response: "Graph"},
+	{method: "GET", path: "/mutations/nodes/{id}/history", handler: (*RestAPI).v1NodeHistory, id: "getNodeHistory",
+		summary: "Get the recent mutations of a node", response: "proto.MutationHistory"},
+
+	{method: "GET", path: "/services", handler: (*RestAPI).v1ListServices, id: "listServices",
+		summary: "List the service instances, with their cfg and dsc state", response: "proto.ServiceStatusList"},
+	{method: "GET", path: "/services/{id}", handler: (*RestAPI).v1GetService, id: "getService",
+		summary: "Get the cfg and dsc state, error and config of a service instance", response: "proto.ServiceStatus"},
+	{method: "POST", path: "/services/{id}/start", handler: (*RestAPI).v1StartService, id: "startService",
+		summary: "Start a service instance; one that stopped in ERROR is reset first", response: "proto.ServiceStatus"},
+	{method: "POST", path: "/services/{id}/stop", handler: (*RestAPI).v1StopService, id: "stopService",
+		summary: "Stop a service instance", response: "proto.ServiceStatus"},
+	{method: "POST", path: "/services/{id}/restart", handler: (*RestAPI).v1RestartService, id: "restartService",
+		summary: "Restart a service instance; it is started again once it has stopped", response: "proto.ServiceStatus"},
+	{method: "PUT", path: "/services/{id}/config", handler: (*RestAPI).v1ConfigureService, id: "configureService",
+		summary: "Set the config of a service instance, and tell it to update if it's running", request: "ServiceConfig", response: "proto.ServiceStatus"},
+
+	{method: "GET", path: "/sme", handler: (*RestAPI).v1Frozen, id: "getSME",
+		summary: "Get whether the state mutation engine is frozen", response: "Frozen"},
+	{method: "POST", path: "/sme/freeze", handler: (*RestAPI).v1Freeze, id: "freezeSME",
+		summary: "Freeze the state mutation engine", response: "Frozen"},
+	{method: "POST", path: "/sme/thaw", handler: (*RestAPI).v1Thaw, id: "thawSME",
+		summary: "Thaw the state mutation engine", response: "Frozen"},
+
+	{method: "GET", path: "/enumerables", handler: (*RestAPI).v1Enumerables, id: "listEnumerables",
+		summary: "List the enums that can be set in nodes", response: "Enumerables"},
+	{method: "GET", path: "/websocket", handler: (*RestAPI).v1WebSocket, id: "getWebSocket",
+		summary: "Get where to connect to the websocket service", response: "WebSocketInfo"},
+	{method: "GET", path: "/metrics", handler: (*RestAPI).v1Metrics, id: "getMetrics",
+		summary: "Get metrics in the Prometheus text format", response: "Metrics"},
+}
+
+func init() {
+	// the OpenAPI document describes v1Routes, so this can't be in its initializer
+	v1Routes = append([]v1Route{
+		{method: "GET", path: "/openapi.json", handler: (*RestAPI).v1OpenAPI, id: "getOpenAPI",
+			summary: "This OpenAPI document", response: "OpenAPI"},
+	}, v1Routes...)
+}
+
+// v1Params are the query parameters routes can take
+var v1Params = map[string]struct {
+	description string
+	schema      map[string]interface{}
+}{
+	"page_size": {
+		description: fmt.Sprintf("the most nodes to return (default %d, at most %d)", DefaultPageSize, MaxPageSize),
+		schema:      map[string]interface{}{"type": "integer", "minimum": 1, "maximum": MaxPageSize},
+	},
+	"page_token": {
+		description: "the nextPageToken of the previous page",
+		schema:      map[string]interface{}{"type": "string"},
+	},
+	"fields": {
+		description: "comma separated JSON field paths to return, e.g. id,physState,services.state",
+		schema:      map[string]interface{}{"type": "string"},
+	},
+}
+
+// A NodePage is one page of a node list
+type NodePage struct {
+	Nodes         []interface{} `json:"nodes"`
+	NextPageToken string        `json:"nextPageToken,omitempty"`
+}
+
+// setupV1 routes the /v1 API
+func (r *RestAPI) setupV1() {
+	sr := r.router.PathPrefix(V1Prefix).Subrouter()
+	for _, rt := range v1Routes {
+		h := rt.handler
+		sr.HandleFunc(rt.path, func(w http.ResponseWriter, req *http.Request) {
+			defer req.Body.Close()
+			h(r, w, req)
+		}).Methods(rt.method)
+	}
+	sr.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeV1Error(w, http.StatusNotFound, fmt.Errorf("no such endpoint: %s %s", req.Method, req.URL.Path))
+	})
+	sr.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeV1Error(w, http.StatusMethodNotAllowed, fmt.Errorf("%s is not allowed on %s", req.Method, req.URL.Path))
+	})
+}
+
+/*
+ * Responses
+ */
+
+// A V1Error is the body of every /v1 error response
+type V1Error struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// httpStatus maps the gRPC status of an API error to an HTTP status, or def if it doesn't have a meaningful one
+func httpStatus(e error, def int) int {
+	switch status.Code(e) {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return def
+}
+
+// writeV1Error writes a JSON error; the status comes from e if it is a gRPC error that has one
+func writeV1Error(w http.ResponseWriter, code int, e error) {
+	if e == nil {
+		e = fmt.Errorf("%s", strings.ToLower(http.StatusText(code)))
+	}
+	code = httpStatus(e, code)
+	var v V1Error
+	v.Error.Code = code
+	v.Error.Status = http.StatusText(code)
+	if s, ok := status.FromError(e); ok {
+		v.Error.Message = s.Message()
+	} else {
+		v.Error.Message = e.Error()
+	}
+	b, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// writeV1JSON writes a JSON response
+func writeV1JSON(w http.ResponseWriter, code int, b []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// writeV1Node writes a node, filtered by the fields query parameter
+func writeV1Node(w http.ResponseWriter, req *http.Request, code int, n lib.Node) {
+	mask := parseFieldMask(req.URL.Query().Get("fields"))
+	if mask == nil {
+		writeV1JSON(w, code, n.JSON())
+		return
+	}
+	v, e := mask.applyJSON(n.JSON())
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	b, _ := json.Marshal(v)
+	writeV1JSON(w, code, b)
+}
+
+func marshalV1(w http.ResponseWriter, v interface{}) {
+	b, e := json.Marshal(v)
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, b)
+}
+
+/*
+ * Field masks
+ */
+
+// A fieldMask selects JSON fields by path; a nil sub-mask selects the whole field
+type fieldMask map[string]fieldMask
+
+// parseFieldMask parses comma separated, dotted field paths; it returns nil (select everything) for ""
+func parseFieldMask(s string) fieldMask {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	m := fieldMask{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		cur := m
+		elems := strings.Split(p, ".")
+		for i, el := range elems {
+			sub, ok := cur[el]
+			if ok && sub == nil {
+				// we already select all of this
+				break
+			}
+			if i == len(elems)-1 {
+				cur[el] = nil
+				break
+			}
+			if !ok {
+				sub = fieldMask{}
+				cur[el] = sub
+			}
+			cur = sub
+		}
+	}
+	return m
+}
+
+// apply filters a decoded JSON value; masks apply to each element of arrays
+func (m fieldMask) apply(v interface{}) interface{} {
+	if m == nil {
+		return v
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		r := make(map[string]interface{})
+		for k, sub := range m {
+			if fv, ok := t[k]; ok {
+				r[k] = sub.apply(fv)
+			}
+		}
+		return r
+	case []interface{}:
+		r := make([]interface{}, len(t))
+		for i := range t {
+			r[i] = m.apply(t[i])
+		}
+		return r
+	}
+	return v
+}
+
+func (m fieldMask) applyJSON(b []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if e := d.Decode(&v); e != nil {
+		return nil, e
+	}
+	return m.apply(v), nil
+}
+
+/*
+ * Pagination
+ */
+
+// paginate sorts nodes by ID, and returns the page after a page token, and the token of the next page
+func paginate(ns []lib.Node, req *http.Request) (page []lib.Node, next string, e error) {
+	q := req.URL.Query()
+	size := DefaultPageSize
+	if s := q.Get("page_size"); s != "" {
+		if size, e = strconv.Atoi(s); e != nil || size < 1 || size > MaxPageSize {
+			return nil, "", fmt.Errorf("page_size must be a number from 1 to %d", MaxPageSize)
+		}
+	}
+	after := ""
+	if t := q.Get("page_token"); t != "" {
+		b, e := base64.RawURLEncoding.DecodeString(t)
+		if e != nil {
+			return nil, "", fmt.Errorf("bad page_token")
+		}
+		after = string(b)
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i].ID().String() < ns[j].ID().String() })
+	start := sort.Search(len(ns), func(i int) bool { return ns[i].ID().String() > after })
+	end := start + size
+	if end >= len(ns) {
+		return ns[start:], "", nil
+	}
+	return ns[start:end], base64.RawURLEncoding.EncodeToString([]byte(ns[end-1].ID().String())), nil
+}
+
+func (r *RestAPI) v1List(w http.ResponseWriter, req *http.Request, ns []lib.Node) {
+	page, next, e := paginate(ns, req)
+	if e != nil {
+		writeV1Error(w, http.StatusBadRequest, e)
+		return
+	}
+	mask := parseFieldMask(req.URL.Query().Get("fields"))
+	rsp := NodePage{
+		Nodes:         make([]interface{}, 0, len(page)),
+		NextPageToken: next,
+	}
+	for _, n := range page {
+		v, e := mask.applyJSON(n.JSON())
+		if e != nil {
+			writeV1Error(w, http.StatusInternalServerError, e)
+			return
+		}
+		rsp.Nodes = append(rsp.Nodes, v)
+	}
+	marshalV1(w, rsp)
+}
+
+/*
+ * Route handlers
+ */
+
+func (r *RestAPI) v1OpenAPI(w http.ResponseWriter, req *http.Request) {
+	marshalV1(w, r.openAPI())
+}
+
+func (r *RestAPI) v1ListCfg(w http.ResponseWriter, req *http.Request) {
+	ns, e := r.apiFor(req).QueryReadAll()
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	r.v1List(w, req, ns)
+}
+
+func (r *RestAPI) v1ListDsc(w http.ResponseWriter, req *http.Request) {
+	ns, e := r.apiFor(req).QueryReadAllDsc()
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	r.v1List(w, req, ns)
+}
+
+// v1ReadBody reads a node from a request body; if the request has an id, the node must have the same one (or none)
+func v1ReadBody(req *http.Request) (lib.Node, error) {
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(req.Body)
+	var m cpb.Node
+	if e := core.UnmarshalJSON(buf.Bytes(), &m); e != nil {
+		return nil, fmt.Errorf("bad node: %v", e)
+	}
+	id, ok := mux.Vars(req)["id"]
+	if !ok {
+		return core.NewNodeFromMessage(&m), nil
+	}
+	nid := core.NewNodeIDFromURL(id)
+	if nid.Nil() {
+		return nil, fmt.Errorf("bad node id: %s", id)
+	}
+	if len(m.Id) == 0 {
+		m.Id = nid.Binary()
+	}
+	n := core.NewNodeFromMessage(&m)
+	if !n.ID().Equal(nid) {
+		return nil, fmt.Errorf("node id %s does not match %s", n.ID().String(), id)
+	}
+	return n, nil
+}
+
+func (r *RestAPI) v1CreateNode(w http.ResponseWriter, req *http.Request) {
+	n, e := v1ReadBody(req)
+	if e != nil {
+		writeV1Error(w, http.StatusBadRequest, e)
+		return
+	}
+	nn, e := r.apiFor(req).QueryCreate(n)
+	if e != nil {
+		writeV1Error(w, http.StatusConflict, e)
+		return
+	}
+	w.Header().Set("Location", V1Prefix+"/cfg/nodes/"+nn.ID().String())
+	writeV1JSON(w, http.StatusCreated, nn.JSON())
+}
+
+func (r *RestAPI) v1ReadCfg(w http.ResponseWriter, req *http.Request) {
+	n, e := r.apiFor(req).QueryRead(mux.Vars(req)["id"])
+	if e != nil || n == nil {
+		writeV1Error(w, http.StatusNotFound, e)
+		return
+	}
+	w.Header().Set("ETag", etag(n))
+	writeV1Node(w, req, http.StatusOK, n)
+}
+
+func (r *RestAPI) v1ReadDsc(w http.ResponseWriter, req *http.Request) {
+	n, e := r.apiFor(req).QueryReadDsc(mux.Vars(req)["id"])
+	if e != nil || n == nil {
+		writeV1Error(w, http.StatusNotFound, e)
+		return
+	}
+	writeV1Node(w, req, http.StatusOK, n)
+}
+
+func (r *RestAPI) v1UpdateCfg(w http.ResponseWriter, req *http.Request) {
+	n, e := v1ReadBody(req)
+	if e != nil {
+		writeV1Error(w, http.StatusBadRequest, e)
+		return
+	}
+	nn, e := r.apiFor(req).QueryUpdate(n)
+	if e != nil {
+		writeV1Error(w, http.StatusBadRequest, e)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, nn.JSON())
+}
+
+func (r *RestAPI) v1UpdateDsc(w http.ResponseWriter, req *http.Request) {
+	n, e := v1ReadBody(req)
+	if e != nil {
+		writeV1Error(w, http.StatusBadRequest, e)
+		return
+	}
+	nn, e := r.apiFor(req).QueryUpdateDsc(n)
+	if e != nil {
+		writeV1Error(w, http.StatusBadRequest, e)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, nn.JSON())
+}
+
+func (r *RestAPI) v1PatchNode(w http.ResponseWriter, req *http.Request) {
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(req.Body)
+	nn, code, e := patchUpdate(r.apiFor(req), mux.Vars(req)["id"], buf.Bytes(), req.Header.Get("Content-Type"), ifMatch(req))
+	if e != nil {
+		writeV1Error(w, code, e)
+		return
+	}
+	w.Header().Set("ETag", etag(nn))
+	writeV1JSON(w, http.StatusOK, nn.JSON())
+}
+
+// v1PatchNodes is like patchMulti
+func (r *RestAPI) v1PatchNodes(w http.ResponseWriter, req *http.Request) {
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(req.Body)
+	var patches map[string]json.RawMessage
+	if e := json.Unmarshal(buf.Bytes(), &patches); e != nil {
+		writeV1Error(w, http.StatusBadRequest, fmt.Errorf("body must map node IDs to patches: %v", e))
+		return
+	}
+	rsp, code, e := patchNodes(r.apiFor(req), patches, req.Header.Get("Content-Type"))
+	if e != nil {
+		writeV1Error(w, code, e)
+		return
+	}
+	b, e := core.MarshalJSON(rsp)
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, b)
+}
+
+func (r *RestAPI) v1DeleteNode(w http.ResponseWriter, req *http.Request) {
+	n, e := r.apiFor(req).QueryDelete(mux.Vars(req)["id"])
+	if e != nil || n == nil {
+		writeV1Error(w, http.StatusNotFound, e)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, n.JSON())
+}
+
+func v1WriteValue(w http.ResponseWriter, n lib.Node, url string) {
+	v, e := n.GetValue(url)
+	if e != nil {
+		writeV1Error(w, http.StatusNotFound, e)
+		return
+	}
+	b, e := core.MarshalValueJSON(v)
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, b)
+}
+
+func (r *RestAPI) v1ReadCfgValue(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	n, e := r.apiFor(req).QueryRead(params["id"])
+	if e != nil || n == nil {
+		writeV1Error(w, http.StatusNotFound, e)
+		return
+	}
+	v1WriteValue(w, n, "/"+params["url"])
+}
+
+func (r *RestAPI) v1ReadDscValue(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	n, e := r.apiFor(req).QueryReadDsc(params["id"])
+	if e != nil || n == nil {
+		writeV1Error(w, http.StatusNotFound, e)
+		return
+	}
+	v1WriteValue(w, n, "/"+params["url"])
+}
+
+func (r *RestAPI) v1UpdateCfgValue(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	url := "/" + params["url"]
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(req.Body)
+	api := r.apiFor(req)
+	n, e := api.QueryRead(params["id"])
+	if e != nil || n == nil {
+		writeV1Error(w, http.StatusNotFound, e)
+		return
+	}
+	cur, e := n.GetValue(url)
+	if e != nil {
+		writeV1Error(w, http.StatusNotFound, e)
+		return
+	}
+	v, e := core.UnmarshalValueJSON(buf.Bytes(), cur.Type())
+	if e != nil {
+		writeV1Error(w, http.StatusBadRequest, fmt.Errorf("bad value for %s: %v", url, e))
+		return
+	}
+	nv, e := api.QuerySetValue(lib.NodeURLJoin(params["id"], url), v)
+	if e != nil {
+		writeV1Error(w, http.StatusBadRequest, e)
+		return
+	}
+	b, e := core.MarshalValueJSON(nv)
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, b)
+}
+
+func (r *RestAPI) v1Graph(w http.ResponseWriter, req *http.Request) {
+	g, e := r.graph(r.apiFor(req))
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	marshalV1(w, g)
+}
+
+func (r *RestAPI) v1NodeGraph(w http.ResponseWriter, req *http.Request) {
+	g, e := r.nodeGraph(r.apiFor(req), mux.Vars(req)["id"])
+	if e != nil {
+		writeV1Error(w, http.StatusNotFound, e)
+		return
+	}
+	marshalV1(w, g)
+}
+
+func (r *RestAPI) v1NodeHistory(w http.ResponseWriter, req *http.Request) {
+	h, e := r.apiFor(req).QueryNodeMutationHistory(mux.Vars(req)["id"])
+	if e != nil {
+		writeV1Error(w, http.StatusNotFound, e)
+		return
+	}
+	b, e := core.MarshalJSON(&h)
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, b)
+}
+
+func (r *RestAPI) v1Frozen(w http.ResponseWriter, req *http.Request) {
+	f, e := r.apiFor(req).QueryFrozen()
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	marshalV1(w, &Frozen{Frozen: f})
+}
+
+func (r *RestAPI) v1Freeze(w http.ResponseWriter, req *http.Request) {
+	if e := r.apiFor(req).QueryFreeze(); e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	marshalV1(w, &Frozen{Frozen: true})
+}
+
+func (r *RestAPI) v1Thaw(w http.ResponseWriter, req *http.Request) {
+	if e := r.apiFor(req).QueryThaw(); e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	marshalV1(w, &Frozen{Frozen: false})
+}
+
+func (r *RestAPI) v1Enumerables(w http.ResponseWriter, req *http.Request) {
+	enums, e := r.enumerables(r.apiFor(req))
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	marshalV1(w, enums)
+}
+
+// v1WebSocket doesn't start the websocket service like /ws does; GETs don't change state
+func (r *RestAPI) v1WebSocket(w http.ResponseWriter, req *http.Request) {
+	host, _, _ := net.SplitHostPort(req.Host)
+	info, code, e := r.webSocketInfo(r.apiFor(req), host, false)
+	if e != nil {
+		writeV1Error(w, code, e)
+		return
+	}
+	marshalV1(w, info)
+}
+
+func (r *RestAPI) v1Metrics(w http.ResponseWriter, req *http.Request) {
+	m, e := r.apiFor(req).Metrics()
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(m))
+}
+
+/*
+ * Services
+ */
+
+func writeV1Service(w http.ResponseWriter, st *cpb.ServiceStatus) {
+	b, e := core.MarshalJSON(st)
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, b)
+}
+
+func (r *RestAPI) v1ListServices(w http.ResponseWriter, req *http.Request) {
+	sts, e := r.apiFor(req).ServiceList()
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	b, e := core.MarshalJSON(&cpb.ServiceStatusList{Services: sts})
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, b)
+}
+
+func (r *RestAPI) v1GetService(w http.ResponseWriter, req *http.Request) {
+	st, e := r.apiFor(req).ServiceGet(mux.Vars(req)["id"])
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	writeV1Service(w, st)
+}
+
+func (r *RestAPI) v1StartService(w http.ResponseWriter, req *http.Request) {
+	st, e := r.apiFor(req).ServiceStart(mux.Vars(req)["id"])
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	writeV1Service(w, st)
+}
+
+func (r *RestAPI) v1StopService(w http.ResponseWriter, req *http.Request) {
+	st, e := r.apiFor(req).ServiceStop(mux.Vars(req)["id"])
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	writeV1Service(w, st)
+}
+
+func (r *RestAPI) v1RestartService(w http.ResponseWriter, req *http.Request) {
+	st, e := r.apiFor(req).ServiceRestart(mux.Vars(req)["id"])
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	writeV1Service(w, st)
+}
+
+// v1ConfigureService takes the JSON of the service's module config.
+// If it has an @type, it's decoded as that type (and kraken checks it's the right one).
+func (r *RestAPI) v1ConfigureService(w http.ResponseWriter, req *http.Request) {
+	api := r.apiFor(req)
+	id := mux.Vars(req)["id"]
+	st, e := api.ServiceGet(id)
+	if e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(req.Body)
+	cfg, e := core.UnmarshalConfigJSON(st.GetModule(), buf.Bytes())
+	if e != nil {
+		writeV1Error(w, http.StatusBadRequest, fmt.Errorf("bad config: %v", e))
+		return
+	}
+	if st, e = api.ServiceConfigure(id, cfg); e != nil {
+		writeV1Error(w, http.StatusInternalServerError, e)
+		return
+	}
+	writeV1Service(w, st)
+}
@@ -0,0 +1,312 @@
+package restapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/core/ktesting"
+	"github.com/hpc/kraken/lib"
+)
+
+const (
+	testSelf  = "123e4567-e89b-12d3-a456-426655440000"
+	testOther = "123e4567-e89b-12d3-a456-426655440001"
+	testNew   = "123e4567-e89b-12d3-a456-426655440002"
+)
+
+// TestParseFieldMask tests how field paths are combined
+func TestParseFieldMask(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		mask fieldMask
+	}{
+		{"empty", "", nil},
+		{"blank", "  ", nil},
+		{"fields", "id,physState", fieldMask{"id": nil, "physState": nil}},
+		{"nested", "services.state,services.id", fieldMask{"services": {"state": nil, "id": nil}}},
+		{"whole field first", "services,services.state", fieldMask{"services": nil}},
+		{"whole field last", "services.state,services", fieldMask{"services": nil}},
+		{"spaces and empty paths", " id , ,a.b.c", fieldMask{"id": nil, "a": {"b": {"c": nil}}}},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			if m := parseFieldMask(v.s); !reflect.DeepEqual(m, v.mask) {
+				t.Errorf("expected %v, got %v", v.mask, m)
+			}
+		})
+	}
+}
+
+// TestFieldMask_Apply tests that masks select fields, and apply to each element of arrays
+func TestFieldMask_Apply(t *testing.T) {
+	doc := `{"id": "x", "physState": "POWER_ON", "arch": "x86_64",
+		"services": [{"id": "a", "state": "RUN", "config": {"port": 1}}, {"id": "b", "state": "STOP"}]}`
+	tests := []struct {
+		name   string
+		fields string
+		result string
+	}{
+		{"everything", "", doc},
+		{"fields", "id,arch", `{"id": "x", "arch": "x86_64"}`},
+		{"missing fields are left out", "id,nosuchfield", `{"id": "x"}`},
+		{"arrays", "services.state", `{"services": [{"state": "RUN"}, {"state": "STOP"}]}`},
+		{"nested", "services.config.port", `{"services": [{"config": {"port": 1}}, {}]}`},
+		{"a path through a value", "id.foo", `{"id": "x"}`},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			r, e := parseFieldMask(v.fields).applyJSON([]byte(doc))
+			if e != nil {
+				t.Fatal(e)
+			}
+			var expect interface{}
+			json.Unmarshal([]byte(v.result), &expect)
+			b, _ := json.Marshal(r)
+			var got interface{}
+			json.Unmarshal(b, &got)
+			if !reflect.DeepEqual(got, expect) {
+				t.Errorf("expected %s, got %s", v.result, b)
+			}
+		})
+	}
+}
+
+// TestPaginate tests page sizes and tokens
+func TestPaginate(t *testing.T) {
+	var ns []lib.Node
+	for _, id := range []string{testNew, testSelf, testOther} {
+		ns = append(ns, core.NewNodeWithID(id))
+	}
+	token := func(id string) string { return base64.RawURLEncoding.EncodeToString([]byte(id)) }
+	tests := []struct {
+		name  string
+		query string
+		ids   []string
+		next  string
+		bad   bool
+	}{
+		{"default size", "", []string{testSelf, testOther, testNew}, "", false},
+		{"first page", "page_size=2", []string{testSelf, testOther}, token(testOther), false},
+		{"last page", "page_size=2&page_token=" + token(testOther), []string{testNew}, "", false},
+		{"exactly one page", "page_size=3", []string{testSelf, testOther, testNew}, "", false},
+		{"after the end", "page_token=" + token(testNew), []string{}, "", false},
+		{"zero size", "page_size=0", nil, "", true},
+		{"too big", fmt.Sprintf("page_size=%d", MaxPageSize+1), nil, "", true},
+		{"not a number", "page_size=x", nil, "", true},
+		{"bad token", "page_token=!!", nil, "", true},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/cfg/nodes?"+v.query, nil)
+			page, next, e := paginate(ns, req)
+			if (e != nil) != v.bad {
+				t.Fatalf("expected error %v, got: %v", v.bad, e)
+			}
+			if v.bad {
+				return
+			}
+			ids := []string{}
+			for _, n := range page {
+				ids = append(ids, n.ID().String())
+			}
+			if !reflect.DeepEqual(ids, v.ids) || next != v.next {
+				t.Errorf("expected %v, %q, got %v, %q", v.ids, v.next, ids, next)
+			}
+		})
+	}
+}
+
+// jsonID is how a node's id appears in its JSON
+func jsonID(id string) string {
+	return base64.StdEncoding.EncodeToString(core.NewNodeID(id).Binary())
+}
+
+// deniedAPI refuses to freeze, as a kraken would if the authorization policy denied it
+type deniedAPI struct {
+	*ktest.APIClient
+}
+
+func (a deniedAPI) WithPrincipal(string) lib.APIClient { return a }
+func (deniedAPI) QueryFreeze() error {
+	return status.Error(codes.PermissionDenied, "eve may not freeze")
+}
+
+// v1Server serves the API for two nodes
+func v1Server(t *testing.T) *httptest.Server {
+	self := core.NewNodeWithID(testSelf)
+	self.SetValue("/Nodename", reflect.ValueOf("kr0"))
+	api := ktest.NewAPIClient(self)
+	if _, e := api.QueryCreate(core.NewNodeWithID(testOther)); e != nil {
+		t.Fatal(e)
+	}
+	r := &RestAPI{api: deniedAPI{api}}
+	r.setupRouter()
+	s := httptest.NewServer(r.router)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// TestV1 makes a round trip to each resource of the /v1 API, one after another
+func TestV1(t *testing.T) {
+	s := v1Server(t)
+	var selfETag, oldETag string // the ETags of the last two responses for the self node
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		header  map[string]string
+		code    int
+		contain string // the response contains this
+	}{
+		{"openapi", "GET", "/openapi.json", "", nil, 200, `"getCfgNode"`},
+		{"list cfg", "GET", "/cfg/nodes?page_size=1&fields=id", "", nil, 200, `"nextPageToken"`},
+		{"list cfg, bad page size", "GET", "/cfg/nodes?page_size=0", "", nil, 400, "page_size must be"},
+		{"read cfg", "GET", "/cfg/nodes/" + testSelf + "?fields=nodename", "", nil, 200, `{"nodename":"kr0"}`},
+		{"read a missing node", "GET", "/cfg/nodes/" + testNew, "", nil, 404, "no such node"},
+		{"create", "POST", "/cfg/nodes", `{"id": "` + jsonID(testNew) + `", "nodename": "kr2"}`, nil, 201, `"kr2"`},
+		{"create again", "POST", "/cfg/nodes", `{"id": "` + jsonID(testNew) + `"}`, nil, 409, "already exists"},
+		{"create a bad node", "POST", "/cfg/nodes", `{"nosuchfield": 1}`, nil, 400, "bad node"},
+		{"replace", "PUT", "/cfg/nodes/" + testNew, `{"nodename": "kr3"}`, nil, 200, `"kr3"`},
+		{"replace, with another id", "PUT", "/cfg/nodes/" + testNew, `{"id": "` + jsonID(testOther) + `"}`, nil, 400, "does not match"},
+		{"merge patch", "PATCH", "/cfg/nodes/" + testSelf, `{"arch": "x86_64"}`, map[string]string{"Content-Type": "application/merge-patch+json"}, 200, `"x86_64"`},
+		{"json patch", "PATCH", "/cfg/nodes/" + testSelf, `[{"op": "replace", "path": "/arch", "value": "aarch64"}]`, map[string]string{"Content-Type": "application/json-patch+json"}, 200, `"aarch64"`},
+		{"patch, if it hasn't changed", "PATCH", "/cfg/nodes/" + testSelf, `{"arch": "ppc64le"}`, map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": "etag"}, 200, `"ppc64le"`},
+		{"patch, if it hasn't changed, but it has", "PATCH", "/cfg/nodes/" + testSelf, `{"arch": "x86_64"}`, map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": "old etag"}, 412, "node has changed"},
+		{"patch the id", "PATCH", "/cfg/nodes/" + testSelf, `{"id": "` + jsonID(testOther) + `"}`, map[string]string{"Content-Type": "application/merge-patch+json"}, 400, "can't change a node's id"},
+		{"patch nodes", "PATCH", "/cfg/nodes", `{"` + testSelf + `": {"arch": "x86_64"}, "` + testOther + `": {"arch": "x86_64"}}`, map[string]string{"Content-Type": "application/merge-patch+json"}, 200, `"nodes"`},
+		{"patch nodes, one missing", "PATCH", "/cfg/nodes", `{"` + testSelf + `": {"arch": "ppc64le"}, "nosuchnode": {}}`, map[string]string{"Content-Type": "application/merge-patch+json"}, 404, "nosuchnode: no such node"},
+		{"patch nodes, not a map", "PATCH", "/cfg/nodes", `[]`, map[string]string{"Content-Type": "application/merge-patch+json"}, 400, "body must map node IDs to patches"},
+		{"read value", "GET", "/cfg/nodes/" + testSelf + "/values/Arch", "", nil, 200, `"x86_64"`},
+		{"read a missing value", "GET", "/cfg/nodes/" + testSelf + "/values/NoSuchURL", "", nil, 404, ""},
+		{"set value", "PUT", "/cfg/nodes/" + testSelf + "/values/PhysState", `"POWER_ON"`, nil, 200, `"POWER_ON"`},
+		{"set a bad value", "PUT", "/cfg/nodes/" + testSelf + "/values/PhysState", `"NOT_A_STATE"`, nil, 400, "bad value for /PhysState"},
+		{"delete", "DELETE", "/cfg/nodes/" + testNew, "", nil, 200, `"kr3"`},
+		{"delete a missing node", "DELETE", "/cfg/nodes/" + testNew, "", nil, 404, "no such node"},
+
+		{"list dsc", "GET", "/dsc/nodes?fields=id", "", nil, 200, `"nodes"`},
+		{"read dsc", "GET", "/dsc/nodes/" + testSelf, "", nil, 200, `"kr0"`},
+		{"replace dsc", "PUT", "/dsc/nodes/" + testOther, `{"physState": "POWER_OFF"}`, nil, 200, `"POWER_OFF"`},
+		{"read dsc value", "GET", "/dsc/nodes/" + testOther + "/values/PhysState", "", nil, 200, `"POWER_OFF"`},
+
+		{"graph", "GET", "/mutations/graph", "", nil, 500, "not supported"},
+		{"services", "GET", "/services", "", nil, 500, "not supported"},
+		{"frozen", "GET", "/sme", "", nil, 200, `{"frozen":false}`},
+		{"thaw", "POST", "/sme/thaw", "", nil, 200, `{"frozen":false}`},
+		{"freeze, denied", "POST", "/sme/freeze", "", nil, 403, `{"error":{"code":403,"status":"Forbidden","message":"eve may not freeze"}}`},
+		{"enumerables", "GET", "/enumerables", "", nil, 200, "PhysState"},
+		{"metrics", "GET", "/metrics", "", nil, 200, ""},
+		{"no such endpoint", "GET", "/nosuchthing", "", nil, 404, "no such endpoint: GET /v1/nosuchthing"},
+		{"method not allowed", "POST", "/sme", "", nil, 405, "POST is not allowed on /v1/sme"},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			req, _ := http.NewRequest(v.method, s.URL+V1Prefix+v.path, strings.NewReader(v.body))
+			for k, h := range v.header {
+				switch h {
+				case "etag":
+					h = selfETag
+				case "old etag":
+					h = oldETag
+				}
+				req.Header.Set(k, h)
+			}
+			rsp, e := http.DefaultClient.Do(req)
+			if e != nil {
+				t.Fatal(e)
+			}
+			defer rsp.Body.Close()
+			b, _ := ioutil.ReadAll(rsp.Body)
+			if rsp.StatusCode != v.code {
+				t.Fatalf("expected %d, got %d: %s", v.code, rsp.StatusCode, b)
+			}
+			if !strings.Contains(string(b), v.contain) {
+				t.Errorf("expected the response to contain %s, got: %s", v.contain, b)
+			}
+			if rsp.StatusCode >= 400 {
+				var ve V1Error
+				if e := json.Unmarshal(b, &ve); e != nil || ve.Error.Code != v.code || ve.Error.Status != http.StatusText(v.code) || ve.Error.Message == "" {
+					t.Errorf("bad error response: %s", b)
+				}
+			}
+			if et := rsp.Header.Get("ETag"); et != "" && strings.Contains(v.path, testSelf) {
+				oldETag, selfETag = selfETag, et
+			}
+		})
+	}
+}
+
+// TestV1_PatchConflict tests that a patch is retried if the node changes under it, and fails once the retries run out
+func TestV1_PatchConflict(t *testing.T) {
+	self := core.NewNodeWithID(testSelf)
+	api := &changingAPI{APIClient: ktest.NewAPIClient(self)}
+	patch := []byte(`{"arch": "x86_64"}`)
+	api.changes = patchRetries
+	n, code, e := patchUpdate(api, testSelf, patch, "application/merge-patch+json", "")
+	if e != nil || code != http.StatusOK {
+		t.Fatalf("a patch of a node that changed %d times failed: %d %v", patchRetries, code, e)
+	}
+	if v, _ := n.GetValue("/Arch"); v.String() != "x86_64" {
+		t.Errorf("the patch wasn't applied: %v", v)
+	}
+	api.changes = patchRetries + 1
+	if _, code, e = patchUpdate(api, testSelf, patch, "application/merge-patch+json", ""); code != http.StatusConflict {
+		t.Errorf("expected %d for a node that keeps changing, got: %d %v", http.StatusConflict, code, e)
+	}
+	// patching several nodes falls back to patching each one as it is now
+	api.changes = 1
+	nl, code, e := patchNodes(api, map[string]json.RawMessage{testSelf: []byte(`{"arch": "aarch64"}`)}, "application/merge-patch+json")
+	if e != nil || len(nl.GetNodes()) != 1 || nl.GetNodes()[0].GetArch() != "aarch64" {
+		t.Errorf("patching nodes that changed failed: %d %v %v", code, e, nl)
+	}
+}
+
+// changingAPI changes a node under every conditional update, until it has done so changes times
+type changingAPI struct {
+	*ktest.APIClient
+	changes int
+}
+
+func (a *changingAPI) QueryUpdateIf(n lib.Node, version string) (lib.Node, error) {
+	if a.changes > 0 {
+		a.changes--
+		cur, _ := a.APIClient.QueryRead(n.ID().String())
+		cur.SetValue("/Nodename", reflect.ValueOf(fmt.Sprintf("changed%d", a.changes)))
+		a.APIClient.QueryUpdate(cur)
+	}
+	return a.APIClient.QueryUpdateIf(n, version)
+}
+
+// TestHTTPStatus tests how API errors map to HTTP statuses
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		e    error
+		code int
+	}{
+		{fmt.Errorf("not a gRPC error"), http.StatusTeapot},
+		{status.Error(codes.Unknown, ""), http.StatusTeapot},
+		{status.Error(codes.InvalidArgument, ""), http.StatusBadRequest},
+		{status.Error(codes.PermissionDenied, ""), http.StatusForbidden},
+		{status.Error(codes.NotFound, ""), http.StatusNotFound},
+		{status.Error(codes.Aborted, ""), http.StatusConflict},
+		{status.Error(codes.FailedPrecondition, ""), http.StatusPreconditionFailed},
+		{status.Error(codes.Unavailable, ""), http.StatusServiceUnavailable},
+	}
+	for _, v := range tests {
+		if c := httpStatus(v.e, http.StatusTeapot); c != v.code {
+			t.Errorf("%v: expected %d, got %d", v.e, v.code, c)
+		}
+	}
+}
@@ -113,7 +113,7 @@ var excs = map[string]reflect.Value{}
 type RFPiPower struct {
 	api    lib.APIClient
 	mutex  *sync.Mutex
-	queue  map[string][2]string // map[<nodename>][<mutation>, <nodeidstr>]
+	queue  map[string][3]string // map[<nodename>][<mutation>, <nodeidstr>, <traceparent>]
 	cfg    *pb.RFPiPowerConfig
 	mchan  <-chan lib.Event
 	dchan  chan<- lib.Event
@@ -223,7 +223,7 @@ func (pp *RFPiPower) Entry() {
 func (pp *RFPiPower) Init(api lib.APIClient) {
 	pp.api = api
 	pp.mutex = &sync.Mutex{}
-	pp.queue = make(map[string][2]string)
+	pp.queue = make(map[string][3]string)
 	pp.cfg = pp.NewConfig().(*pb.RFPiPowerConfig)
 }
 
@@ -249,12 +249,14 @@ func (pp *RFPiPower) fireChanges() {
 	stat := map[string][]string{}
 
 	idmap := map[string]string{}
+	tpmap := map[string]string{}
 
 	pp.mutex.Lock()
 	for m := range pp.queue {
 		c, n := pp.parseNodeName(m)
 
 		idmap[m] = pp.queue[m][1]
+		tpmap[m] = pp.queue[m][2]
 		switch pp.queue[m][0] {
 		case "UKtoOFF": // this actually just forces discovery
 			stat[c] = append(stat[c], n)
@@ -270,21 +272,21 @@ func (pp *RFPiPower) fireChanges() {
 		}
 	}
 
-	pp.queue = make(map[string][2]string)
+	pp.queue = make(map[string][3]string)
 	pp.mutex.Unlock()
 	for c := range on {
-		pp.fire(c, on[c], "on", idmap)
+		pp.fire(c, on[c], "on", idmap, tpmap)
 	}
 	for c := range off {
-		pp.fire(c, off[c], "off", idmap)
+		pp.fire(c, off[c], "off", idmap, tpmap)
 	}
 	for c := range stat {
-		pp.fire(c, stat[c], "state", idmap)
+		pp.fire(c, stat[c], "state", idmap, tpmap)
 	}
 
 }
 
-func (pp *RFPiPower) fire(c string, ns []string, cmd string, idmap map[string]string) {
+func (pp *RFPiPower) fire(c string, ns []string, cmd string, idmap map[string]string, tpmap map[string]string) {
 
 	srv, ok := pp.cfg.Servers[c]
 	if !ok {
@@ -302,18 +304,47 @@ func (pp *RFPiPower) fire(c string, ns []string, cmd string, idmap map[string]st
 	addr := srv.Ip + ":" + strconv.Itoa(int(srv.Port))
 	url := "http://" + addr + "/redfish/v1/Systems/" + c + "/Actions/ComputerSystem.Reset"
 
+	// one call controls many nodes, so each node gets a span for it in its own trace
+	spans := map[string]*core.Span{}
+	if pa, ok := pp.api.(*core.APIClient); ok {
+		for _, n := range ns {
+			if tp := tpmap[c+"n"+n]; tp != "" {
+				s := pa.Tracer().Start("redfish "+cmd, tp)
+				s.SetAttr("http.url", url)
+				s.SetAttr("kraken.node", idmap[c+"n"+n])
+				spans[c+"n"+n] = s
+			}
+		}
+	}
+	endSpans := func(err string) {
+		for _, s := range spans {
+			if err != "" {
+				s.SetError(err)
+			}
+			s.End()
+		}
+	}
+
 	httpClient := &http.Client{}
 	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(payLoad))
 	if err != nil {
 		pp.api.Logf(lib.LLERROR, "http PUT API request failed: %v", err)
+		endSpans(err.Error())
 		return
 	}
+	for _, s := range spans {
+		// we can only pass one trace to the BMC
+		req.Header.Set(core.TraceMetadataKey, s.Traceparent())
+		break
+	}
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		pp.api.Logf(lib.LLERROR, "http PUT API call failed: %v", err)
+		endSpans(err.Error())
 		return
 	}
+	endSpans("")
 
 	defer resp.Body.Close()
 	body, e := ioutil.ReadAll(resp.Body)
@@ -341,6 +372,7 @@ func (pp *RFPiPower) fire(c string, ns []string, cmd string, idmap map[string]st
 			&core.DiscoveryEvent{
 				URL:     url,
 				ValueID: vid,
+				Trace:   spans[c+"n"+r.ID].Traceparent(),
 			},
 		)
 		pp.dchan <- v
@@ -374,7 +406,7 @@ func (pp *RFPiPower) handleMutation(m lib.Event) {
 			fallthrough
 		case "HANGtoOFF":
 			pp.mutex.Lock()
-			pp.queue[nodename] = [2]string{me.Mutation[1], me.NodeCfg.ID().String()}
+			pp.queue[nodename] = [3]string{me.Mutation[1], me.NodeCfg.ID().String(), me.Trace}
 			pp.mutex.Unlock()
 			break
 		case "UKtoHANG": // we don't actually do this
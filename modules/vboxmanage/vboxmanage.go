@@ -156,6 +156,29 @@ func (*VBM) ConfigURL() string {
 	return any.GetTypeUrl()
 }
 
+var _ lib.ModuleWithConfigValidation = (*VBM)(nil)
+
+// ValidateConfig makes sure the polling interval is a duration, and that each server has an ip and a port.
+// It doesn't try to reach the servers.
+func (*VBM) ValidateConfig(cfg proto.Message) error {
+	ppcfg, ok := cfg.(*pb.VBMConfig)
+	if !ok {
+		return fmt.Errorf("invalid config type")
+	}
+	if d, e := time.ParseDuration(ppcfg.GetPollingInterval()); e != nil || d <= 0 {
+		return fmt.Errorf("polling_interval must be a positive duration, not %q", ppcfg.GetPollingInterval())
+	}
+	for name, srv := range ppcfg.GetServers() {
+		if srv.GetName() != name {
+			return fmt.Errorf("server %q has the name %q", name, srv.GetName())
+		}
+		if srv.GetIp() == "" || srv.GetPort() <= 0 || srv.GetPort() > 65535 {
+			return fmt.Errorf("server %q needs an ip and a port", name)
+		}
+	}
+	return nil
+}
+
 /*
  * lib.ModuleWithMutations & lib.ModuleWithDiscovery
  */
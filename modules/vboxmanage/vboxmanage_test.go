@@ -0,0 +1,46 @@
+package vboxmanage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+
+	pb "github.com/hpc/kraken/modules/vboxmanage/proto"
+)
+
+// TestVBM_ValidateConfig tests which configs are rejected
+func TestVBM_ValidateConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(*pb.VBMConfig)
+		err  string
+	}{
+		{"default", func(*pb.VBMConfig) {}, ""},
+		{"a server that doesn't resolve", func(c *pb.VBMConfig) { c.Servers["vbm"].Ip = "nosuchhost.invalid" }, ""},
+		{"no servers", func(c *pb.VBMConfig) { c.Servers = nil }, ""},
+		{"bad polling interval", func(c *pb.VBMConfig) { c.PollingInterval = "often" }, `polling_interval must be a positive duration, not "often"`},
+		{"zero polling interval", func(c *pb.VBMConfig) { c.PollingInterval = "0s" }, `polling_interval must be a positive duration, not "0s"`},
+		{"misnamed server", func(c *pb.VBMConfig) { c.Servers["vbm"].Name = "other" }, `server "vbm" has the name "other"`},
+		{"no ip", func(c *pb.VBMConfig) { c.Servers["vbm"].Ip = "" }, `server "vbm" needs an ip and a port`},
+		{"negative port", func(c *pb.VBMConfig) { c.Servers["vbm"].Port = -1 }, `server "vbm" needs an ip and a port`},
+		{"port out of range", func(c *pb.VBMConfig) { c.Servers["vbm"].Port = 65536 }, `server "vbm" needs an ip and a port`},
+	}
+	pp := &VBM{}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			cfg := pp.NewConfig().(*pb.VBMConfig)
+			v.edit(cfg)
+			e := pp.ValidateConfig(cfg)
+			if v.err == "" && e != nil {
+				t.Fatalf("a good config was rejected: %v", e)
+			}
+			if v.err != "" && (e == nil || !strings.Contains(e.Error(), v.err)) {
+				t.Fatalf("expected an error containing %q, got: %v", v.err, e)
+			}
+		})
+	}
+	if e := pp.ValidateConfig(&wrappers.StringValue{}); e == nil {
+		t.Errorf("a config of the wrong type was accepted")
+	}
+}
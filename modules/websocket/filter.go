@@ -0,0 +1,103 @@
+/* filter.go: server-side filtering of the events websocket clients subscribe to
+ *
+ * Author: J. Lowell Wofford <lowell@lanl.gov>
+ *
+ * This software is open source software available under the BSD-3 license.
+ * Copyright (c) 2018, Triad National Security, LLC
+ * See LICENSE file for details.
+ */
+
+package websocket
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// URL filters starting with this are regular expressions; otherwise they are globs
+const regexpPrefix = "re:"
+
+// A subFilter limits which events of a subscribed type a client gets.
+// An event must match every list that isn't empty.  A nil *subFilter matches everything.
+type subFilter struct {
+	nodes  map[string]bool
+	globs  []string
+	res    []*regexp.Regexp
+	values map[string]bool
+}
+
+func newSubFilter(nodes, urls, values []string) (f *subFilter, e error) {
+	if len(nodes) == 0 && len(urls) == 0 && len(values) == 0 {
+		return nil, nil
+	}
+	f = &subFilter{
+		nodes:  make(map[string]bool),
+		values: make(map[string]bool),
+	}
+	for _, n := range nodes {
+		f.nodes[n] = true
+	}
+	for _, u := range urls {
+		if strings.HasPrefix(u, regexpPrefix) {
+			re, e := regexp.Compile(strings.TrimPrefix(u, regexpPrefix))
+			if e != nil {
+				return nil, fmt.Errorf("bad URL regexp %s: %v", u, e)
+			}
+			f.res = append(f.res, re)
+			continue
+		}
+		if _, e := path.Match(u, ""); e != nil {
+			return nil, fmt.Errorf("bad URL glob %s: %v", u, e)
+		}
+		f.globs = append(f.globs, u)
+	}
+	for _, v := range values {
+		f.values[v] = true
+	}
+	return
+}
+
+// match decides if an event payload passes the filter
+func (f *subFilter) match(p *Payload) bool {
+	return f.matchNode(p.NodeId) && f.matchURL(p.URL) && f.matchValue(p.Value)
+}
+
+func (f *subFilter) matchNode(id string) bool {
+	return f == nil || len(f.nodes) == 0 || f.nodes[id]
+}
+
+func (f *subFilter) matchURL(u string) bool {
+	if f == nil || len(f.globs)+len(f.res) == 0 {
+		return true
+	}
+	for _, g := range f.globs {
+		if ok, _ := path.Match(g, u); ok {
+			return true
+		}
+	}
+	for _, re := range f.res {
+		if re.MatchString(u) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *subFilter) matchValue(v string) bool {
+	return f == nil || len(f.values) == 0 || f.values[v]
+}
+
+// exactURLs are the URL filters that name a single URL
+func (f *subFilter) exactURLs() (urls []string) {
+	if f == nil {
+		return
+	}
+	for _, g := range f.globs {
+		if !strings.ContainsAny(g, `*?[\`) {
+			urls = append(urls, g)
+		}
+	}
+	return
+}
@@ -0,0 +1,99 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestNewSubFilter tests which filters are nil, and which are rejected
+func TestNewSubFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		nodes  []string
+		urls   []string
+		values []string
+		isNil  bool
+		bad    bool
+	}{
+		{"empty", nil, nil, nil, true, false},
+		{"nodes", []string{"n1"}, nil, nil, false, false},
+		{"glob", nil, []string{"/Services/*/State"}, nil, false, false},
+		{"regexp", nil, []string{"re:^/Phys"}, nil, false, false},
+		{"values", nil, nil, []string{"POWER_ON"}, false, false},
+		{"bad glob", nil, []string{"/Services/[/State"}, nil, true, true},
+		{"bad regexp", nil, []string{"re:^(/Phys"}, nil, true, true},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			f, e := newSubFilter(v.nodes, v.urls, v.values)
+			if (e != nil) != v.bad {
+				t.Fatalf("expected error %v, got: %v", v.bad, e)
+			}
+			if (f == nil) != v.isNil {
+				t.Errorf("expected a nil filter %v, got: %+v", v.isNil, f)
+			}
+		})
+	}
+}
+
+// TestSubFilter_Match tests that an event must match every list of a filter that isn't empty
+func TestSubFilter_Match(t *testing.T) {
+	p := &Payload{NodeId: "n1", URL: "/Services/restapi/State", Value: "RUN"}
+	tests := []struct {
+		name   string
+		nodes  []string
+		urls   []string
+		values []string
+		match  bool
+	}{
+		{"no filter", nil, nil, nil, true},
+		{"node", []string{"n2", "n1"}, nil, nil, true},
+		{"other node", []string{"n2"}, nil, nil, false},
+		{"glob", nil, []string{"/Services/*/State"}, nil, true},
+		{"glob doesn't cross slashes", nil, []string{"/Services/*"}, nil, false},
+		{"exact URL", nil, []string{"/Services/restapi/State"}, nil, true},
+		{"regexp", nil, []string{"re:^/Services/"}, nil, true},
+		{"glob or regexp", nil, []string{"/PhysState", "re:State$"}, nil, true},
+		{"other URL", nil, []string{"/PhysState", "re:^/Phys"}, nil, false},
+		{"value", nil, nil, []string{"STOP", "RUN"}, true},
+		{"other value", nil, nil, []string{"STOP"}, false},
+		{"all lists", []string{"n1"}, []string{"/Services/*/State"}, []string{"RUN"}, true},
+		{"all lists but one", []string{"n1"}, []string{"/Services/*/State"}, []string{"STOP"}, false},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			f, e := newSubFilter(v.nodes, v.urls, v.values)
+			if e != nil {
+				t.Fatal(e)
+			}
+			if m := f.match(p); m != v.match {
+				t.Errorf("expected match %v, got %v", v.match, m)
+			}
+		})
+	}
+}
+
+// TestSubFilter_ExactURLs tests which URL filters name a single URL
+func TestSubFilter_ExactURLs(t *testing.T) {
+	tests := []struct {
+		name  string
+		urls  []string
+		exact []string
+	}{
+		{"no filter", nil, nil},
+		{"exact", []string{"/PhysState", "/RunState"}, []string{"/PhysState", "/RunState"}},
+		{"globs aren't exact", []string{"/PhysState", "/Services/*/State", "/Ifaces/?", "/Arch[0]", `/A\rch`}, []string{"/PhysState"}},
+		{"regexps aren't exact", []string{"re:/PhysState"}, nil},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			f, e := newSubFilter(nil, v.urls, nil)
+			if e != nil {
+				t.Fatal(e)
+			}
+			if u := f.exactURLs(); !reflect.DeepEqual(u, v.exact) {
+				t.Errorf("expected %v, got %v", v.exact, u)
+			}
+		})
+	}
+}
@@ -38,11 +38,17 @@ type WebSocketConfig struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Port           int32  `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
-	WriteWait      string `protobuf:"bytes,3,opt,name=writeWait,proto3" json:"writeWait,omitempty"`            // Time allowed to write a message to the peer.
-	PongWait       string `protobuf:"bytes,4,opt,name=pongWait,proto3" json:"pongWait,omitempty"`              // Time allowed to read the next pong message from the peer.
-	PingPeriod     string `protobuf:"bytes,5,opt,name=pingPeriod,proto3" json:"pingPeriod,omitempty"`          // Send pings to peer with this period. Must be less than pongWait.
-	MaxMessageSize int64  `protobuf:"varint,6,opt,name=maxMessageSize,proto3" json:"maxMessageSize,omitempty"` // Maximum message size allowed from peer.
+	Port              int32    `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+	WriteWait         string   `protobuf:"bytes,3,opt,name=writeWait,proto3" json:"writeWait,omitempty"`                   // Time allowed to write a message to the peer.
+	PongWait          string   `protobuf:"bytes,4,opt,name=pongWait,proto3" json:"pongWait,omitempty"`                     // Time allowed to read the next pong message from the peer.
+	PingPeriod        string   `protobuf:"bytes,5,opt,name=pingPeriod,proto3" json:"pingPeriod,omitempty"`                 // Send pings to peer with this period. Must be less than pongWait.
+	MaxMessageSize    int64    `protobuf:"varint,6,opt,name=maxMessageSize,proto3" json:"maxMessageSize,omitempty"`        // Maximum message size allowed from peer.
+	TlsCert           string   `protobuf:"bytes,7,opt,name=tlsCert,proto3" json:"tlsCert,omitempty"`                       // PEM certificate; serve wss if set (needs tlsKey)
+	TlsKey            string   `protobuf:"bytes,8,opt,name=tlsKey,proto3" json:"tlsKey,omitempty"`                         // PEM key for tlsCert
+	ClientCa          string   `protobuf:"bytes,9,opt,name=clientCa,proto3" json:"clientCa,omitempty"`                     // PEM CA certificates; clients with certificates they signed are authenticated
+	RequireClientCert bool     `protobuf:"varint,10,opt,name=requireClientCert,proto3" json:"requireClientCert,omitempty"` // Reject TLS connections without a valid client certificate.
+	TokenFile         string   `protobuf:"bytes,11,opt,name=tokenFile,proto3" json:"tokenFile,omitempty"`                  // Bearer tokens, one per line as "<token> [<name>]".
+	AllowedOrigins    []string `protobuf:"bytes,12,rep,name=allowedOrigins,proto3" json:"allowedOrigins,omitempty"`        // Origins that may connect from a browser; "*" allows any.
 }
 
 func (x *WebSocketConfig) Reset() {
@@ -112,11 +118,53 @@ func (x *WebSocketConfig) GetMaxMessageSize() int64 {
 	return 0
 }
 
+func (x *WebSocketConfig) GetTlsCert() string {
+	if x != nil {
+		return x.TlsCert
+	}
+	return ""
+}
+
+func (x *WebSocketConfig) GetTlsKey() string {
+	if x != nil {
+		return x.TlsKey
+	}
+	return ""
+}
+
+func (x *WebSocketConfig) GetClientCa() string {
+	if x != nil {
+		return x.ClientCa
+	}
+	return ""
+}
+
+func (x *WebSocketConfig) GetRequireClientCert() bool {
+	if x != nil {
+		return x.RequireClientCert
+	}
+	return false
+}
+
+func (x *WebSocketConfig) GetTokenFile() string {
+	if x != nil {
+		return x.TokenFile
+	}
+	return ""
+}
+
+func (x *WebSocketConfig) GetAllowedOrigins() []string {
+	if x != nil {
+		return x.AllowedOrigins
+	}
+	return nil
+}
+
 var File_websocket_proto protoreflect.FileDescriptor
 
 var file_websocket_proto_rawDesc = []byte{
 	0x0a, 0x0f, 0x77, 0x65, 0x62, 0x73, 0x6f, 0x63, 0x6b, 0x65, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x22, 0xa7, 0x01, 0x0a, 0x0f, 0x57, 0x65, 0x62, 0x53, 0x6f, 0x63, 0x6b, 0x65, 0x74, 0x43,
+	0x6f, 0x22, 0xe9, 0x02, 0x0a, 0x0f, 0x57, 0x65, 0x62, 0x53, 0x6f, 0x63, 0x6b, 0x65, 0x74, 0x43,
 	0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20,
 	0x01, 0x28, 0x05, 0x52, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x77, 0x72, 0x69,
 	0x74, 0x65, 0x57, 0x61, 0x69, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x77, 0x72,
@@ -126,8 +174,20 @@ var file_websocket_proto_rawDesc = []byte{
 	0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x70, 0x69, 0x6e, 0x67, 0x50, 0x65, 0x72,
 	0x69, 0x6f, 0x64, 0x12, 0x26, 0x0a, 0x0e, 0x6d, 0x61, 0x78, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
 	0x65, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x6d, 0x61, 0x78,
-	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x42, 0x09, 0x5a, 0x07, 0x2e,
-	0x3b, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x74,
+	0x6c, 0x73, 0x43, 0x65, 0x72, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x6c,
+	0x73, 0x43, 0x65, 0x72, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x6c, 0x73, 0x4b, 0x65, 0x79, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x6c, 0x73, 0x4b, 0x65, 0x79, 0x12, 0x1a, 0x0a,
+	0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x43, 0x61, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x43, 0x61, 0x12, 0x2c, 0x0a, 0x11, 0x72, 0x65, 0x71,
+	0x75, 0x69, 0x72, 0x65, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x18, 0x0a,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x11, 0x72, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65, 0x43, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x6f, 0x6b, 0x65, 0x6e,
+	0x46, 0x69, 0x6c, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x74, 0x6f, 0x6b, 0x65,
+	0x6e, 0x46, 0x69, 0x6c, 0x65, 0x12, 0x26, 0x0a, 0x0e, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64,
+	0x4f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x73, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0e, 0x61,
+	0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x4f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x73, 0x42, 0x09, 0x5a,
+	0x07, 0x2e, 0x3b, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -20,7 +20,10 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	cpb "github.com/hpc/kraken/core/proto"
@@ -43,18 +46,54 @@ var _ lib.ModuleWithDiscovery = (*WebSocket)(nil)
 
 const WsStateURL = "/Services/websocket/State"
 
+// stopTimeout is how long Stop waits for requests in progress to finish
+const stopTimeout = 5 * time.Second
+
 type Command uint8
 
 const (
 	SUBSCRIBE Command = iota
 	UNSUBSCRIBE
+	SNAPSHOT
 )
 
+var CommandString = map[Command]string{
+	SUBSCRIBE:   "SUBSCRIBE",
+	UNSUBSCRIBE: "UNSUBSCRIBE",
+	SNAPSHOT:    "SNAPSHOT",
+}
+
+var CommandValue = map[string]Command{
+	"SUBSCRIBE":   SUBSCRIBE,
+	"UNSUBSCRIBE": UNSUBSCRIBE,
+	"SNAPSHOT":    SNAPSHOT,
+}
+
+// UnmarshalJSON lets commands be given by name or number
+func (c *Command) UnmarshalJSON(b []byte) error {
+	var s string
+	if e := json.Unmarshal(b, &s); e == nil {
+		v, ok := CommandValue[s]
+		if !ok {
+			return fmt.Errorf("unknown command: %s", s)
+		}
+		*c = v
+		return nil
+	}
+	var n uint8
+	if e := json.Unmarshal(b, &n); e != nil {
+		return fmt.Errorf("command must be a name or number: %s", string(b))
+	}
+	*c = Command(n)
+	return nil
+}
+
 type WebSocket struct {
 	cfg    *pb.WebSocketConfig
 	api    lib.APIClient
 	router *mux.Router
 	srv    *http.Server
+	sec    *lib.HTTPSecurity
 	echan  <-chan lib.Event
 	dchan  chan<- lib.Event
 	hub    *Hub
@@ -63,20 +102,38 @@ type WebSocket struct {
 }
 
 type Hub struct {
-	clients    map[*Client]bool // Registered clients.
-	broadcast  chan *Payload    // Messages from the event stream.
-	action     chan *Action     // Messages from the websocket Clients.
-	register   chan *Client     // Register requests from the clients.
-	unregister chan *Client     // Unregister requests from clients.
+	clients    map[*Client]bool        // Registered clients.
+	broadcast  chan *Payload           // Messages from the event stream.
+	action     chan *Action            // Messages from the websocket Clients.
+	register   chan *Client            // Register requests from the clients.
+	unregister chan *Client            // Unregister requests from clients.
+	snapshots  chan *snapshotResult    // Snapshots that have been taken for clients.
+	held       map[*Client]*heldEvents // Clients waiting for snapshots, and the events held for them until they're sent.
 	api        lib.APIClient
 }
 
+// heldEvents are events for a client that is waiting for snapshots; they're sent once the snapshots are
+type heldEvents struct {
+	pending int
+	events  []*Payload
+}
+
+// A snapshotResult is a snapshot taken for a client, or why it couldn't be
+type snapshotResult struct {
+	client   *Client
+	snapshot *Snapshot
+	err      error
+}
+
 type Client struct {
-	hub  *Hub
-	conn *websocket.Conn        // The websocket connection.
-	send chan *Payload          // Buffered channel of outbound messages.
-	w    *WebSocket             // Web Socket
-	subs map[lib.EventType]bool // List of event types that client is subscribed to
+	hub    *Hub
+	conn   *websocket.Conn              // The websocket connection.
+	send   chan interface{}             // Buffered channel of outbound messages.
+	w      *WebSocket                   // Web Socket
+	subs   map[lib.EventType]*subFilter // Event types that client is subscribed to, and how they're filtered (nil for no filter)
+	smutex *sync.Mutex                  // Protects subs
+	events <-chan lib.Event             // Replaying clients get their own event stream instead of the hub broadcast
+	cancel context.CancelFunc           // Stops the replaying client's event stream
 }
 
 type Payload struct {
@@ -85,6 +142,7 @@ type Payload struct {
 	Data   string        `json:"data"`
 	NodeId string        `json:"nodeid"`
 	Value  string        `json:"value"`
+	Seq    uint64        `json:"seq"`
 }
 
 func (p *Payload) String() string {
@@ -92,9 +150,31 @@ func (p *Payload) String() string {
 }
 
 type Action struct {
-	Command   Command `json:"command"`
-	EventType string  `json:"type"`
+	Command   Command  `json:"command"`
+	EventType string   `json:"type"`
+	Nodes     []string `json:"nodes"`    // only events for these node IDs
+	URLs      []string `json:"urls"`     // only events for URLs matching these globs, or regexps prefixed with "re:"
+	Values    []string `json:"values"`   // only events with these values
+	Snapshot  bool     `json:"snapshot"` // send a snapshot of current state that matches the filter, before more events
 	Client    *Client
+	err       error // set if the action couldn't be parsed
+}
+
+// A Snapshot is the current state of everything that matches a filter
+type Snapshot struct {
+	Snapshot []*SnapshotValue `json:"snapshot"`
+}
+
+type SnapshotValue struct {
+	NodeId string `json:"nodeid"`
+	URL    string `json:"url"`
+	Cfg    string `json:"cfg"`
+	Dsc    string `json:"dsc"`
+}
+
+// An Error tells a client that something it asked for failed
+type Error struct {
+	Error string `json:"error"`
 }
 
 func (w *WebSocket) Entry() {
@@ -138,7 +218,13 @@ func (w *WebSocket) Entry() {
 }
 
 func (w *WebSocket) handleEvent(ev lib.Event) {
-	var payload = &Payload{}
+	if payload := w.eventPayload(ev); payload != nil {
+		w.hub.broadcast <- payload
+	}
+}
+
+// eventPayload converts an event into a payload for clients, or returns nil if we don't know how
+func (w *WebSocket) eventPayload(ev lib.Event) (payload *Payload) {
 	nodeID, url := lib.NodeURLSplit(ev.URL())
 	switch ev.Type() {
 	case lib.Event_STATE_MUTATION:
@@ -167,11 +253,21 @@ func (w *WebSocket) handleEvent(ev lib.Event) {
 		}
 	default:
 		w.api.Logf(lib.LLDEBUG, "got unknown event: %+v\n", ev.Data())
+		return nil
 	}
-	w.hub.broadcast <- payload
+	payload.Seq = ev.Seq()
+	return
 }
 
-func (w *WebSocket) Stop() { os.Exit(0) }
+// Stop lets requests in progress finish, for up to stopTimeout, then exits
+func (w *WebSocket) Stop() {
+	if w.srv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+		w.srvStop(ctx)
+		cancel()
+	}
+	os.Exit(0)
+}
 
 func (w *WebSocket) Name() string { return "github.com/hpc/kraken/modules/websocket" }
 
@@ -186,7 +282,7 @@ func (w *WebSocket) UpdateConfig(cfg proto.Message) (e error) {
 		w.api.Logf(lib.LLDEBUG, "updating config for websocket: %v", wc)
 		w.cfg = wc
 		if w.srv != nil {
-			w.srvStop() // we just stop, entry will (re)start
+			w.srvStop(context.Background()) // we just stop, entry will (re)start
 		}
 		return
 	}
@@ -236,18 +332,41 @@ func (w *WebSocket) startServer() {
 	}
 
 	for {
+		sec, e := lib.NewHTTPSecurity(lib.HTTPSecurityConfig{
+			CertFile:          w.cfg.TlsCert,
+			KeyFile:           w.cfg.TlsKey,
+			ClientCAFile:      w.cfg.ClientCa,
+			RequireClientCert: w.cfg.RequireClientCert,
+			TokenFile:         w.cfg.TokenFile,
+			AllowedOrigins:    w.cfg.AllowedOrigins,
+		})
+		if e != nil {
+			// we don't serve anything insecurely; wait and try again, maybe with a new config
+			w.api.Logf(lib.LLERROR, "websocket can't be secured as configured: %v", e)
+			w.srv = nil
+			time.Sleep(10 * time.Second)
+			continue
+		}
+		w.sec = sec
 		w.srv = &http.Server{
+			// CORS answers preflight requests, which don't carry credentials, before we authenticate
 			Handler: handlers.CORS(
 				handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}),
-				handlers.AllowedOrigins([]string{"*"}),
+				handlers.AllowedOriginValidator(sec.AllowedOrigin),
 				handlers.AllowedMethods([]string{"PUT", "GET", "POST", "DELETE"}),
-			)(w.router),
+			)(sec.Handler(w.router)),
 			Addr:         url.Host,
+			TLSConfig:    sec.TLSConfig(),
 			WriteTimeout: 15 * time.Second,
 			ReadTimeout:  15 * time.Second,
 		}
-		w.api.Logf(lib.LLINFO, "websocket is listening on: %s\n", w.srv.Addr)
-		if e := w.srv.ListenAndServe(); e != nil {
+		w.api.Logf(lib.LLINFO, "websocket is listening on: %s://%s\n", sec.Scheme(), w.srv.Addr)
+		if sec.TLSConfig() != nil {
+			e = w.srv.ListenAndServeTLS("", "")
+		} else {
+			e = w.srv.ListenAndServe()
+		}
+		if e != nil {
 			if e != http.ErrServerClosed {
 				w.api.Logf(lib.LLNOTICE, "http stopped: %v\n", e)
 			}
@@ -257,9 +376,9 @@ func (w *WebSocket) startServer() {
 	}
 }
 
-func (w *WebSocket) srvStop() {
+func (w *WebSocket) srvStop(ctx context.Context) {
 	w.api.Log(lib.LLDEBUG, "websocket is shutting down listener")
-	w.srv.Shutdown(context.Background())
+	w.srv.Shutdown(ctx)
 }
 
 func init() {
@@ -285,7 +404,9 @@ func (w *WebSocket) newHub() *Hub {
 		action:     make(chan *Action),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		snapshots:  make(chan *snapshotResult),
 		clients:    make(map[*Client]bool),
+		held:       make(map[*Client]*heldEvents),
 		api:        w.api,
 	}
 }
@@ -301,33 +422,193 @@ func (h *Hub) run() {
 		case client := <-h.unregister:
 			h.api.Logf(lib.LLDDDEBUG, "hub unregistering client %p", client)
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
+				h.drop(client)
 			}
 			h.api.Logf(lib.LLDDDEBUG, "hub client list: %+v", h.clients)
 		case messages := <-h.broadcast:
 			for client := range h.clients {
-				select {
-				case client.send <- messages:
-				default:
-					h.api.Logf(lib.LLERROR, "Websocket channel buffer overflow. closing websocket. messages not sent: %v", messages)
-					close(client.send)
-					delete(h.clients, client)
+				if client.events != nil || !client.wants(messages) {
+					// this client has its own event stream, or doesn't want this
+					continue
 				}
+				h.sendTo(client, messages)
 			}
+		case r := <-h.snapshots:
+			h.sendSnapshot(r)
 		case action := <-h.action:
-			switch action.Command {
-			case SUBSCRIBE:
-				h.api.Logf(lib.LLDDDEBUG, "Subscribing to: %v", action.EventType)
-				action.Client.subscribeEvent(lib.EventTypeValue[action.EventType])
-			case UNSUBSCRIBE:
-				h.api.Logf(lib.LLDDDEBUG, "Unsubscribing from: %v", action.EventType)
-				action.Client.unsubscribeEvent(lib.EventTypeValue[action.EventType])
-			default:
-				h.api.Logf(lib.LLDDEBUG, "Hub received action that has unknown command: %v", action.Command)
+			if _, ok := h.clients[action.Client]; !ok {
+				// the client went away
+				continue
+			}
+			if e := h.handleAction(action); e != nil {
+				h.api.Logf(lib.LLDEBUG, "websocket client %p action failed: %v", action.Client, e)
+				h.sendTo(action.Client, &Error{Error: e.Error()})
+			}
+		}
+	}
+}
+
+// handleAction carries out a client's request
+func (h *Hub) handleAction(action *Action) (e error) {
+	if action.err != nil {
+		return action.err
+	}
+	f, e := newSubFilter(action.Nodes, action.URLs, action.Values)
+	if e != nil {
+		return
+	}
+	switch action.Command {
+	case SUBSCRIBE:
+		h.api.Logf(lib.LLDDDEBUG, "Subscribing to: %v", action.EventType)
+		et, ok := lib.EventTypeValue[action.EventType]
+		if !ok {
+			return fmt.Errorf("unknown event type: %s", action.EventType)
+		}
+		action.Client.subscribeEvent(et, f)
+		if !action.Snapshot {
+			return
+		}
+	case UNSUBSCRIBE:
+		h.api.Logf(lib.LLDDDEBUG, "Unsubscribing from: %v", action.EventType)
+		et, ok := lib.EventTypeValue[action.EventType]
+		if !ok {
+			return fmt.Errorf("unknown event type: %s", action.EventType)
+		}
+		action.Client.unsubscribeEvent(et)
+		return
+	case SNAPSHOT:
+	default:
+		return fmt.Errorf("unknown command: %v", action.Command)
+	}
+	h.takeSnapshot(action.Client, f)
+	return
+}
+
+// takeSnapshot takes a snapshot for a client without holding up the hub.
+// Events broadcast while it's taken are held, so they're sent after it.
+func (h *Hub) takeSnapshot(client *Client, f *subFilter) {
+	if h.held[client] == nil {
+		h.held[client] = &heldEvents{}
+	}
+	h.held[client].pending++
+	go func() {
+		s, e := h.snapshot(f)
+		h.snapshots <- &snapshotResult{client: client, snapshot: s, err: e}
+	}()
+}
+
+// sendSnapshot sends a client its snapshot, then the events held for it if it isn't waiting for more
+func (h *Hub) sendSnapshot(r *snapshotResult) {
+	held, ok := h.held[r.client]
+	if !ok {
+		// the client went away
+		return
+	}
+	if held.pending--; held.pending == 0 {
+		delete(h.held, r.client)
+	}
+	if r.err != nil {
+		h.sendTo(r.client, &Error{Error: fmt.Sprintf("failed to take snapshot: %v", r.err)})
+	} else {
+		h.sendTo(r.client, r.snapshot)
+	}
+	if held.pending > 0 {
+		return
+	}
+	for _, ev := range held.events {
+		if _, ok := h.clients[r.client]; !ok {
+			return
+		}
+		h.sendTo(r.client, ev)
+	}
+}
+
+// sendTo queues a message for a client, dropping the client if it can't keep up.
+// Events for a client that is waiting for a snapshot are held until it's sent.
+func (h *Hub) sendTo(client *Client, message interface{}) {
+	if held, ok := h.held[client]; ok {
+		if ev, ok := message.(*Payload); ok {
+			if len(held.events) >= cap(client.send) {
+				h.api.Logf(lib.LLERROR, "Websocket client waited too long for a snapshot. closing websocket. message not sent: %v", message)
+				h.drop(client)
+				return
+			}
+			held.events = append(held.events, ev)
+			return
+		}
+	}
+	select {
+	case client.send <- message:
+	default:
+		h.api.Logf(lib.LLERROR, "Websocket channel buffer overflow. closing websocket. message not sent: %v", message)
+		h.drop(client)
+	}
+}
+
+// drop closes a client's send channel and forgets it
+func (h *Hub) drop(client *Client) {
+	close(client.send)
+	delete(h.clients, client)
+	delete(h.held, client)
+}
+
+// snapshot gets the current cfg and dsc values that match a filter
+func (h *Hub) snapshot(f *subFilter) (s *Snapshot, e error) {
+	cfgs, e := h.api.QueryReadAll()
+	if e != nil {
+		return
+	}
+	dscs, e := h.api.QueryReadAllDsc()
+	if e != nil {
+		return
+	}
+	dscMap := make(map[string]lib.Node, len(dscs))
+	for _, n := range dscs {
+		dscMap[n.ID().String()] = n
+	}
+	s = &Snapshot{Snapshot: []*SnapshotValue{}}
+	for _, cfg := range cfgs {
+		id := cfg.ID().String()
+		if !f.matchNode(id) {
+			continue
+		}
+		dsc, ok := dscMap[id]
+		if !ok {
+			dsc = core.NewNodeWithID(id)
+		}
+		// every URL that's set in either node, plus any the filter names, so values that are still zero show up
+		urls := map[string]bool{}
+		for _, u := range f.exactURLs() {
+			urls[u] = true
+		}
+		for _, n := range []lib.Node{cfg, dsc} {
+			diff, _ := n.Diff(core.NewNodeWithID(id), "")
+			for _, u := range diff {
+				urls[u] = true
+			}
+		}
+		sorted := make([]string, 0, len(urls))
+		for u := range urls {
+			if f.matchURL(u) {
+				sorted = append(sorted, u)
+			}
+		}
+		sort.Strings(sorted)
+		for _, u := range sorted {
+			sv := &SnapshotValue{NodeId: id, URL: u}
+			if v, e := cfg.GetValue(u); e == nil {
+				sv.Cfg = lib.ValueToString(v)
+			}
+			if v, e := dsc.GetValue(u); e == nil {
+				sv.Dsc = lib.ValueToString(v)
+			}
+			if !f.matchValue(sv.Cfg) && !f.matchValue(sv.Dsc) {
+				continue
 			}
+			s.Snapshot = append(s.Snapshot, sv)
 		}
 	}
+	return
 }
 
 // write sends messages from the hub to the websocket connection.
@@ -360,12 +641,17 @@ func (c *Client) write() {
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
-			if _, ok := c.subs[message.Type]; ok {
-				c.w.api.Logf(lib.LLDDDEBUG, "sending message to client: %v\n", message)
-				err := c.conn.WriteJSON(message)
-				if err != nil {
-					c.w.api.Logf(lib.LLERROR, "Error writing json to websocket connection%v\n", err)
-				}
+			c.writeMessage(message)
+		case ev, ok := <-c.events:
+			if !ok {
+				c.w.api.Logf(lib.LLDDEBUG, "event stream for client %p closed", c)
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if message := c.w.eventPayload(ev); message != nil && c.wants(message) {
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+				c.writeMessage(message)
 			}
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
@@ -376,6 +662,23 @@ func (c *Client) write() {
 	}
 }
 
+// writeMessage sends a message to the client
+func (c *Client) writeMessage(message interface{}) {
+	c.w.api.Logf(lib.LLDDDEBUG, "sending message to client: %v\n", message)
+	err := c.conn.WriteJSON(message)
+	if err != nil {
+		c.w.api.Logf(lib.LLERROR, "Error writing json to websocket connection%v\n", err)
+	}
+}
+
+// wants decides if the client is subscribed to an event
+func (c *Client) wants(message *Payload) bool {
+	c.smutex.Lock()
+	defer c.smutex.Unlock()
+	f, ok := c.subs[message.Type]
+	return ok && f.match(message)
+}
+
 // read checks for close messages from the websocket connection.
 //
 // The application runs read in a per-connection goroutine. The application
@@ -385,6 +688,9 @@ func (c *Client) read() {
 	defer func() {
 		c.hub.unregister <- c
 		c.conn.Close()
+		if c.cancel != nil {
+			c.cancel()
+		}
 	}()
 	pongWait, err := time.ParseDuration(c.w.cfg.PongWait)
 	if err != nil {
@@ -406,18 +712,27 @@ func (c *Client) read() {
 		action := &Action{
 			Client: c,
 		}
-		json.Unmarshal(message, &action)
+		if err := json.Unmarshal(message, &action); err != nil {
+			c.w.api.Logf(lib.LLDEBUG, "websocket client %p sent a bad action: %v", c, err)
+			c.hub.action <- &Action{Client: c, err: fmt.Errorf("bad action: %v", err)}
+			continue
+		}
 		c.w.api.Logf(lib.LLDDDEBUG, "client got message from websocket connection: %v", action)
 		c.hub.action <- action
 	}
 	c.w.api.Logf(lib.LLDDEBUG, "Closing websocket client: %p\n", c)
 }
 
-func (c *Client) subscribeEvent(t lib.EventType) {
-	c.subs[t] = true
+// subscribeEvent subscribes to an event type, replacing any filter we had for it
+func (c *Client) subscribeEvent(t lib.EventType, f *subFilter) {
+	c.smutex.Lock()
+	defer c.smutex.Unlock()
+	c.subs[t] = f
 }
 
 func (c *Client) unsubscribeEvent(t lib.EventType) {
+	c.smutex.Lock()
+	defer c.smutex.Unlock()
 	delete(c.subs, t)
 }
 
@@ -425,9 +740,44 @@ func (w *WebSocket) serveWs(hub *Hub, wrt http.ResponseWriter, req *http.Request
 	var upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
+		CheckOrigin:     w.sec.CheckOrigin,
+	}
+
+	// ?nodes=, ?urls= and ?values= (comma separated) filter the event types subscribed to with ?types=
+	split := func(param string) []string {
+		if v := req.URL.Query().Get(param); v != "" {
+			return strings.Split(v, ",")
+		}
+		return nil
+	}
+	f, err := newSubFilter(split("nodes"), split("urls"), split("values"))
+	if err != nil {
+		http.Error(wrt, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// ?types=STATE_CHANGE,DISCOVERY subscribes to event types up front
+	subs := make(map[lib.EventType]*subFilter)
+	for _, t := range split("types") {
+		et, ok := lib.EventTypeValue[t]
+		if !ok {
+			http.Error(wrt, fmt.Sprintf("unknown event type: %s", t), http.StatusBadRequest)
+			return
+		}
+		subs[et] = f
+	}
+	// ?snapshot=true sends a snapshot of the current state that matches the filter before any events
+	snapshot := req.URL.Query().Get("snapshot") == "true"
+	// ?from=<seq> replays journaled events starting at that sequence number before sending new ones
+	var from uint64
+	if fs := req.URL.Query().Get("from"); fs != "" {
+		if from, err = strconv.ParseUint(fs, 10, 64); err != nil {
+			http.Error(wrt, fmt.Sprintf("bad sequence number: %s", fs), http.StatusBadRequest)
+			return
+		}
+		if snapshot {
+			http.Error(wrt, "from and snapshot can't be used together", http.StatusBadRequest)
+			return
+		}
 	}
 
 	conn, err := upgrader.Upgrade(wrt, req, nil)
@@ -436,9 +786,22 @@ func (w *WebSocket) serveWs(hub *Hub, wrt http.ResponseWriter, req *http.Request
 		return
 	}
 	// Creating client with buffered payload channel set to 50. This might have to be increased if we have a lot of nodes
-	client := &Client{hub: hub, conn: conn, send: make(chan *Payload, 50), w: w, subs: make(map[lib.EventType]bool)}
+	client := &Client{hub: hub, conn: conn, send: make(chan interface{}, 50), w: w, subs: subs, smutex: &sync.Mutex{}}
+	if from > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		if client.events, err = w.api.Watch(ctx, from, nil); err != nil {
+			w.api.Logf(lib.LLERROR, "Error starting event replay for websocket client: %v", err)
+			cancel()
+			conn.Close()
+			return
+		}
+		client.cancel = cancel
+	}
 	w.api.Logf(lib.LLDDDEBUG, "websocket added new client: %p\n", client)
 	client.hub.register <- client
+	if snapshot {
+		client.hub.action <- &Action{Command: SNAPSHOT, Nodes: split("nodes"), URLs: split("urls"), Values: split("values"), Client: client}
+	}
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
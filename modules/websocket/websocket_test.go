@@ -0,0 +1,197 @@
+package websocket
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hpc/kraken/core"
+	"github.com/hpc/kraken/core/ktesting"
+	"github.com/hpc/kraken/lib"
+
+	cpb "github.com/hpc/kraken/core/proto"
+)
+
+const testID = "123e4567-e89b-12d3-a456-426655440000"
+
+// testHub is a hub for one client, over a node that is POWER_ON in cfg and POWER_OFF in dsc.  It isn't running.
+func testHub(t *testing.T) (*Hub, *Client) {
+	self := core.NewNodeWithID(testID)
+	self.SetValue("/Nodename", reflect.ValueOf("kr0"))
+	self.SetValue("/PhysState", reflect.ValueOf(cpb.Node_POWER_ON))
+	api := ktest.NewAPIClient(self)
+	dsc, _ := api.QueryReadDsc(testID)
+	dsc.SetValue("/PhysState", reflect.ValueOf(cpb.Node_POWER_OFF))
+	if _, e := api.QueryUpdateDsc(dsc); e != nil {
+		t.Fatal(e)
+	}
+	h := (&WebSocket{api: api}).newHub()
+	c := &Client{hub: h, send: make(chan interface{}, 50), subs: map[lib.EventType]*subFilter{}, smutex: &sync.Mutex{}}
+	h.clients[c] = true
+	return h, c
+}
+
+// TestHub_HandleAction tests what each command does, and the errors a client gets
+func TestHub_HandleAction(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   *Action
+		err      string
+		subs     map[lib.EventType]bool // subscriptions after the action
+		snapshot []*SnapshotValue       // the snapshot sent, if any
+	}{
+		{
+			name:   "bad action",
+			action: &Action{err: fmt.Errorf("bad action: oops")},
+			err:    "bad action: oops",
+			subs:   map[lib.EventType]bool{lib.Event_DISCOVERY: true},
+		},
+		{
+			name:   "unknown command",
+			action: &Action{Command: Command(99)},
+			err:    "unknown command",
+			subs:   map[lib.EventType]bool{lib.Event_DISCOVERY: true},
+		},
+		{
+			name:   "bad filter",
+			action: &Action{Command: SUBSCRIBE, EventType: "STATE_CHANGE", URLs: []string{"re:("}},
+			err:    "bad URL regexp",
+			subs:   map[lib.EventType]bool{lib.Event_DISCOVERY: true},
+		},
+		{
+			name:   "subscribe",
+			action: &Action{Command: SUBSCRIBE, EventType: "STATE_CHANGE"},
+			subs:   map[lib.EventType]bool{lib.Event_DISCOVERY: true, lib.Event_STATE_CHANGE: true},
+		},
+		{
+			name:   "subscribe to an unknown type",
+			action: &Action{Command: SUBSCRIBE, EventType: "FOO"},
+			err:    "unknown event type: FOO",
+			subs:   map[lib.EventType]bool{lib.Event_DISCOVERY: true},
+		},
+		{
+			name:   "unsubscribe",
+			action: &Action{Command: UNSUBSCRIBE, EventType: "DISCOVERY"},
+			subs:   map[lib.EventType]bool{},
+		},
+		{
+			name:   "unsubscribe from an unknown type",
+			action: &Action{Command: UNSUBSCRIBE, EventType: "FOO"},
+			err:    "unknown event type: FOO",
+			subs:   map[lib.EventType]bool{lib.Event_DISCOVERY: true},
+		},
+		{
+			name:     "subscribe with a snapshot",
+			action:   &Action{Command: SUBSCRIBE, EventType: "STATE_CHANGE", URLs: []string{"/PhysState"}, Snapshot: true},
+			subs:     map[lib.EventType]bool{lib.Event_DISCOVERY: true, lib.Event_STATE_CHANGE: true},
+			snapshot: []*SnapshotValue{{NodeId: testID, URL: "/PhysState", Cfg: "POWER_ON", Dsc: "POWER_OFF"}},
+		},
+		{
+			name:     "snapshot",
+			action:   &Action{Command: SNAPSHOT, URLs: []string{"/Nodename", "/Arch"}},
+			subs:     map[lib.EventType]bool{lib.Event_DISCOVERY: true},
+			snapshot: []*SnapshotValue{{NodeId: testID, URL: "/Arch"}, {NodeId: testID, URL: "/Nodename", Cfg: "kr0", Dsc: "kr0"}},
+		},
+		{
+			name:     "snapshot of a value",
+			action:   &Action{Command: SNAPSHOT, Values: []string{"POWER_OFF"}},
+			subs:     map[lib.EventType]bool{lib.Event_DISCOVERY: true},
+			snapshot: []*SnapshotValue{{NodeId: testID, URL: "/PhysState", Cfg: "POWER_ON", Dsc: "POWER_OFF"}},
+		},
+		{
+			name:     "snapshot of another node",
+			action:   &Action{Command: SNAPSHOT, Nodes: []string{"nosuchnode"}},
+			subs:     map[lib.EventType]bool{lib.Event_DISCOVERY: true},
+			snapshot: []*SnapshotValue{},
+		},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			h, c := testHub(t)
+			c.subscribeEvent(lib.Event_DISCOVERY, nil)
+			v.action.Client = c
+			e := h.handleAction(v.action)
+			if v.err == "" && e != nil {
+				t.Fatalf("action failed: %v", e)
+			}
+			if v.err != "" && (e == nil || !strings.Contains(e.Error(), v.err)) {
+				t.Fatalf("expected an error containing %q, got: %v", v.err, e)
+			}
+			subs := map[lib.EventType]bool{}
+			for et := range c.subs {
+				subs[et] = true
+			}
+			if !reflect.DeepEqual(subs, v.subs) {
+				t.Errorf("expected subscriptions %v, got %v", v.subs, subs)
+			}
+			if v.snapshot == nil {
+				if len(h.held) != 0 {
+					t.Errorf("a snapshot was taken")
+				}
+				return
+			}
+			h.sendSnapshot(<-h.snapshots)
+			s, ok := (<-c.send).(*Snapshot)
+			if !ok {
+				t.Fatalf("the client wasn't sent a snapshot")
+			}
+			if !reflect.DeepEqual(s.Snapshot, v.snapshot) {
+				t.Errorf("expected snapshot %s, got %s", snapshotString(v.snapshot), snapshotString(s.Snapshot))
+			}
+		})
+	}
+}
+
+// TestHub_SnapshotHoldsEvents tests that events broadcast while a snapshot is taken are sent after it
+func TestHub_SnapshotHoldsEvents(t *testing.T) {
+	h, c := testHub(t)
+	ev := &Payload{Type: lib.Event_STATE_CHANGE, NodeId: testID, URL: "/PhysState", Value: "POWER_OFF"}
+	if e := h.handleAction(&Action{Command: SNAPSHOT, Client: c}); e != nil {
+		t.Fatal(e)
+	}
+	if e := h.handleAction(&Action{Command: SNAPSHOT, Client: c}); e != nil {
+		t.Fatal(e)
+	}
+	h.sendTo(c, ev)
+	if len(c.send) != 0 {
+		t.Fatalf("an event was sent before the snapshot")
+	}
+	h.sendSnapshot(<-h.snapshots)
+	if _, ok := (<-c.send).(*Snapshot); !ok || len(c.send) != 0 {
+		t.Fatalf("the event wasn't held until the last snapshot was sent")
+	}
+	h.sendSnapshot(<-h.snapshots)
+	if _, ok := (<-c.send).(*Snapshot); !ok {
+		t.Fatalf("the client wasn't sent the second snapshot")
+	}
+	if m := <-c.send; m != ev {
+		t.Errorf("expected the held event after the snapshot, got: %v", m)
+	}
+	if len(h.held) != 0 {
+		t.Errorf("the client is still waiting for a snapshot")
+	}
+	h.sendTo(c, ev)
+	if m := <-c.send; m != ev {
+		t.Errorf("an event was held after the snapshots were sent")
+	}
+
+	// a client that goes away while a snapshot is taken is forgotten, and so is its snapshot
+	if e := h.handleAction(&Action{Command: SNAPSHOT, Client: c}); e != nil {
+		t.Fatal(e)
+	}
+	h.drop(c)
+	h.sendSnapshot(<-h.snapshots)
+	if _, ok := <-c.send; ok {
+		t.Errorf("a client that went away was sent a snapshot")
+	}
+}
+
+func snapshotString(s []*SnapshotValue) string {
+	vs := []string{}
+	for _, v := range s {
+		vs = append(vs, fmt.Sprintf("%+v", *v))
+	}
+	return "[" + strings.Join(vs, " ") + "]"
+}